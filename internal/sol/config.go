@@ -3,9 +3,18 @@ package sol
 import (
 	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"sol/pkg/acl"
+	"sol/pkg/rtmp"
+	"sol/pkg/rtp"
+	"sol/pkg/rtsp"
+	"sol/pkg/tcpopt"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,42 +24,205 @@ type Config struct {
 	RTSP    RTSPConfig    `yaml:"rtsp"`
 	Logging LoggingConfig `yaml:"logging"`
 	Stream  StreamConfig  `yaml:"stream"`
+	Access  AccessConfig  `yaml:"access"`
+	Feed    FeedConfig    `yaml:"feed"`
+	TCP     TCPConfig     `yaml:"tcp"`
 }
 
 type RTMPConfig struct {
-	Port int `yaml:"port"`
+	Port           int `yaml:"port"`
+	MaxMessageSize int `yaml:"max_message_size"` // 수신 메시지 최대 크기 (바이트)
+	MinChunkSize   int `yaml:"min_chunk_size"`   // 허용하는 최소 Set Chunk Size (바이트)
+
+	ValidateMessageLength bool `yaml:"validate_message_length"` // 디버그용 메시지 길이 검증 및 불일치 덤프
+
+	// 이벤트 채널 버퍼 크기 (크면 메모리를 더 쓰고, 작으면 버스트 시 이벤트가 드롭됨)
+	EventChannelSize   int `yaml:"event_channel_size"`   // 모든 세션이 공유하는 서버 이벤트 채널
+	SessionChannelSize int `yaml:"session_channel_size"` // 세션별 메시지 채널
+
+	ReadBufferSize int `yaml:"read_buffer_size"` // 연결별 읽기 버퍼 크기 (바이트)
+
+	// 레거시 Flash 클라이언트의 소켓 정책 파일 요청(<policy-file-request/>)에 응답
+	FlashPolicy     bool   `yaml:"flash_policy"`
+	FlashPolicyFile string `yaml:"flash_policy_file"` // 응답할 cross-domain 정책 XML 파일, 비어 있으면 모든 도메인 허용
+
+	EventTiming bool `yaml:"event_timing"` // 이벤트 처리/브로드캐스트/인코딩 시간 히스토그램 집계 (성능 튜닝용)
+
+	FCPublishStyle string `yaml:"fcpublish_style"` // FCPublish/FCUnpublish 응답 형식 (srs, fms)
+
+	UnknownCommand string `yaml:"unknown_command"` // 알 수 없는 AMF0 명령어 응답 방식 (error, ignore)
+
+	MaxCommandRate int `yaml:"max_command_rate"` // 세션별 초당 최대 AMF 명령어 수, 초과하면 연결 종료 (0이면 무제한)
+
+	VirtualHosts bool `yaml:"virtual_hosts"` // 가상 호스트(connect의 vhost 파라미터 또는 tcUrl 호스트)별로 스트림 분리 (vhost/app/stream)
+
+	// 문제 분석용 원본 바이트 덤프 (연결별 파일에 송수신 바이트를 hex로 기록)
+	ByteDumpDir   string `yaml:"byte_dump_dir"`   // 덤프 파일 디렉토리 (비어 있으면 비활성화)
+	ByteDumpLimit int64  `yaml:"byte_dump_limit"` // 연결별로 기록할 최대 바이트
+
+	// 읽지 않는 클라이언트 감지 (connect 시 Window Acknowledgement Size를 알리고 ack가 멈추면 연결 종료)
+	AckWindowSize  int `yaml:"ack_window_size"`  // 알릴 윈도우 크기 (바이트, 0이면 비활성화)
+	AckStallFactor int `yaml:"ack_stall_factor"` // 미확인 바이트가 윈도우의 이 배수를 넘으면 연결 종료
 }
 
 type RTSPConfig struct {
-	Port    int `yaml:"port"`
-	Timeout int `yaml:"timeout"`
+	Port      int    `yaml:"port"`
+	Timeout   int    `yaml:"timeout"`
+	PlayStart string `yaml:"play_start"` // live: 라이브 엣지부터, start: 버퍼된 처음부터
+	RTPMTU    int    `yaml:"rtp_mtu"`    // RTP 패킷 최대 크기 (헤더 포함)
+
+	InterleavedFlushSize       int `yaml:"interleaved_flush_size"`        // TCP 인터리브 RTP 프레임 묶음 크기 (바이트), 0이면 프레임마다 전송
+	InterleavedFlushIntervalMs int `yaml:"interleaved_flush_interval_ms"` // 묶인 프레임의 최대 대기 시간, 0이면 기본값
+	MaxInterleavedFrameSize    int `yaml:"max_interleaved_frame_size"`    // 클라이언트가 보내는 인터리브 프레임 최대 크기 (바이트), 초과하면 연결 종료
+
+	InterleavedChannels string `yaml:"interleaved_channels"` // client: 클라이언트가 요청한 채널 쌍 (겹치면 빈 쌍), track: 트랙별 고정 쌍 (video 0-1, audio 2-3)
+
+	RTX bool `yaml:"rtx"` // SDP에 RTX(RFC 4588)를 추가하고 UDP 플레이어의 NACK에 재전송으로 응답
+
+	SDPSessionName string `yaml:"sdp_session_name"` // 생성한 SDP의 세션 이름 (s=)
+	SDPSessionInfo string `yaml:"sdp_session_info"` // 생성한 SDP의 세션 정보 (i=)
+	SDPAddress     string `yaml:"sdp_address"`      // 생성하거나 중계하는 SDP의 o=/c= 줄에 넣을 서버 IP (NAT 뒤의 공인 IP 등), 비어 있으면 클라이언트가 접속한 주소
+}
+
+// AccessConfig는 클라이언트 IP 기반 접근 제어 설정 (RTMP/RTSP 공통)
+type AccessConfig struct {
+	Connect AccessListConfig `yaml:"connect"` // 연결 수락 시 검사
+	Publish AccessListConfig `yaml:"publish"` // 발행 시 검사
+	Play    AccessListConfig `yaml:"play"`    // 재생 시 검사
+}
+
+// AccessListConfig는 CIDR 또는 단일 IP 목록 (deny 우선, allow가 비어 있으면 모두 허용)
+type AccessListConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// FeedConfig는 스트림 상태 알림 피드(Server-Sent Events) 설정
+type FeedConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    int    `yaml:"port"` // HTTP 포트
+	Path    string `yaml:"path"` // SSE 엔드포인트 경로
+}
+
+// TCPConfig는 RTMP/RTSP 서버가 수락한 연결에 적용할 TCP 소켓 옵션
+type TCPConfig struct {
+	NoDelay     bool `yaml:"no_delay"`     // Nagle 알고리즘 비활성화 (작은 오디오 청크를 모으지 않고 바로 전송)
+	KeepAlive   int  `yaml:"keep_alive"`   // TCP keepalive 주기 (초, 0이면 비활성화)
+	ReadBuffer  int  `yaml:"read_buffer"`  // 소켓 수신 버퍼 크기 (바이트, 0이면 OS 기본값)
+	WriteBuffer int  `yaml:"write_buffer"` // 소켓 송신 버퍼 크기 (바이트, 0이면 OS 기본값)
 }
 
 type LoggingConfig struct {
-	Level string `yaml:"level"`
+	Level     string          `yaml:"level"`
+	AccessLog AccessLogConfig `yaml:"access_log"` // 세션 종료 시 한 줄씩 남기는 RTMP 접근 로그
+
+	UnhandledEvents bool `yaml:"unhandled_events"` // 처리되지 않은 이벤트를 타입과 요약으로 경고 로그 (개수는 항상 집계)
+}
+
+// AccessLogConfig는 RTMP 접근 로그 출력 설정
+type AccessLogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`   // 접근 로그 파일 경로, 비어 있으면 stdout
+	Format  string `yaml:"format"` // text 또는 json
 }
 
 type StreamConfig struct {
-	GopCacheSize        int `yaml:"gop_cache_size"`
-	MaxPlayersPerStream int `yaml:"max_players_per_stream"`
+	GopCacheSize            int    `yaml:"gop_cache_size"`
+	MaxPlayersPerStream     int    `yaml:"max_players_per_stream"`
+	MaxStreams              int    `yaml:"max_streams"` // 서버 전체 최대 스트림 수, 0이면 무제한
+	RecordPath              string `yaml:"record_path"`
+	RecordPathTemplate      string `yaml:"record_path_template"`      // 녹화 파일 경로 템플릿 (record_path 기준 상대 경로 또는 절대 경로)
+	PublisherReconnectGrace int    `yaml:"publisher_reconnect_grace"` // 초 단위, 0이면 비활성화
+	LatencyBudgetMs         int    `yaml:"latency_budget_ms"`         // 플레이어 송신 지연 예산, 0이면 비활성화
+	InitialBurstPacingMs    int    `yaml:"initial_burst_pacing_ms"`   // 입장 시 캐시 버스트를 나눠 보낼 시간, 0이면 한 번에 전송
+	MaxPlayerDroppedFrames  int    `yaml:"max_player_dropped_frames"` // 저지연 송신 큐에서 버린 프레임이 넘으면 플레이어 연결 종료, 0이면 제한 없음
+	IdleCacheCompressAfter  int    `yaml:"idle_cache_compress_after"` // 초 단위, 플레이어 없는 스트림의 캐시 압축, 0이면 비활성화
+	IdleCacheMinBytes       int    `yaml:"idle_cache_min_bytes"`      // 이보다 작은 캐시는 압축하지 않음
+	InactiveStreamSweep     int    `yaml:"inactive_stream_sweep"`     // 초 단위, 남은 비활성 스트림 정리 주기, 0이면 비활성화
+	PresenceTTL             int    `yaml:"presence_ttl"`              // 초 단위, 마지막 플레이어가 나간 뒤 풀 스트림을 정지하기까지의 시간, 0이면 비활성화
+	WaitForPlayable         bool   `yaml:"wait_for_playable"`         // 재생 준비 전 입장한 플레이어를 대기시킴
+	CacheEviction           string `yaml:"cache_eviction"`            // 비디오 캐시 제거 정책 (frames, duration)
+	CacheDurationMs         int    `yaml:"cache_duration_ms"`         // duration 정책에서 캐시를 유지하는 시간
+	ResumablePlay           bool   `yaml:"resumable_play"`            // 재생 재개 토큰 발급 및 토큰으로 재연결한 플레이어 이어서 재생
+	ResumeTokenTTL          int    `yaml:"resume_token_ttl"`          // 초 단위, 연결이 끊긴 뒤 토큰이 유효한 시간
+	MaxPublishBitrateKbps   int    `yaml:"max_publish_bitrate_kbps"`  // 스트림별 최대 발행 비트레이트, 0이면 무제한
+	AVSyncCorrection        bool   `yaml:"av_sync_correction"`        // 오디오/비디오 타임스탬프 드리프트 보정
+	AVSyncToleranceMs       int    `yaml:"av_sync_tolerance_ms"`      // 보정하지 않고 허용하는 드리프트
+	OfflinePlay             string `yaml:"offline_play"`              // 발행자가 없는 스트림 재생 시 동작 (hold, not_found)
+	StreamNamePolicy        string `yaml:"stream_name_policy"`        // 스트림 이름에 허용하는 문자 범위 (unicode, ascii, any)
+	UnsupportedCodec        string `yaml:"unsupported_codec"`         // 플레이어가 connect에서 알리지 않은 코덱의 미디어 처리 (warn, skip)
+	WarmUpBufferMs          int    `yaml:"warm_up_buffer_ms"`         // 플레이어 없이 발행 중인 스트림이 유지할 캐시 구간, 0이면 비활성화
+
+	MetadataFilter MetadataFilterConfig `yaml:"metadata_filter"` // 플레이어에게 전달할 onMetaData 키 필터
+
+	StampMetadata bool `yaml:"stamp_metadata"` // onMetaData를 현재 스트림 타임스탬프와 플레이어 스트림 ID로 전송 (false면 타임스탬프 0)
+
+	AppRecordPathTemplates map[string]string `yaml:"app_record_path_templates"` // 앱 이름별 녹화 파일 경로 템플릿 (record_path_template보다 우선)
+}
+
+// MetadataFilterConfig는 플레이어에게 전달할 onMetaData 키 목록 (deny 우선, allow가 비어 있으면 모두 전달)
+// 스트림 캐시와 녹화에는 원본 메타데이터를 그대로 사용
+type MetadataFilterConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
 }
 
 // GetConfigWithDefaults returns default configuration values
 func GetConfigWithDefaults() *Config {
 	return &Config{
 		RTMP: RTMPConfig{
-			Port: 1935,
+			Port:           1935,
+			MaxMessageSize: rtmp.DEFAULT_MAX_MESSAGE_SIZE,
+			MinChunkSize:   rtmp.DEFAULT_MIN_CHUNK_SIZE,
+
+			EventChannelSize:   rtmp.DEFAULT_EVENT_CHANNEL_SIZE,
+			SessionChannelSize: rtmp.DEFAULT_SESSION_CHANNEL_SIZE,
+			ReadBufferSize:     rtmp.DEFAULT_READ_BUFFER_SIZE,
+
+			FCPublishStyle: string(rtmp.FCPublishStyleSRS),
+			UnknownCommand: string(rtmp.UnknownCommandError),
+			ByteDumpLimit:  rtmp.DEFAULT_BYTE_DUMP_LIMIT,
+			AckStallFactor: rtmp.DEFAULT_ACK_STALL_FACTOR,
 		},
 		RTSP: RTSPConfig{
-			Port: 554,
-			Timeout: 60,
+			Port:                    554,
+			Timeout:                 60,
+			PlayStart:               rtsp.PlayStartLive,
+			RTPMTU:                  rtp.DefaultMTU,
+			MaxInterleavedFrameSize: rtsp.DefaultMaxInterleavedFrameSize,
+			InterleavedChannels:     rtsp.ChannelAssignmentClient,
+			SDPSessionName:          rtsp.DefaultSDPSessionName,
+			SDPSessionInfo:          rtsp.DefaultSDPSessionInfo,
 		},
 		Logging: LoggingConfig{
-			Level: "info",
+			Level:           "info",
+			UnhandledEvents: true,
+			AccessLog: AccessLogConfig{
+				Format: string(rtmp.AccessLogText),
+			},
 		},
 		Stream: StreamConfig{
 			GopCacheSize:        10,
 			MaxPlayersPerStream: 100,
+			RecordPath:          "recordings",
+			RecordPathTemplate:  rtmp.DEFAULT_RECORD_PATH_TEMPLATE,
+			CacheEviction:       string(rtmp.CacheEvictionFrames),
+			CacheDurationMs:     2000,
+			ResumeTokenTTL:      30,
+			InactiveStreamSweep: 60,
+			AVSyncToleranceMs:   40,
+			OfflinePlay:         string(rtmp.OfflinePlayHold),
+			StreamNamePolicy:    string(rtmp.StreamNameUnicode),
+			UnsupportedCodec:    string(rtmp.UnsupportedCodecWarn),
+			IdleCacheMinBytes:   rtmp.DEFAULT_IDLE_CACHE_MIN_BYTES,
+		},
+		Feed: FeedConfig{
+			Port: 8080,
+			Path: "/events",
+		},
+		TCP: TCPConfig{
+			NoDelay:   true,
+			KeepAlive: 15,
 		},
 	}
 }
@@ -62,43 +234,102 @@ func LoadConfig() (*Config, error) {
 
 	// 설정 파일 경로 결정 (프로젝트 루트의 configs/default.yaml)
 	configPath := filepath.Join("configs", "default.yaml")
-	
+
 	// 파일 존재 확인 - 없으면 기본값 사용
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		fmt.Printf("Config file not found (%s), using default values:\n", configPath)
-		fmt.Printf("  RTMP Port: %d\n", config.RTMP.Port)
-		fmt.Printf("  RTSP Port: %d\n", config.RTSP.Port)
-		fmt.Printf("  RTSP Timeout: %d\n", config.RTSP.Timeout)
-		fmt.Printf("  Log Level: %s\n", config.Logging.Level)
-	fmt.Printf("  GOP Cache Size: %d\n", config.Stream.GopCacheSize)
-	fmt.Printf("  Max Players Per Stream: %d\n", config.Stream.MaxPlayersPerStream)
+		printConfig(config)
 		return config, nil
 	}
-	
+
 	// 파일 읽기
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
-	
+
 	// YAML 파싱 - 기존 기본값 위에 덮어쓰기
 	if err := yaml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
-	
+
 	// 설정 검증
 	if err := config.validate(); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
-	
+
+	// 특권 포트(1024 미만)는 root 권한 없이 바인딩할 수 없으므로 경고
+	if os.Geteuid() != 0 {
+		for _, port := range config.privilegedPorts() {
+			fmt.Printf("Warning: port %d is privileged (< 1024) and may fail to bind without root\n", port)
+		}
+	}
+
 	fmt.Printf("Config loaded from %s:\n", configPath)
+	printConfig(config)
+	return config, nil
+}
+
+// printConfig는 적용된 설정 값을 출력 (기본값과 설정 파일 모두 같은 목록)
+func printConfig(config *Config) {
 	fmt.Printf("  RTMP Port: %d\n", config.RTMP.Port)
+	fmt.Printf("  RTMP Max Message Size: %d\n", config.RTMP.MaxMessageSize)
+	fmt.Printf("  RTMP Min Chunk Size: %d\n", config.RTMP.MinChunkSize)
+	fmt.Printf("  RTMP Validate Message Length: %t\n", config.RTMP.ValidateMessageLength)
+	fmt.Printf("  RTMP Event Channel Size: %d\n", config.RTMP.EventChannelSize)
+	fmt.Printf("  RTMP Session Channel Size: %d\n", config.RTMP.SessionChannelSize)
+	fmt.Printf("  RTMP Read Buffer Size: %d\n", config.RTMP.ReadBufferSize)
+	fmt.Printf("  RTMP Flash Policy: %t (file: %q)\n", config.RTMP.FlashPolicy, config.RTMP.FlashPolicyFile)
+	fmt.Printf("  RTMP Event Timing: %t\n", config.RTMP.EventTiming)
+	fmt.Printf("  RTMP FCPublish Style: %s\n", config.RTMP.FCPublishStyle)
+	fmt.Printf("  RTMP Unknown Command: %s\n", config.RTMP.UnknownCommand)
+	fmt.Printf("  RTMP Max Command Rate: %d\n", config.RTMP.MaxCommandRate)
+	fmt.Printf("  RTMP Virtual Hosts: %t\n", config.RTMP.VirtualHosts)
+	fmt.Printf("  RTMP Byte Dump: %q (limit: %d)\n", config.RTMP.ByteDumpDir, config.RTMP.ByteDumpLimit)
+	fmt.Printf("  RTMP Ack Window Size: %d (stall factor: %d)\n", config.RTMP.AckWindowSize, config.RTMP.AckStallFactor)
 	fmt.Printf("  RTSP Port: %d\n", config.RTSP.Port)
 	fmt.Printf("  RTSP Timeout: %d\n", config.RTSP.Timeout)
+	fmt.Printf("  RTSP Play Start: %s\n", config.RTSP.PlayStart)
+	fmt.Printf("  RTP MTU: %d\n", config.RTSP.RTPMTU)
+	fmt.Printf("  RTSP Interleaved Flush Size: %d\n", config.RTSP.InterleavedFlushSize)
+	fmt.Printf("  RTSP Interleaved Flush Interval (ms): %d\n", config.RTSP.InterleavedFlushIntervalMs)
+	fmt.Printf("  RTSP Max Interleaved Frame Size: %d\n", config.RTSP.MaxInterleavedFrameSize)
+	fmt.Printf("  RTSP Interleaved Channels: %s\n", config.RTSP.InterleavedChannels)
+	fmt.Printf("  RTSP RTX: %t\n", config.RTSP.RTX)
+	fmt.Printf("  RTSP SDP Session Name: %s\n", config.RTSP.SDPSessionName)
+	fmt.Printf("  RTSP SDP Address: %s\n", config.RTSP.SDPAddress)
 	fmt.Printf("  Log Level: %s\n", config.Logging.Level)
+	fmt.Printf("  Access Log: %t (path: %q, format: %s)\n", config.Logging.AccessLog.Enabled, config.Logging.AccessLog.Path, config.Logging.AccessLog.Format)
+	fmt.Printf("  Log Unhandled Events: %t\n", config.Logging.UnhandledEvents)
+	fmt.Printf("  Feed: %t (port: %d, path: %s)\n", config.Feed.Enabled, config.Feed.Port, config.Feed.Path)
+	fmt.Printf("  TCP: nodelay=%t, keepalive=%ds, read_buffer=%d, write_buffer=%d\n", config.TCP.NoDelay, config.TCP.KeepAlive, config.TCP.ReadBuffer, config.TCP.WriteBuffer)
 	fmt.Printf("  GOP Cache Size: %d\n", config.Stream.GopCacheSize)
 	fmt.Printf("  Max Players Per Stream: %d\n", config.Stream.MaxPlayersPerStream)
-	return config, nil
+	fmt.Printf("  Max Streams: %d\n", config.Stream.MaxStreams)
+	fmt.Printf("  Record Path: %s\n", config.Stream.RecordPath)
+	fmt.Printf("  Record Path Template: %s (per app: %v)\n", config.Stream.RecordPathTemplate, config.Stream.AppRecordPathTemplates)
+	fmt.Printf("  Publisher Reconnect Grace: %d\n", config.Stream.PublisherReconnectGrace)
+	fmt.Printf("  Latency Budget (ms): %d\n", config.Stream.LatencyBudgetMs)
+	fmt.Printf("  Initial Burst Pacing (ms): %d\n", config.Stream.InitialBurstPacingMs)
+	fmt.Printf("  Max Player Dropped Frames: %d\n", config.Stream.MaxPlayerDroppedFrames)
+	fmt.Printf("  Idle Cache Compress After: %ds (min bytes: %d)\n", config.Stream.IdleCacheCompressAfter, config.Stream.IdleCacheMinBytes)
+	fmt.Printf("  Inactive Stream Sweep: %ds\n", config.Stream.InactiveStreamSweep)
+	fmt.Printf("  Presence TTL: %ds\n", config.Stream.PresenceTTL)
+	fmt.Printf("  Warm-up Buffer (ms): %d\n", config.Stream.WarmUpBufferMs)
+	fmt.Printf("  Wait For Playable: %t\n", config.Stream.WaitForPlayable)
+	fmt.Printf("  Cache Eviction: %s\n", config.Stream.CacheEviction)
+	fmt.Printf("  Cache Duration (ms): %d\n", config.Stream.CacheDurationMs)
+	fmt.Printf("  Resumable Play: %t (token ttl: %ds)\n", config.Stream.ResumablePlay, config.Stream.ResumeTokenTTL)
+	fmt.Printf("  Max Publish Bitrate (kbps): %d\n", config.Stream.MaxPublishBitrateKbps)
+	fmt.Printf("  A/V Sync Correction: %t (tolerance: %dms)\n", config.Stream.AVSyncCorrection, config.Stream.AVSyncToleranceMs)
+	fmt.Printf("  Offline Play: %s\n", config.Stream.OfflinePlay)
+	fmt.Printf("  Stream Name Policy: %s\n", config.Stream.StreamNamePolicy)
+	fmt.Printf("  Unsupported Codec: %s\n", config.Stream.UnsupportedCodec)
+	fmt.Printf("  Metadata Filter: allow=%v deny=%v\n", config.Stream.MetadataFilter.Allow, config.Stream.MetadataFilter.Deny)
+	fmt.Printf("  Stamp Metadata: %t\n", config.Stream.StampMetadata)
+	fmt.Printf("  Access Connect: allow=%v deny=%v\n", config.Access.Connect.Allow, config.Access.Connect.Deny)
+	fmt.Printf("  Access Publish: allow=%v deny=%v\n", config.Access.Publish.Allow, config.Access.Publish.Deny)
+	fmt.Printf("  Access Play: allow=%v deny=%v\n", config.Access.Play.Allow, config.Access.Play.Deny)
 }
 
 // validate checks if the configuration is valid
@@ -107,19 +338,124 @@ func (c *Config) validate() error {
 	if c.RTMP.Port <= 0 || c.RTMP.Port > 65535 {
 		return fmt.Errorf("invalid rtmp port: %d (must be between 1-65535)", c.RTMP.Port)
 	}
-	
+
+	// RTMP 최대 메시지 크기 검증
+	if c.RTMP.MaxMessageSize <= 0 || c.RTMP.MaxMessageSize > rtmp.MAX_MESSAGE_LENGTH {
+		return fmt.Errorf("invalid rtmp max_message_size: %d (must be between 1-%d)", c.RTMP.MaxMessageSize, rtmp.MAX_MESSAGE_LENGTH)
+	}
+	if c.RTMP.MinChunkSize < 1 || c.RTMP.MinChunkSize > rtmp.MAX_CHUNK_SIZE {
+		return fmt.Errorf("invalid rtmp min_chunk_size: %d (must be between 1-%d)", c.RTMP.MinChunkSize, rtmp.MAX_CHUNK_SIZE)
+	}
+
+	// 이벤트 채널 버퍼 크기 검증
+	if c.RTMP.EventChannelSize < 1 || c.RTMP.EventChannelSize > rtmp.MAX_EVENT_CHANNEL_SIZE {
+		return fmt.Errorf("invalid rtmp event_channel_size: %d (must be between 1-%d)", c.RTMP.EventChannelSize, rtmp.MAX_EVENT_CHANNEL_SIZE)
+	}
+	if c.RTMP.SessionChannelSize < 1 || c.RTMP.SessionChannelSize > rtmp.MAX_EVENT_CHANNEL_SIZE {
+		return fmt.Errorf("invalid rtmp session_channel_size: %d (must be between 1-%d)", c.RTMP.SessionChannelSize, rtmp.MAX_EVENT_CHANNEL_SIZE)
+	}
+
+	// 읽기 버퍼 크기 검증
+	if c.RTMP.ReadBufferSize < 1 || c.RTMP.ReadBufferSize > rtmp.MAX_READ_BUFFER_SIZE {
+		return fmt.Errorf("invalid rtmp read_buffer_size: %d (must be between 1-%d)", c.RTMP.ReadBufferSize, rtmp.MAX_READ_BUFFER_SIZE)
+	}
+
+	// FCPublish 응답 형식 검증
+	switch rtmp.FCPublishStyle(c.RTMP.FCPublishStyle) {
+	case rtmp.FCPublishStyleSRS, rtmp.FCPublishStyleFMS:
+	default:
+		return fmt.Errorf("invalid rtmp fcpublish_style: %s (must be one of: srs, fms)", c.RTMP.FCPublishStyle)
+	}
+
+	// 알 수 없는 명령어 응답 방식 검증
+	switch rtmp.UnknownCommandPolicy(c.RTMP.UnknownCommand) {
+	case rtmp.UnknownCommandError, rtmp.UnknownCommandIgnore:
+	default:
+		return fmt.Errorf("invalid rtmp unknown_command: %s (must be one of: error, ignore)", c.RTMP.UnknownCommand)
+	}
+
+	// 명령어 속도 제한 검증
+	if c.RTMP.MaxCommandRate < 0 {
+		return fmt.Errorf("invalid rtmp max_command_rate: %d (must be non-negative)", c.RTMP.MaxCommandRate)
+	}
+
+	// 원본 바이트 덤프 예산 검증
+	if c.RTMP.ByteDumpLimit < 1 {
+		return fmt.Errorf("invalid rtmp byte_dump_limit: %d (must be positive)", c.RTMP.ByteDumpLimit)
+	}
+
+	// ack 윈도우 검증 (윈도우는 32비트 시퀀스 번호 범위 안이어야 함)
+	if c.RTMP.AckWindowSize < 0 || c.RTMP.AckWindowSize > math.MaxInt32 {
+		return fmt.Errorf("invalid rtmp ack_window_size: %d (must be between 0-%d)", c.RTMP.AckWindowSize, math.MaxInt32)
+	}
+	if c.RTMP.AckStallFactor < 1 {
+		return fmt.Errorf("invalid rtmp ack_stall_factor: %d (must be positive)", c.RTMP.AckStallFactor)
+	}
+
+	// Flash 정책 파일 검증 (활성화된 경우에만)
+	if c.RTMP.FlashPolicy && c.RTMP.FlashPolicyFile != "" {
+		if _, err := os.Stat(c.RTMP.FlashPolicyFile); err != nil {
+			return fmt.Errorf("invalid rtmp flash_policy_file: %w", err)
+		}
+	}
+
 	// RTSP 포트 검증
 	if c.RTSP.Port <= 0 || c.RTSP.Port > 65535 {
 		return fmt.Errorf("invalid rtsp port: %d (must be between 1-65535)", c.RTSP.Port)
 	}
-	
+
+	// 포트 충돌 검증 (RTMP/RTSP/RTP는 서로 다른 포트를 사용해야 함)
+	if c.RTMP.Port == c.RTSP.Port {
+		return fmt.Errorf("rtmp port and rtsp port must differ: both are %d", c.RTMP.Port)
+	}
+	rtpPort := c.RTSP.Port + rtsp.RTPPortOffset
+	if rtpPort > 65535 {
+		return fmt.Errorf("invalid rtsp port: %d (RTP port %d = rtsp port + %d exceeds 65535)", c.RTSP.Port, rtpPort, rtsp.RTPPortOffset)
+	}
+	if rtpPort == c.RTMP.Port {
+		return fmt.Errorf("rtmp port %d conflicts with the RTP port (rtsp port + %d)", c.RTMP.Port, rtsp.RTPPortOffset)
+	}
+
 	// RTSP 타임아웃 검증
 	if c.RTSP.Timeout <= 0 {
 		return fmt.Errorf("invalid rtsp timeout: %d (must be positive)", c.RTSP.Timeout)
 	}
-	
+
+	// RTSP 재생 시작 정책 검증
+	if _, err := rtsp.ParsePlayStartPolicy(c.RTSP.PlayStart); err != nil {
+		return err
+	}
+
+	// RTSP 인터리브 채널 할당 방식 검증
+	if _, err := rtsp.ParseChannelAssignment(c.RTSP.InterleavedChannels); err != nil {
+		return err
+	}
+
+	// RTP MTU 검증
+	if err := rtp.ValidateMTU(c.RTSP.RTPMTU); err != nil {
+		return err
+	}
+
+	// 인터리브 프레임 묶음 설정 검증
+	if c.RTSP.InterleavedFlushSize < 0 {
+		return fmt.Errorf("invalid interleaved_flush_size: %d (must be non-negative)", c.RTSP.InterleavedFlushSize)
+	}
+	if c.RTSP.InterleavedFlushIntervalMs < 0 {
+		return fmt.Errorf("invalid interleaved_flush_interval_ms: %d (must be non-negative)", c.RTSP.InterleavedFlushIntervalMs)
+	}
+
+	// 인터리브 프레임 최대 크기 검증 (16비트 길이 필드 범위)
+	if c.RTSP.MaxInterleavedFrameSize <= 0 || c.RTSP.MaxInterleavedFrameSize > rtsp.MaxInterleavedFrameSize {
+		return fmt.Errorf("invalid max_interleaved_frame_size: %d (must be between 1-%d)", c.RTSP.MaxInterleavedFrameSize, rtsp.MaxInterleavedFrameSize)
+	}
+
+	// SDP 광고 주소 검증 (호스트 이름은 o=/c= 줄에 쓸 수 없음)
+	if c.RTSP.SDPAddress != "" && net.ParseIP(c.RTSP.SDPAddress) == nil {
+		return fmt.Errorf("invalid sdp_address: %s (must be an IP address)", c.RTSP.SDPAddress)
+	}
+
 	// 로그 레벨 검증
-	validLevels := []string{"debug", "info", "warn", "error"}
+	validLevels := []string{"trace", "debug", "info", "warn", "error"}
 	levelValid := false
 	for _, level := range validLevels {
 		if strings.ToLower(c.Logging.Level) == level {
@@ -130,22 +466,180 @@ func (c *Config) validate() error {
 	if !levelValid {
 		return fmt.Errorf("invalid log level: %s (must be one of: %v)", c.Logging.Level, validLevels)
 	}
-	
+
+	switch rtmp.AccessLogFormat(c.Logging.AccessLog.Format) {
+	case rtmp.AccessLogText, rtmp.AccessLogJSON:
+	default:
+		return fmt.Errorf("invalid access_log.format: %s (must be one of: text, json)", c.Logging.AccessLog.Format)
+	}
+
 	// 스트림 설정 검증
 	if c.Stream.GopCacheSize < 0 {
 		return fmt.Errorf("invalid gop_cache_size: %d (must be non-negative)", c.Stream.GopCacheSize)
 	}
-	
+
 	if c.Stream.MaxPlayersPerStream < 0 {
 		return fmt.Errorf("invalid max_players_per_stream: %d (must be non-negative)", c.Stream.MaxPlayersPerStream)
 	}
-	
+
+	if c.Stream.MaxStreams < 0 {
+		return fmt.Errorf("invalid max_streams: %d (must be non-negative)", c.Stream.MaxStreams)
+	}
+
+	if c.Stream.RecordPath == "" {
+		return fmt.Errorf("invalid record_path: must not be empty")
+	}
+
+	if err := rtmp.ValidateRecordPathTemplate(c.Stream.RecordPathTemplate); err != nil {
+		return fmt.Errorf("invalid record_path_template: %w", err)
+	}
+
+	apps := make([]string, 0, len(c.Stream.AppRecordPathTemplates))
+	for app := range c.Stream.AppRecordPathTemplates {
+		apps = append(apps, app)
+	}
+	slices.Sort(apps)
+	for _, app := range apps {
+		if app == "" {
+			return fmt.Errorf("invalid app_record_path_templates: empty app name")
+		}
+		if err := rtmp.ValidateRecordPathTemplate(c.Stream.AppRecordPathTemplates[app]); err != nil {
+			return fmt.Errorf("invalid app_record_path_templates[%s]: %w", app, err)
+		}
+	}
+
+	if c.Stream.PublisherReconnectGrace < 0 {
+		return fmt.Errorf("invalid publisher_reconnect_grace: %d (must be non-negative)", c.Stream.PublisherReconnectGrace)
+	}
+
+	if c.Stream.LatencyBudgetMs < 0 {
+		return fmt.Errorf("invalid latency_budget_ms: %d (must be non-negative)", c.Stream.LatencyBudgetMs)
+	}
+
+	if c.Stream.InitialBurstPacingMs < 0 {
+		return fmt.Errorf("invalid initial_burst_pacing_ms: %d (must be non-negative)", c.Stream.InitialBurstPacingMs)
+	}
+
+	if c.Stream.MaxPlayerDroppedFrames < 0 {
+		return fmt.Errorf("invalid max_player_dropped_frames: %d (must be non-negative)", c.Stream.MaxPlayerDroppedFrames)
+	}
+
+	if c.Stream.IdleCacheCompressAfter < 0 {
+		return fmt.Errorf("invalid idle_cache_compress_after: %d (must be non-negative)", c.Stream.IdleCacheCompressAfter)
+	}
+
+	if c.Stream.IdleCacheMinBytes < 0 {
+		return fmt.Errorf("invalid idle_cache_min_bytes: %d (must be non-negative)", c.Stream.IdleCacheMinBytes)
+	}
+
+	if c.Stream.InactiveStreamSweep < 0 {
+		return fmt.Errorf("invalid inactive_stream_sweep: %d (must be non-negative)", c.Stream.InactiveStreamSweep)
+	}
+
+	if c.Stream.PresenceTTL < 0 {
+		return fmt.Errorf("invalid presence_ttl: %d (must be non-negative)", c.Stream.PresenceTTL)
+	}
+
+	switch rtmp.CacheEvictionPolicy(c.Stream.CacheEviction) {
+	case rtmp.CacheEvictionFrames, rtmp.CacheEvictionDuration:
+	default:
+		return fmt.Errorf("invalid cache_eviction: %s (must be one of: frames, duration)", c.Stream.CacheEviction)
+	}
+
+	if c.Stream.WarmUpBufferMs < 0 {
+		return fmt.Errorf("invalid warm_up_buffer_ms: %d (must be non-negative)", c.Stream.WarmUpBufferMs)
+	}
+
+	if c.Stream.CacheDurationMs < 0 {
+		return fmt.Errorf("invalid cache_duration_ms: %d (must be non-negative)", c.Stream.CacheDurationMs)
+	}
+
+	if c.Stream.ResumeTokenTTL < 0 {
+		return fmt.Errorf("invalid resume_token_ttl: %d (must be non-negative)", c.Stream.ResumeTokenTTL)
+	}
+
+	// 최대 발행 비트레이트 검증 (0은 무제한)
+	if c.Stream.MaxPublishBitrateKbps < 0 {
+		return fmt.Errorf("invalid max_publish_bitrate_kbps: %d (must be non-negative)", c.Stream.MaxPublishBitrateKbps)
+	}
+
+	if c.Stream.AVSyncToleranceMs < 0 {
+		return fmt.Errorf("invalid av_sync_tolerance_ms: %d (must be non-negative)", c.Stream.AVSyncToleranceMs)
+	}
+
+	switch rtmp.OfflinePlayPolicy(c.Stream.OfflinePlay) {
+	case rtmp.OfflinePlayHold, rtmp.OfflinePlayNotFound:
+	default:
+		return fmt.Errorf("invalid offline_play: %s (must be one of: hold, not_found)", c.Stream.OfflinePlay)
+	}
+
+	switch rtmp.StreamNamePolicy(c.Stream.StreamNamePolicy) {
+	case rtmp.StreamNameUnicode, rtmp.StreamNameASCII, rtmp.StreamNameAny:
+	default:
+		return fmt.Errorf("invalid stream_name_policy: %s (must be one of: unicode, ascii, any)", c.Stream.StreamNamePolicy)
+	}
+
+	switch rtmp.UnsupportedCodecPolicy(c.Stream.UnsupportedCodec) {
+	case rtmp.UnsupportedCodecWarn, rtmp.UnsupportedCodecSkip:
+	default:
+		return fmt.Errorf("invalid unsupported_codec: %s (must be one of: warn, skip)", c.Stream.UnsupportedCodec)
+	}
+
+	// 메타데이터 필터 키 검증
+	for _, key := range slices.Concat(c.Stream.MetadataFilter.Allow, c.Stream.MetadataFilter.Deny) {
+		if key == "" {
+			return fmt.Errorf("invalid metadata_filter: empty key")
+		}
+	}
+
+	// 접근 제어 목록 검증
+	if _, err := c.buildAccessPolicy(); err != nil {
+		return err
+	}
+
+	// 상태 알림 피드 검증 (활성화된 경우에만, RTMP/RTSP/RTP 포트와 겹치면 안 됨)
+	if c.Feed.Enabled {
+		if c.Feed.Port <= 0 || c.Feed.Port > 65535 {
+			return fmt.Errorf("invalid feed port: %d (must be between 1-65535)", c.Feed.Port)
+		}
+		if c.Feed.Port == c.RTMP.Port || c.Feed.Port == c.RTSP.Port || c.Feed.Port == rtpPort {
+			return fmt.Errorf("feed port %d conflicts with the rtmp, rtsp or RTP port", c.Feed.Port)
+		}
+		if !strings.HasPrefix(c.Feed.Path, "/") {
+			return fmt.Errorf("invalid feed path: %q (must start with /)", c.Feed.Path)
+		}
+	}
+
+	// TCP 소켓 옵션 검증
+	if c.TCP.KeepAlive < 0 {
+		return fmt.Errorf("invalid tcp keep_alive: %d (must be non-negative)", c.TCP.KeepAlive)
+	}
+	if c.TCP.ReadBuffer < 0 || c.TCP.WriteBuffer < 0 {
+		return fmt.Errorf("invalid tcp buffer size: read %d, write %d (must be non-negative)", c.TCP.ReadBuffer, c.TCP.WriteBuffer)
+	}
+
 	return nil
 }
 
+// privilegedPorts returns configured listen ports below 1024
+func (c *Config) privilegedPorts() []int {
+	var ports []int
+	for _, port := range []int{c.RTMP.Port, c.RTSP.Port} {
+		if port < 1024 {
+			ports = append(ports, port)
+		}
+	}
+	if c.Feed.Enabled && c.Feed.Port < 1024 {
+		ports = append(ports, c.Feed.Port)
+	}
+	return ports
+}
+
 // GetSlogLevel returns slog.Level from config
 func (c *Config) GetSlogLevel() slog.Level {
 	switch strings.ToLower(c.Logging.Level) {
+	case "trace":
+		return rtmp.LevelTrace // 청크/메시지 단위 로그까지 출력
 	case "debug":
 		return slog.LevelDebug
 	case "info":
@@ -158,3 +652,65 @@ func (c *Config) GetSlogLevel() slog.Level {
 		return slog.LevelInfo // 기본값
 	}
 }
+
+// GetPlayStartPolicy returns rtsp.PlayStartPolicy from config
+func (c *Config) GetPlayStartPolicy() rtsp.PlayStartPolicy {
+	policy, err := rtsp.ParsePlayStartPolicy(c.RTSP.PlayStart)
+	if err != nil {
+		return rtsp.PlayFromLiveEdge // 기본값
+	}
+	return policy
+}
+
+// GetChannelAssignment returns rtsp.ChannelAssignment from config
+func (c *Config) GetChannelAssignment() rtsp.ChannelAssignment {
+	assignment, err := rtsp.ParseChannelAssignment(c.RTSP.InterleavedChannels)
+	if err != nil {
+		return rtsp.ChannelsFromClient // 기본값
+	}
+	return assignment
+}
+
+// GetAccessPolicy returns acl.Policy from config (nil if no lists are configured)
+func (c *Config) GetAccessPolicy() *acl.Policy {
+	policy, err := c.buildAccessPolicy()
+	if err != nil {
+		return nil // validate()에서 이미 검증됨
+	}
+	return policy
+}
+
+// GetTCPOptions returns tcpopt.Options from config
+func (c *Config) GetTCPOptions() *tcpopt.Options {
+	return &tcpopt.Options{
+		NoDelay:     c.TCP.NoDelay,
+		KeepAlive:   time.Duration(c.TCP.KeepAlive) * time.Second,
+		ReadBuffer:  c.TCP.ReadBuffer,
+		WriteBuffer: c.TCP.WriteBuffer,
+	}
+}
+
+// buildAccessPolicy는 접근 제어 설정을 acl.Policy로 변환
+func (c *Config) buildAccessPolicy() (*acl.Policy, error) {
+	connect, err := acl.NewList(c.Access.Connect.Allow, c.Access.Connect.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access.connect: %w", err)
+	}
+	publish, err := acl.NewList(c.Access.Publish.Allow, c.Access.Publish.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access.publish: %w", err)
+	}
+	play, err := acl.NewList(c.Access.Play.Allow, c.Access.Play.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access.play: %w", err)
+	}
+
+	if connect == nil && publish == nil && play == nil {
+		return nil, nil
+	}
+	return &acl.Policy{
+		Connect: connect,
+		Publish: publish,
+		Play:    play,
+	}, nil
+}
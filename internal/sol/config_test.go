@@ -0,0 +1,121 @@
+package sol
+
+import (
+	"log/slog"
+	"sol/pkg/rtmp"
+	"testing"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if err := GetConfigWithDefaults().validate(); err != nil {
+		t.Fatalf("expected default config to be valid, got: %v", err)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"rtmp port out of range", func(c *Config) { c.RTMP.Port = 70000 }},
+		{"rtsp port out of range", func(c *Config) { c.RTSP.Port = 0 }},
+		{"rtmp and rtsp share a port", func(c *Config) { c.RTMP.Port = 8554; c.RTSP.Port = 8554 }},
+		{"rtp port exceeds range", func(c *Config) { c.RTSP.Port = 65000 }},
+		{"rtmp port collides with rtp port", func(c *Config) { c.RTSP.Port = 8554; c.RTMP.Port = 9554 }},
+		{"rtmp max message size zero", func(c *Config) { c.RTMP.MaxMessageSize = 0 }},
+		{"rtmp max message size too large", func(c *Config) { c.RTMP.MaxMessageSize = 1 << 24 }},
+		{"rtmp event channel size zero", func(c *Config) { c.RTMP.EventChannelSize = 0 }},
+		{"unknown rtmp fcpublish style", func(c *Config) { c.RTMP.FCPublishStyle = "wowza" }},
+		{"unknown rtmp unknown command policy", func(c *Config) { c.RTMP.UnknownCommand = "result" }},
+		{"negative rtmp max command rate", func(c *Config) { c.RTMP.MaxCommandRate = -1 }},
+		{"rtmp flash policy file missing", func(c *Config) {
+			c.RTMP.FlashPolicy = true
+			c.RTMP.FlashPolicyFile = "does-not-exist.xml"
+		}},
+		{"rtmp session channel size too large", func(c *Config) { c.RTMP.SessionChannelSize = 1 << 21 }},
+		{"rtmp read buffer size zero", func(c *Config) { c.RTMP.ReadBufferSize = 0 }},
+		{"rtmp byte dump limit zero", func(c *Config) { c.RTMP.ByteDumpLimit = 0 }},
+		{"negative rtmp ack window size", func(c *Config) { c.RTMP.AckWindowSize = -1 }},
+		{"rtmp ack stall factor zero", func(c *Config) { c.RTMP.AckStallFactor = 0 }},
+		{"rtmp min chunk size zero", func(c *Config) { c.RTMP.MinChunkSize = 0 }},
+		{"rtsp timeout zero", func(c *Config) { c.RTSP.Timeout = 0 }},
+		{"unknown play start", func(c *Config) { c.RTSP.PlayStart = "middle" }},
+		{"unknown interleaved channel assignment", func(c *Config) { c.RTSP.InterleavedChannels = "random" }},
+		{"rtp mtu too small", func(c *Config) { c.RTSP.RTPMTU = 10 }},
+		{"negative interleaved flush size", func(c *Config) { c.RTSP.InterleavedFlushSize = -1 }},
+		{"negative interleaved flush interval", func(c *Config) { c.RTSP.InterleavedFlushIntervalMs = -1 }},
+		{"sdp address not an IP", func(c *Config) { c.RTSP.SDPAddress = "media.example.com" }},
+		{"zero max interleaved frame size", func(c *Config) { c.RTSP.MaxInterleavedFrameSize = 0 }},
+		{"max interleaved frame size over 16 bits", func(c *Config) { c.RTSP.MaxInterleavedFrameSize = 65536 }},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }},
+		{"unknown access log format", func(c *Config) { c.Logging.AccessLog.Format = "xml" }},
+		{"negative gop cache size", func(c *Config) { c.Stream.GopCacheSize = -1 }},
+		{"negative resume token ttl", func(c *Config) { c.Stream.ResumeTokenTTL = -1 }},
+		{"negative max publish bitrate", func(c *Config) { c.Stream.MaxPublishBitrateKbps = -1 }},
+		{"empty metadata filter key", func(c *Config) { c.Stream.MetadataFilter.Deny = []string{"encoder", ""} }},
+		{"negative max players", func(c *Config) { c.Stream.MaxPlayersPerStream = -1 }},
+		{"negative max streams", func(c *Config) { c.Stream.MaxStreams = -1 }},
+		{"empty record path", func(c *Config) { c.Stream.RecordPath = "" }},
+		{"record path template without stream", func(c *Config) { c.Stream.RecordPathTemplate = "{app}/{yyyy}.flv" }},
+		{"record path template escaping record path", func(c *Config) { c.Stream.RecordPathTemplate = "../{stream}.flv" }},
+		{"unknown app record path placeholder", func(c *Config) {
+			c.Stream.AppRecordPathTemplates = map[string]string{"live": "{app}/{stream}-{minute}.flv"}
+		}},
+		{"negative reconnect grace", func(c *Config) { c.Stream.PublisherReconnectGrace = -1 }},
+		{"negative latency budget", func(c *Config) { c.Stream.LatencyBudgetMs = -1 }},
+		{"negative initial burst pacing", func(c *Config) { c.Stream.InitialBurstPacingMs = -1 }},
+		{"negative max player dropped frames", func(c *Config) { c.Stream.MaxPlayerDroppedFrames = -1 }},
+		{"negative idle cache compress after", func(c *Config) { c.Stream.IdleCacheCompressAfter = -1 }},
+		{"negative idle cache min bytes", func(c *Config) { c.Stream.IdleCacheMinBytes = -1 }},
+		{"negative inactive stream sweep", func(c *Config) { c.Stream.InactiveStreamSweep = -1 }},
+		{"negative presence ttl", func(c *Config) { c.Stream.PresenceTTL = -1 }},
+		{"negative av sync tolerance", func(c *Config) { c.Stream.AVSyncToleranceMs = -1 }},
+		{"unknown cache eviction", func(c *Config) { c.Stream.CacheEviction = "bytes" }},
+		{"unknown offline play policy", func(c *Config) { c.Stream.OfflinePlay = "reject" }},
+		{"unknown stream name policy", func(c *Config) { c.Stream.StreamNamePolicy = "latin1" }},
+		{"unknown unsupported codec policy", func(c *Config) { c.Stream.UnsupportedCodec = "transcode" }},
+		{"negative cache duration", func(c *Config) { c.Stream.CacheDurationMs = -1 }},
+		{"negative warm-up buffer", func(c *Config) { c.Stream.WarmUpBufferMs = -1 }},
+		{"invalid access entry", func(c *Config) { c.Access.Publish.Allow = []string{"10.0.0.0/40"} }},
+		{"feed port collides with rtmp port", func(c *Config) { c.Feed.Enabled = true; c.Feed.Port = 1935 }},
+		{"feed path without leading slash", func(c *Config) { c.Feed.Enabled = true; c.Feed.Path = "events" }},
+		{"negative tcp keep-alive", func(c *Config) { c.TCP.KeepAlive = -1 }},
+		{"negative tcp read buffer", func(c *Config) { c.TCP.ReadBuffer = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := GetConfigWithDefaults()
+			tt.modify(config)
+			if err := config.validate(); err == nil {
+				t.Errorf("expected validation error")
+			}
+		})
+	}
+}
+
+func TestPrivilegedPorts(t *testing.T) {
+	config := GetConfigWithDefaults() // RTMP 1935, RTSP 554
+
+	ports := config.privilegedPorts()
+	if len(ports) != 1 || ports[0] != 554 {
+		t.Fatalf("expected [554], got %v", ports)
+	}
+
+	config.RTSP.Port = 8554
+	if ports := config.privilegedPorts(); len(ports) != 0 {
+		t.Fatalf("expected no privileged ports, got %v", ports)
+	}
+}
+
+func TestTraceLogLevel(t *testing.T) {
+	config := GetConfigWithDefaults()
+	config.Logging.Level = "trace"
+
+	if err := config.validate(); err != nil {
+		t.Fatalf("expected trace log level to be valid, got: %v", err)
+	}
+	if level := config.GetSlogLevel(); level != rtmp.LevelTrace || level >= slog.LevelDebug {
+		t.Fatalf("expected trace level below debug, got %v", level)
+	}
+}
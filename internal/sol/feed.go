@@ -0,0 +1,60 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sol/pkg/feed"
+	"time"
+)
+
+// 피드 서버 종료 시 진행 중인 요청을 기다리는 최대 시간
+const feedShutdownTimeout = 5 * time.Second
+
+// startFeed는 상태 알림 피드 HTTP 서버를 시작 (포트 바인딩 실패는 바로 반환, 호출자가 피드를 끄고 계속 실행)
+func (s *Server) startFeed() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Feed.Port))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(s.config.Feed.Path, s.feed)
+	s.feedServer = &http.Server{Handler: mux}
+
+	go func() {
+		if err := s.feedServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Feed server error", "err", err)
+		}
+	}()
+	return nil
+}
+
+// stopFeed는 구독 중인 클라이언트를 끊고 피드 HTTP 서버를 종료
+func (s *Server) stopFeed() {
+	if s.feed == nil {
+		return
+	}
+	s.feed.Close()
+
+	if s.feedServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), feedShutdownTimeout)
+	defer cancel()
+	if err := s.feedServer.Shutdown(ctx); err != nil {
+		slog.Error("Error stopping feed server", "err", err)
+	}
+	slog.Info("Feed server stopped")
+}
+
+// publishFeed는 스트림 상태 알림을 피드 구독자에게 전달
+func (s *Server) publishFeed(eventType, streamName string, players int) {
+	if s.feed == nil {
+		return
+	}
+	s.feed.Publish(feed.Event{Type: eventType, Data: feed.StreamStatus{Stream: streamName, Players: players}})
+}
@@ -0,0 +1,57 @@
+package sol
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"sol/pkg/deadletter"
+	"sol/pkg/feed"
+	"sol/pkg/rtmp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamOnlineReachesFeedSubscriber(t *testing.T) {
+	s := &Server{
+		feed:        feed.NewHub(0),
+		deadLetters: deadletter.New("sol", false),
+	}
+	server := httptest.NewServer(s.feed)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	// 구독 등록 확인
+	deadline := time.Now().Add(2 * time.Second)
+	for s.feed.ClientCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected feed subscriber to be registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// RTMP 서버가 발행 시작 시 보내는 알림
+	s.channelHandler(rtmp.StreamOnline{StreamName: "live/test"})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	if lines[0] != "event: stream_online" || lines[1] != `data: {"stream":"live/test","players":0}` {
+		t.Fatalf("expected stream_online event, got %q", lines)
+	}
+	if s.deadLetters.Count() != 0 {
+		t.Fatalf("expected status event to be handled, got %d dead letters", s.deadLetters.Count())
+	}
+}
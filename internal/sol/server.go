@@ -3,23 +3,37 @@ package sol
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"sol/pkg/deadletter"
+	"sol/pkg/feed"
 	"sol/pkg/rtmp"
 	"sol/pkg/rtsp"
+	"sync"
 	"syscall"
 	"time"
 )
 
 type Server struct {
-	ticker  *time.Ticker
-	rtmp    *rtmp.Server
-	rtsp    *rtsp.Server
-	channel chan interface{}
-	ctx     context.Context    // 루트 컨텍스트
-	cancel  context.CancelFunc // 컨텍스트 취소 함수
-	config  *Config            // 설정
+	ticker   *time.Ticker
+	rtmp     *rtmp.Server
+	rtsp     *rtsp.Server
+	channel  chan interface{}
+	ctx      context.Context    // 루트 컨텍스트
+	cancel   context.CancelFunc // 컨텍스트 취소 함수
+	stopOnce sync.Once          // 종료를 여러 번 요청해도 한 번만 수행
+	config   *Config            // 설정
+
+	accessLog io.Closer // 접근 로그 파일 (stdout이거나 비활성화면 nil)
+
+	deadLetters *deadletter.Recorder // 처리되지 않은 이벤트 기록
+
+	// 스트림 상태 알림 피드 (비활성화면 nil)
+	feed       *feed.Hub
+	feedServer *http.Server
 }
 
 func NewServer() *Server {
@@ -34,51 +48,171 @@ func NewServer() *Server {
 	// 설정을 기반으로 로거 초기화
 	InitLogger(config)
 
+	// 접근 제어 정책 (RTMP/RTSP 공통)
+	access := config.GetAccessPolicy()
+
+	// RTMP 접근 로그
+	accessLogger, accessLog, err := NewAccessLogger(config)
+	if err != nil {
+		slog.Error("Failed to create access logger", "err", err)
+		os.Exit(1)
+	}
+
+	// 레거시 Flash 소켓 정책 응답
+	crossDomainPolicy, err := LoadCrossDomainPolicy(config)
+	if err != nil {
+		slog.Error("Failed to load flash policy", "err", err)
+		os.Exit(1)
+	}
+
 	// 취소 가능한 컨텍스트 생성
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// sol 이벤트 채널 (피드가 켜져 있으면 RTMP 스트림 상태 알림을 받음)
+	channel := make(chan interface{}, 10)
+	var statusEvents chan<- interface{}
+	var statusFeed *feed.Hub
+	if config.Feed.Enabled {
+		statusEvents = channel
+		statusFeed = feed.NewHub(feed.DefaultClientBufferSize)
+	}
+
 	sol := &Server{
-		channel: make(chan interface{}, 10),
-		rtmp:    rtmp.NewServer(config.RTMP.Port, rtmp.StreamConfig{
-			GopCacheSize:        config.Stream.GopCacheSize,
-			MaxPlayersPerStream: config.Stream.MaxPlayersPerStream,
-		}),
-		rtsp:    rtsp.NewServer(rtsp.RTSPConfig{
-			Port:    config.RTSP.Port,
-			Timeout: config.RTSP.Timeout,
+		channel: channel,
+		rtmp: rtmp.NewServer(config.RTMP.Port, rtmp.StreamConfig{
+			GopCacheSize:            config.Stream.GopCacheSize,
+			MaxPlayersPerStream:     config.Stream.MaxPlayersPerStream,
+			MaxStreams:              config.Stream.MaxStreams,
+			RecordPath:              config.Stream.RecordPath,
+			RecordPathTemplate:      config.Stream.RecordPathTemplate,
+			AppRecordPathTemplates:  config.Stream.AppRecordPathTemplates,
+			PublisherReconnectGrace: time.Duration(config.Stream.PublisherReconnectGrace) * time.Second,
+			MaxMessageSize:          uint32(config.RTMP.MaxMessageSize),
+			MinChunkSize:            uint32(config.RTMP.MinChunkSize),
+			ValidateMessageLength:   config.RTMP.ValidateMessageLength,
+			EventChannelSize:        config.RTMP.EventChannelSize,
+			SessionChannelSize:      config.RTMP.SessionChannelSize,
+			ReadBufferSize:          config.RTMP.ReadBufferSize,
+			CrossDomainPolicy:       crossDomainPolicy,
+			EventTiming:             config.RTMP.EventTiming,
+			FCPublishStyle:          rtmp.FCPublishStyle(config.RTMP.FCPublishStyle),
+			UnknownCommandPolicy:    rtmp.UnknownCommandPolicy(config.RTMP.UnknownCommand),
+			MaxCommandRate:          config.RTMP.MaxCommandRate,
+			VirtualHosts:            config.RTMP.VirtualHosts,
+			ByteDumpDir:             config.RTMP.ByteDumpDir,
+			ByteDumpLimit:           config.RTMP.ByteDumpLimit,
+			AckWindowSize:           uint32(config.RTMP.AckWindowSize),
+			AckStallFactor:          config.RTMP.AckStallFactor,
+			LatencyBudget:           time.Duration(config.Stream.LatencyBudgetMs) * time.Millisecond,
+			InitialBurstPacing:      time.Duration(config.Stream.InitialBurstPacingMs) * time.Millisecond,
+			MaxPlayerDroppedFrames:  uint64(config.Stream.MaxPlayerDroppedFrames),
+			IdleCacheCompressAfter:  time.Duration(config.Stream.IdleCacheCompressAfter) * time.Second,
+			IdleCacheMinBytes:       config.Stream.IdleCacheMinBytes,
+			StreamSweepInterval:     time.Duration(config.Stream.InactiveStreamSweep) * time.Second,
+			StreamPresenceTTL:       time.Duration(config.Stream.PresenceTTL) * time.Second,
+			WarmUpBuffer:            time.Duration(config.Stream.WarmUpBufferMs) * time.Millisecond,
+			WaitForPlayable:         config.Stream.WaitForPlayable,
+			LogUnhandledEvents:      config.Logging.UnhandledEvents,
+			AccessLogger:            accessLogger,
+			StatusEvents:            statusEvents,
+			CacheEviction:           rtmp.CacheEvictionPolicy(config.Stream.CacheEviction),
+			CacheDuration:           time.Duration(config.Stream.CacheDurationMs) * time.Millisecond,
+			ResumablePlay:           config.Stream.ResumablePlay,
+			ResumeTokenTTL:          time.Duration(config.Stream.ResumeTokenTTL) * time.Second,
+			MaxPublishBitrate:       int64(config.Stream.MaxPublishBitrateKbps) * 1000,
+			AVSyncCorrection:        config.Stream.AVSyncCorrection,
+			AVSyncTolerance:         time.Duration(config.Stream.AVSyncToleranceMs) * time.Millisecond,
+			OfflinePlay:             rtmp.OfflinePlayPolicy(config.Stream.OfflinePlay),
+			StreamNamePolicy:        rtmp.StreamNamePolicy(config.Stream.StreamNamePolicy),
+			UnsupportedCodecPolicy:  rtmp.UnsupportedCodecPolicy(config.Stream.UnsupportedCodec),
+			TCPOptions:              config.GetTCPOptions(),
+			StampMetadata:           config.Stream.StampMetadata,
+			MetadataFilter: rtmp.MetadataFilter{
+				Allow: config.Stream.MetadataFilter.Allow,
+				Deny:  config.Stream.MetadataFilter.Deny,
+			},
+		}, access),
+		rtsp: rtsp.NewServer(rtsp.RTSPConfig{
+			Port:      config.RTSP.Port,
+			Timeout:   config.RTSP.Timeout,
+			PlayStart: config.GetPlayStartPolicy(),
+			RTPMTU:    config.RTSP.RTPMTU,
+			Access:    access,
+
+			TCPOptions: config.GetTCPOptions(),
+
+			InterleavedFlushSize:     config.RTSP.InterleavedFlushSize,
+			InterleavedFlushInterval: time.Duration(config.RTSP.InterleavedFlushIntervalMs) * time.Millisecond,
+			MaxInterleavedFrameSize:  config.RTSP.MaxInterleavedFrameSize,
+			InterleavedChannels:      config.GetChannelAssignment(),
+
+			LogUnhandledEvents: config.Logging.UnhandledEvents,
+
+			RTX: config.RTSP.RTX,
+			SDP: rtsp.SDPOptions{
+				SessionName: config.RTSP.SDPSessionName,
+				Info:        config.RTSP.SDPSessionInfo,
+				Address:     config.RTSP.SDPAddress,
+			},
 		}),
-		ticker:  time.NewTicker(1000 * time.Second),
-		ctx:     ctx,
-		cancel:  cancel,
-		config:  config,
+		ticker: time.NewTicker(1000 * time.Second),
+		ctx:    ctx,
+		cancel: cancel,
+		config: config,
+
+		accessLog: accessLog,
+
+		deadLetters: deadletter.New("sol", config.Logging.UnhandledEvents),
+
+		feed: statusFeed,
 	}
 	return sol
 }
 
 func (s *Server) Start() {
+	if err := s.startServers(); err != nil {
+		os.Exit(1)
+	}
+
+	// 이벤트 루프 시작
+	go s.eventLoop()
+
+	// 시그널 처리 시작
+	s.waitForShutdown()
+}
+
+// startServers는 미디어 서버와 부가 HTTP 서버를 시작 (미디어 서버 시작 실패만 반환)
+func (s *Server) startServers() error {
 	slog.Info("Servers starting...")
-	
+
 	// RTMP 서버 시작
 	if err := s.rtmp.Start(); err != nil {
 		slog.Error("Failed to start RTMP server", "err", err)
-		os.Exit(1)
+		return err
 	}
-	
+
 	slog.Info("RTMP Server started", "port", s.config.RTMP.Port)
-	
+
 	// RTSP 서버 시작
 	if err := s.rtsp.Start(); err != nil {
 		slog.Error("Failed to start RTSP server", "err", err)
-		os.Exit(1)
+		return err
 	}
-	
+
 	slog.Info("RTSP Server started", "port", s.config.RTSP.Port)
-	
-	// 이벤트 루프 시작
-	go s.eventLoop()
-	
-	// 시그널 처리 시작
-	s.waitForShutdown()
+
+	// 상태 알림 피드 시작 (활성화된 경우에만)
+	// 피드는 부가 기능이므로 포트 바인딩에 실패해도 미디어 서버는 피드 없이 계속 실행
+	if s.feed != nil {
+		if err := s.startFeed(); err != nil {
+			slog.Error("Failed to start feed server, continuing without feed", "port", s.config.Feed.Port, "err", err)
+			s.feed.Close()
+			s.feed = nil
+		} else {
+			slog.Info("Feed server started", "port", s.config.Feed.Port, "path", s.config.Feed.Path)
+		}
+	}
+	return nil
 }
 
 // waitForShutdown은 시그널을 대기하고 우아한 종료를 수행합니다
@@ -86,7 +220,7 @@ func (s *Server) waitForShutdown() {
 	// 시그널 채널 생성
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
+
 	// 시그널 대기
 	select {
 	case sig := <-sigChan:
@@ -94,31 +228,45 @@ func (s *Server) waitForShutdown() {
 	case <-s.ctx.Done():
 		slog.Info("Context cancelled, shutting down server")
 	}
-	
+
 	// 우아한 종료 수행
 	s.shutdown()
 }
 
-// shutdown은 실제 종료 로직을 수행합니다
+// shutdown은 실제 종료 로직을 수행합니다 (여러 번 호출해도 한 번만 수행)
 func (s *Server) shutdown() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
 	slog.Info("Stopping Sol Server...")
-	
+
 	// 1. 컨텍스트 취소 (모든 고루틴에 종료 신호)
 	s.cancel()
-	
+
 	// 2. RTMP 서버 종료
 	s.rtmp.Stop()
-	
+
 	// 3. RTSP 서버 종료
 	s.rtsp.Stop()
-	
-	// 4. 티커 종료
+
+	// 4. 상태 알림 피드 종료 (구독 중인 클라이언트 연결 해제)
+	s.stopFeed()
+
+	// 5. 티커 종료
 	if s.ticker != nil {
 		s.ticker.Stop()
 		slog.Info("Ticker stopped")
 	}
-	
-	// 5. 채널 청소
+
+	// 6. 접근 로그 파일 닫기
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			slog.Error("Error closing access log", "err", err)
+		}
+	}
+
+	// 7. 채널 청소
 	for {
 		select {
 		case <-s.channel:
@@ -128,7 +276,7 @@ func (s *Server) shutdown() {
 			goto cleanup_done
 		}
 	}
-	
+
 cleanup_done:
 	close(s.channel)
 	slog.Info("Sol Server stopped successfully")
@@ -149,5 +297,23 @@ func (s *Server) eventLoop() {
 }
 
 func (s *Server) channelHandler(data interface{}) {
-
+	switch v := data.(type) {
+	case rtmp.StreamOnline:
+		s.publishFeed(feed.EventStreamOnline, v.StreamName, v.Players)
+	case rtmp.StreamOffline:
+		s.publishFeed(feed.EventStreamOffline, v.StreamName, v.Players)
+	case rtmp.PlayerCountChanged:
+		s.publishFeed(feed.EventPlayerCountChanged, v.StreamName, v.Players)
+	case rtmp.CodecMismatch:
+		if s.feed != nil {
+			s.feed.Publish(feed.Event{Type: feed.EventCodecMismatch, Data: feed.CodecMismatch{
+				Stream:   v.StreamName,
+				Media:    v.Media,
+				Declared: string(v.Declared),
+				Detected: string(v.Detected),
+			}})
+		}
+	default:
+		s.deadLetters.Record(data)
+	}
 }
@@ -0,0 +1,88 @@
+package sol
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sol/pkg/deadletter"
+	"sol/pkg/feed"
+	"sol/pkg/rtmp"
+	"sol/pkg/rtsp"
+	"testing"
+)
+
+func TestShutdownTwiceIsSafe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		rtmp:        rtmp.NewServer(0, rtmp.StreamConfig{}, nil),
+		rtsp:        rtsp.NewServer(rtsp.RTSPConfig{}),
+		channel:     make(chan interface{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		deadLetters: deadletter.New("sol", false),
+	}
+
+	// 시그널 처리와 컨텍스트 취소가 모두 종료를 요청하는 경우
+	s.shutdown()
+	s.shutdown()
+
+	if ctx.Err() == nil {
+		t.Fatal("expected root context to be cancelled")
+	}
+}
+
+// freePort는 테스트 서버가 사용할 빈 TCP 포트를 찾음
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestMediaServersStartWhenFeedPortInUse(t *testing.T) {
+	// 피드 포트를 다른 프로세스가 이미 사용 중인 경우
+	occupied, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to occupy feed port: %v", err)
+	}
+	t.Cleanup(func() { occupied.Close() })
+
+	config := GetConfigWithDefaults()
+	config.RTMP.Port = freePort(t)
+	config.RTSP.Port = freePort(t)
+	config.Feed.Enabled = true
+	config.Feed.Port = occupied.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		rtmp:        rtmp.NewServer(config.RTMP.Port, rtmp.StreamConfig{}, nil),
+		rtsp:        rtsp.NewServer(rtsp.RTSPConfig{Port: config.RTSP.Port}),
+		channel:     make(chan interface{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		config:      config,
+		deadLetters: deadletter.New("sol", false),
+		feed:        feed.NewHub(0),
+	}
+	t.Cleanup(s.shutdown)
+
+	if err := s.startServers(); err != nil {
+		t.Fatalf("expected media servers to start without the feed, got %v", err)
+	}
+	if s.feed != nil || s.feedServer != nil {
+		t.Fatal("expected feed to be disabled after bind failure")
+	}
+	for _, port := range []int{config.RTMP.Port, config.RTSP.Port} {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("expected media server on port %d to accept connections: %v", port, err)
+		}
+		conn.Close()
+	}
+
+	// 피드가 꺼진 상태에서도 스트림 상태 알림 처리는 안전
+	s.channelHandler(rtmp.StreamOnline{StreamName: "live/test"})
+}
@@ -1,9 +1,12 @@
 package sol
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"runtime"
+	"sol/pkg/rtmp"
 	"strings"
 	"time"
 
@@ -21,6 +24,13 @@ func InitLogger(config *Config) {
 	// ReplaceAttr 함수를 정의합니다.
 	// 이 함수는 각 로그 속성을 처리할 때 호출됩니다.
 	replaceAttr := func(groups []string, a slog.Attr) slog.Attr {
+		// Debug보다 낮은 trace 레벨(청크/메시지 단위 로그)은 DBG-4 대신 TRC로 표시합니다.
+		if a.Key == slog.LevelKey && len(groups) == 0 {
+			if level, ok := a.Value.Any().(slog.Level); ok && level <= rtmp.LevelTrace {
+				return tint.Attr(13, slog.String(a.Key, "TRC"))
+			}
+		}
+
 		// slog.SourceKey ("source") 속성을 찾습니다.
 		if a.Key == slog.SourceKey {
 			source, ok := a.Value.Any().(*slog.Source)
@@ -58,6 +68,51 @@ func InitLogger(config *Config) {
 	slog.SetDefault(logger)
 }
 
+// NewAccessLogger는 설정에 따라 RTMP 접근 로거를 생성합니다.
+// 비활성화되어 있으면 nil을 반환하고, 파일에 기록하는 경우 종료 시 닫을 io.Closer를 함께 반환합니다.
+func NewAccessLogger(config *Config) (rtmp.AccessLogger, io.Closer, error) {
+	accessLog := config.Logging.AccessLog
+	if !accessLog.Enabled {
+		return nil, nil, nil
+	}
+
+	var w io.Writer = os.Stdout
+	var closer io.Closer
+	if accessLog.Path != "" {
+		file, err := os.OpenFile(accessLog.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		w, closer = file, file
+	}
+
+	logger, err := rtmp.NewAccessLogger(w, rtmp.AccessLogFormat(accessLog.Format))
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, err
+	}
+	return logger, closer, nil
+}
+
+// LoadCrossDomainPolicy는 설정에 따라 Flash 소켓 정책 요청에 응답할 정책 XML을 반환합니다.
+// 비활성화되어 있으면 빈 문자열을, 파일을 지정하지 않았으면 모든 도메인을 허용하는 기본 정책을 반환합니다.
+func LoadCrossDomainPolicy(config *Config) (string, error) {
+	if !config.RTMP.FlashPolicy {
+		return "", nil
+	}
+	if config.RTMP.FlashPolicyFile == "" {
+		return rtmp.DefaultCrossDomainPolicy, nil
+	}
+
+	policy, err := os.ReadFile(config.RTMP.FlashPolicyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flash policy file: %w", err)
+	}
+	return string(policy), nil
+}
+
 // getProjectRoot는 주어진 파일 경로에서 프로젝트 루트 경로를 추론하는 헬퍼 함수입니다.
 // 실제 프로젝트에서는 go.mod 파일을 찾거나, 고정된 경로를 사용하기도 합니다.
 // 여기서는 간략화를 위해 main.go 파일이 있는 디렉토리를 루트로 가정합니다.
@@ -0,0 +1,149 @@
+package acl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// List is a CIDR-based allow/deny list for client source addresses.
+// Deny entries take precedence. An empty allow list permits every address
+// that is not denied. A nil *List permits everything.
+type List struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewList parses allow and deny entries into a List.
+// Entries are CIDRs ("10.0.0.0/8") or single addresses ("192.168.1.10").
+// It returns nil when both lists are empty.
+func NewList(allow, deny []string) (*List, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	allowNets, err := parseNetworks(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow entry: %w", err)
+	}
+	denyNets, err := parseNetworks(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny entry: %w", err)
+	}
+
+	return &List{
+		allow: allowNets,
+		deny:  denyNets,
+	}, nil
+}
+
+// Allowed reports whether ip passes the list
+func (l *List) Allowed(ip net.IP) bool {
+	if l == nil {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range l.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(l.allow) == 0 {
+		return true
+	}
+	for _, n := range l.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedAddr reports whether the IP of a network address passes the list
+func (l *List) AllowedAddr(addr net.Addr) bool {
+	if l == nil {
+		return true
+	}
+	return l.Allowed(AddrIP(addr))
+}
+
+// Policy groups the lists checked at each stage of a client connection
+type Policy struct {
+	Connect *List // checked when the connection is accepted
+	Publish *List // checked on publish/ANNOUNCE/RECORD
+	Play    *List // checked on play/DESCRIBE/PLAY
+}
+
+// AllowConnect reports whether addr may connect
+func (p *Policy) AllowConnect(addr net.Addr) bool {
+	if p == nil {
+		return true
+	}
+	return p.Connect.AllowedAddr(addr)
+}
+
+// AllowPublish reports whether addr may publish
+func (p *Policy) AllowPublish(addr net.Addr) bool {
+	if p == nil {
+		return true
+	}
+	return p.Publish.AllowedAddr(addr)
+}
+
+// AllowPlay reports whether addr may play
+func (p *Policy) AllowPlay(addr net.Addr) bool {
+	if p == nil {
+		return true
+	}
+	return p.Play.AllowedAddr(addr)
+}
+
+// AddrIP extracts the IP from a network address, or nil if it has none
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case nil:
+		return nil
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// parseNetworks parses CIDR or single-address entries
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,90 @@
+package acl
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListAllowDeny(t *testing.T) {
+	list, err := NewList([]string{"10.0.0.0/8", "192.168.1.10"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("Failed to create list: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.2.3.4", true},
+		{"192.168.1.10", true},
+		{"10.1.2.3", false},     // denied range inside allowed range
+		{"192.168.1.11", false}, // not in allow list
+		{"203.0.113.5", false},
+	}
+
+	for _, tt := range tests {
+		if got := list.Allowed(net.ParseIP(tt.ip)); got != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.ip, tt.allowed, got)
+		}
+	}
+}
+
+func TestListDenyOnly(t *testing.T) {
+	list, err := NewList(nil, []string{"203.0.113.0/24", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("Failed to create list: %v", err)
+	}
+
+	if !list.Allowed(net.ParseIP("198.51.100.1")) {
+		t.Error("Expected address outside deny list to be allowed")
+	}
+	if list.Allowed(net.ParseIP("203.0.113.7")) {
+		t.Error("Expected IPv4 address in deny list to be rejected")
+	}
+	if list.Allowed(net.ParseIP("2001:db8::1")) {
+		t.Error("Expected IPv6 address in deny list to be rejected")
+	}
+}
+
+func TestNewListEmptyAndInvalid(t *testing.T) {
+	list, err := NewList(nil, nil)
+	if err != nil || list != nil {
+		t.Fatalf("Expected nil list without error, got %v, %v", list, err)
+	}
+	if !list.Allowed(net.ParseIP("203.0.113.5")) {
+		t.Error("Expected nil list to allow everything")
+	}
+
+	if _, err := NewList([]string{"not-an-ip"}, nil); err == nil {
+		t.Error("Expected error for invalid allow entry")
+	}
+	if _, err := NewList(nil, []string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected error for invalid deny entry")
+	}
+}
+
+func TestPolicyPerOperation(t *testing.T) {
+	publish, err := NewList([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create list: %v", err)
+	}
+	policy := &Policy{Publish: publish}
+
+	internal := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 50000}
+	external := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 50000}
+
+	if !policy.AllowConnect(external) || !policy.AllowPlay(external) {
+		t.Error("Expected external address to connect and play")
+	}
+	if policy.AllowPublish(external) {
+		t.Error("Expected external address not to publish")
+	}
+	if !policy.AllowPublish(internal) {
+		t.Error("Expected internal address to publish")
+	}
+
+	var nilPolicy *Policy
+	if !nilPolicy.AllowPublish(external) {
+		t.Error("Expected nil policy to allow everything")
+	}
+}
@@ -1,6 +1,7 @@
 package amf
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,10 +11,16 @@ import (
 )
 
 func DecodeAMF0Sequence(r io.Reader) ([]any, error) {
+	return DecodeAMF0SequenceWithOptions(r, DefaultDecodeOptions())
+}
+
+// DecodeAMF0SequenceWithOptions는 디코딩 옵션을 적용해서 AMF0 값들을 순서대로 디코딩
+func DecodeAMF0SequenceWithOptions(r io.Reader, opts DecodeOptions) ([]any, error) {
 	values := make([]any, 0, 5)
+	r = newPeekReader(r) // 시퀀스는 EOF까지 읽으므로 미리 버퍼링해도 잃는 데이터가 없음
 
 	for {
-		val, err := DecodeAMF0(r)
+		val, err := decodeValue(r, &opts)
 		switch {
 		case err == nil:
 			values = append(values, val)
@@ -25,7 +32,28 @@ func DecodeAMF0Sequence(r io.Reader) ([]any, error) {
 	}
 }
 
+// DecodeAMF0은 r에서 값 하나를 디코딩
+// ECMA 배열 끝 표시를 미리 확인하기 위해 r을 버퍼링하므로 값 뒤의 데이터까지 읽을 수 있다
 func DecodeAMF0(r io.Reader) (any, error) {
+	opts := DefaultDecodeOptions()
+	return decodeValue(newPeekReader(r), &opts)
+}
+
+// peekReader는 다음 바이트를 소비하지 않고 확인할 수 있는 reader (ECMA 배열 끝 표시 확인용)
+type peekReader interface {
+	io.Reader
+	Peek(n int) ([]byte, error)
+	Discard(n int) (int, error)
+}
+
+func newPeekReader(r io.Reader) io.Reader {
+	if _, ok := r.(peekReader); ok {
+		return r
+	}
+	return bufio.NewReader(r)
+}
+
+func decodeValue(r io.Reader, opts *DecodeOptions) (any, error) {
 	marker := make([]byte, 1)
 	if _, err := io.ReadFull(r, marker); err != nil {
 		return nil, err
@@ -39,26 +67,26 @@ func DecodeAMF0(r io.Reader) (any, error) {
 	case stringMarker:
 		return decodeString(r)
 	case objectMarker:
-		return decodeObject(r)
+		return decodeObject(r, opts)
 	case nullMarker, undefinedMarker:
 		return decodeNull(r)
 	case ecmaArrayMarker:
-		return decodeECMAArray(r)
+		return decodeECMAArray(r, opts)
 	case strictArrayMarker:
-		return decodeStrictArray(r)
+		return decodeStrictArray(r, opts)
 	case dateMarker:
 		return decodeDate(r)
 	case longStringMarker:
 		return decodeLongString(r)
+	case avmplusObjectMarker:
+		return decodeAMF3(r, opts)
 	default:
 		return nil, fmt.Errorf("unsupported AMF0 marker: 0x%x", marker[0])
 	}
 }
 
 func decodeNumber(r io.Reader) (float64, error) {
-	var num float64
-	err := binary.Read(r, binary.BigEndian, &num)
-	return num, err
+	return readFloat64(r)
 }
 
 func decodeBoolean(r io.Reader) (bool, error) {
@@ -70,8 +98,8 @@ func decodeBoolean(r io.Reader) (bool, error) {
 }
 
 func decodeString(r io.Reader) (string, error) {
-	var length uint16
-	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+	length, err := readUint16(r)
+	if err != nil {
 		return "", err
 	}
 	buf := make([]byte, length)
@@ -82,8 +110,8 @@ func decodeString(r io.Reader) (string, error) {
 }
 
 func decodeLongString(r io.Reader) (string, error) {
-	var length uint32
-	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+	length, err := readUint32(r)
+	if err != nil {
 		return "", err
 	}
 	buf := make([]byte, length)
@@ -97,17 +125,79 @@ func decodeNull(_ io.Reader) (any, error) {
 	return nil, nil
 }
 
-func decodeECMAArray(r io.Reader) (map[string]any, error) {
-	var length uint32
-	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+// decodeECMAArray는 associative-count만큼 항목을 읽는다
+// count가 0이면 많은 인코더가 개수를 채우지 않은 것이므로 객체처럼 끝 표시까지 읽고,
+// count보다 먼저 끝 표시가 나오면 거기서 끝낸다. count만큼 읽은 뒤의 끝 표시는 있으면 소비
+// (끝 표시를 생략하는 인코더가 있어 뒤따르는 값을 항목으로 잘못 읽지 않도록)
+func decodeECMAArray(r io.Reader, opts *DecodeOptions) (map[string]any, error) {
+	count, err := readUint32(r)
+	if err != nil {
 		return nil, err
 	}
-	return decodeObject(r)
-}
+	if count == 0 {
+		return decodeObject(r, opts)
+	}
+	r = limitObjectSize(r, opts)
 
-func decodeObject(r io.Reader) (map[string]any, error) {
 	obj := make(map[string]any)
+	for i := uint32(0); i < count; i++ {
+		key, err := decodeString(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			// count보다 항목이 적은 경우 끝 표시가 먼저 나옴
+			if err := readObjectEndMarker(r); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		}
+		val, err := decodeValue(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := setObjectValue(obj, key, val, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := skipObjectEnd(r); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// skipObjectEnd는 다음 3바이트가 객체 끝 표시(0x00 0x00 0x09)이면 소비하고, 아니면 그대로 둔다
+func skipObjectEnd(r io.Reader) error {
+	p, ok := r.(peekReader)
+	if !ok {
+		return nil
+	}
+	next, err := p.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if len(next) == 3 && next[0] == 0 && next[1] == 0 && next[2] == objectEndMarker {
+		_, err = p.Discard(3)
+		return err
+	}
+	return nil
+}
+
+func readObjectEndMarker(r io.Reader) error {
 	end := make([]byte, 1)
+	if _, err := io.ReadFull(r, end); err != nil {
+		return err
+	}
+	if end[0] != objectEndMarker {
+		return errors.New("expected object end marker")
+	}
+	return nil
+}
+
+func decodeObject(r io.Reader, opts *DecodeOptions) (map[string]any, error) {
+	r = limitObjectSize(r, opts)
+	obj := make(map[string]any)
 
 	for {
 		key, err := decodeString(r)
@@ -115,31 +205,107 @@ func decodeObject(r io.Reader) (map[string]any, error) {
 			return nil, err
 		}
 		if len(key) == 0 {
-			if _, err := io.ReadFull(r, end); err != nil {
+			if err := readObjectEndMarker(r); err != nil {
 				return nil, err
 			}
-			if end[0] == objectEndMarker {
-				break
-			}
-			return nil, errors.New("expected object end marker")
+			break
 		}
-		val, err := DecodeAMF0(r)
+		val, err := decodeValue(r, opts)
 		if err != nil {
 			return nil, err
 		}
-		obj[key] = val
+		if err := setObjectValue(obj, key, val, opts); err != nil {
+			return nil, err
+		}
 	}
 	return obj, nil
 }
 
-func decodeStrictArray(r io.Reader) ([]any, error) {
-	var count uint32
-	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+// 중복 키 처리 (정책에 따라 처음/마지막 값 유지 또는 에러)
+// 새 키가 MaxObjectProperties를 넘기면 에러
+func setObjectValue(obj map[string]any, key string, val any, opts *DecodeOptions) error {
+	_, exists := obj[key]
+	if !exists && opts.MaxObjectProperties > 0 && len(obj) >= opts.MaxObjectProperties {
+		return fmt.Errorf("%w: more than %d", ErrTooManyProperties, opts.MaxObjectProperties)
+	}
+	if exists {
+		if opts.OnDuplicateKey != nil {
+			opts.OnDuplicateKey(key)
+		}
+		switch opts.DuplicateKeys {
+		case DuplicateKeyError:
+			return fmt.Errorf("%w: %s", ErrDuplicateKey, key)
+		case DuplicateKeyKeepFirst:
+			return nil
+		}
+	}
+	obj[key] = val
+	return nil
+}
+
+// objectSizeReader는 최상위 객체가 읽는 바이트를 세어 MaxObjectBytes를 넘으면 ErrObjectTooLarge를 반환
+// 중첩된 객체는 바깥 객체의 reader를 그대로 쓰므로 전체 크기가 한 번에 제한된다
+type objectSizeReader struct {
+	r         io.Reader
+	remaining int
+}
+
+// limitObjectSize는 MaxObjectBytes가 설정되어 있고 아직 제한 중이 아니면 r을 objectSizeReader로 감싼다
+func limitObjectSize(r io.Reader, opts *DecodeOptions) io.Reader {
+	if opts.MaxObjectBytes <= 0 {
+		return r
+	}
+	if _, ok := r.(*objectSizeReader); ok {
+		return r
+	}
+	return &objectSizeReader{r: r, remaining: opts.MaxObjectBytes}
+}
+
+func (o *objectSizeReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if o.remaining <= 0 {
+		return 0, ErrObjectTooLarge
+	}
+	if len(p) > o.remaining {
+		p = p[:o.remaining]
+	}
+	n, err := o.r.Read(p)
+	o.remaining -= n
+	return n, err
+}
+
+// Peek은 바이트를 소비하지 않으므로 제한 없이 아래 reader에 넘긴다
+func (o *objectSizeReader) Peek(n int) ([]byte, error) {
+	p, ok := o.r.(peekReader)
+	if !ok {
+		return nil, nil
+	}
+	return p.Peek(n)
+}
+
+func (o *objectSizeReader) Discard(n int) (int, error) {
+	if n > o.remaining {
+		return 0, ErrObjectTooLarge
+	}
+	p, ok := o.r.(peekReader)
+	if !ok {
+		return 0, nil
+	}
+	discarded, err := p.Discard(n)
+	o.remaining -= discarded
+	return discarded, err
+}
+
+func decodeStrictArray(r io.Reader, opts *DecodeOptions) ([]any, error) {
+	count, err := readUint32(r)
+	if err != nil {
 		return nil, err
 	}
 	arr := make([]any, count)
 	for i := uint32(0); i < count; i++ {
-		v, err := DecodeAMF0(r)
+		v, err := decodeValue(r, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -149,8 +315,8 @@ func decodeStrictArray(r io.Reader) ([]any, error) {
 }
 
 func decodeDate(r io.Reader) (time.Time, error) {
-	var millis float64
-	if err := binary.Read(r, binary.BigEndian, &millis); err != nil {
+	millis, err := readFloat64(r)
+	if err != nil {
 		return time.Time{}, err
 	}
 
@@ -165,3 +331,30 @@ func decodeDate(r io.Reader) (time.Time, error) {
 
 	return time.Unix(sec, nanoSec).UTC(), nil
 }
+
+// 고정 크기 읽기는 모두 io.ReadFull을 사용해서
+// io.MultiReader처럼 슬라이스 경계에서 짧게 읽히는 reader에서도 값이 잘리지 않도록 한다
+
+func readUint16(r io.Reader) (uint16, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(buf[:]), nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+func readFloat64(r io.Reader) (float64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(buf[:])), nil
+}
@@ -2,7 +2,11 @@ package amf
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -230,6 +234,51 @@ func TestDecodeAMF0_ECMAArray_MalformedLength(t *testing.T) {
 	}
 }
 
+func TestDecodeAMF0Sequence_ECMAArrayCount(t *testing.T) {
+	entries := []byte{
+		0x00, 0x05, 'w', 'i', 'd', 't', 'h',
+		0x00, 0x40, 0x94, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 1280
+		0x00, 0x07, 'e', 'n', 'c', 'o', 'd', 'e', 'r',
+		0x02, 0x00, 0x03, 'o', 'b', 's',
+	}
+	trailing := []byte{0x02, 0x00, 0x04, 'n', 'e', 'x', 't'} // 배열 뒤에 이어지는 값
+
+	tests := []struct {
+		name  string
+		count byte
+		end   []byte
+	}{
+		{"count with end marker", 2, []byte{0x00, 0x00, 0x09}},
+		{"count without end marker", 2, nil},
+		{"count larger than entries", 5, []byte{0x00, 0x00, 0x09}},
+		{"zero count with end marker", 0, []byte{0x00, 0x00, 0x09}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte{0x08, 0x00, 0x00, 0x00, tt.count}
+			data = append(data, entries...)
+			data = append(data, tt.end...)
+			data = append(data, trailing...)
+
+			values, err := DecodeAMF0Sequence(iotest.OneByteReader(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(values) != 2 {
+				t.Fatalf("expected 2 values, got %d: %v", len(values), values)
+			}
+			m, ok := values[0].(map[string]any)
+			if !ok || len(m) != 2 || m["width"] != 1280.0 || m["encoder"] != "obs" {
+				t.Errorf("expected width=1280 encoder=obs, got %v", values[0])
+			}
+			if values[1] != "next" {
+				t.Errorf("expected trailing value 'next', got %v", values[1])
+			}
+		})
+	}
+}
+
 func TestDecodeAMF0_StrictArray(t *testing.T) {
 	data := []byte{
 		0x0A,                   // strictArrayMarker
@@ -334,3 +383,177 @@ func TestDecodeAMF0_LongString_MalformedShortData(t *testing.T) {
 		t.Fatal("expected error for incomplete long string data")
 	}
 }
+
+// 값들을 어색한 바이트 경계에서 나눠 MultiReader로 묶고 1바이트씩 읽히도록 한다
+func TestDecodeAMF0Sequence_SplitAcrossSlices(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	encoded, err := EncodeAMF0Sequence("connect", 3.14, true, map[string]any{"app": "live"}, []any{1.0, "a"}, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 숫자/길이 필드 중간에서 잘리도록 다양한 크기로 분할
+	var slices [][]byte
+	sizes := []int{1, 3, 2, 5, 7}
+	for offset, i := 0, 0; offset < len(encoded); i++ {
+		size := sizes[i%len(sizes)]
+		if offset+size > len(encoded) {
+			size = len(encoded) - offset
+		}
+		slices = append(slices, encoded[offset:offset+size])
+		offset += size
+	}
+
+	readers := make([]io.Reader, 0, len(slices))
+	for _, b := range slices {
+		readers = append(readers, bytes.NewReader(b))
+	}
+	r := iotest.OneByteReader(io.MultiReader(readers...))
+
+	values, err := DecodeAMF0Sequence(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 6 {
+		t.Fatalf("expected 6 values, got %d", len(values))
+	}
+	if values[0] != "connect" {
+		t.Errorf("expected 'connect', got %v", values[0])
+	}
+	if values[1] != 3.14 {
+		t.Errorf("expected 3.14, got %v", values[1])
+	}
+	if values[2] != true {
+		t.Errorf("expected true, got %v", values[2])
+	}
+	if obj, ok := values[3].(map[string]any); !ok || obj["app"] != "live" {
+		t.Errorf("expected object with app=live, got %v", values[3])
+	}
+	if arr, ok := values[4].([]any); !ok || len(arr) != 2 || arr[0] != 1.0 || arr[1] != "a" {
+		t.Errorf("expected [1 a], got %v", values[4])
+	}
+	if d, ok := values[5].(time.Time); !ok || !d.Equal(date) {
+		t.Errorf("expected %v, got %v", date, values[5])
+	}
+}
+
+func TestDecodeAMF0Object_DuplicateKeys(t *testing.T) {
+	// { a: "1", a: "2" }
+	data := []byte{0x03,
+		0x00, 0x01, 'a', 0x02, 0x00, 0x01, '1',
+		0x00, 0x01, 'a', 0x02, 0x00, 0x01, '2',
+		0x00, 0x00, 0x09}
+
+	tests := []struct {
+		policy   DuplicateKeyPolicy
+		expected string
+	}{
+		{DuplicateKeyKeepLast, "2"},
+		{DuplicateKeyKeepFirst, "1"},
+	}
+
+	for _, tt := range tests {
+		var duplicates []string
+		opts := DecodeOptions{
+			DuplicateKeys:  tt.policy,
+			OnDuplicateKey: func(key string) { duplicates = append(duplicates, key) },
+		}
+		values, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+		obj, ok := values[0].(map[string]any)
+		if !ok || obj["a"] != tt.expected {
+			t.Errorf("policy %d: expected a=%s, got %v", tt.policy, tt.expected, values[0])
+		}
+		if len(duplicates) != 1 || duplicates[0] != "a" {
+			t.Errorf("policy %d: expected duplicate key 'a' reported once, got %v", tt.policy, duplicates)
+		}
+	}
+
+	// 기본 디코딩은 마지막 값 유지
+	v, err := DecodeAMF0(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj := v.(map[string]any); obj["a"] != "2" {
+		t.Errorf("expected default decode to keep last value, got %v", obj["a"])
+	}
+
+	_, err = DecodeAMF0SequenceWithOptions(bytes.NewReader(data), DecodeOptions{DuplicateKeys: DuplicateKeyError})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+// count개의 서로 다른 문자열 속성을 가진 AMF0 객체
+func encodeTestObject(count int) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(objectMarker)
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("k%d", i)
+		buf.Write([]byte{0x00, byte(len(key))})
+		buf.WriteString(key)
+		buf.Write([]byte{stringMarker, 0x00, 0x01, 'v'})
+	}
+	buf.Write([]byte{0x00, 0x00, objectEndMarker})
+	return buf.Bytes()
+}
+
+func TestDecodeAMF0Object_PropertyLimit(t *testing.T) {
+	opts := DecodeOptions{MaxObjectProperties: 10}
+
+	values, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(encodeTestObject(10)), opts)
+	if err != nil {
+		t.Fatalf("expected object at the limit to decode, got %v", err)
+	}
+	if obj := values[0].(map[string]any); len(obj) != 10 {
+		t.Fatalf("expected 10 properties, got %d", len(obj))
+	}
+
+	_, err = DecodeAMF0SequenceWithOptions(bytes.NewReader(encodeTestObject(11)), opts)
+	if !errors.Is(err, ErrTooManyProperties) {
+		t.Fatalf("expected ErrTooManyProperties, got %v", err)
+	}
+
+	// 같은 제한이 ECMA 배열과 중첩 객체에도 적용됨
+	ecma := append([]byte{ecmaArrayMarker, 0x00, 0x00, 0x00, 0x0B}, encodeTestObject(11)[1:]...)
+	if _, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(ecma), opts); !errors.Is(err, ErrTooManyProperties) {
+		t.Errorf("expected ErrTooManyProperties for ECMA array, got %v", err)
+	}
+	nested := append([]byte{objectMarker, 0x00, 0x01, 'o'}, encodeTestObject(11)...)
+	nested = append(nested, 0x00, 0x00, objectEndMarker)
+	if _, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(nested), opts); !errors.Is(err, ErrTooManyProperties) {
+		t.Errorf("expected ErrTooManyProperties for nested object, got %v", err)
+	}
+
+	// 기본 옵션도 제한이 켜져 있음
+	if _, err := DecodeAMF0(bytes.NewReader(encodeTestObject(DefaultMaxObjectProperties + 1))); !errors.Is(err, ErrTooManyProperties) {
+		t.Errorf("expected default options to limit properties, got %v", err)
+	}
+}
+
+func TestDecodeAMF0Object_SizeLimit(t *testing.T) {
+	data := encodeTestObject(20)
+
+	// 객체 크기(마커 제외)까지는 허용
+	if _, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(data), DecodeOptions{MaxObjectBytes: len(data) - 1}); err != nil {
+		t.Fatalf("expected object at the size limit to decode, got %v", err)
+	}
+	_, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(data), DecodeOptions{MaxObjectBytes: len(data) - 2})
+	if !errors.Is(err, ErrObjectTooLarge) {
+		t.Fatalf("expected ErrObjectTooLarge, got %v", err)
+	}
+
+	// 중첩된 객체는 바깥 객체의 크기에 포함되고, 객체 뒤의 값은 제한과 무관
+	nested := append([]byte{objectMarker, 0x00, 0x01, 'o'}, data...)
+	nested = append(nested, 0x00, 0x00, objectEndMarker)
+	opts := DecodeOptions{MaxObjectBytes: len(data)}
+	if _, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(nested), opts); !errors.Is(err, ErrObjectTooLarge) {
+		t.Errorf("expected nested object to count against the outer limit, got %v", err)
+	}
+	values, err := DecodeAMF0SequenceWithOptions(bytes.NewReader(append(data, data...)), opts)
+	if err != nil || len(values) != 2 {
+		t.Errorf("expected each top-level object to have its own limit, got %d values, %v", len(values), err)
+	}
+}
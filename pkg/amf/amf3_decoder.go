@@ -0,0 +1,364 @@
+package amf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// AMF3 마커
+const (
+	amf3UndefinedMarker    = 0x00
+	amf3NullMarker         = 0x01
+	amf3FalseMarker        = 0x02
+	amf3TrueMarker         = 0x03
+	amf3IntegerMarker      = 0x04
+	amf3DoubleMarker       = 0x05
+	amf3StringMarker       = 0x06
+	amf3XMLDocMarker       = 0x07
+	amf3DateMarker         = 0x08
+	amf3ArrayMarker        = 0x09
+	amf3ObjectMarker       = 0x0A
+	amf3XMLMarker          = 0x0B
+	amf3ByteArrayMarker    = 0x0C
+	amf3VectorIntMarker    = 0x0D
+	amf3VectorUintMarker   = 0x0E
+	amf3VectorDoubleMarker = 0x0F
+	amf3VectorObjectMarker = 0x10
+	amf3DictionaryMarker   = 0x11
+)
+
+// ErrAMF3Externalizable은 클래스별 직렬화 형식을 알 수 없는 externalizable 객체를 만난 경우의 에러
+var ErrAMF3Externalizable = errors.New("AMF3 externalizable objects are not supported")
+
+// amf3Traits는 AMF3 객체의 클래스 정보 (trait 참조 테이블에 보관)
+type amf3Traits struct {
+	className      string
+	dynamic        bool
+	externalizable bool
+	members        []string // sealed 멤버 이름 (순서대로 값이 나옴)
+}
+
+// amf3Decoder는 AMF3 값 하나를 디코딩하는 동안의 참조 테이블
+// AMF0 스트림 안의 avmplus 값(0x11)마다 새 테이블로 시작한다
+type amf3Decoder struct {
+	r    io.Reader
+	opts *DecodeOptions
+
+	strings []string
+	objects []any
+	traits  []*amf3Traits
+}
+
+// decodeAMF3는 AMF0 avmplus 마커 뒤의 AMF3 값 하나를 디코딩
+// 숫자는 AMF0과 같이 float64, 객체와 연관 배열은 map[string]any, 밀집 배열은 []any로 반환
+func decodeAMF3(r io.Reader, opts *DecodeOptions) (any, error) {
+	d := &amf3Decoder{r: r, opts: opts}
+	return d.decodeValue()
+}
+
+func (d *amf3Decoder) decodeValue() (any, error) {
+	marker := make([]byte, 1)
+	if _, err := io.ReadFull(d.r, marker); err != nil {
+		return nil, err
+	}
+
+	switch marker[0] {
+	case amf3UndefinedMarker, amf3NullMarker:
+		return nil, nil
+	case amf3FalseMarker:
+		return false, nil
+	case amf3TrueMarker:
+		return true, nil
+	case amf3IntegerMarker:
+		return d.decodeInteger()
+	case amf3DoubleMarker:
+		return readFloat64(d.r)
+	case amf3StringMarker:
+		return d.decodeString()
+	case amf3XMLDocMarker, amf3XMLMarker:
+		return d.decodeXML()
+	case amf3DateMarker:
+		return d.decodeDate()
+	case amf3ArrayMarker:
+		return d.decodeArray()
+	case amf3ObjectMarker:
+		return d.decodeObject()
+	case amf3ByteArrayMarker:
+		return d.decodeByteArray()
+	case amf3VectorIntMarker, amf3VectorUintMarker, amf3VectorDoubleMarker, amf3VectorObjectMarker, amf3DictionaryMarker:
+		return nil, fmt.Errorf("unsupported AMF3 marker: 0x%x", marker[0])
+	default:
+		return nil, fmt.Errorf("unknown AMF3 marker: 0x%x", marker[0])
+	}
+}
+
+// decodeInteger는 29비트 부호 있는 정수를 읽는다 (AMF0 숫자와 맞춰 float64로 반환)
+func (d *amf3Decoder) decodeInteger() (float64, error) {
+	u29, err := readU29(d.r)
+	if err != nil {
+		return 0, err
+	}
+	value := int32(u29)
+	if u29&0x10000000 != 0 {
+		value -= 1 << 29
+	}
+	return float64(value), nil
+}
+
+// decodeString은 문자열을 읽는다 (하위 비트가 0이면 문자열 참조, 빈 문자열은 참조 테이블에 넣지 않음)
+func (d *amf3Decoder) decodeString() (string, error) {
+	header, err := readU29(d.r)
+	if err != nil {
+		return "", err
+	}
+	if header&1 == 0 {
+		index := int(header >> 1)
+		if index >= len(d.strings) {
+			return "", fmt.Errorf("invalid AMF3 string reference: %d", index)
+		}
+		return d.strings[index], nil
+	}
+
+	buf, err := readBytes(d.r, header>>1)
+	if err != nil {
+		return "", err
+	}
+	s := string(buf)
+	if s != "" {
+		d.strings = append(d.strings, s)
+	}
+	return s, nil
+}
+
+// readObjectHeader는 객체 참조 테이블을 쓰는 타입의 U29 헤더를 읽는다
+// 참조면 참조된 값과 true, 아니면 하위 비트를 뺀 값(길이 등)을 반환
+func (d *amf3Decoder) readObjectHeader() (uint32, any, bool, error) {
+	header, err := readU29(d.r)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if header&1 == 0 {
+		index := int(header >> 1)
+		if index >= len(d.objects) {
+			return 0, nil, false, fmt.Errorf("invalid AMF3 object reference: %d", index)
+		}
+		return 0, d.objects[index], true, nil
+	}
+	return header >> 1, nil, false, nil
+}
+
+// decodeXML은 XMLDocument와 XML 값을 문자열로 읽는다
+func (d *amf3Decoder) decodeXML() (any, error) {
+	length, ref, isRef, err := d.readObjectHeader()
+	if err != nil || isRef {
+		return ref, err
+	}
+	buf, err := readBytes(d.r, length)
+	if err != nil {
+		return nil, err
+	}
+	s := string(buf)
+	d.objects = append(d.objects, s)
+	return s, nil
+}
+
+// decodeDate는 UTC 기준 밀리초 날짜를 읽는다 (AMF0 날짜와 달리 시간대 필드 없음)
+func (d *amf3Decoder) decodeDate() (any, error) {
+	_, ref, isRef, err := d.readObjectHeader()
+	if err != nil || isRef {
+		return ref, err
+	}
+	millis, err := readFloat64(d.r)
+	if err != nil {
+		return nil, err
+	}
+	date := time.UnixMilli(int64(millis)).UTC()
+	d.objects = append(d.objects, date)
+	return date, nil
+}
+
+// decodeByteArray는 바이트 배열을 읽는다
+func (d *amf3Decoder) decodeByteArray() (any, error) {
+	length, ref, isRef, err := d.readObjectHeader()
+	if err != nil || isRef {
+		return ref, err
+	}
+	buf, err := readBytes(d.r, length)
+	if err != nil {
+		return nil, err
+	}
+	d.objects = append(d.objects, buf)
+	return buf, nil
+}
+
+// decodeArray는 배열을 읽는다
+// 연관 부분이 비어 있으면 []any, 있으면 밀집 부분을 인덱스 문자열 키로 합친 map[string]any
+func (d *amf3Decoder) decodeArray() (any, error) {
+	count, ref, isRef, err := d.readObjectHeader()
+	if err != nil || isRef {
+		return ref, err
+	}
+
+	// 자식이 이 배열을 참조할 수 있으므로 먼저 자리를 잡아 둔다
+	index := len(d.objects)
+	d.objects = append(d.objects, nil)
+
+	var assoc map[string]any
+	for {
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			break
+		}
+		if assoc == nil {
+			assoc = make(map[string]any)
+			d.objects[index] = assoc
+		}
+		val, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setObjectValue(assoc, key, val, d.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// 선언된 개수만큼 미리 할당하지 않음 (잘못된 개수로 큰 메모리를 잡지 않도록)
+	var dense []any
+	for i := uint32(0); i < count; i++ {
+		val, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if assoc != nil {
+			if err := setObjectValue(assoc, strconv.Itoa(int(i)), val, d.opts); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		dense = append(dense, val)
+	}
+
+	if assoc != nil {
+		return assoc, nil
+	}
+	if dense == nil {
+		dense = []any{}
+	}
+	d.objects[index] = dense
+	return dense, nil
+}
+
+// decodeObject는 객체를 읽는다 (sealed 멤버 다음에 dynamic 멤버, 클래스 이름은 버림)
+func (d *amf3Decoder) decodeObject() (any, error) {
+	header, ref, isRef, err := d.readObjectHeader()
+	if err != nil || isRef {
+		return ref, err
+	}
+
+	traits, err := d.decodeTraits(header)
+	if err != nil {
+		return nil, err
+	}
+	if traits.externalizable {
+		return nil, fmt.Errorf("%w: %s", ErrAMF3Externalizable, traits.className)
+	}
+
+	obj := make(map[string]any)
+	d.objects = append(d.objects, obj)
+
+	for _, member := range traits.members {
+		val, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setObjectValue(obj, member, val, d.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if traits.dynamic {
+		for {
+			key, err := d.decodeString()
+			if err != nil {
+				return nil, err
+			}
+			if key == "" {
+				break
+			}
+			val, err := d.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := setObjectValue(obj, key, val, d.opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return obj, nil
+}
+
+// decodeTraits는 객체 헤더(객체 참조 비트를 뺀 값)에서 trait 정보를 읽는다
+func (d *amf3Decoder) decodeTraits(header uint32) (*amf3Traits, error) {
+	if header&1 == 0 {
+		index := int(header >> 1)
+		if index >= len(d.traits) {
+			return nil, fmt.Errorf("invalid AMF3 traits reference: %d", index)
+		}
+		return d.traits[index], nil
+	}
+
+	traits := &amf3Traits{
+		externalizable: header&2 != 0,
+		dynamic:        header&4 != 0,
+	}
+	className, err := d.decodeString()
+	if err != nil {
+		return nil, err
+	}
+	traits.className = className
+
+	for i := uint32(0); i < header>>3; i++ {
+		member, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		traits.members = append(traits.members, member)
+	}
+	d.traits = append(d.traits, traits)
+	return traits, nil
+}
+
+// readU29는 AMF3 가변 길이 정수(1~4바이트)를 읽는다
+// 앞의 세 바이트는 상위 비트가 다음 바이트 유무, 네 번째 바이트는 8비트 전부 사용
+func readU29(r io.Reader) (uint32, error) {
+	var result uint32
+	b := make([]byte, 1)
+	for i := 0; i < 4; i++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, err
+		}
+		if i == 3 {
+			return result<<8 | uint32(b[0]), nil
+		}
+		result = result<<7 | uint32(b[0]&0x7F)
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+// readBytes는 length 바이트를 읽는다
+func readBytes(r io.Reader, length uint32) ([]byte, error) {
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
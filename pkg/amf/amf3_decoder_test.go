@@ -0,0 +1,143 @@
+package amf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeAMF0Sequence_AVMPlusObject(t *testing.T) {
+	// connect 명령: AMF0 명령 이름/트랜잭션 ID 뒤에 AMF3로 전환된 명령 객체, 그 뒤 다시 AMF0 null
+	data := []byte{
+		0x02, 0x00, 0x07, 'c', 'o', 'n', 'n', 'e', 'c', 't',
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x11,             // avmplus: AMF3로 전환
+		0x0A, 0x0B, 0x01, // 동적 익명 객체 (sealed 멤버 없음)
+		0x07, 'a', 'p', 'p', 0x06, 0x09, 'l', 'i', 'v', 'e',
+		0x1D, 'o', 'b', 'j', 'e', 'c', 't', 'E', 'n', 'c', 'o', 'd', 'i', 'n', 'g', 0x04, 0x03,
+		0x01, // 동적 멤버 끝
+		0x05, // AMF0 null
+	}
+
+	values, err := DecodeAMF0Sequence(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected AMF3 value to decode, got: %v", err)
+	}
+	if len(values) != 4 {
+		t.Fatalf("expected 4 values, got %d: %v", len(values), values)
+	}
+	if values[0] != "connect" || values[1] != 1.0 || values[3] != nil {
+		t.Fatalf("expected surrounding AMF0 values, got %v", values)
+	}
+
+	obj, ok := values[2].(map[string]any)
+	if !ok {
+		t.Fatalf("expected AMF3 object as map, got %T", values[2])
+	}
+	if obj["app"] != "live" || obj["objectEncoding"] != 3.0 {
+		t.Fatalf("unexpected AMF3 object: %v", obj)
+	}
+}
+
+func TestDecodeAMF3_References(t *testing.T) {
+	// 같은 클래스 객체 두 개(trait 참조, 문자열 참조)와 첫 객체를 다시 가리키는 객체 참조
+	data := []byte{
+		0x11,
+		0x09, 0x07, 0x01, // 밀집 배열 3개, 연관 부분 없음
+		0x0A, 0x13, 0x03, 'P', 0x03, 'x', 0x04, 0x01, // class P { x } 인라인 trait, x = 1
+		0x0A, 0x01, 0x06, 0x02, // trait 참조 0, x = 문자열 참조 1 ("x")
+		0x0A, 0x02, // 객체 참조 1 (첫 객체)
+	}
+
+	value, err := DecodeAMF0(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected AMF3 array to decode, got: %v", err)
+	}
+	arr, ok := value.([]any)
+	if !ok || len(arr) != 3 {
+		t.Fatalf("expected 3-element array, got %#v", value)
+	}
+
+	first := arr[0].(map[string]any)
+	if first["x"] != 1.0 {
+		t.Fatalf("expected first.x = 1, got %v", first["x"])
+	}
+	if second := arr[1].(map[string]any); second["x"] != "x" {
+		t.Fatalf("expected second.x = \"x\" from string reference, got %v", second["x"])
+	}
+	if third := arr[2].(map[string]any); third["x"] != 1.0 {
+		t.Fatalf("expected object reference to the first object, got %v", third)
+	}
+}
+
+func TestDecodeAMF3_Scalars(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected any
+	}{
+		{"true", []byte{0x11, 0x03}, true},
+		{"negative integer", []byte{0x11, 0x04, 0xFF, 0xFF, 0xFF, 0xFF}, -1.0},
+		{"two-byte integer", []byte{0x11, 0x04, 0x81, 0x00}, 128.0},
+		{"double", []byte{0x11, 0x05, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1.5},
+		{"empty string", []byte{0x11, 0x06, 0x01}, ""},
+		{"null", []byte{0x11, 0x01}, nil},
+		{"date", []byte{0x11, 0x08, 0x01, 0x42, 0x71, 0x06, 0x96, 0xf3, 0x40, 0x00, 0x00}, time.UnixMilli(1170000000000).UTC()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := DecodeAMF0(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("expected value to decode, got: %v", err)
+			}
+			if value != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestDecodeAMF3_AssociativeArray(t *testing.T) {
+	// 연관 부분 { name: "a" } + 밀집 부분 [true]
+	data := []byte{0x11, 0x09, 0x03, 0x09, 'n', 'a', 'm', 'e', 0x06, 0x03, 'a', 0x01, 0x03}
+
+	value, err := DecodeAMF0(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected associative array to decode, got: %v", err)
+	}
+	obj, ok := value.(map[string]any)
+	if !ok || obj["name"] != "a" || obj["0"] != true {
+		t.Fatalf("expected merged map, got %#v", value)
+	}
+}
+
+func TestDecodeAMF3_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated integer", []byte{0x11, 0x04, 0x81}},
+		{"invalid string reference", []byte{0x11, 0x06, 0x02}},
+		{"invalid object reference", []byte{0x11, 0x0A, 0x00}},
+		{"vector", []byte{0x11, 0x0D, 0x01, 0x00}},
+		{"unknown marker", []byte{0x11, 0x20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAMF0(bytes.NewReader(tt.data)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestDecodeAMF3_Externalizable(t *testing.T) {
+	data := []byte{0x11, 0x0A, 0x07, 0x03, 'E'} // externalizable 클래스 E
+	_, err := DecodeAMF0(bytes.NewReader(data))
+	if !errors.Is(err, ErrAMF3Externalizable) {
+		t.Fatalf("expected ErrAMF3Externalizable, got %v", err)
+	}
+}
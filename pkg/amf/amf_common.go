@@ -1,5 +1,7 @@
 package amf
 
+import "errors"
+
 const (
 	numberMarker      = 0x00
 	booleanMarker     = 0x01
@@ -12,4 +14,48 @@ const (
 	strictArrayMarker = 0x0A
 	dateMarker        = 0x0B
 	longStringMarker  = 0x0C
+
+	avmplusObjectMarker = 0x11 // 다음 값은 AMF3로 인코딩됨 (objectEncoding 3)
+)
+
+// ErrDuplicateKey는 DuplicateKeyError 정책에서 객체에 같은 키가 두 번 나온 경우의 에러
+var ErrDuplicateKey = errors.New("duplicate AMF0 object key")
+
+// ErrTooManyProperties는 객체의 속성 수가 MaxObjectProperties를 넘은 경우의 에러
+var ErrTooManyProperties = errors.New("too many AMF object properties")
+
+// ErrObjectTooLarge는 객체의 인코딩 크기가 MaxObjectBytes를 넘은 경우의 에러
+var ErrObjectTooLarge = errors.New("AMF object too large")
+
+const (
+	DefaultMaxObjectProperties = 1024    // 객체 하나의 기본 최대 속성 수
+	DefaultMaxObjectBytes      = 1 << 20 // 최상위 객체 하나의 기본 최대 인코딩 크기 (중첩 객체 포함)
 )
+
+// DuplicateKeyPolicy는 객체 디코딩 중 중복 키를 처리하는 방식
+type DuplicateKeyPolicy int
+
+const (
+	DuplicateKeyKeepLast  DuplicateKeyPolicy = iota // 마지막 값 유지 (기본 동작)
+	DuplicateKeyKeepFirst                           // 처음 값 유지
+	DuplicateKeyError                               // 디코딩 실패
+)
+
+// DecodeOptions는 AMF0 디코딩 옵션
+type DecodeOptions struct {
+	DuplicateKeys  DuplicateKeyPolicy
+	OnDuplicateKey func(key string) // 중복 키 발견 시 호출 (경고 로그/통계용, nil 가능)
+
+	// 끝 표시 전까지 속성을 무한히 쌓아 메모리를 소진하지 않도록 하는 제한 (0이면 제한 없음)
+	MaxObjectProperties int // 객체(ECMA 배열, AMF3 객체 포함) 하나의 최대 속성 수
+	MaxObjectBytes      int // 최상위 객체 하나가 읽을 수 있는 최대 바이트 (중첩된 값 포함)
+}
+
+// DefaultDecodeOptions는 기본 디코딩 옵션을 반환
+func DefaultDecodeOptions() DecodeOptions {
+	return DecodeOptions{
+		DuplicateKeys:       DuplicateKeyKeepLast,
+		MaxObjectProperties: DefaultMaxObjectProperties,
+		MaxObjectBytes:      DefaultMaxObjectBytes,
+	}
+}
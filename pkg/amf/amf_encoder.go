@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"reflect"
 	"time"
 )
 
@@ -21,8 +22,7 @@ func EncodeAMF0Sequence(values ...any) ([]byte, error) {
 func encodeValue(w io.Writer, value any) error {
 	switch v := value.(type) {
 	case nil:
-		_, err := w.Write([]byte{nullMarker})
-		return err
+		return encodeNull(w)
 	case bool:
 		b := byte(0)
 		if v {
@@ -64,8 +64,26 @@ func encodeValue(w io.Writer, value any) error {
 	case time.Time:
 		return encodeDate(w, v)
 	default:
+		return encodePointer(w, value)
+	}
+}
+
+// encodePointer는 포인터가 가리키는 값을 인코딩 (nil 포인터는 null)
+// 구조체 필드에서 옮겨 온 *string, *float64 같은 선택적 값을 그대로 넘길 수 있도록 함
+func encodePointer(w io.Writer, value any) error {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Pointer {
 		return errors.New("unsupported AMF0 type")
 	}
+	if rv.IsNil() {
+		return encodeNull(w)
+	}
+	return encodeValue(w, rv.Elem().Interface())
+}
+
+func encodeNull(w io.Writer) error {
+	_, err := w.Write([]byte{nullMarker})
+	return err
 }
 
 func encodeString(w io.Writer, s string) error {
@@ -514,6 +514,66 @@ func TestEncodeAMF0_UnsupportedType(t *testing.T) {
 	}
 }
 
+func TestEncodeAMF0_Pointer(t *testing.T) {
+	str := "test"
+	num := 1.5
+	strPtr := &str
+
+	tests := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{"string pointer", &str, "test"},
+		{"float64 pointer", &num, 1.5},
+		{"pointer to pointer", &strPtr, "test"},
+		{"object with pointer field", map[string]any{"name": &str}, map[string]any{"name": "test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeAMF0Sequence(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := EncodeAMF0Sequence(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, expected) {
+				t.Errorf("expected %v, got %v", expected, data)
+			}
+		})
+	}
+}
+
+func TestEncodeAMF0_NilPointer(t *testing.T) {
+	var str *string
+	var num *float64
+	var value any = num // typed nil
+
+	data, err := EncodeAMF0Sequence(str, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{0x05, 0x05}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+}
+
+func TestEncodeAMF0_PointerToUnsupportedType(t *testing.T) {
+	type customType struct {
+		field string
+	}
+
+	_, err := EncodeAMF0Sequence(&customType{field: "test"})
+	if err == nil || err.Error() != "unsupported AMF0 type" {
+		t.Errorf("expected 'unsupported AMF0 type', got %v", err)
+	}
+}
+
 func TestWriteByte_Success(t *testing.T) {
 	buf := new(bytes.Buffer)
 	err := writeByte(buf, 0xFF)
@@ -0,0 +1,143 @@
+// Package codec identifies media codecs the same way across RTMP (FLV codec
+// IDs and FourCCs), RTSP (SDP rtpmap encoding names) and RTP
+package codec
+
+import "strings"
+
+// Codec identifies a media codec
+type Codec string
+
+const (
+	Unknown Codec = ""
+
+	// Video
+	H264        Codec = "H264"
+	H265        Codec = "H265"
+	H263        Codec = "H263"        // Sorenson H.263 (FLV only)
+	VP6         Codec = "VP6"         // On2 VP6, with or without alpha (FLV only)
+	ScreenVideo Codec = "ScreenVideo" // Screen video v1/v2 (FLV only)
+
+	// Audio
+	AAC        Codec = "AAC"
+	MP3        Codec = "MP3"
+	PCMA       Codec = "PCMA" // G.711 A-law
+	PCMU       Codec = "PCMU" // G.711 mu-law
+	LPCM       Codec = "LPCM" // Linear PCM (FLV only)
+	ADPCM      Codec = "ADPCM"
+	Nellymoser Codec = "Nellymoser"
+	Speex      Codec = "Speex"
+)
+
+// FLV codec IDs: the VIDEODATA CodecID and the AUDIODATA SoundFormat, also used
+// by onMetaData videocodecid/audiocodecid (12 is the de-facto HEVC extension)
+var (
+	flvVideoCodecs = map[uint8]Codec{
+		2:  H263,
+		3:  ScreenVideo,
+		4:  VP6,
+		5:  VP6,
+		6:  ScreenVideo,
+		7:  H264,
+		12: H265,
+	}
+	flvAudioCodecs = map[uint8]Codec{
+		0:  LPCM,
+		1:  ADPCM,
+		2:  MP3,
+		3:  LPCM,
+		4:  Nellymoser,
+		5:  Nellymoser,
+		6:  Nellymoser,
+		7:  PCMA,
+		8:  PCMU,
+		10: AAC,
+		11: Speex,
+		14: MP3,
+	}
+	fourCCCodecs = map[string]Codec{
+		"avc1": H264,
+		"hvc1": H265,
+		"hev1": H265,
+		"mp4a": AAC,
+		".mp3": MP3,
+	}
+)
+
+// rtpEncodingNames are the SDP rtpmap encoding names of codecs that can be carried over RTP
+var rtpEncodingNames = map[Codec]string{
+	H264: "H264",
+	H265: "H265",
+	AAC:  "MPEG4-GENERIC",
+	MP3:  "MPA",
+	PCMA: "PCMA",
+	PCMU: "PCMU",
+}
+
+// FromFLVVideoID maps an FLV video CodecID to a Codec (Unknown if unrecognized)
+func FromFLVVideoID(id uint8) Codec {
+	return flvVideoCodecs[id]
+}
+
+// FromFLVAudioID maps an FLV audio SoundFormat to a Codec (Unknown if unrecognized)
+func FromFLVAudioID(id uint8) Codec {
+	return flvAudioCodecs[id]
+}
+
+// FromFourCC maps an (enhanced RTMP / MP4) FourCC to a Codec, case-insensitively
+func FromFourCC(fourCC string) Codec {
+	return fourCCCodecs[strings.ToLower(fourCC)]
+}
+
+// FromRTPEncodingName maps an SDP rtpmap encoding ("H264" or "H264/90000") to a Codec
+func FromRTPEncodingName(encoding string) Codec {
+	name, _, _ := strings.Cut(encoding, "/")
+	for codec, encodingName := range rtpEncodingNames {
+		if strings.EqualFold(name, encodingName) {
+			return codec
+		}
+	}
+	return Unknown
+}
+
+// String returns the codec name, or "unknown" for Unknown
+func (c Codec) String() string {
+	if c == Unknown {
+		return "unknown"
+	}
+	return string(c)
+}
+
+// IsVideo reports whether c is a video codec
+func (c Codec) IsVideo() bool {
+	switch c {
+	case H264, H265, H263, VP6, ScreenVideo:
+		return true
+	}
+	return false
+}
+
+// IsAudio reports whether c is an audio codec
+func (c Codec) IsAudio() bool {
+	switch c {
+	case AAC, MP3, PCMA, PCMU, LPCM, ADPCM, Nellymoser, Speex:
+		return true
+	}
+	return false
+}
+
+// RTPEncodingName returns the SDP rtpmap encoding name, or "" if c has no RTP payload format
+func (c Codec) RTPEncodingName() string {
+	return rtpEncodingNames[c]
+}
+
+// RTPClockRate returns the RTP timestamp clock rate (RFC 3551 / RFC 6184).
+// AAC is clocked at its sample rate, so 0 is returned for it and for codecs without an RTP payload format
+func (c Codec) RTPClockRate() int {
+	switch c {
+	case H264, H265, MP3:
+		return 90000
+	case PCMA, PCMU:
+		return 8000
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package codec
+
+import "testing"
+
+func TestFromFLVVideoID(t *testing.T) {
+	tests := []struct {
+		id       uint8
+		expected Codec
+	}{
+		{2, H263},
+		{4, VP6},
+		{5, VP6},
+		{7, H264},
+		{12, H265},
+		{9, Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := FromFLVVideoID(tt.id); got != tt.expected {
+			t.Errorf("FromFLVVideoID(%d): Expected %s, got %s", tt.id, tt.expected, got)
+		}
+	}
+}
+
+func TestFromFLVAudioID(t *testing.T) {
+	tests := []struct {
+		id       uint8
+		expected Codec
+	}{
+		{2, MP3},
+		{7, PCMA},
+		{8, PCMU},
+		{10, AAC},
+		{11, Speex},
+		{14, MP3},
+		{9, Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := FromFLVAudioID(tt.id); got != tt.expected {
+			t.Errorf("FromFLVAudioID(%d): Expected %s, got %s", tt.id, tt.expected, got)
+		}
+		if tt.expected != Unknown && (!tt.expected.IsAudio() || tt.expected.IsVideo()) {
+			t.Errorf("Expected %s to be an audio codec", tt.expected)
+		}
+	}
+}
+
+func TestFromFourCC(t *testing.T) {
+	if got := FromFourCC("HVC1"); got != H265 {
+		t.Errorf("Expected H265 for HVC1, got %s", got)
+	}
+	if got := FromFourCC("av01"); got != Unknown {
+		t.Errorf("Expected unknown for av01, got %s", got)
+	}
+}
+
+func TestRTPEncodingNameRoundTrip(t *testing.T) {
+	for _, c := range []Codec{H264, H265, AAC, MP3, PCMA, PCMU} {
+		name := c.RTPEncodingName()
+		if name == "" {
+			t.Fatalf("Expected an RTP encoding name for %s", c)
+		}
+		if got := FromRTPEncodingName(name + "/90000"); got != c {
+			t.Errorf("Expected %s for encoding %q, got %s", c, name, got)
+		}
+	}
+
+	if got := FromRTPEncodingName("mpeg4-generic/44100/2"); got != AAC {
+		t.Errorf("Expected case-insensitive AAC match, got %s", got)
+	}
+	if VP6.RTPEncodingName() != "" || VP6.RTPClockRate() != 0 {
+		t.Error("Expected VP6 to have no RTP payload format")
+	}
+}
@@ -0,0 +1,112 @@
+// Package deadletter records events that reach an event loop without a
+// matching handler, so event types that were added but never wired up show
+// up in metrics and logs instead of being dropped silently
+package deadletter
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxStringLen limits how much of a string field goes into a summary
+const maxStringLen = 64
+
+// Recorder counts unhandled events per event type and optionally logs them.
+// Record is called from an event loop; the counters may be read from any goroutine.
+type Recorder struct {
+	component string // event loop name used in logs ("rtmp", "rtsp", ...)
+	log       bool
+
+	total  atomic.Uint64
+	mu     sync.Mutex
+	byType map[string]uint64
+}
+
+// New creates a Recorder for the named event loop. When log is true every
+// unhandled event is also logged at warn level with its type and summary.
+func New(component string, log bool) *Recorder {
+	return &Recorder{
+		component: component,
+		log:       log,
+		byType:    make(map[string]uint64),
+	}
+}
+
+// Record registers an event that no handler accepted
+func (r *Recorder) Record(event any) {
+	eventType := fmt.Sprintf("%T", event)
+
+	r.total.Add(1)
+	r.mu.Lock()
+	r.byType[eventType]++
+	r.mu.Unlock()
+
+	if r.log {
+		slog.Warn("Unhandled event", "component", r.component, "eventType", eventType, "summary", Summary(event))
+	}
+}
+
+// Count returns the number of unhandled events recorded so far
+func (r *Recorder) Count() uint64 {
+	return r.total.Load()
+}
+
+// CountByType returns a snapshot of unhandled event counts keyed by Go type name
+func (r *Recorder) CountByType() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]uint64, len(r.byType))
+	for eventType, count := range r.byType {
+		counts[eventType] = count
+	}
+	return counts
+}
+
+// Summary describes an event without dumping its payload: exported struct
+// fields are listed with scalar values, strings are truncated and byte
+// slices, slices and maps are reduced to their length
+func Summary(event any) string {
+	v := reflect.ValueOf(event)
+	if !v.IsValid() {
+		return "<nil>"
+	}
+	if v.Kind() != reflect.Struct {
+		return summarizeValue(v)
+	}
+
+	t := v.Type()
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		fields = append(fields, t.Field(i).Name+"="+summarizeValue(v.Field(i)))
+	}
+	return strings.Join(fields, " ")
+}
+
+// summarizeValue formats a single value for Summary
+func summarizeValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if len(s) > maxStringLen {
+			s = s[:maxStringLen] + "..."
+		}
+		return fmt.Sprintf("%q", s)
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return fmt.Sprintf("%s(len=%d)", v.Type(), v.Len())
+	default:
+		return v.Type().String()
+	}
+}
@@ -0,0 +1,34 @@
+package deadletter
+
+import "testing"
+
+type unknownEvent struct {
+	SessionId string
+	Timestamp uint32
+	Data      []byte
+	secret    string
+}
+
+func TestRecorderCountsByType(t *testing.T) {
+	r := New("test", false)
+	r.Record(unknownEvent{SessionId: "a"})
+	r.Record(unknownEvent{SessionId: "b"})
+	r.Record(42)
+
+	if r.Count() != 3 {
+		t.Fatalf("Expected 3 unhandled events, got %d", r.Count())
+	}
+	counts := r.CountByType()
+	if counts["deadletter.unknownEvent"] != 2 || counts["int"] != 1 {
+		t.Fatalf("Expected per-type counts, got %v", counts)
+	}
+}
+
+func TestSummaryOmitsPayload(t *testing.T) {
+	summary := Summary(unknownEvent{SessionId: "abc", Timestamp: 1000, Data: make([]byte, 4096), secret: "token"})
+
+	expected := `SessionId="abc" Timestamp=1000 Data=[]uint8(len=4096)`
+	if summary != expected {
+		t.Fatalf("Expected %q, got %q", expected, summary)
+	}
+}
@@ -0,0 +1,205 @@
+// Package feed streams server events to HTTP clients as Server-Sent Events,
+// so dashboards can follow stream status without polling
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types published to the feed
+const (
+	EventStreamOnline       = "stream_online"        // a publisher started a stream
+	EventStreamOffline      = "stream_offline"       // the publisher of a stream went away
+	EventPlayerCountChanged = "player_count_changed" // the number of players of a stream changed
+	EventCodecMismatch      = "codec_mismatch"       // frames use a different codec than the metadata declares
+)
+
+// DefaultClientBufferSize is the number of events queued per client before it is
+// considered too slow and disconnected
+const DefaultClientBufferSize = 64
+
+// keepAliveInterval is how often an SSE comment is sent to idle clients, so
+// proxies do not time the connection out and dead clients are noticed
+const keepAliveInterval = 15 * time.Second
+
+// Event is one feed notification; Data is sent as the JSON "data:" field
+type Event struct {
+	Type string
+	Data any
+}
+
+// StreamStatus is the Data of the stream events
+type StreamStatus struct {
+	Stream  string `json:"stream"`
+	Players int    `json:"players"`
+}
+
+// CodecMismatch is the Data of EventCodecMismatch
+type CodecMismatch struct {
+	Stream   string `json:"stream"`
+	Media    string `json:"media"` // "video" or "audio"
+	Declared string `json:"declared"`
+	Detected string `json:"detected"`
+}
+
+// Hub fans published events out to the connected SSE clients.
+// Publish never blocks: a client whose buffer is full is disconnected.
+type Hub struct {
+	bufferSize int
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closed  bool
+
+	evicted atomic.Uint64 // clients disconnected for falling behind
+}
+
+// client is one connected subscriber
+type client struct {
+	events chan []byte
+	done   chan struct{} // closed when the hub drops the client
+}
+
+// NewHub creates a Hub that queues up to bufferSize events per client
+// (DefaultClientBufferSize if bufferSize <= 0)
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultClientBufferSize
+	}
+	return &Hub{
+		bufferSize: bufferSize,
+		clients:    make(map[*client]struct{}),
+	}
+}
+
+// Publish sends event to every connected client
+func (h *Hub) Publish(event Event) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		slog.Error("Failed to encode feed event", "type", event.Type, "err", err)
+		return
+	}
+	message := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.events <- message:
+		default:
+			h.dropLocked(c)
+			h.evicted.Add(1)
+			slog.Warn("Feed client too slow, disconnecting", "type", event.Type)
+		}
+	}
+}
+
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// GetEvictedCount returns the number of clients disconnected for falling behind
+func (h *Hub) GetEvictedCount() uint64 {
+	return h.evicted.Load()
+}
+
+// Close disconnects every client and rejects new ones, so an http.Server
+// shutdown does not wait on open event streams
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		h.dropLocked(c)
+	}
+}
+
+// ServeHTTP streams events to the client until it disconnects or the hub drops it
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c, ok := h.subscribe()
+	if !ok {
+		http.Error(w, "feed closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	slog.Info("Feed client connected", "remoteAddr", r.RemoteAddr)
+	defer slog.Info("Feed client disconnected", "remoteAddr", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case message := <-c.events:
+			if _, err := w.Write(message); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// subscribe registers a new client, or returns false if the hub is closed
+func (h *Hub) subscribe() (*client, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+	c := &client{
+		events: make(chan []byte, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+	h.clients[c] = struct{}{}
+	return c, true
+}
+
+// unsubscribe removes a client when its request ends
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropLocked(c)
+}
+
+// dropLocked removes a client and wakes its handler; h.mu must be held
+func (h *Hub) dropLocked(c *client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	close(c.done)
+}
@@ -0,0 +1,142 @@
+package feed
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFeedServer serves hub over HTTP; registered as a cleanup so it closes
+// after the subscriber bodies (server.Close waits for open event streams)
+func newFeedServer(t *testing.T, hub *Hub) *httptest.Server {
+	server := httptest.NewServer(hub)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// subscribe opens the feed and returns a reader over its event stream
+func subscribe(t *testing.T, ctx context.Context, url string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", contentType)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent reads one SSE event block (up to the blank line)
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+// waitForClients waits until the hub has the expected number of clients
+func waitForClients(t *testing.T, hub *Hub, expected int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.ClientCount() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d feed clients, got %d", expected, hub.ClientCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFeedDeliversPublishedEvents(t *testing.T) {
+	hub := NewHub(0)
+	server := newFeedServer(t, hub)
+
+	reader := subscribe(t, context.Background(), server.URL)
+	waitForClients(t, hub, 1)
+
+	hub.Publish(Event{Type: EventStreamOnline, Data: StreamStatus{Stream: "live/test"}})
+
+	expected := "event: stream_online\ndata: {\"stream\":\"live/test\",\"players\":0}"
+	if event := readEvent(t, reader); event != expected {
+		t.Fatalf("Expected %q, got %q", expected, event)
+	}
+}
+
+func TestFeedRemovesDisconnectedClient(t *testing.T) {
+	hub := NewHub(0)
+	server := newFeedServer(t, hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribe(t, ctx, server.URL)
+	waitForClients(t, hub, 1)
+
+	cancel()
+	waitForClients(t, hub, 0)
+
+	// Publishing after the client is gone must not block or panic
+	hub.Publish(Event{Type: EventStreamOffline, Data: StreamStatus{Stream: "live/test"}})
+}
+
+func TestFeedDropsSlowClient(t *testing.T) {
+	hub := NewHub(1)
+	c, _ := hub.subscribe()
+
+	hub.Publish(Event{Type: EventStreamOnline, Data: StreamStatus{Stream: "a"}})
+	hub.Publish(Event{Type: EventStreamOnline, Data: StreamStatus{Stream: "b"}})
+
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("Expected slow client to be dropped")
+	}
+	if hub.ClientCount() != 0 || hub.GetEvictedCount() != 1 {
+		t.Fatalf("Expected 0 clients and 1 eviction, got %d and %d", hub.ClientCount(), hub.GetEvictedCount())
+	}
+}
+
+func TestClosedHubRejectsClients(t *testing.T) {
+	hub := NewHub(0)
+	server := newFeedServer(t, hub)
+
+	reader := subscribe(t, context.Background(), server.URL)
+	waitForClients(t, hub, 1)
+
+	hub.Close()
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Fatal("Expected event stream to end when the hub closes")
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to request feed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", resp.StatusCode)
+	}
+}
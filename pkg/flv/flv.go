@@ -0,0 +1,236 @@
+// Package flv reads and writes the FLV container: the file header and the
+// tags that frame audio, video and script data. RTMP audio/video/data message
+// payloads are FLV tag bodies, so they can be written as tags unchanged.
+package flv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tag types (the same values as the RTMP message type IDs)
+const (
+	TagTypeAudio  uint8 = 8
+	TagTypeVideo  uint8 = 9
+	TagTypeScript uint8 = 18 // AMF0 script data (onMetaData, ...)
+)
+
+const (
+	HeaderSize          = 9        // FLV file header
+	TagHeaderSize       = 11       // tag header before the data
+	PreviousTagSizeSize = 4        // size of the previous tag written after each tag
+	MaxDataSize         = 0xFFFFFF // largest tag data size (24 bits)
+)
+
+// Header flags
+const (
+	flagVideo = 0x01
+	flagAudio = 0x04
+)
+
+var (
+	// ErrInvalidSignature is returned when a file does not start with "FLV"
+	ErrInvalidSignature = errors.New("flv: invalid signature")
+
+	// ErrDataTooLarge is returned when tag data does not fit the 24-bit size field
+	ErrDataTooLarge = errors.New("flv: tag data too large")
+
+	// ErrPreviousTagSizeMismatch is returned when the size written after a tag
+	// does not match the tag that precedes it
+	ErrPreviousTagSizeMismatch = errors.New("flv: previous tag size mismatch")
+)
+
+// Header is the FLV file header
+type Header struct {
+	Version  uint8
+	HasAudio bool
+	HasVideo bool
+}
+
+// ParseHeader parses a file header from the first HeaderSize bytes of b
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < HeaderSize {
+		return Header{}, io.ErrUnexpectedEOF
+	}
+	if b[0] != 'F' || b[1] != 'L' || b[2] != 'V' {
+		return Header{}, ErrInvalidSignature
+	}
+	if offset := binary.BigEndian.Uint32(b[5:9]); offset < HeaderSize {
+		return Header{}, fmt.Errorf("flv: invalid header size %d", offset)
+	}
+	return Header{
+		Version:  b[3],
+		HasAudio: b[4]&flagAudio != 0,
+		HasVideo: b[4]&flagVideo != 0,
+	}, nil
+}
+
+// Append appends the encoded header to dst (version 1 if Version is 0)
+func (h Header) Append(dst []byte) []byte {
+	version := h.Version
+	if version == 0 {
+		version = 1
+	}
+	var flags byte
+	if h.HasAudio {
+		flags |= flagAudio
+	}
+	if h.HasVideo {
+		flags |= flagVideo
+	}
+	dst = append(dst, 'F', 'L', 'V', version, flags)
+	return binary.BigEndian.AppendUint32(dst, HeaderSize)
+}
+
+// TagHeader is the header in front of every tag's data
+type TagHeader struct {
+	Type      uint8
+	DataSize  uint32
+	Timestamp uint32 // milliseconds, 32 bits (24 bits plus the extended byte)
+	StreamID  uint32 // always 0 in files
+}
+
+// ParseTagHeader parses a tag header from the first TagHeaderSize bytes of b
+func ParseTagHeader(b []byte) (TagHeader, error) {
+	if len(b) < TagHeaderSize {
+		return TagHeader{}, io.ErrUnexpectedEOF
+	}
+	return TagHeader{
+		Type:      b[0] & 0x1F, // upper bits are reserved / the filter flag
+		DataSize:  uint24(b[1:4]),
+		Timestamp: uint24(b[4:7]) | uint32(b[7])<<24,
+		StreamID:  uint24(b[8:11]),
+	}, nil
+}
+
+// Append appends the encoded tag header to dst
+func (h TagHeader) Append(dst []byte) []byte {
+	dst = append(dst, h.Type)
+	dst = appendUint24(dst, h.DataSize)
+	dst = appendUint24(dst, h.Timestamp&0xFFFFFF)
+	dst = append(dst, byte(h.Timestamp>>24))
+	return appendUint24(dst, h.StreamID)
+}
+
+// Tag is one parsed tag
+type Tag struct {
+	TagHeader
+	Data []byte
+}
+
+// Writer writes an FLV file: the header, then tags each followed by its size
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a Writer on w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteHeader writes the file header and the zero PreviousTagSize that precedes the first tag.
+// Files that are appended to already have a header and must not get a second one.
+func (w *Writer) WriteHeader(h Header) error {
+	buf := h.Append(make([]byte, 0, HeaderSize+PreviousTagSizeSize))
+	buf = binary.BigEndian.AppendUint32(buf, 0)
+	if _, err := w.w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write FLV header: %w", err)
+	}
+	return nil
+}
+
+// WriteTag writes one tag whose data is the concatenation of data (RTMP
+// payload chunks are written without copying them together first), followed
+// by its PreviousTagSize
+func (w *Writer) WriteTag(tagType uint8, timestamp uint32, data [][]byte) error {
+	dataSize := 0
+	for _, chunk := range data {
+		dataSize += len(chunk)
+	}
+	if dataSize > MaxDataSize {
+		return fmt.Errorf("%w: %d bytes", ErrDataTooLarge, dataSize)
+	}
+
+	tag := make([]byte, 0, TagHeaderSize+dataSize+PreviousTagSizeSize)
+	tag = TagHeader{Type: tagType, DataSize: uint32(dataSize), Timestamp: timestamp}.Append(tag)
+	for _, chunk := range data {
+		tag = append(tag, chunk...)
+	}
+	tag = binary.BigEndian.AppendUint32(tag, uint32(TagHeaderSize+dataSize))
+
+	if _, err := w.w.Write(tag); err != nil {
+		return fmt.Errorf("failed to write FLV tag: %w", err)
+	}
+	return nil
+}
+
+// Reader reads an FLV file written by Writer or any other muxer
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader creates a Reader on r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadHeader reads the file header, skips any extra header bytes and the
+// PreviousTagSize before the first tag
+func (r *Reader) ReadHeader() (Header, error) {
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return Header{}, err
+	}
+	h, err := ParseHeader(buf)
+	if err != nil {
+		return Header{}, err
+	}
+
+	skip := int64(binary.BigEndian.Uint32(buf[5:9])) - HeaderSize + PreviousTagSizeSize
+	if _, err := io.CopyN(io.Discard, r.r, skip); err != nil {
+		return Header{}, fmt.Errorf("failed to read FLV header: %w", err)
+	}
+	return h, nil
+}
+
+// ReadTag reads the next tag and checks the PreviousTagSize that follows it.
+// It returns io.EOF when there are no more tags.
+func (r *Reader) ReadTag() (Tag, error) {
+	buf := make([]byte, TagHeaderSize)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return Tag{}, err
+	}
+	h, err := ParseTagHeader(buf)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	data := make([]byte, int(h.DataSize)+PreviousTagSizeSize)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Tag{}, fmt.Errorf("failed to read FLV tag data: %w", noEOF(err))
+	}
+
+	previousTagSize := binary.BigEndian.Uint32(data[h.DataSize:])
+	if previousTagSize != TagHeaderSize+h.DataSize {
+		return Tag{}, fmt.Errorf("%w: %d after a %d byte tag", ErrPreviousTagSizeMismatch, previousTagSize, TagHeaderSize+h.DataSize)
+	}
+	return Tag{TagHeader: h, Data: data[:h.DataSize]}, nil
+}
+
+// noEOF turns io.EOF inside a tag into io.ErrUnexpectedEOF (a truncated file)
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func appendUint24(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>16), byte(v>>8), byte(v))
+}
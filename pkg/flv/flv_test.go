@@ -0,0 +1,166 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	headers := []Header{
+		{Version: 1, HasAudio: true, HasVideo: true},
+		{Version: 1, HasAudio: true},
+		{Version: 1, HasVideo: true},
+	}
+
+	for _, expected := range headers {
+		encoded := expected.Append(nil)
+		if len(encoded) != HeaderSize {
+			t.Fatalf("Expected %d header bytes, got %d", HeaderSize, len(encoded))
+		}
+		parsed, err := ParseHeader(encoded)
+		if err != nil {
+			t.Fatalf("Failed to parse header: %v", err)
+		}
+		if parsed != expected {
+			t.Errorf("Expected %+v, got %+v", expected, parsed)
+		}
+	}
+
+	// Audio and video flags are the same bytes every FLV muxer writes
+	expected := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9}
+	if encoded := (Header{HasAudio: true, HasVideo: true}).Append(nil); !bytes.Equal(encoded, expected) {
+		t.Errorf("Expected %x, got %x", expected, encoded)
+	}
+}
+
+func TestParseHeaderRejectsInvalidSignature(t *testing.T) {
+	if _, err := ParseHeader([]byte{'F', 'L', 'X', 1, 5, 0, 0, 0, 9}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected ErrInvalidSignature, got %v", err)
+	}
+	if _, err := ParseHeader([]byte{'F', 'L', 'V'}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected io.ErrUnexpectedEOF for a short header, got %v", err)
+	}
+}
+
+func TestTagHeaderRoundTrip(t *testing.T) {
+	expected := TagHeader{Type: TagTypeVideo, DataSize: 0x123456, Timestamp: 0x89ABCDEF, StreamID: 0}
+
+	encoded := expected.Append(nil)
+	if len(encoded) != TagHeaderSize {
+		t.Fatalf("Expected %d tag header bytes, got %d", TagHeaderSize, len(encoded))
+	}
+	// The upper timestamp byte follows the lower 24 bits
+	if !bytes.Equal(encoded[4:8], []byte{0xAB, 0xCD, 0xEF, 0x89}) {
+		t.Errorf("Expected extended timestamp layout, got %x", encoded[4:8])
+	}
+
+	parsed, err := ParseTagHeader(encoded)
+	if err != nil {
+		t.Fatalf("Failed to parse tag header: %v", err)
+	}
+	if parsed != expected {
+		t.Errorf("Expected %+v, got %+v", expected, parsed)
+	}
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	tags := []struct {
+		tagType   uint8
+		timestamp uint32
+		data      [][]byte
+	}{
+		{TagTypeScript, 0, [][]byte{{0x02, 0x00, 0x0A}, []byte("onMetaData")}},
+		{TagTypeVideo, 0, [][]byte{{0x17, 0x00, 0x00, 0x00, 0x00}, {0x01, 0x64, 0x00, 0x1F}}},
+		{TagTypeAudio, 23, [][]byte{{0xAF, 0x01, 0x21, 0x10}}},
+		{TagTypeVideo, 0x01000040, [][]byte{{0x27, 0x01}, {}, {0x00, 0x00, 0x00}}},
+		{TagTypeAudio, 46, nil},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	if err := writer.WriteHeader(Header{HasAudio: true, HasVideo: true}); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+	for _, tag := range tags {
+		if err := writer.WriteTag(tag.tagType, tag.timestamp, tag.data); err != nil {
+			t.Fatalf("Failed to write tag: %v", err)
+		}
+	}
+
+	reader := NewReader(&buf)
+	header, err := reader.ReadHeader()
+	if err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	if !header.HasAudio || !header.HasVideo || header.Version != 1 {
+		t.Fatalf("Expected version 1 audio+video header, got %+v", header)
+	}
+
+	for i, expected := range tags {
+		tag, err := reader.ReadTag()
+		if err != nil {
+			t.Fatalf("Failed to read tag %d: %v", i, err)
+		}
+		data := bytes.Join(expected.data, nil)
+		if tag.Type != expected.tagType || tag.Timestamp != expected.timestamp || tag.StreamID != 0 {
+			t.Errorf("Tag %d: expected type %d at %d, got %+v", i, expected.tagType, expected.timestamp, tag.TagHeader)
+		}
+		if tag.DataSize != uint32(len(data)) || !bytes.Equal(tag.Data, data) {
+			t.Errorf("Tag %d: expected data %x, got %x", i, data, tag.Data)
+		}
+	}
+
+	if _, err := reader.ReadTag(); err != io.EOF {
+		t.Fatalf("Expected io.EOF after the last tag, got %v", err)
+	}
+}
+
+func TestReadTagDetectsCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).WriteTag(TagTypeAudio, 0, [][]byte{{0xAF, 0x01}}); err != nil {
+		t.Fatalf("Failed to write tag: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	corrupted := bytes.Clone(encoded)
+	corrupted[len(corrupted)-1]++
+	if _, err := NewReader(bytes.NewReader(corrupted)).ReadTag(); !errors.Is(err, ErrPreviousTagSizeMismatch) {
+		t.Fatalf("Expected ErrPreviousTagSizeMismatch, got %v", err)
+	}
+
+	truncated := encoded[:TagHeaderSize+1]
+	if _, err := NewReader(bytes.NewReader(truncated)).ReadTag(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected io.ErrUnexpectedEOF for a truncated tag, got %v", err)
+	}
+}
+
+func TestWriteTagRejectsOversizedData(t *testing.T) {
+	data := [][]byte{make([]byte, MaxDataSize), {0}}
+	if err := NewWriter(io.Discard).WriteTag(TagTypeVideo, 0, data); !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("Expected ErrDataTooLarge, got %v", err)
+	}
+}
+
+func TestReadHeaderSkipsExtraHeaderBytes(t *testing.T) {
+	// A header that declares 12 bytes, then PreviousTagSize0 and one tag
+	data := []byte{'F', 'L', 'V', 1, 0x04, 0, 0, 0, 12, 0xEE, 0xEE, 0xEE, 0, 0, 0, 0}
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).WriteTag(TagTypeAudio, 10, [][]byte{{0xAF, 0x01}}); err != nil {
+		t.Fatalf("Failed to write tag: %v", err)
+	}
+	data = append(data, buf.Bytes()...)
+
+	reader := NewReader(bytes.NewReader(data))
+	if _, err := reader.ReadHeader(); err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	tag, err := reader.ReadTag()
+	if err != nil {
+		t.Fatalf("Failed to read tag: %v", err)
+	}
+	if tag.Type != TagTypeAudio || tag.Timestamp != 10 {
+		t.Fatalf("Expected audio tag at 10, got %+v", tag.TagHeader)
+	}
+}
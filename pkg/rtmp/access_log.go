@@ -0,0 +1,114 @@
+package rtmp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogFormat은 접근 로그 출력 형식
+type AccessLogFormat string
+
+const (
+	AccessLogText AccessLogFormat = "text" // key=value 한 줄
+	AccessLogJSON AccessLogFormat = "json" // JSON 객체 한 줄
+)
+
+// 세션 종료 사유 코드 (접근 로그의 reason)
+const (
+	CloseReasonClientClosed     = "client_closed"      // 클라이언트가 연결을 끊음
+	CloseReasonServerClosed     = "server_closed"      // 서버가 연결을 닫음 (종료, 발행자 교체 등)
+	CloseReasonHandshakeFailed  = "handshake_failed"   // RTMP 핸드셰이크 실패
+	CloseReasonReadError        = "read_error"         // 수신 오류
+	CloseReasonMessageTooLarge  = "message_too_large"  // 최대 메시지 크기 초과
+	CloseReasonProtocolError    = "protocol_error"     // 청크 스트림이 RTMP 명세를 어김 (잘못된 chunk stream ID 등)
+	CloseReasonInvalidChunkSize = "invalid_chunk_size" // 허용되지 않는 Set Chunk Size
+	CloseReasonPolicyServed     = "policy_served"      // Flash 소켓 정책 파일 요청에 응답 후 종료
+	CloseReasonCommandFlood     = "command_flood"      // 초당 최대 명령어 수 초과
+	CloseReasonConnectRejected  = "connect_rejected"   // ConnectAuthenticator가 connect를 거부
+)
+
+// AccessRecord는 세션 하나의 접근 로그 항목 (세션 종료 시 한 번 기록)
+type AccessRecord struct {
+	SessionId   string
+	RemoteAddr  string
+	ConnectedAt time.Time
+	App         string
+	Stream      string
+	Role        string // publisher, player (역할을 알 수 없으면 빈 값)
+	FlashVer    string // connect 명령의 클라이언트 정보 (보내지 않았으면 빈 값)
+	TcUrl       string
+	SwfUrl      string
+	PageUrl     string
+	BytesIn     uint64
+	BytesOut    uint64
+	Duration    time.Duration
+	Reason      string // CloseReason* 코드
+}
+
+// AccessLogger는 세션 종료 시 접근 로그를 기록
+type AccessLogger interface {
+	LogAccess(record AccessRecord)
+}
+
+// NewAccessLogger는 w에 format 형식으로 접근 로그를 남기는 AccessLogger를 생성
+func NewAccessLogger(w io.Writer, format AccessLogFormat) (AccessLogger, error) {
+	var handler slog.Handler
+	switch format {
+	case AccessLogText:
+		handler = slog.NewTextHandler(w, nil)
+	case AccessLogJSON:
+		handler = slog.NewJSONHandler(w, nil)
+	default:
+		return nil, fmt.Errorf("unsupported access log format: %s", format)
+	}
+	return &slogAccessLogger{logger: slog.New(handler)}, nil
+}
+
+// slogAccessLogger는 slog 핸들러로 접근 로그를 출력 (일반 로그와 별도 출력 대상 사용)
+type slogAccessLogger struct {
+	logger *slog.Logger
+}
+
+// LogAccess는 접근 로그 한 줄을 기록
+func (l *slogAccessLogger) LogAccess(record AccessRecord) {
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "rtmp access",
+		slog.String("sessionId", record.SessionId),
+		slog.String("remoteAddr", record.RemoteAddr),
+		slog.Time("connectedAt", record.ConnectedAt),
+		slog.String("app", record.App),
+		slog.String("stream", record.Stream),
+		slog.String("role", record.Role),
+		slog.String("flashVer", record.FlashVer),
+		slog.String("tcUrl", record.TcUrl),
+		slog.String("swfUrl", record.SwfUrl),
+		slog.String("pageUrl", record.PageUrl),
+		slog.Uint64("bytesIn", record.BytesIn),
+		slog.Uint64("bytesOut", record.BytesOut),
+		slog.Int64("durationMs", record.Duration.Milliseconds()),
+		slog.String("reason", record.Reason),
+	)
+}
+
+// countingConn은 세션의 송수신 바이트 수를 센다 (접근 로그용)
+type countingConn struct {
+	net.Conn
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.bytesIn.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.bytesOut.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,134 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sol/pkg/amf"
+	"testing"
+	"time"
+)
+
+// 접근 로그 레코드를 한 줄씩 전달하는 writer (세션 goroutine에서 기록됨)
+type accessLogLines chan []byte
+
+func (w accessLogLines) Write(p []byte) (int, error) {
+	w <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+func TestAccessLogOnSessionTeardown(t *testing.T) {
+	lines := make(accessLogLines, 1)
+	logger, err := NewAccessLogger(lines, AccessLogJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(0, StreamConfig{AccessLogger: logger}, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	defer server.cancel()
+	go server.acceptConnections(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// C0 + C1 + C2 전송 후 S0 + S1 + S2 수신
+	handshakeData := append([]byte{RTMP_VERSION}, make([]byte, HANDSHAKE_SIZE*2)...)
+	if _, err := conn.Write(handshakeData); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 1+HANDSHAKE_SIZE*2)); err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+
+	// connect 후 publish
+	writer := newMessageWriter()
+	commands := [][]any{
+		{"connect", 1.0, map[string]any{"app": "live", "flashVer": "FMLE/3.0 (compatible; FMSc/1.0)", "tcUrl": "rtmp://localhost/live"}},
+		{"publish", 2.0, nil, "test", "live"},
+	}
+	for _, command := range commands {
+		sequence, err := amf.EncodeAMF0Sequence(command...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.writeCommand(conn, sequence); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 발행 시작 응답을 받은 뒤 클라이언트가 연결 종료
+	reader := newMessageReader()
+	for started := false; !started; {
+		msg, err := reader.readNextMessage(conn)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		switch msg.messageHeader.typeId {
+		case MSG_TYPE_SET_CHUNK_SIZE:
+			reader.setChunkSize(binary.BigEndian.Uint32(concatChunks(msg.payload)))
+		case MSG_TYPE_AMF0_COMMAND:
+			started = bytes.Contains(concatChunks(msg.payload), []byte("NetStream.Publish.Start"))
+		}
+	}
+	conn.Close()
+
+	var line []byte
+	select {
+	case line = <-lines:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an access record on session teardown")
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(line, &record); err != nil {
+		t.Fatalf("expected a JSON access record, got %q: %v", line, err)
+	}
+	expected := map[string]any{
+		"msg":      "rtmp access",
+		"app":      "live",
+		"stream":   "test",
+		"role":     "publisher",
+		"flashVer": "FMLE/3.0 (compatible; FMSc/1.0)",
+		"tcUrl":    "rtmp://localhost/live",
+		"swfUrl":   "",
+		"reason":   CloseReasonClientClosed,
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+	for _, key := range []string{"bytesIn", "bytesOut"} {
+		if n, _ := record[key].(float64); n <= 0 {
+			t.Errorf("expected positive %s, got %v", key, record[key])
+		}
+	}
+	for _, key := range []string{"sessionId", "remoteAddr", "connectedAt", "durationMs"} {
+		if _, ok := record[key]; !ok {
+			t.Errorf("expected %s in access record", key)
+		}
+	}
+
+	// 세션당 한 번만 기록
+	select {
+	case extra := <-lines:
+		t.Fatalf("expected a single access record, got another: %q", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNewAccessLoggerRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewAccessLogger(io.Discard, "xml"); err == nil {
+		t.Fatal("expected error for unknown access log format")
+	}
+}
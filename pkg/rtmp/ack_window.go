@@ -0,0 +1,77 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrAckWindowStalled는 클라이언트가 Acknowledgement를 보내지 않아 미확인 바이트가 한도를 넘었을 때 반환
+var ErrAckWindowStalled = errors.New("peer stopped acknowledging, unacknowledged bytes exceed the limit")
+
+// ackWindowConn은 보낸 바이트 수와 클라이언트가 Acknowledgement로 알린 수신 바이트 수를 비교해
+// 읽지 않는 클라이언트를 찾아낸다 (미확인 바이트가 한도를 넘으면 더 쓰지 않고 연결 종료)
+// 쓰기(이벤트 루프, 송신 큐)와 ack 처리(세션 goroutine)가 동시에 일어나므로 원자 연산으로 관리
+type ackWindowConn struct {
+	net.Conn
+	sent  atomic.Uint32 // 보낸 바이트 수 (ack 시퀀스 번호처럼 32비트로 순환)
+	acked atomic.Uint32 // 클라이언트가 마지막으로 알린 수신 바이트 수
+	limit atomic.Uint64 // 허용하는 미확인 바이트 (0이면 윈도우를 알리기 전이라 검사하지 않음)
+
+	stallOnce sync.Once
+}
+
+// start는 클라이언트에게 윈도우를 알린 뒤 미확인 바이트 검사를 시작 (한도 = window * factor)
+func (c *ackWindowConn) start(window uint32, factor int) {
+	if factor <= 0 {
+		factor = DEFAULT_ACK_STALL_FACTOR
+	}
+	c.limit.Store(uint64(window) * uint64(factor))
+}
+
+// acknowledge는 클라이언트가 보낸 Acknowledgement의 시퀀스 번호(지금까지 받은 바이트 수)를 기록
+func (c *ackWindowConn) acknowledge(sequence uint32) {
+	c.acked.Store(sequence)
+}
+
+// outstanding은 보냈지만 클라이언트가 아직 받았다고 알리지 않은 바이트 수
+func (c *ackWindowConn) outstanding() uint32 {
+	diff := c.sent.Load() - c.acked.Load()
+	// 실제 보낸 것보다 많이 받았다고 하면 (핸드셰이크를 세는 방식 차이 등) 밀린 것이 없다고 본다
+	if diff > 1<<31 {
+		return 0
+	}
+	return diff
+}
+
+func (c *ackWindowConn) Write(p []byte) (int, error) {
+	if limit := c.limit.Load(); limit > 0 && uint64(c.outstanding()) > limit {
+		c.stallOnce.Do(func() {
+			slog.Warn("Closing connection that stopped acknowledging", "addr", c.RemoteAddr(), "unackedBytes", c.outstanding(), "limit", limit)
+			closeWithLog(c.Conn)
+		})
+		return 0, ErrAckWindowStalled
+	}
+	n, err := c.Conn.Write(p)
+	c.sent.Add(uint32(n))
+	return n, err
+}
+
+// handleAcknowledgement는 클라이언트의 Acknowledgement 메시지를 처리
+func (s *session) handleAcknowledgement(message *Message) {
+	if s.ackWindow == nil {
+		return
+	}
+	payload := make([]byte, 0, 4)
+	for _, chunk := range message.payload {
+		payload = append(payload, chunk...)
+	}
+	if len(payload) != 4 {
+		slog.Warn("Invalid Acknowledgement message length", "sessionId", s.sessionId, "length", len(payload))
+		return
+	}
+	s.ackWindow.acknowledge(binary.BigEndian.Uint32(payload))
+}
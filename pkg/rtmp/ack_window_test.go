@@ -0,0 +1,85 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// 클라이언트가 sequence 바이트를 받았다고 알리는 Acknowledgement 메시지
+func newTestAcknowledgement(sequence uint32) *Message {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, sequence)
+	return NewMessage(newMessageHeader(0, 4, MSG_TYPE_ACKNOWLEDGEMENT, 0), [][]byte{payload})
+}
+
+// 캡처된 출력의 첫 메시지 (connect 이후 메시지는 바뀐 청크 크기로 쓰여 기본 reader로 이어 읽을 수 없음)
+func readFirstMessage(t *testing.T, conn *bufferConn) *Message {
+	t.Helper()
+	msg, err := newMessageReader().readNextMessage(&conn.buf)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestStalledPlayerDisconnectedWhenAcksStop(t *testing.T) {
+	const window = 1000
+
+	player, conn := newTestPlayer(1)
+	player.ackWindow = &ackWindowConn{Conn: conn}
+	player.ackWindowSize = window
+	player.ackStallFactor = 2
+	player.conn = player.ackWindow
+
+	// connect 응답 전에 Window Acknowledgement Size를 알림
+	player.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live"}))
+	first := readFirstMessage(t, conn)
+	if first.messageHeader.typeId != MSG_TYPE_WINDOW_ACK_SIZE {
+		t.Fatalf("expected Window Acknowledgement Size first, got type %d", first.messageHeader.typeId)
+	}
+	if size := binary.BigEndian.Uint32(first.payload[0]); size != window {
+		t.Fatalf("expected window of %d, got %d", window, size)
+	}
+
+	// ack를 보내는 동안에는 윈도우보다 훨씬 많이 보내도 계속 전송
+	frame := [][]byte{make([]byte, 500)}
+	for i := 0; i < 20; i++ {
+		if err := player.writer.writeVideoData(player.conn, frame, uint32(i*40), player.streamID); err != nil {
+			t.Fatalf("expected acknowledged writes to succeed, frame %d: %v", i, err)
+		}
+		player.handleMessage(newTestAcknowledgement(player.ackWindow.sent.Load()))
+	}
+
+	// ack가 멈추면 미확인 바이트가 window * 2를 넘는 순간 더 보내지 않고 연결 종료
+	var err error
+	written := 0
+	for i := 0; i < 20 && err == nil; i++ {
+		before := player.ackWindow.outstanding()
+		if err = player.writer.writeVideoData(player.conn, frame, uint32(800+i*40), player.streamID); err == nil {
+			written++
+		} else if before <= 2*window {
+			t.Fatalf("expected writes to continue with %d unacknowledged bytes", before)
+		}
+	}
+	if !errors.Is(err, ErrAckWindowStalled) {
+		t.Fatalf("expected %v, got %v", ErrAckWindowStalled, err)
+	}
+	if written < 3 {
+		t.Fatalf("expected a few frames before the stall was detected, got %d", written)
+	}
+	if !conn.closed {
+		t.Fatal("expected stalled connection to be closed")
+	}
+}
+
+func TestAckWindowDisabledByDefault(t *testing.T) {
+	player, conn := newTestPlayer(1)
+	player.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live"}))
+	if first := readFirstMessage(t, conn); first.messageHeader.typeId != MSG_TYPE_SET_CHUNK_SIZE {
+		t.Fatalf("expected connect to start with Set Chunk Size without AckWindowSize, got type %d", first.messageHeader.typeId)
+	}
+
+	// ack가 없는 세션의 Acknowledgement는 무시
+	player.handleMessage(newTestAcknowledgement(1234))
+}
@@ -0,0 +1,119 @@
+package rtmp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// A/V 동기 보정 설정
+const (
+	defaultAVSyncTolerance = 40 * time.Millisecond // 보정하지 않고 허용하는 드리프트 (0이면 이 값)
+	avSyncWindow           = 1000                  // 드리프트 측정 구간 (비디오 타임스탬프 ms)
+	avSyncMaxStep          = 1                     // 비디오 프레임당 최대 보정량 (ms, 재생이 튀지 않도록 조금씩 이동)
+)
+
+// avSyncCorrector는 중계하는 스트림의 오디오와 비디오 타임스탬프가 서로 멀어지는 드리프트를 보정
+// 오디오를 기준 시계로 삼고 비디오 타임스탬프만 오디오 쪽으로 조금씩 옮긴다
+//
+// 비디오 프레임마다 직전 오디오 프레임과의 타임스탬프 차이를 재고, 측정 구간의 최소값을 오프셋으로 삼는다
+// (인터리빙으로 생기는 프레임 길이만큼의 흔들림은 최소값에서 사라짐)
+// 첫 구간의 오프셋이 기준이며, 이후 구간 오프셋이 기준에서 tolerance 넘게 벗어나면 그만큼 되돌린다
+type avSyncCorrector struct {
+	streamName string
+	tolerance  int64 // ms
+
+	lastAudio int64
+	hasAudio  bool
+
+	// 측정 구간 (원본 비디오 타임스탬프 기준)
+	windowStart   int64
+	windowMin     int64
+	windowSamples int
+	baseline      int64
+	hasBaseline   bool
+
+	correction   int64 // 비디오 타임스탬프에 더하는 현재 보정값 (ms)
+	target       int64 // correction이 따라갈 목표 보정값
+	lastRawVideo int64 // 마지막 원본 비디오 타임스탬프 (타임스탬프 리셋 감지용)
+	lastVideo    int64 // 마지막으로 내보낸 비디오 타임스탬프 (단조 증가 유지)
+	hasVideo     bool
+}
+
+func newAVSyncCorrector(streamName string, tolerance time.Duration) *avSyncCorrector {
+	if tolerance <= 0 {
+		tolerance = defaultAVSyncTolerance
+	}
+	return &avSyncCorrector{streamName: streamName, tolerance: tolerance.Milliseconds()}
+}
+
+// reset은 측정과 보정을 처음부터 다시 시작 (발행자 교체, 타임스탬프 리셋 시)
+func (c *avSyncCorrector) reset() {
+	*c = avSyncCorrector{streamName: c.streamName, tolerance: c.tolerance}
+}
+
+// observeAudio는 오디오 프레임 타임스탬프를 기록 (오디오 타임스탬프는 바꾸지 않음)
+func (c *avSyncCorrector) observeAudio(timestamp uint32) {
+	ts := int64(timestamp)
+	if c.hasAudio && ts+avSyncWindow < c.lastAudio {
+		c.reset()
+	}
+	c.lastAudio = ts
+	c.hasAudio = true
+}
+
+// correctVideo는 드리프트를 측정하고 보정한 비디오 타임스탬프를 반환
+func (c *avSyncCorrector) correctVideo(timestamp uint32) uint32 {
+	ts := int64(timestamp)
+	if c.hasVideo && ts+avSyncWindow < c.lastRawVideo {
+		c.reset()
+	}
+	c.lastRawVideo = ts
+
+	if c.hasAudio {
+		c.measure(ts)
+	}
+
+	switch {
+	case c.correction < c.target:
+		c.correction = min(c.correction+avSyncMaxStep, c.target)
+	case c.correction > c.target:
+		c.correction = max(c.correction-avSyncMaxStep, c.target)
+	}
+
+	corrected := ts + c.correction
+	if c.hasVideo {
+		corrected = max(corrected, c.lastVideo)
+	}
+	corrected = max(corrected, 0)
+	c.lastVideo = corrected
+	c.hasVideo = true
+	return uint32(corrected)
+}
+
+// measure는 원본 비디오 타임스탬프와 직전 오디오의 차이를 측정 구간에 넣고, 구간이 끝나면 보정 목표를 갱신
+func (c *avSyncCorrector) measure(ts int64) {
+	offset := ts - c.lastAudio
+	if c.windowSamples == 0 {
+		c.windowStart = ts
+		c.windowMin = offset
+	}
+	c.windowMin = min(c.windowMin, offset)
+	c.windowSamples++
+	if ts-c.windowStart < avSyncWindow {
+		return
+	}
+	c.windowSamples = 0
+
+	if !c.hasBaseline {
+		c.baseline = c.windowMin
+		c.hasBaseline = true
+		return
+	}
+
+	// 보정 후에도 남는 드리프트가 허용 범위를 넘으면 목표를 다시 잡음
+	drift := c.windowMin - c.baseline
+	if residual := drift + c.target; residual > c.tolerance || residual < -c.tolerance {
+		c.target = -drift
+		slog.Info("A/V drift correction", "streamName", c.streamName, "driftMs", drift, "correctionMs", c.target)
+	}
+}
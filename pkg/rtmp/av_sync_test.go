@@ -0,0 +1,122 @@
+package rtmp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// runAVSync는 오디오(AAC 44.1kHz, 1024 샘플)와 비디오(29.97fps) 프레임을 도착 순서대로 보정기에 넣는다
+// 비디오 타임스탬프는 videoRate배로 흘러 오디오와 점점 멀어진다 (1이면 드리프트 없음)
+// 비디오 프레임마다 (원본, 보정) 타임스탬프에서 직전 오디오 타임스탬프를 뺀 오프셋을 반환
+func runAVSync(c *avSyncCorrector, duration time.Duration, videoRate float64) (raw, corrected []int64) {
+	const audioFrame = 1024.0 / 44.1 // ms
+	const videoFrame = 1001.0 / 30.0 // ms
+
+	end := float64(duration.Milliseconds())
+	var lastAudio int64
+	audio, video := 0, 0
+	for {
+		audioAt, videoAt := float64(audio)*audioFrame, float64(video)*videoFrame
+		if audioAt > end && videoAt > end {
+			return raw, corrected
+		}
+		if audioAt <= videoAt {
+			lastAudio = int64(math.Round(audioAt))
+			c.observeAudio(uint32(lastAudio))
+			audio++
+			continue
+		}
+		ts := int64(math.Round(videoAt * videoRate))
+		raw = append(raw, ts-lastAudio)
+		corrected = append(corrected, int64(c.correctVideo(uint32(ts)))-lastAudio)
+		video++
+	}
+}
+
+// windowMinOffset는 비디오 프레임 오프셋 중 [from, to) 구간(프레임 순번)의 최소값
+func windowMinOffset(offsets []int64, from, to int) int64 {
+	result := offsets[from]
+	for _, offset := range offsets[from:to] {
+		result = min(result, offset)
+	}
+	return result
+}
+
+func TestAVSyncCorrectsDrift(t *testing.T) {
+	c := newAVSyncCorrector("live/test", 40*time.Millisecond)
+
+	// 비디오가 0.2% 빠르게 흐르면 2분 뒤 약 240ms 어긋남
+	raw, corrected := runAVSync(c, 2*time.Minute, 1.002)
+
+	const framesPerSecond = 30
+	baseline := windowMinOffset(raw, 0, framesPerSecond)
+	last := len(raw) - framesPerSecond
+	if drift := windowMinOffset(raw, last, len(raw)) - baseline; drift < 200 {
+		t.Fatalf("expected the injected drift to exceed 200ms, got %dms", drift)
+	}
+
+	// 매 초 보정 후 오프셋은 허용 범위 안에 머물러야 함 (측정 구간 + 프레임당 보정 속도만큼의 지연 허용)
+	const slack = 10
+	for from := framesPerSecond; from+framesPerSecond <= len(corrected); from += framesPerSecond {
+		offset := windowMinOffset(corrected, from, from+framesPerSecond) - baseline
+		if offset > 40+slack || offset < -40-slack {
+			t.Fatalf("expected corrected offset within tolerance at %ds, got %dms", from/framesPerSecond, offset)
+		}
+	}
+}
+
+func TestAVSyncLeavesSteadyStreamUntouched(t *testing.T) {
+	c := newAVSyncCorrector("live/test", 0)
+
+	raw, corrected := runAVSync(c, time.Minute, 1)
+	for i := range raw {
+		if raw[i] != corrected[i] {
+			t.Fatalf("expected frame %d to be untouched, got offset %d instead of %d", i, corrected[i], raw[i])
+		}
+	}
+}
+
+func TestAVSyncKeepsVideoTimestampsMonotonic(t *testing.T) {
+	c := newAVSyncCorrector("live/test", 40*time.Millisecond)
+	c.observeAudio(1000)
+	c.target = -20 // 비디오를 되돌리는 보정 중
+
+	var last uint32
+	for ts := uint32(1000); ts < 1010; ts++ {
+		corrected := c.correctVideo(ts)
+		if corrected < last {
+			t.Fatalf("expected monotonic video timestamps, got %d after %d", corrected, last)
+		}
+		last = corrected
+	}
+
+	// 발행자가 타임스탬프를 0부터 다시 시작하면 보정도 처음부터
+	if corrected := c.correctVideo(0); corrected != 0 || c.correction != 0 || c.target != 0 {
+		t.Fatalf("expected correction reset on timestamp restart, got %d (correction %d)", corrected, c.correction)
+	}
+}
+
+func TestStreamAppliesAVSyncCorrection(t *testing.T) {
+	stream := NewStream("test", 10, 0)
+	stream.SetAVSyncCorrection(true, 0)
+	stream.avSync.target = 5
+	stream.avSync.correction = 5
+
+	stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 100, FrameType: "key frame", Data: testAVCKeyFrame})
+
+	// sequence header는 그대로, 미디어 프레임은 보정된 타임스탬프로 캐시
+	if stream.videoCache.sequenceHeader.timestamp != 0 {
+		t.Fatalf("expected sequence header timestamp 0, got %d", stream.videoCache.sequenceHeader.timestamp)
+	}
+	if frames := stream.videoCache.gopFrames; len(frames) != 1 || frames[0].timestamp != 105 {
+		t.Fatalf("expected key frame cached at 105, got %+v", frames)
+	}
+
+	stream.SetAVSyncCorrection(false, 0)
+	if stream.avSync != nil {
+		t.Fatal("expected correction to be disabled")
+	}
+}
@@ -0,0 +1,70 @@
+package rtmp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// 발행자 수신 비트레이트를 측정하는 구간
+const inboundBitrateWindow = 5 * time.Second
+
+// 최대 비트레이트를 넘은 발행자에게 보내는 onStatus
+const (
+	bitrateExceededStatusCode  = "NetStream.Publish.Rejected"
+	bitrateExceededDescription = "Maximum publish bitrate exceeded"
+)
+
+// bitrateSample은 수신 시각별 미디어 크기
+type bitrateSample struct {
+	at   time.Time
+	size int
+}
+
+// bitrateMeter는 최근 inboundBitrateWindow 구간의 수신 바이트로 비트레이트를 계산 (이벤트 루프에서만 사용)
+type bitrateMeter struct {
+	samples []bitrateSample
+	bytes   int
+}
+
+// add는 수신한 미디어 크기를 기록하고 구간 평균 비트레이트(bps)를 반환
+// 구간이 다 차지 않아도 구간 길이로 나누므로 시작 직후의 키프레임 버스트로 초과 판정되지 않음
+func (m *bitrateMeter) add(size int, now time.Time) int64 {
+	m.samples = append(m.samples, bitrateSample{at: now, size: size})
+	m.bytes += size
+
+	expired := 0
+	for expired < len(m.samples) && now.Sub(m.samples[expired].at) > inboundBitrateWindow {
+		m.bytes -= m.samples[expired].size
+		expired++
+	}
+	m.samples = m.samples[expired:]
+
+	return int64(m.bytes) * 8 * int64(time.Second) / int64(inboundBitrateWindow)
+}
+
+// enforceMaxPublishBitrate는 현재 발행자가 보낸 미디어로 스트림 수신 비트레이트를 갱신하고,
+// 최대 비트레이트를 넘으면 발행자에게 알린 뒤 연결을 끊는다 (끊었으면 true, 해당 미디어는 전달하지 않음)
+func (s *Server) enforceMaxPublishBitrate(stream *Stream, sessionId string, size int, now time.Time) bool {
+	if s.streamConfig.MaxPublishBitrate <= 0 {
+		return false
+	}
+
+	// 이미 교체된 발행자의 늦은 미디어는 측정하지 않음
+	publisher := stream.GetPublisher()
+	if publisher == nil || publisher.sessionId != sessionId {
+		return false
+	}
+
+	bitrate := stream.inboundBitrate.add(size, now)
+	if bitrate <= s.streamConfig.MaxPublishBitrate {
+		return false
+	}
+
+	slog.Warn("Publisher exceeded max bitrate", "streamName", stream.name, "sessionId", sessionId, "bitrate", bitrate, "maxBitrate", s.streamConfig.MaxPublishBitrate)
+	if err := publisher.sendStatus("error", bitrateExceededStatusCode, bitrateExceededDescription, stream.name); err != nil {
+		slog.Error("Failed to send bitrate rejection", "sessionId", sessionId, "err", err)
+	}
+	closeWithLog(publisher.conn)
+	s.detachPublisher(stream, stream.name)
+	return true
+}
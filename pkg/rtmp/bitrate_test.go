@@ -0,0 +1,53 @@
+package rtmp
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMaxPublishBitrateDisconnectsPublisher(t *testing.T) {
+	server := NewServer(0, StreamConfig{MaxPublishBitrate: 1_000_000}, nil)
+	defer server.cancel()
+
+	publisher, publisherConn := newTestPlayer(1)
+	publisher.sessionId = "publisher"
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+
+	// 50KB 프레임을 한꺼번에 보내 5초 구간 평균이 1Mbps(625KB)를 넘게 함
+	frame := append(append([]byte(nil), testAVCKeyFrame[0]...), make([]byte, 50*1024)...)
+	for i := 0; i < 20 && !publisherConn.closed; i++ {
+		server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", FrameType: "AVC NALU", Timestamp: uint32(i * 33), Data: [][]byte{frame}})
+	}
+
+	if !publisherConn.closed {
+		t.Fatal("expected publisher exceeding max bitrate to be disconnected")
+	}
+	if codes := readStatusCodes(t, publisherConn); !slices.Contains(codes, bitrateExceededStatusCode) {
+		t.Fatalf("expected %s status, got %v", bitrateExceededStatusCode, codes)
+	}
+	if server.GetStream("live/test") != nil {
+		t.Fatal("expected stream to be removed after publisher was disconnected")
+	}
+}
+
+func TestBitrateMeterSlidingWindow(t *testing.T) {
+	var meter bitrateMeter
+	start := time.Now()
+
+	// 100ms마다 10KB = 800kbps
+	var bitrate int64
+	for i := 0; i < 100; i++ {
+		bitrate = meter.add(10_000, start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+	if bitrate < 790_000 || bitrate > 820_000 {
+		t.Fatalf("expected about 800kbps over the window, got %d", bitrate)
+	}
+
+	// 구간이 지나면 이전 샘플은 빠짐
+	if bitrate := meter.add(0, start.Add(20*time.Second)); bitrate != 0 {
+		t.Fatalf("expected expired samples to be dropped, got %d", bitrate)
+	}
+}
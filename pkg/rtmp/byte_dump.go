@@ -0,0 +1,111 @@
+package rtmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// byteDump는 세션의 송수신 원본 바이트를 hex 덤프로 기록 (문제 클라이언트 분석용)
+// 읽기(세션 goroutine)와 쓰기(이벤트 루프, 송신 큐)가 동시에 기록하므로 잠금으로 보호
+type byteDump struct {
+	mu        sync.Mutex
+	w         io.WriteCloser
+	limit     int64 // 기록할 최대 바이트 (방향 합산)
+	written   int64
+	exhausted bool // 예산을 다 써서 더 기록하지 않음 (한 번만 표시)
+	closed    bool
+}
+
+// record는 한 번의 읽기/쓰기로 오간 바이트를 방향("in", "out")과 함께 기록
+func (d *byteDump) record(direction string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.exhausted {
+		return
+	}
+	remaining := d.limit - d.written
+	if remaining <= 0 {
+		d.exhausted = true
+		fmt.Fprintf(d.w, "%s byte budget of %d exhausted, not recording further\n", time.Now().Format(time.RFC3339Nano), d.limit)
+		return
+	}
+	truncated := ""
+	if int64(len(data)) > remaining {
+		truncated = fmt.Sprintf(", truncated from %d", len(data))
+		data = data[:remaining]
+	}
+	d.written += int64(len(data))
+
+	fmt.Fprintf(d.w, "%s %s %d bytes%s\n%s", time.Now().Format(time.RFC3339Nano), direction, len(data), truncated, hex.Dump(data))
+}
+
+// close는 덤프 파일을 닫는다 (여러 번 호출해도 안전)
+func (d *byteDump) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	if err := d.w.Close(); err != nil {
+		slog.Error("Error closing byte dump", "err", err)
+	}
+}
+
+// dumpConn은 연결을 오가는 바이트를 그대로 전달하면서 byteDump에 기록
+type dumpConn struct {
+	net.Conn
+	dump *byteDump
+}
+
+// newDumpConn은 dir 아래에 연결별 덤프 파일을 만들고 conn을 감싼다
+func newDumpConn(conn net.Conn, dir string, limit int64) (*dumpConn, error) {
+	if limit <= 0 {
+		limit = DEFAULT_BYTE_DUMP_LIMIT
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create byte dump directory: %w", err)
+	}
+
+	// 예: rtmp-20260102-150405.123456-127.0.0.1_50000.hex
+	remote := strings.NewReplacer(":", "_", "[", "", "]", "", "/", "_").Replace(conn.RemoteAddr().String())
+	name := fmt.Sprintf("rtmp-%s-%s.hex", time.Now().Format("20060102-150405.000000"), remote)
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create byte dump file: %w", err)
+	}
+
+	fmt.Fprintf(file, "remote %s, local %s, budget %d bytes\n", conn.RemoteAddr(), conn.LocalAddr(), limit)
+	return &dumpConn{Conn: conn, dump: &byteDump{w: file, limit: limit}}, nil
+}
+
+func (c *dumpConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.dump.record("in", p[:n])
+	return n, err
+}
+
+func (c *dumpConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.dump.record("out", p[:n])
+	return n, err
+}
+
+func (c *dumpConn) Close() error {
+	c.dump.close()
+	return c.Conn.Close()
+}
@@ -0,0 +1,116 @@
+package rtmp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handshakeOverPipe는 net.Pipe로 세션을 만들고 C0+C1+C2를 보내 S0+S1+S2를 받은 뒤 연결을 닫는다
+func handshakeOverPipe(t *testing.T, server *Server) *session {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	session := server.newSessionWithChannel(serverConn)
+
+	clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+	c1 := bytes.Repeat([]byte{0xAB}, HANDSHAKE_SIZE)
+	if _, err := clientConn.Write(append(append([]byte{RTMP_VERSION}, c1...), make([]byte, HANDSHAKE_SIZE)...)); err != nil {
+		t.Fatalf("failed to write handshake: %v", err)
+	}
+	if _, err := io.ReadFull(clientConn, make([]byte, 1+HANDSHAKE_SIZE*2)); err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+	clientConn.Close()
+	return session
+}
+
+// waitForDumpClosed는 세션 종료로 덤프 파일이 닫힐 때까지 기다린다
+func waitForDumpClosed(t *testing.T, dump *byteDump) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dump.mu.Lock()
+		closed := dump.closed
+		dump.mu.Unlock()
+		if closed {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected byte dump to be closed on session teardown")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestByteDumpCapturesHandshake(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServer(0, StreamConfig{ByteDumpDir: dir}, nil)
+	defer server.cancel()
+
+	session := handshakeOverPipe(t, server)
+	conn, ok := session.conn.(*dumpConn)
+	if !ok {
+		t.Fatalf("expected session connection to be wrapped for dumping, got %T", session.conn)
+	}
+	waitForDumpClosed(t, conn.dump)
+
+	files, err := filepath.Glob(filepath.Join(dir, "rtmp-*.hex"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one dump file, got %v (err: %v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(data)
+	// C0와 C1의 첫 바이트(03 ab)가 수신으로, S0(03)가 송신으로 기록됨
+	if !strings.Contains(dump, " in ") || !strings.Contains(dump, "03 ab ab ab") {
+		t.Fatalf("expected inbound handshake bytes in dump, got:\n%.500s", dump)
+	}
+	if !strings.Contains(dump, " out ") {
+		t.Fatalf("expected outbound handshake bytes in dump, got:\n%.500s", dump)
+	}
+	if conn.dump.written != 2*(1+HANDSHAKE_SIZE*2) {
+		t.Fatalf("expected %d bytes recorded, got %d", 2*(1+HANDSHAKE_SIZE*2), conn.dump.written)
+	}
+}
+
+func TestByteDumpStopsAtBudget(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServer(0, StreamConfig{ByteDumpDir: dir, ByteDumpLimit: 100}, nil)
+	defer server.cancel()
+
+	session := handshakeOverPipe(t, server)
+	conn := session.conn.(*dumpConn)
+	waitForDumpClosed(t, conn.dump)
+
+	if conn.dump.written != 100 {
+		t.Fatalf("expected recording to stop at 100 bytes, got %d", conn.dump.written)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "rtmp-*.hex"))
+	if len(files) != 1 {
+		t.Fatalf("expected one dump file, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "truncated from") || !strings.Contains(string(data), "budget of 100 exhausted") {
+		t.Fatalf("expected truncation to be noted in dump, got:\n%s", data)
+	}
+}
+
+func TestByteDumpDisabledByDefault(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	session := handshakeOverPipe(t, server)
+	if _, ok := session.conn.(*dumpConn); ok {
+		t.Fatal("expected no byte dump without ByteDumpDir")
+	}
+}
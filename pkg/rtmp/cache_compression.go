@@ -0,0 +1,136 @@
+package rtmp
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// 유휴 스트림 캐시를 압축할지 확인하는 주기
+const idleCacheCheckInterval = time.Second
+
+// compressedCache는 플레이어가 없는 스트림의 캐시 payload를 압축해 둔 것
+// 프레임 목록(타입, 타임스탬프)은 그대로 두고 payload만 비우며, 다음 입장이나 새 프레임에서 푼다
+type compressedCache struct {
+	data    []byte // cachedPayloads 순서로 이어 붙여 압축한 payload
+	lengths []int  // 프레임별 원래 payload 길이
+	size    int    // 압축 전 전체 크기
+}
+
+// cachedPayloads는 캐시된 모든 프레임의 payload 위치 (sequence header, GOP, 오디오 순서)
+func (s *Stream) cachedPayloads() []*[][]byte {
+	var payloads []*[][]byte
+	if s.videoCache.sequenceHeader != nil {
+		payloads = append(payloads, &s.videoCache.sequenceHeader.data)
+	}
+	for i := range s.videoCache.gopFrames {
+		payloads = append(payloads, &s.videoCache.gopFrames[i].data)
+	}
+	if s.audioCache.sequenceHeader != nil {
+		payloads = append(payloads, &s.audioCache.sequenceHeader.data)
+	}
+	for i := range s.audioCache.recentFrames {
+		payloads = append(payloads, &s.audioCache.recentFrames[i].data)
+	}
+	return payloads
+}
+
+// useCache는 캐시를 읽거나 바꾸기 전에 호출 (압축되어 있으면 풀고 유휴 시간을 다시 센다)
+func (s *Stream) useCache() {
+	s.cacheUsedAt = time.Now()
+	s.cacheCompressTried = false
+	s.decompressCache()
+}
+
+// compressIdleCache는 플레이어 없이 idleAfter 동안 쓰이지 않은 캐시를 압축 (압축했으면 true)
+// 워밍업 중인 스트림은 첫 플레이어가 바로 받을 수 있도록 압축하지 않는다
+// minBytes보다 작거나 압축해도 줄지 않는 캐시는 그대로 둔다
+func (s *Stream) compressIdleCache(now time.Time, idleAfter time.Duration, minBytes int) bool {
+	if s.compressedCache != nil || s.cacheCompressTried || len(s.players) > 0 || len(s.pendingPlayers) > 0 || s.warming() {
+		return false
+	}
+	if now.Sub(s.cacheUsedAt) < idleAfter {
+		return false
+	}
+	// 캐시를 다시 쓰기 전까지는 다시 시도하지 않음
+	s.cacheCompressTried = true
+
+	payloads := s.cachedPayloads()
+	lengths := make([]int, len(payloads))
+	size := 0
+	for i, payload := range payloads {
+		lengths[i] = chunksSize(*payload)
+		size += lengths[i]
+	}
+	if size == 0 || size < minBytes {
+		return false
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		slog.Error("Failed to create cache compressor", "streamName", s.name, "err", err)
+		return false
+	}
+	for _, payload := range payloads {
+		for _, chunk := range *payload {
+			w.Write(chunk) // bytes.Buffer에 쓰므로 실패하지 않음
+		}
+	}
+	if err := w.Close(); err != nil {
+		slog.Error("Failed to compress stream cache", "streamName", s.name, "err", err)
+		return false
+	}
+	if buf.Len() >= size {
+		slog.Debug("Stream cache not compressible", "streamName", s.name, "bytes", size)
+		return false
+	}
+
+	for _, payload := range payloads {
+		*payload = nil
+	}
+	s.compressedCache = &compressedCache{data: buf.Bytes(), lengths: lengths, size: size}
+	slog.Info("Idle stream cache compressed", "streamName", s.name, "bytes", size, "compressedBytes", buf.Len())
+	return true
+}
+
+// decompressCache는 압축된 캐시 payload를 원래 프레임에 되돌린다
+// 되돌릴 수 없으면 (있어서는 안 되는 경우) 캐시를 버린다
+func (s *Stream) decompressCache() {
+	c := s.compressedCache
+	if c == nil {
+		return
+	}
+	s.compressedCache = nil
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(c.data)))
+	payloads := s.cachedPayloads()
+	if err != nil || len(data) != c.size || len(payloads) != len(c.lengths) {
+		slog.Error("Failed to decompress stream cache, dropping it", "streamName", s.name, "err", err)
+		s.videoCache = VideoCache{
+			gopFrames: make([]VideoFrame, 0),
+		}
+		s.audioCache = AudioCache{
+			recentFrames: make([]AudioFrame, 0),
+			maxFrames:    s.audioCache.maxFrames,
+		}
+		return
+	}
+
+	offset := 0
+	for i, payload := range payloads {
+		end := offset + c.lengths[i]
+		*payload = [][]byte{data[offset:end:end]}
+		offset = end
+	}
+	slog.Debug("Stream cache decompressed", "streamName", s.name, "bytes", c.size)
+}
+
+// compressIdleCaches는 플레이어가 없는 스트림의 캐시를 압축 (이벤트 루프에서 주기적으로 호출)
+func (s *Server) compressIdleCaches(now time.Time) {
+	for _, stream := range s.streams {
+		stream.compressIdleCache(now, s.streamConfig.IdleCacheCompressAfter, s.streamConfig.IdleCacheMinBytes)
+	}
+}
@@ -0,0 +1,101 @@
+package rtmp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// header 뒤에 size 바이트의 압축되는 payload를 붙여 두 청크로 나눈 프레임
+func testLargeFrame(header []byte, size int) [][]byte {
+	payload := append(append([]byte{}, header...), bytes.Repeat([]byte("gop-cache "), size/10)...)
+	return [][]byte{payload[:len(payload)/3], payload[len(payload)/3:]}
+}
+
+// 시퀀스 헤더, 큰 GOP, 오디오가 캐시된 스트림
+func newTestCachedStream() *Stream {
+	stream := NewStream("live/test", 30, 0)
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testLargeFrame([]byte{0x17, 0x01, 0x00, 0x00, 0x00}, 40000)})
+	for ts := uint32(40); ts < 400; ts += 40 {
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "inter frame", Data: testLargeFrame([]byte{0x27, 0x01, 0x00, 0x00, 0x00}, 8000)})
+		stream.ProcessAudioData(AudioData{Timestamp: ts, Data: testLargeFrame([]byte{0xAF, 0x01}, 300)})
+	}
+	return stream
+}
+
+func TestIdleCacheSurvivesCompression(t *testing.T) {
+	stream := newTestCachedStream()
+
+	// 압축 전에 입장한 플레이어가 받은 바이트
+	reference, referenceConn := newTestPlayer(1)
+	stream.AddPlayer(reference)
+	expected := bytes.Clone(referenceConn.buf.Bytes())
+	stream.RemovePlayer(reference)
+	cached := stream.GetGOPCache()
+
+	now := time.Now()
+	if stream.compressIdleCache(now, time.Minute, 0) {
+		t.Fatal("expected a recently used cache not to be compressed")
+	}
+	if !stream.compressIdleCache(now.Add(2*time.Minute), time.Minute, 0) {
+		t.Fatal("expected idle cache to be compressed")
+	}
+	if compressed := stream.compressedCache; compressed == nil || len(compressed.data) >= compressed.size {
+		t.Fatalf("expected compressed cache smaller than the original, got %+v", compressed)
+	}
+	for i, payload := range stream.cachedPayloads() {
+		if *payload != nil {
+			t.Fatalf("expected payload %d to be released while compressed", i)
+		}
+	}
+
+	// 다음 입장 시 캐시를 풀어 압축 전과 같은 바이트를 전송
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+	if !bytes.Equal(conn.buf.Bytes(), expected) {
+		t.Fatalf("expected joiner to receive the same %d bytes as before compression, got %d", len(expected), conn.buf.Len())
+	}
+	if stream.compressedCache != nil {
+		t.Fatal("expected cache to be decompressed on join")
+	}
+	if !reflect.DeepEqual(stream.GetGOPCache(), cached) {
+		t.Fatal("expected cached frames to survive compression byte-for-byte")
+	}
+}
+
+func TestIdleCacheCompressionConditions(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	// 플레이어가 있으면 유휴 상태가 아님
+	stream := newTestCachedStream()
+	player, _ := newTestPlayer(1)
+	stream.AddPlayer(player)
+	if stream.compressIdleCache(later, time.Minute, 0) {
+		t.Fatal("expected cache with players not to be compressed")
+	}
+
+	// 최소 크기보다 작은 캐시는 압축하지 않음
+	stream = newTestCachedStream()
+	if stream.compressIdleCache(later, time.Minute, 1<<20) {
+		t.Fatal("expected cache below the size threshold not to be compressed")
+	}
+
+	// 압축 중 새 프레임이 들어오면 풀고 다시 유휴 시간을 센다
+	stream = newTestCachedStream()
+	if !stream.compressIdleCache(later, time.Minute, 0) {
+		t.Fatal("expected idle cache to be compressed")
+	}
+	stream.ProcessVideoData(VideoData{Timestamp: 400, FrameType: "inter frame", Data: testAVCInterFrame})
+	if stream.compressedCache != nil || len(stream.videoCache.gopFrames) != 11 {
+		t.Fatalf("expected new frame to decompress the cache, got %d frames", len(stream.videoCache.gopFrames))
+	}
+	if frame := stream.videoCache.gopFrames[0]; !isVideoKeyFrame(frame.data) || chunksSize(frame.data) != 40005 {
+		t.Fatalf("expected key frame payload to be restored, got %d bytes", chunksSize(frame.data))
+	}
+	if stream.compressIdleCache(time.Now(), time.Minute, 0) {
+		t.Fatal("expected a just-written cache not to be compressed")
+	}
+}
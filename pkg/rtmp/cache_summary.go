@@ -0,0 +1,127 @@
+package rtmp
+
+import (
+	"errors"
+	"time"
+)
+
+// 캐시 요약 요청이 이벤트 루프 응답을 기다리는 최대 시간
+const cacheSummaryTimeout = time.Second
+
+// ErrStreamNotFound는 요청한 스트림이 없는 경우의 에러
+var ErrStreamNotFound = errors.New("stream not found")
+
+// ErrEventLoopBusy는 이벤트 루프가 제한 시간 안에 요청을 처리하지 못한 경우의 에러
+var ErrEventLoopBusy = errors.New("event loop did not respond")
+
+// CacheSummary는 스트림 캐시 내용 요약 (재생 문제 디버깅용)
+// 타임스탬프는 캐시된 프레임이 없으면 0
+type CacheSummary struct {
+	StreamName string
+
+	VideoSequenceHeader bool // AVC sequence header 캐시 여부
+	AudioSequenceHeader bool // AAC sequence header 캐시 여부
+	Metadata            bool // onMetaData 캐시 여부
+
+	GopFrameCount       int // 캐시된 비디오 프레임 수 (키프레임 포함)
+	KeyFrameCount       int
+	FirstVideoTimestamp uint32
+	LastVideoTimestamp  uint32
+	VideoBytes          int
+
+	AudioFrameCount     int
+	FirstAudioTimestamp uint32
+	LastAudioTimestamp  uint32
+	AudioBytes          int
+
+	Publisher PublisherInfo // 발행자가 없으면 zero
+	Uptime    time.Duration // 요약 시점까지의 발행 시간
+}
+
+// CacheSummary는 현재 캐시 내용을 요약 (이벤트 루프에서 호출)
+func (s *Stream) CacheSummary() CacheSummary {
+	s.useCache()
+	summary := CacheSummary{
+		StreamName:          s.name,
+		VideoSequenceHeader: s.videoCache.sequenceHeader != nil,
+		AudioSequenceHeader: s.audioCache.sequenceHeader != nil,
+		Metadata:            s.lastMetadata != nil,
+		GopFrameCount:       len(s.videoCache.gopFrames),
+		AudioFrameCount:     len(s.audioCache.recentFrames),
+	}
+	if publisher, ok := s.PublisherInfo(); ok {
+		summary.Publisher = publisher
+		summary.Uptime = publisher.Uptime(time.Now())
+	}
+
+	if frames := s.videoCache.gopFrames; len(frames) > 0 {
+		summary.FirstVideoTimestamp = frames[0].timestamp
+		summary.LastVideoTimestamp = frames[len(frames)-1].timestamp
+	}
+	for _, frame := range s.videoCache.gopFrames {
+		if frame.frameType == "key frame" || isVideoKeyFrame(frame.data) {
+			summary.KeyFrameCount++
+		}
+		summary.VideoBytes += chunksSize(frame.data)
+	}
+
+	if frames := s.audioCache.recentFrames; len(frames) > 0 {
+		summary.FirstAudioTimestamp = frames[0].timestamp
+		summary.LastAudioTimestamp = frames[len(frames)-1].timestamp
+	}
+	for _, frame := range s.audioCache.recentFrames {
+		summary.AudioBytes += chunksSize(frame.data)
+	}
+
+	return summary
+}
+
+// cacheSummaryRequest는 이벤트 루프에 스트림 캐시 요약을 요청하는 이벤트
+// (스트림 캐시는 이벤트 루프만 접근하므로 다른 goroutine은 이 요청으로 조회)
+type cacheSummaryRequest struct {
+	streamName string
+	reply      chan cacheSummaryReply // 버퍼 1 (요청자가 포기해도 이벤트 루프가 막히지 않도록)
+}
+
+type cacheSummaryReply struct {
+	summary CacheSummary
+	found   bool
+}
+
+// handleCacheSummaryRequest는 이벤트 루프에서 캐시 요약을 만들어 응답
+func (s *Server) handleCacheSummaryRequest(request cacheSummaryRequest) {
+	stream := s.GetStream(request.streamName)
+	if stream == nil {
+		request.reply <- cacheSummaryReply{}
+		return
+	}
+	request.reply <- cacheSummaryReply{summary: stream.CacheSummary(), found: true}
+}
+
+// GetCacheSummary는 스트림의 캐시 요약을 반환 (어느 goroutine에서나 호출 가능)
+// 이벤트 루프를 거쳐 조회하므로 서버가 실행 중이어야 한다
+func (s *Server) GetCacheSummary(streamName string) (CacheSummary, error) {
+	request := cacheSummaryRequest{streamName: streamName, reply: make(chan cacheSummaryReply, 1)}
+	timeout := time.NewTimer(cacheSummaryTimeout)
+	defer timeout.Stop()
+
+	select {
+	case s.channel <- request:
+	case <-timeout.C:
+		return CacheSummary{}, ErrEventLoopBusy
+	case <-s.ctx.Done():
+		return CacheSummary{}, s.ctx.Err()
+	}
+
+	select {
+	case reply := <-request.reply:
+		if !reply.found {
+			return CacheSummary{}, ErrStreamNotFound
+		}
+		return reply.summary, nil
+	case <-timeout.C:
+		return CacheSummary{}, ErrEventLoopBusy
+	case <-s.ctx.Done():
+		return CacheSummary{}, s.ctx.Err()
+	}
+}
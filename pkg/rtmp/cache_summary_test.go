@@ -0,0 +1,85 @@
+package rtmp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCacheSummaryReflectsCachedFrames(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+
+	summary := stream.CacheSummary()
+	if summary.VideoSequenceHeader || summary.GopFrameCount != 0 || summary.AudioFrameCount != 0 {
+		t.Fatalf("expected empty cache summary, got %+v", summary)
+	}
+
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	feedTestVideo(stream, 5, 40, 80) // 0, 80, 160은 키프레임
+	stream.ProcessAudioData(AudioData{Timestamp: 23, Data: testAACFrame})
+	stream.ProcessAudioData(AudioData{Timestamp: 46, Data: testAACFrame})
+
+	summary = stream.CacheSummary()
+	if !summary.VideoSequenceHeader || !summary.AudioSequenceHeader || summary.Metadata {
+		t.Fatalf("expected sequence headers without metadata, got %+v", summary)
+	}
+
+	// GOP 캐시는 마지막 키프레임(160)부터 유지
+	frames := stream.videoCache.gopFrames
+	if summary.GopFrameCount != len(frames) || summary.GopFrameCount == 0 {
+		t.Fatalf("expected %d cached video frames, got %d", len(frames), summary.GopFrameCount)
+	}
+	if summary.FirstVideoTimestamp != frames[0].timestamp || summary.LastVideoTimestamp != 160 {
+		t.Fatalf("expected video timestamps %d-160, got %d-%d", frames[0].timestamp, summary.FirstVideoTimestamp, summary.LastVideoTimestamp)
+	}
+	keyFrames := 0
+	for _, frame := range frames {
+		if isVideoKeyFrame(frame.data) {
+			keyFrames++
+		}
+	}
+	if summary.KeyFrameCount != keyFrames || summary.KeyFrameCount == 0 {
+		t.Fatalf("expected %d key frames, got %d", keyFrames, summary.KeyFrameCount)
+	}
+	if summary.VideoBytes != len(frames)*len(testAVCKeyFrame[0]) {
+		t.Fatalf("expected %d video bytes, got %d", len(frames)*len(testAVCKeyFrame[0]), summary.VideoBytes)
+	}
+
+	if summary.AudioFrameCount != 2 || summary.FirstAudioTimestamp != 23 || summary.LastAudioTimestamp != 46 {
+		t.Fatalf("expected 2 audio frames at 23-46, got %d at %d-%d", summary.AudioFrameCount, summary.FirstAudioTimestamp, summary.LastAudioTimestamp)
+	}
+	if summary.AudioBytes != 2*len(testAACFrame[0]) {
+		t.Fatalf("expected %d audio bytes, got %d", 2*len(testAACFrame[0]), summary.AudioBytes)
+	}
+}
+
+func TestGetCacheSummaryThroughEventLoop(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", FrameType: "AVC NALU", Timestamp: 40, Data: testAVCKeyFrame})
+
+	go server.eventLoop()
+
+	summary, err := server.GetCacheSummary("live/test")
+	if err != nil {
+		t.Fatalf("expected cache summary, got error: %v", err)
+	}
+	if summary.StreamName != "live/test" || !summary.VideoSequenceHeader || summary.GopFrameCount != 1 || summary.KeyFrameCount != 1 {
+		t.Fatalf("unexpected cache summary: %+v", summary)
+	}
+	if summary.Publisher.SessionID != publisher.sessionId || summary.Uptime <= 0 {
+		t.Fatalf("expected publisher %s with uptime, got %+v (%v)", publisher.sessionId, summary.Publisher, summary.Uptime)
+	}
+
+	if _, err := server.GetCacheSummary("live/missing"); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected ErrStreamNotFound, got %v", err)
+	}
+	if server.GetUnhandledEventCount() != 0 {
+		t.Fatalf("expected cache summary requests to be handled, got %d unhandled events", server.GetUnhandledEventCount())
+	}
+}
@@ -0,0 +1,89 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"sol/pkg/codec"
+)
+
+// codecTrack은 미디어 종류 하나(비디오 또는 오디오)의 선언된 코덱과 프레임에서 감지한 코덱
+type codecTrack struct {
+	declared codec.Codec   // onMetaData의 videocodecid/audiocodecid (없으면 Unknown)
+	detected codec.Codec   // 마지막으로 받은 프레임의 코덱
+	reported CodecMismatch // 마지막으로 알린 불일치 (같은 불일치를 프레임마다 반복해서 알리지 않음)
+}
+
+// declaredCodec은 onMetaData의 코덱 값을 Codec으로 변환
+// FLV 코덱 ID 숫자(7), enhanced RTMP FourCC 문자열("hvc1") 또는 FourCC를 숫자로 쓴 값(0x68766331)을 받는다
+func declaredCodec(value any, fromID func(uint8) codec.Codec) codec.Codec {
+	switch v := value.(type) {
+	case float64:
+		if v >= 0 && v <= 0xFF && v == float64(uint8(v)) {
+			return fromID(uint8(v))
+		}
+		if v > 0xFF && v <= 0xFFFFFFFF && v == float64(uint32(v)) {
+			return codec.FromFourCC(string(binary.BigEndian.AppendUint32(nil, uint32(v))))
+		}
+	case string:
+		return codec.FromFourCC(v)
+	}
+	return codec.Unknown
+}
+
+// checkMetadataCodecs는 메타데이터의 코덱 선언을 기록하고 지금까지 받은 프레임의 코덱과 비교
+func (s *Stream) checkMetadataCodecs(metadata map[string]any) {
+	s.videoCodecs.declared = declaredCodec(metadata["videocodecid"], codec.FromFLVVideoID)
+	s.audioCodecs.declared = declaredCodec(metadata["audiocodecid"], codec.FromFLVAudioID)
+	s.compareCodecs("video", &s.videoCodecs)
+	s.compareCodecs("audio", &s.audioCodecs)
+}
+
+// detectVideoCodec은 비디오 프레임의 코덱을 기록
+// 코덱이 바뀌면 이전 코덱의 sequence header와 GOP는 새 코덱으로 디코딩할 수 없으므로 버린다
+// (새 코덱의 sequence header는 캐시되고 기존 플레이어에게도 그대로 전달되어 디코더를 재설정)
+func (s *Stream) detectVideoCodec(detected codec.Codec) {
+	if detected == codec.Unknown || detected == s.videoCodecs.detected {
+		return
+	}
+	if previous := s.videoCodecs.detected; previous != codec.Unknown {
+		slog.Warn("Video codec changed mid-stream, dropping cached video", "streamName", s.name, "from", previous, "to", detected)
+		s.videoCache = VideoCache{
+			gopFrames: make([]VideoFrame, 0),
+		}
+	}
+	s.videoCodecs.detected = detected
+	s.compareCodecs("video", &s.videoCodecs)
+}
+
+// detectAudioCodec은 오디오 프레임의 코덱을 기록 (코덱이 바뀌면 이전 코덱의 캐시를 버림)
+func (s *Stream) detectAudioCodec(detected codec.Codec) {
+	if detected == codec.Unknown || detected == s.audioCodecs.detected {
+		return
+	}
+	if previous := s.audioCodecs.detected; previous != codec.Unknown {
+		slog.Warn("Audio codec changed mid-stream, dropping cached audio", "streamName", s.name, "from", previous, "to", detected)
+		s.audioCache = AudioCache{
+			recentFrames: make([]AudioFrame, 0),
+			maxFrames:    s.audioCache.maxFrames,
+		}
+	}
+	s.audioCodecs.detected = detected
+	s.compareCodecs("audio", &s.audioCodecs)
+}
+
+// compareCodecs는 선언된 코덱과 감지한 코덱이 모두 알려져 있고 서로 다르면 불일치를 알림
+func (s *Stream) compareCodecs(media string, track *codecTrack) {
+	if track.declared == codec.Unknown || track.detected == codec.Unknown || track.declared == track.detected {
+		return
+	}
+	mismatch := CodecMismatch{StreamName: s.name, Media: media, Declared: track.declared, Detected: track.detected}
+	if track.reported == mismatch {
+		return
+	}
+	track.reported = mismatch
+
+	slog.Warn("Metadata codec does not match frames", "streamName", s.name, "media", media, "declared", track.declared, "detected", track.detected)
+	if s.onCodecMismatch != nil {
+		s.onCodecMismatch(mismatch)
+	}
+}
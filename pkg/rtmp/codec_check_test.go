@@ -0,0 +1,116 @@
+package rtmp
+
+import (
+	"sol/pkg/codec"
+	"testing"
+)
+
+// VP6 키프레임 (CodecID 4, sequence header 없음)
+var testVP6KeyFrame = [][]byte{{0x14, 0x00, 0x00}}
+
+func TestDeclaredCodec(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected codec.Codec
+	}{
+		{7.0, codec.H264},
+		{12.0, codec.H265},
+		{"hvc1", codec.H265},
+		{float64(0x68766331), codec.H265}, // FourCC "hvc1"를 숫자로 선언
+		{7.5, codec.Unknown},
+		{"bogus", codec.Unknown},
+		{nil, codec.Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := declaredCodec(tt.value, codec.FromFLVVideoID); got != tt.expected {
+			t.Errorf("expected %v to declare %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+	if got := declaredCodec(10.0, codec.FromFLVAudioID); got != codec.AAC {
+		t.Errorf("expected audiocodecid 10 to declare AAC, got %q", got)
+	}
+}
+
+func TestMetadataCodecMismatchReported(t *testing.T) {
+	events := make(chan interface{}, 10)
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, StatusEvents: events}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	for len(events) > 0 {
+		<-events // 발행 시작 알림
+	}
+
+	// 메타데이터는 H.264/AAC라고 선언하지만 비디오는 VP6로 도착
+	server.handleMetaData(MetaData{SessionId: publisher.sessionId, StreamName: "live/test", Metadata: map[string]any{"videocodecid": 7.0, "audiocodecid": 10.0}})
+	server.handleAudioData(AudioData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: 0, Data: testAACSequenceHeader})
+	for ts := uint32(0); ts < 100; ts += 33 {
+		server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: ts, FrameType: "key frame", Data: testVP6KeyFrame})
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected exactly one status event, got %d", len(events))
+	}
+	expected := CodecMismatch{StreamName: "live/test", Media: "video", Declared: codec.H264, Detected: codec.VP6}
+	if event, ok := (<-events).(CodecMismatch); !ok || event != expected {
+		t.Fatalf("expected %+v, got %+v", expected, event)
+	}
+	if got := server.GetCodecMismatchCount(); got != 1 {
+		t.Fatalf("expected 1 codec mismatch, got %d", got)
+	}
+
+	// 메타데이터를 실제 코덱으로 갱신하면 더 알리지 않음
+	server.handleMetaData(MetaData{SessionId: publisher.sessionId, StreamName: "live/test", Metadata: map[string]any{"videocodecid": 4.0, "audiocodecid": 10.0}})
+	server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: 132, FrameType: "key frame", Data: testVP6KeyFrame})
+	if len(events) != 0 {
+		t.Fatalf("expected no status event after metadata matches, got %v", <-events)
+	}
+}
+
+func TestCodecSwitchDropsStaleCache(t *testing.T) {
+	stream := NewStream("test", 10, 0)
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+
+	// 발행 도중 VP6로 바뀌면 H.264 sequence header와 GOP를 새 플레이어에게 보내지 않음
+	stream.ProcessVideoData(VideoData{Timestamp: 33, FrameType: "key frame", Data: testVP6KeyFrame})
+	if stream.videoCache.sequenceHeader != nil {
+		t.Fatal("expected the stale AVC sequence header to be dropped")
+	}
+	if frames := stream.videoCache.gopFrames; len(frames) != 1 || videoCodec(frames[0].data) != codec.VP6 {
+		t.Fatalf("expected only the VP6 key frame to be cached, got %+v", frames)
+	}
+
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+	initial := len(readAllMessages(t, conn.buf.Bytes()))
+	for _, msg := range readAllMessages(t, conn.buf.Bytes()) {
+		if msg.messageHeader.typeId == MSG_TYPE_VIDEO && videoCodec(msg.payload) != codec.VP6 {
+			t.Fatalf("expected new player to receive only VP6 video, got codec %q", videoCodec(msg.payload))
+		}
+	}
+
+	// 다시 H.264로 바뀌면 새 sequence header를 캐시하고 기존 플레이어에게도 전달
+	stream.ProcessVideoData(VideoData{Timestamp: 66, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	if stream.videoCache.sequenceHeader == nil || len(stream.videoCache.gopFrames) != 0 {
+		t.Fatal("expected the new AVC sequence header to be cached with an empty GOP")
+	}
+	messages := readAllMessages(t, conn.buf.Bytes())
+	if len(messages) != initial+1 || !isVideoSequenceHeader(messages[initial].payload) {
+		t.Fatalf("expected the player to receive the new sequence header, got %d new messages", len(messages)-initial)
+	}
+}
+
+func TestVideoCodecFromEnhancedRTMPHeader(t *testing.T) {
+	// IsExHeader | key frame | PacketType 1 (CodedFrames), FourCC hvc1
+	frame := [][]byte{{0x80 | 0x10 | 0x01, 'h', 'v', 'c', '1', 0x00}}
+	if got := videoCodec(frame); got != codec.H265 {
+		t.Fatalf("expected H265 from the FourCC, got %q", got)
+	}
+	if isVideoSequenceHeader(frame) {
+		t.Fatal("expected an enhanced RTMP frame not to be taken for an AVC sequence header")
+	}
+}
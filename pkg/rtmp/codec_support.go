@@ -0,0 +1,77 @@
+package rtmp
+
+import (
+	"log/slog"
+	"sol/pkg/codec"
+)
+
+// UnsupportedCodecPolicy는 플레이어가 connect에서 지원한다고 알리지 않은 코덱의 미디어 처리 방식
+type UnsupportedCodecPolicy string
+
+const (
+	UnsupportedCodecWarn UnsupportedCodecPolicy = "warn" // 그대로 전달하고 경고 로그 (기본값)
+	UnsupportedCodecSkip UnsupportedCodecPolicy = "skip" // 해당 미디어(오디오 또는 비디오)를 전달하지 않고 경고 로그
+)
+
+// connect 명령 객체의 audioCodecs 비트 (SUPPORT_SND_*)
+// 같은 코덱의 변형(Nellymoser 8kHz/16kHz 등)은 하나라도 알리면 지원하는 것으로 본다
+var audioCodecFlags = map[codec.Codec]uint16{
+	codec.LPCM:       0x0001, // SUPPORT_SND_NONE (무압축)
+	codec.ADPCM:      0x0002,
+	codec.MP3:        0x0004,
+	codec.Nellymoser: 0x0020 | 0x0040 | 0x0200, // NELLY8, NELLY, NELLY16
+	codec.PCMA:       0x0080,
+	codec.PCMU:       0x0100,
+	codec.AAC:        0x0400,
+	codec.Speex:      0x0800,
+}
+
+// connect 명령 객체의 videoCodecs 비트 (SUPPORT_VID_*)
+var videoCodecFlags = map[codec.Codec]uint16{
+	codec.H263:        0x0004,          // SORENSON
+	codec.ScreenVideo: 0x0008 | 0x0040, // HOMEBREW, HOMEBREWV
+	codec.VP6:         0x0010 | 0x0020, // VP6, VP6ALPHA
+	codec.H264:        0x0080,
+}
+
+// SupportsAudioCodec는 클라이언트가 connect에서 c를 지원한다고 알렸는지 확인
+// audioCodecs를 보내지 않았거나 c에 해당하는 비트가 없는 코덱(enhanced RTMP 코덱 등)이면 true
+func (info ConnectInfo) SupportsAudioCodec(c codec.Codec) bool {
+	return codecFlagSet(info.AudioCodecs, audioCodecFlags[c])
+}
+
+// SupportsVideoCodec는 클라이언트가 connect에서 c를 지원한다고 알렸는지 확인
+// videoCodecs를 보내지 않았거나 c에 해당하는 비트가 없는 코덱(HEVC 등)이면 true
+func (info ConnectInfo) SupportsVideoCodec(c codec.Codec) bool {
+	return codecFlagSet(info.VideoCodecs, videoCodecFlags[c])
+}
+
+func codecFlagSet(advertised, flag uint16) bool {
+	return advertised == 0 || flag == 0 || advertised&flag != 0
+}
+
+// acceptsCodec은 플레이어에게 media 코덱 c의 프레임을 보낼지 결정
+// 지원하지 않는 코덱이면 코덱마다 한 번 경고하고, UnsupportedCodecSkip이면 false
+func (s *session) acceptsCodec(media string, c codec.Codec, streamName string) bool {
+	supported := true
+	switch media {
+	case "audio":
+		supported = s.connectInfo.SupportsAudioCodec(c)
+	case "video":
+		supported = s.connectInfo.SupportsVideoCodec(c)
+	}
+	if supported || c == codec.Unknown {
+		return true
+	}
+
+	skip := s.unsupportedCodecPolicy == UnsupportedCodecSkip
+	if _, reported := s.reportedCodecs[c]; !reported {
+		if s.reportedCodecs == nil {
+			s.reportedCodecs = make(map[codec.Codec]struct{})
+		}
+		s.reportedCodecs[c] = struct{}{}
+		slog.Warn("Player did not advertise support for stream codec", "streamName", streamName, "sessionId", s.sessionId,
+			"media", media, "codec", c, "audioCodecs", s.connectInfo.AudioCodecs, "videoCodecs", s.connectInfo.VideoCodecs, "skipped", skip)
+	}
+	return !skip
+}
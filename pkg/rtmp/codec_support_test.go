@@ -0,0 +1,75 @@
+package rtmp
+
+import (
+	"sol/pkg/codec"
+	"testing"
+)
+
+func TestConnectCodecFlags(t *testing.T) {
+	info := connectInfoFromCommand(map[string]any{"app": "live", "audioCodecs": 3191.0, "videoCodecs": 252.0}) // librtmp 기본값
+	if info.AudioCodecs != 3191 || info.VideoCodecs != 252 {
+		t.Fatalf("expected codec flags 3191/252, got %d/%d", info.AudioCodecs, info.VideoCodecs)
+	}
+	for _, c := range []codec.Codec{codec.AAC, codec.MP3, codec.Speex, codec.Nellymoser} {
+		if !info.SupportsAudioCodec(c) {
+			t.Errorf("expected audioCodecs 3191 to support %s", c)
+		}
+	}
+	if info.SupportsAudioCodec(codec.PCMA) {
+		t.Error("expected audioCodecs 3191 not to support PCMA")
+	}
+	if !info.SupportsVideoCodec(codec.H264) || !info.SupportsVideoCodec(codec.VP6) || !info.SupportsVideoCodec(codec.H265) {
+		t.Error("expected videoCodecs 252 to support H.264, VP6 and codecs without a flag")
+	}
+
+	// 보내지 않았거나 숫자가 아니면 모든 코덱 허용
+	for _, obj := range []map[string]any{{}, {"audioCodecs": "all", "videoCodecs": -1.0}} {
+		info := connectInfoFromCommand(obj)
+		if !info.SupportsAudioCodec(codec.PCMA) || !info.SupportsVideoCodec(codec.H263) {
+			t.Errorf("expected %v to allow every codec, got %+v", obj, info)
+		}
+	}
+}
+
+func TestPlayerWithoutCodecSupport(t *testing.T) {
+	countMedia := func(conn *bufferConn) (audio, video int) {
+		for _, msg := range conn.readMessages(t) {
+			switch msg.messageHeader.typeId {
+			case MSG_TYPE_AUDIO:
+				audio++
+			case MSG_TYPE_VIDEO:
+				video++
+			}
+		}
+		return audio, video
+	}
+
+	for _, tt := range []struct {
+		policy UnsupportedCodecPolicy
+		audio  int
+	}{
+		{UnsupportedCodecWarn, 3},
+		{UnsupportedCodecSkip, 0},
+	} {
+		player, conn := newTestPlayer(1)
+		player.unsupportedCodecPolicy = tt.policy
+		// MP3만 지원하는 플레이어 (SUPPORT_SND_MP3, SUPPORT_VID_H264)
+		player.connectInfo = connectInfoFromCommand(map[string]any{"app": "live", "audioCodecs": float64(0x0004), "videoCodecs": float64(0x0080)})
+
+		stream := NewStream("live/test", 10, 0)
+		stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCSequenceHeader})
+		stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACSequenceHeader})
+		stream.AddPlayer(player) // 캐시된 sequence header
+		stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+		stream.ProcessAudioData(AudioData{Timestamp: 23, Data: testAACFrame})
+		stream.ProcessAudioData(AudioData{Timestamp: 46, Data: testAACFrame})
+
+		audio, video := countMedia(conn)
+		if audio != tt.audio || video != 2 {
+			t.Errorf("%s: expected %d audio and 2 video messages, got %d and %d", tt.policy, tt.audio, audio, video)
+		}
+		if _, reported := player.reportedCodecs[codec.AAC]; !reported || len(player.reportedCodecs) != 1 {
+			t.Errorf("%s: expected AAC to be reported once, got %v", tt.policy, player.reportedCodecs)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package rtmp
+
+// H.273 색 코드 포인트 중 HDR 판별에 쓰는 값
+const (
+	COLOR_UNSPECIFIED    = 2  // 정보 없음 (colorConfig에 값이 없을 때)
+	COLOR_PRIMARIES_2020 = 9  // BT.2020 색역
+	TRANSFER_PQ          = 16 // SMPTE ST 2084 (HDR10)
+	TRANSFER_HLG         = 18 // ARIB STD-B67
+)
+
+// ColorInfo는 enhanced RTMP onMetaData의 colorInfo 객체 (HDR 색 정보)
+// colorConfig 값은 ITU-T H.273 코드 포인트이고 없는 값은 COLOR_UNSPECIFIED
+// hdrCll/hdrMdcv는 HDR10 정적 메타데이터로 없으면 0 또는 nil
+type ColorInfo struct {
+	BitDepth                int
+	ColorPrimaries          int
+	TransferCharacteristics int
+	MatrixCoefficients      int
+
+	MaxFALL int // hdrCll.maxFall: 프레임 평균 밝기 최대값 (cd/m²)
+	MaxCLL  int // hdrCll.maxCLL: 픽셀 밝기 최대값 (cd/m²)
+
+	MasteringDisplay *MasteringDisplay // hdrMdcv (없으면 nil)
+}
+
+// MasteringDisplay는 마스터링 디스플레이의 색역과 밝기 (hdrMdcv, 색도 좌표는 CIE 1931 xy)
+type MasteringDisplay struct {
+	RedX, RedY     float64
+	GreenX, GreenY float64
+	BlueX, BlueY   float64
+	WhitePointX    float64
+	WhitePointY    float64
+	MaxLuminance   float64 // cd/m²
+	MinLuminance   float64 // cd/m²
+}
+
+// ColorInfoFromMetadata는 onMetaData의 colorInfo 객체를 해석 (없으면 false)
+// 명세대로 colorConfig 아래에 있는 값을 쓰고, colorInfo에 바로 넣는 인코더도 받아들인다
+func ColorInfoFromMetadata(metadata map[string]any) (ColorInfo, bool) {
+	colorInfo, ok := metadata["colorInfo"].(map[string]any)
+	if !ok {
+		return ColorInfo{}, false
+	}
+
+	config, ok := colorInfo["colorConfig"].(map[string]any)
+	if !ok {
+		config = colorInfo
+	}
+	info := ColorInfo{
+		BitDepth:                metadataInt(config, "bitDepth", 0),
+		ColorPrimaries:          metadataInt(config, "colorPrimaries", COLOR_UNSPECIFIED),
+		TransferCharacteristics: metadataInt(config, "transferCharacteristics", COLOR_UNSPECIFIED),
+		MatrixCoefficients:      metadataInt(config, "matrixCoefficients", COLOR_UNSPECIFIED),
+	}
+
+	if cll, ok := colorInfo["hdrCll"].(map[string]any); ok {
+		info.MaxFALL = metadataInt(cll, "maxFall", 0)
+		info.MaxCLL = metadataInt(cll, "maxCLL", 0)
+	}
+	if mdcv, ok := colorInfo["hdrMdcv"].(map[string]any); ok {
+		value := func(key string) float64 {
+			v, _ := mdcv[key].(float64)
+			return v
+		}
+		info.MasteringDisplay = &MasteringDisplay{
+			RedX: value("redX"), RedY: value("redY"),
+			GreenX: value("greenX"), GreenY: value("greenY"),
+			BlueX: value("blueX"), BlueY: value("blueY"),
+			WhitePointX:  value("whitePointX"),
+			WhitePointY:  value("whitePointY"),
+			MaxLuminance: value("maxLuminance"),
+			MinLuminance: value("minLuminance"),
+		}
+	}
+	return info, true
+}
+
+// metadataInt는 AMF 숫자(float64) 값을 정수로 읽는다 (없거나 숫자가 아니면 fallback)
+func metadataInt(object map[string]any, key string, fallback int) int {
+	if v, ok := object[key].(float64); ok {
+		return int(v)
+	}
+	return fallback
+}
+
+// VideoRange는 HLS VIDEO-RANGE 속성 값 (PQ, HLG, SDR)
+func (c ColorInfo) VideoRange() string {
+	switch c.TransferCharacteristics {
+	case TRANSFER_PQ:
+		return "PQ"
+	case TRANSFER_HLG:
+		return "HLG"
+	default:
+		return "SDR"
+	}
+}
+
+// IsHDR은 HDR 전달 특성(PQ, HLG)을 쓰는지 확인
+func (c ColorInfo) IsHDR() bool {
+	return c.VideoRange() != "SDR"
+}
+
+// ColorInfo는 캐시된 메타데이터의 colorInfo를 반환 (HLS/DASH 매니페스트 생성 등, 없으면 false)
+func (s *Stream) ColorInfo() (ColorInfo, bool) {
+	return ColorInfoFromMetadata(s.lastMetadata)
+}
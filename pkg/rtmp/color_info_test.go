@@ -0,0 +1,91 @@
+package rtmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// HDR10으로 발행하는 enhanced RTMP 인코더의 onMetaData
+func testHDRMetadata() map[string]any {
+	return map[string]any{
+		"width":        3840.0,
+		"height":       2160.0,
+		"videocodecid": "hvc1",
+		"colorInfo": map[string]any{
+			"colorConfig": map[string]any{
+				"bitDepth":                10.0,
+				"colorPrimaries":          9.0,
+				"transferCharacteristics": 16.0,
+				"matrixCoefficients":      9.0,
+			},
+			"hdrCll": map[string]any{
+				"maxFall": 400.0,
+				"maxCLL":  1000.0,
+			},
+			"hdrMdcv": map[string]any{
+				"redX": 0.708, "redY": 0.292,
+				"greenX": 0.17, "greenY": 0.797,
+				"blueX": 0.131, "blueY": 0.046,
+				"whitePointX": 0.3127, "whitePointY": 0.329,
+				"maxLuminance": 1000.0, "minLuminance": 0.0001,
+			},
+		},
+	}
+}
+
+func TestColorInfoPreservedAndForwarded(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+
+	stream.ProcessMetaData(MetaData{Metadata: testHDRMetadata()})
+
+	expected := ColorInfo{
+		BitDepth:                10,
+		ColorPrimaries:          COLOR_PRIMARIES_2020,
+		TransferCharacteristics: TRANSFER_PQ,
+		MatrixCoefficients:      9,
+		MaxFALL:                 400,
+		MaxCLL:                  1000,
+		MasteringDisplay: &MasteringDisplay{
+			RedX: 0.708, RedY: 0.292,
+			GreenX: 0.17, GreenY: 0.797,
+			BlueX: 0.131, BlueY: 0.046,
+			WhitePointX: 0.3127, WhitePointY: 0.329,
+			MaxLuminance: 1000, MinLuminance: 0.0001,
+		},
+	}
+	info, ok := stream.ColorInfo()
+	if !ok || !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected color info %+v, got %+v (ok %t)", expected, info, ok)
+	}
+	if info.VideoRange() != "PQ" || !info.IsHDR() {
+		t.Fatalf("expected PQ HDR video range, got %s", info.VideoRange())
+	}
+
+	// 재생 중인 플레이어와 나중에 들어온 플레이어 모두 colorInfo 객체를 그대로 받음
+	lateJoiner, lateConn := newTestPlayer(1)
+	stream.AddPlayer(lateJoiner)
+	for name, c := range map[string]*bufferConn{"playing": conn, "late": lateConn} {
+		forwarded := readForwardedMetadata(t, c)
+		if len(forwarded) != 1 || !reflect.DeepEqual(forwarded[0]["colorInfo"], testHDRMetadata()["colorInfo"]) {
+			t.Fatalf("expected %s player to receive colorInfo, got %v", name, forwarded)
+		}
+	}
+}
+
+func TestColorInfoFromMetadataVariants(t *testing.T) {
+	// colorConfig 없이 colorInfo에 바로 넣은 HLG 값
+	info, ok := ColorInfoFromMetadata(map[string]any{"colorInfo": map[string]any{"transferCharacteristics": 18.0}})
+	if !ok || info.VideoRange() != "HLG" || info.ColorPrimaries != COLOR_UNSPECIFIED || info.MasteringDisplay != nil {
+		t.Fatalf("expected HLG with unspecified primaries, got %+v (ok %t)", info, ok)
+	}
+
+	// SDR 시절 메타데이터에는 colorInfo가 없음
+	if _, ok := ColorInfoFromMetadata(testPublisherMetadata()); ok {
+		t.Fatal("expected no color info without a colorInfo object")
+	}
+	if info := (ColorInfo{TransferCharacteristics: 1}); info.IsHDR() || info.VideoRange() != "SDR" {
+		t.Fatalf("expected BT.709 transfer to be SDR, got %s", info.VideoRange())
+	}
+}
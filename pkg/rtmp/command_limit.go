@@ -0,0 +1,41 @@
+package rtmp
+
+import "time"
+
+// 속도 제한과 관계없이 연달아 허용하는 최소 명령어 수
+// OBS/FFmpeg의 발행 시퀀스(connect, releaseStream, FCPublish, createStream, _checkbw, publish)가 한 번에 들어와도 통과
+const minCommandBurst = 16
+
+// commandLimiter는 세션별 AMF 명령어 토큰 버킷 (세션 수신 goroutine에서만 사용)
+type commandLimiter struct {
+	rate   float64 // 초당 채워지는 토큰 (허용 명령어 수)
+	burst  float64 // 버킷 크기 (연달아 허용하는 명령어 수)
+	tokens float64
+	last   time.Time
+}
+
+// newCommandLimiter는 초당 rate개의 명령어를 허용하는 제한기를 생성 (0 이하면 nil: 제한 없음)
+func newCommandLimiter(rate int) *commandLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := float64(max(rate, minCommandBurst))
+	return &commandLimiter{rate: float64(rate), burst: burst, tokens: burst}
+}
+
+// allow는 명령어 하나를 처리해도 되는지 반환 (nil이면 항상 허용)
+func (l *commandLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
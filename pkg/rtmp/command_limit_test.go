@@ -0,0 +1,65 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommandLimiterAllowsBurstThenRate(t *testing.T) {
+	if limiter := newCommandLimiter(0); limiter != nil || !limiter.allow(time.Now()) {
+		t.Fatal("expected no limit when the rate is 0")
+	}
+
+	limiter := newCommandLimiter(5)
+	now := time.Unix(1000, 0)
+	for i := 0; i < minCommandBurst; i++ {
+		if !limiter.allow(now) {
+			t.Fatalf("expected burst command %d to be allowed", i+1)
+		}
+	}
+	if limiter.allow(now) {
+		t.Fatal("expected command beyond the burst to be rejected")
+	}
+
+	// 1초 뒤에는 초당 허용량만큼 다시 허용
+	now = now.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		if !limiter.allow(now) {
+			t.Fatalf("expected refilled command %d to be allowed", i+1)
+		}
+	}
+	if limiter.allow(now) {
+		t.Error("expected command beyond the refill to be rejected")
+	}
+}
+
+func TestCommandFloodClosesSession(t *testing.T) {
+	s, conn := newTestPlayer(0)
+	s.commandLimiter = newCommandLimiter(5)
+	events := make(chan interface{}, 100)
+	s.externalChannel = events
+
+	// OBS 발행 시퀀스는 버스트 안에서 통과
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live"}))
+	s.handleAMF0Command(newTestCommand(t, "releaseStream", 2.0, nil, "test"))
+	s.handleAMF0Command(newTestCommand(t, "FCPublish", 3.0, nil, "test"))
+	s.handleAMF0Command(newTestCommand(t, "createStream", 4.0, nil))
+	s.handleAMF0Command(newTestCommand(t, "_checkbw", 5.0, nil))
+	if s.commandFlooded || conn.closed {
+		t.Fatal("expected the publish sequence to stay within the burst")
+	}
+
+	// createStream 폭주
+	for i := 0; i < 100; i++ {
+		s.handleAMF0Command(newTestCommand(t, "createStream", float64(6+i), nil))
+	}
+	if !s.commandFlooded || !conn.closed {
+		t.Fatalf("expected command flood to close the connection, flooded=%t closed=%t", s.commandFlooded, conn.closed)
+	}
+	if s.commandSeq != minCommandBurst+1 {
+		t.Errorf("expected commands after the flood to be dropped undecoded, got command seq %d", s.commandSeq)
+	}
+	if len(s.createdStreams) > minCommandBurst {
+		t.Errorf("expected at most %d streams created, got %d", minCommandBurst, len(s.createdStreams))
+	}
+}
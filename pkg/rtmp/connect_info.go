@@ -0,0 +1,64 @@
+package rtmp
+
+import (
+	"math"
+	"net"
+)
+
+// ConnectInfo는 connect 명령 객체에 담긴 클라이언트 정보 (통계, 클라이언트별 호환성 처리용)
+// 클라이언트가 보내지 않은 필드는 빈 값
+type ConnectInfo struct {
+	App      string
+	FlashVer string // 클라이언트 종류와 버전 (예: "FMLE/3.0 (compatible; FMSc/1.0)", "LNX 9,0,124,2")
+	SwfUrl   string // 플레이어 SWF 주소
+	PageUrl  string // 플레이어가 포함된 웹 페이지 주소
+	TcUrl    string // 클라이언트가 접속한 서버 주소 (예: "rtmp://host/live")
+
+	// 클라이언트가 지원하는 코덱 비트 (audioCodecs: SUPPORT_SND_*, videoCodecs: SUPPORT_VID_*, 0이면 알리지 않음)
+	AudioCodecs uint16
+	VideoCodecs uint16
+}
+
+// connectInfoFromCommand는 connect 명령 객체에서 클라이언트 정보를 추출 (타입이 맞지 않는 값은 무시)
+func connectInfoFromCommand(commandObj map[string]any) ConnectInfo {
+	field := func(key string) string {
+		value, _ := commandObj[key].(string)
+		return value
+	}
+	flags := func(key string) uint16 {
+		value, _ := commandObj[key].(float64)
+		if value < 0 || value > math.MaxUint16 {
+			return 0
+		}
+		return uint16(value)
+	}
+	return ConnectInfo{
+		App:         field("app"),
+		FlashVer:    field("flashVer"),
+		SwfUrl:      field("swfUrl"),
+		PageUrl:     field("pageUrl"),
+		TcUrl:       field("tcUrl"),
+		AudioCodecs: flags("audioCodecs"),
+		VideoCodecs: flags("videoCodecs"),
+	}
+}
+
+// ConnectAuthenticator는 connect 명령을 받을 때 연결 허용 여부를 결정
+// 에러를 반환하면 NetConnection.Connect.Rejected로 응답하고 연결을 끊는다
+type ConnectAuthenticator interface {
+	AuthenticateConnect(remoteAddr net.Addr, info ConnectInfo) error
+}
+
+// ConnectAuthenticatorFunc는 함수를 ConnectAuthenticator로 사용하기 위한 어댑터
+type ConnectAuthenticatorFunc func(remoteAddr net.Addr, info ConnectInfo) error
+
+// AuthenticateConnect는 f(remoteAddr, info)를 호출
+func (f ConnectAuthenticatorFunc) AuthenticateConnect(remoteAddr net.Addr, info ConnectInfo) error {
+	return f(remoteAddr, info)
+}
+
+// ConnectInfo는 connect 명령에서 받은 클라이언트 정보를 반환 (connect 전에는 빈 값)
+// connect는 publish/play 이벤트보다 먼저 처리되므로 그 이후 이벤트 루프에서도 조회할 수 있다
+func (s *session) ConnectInfo() ConnectInfo {
+	return s.connectInfo
+}
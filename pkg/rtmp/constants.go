@@ -18,6 +18,17 @@ const (
 	MSG_TYPE_AMF0_COMMAND       = 20
 )
 
+// User Control 메시지 이벤트 타입
+const (
+	USER_CONTROL_STREAM_BEGIN      = 0
+	USER_CONTROL_STREAM_EOF        = 1
+	USER_CONTROL_STREAM_DRY        = 2
+	USER_CONTROL_SET_BUFFER_LENGTH = 3 // 클라이언트 → 서버: 스트림 ID(4바이트)와 버퍼 길이(밀리초, 4바이트)
+	USER_CONTROL_STREAM_RECORDED   = 4
+	USER_CONTROL_PING_REQUEST      = 6
+	USER_CONTROL_PING_RESPONSE     = 7
+)
+
 // 청크 스트림 ID 상수
 const (
 	CHUNK_STREAM_PROTOCOL = 2 // 프로토콜 제어 메시지 (Set Chunk Size 등)
@@ -25,6 +36,10 @@ const (
 	CHUNK_STREAM_AUDIO    = 4 // 오디오 데이터
 	CHUNK_STREAM_VIDEO    = 5 // 비디오 데이터
 	CHUNK_STREAM_SCRIPT   = 6 // 스크립트 데이터 (onMetaData 등)
+
+	// 메시지 스트림 ID가 1보다 큰 스트림의 오디오/비디오/스크립트에 차례로 할당하는 청크 스트림 ID 범위
+	CHUNK_STREAM_DYNAMIC_START = 8
+	CHUNK_STREAM_MAX           = 65599 // 3바이트 basic header로 표현 가능한 최대값
 )
 
 // RTMP 버전
@@ -39,10 +54,45 @@ const (
 
 // 기본 청크 크기
 const (
-	DEFAULT_CHUNK_SIZE = 128
-	MAX_CHUNK_SIZE     = 65536
+	DEFAULT_CHUNK_SIZE     = 128
+	MAX_CHUNK_SIZE         = 65536
+	DEFAULT_MIN_CHUNK_SIZE = 128 // 이보다 작은 Set Chunk Size는 연결 종료 (청크당 헤더 오버헤드를 이용한 DoS 방지)
 )
 
+// 메시지 크기 제한 (헤더에 선언된 길이 기준, 최대 24비트 = 16MB)
+const (
+	DEFAULT_MAX_MESSAGE_SIZE = 8 * 1024 * 1024
+	MAX_MESSAGE_LENGTH       = 0xFFFFFF
+)
+
+// 이벤트 채널 버퍼 크기 기본값
+// 크게 잡으면 채널마다 메모리를 더 쓰는 대신 이벤트 루프가 잠시 밀려도 이벤트를 드롭하지 않고 버틴다
+const (
+	DEFAULT_EVENT_CHANNEL_SIZE   = 100 // 서버 이벤트 채널 (모든 세션이 공유)
+	DEFAULT_SESSION_CHANNEL_SIZE = 10  // 세션별 메시지 채널
+	MAX_EVENT_CHANNEL_SIZE       = 1 << 20
+)
+
+// 연결 수신 버퍼 크기 (청크 헤더를 바이트 단위로 읽을 때 시스템 호출을 줄이기 위한 bufio 버퍼)
+const (
+	DEFAULT_READ_BUFFER_SIZE = 16 * 1024
+	MAX_READ_BUFFER_SIZE     = 1 << 20
+)
+
+// 클라이언트가 Acknowledgement를 보내지 않고 쌓아 둘 수 있는 미확인 바이트 (Window Acknowledgement Size의 배수)
+// ack는 윈도우만큼 받은 뒤에야 오고 오는 동안에도 계속 보내므로 여유를 둔다
+const DEFAULT_ACK_STALL_FACTOR = 4
+
+// 유휴 스트림 캐시 압축 시 이보다 작은 캐시는 압축하지 않는 기본 크기 (sol 설정 기본값)
+const DEFAULT_IDLE_CACHE_MIN_BYTES = 64 * 1024
+
+// 원본 바이트 덤프의 세션별 기본 예산 (이를 넘는 송수신 바이트는 기록하지 않음)
+const DEFAULT_BYTE_DUMP_LIMIT = 1 << 20
+
+// 메시지 조립 버퍼를 미리 할당하는 최대 크기
+// 선언된 길이가 이보다 크면 데이터가 도착하는 만큼 늘린다 (큰 길이만 선언하고 데이터를 보내지 않는 연결의 메모리 점유 방지)
+const MAX_PREALLOCATED_PAYLOAD_SIZE = 64 * 1024
+
 // 확장 타임스탬프 임계값
 const (
 	EXTENDED_TIMESTAMP_THRESHOLD = 0xFFFFFF
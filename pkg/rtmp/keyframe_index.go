@@ -0,0 +1,86 @@
+package rtmp
+
+import "sort"
+
+// keyframeIndex는 키프레임의 타임스탬프 → 버퍼 위치 인덱스 (DVR 탐색용)
+// 위치는 버퍼에 추가된 순서대로 매긴 번호라서 앞쪽 프레임이 제거되어도 바뀌지 않는다
+// (비디오 캐시는 프레임 번호, 녹화 파일이라면 바이트 오프셋처럼 버퍼마다 위치의 의미를 정한다)
+type keyframeIndex struct {
+	entries []keyframeEntry // 타임스탬프 순
+}
+
+// keyframeEntry는 인덱스에 기록된 키프레임 하나
+type keyframeEntry struct {
+	timestamp uint32
+	position  int
+}
+
+// add는 버퍼 끝에 추가된 키프레임을 기록
+func (x *keyframeIndex) add(timestamp uint32, position int) {
+	x.entries = append(x.entries, keyframeEntry{timestamp: timestamp, position: position})
+}
+
+// trim은 position보다 앞에 있는 키프레임을 제거 (버퍼 앞쪽이 제거된 경우)
+func (x *keyframeIndex) trim(position int) {
+	i := sort.Search(len(x.entries), func(i int) bool {
+		return x.entries[i].position >= position
+	})
+	x.entries = x.entries[i:]
+}
+
+// reset은 인덱스를 비운다
+func (x *keyframeIndex) reset() {
+	x.entries = nil
+}
+
+// seek는 timestamp 이하에서 가장 가까운 키프레임을 찾는다 (없으면 false)
+func (x *keyframeIndex) seek(timestamp uint32) (keyframeEntry, bool) {
+	i := sort.Search(len(x.entries), func(i int) bool {
+		return x.entries[i].timestamp > timestamp
+	})
+	if i == 0 {
+		return keyframeEntry{}, false
+	}
+	return x.entries[i-1], true
+}
+
+// appendFrame은 프레임을 캐시 끝에 추가하고 키프레임이면 인덱스에 기록
+func (c *VideoCache) appendFrame(frame VideoFrame) {
+	if frame.frameType == "key frame" || isVideoKeyFrame(frame.data) {
+		c.keyframes.add(frame.timestamp, c.evicted+len(c.gopFrames))
+	}
+	c.gopFrames = append(c.gopFrames, frame)
+}
+
+// dropFront는 캐시 앞쪽 n개 프레임을 제거
+func (c *VideoCache) dropFront(n int) {
+	c.gopFrames = c.gopFrames[n:]
+	c.evicted += n
+	c.keyframes.trim(c.evicted)
+}
+
+// clearFrames는 캐시된 GOP 프레임을 모두 제거 (sequence header는 유지)
+func (c *VideoCache) clearFrames() {
+	c.evicted += len(c.gopFrames)
+	c.gopFrames = make([]VideoFrame, 0)
+	c.keyframes.reset()
+}
+
+// seekKeyFrame은 timestamp 이하에서 가장 가까운 키프레임의 gopFrames 인덱스 (없으면 -1)
+func (c *VideoCache) seekKeyFrame(timestamp uint32) int {
+	entry, ok := c.keyframes.seek(timestamp)
+	if !ok {
+		return -1
+	}
+	return entry.position - c.evicted
+}
+
+// SeekTo는 캐시에서 timestamp 이하의 가장 가까운 키프레임 타임스탬프를 찾는다 (없으면 false)
+// RTMP seek와 RTSP Range처럼 버퍼 중간부터 재생을 시작할 때 디코딩 가능한 시작 위치
+func (s *Stream) SeekTo(timestamp uint32) (uint32, bool) {
+	i := s.videoCache.seekKeyFrame(timestamp)
+	if i < 0 {
+		return 0, false
+	}
+	return s.videoCache.gopFrames[i].timestamp, true
+}
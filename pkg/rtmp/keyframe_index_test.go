@@ -0,0 +1,80 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+// 100ms 간격 프레임, 1초마다 키프레임인 스트림을 end까지 발행
+func publishSyntheticGOPs(stream *Stream, end uint32) {
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	for ts := uint32(0); ts <= end; ts += 100 {
+		if ts%1000 == 0 {
+			stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "key frame", Data: testAVCKeyFrame})
+		} else {
+			stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "inter frame", Data: testAVCInterFrame})
+		}
+	}
+}
+
+func TestSeekToLandsOnPrecedingKeyFrame(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetCacheEviction(CacheEvictionDuration, 10*time.Second)
+	publishSyntheticGOPs(stream, 5900)
+
+	tests := []struct {
+		timestamp uint32
+		expected  uint32
+	}{
+		{0, 0},
+		{999, 0},
+		{1000, 1000},
+		{2550, 2000},
+		{5900, 5000},
+		{60000, 5000},
+	}
+	for _, tt := range tests {
+		keyFrame, ok := stream.SeekTo(tt.timestamp)
+		if !ok || keyFrame != tt.expected {
+			t.Errorf("seek to %d: expected key frame %d, got %d (ok %t)", tt.timestamp, tt.expected, keyFrame, ok)
+		}
+	}
+}
+
+func TestSeekToFollowsCacheEviction(t *testing.T) {
+	// 2초 구간만 유지하면 앞쪽 GOP가 제거되어도 인덱스 위치가 캐시와 맞아야 함
+	stream := NewStream("live/test", 10, 0)
+	stream.SetCacheEviction(CacheEvictionDuration, 2*time.Second)
+	publishSyntheticGOPs(stream, 9500)
+
+	if _, ok := stream.SeekTo(7900); ok {
+		t.Fatal("expected no key frame before the cached range")
+	}
+	for _, ts := range []uint32{8000, 8400, 8999, 9500} {
+		keyFrame, ok := stream.SeekTo(ts)
+		if !ok || keyFrame != ts/1000*1000 {
+			t.Fatalf("seek to %d: expected key frame %d, got %d (ok %t)", ts, ts/1000*1000, keyFrame, ok)
+		}
+		i := stream.videoCache.seekKeyFrame(ts)
+		if frame := stream.videoCache.gopFrames[i]; frame.timestamp != keyFrame || frame.frameType != "key frame" {
+			t.Fatalf("seek to %d: expected cache position of key frame %d, got %+v", ts, keyFrame, frame)
+		}
+	}
+
+	// 프레임 수 정책은 새 GOP마다 캐시를 비우므로 현재 GOP의 키프레임만 남음
+	stream = NewStream("live/test", 5, 0)
+	publishSyntheticGOPs(stream, 2300)
+	if _, ok := stream.SeekTo(1500); ok {
+		t.Fatal("expected the previous GOP to be gone with the frames policy")
+	}
+	if keyFrame, ok := stream.SeekTo(2300); !ok || keyFrame != 2000 {
+		t.Fatalf("expected key frame 2000, got %d (ok %t)", keyFrame, ok)
+	}
+	// gopCacheSize를 넘어 키프레임이 캐시 앞에서 잘리면 인덱스에서도 빠짐
+	for _, ts := range []uint32{2400, 2500} {
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "inter frame", Data: testAVCInterFrame})
+	}
+	if _, ok := stream.SeekTo(2500); ok {
+		t.Fatal("expected key frame to be dropped with the trimmed cache")
+	}
+}
@@ -0,0 +1,16 @@
+package rtmp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelTrace는 청크/메시지 단위 로그 레벨 (Debug보다 한 단계 낮음)
+// 로그 레벨 구분: 세션/스트림 생명주기와 sequence header는 Info, 미디어 프레임마다는 Debug,
+// 청크와 메시지마다는 Trace (운영 트래픽에서는 초당 수천 줄이 되므로 Debug에서도 숨김)
+const LevelTrace = slog.LevelDebug - 4
+
+// traceLog는 LevelTrace로 로그를 남긴다 (레벨이 꺼져 있으면 레코드를 만들지 않음)
+func traceLog(msg string, args ...any) {
+	slog.Log(context.Background(), LevelTrace, msg, args...)
+}
@@ -0,0 +1,65 @@
+package rtmp
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLogs는 기본 로거를 level 이상만 기록하는 버퍼 로거로 바꾼다 (테스트 종료 시 복원)
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// readTestMessages는 오디오 메시지 여러 개를 청크로 읽고 세션에서 처리
+func readTestMessages(t *testing.T) {
+	t.Helper()
+	var data []byte
+	for i := 0; i < 3; i++ {
+		data = append(data, oversizedChunkHeader(4)...)
+		data = append(data, 1, 2, 3, 4)
+	}
+
+	s, _ := newTestPlayer(1)
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		msg, err := s.reader.readNextMessage(r)
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		s.handleMessage(msg)
+	}
+}
+
+// 청크/메시지마다 남기는 로그 메시지
+var hotPathLogMessages = []string{"msg=\"read chunk\"", "msg=fmt", "msg=chunkStreamId", "msg=Fmt0MessageHeade", "msg=\"receive message\""}
+
+func TestHotPathLogsSuppressedAtInfo(t *testing.T) {
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		logs := captureLogs(t, level)
+		readTestMessages(t)
+
+		for _, message := range hotPathLogMessages {
+			if strings.Contains(logs.String(), message) {
+				t.Fatalf("expected %s to be suppressed at %s, got logs:\n%s", message, level, logs.String())
+			}
+		}
+	}
+}
+
+func TestHotPathLogsAtTraceLevel(t *testing.T) {
+	logs := captureLogs(t, LevelTrace)
+	readTestMessages(t)
+
+	for _, message := range hotPathLogMessages {
+		if !strings.Contains(logs.String(), message) {
+			t.Fatalf("expected %s at trace level, got logs:\n%s", message, logs.String())
+		}
+	}
+}
@@ -7,9 +7,40 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"sync"
+	"sync/atomic"
 )
 
+// ErrMessageTooLarge는 선언된 메시지 길이가 최대 메시지 크기를 넘는 경우의 에러
+var ErrMessageTooLarge = errors.New("message length exceeds maximum message size")
+
+// ErrChunkSizeTooSmall는 Set Chunk Size 값이 허용 최소 크기보다 작은 경우의 에러
+var ErrChunkSizeTooSmall = errors.New("chunk size below minimum")
+
+// ProtocolError는 클라이언트가 보낸 청크가 RTMP 명세를 어긴 경우의 에러
+// 연결 종료(EOF) 같은 I/O 에러는 감싸지 않고 그대로 반환하므로 errors.As로 둘을 구분한다
+type ProtocolError struct {
+	Err error
+}
+
+func (e *ProtocolError) Error() string {
+	return "rtmp protocol violation: " + e.Err.Error()
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// protocolErrorf는 형식화한 메시지로 ProtocolError를 만든다 (%w로 감싼 에러는 errors.Is로 찾을 수 있음)
+func protocolErrorf(format string, args ...any) error {
+	return &ProtocolError{Err: fmt.Errorf(format, args...)}
+}
+
+// IsProtocolError는 err가 프로토콜 위반인지 확인 (아니면 I/O 에러)
+func IsProtocolError(err error) bool {
+	var protocolErr *ProtocolError
+	return errors.As(err, &protocolErr)
+}
+
 type messageReader struct {
 	readerContext *messageReaderContext
 }
@@ -23,11 +54,25 @@ func newMessageReader() *messageReader {
 
 func handshake(rw io.ReadWriter) error {
 	// C0
+	c0, err := readC0(rw)
+	if err != nil {
+		return err
+	}
+	return handshakeAfterC0(rw, c0)
+}
+
+// readC0은 핸드셰이크의 첫 바이트(C0, RTMP 버전)를 읽는다
+func readC0(r io.Reader) (byte, error) {
 	c0 := make([]byte, 1)
-	if _, err := io.ReadFull(rw, c0); err != nil {
-		return fmt.Errorf("failed to read C0: %w", err)
+	if _, err := io.ReadFull(r, c0); err != nil {
+		return 0, fmt.Errorf("failed to read C0: %w", err)
 	}
+	return c0[0], nil
+}
 
+// handshakeAfterC0은 이미 읽은 C0로 나머지 핸드셰이크를 진행
+func handshakeAfterC0(rw io.ReadWriter, version byte) error {
+	c0 := []byte{version}
 	if c0[0] != RTMP_VERSION {
 		return fmt.Errorf("unsupported RTMP version: %d", c0[0])
 	}
@@ -71,13 +116,26 @@ func (ms *messageReader) setChunkSize(size uint32) {
 	ms.readerContext.setChunkSize(size)
 }
 
+// setMaxMessageSize는 조립할 수 있는 최대 메시지 길이를 설정 (0이면 기본값)
+func (ms *messageReader) setMaxMessageSize(size uint32) {
+	if size == 0 {
+		size = DEFAULT_MAX_MESSAGE_SIZE
+	}
+	ms.readerContext.maxMessageSize = size
+}
+
+// enableLengthValidation은 메시지 조립 시 선언된 길이 검증을 켬 (디버그용, 불일치 횟수는 mismatches에 누적)
+func (ms *messageReader) enableLengthValidation(mismatches *atomic.Uint64) {
+	ms.readerContext.lengthMismatches = mismatches
+}
+
 func (ms *messageReader) readNextMessage(r io.Reader) (*Message, error) {
 	for {
 		chunk, err := ms.readChunk(r)
 		if err != nil {
 			return nil, err
 		}
-		slog.Info("read chunk", "chunk.messageHeader", chunk.messageHeader)
+		traceLog("read chunk", "chunk.messageHeader", chunk.messageHeader)
 
 		message, err := ms.readerContext.popMessageIfPossible()
 		if err == nil {
@@ -89,26 +147,46 @@ func (ms *messageReader) readNextMessage(r io.Reader) (*Message, error) {
 func (ms *messageReader) readChunk(r io.Reader) (*Chunk, error) {
 	basicHeader, err := readBasicHeader(r)
 	if err != nil {
-		slog.Error("Failed to read basic header", "err", err)
 		return nil, err
 	}
 
-	messageHeader, err := readMessageHeader(r, basicHeader.fmt, ms.readerContext.getMsgHeader(basicHeader.chunkStreamID))
+	// Fmt1/2/3은 이전 헤더를 이어받으므로 같은 청크 스트림의 Fmt0이 먼저 와야 함
+	previousHeader := ms.readerContext.getMsgHeader(basicHeader.chunkStreamID)
+	if basicHeader.fmt != 0 && previousHeader == nil {
+		return nil, protocolErrorf("chunk stream %d sent a fmt %d header without a previous message header", basicHeader.chunkStreamID, basicHeader.fmt)
+	}
+
+	messageHeader, err := readMessageHeader(r, basicHeader.fmt, previousHeader)
 	if err != nil {
 		return nil, err
 	}
 
+	// 선언된 길이만큼 버퍼링하기 전에 크기 제한 검사 (메모리 고갈 방지)
+	if messageHeader.length > ms.readerContext.maxMessageSize {
+		return nil, protocolErrorf("%w: chunk stream %d declared %d bytes (max %d)",
+			ErrMessageTooLarge, basicHeader.chunkStreamID, messageHeader.length, ms.readerContext.maxMessageSize)
+	}
+
+	// 새 메시지 헤더인데 이전 메시지가 덜 조립되어 있으면 진단 (검증이 켜진 경우만)
+	if basicHeader.fmt != 3 {
+		ms.readerContext.checkIncompleteMessage(basicHeader.chunkStreamID)
+	}
+
 	// 모든 경우에 헤더를 업데이트 (Fmt1/2/3의 경우 상속받은 완전한 헤더로 업데이트)
 	ms.readerContext.updateMsgHeader(basicHeader.chunkStreamID, messageHeader)
 
-	payload, err := readPayload(r, ms.readerContext.bufferPool, ms.readerContext.nextChunkSize(basicHeader.chunkStreamID))
+	// 길이 0 메시지는 읽을 페이로드가 없으므로 바로 완성 (빈 청크를 붙이지 않아 핸들러는 빈 페이로드를 받음)
+	if messageHeader.length == 0 {
+		ms.readerContext.startEmptyMessage(basicHeader.chunkStreamID)
+		return NewChunk(basicHeader, messageHeader, nil), nil
+	}
+
+	payload, err := ms.readerContext.readPayload(r, basicHeader.chunkStreamID, ms.readerContext.nextChunkSize(basicHeader.chunkStreamID))
 	if err != nil {
 		return nil, err
 	}
 
-	ms.readerContext.appendPayload(basicHeader.chunkStreamID, payload)
-
-	slog.Info("msg", "messageHeader", messageHeader.Timestamp)
+	traceLog("msg", "messageHeader", messageHeader.Timestamp)
 
 	return NewChunk(basicHeader, messageHeader, payload), nil
 }
@@ -123,7 +201,7 @@ func readBasicHeader(r io.Reader) (*basicHeader, error) {
 	firstByte := buf[0] & 0x3F
 	var chunkStreamId uint32
 
-	slog.Info("fmt", "fmt", format)
+	traceLog("fmt", "fmt", format)
 
 	switch firstByte {
 	case 0:
@@ -136,7 +214,7 @@ func readBasicHeader(r io.Reader) (*basicHeader, error) {
 		
 		// 범위 검증 (64-319)
 		if chunkStreamId > 319 {
-			return nil, fmt.Errorf("invalid chunk stream ID %d for 2-byte header (must be 64-319)", chunkStreamId)
+			return nil, protocolErrorf("invalid chunk stream ID %d for 2-byte header (must be 64-319)", chunkStreamId)
 		}
 		
 	case 1:
@@ -150,7 +228,7 @@ func readBasicHeader(r io.Reader) (*basicHeader, error) {
 		
 		// 범위 검증 (320-65599)
 		if chunkStreamId < 320 || chunkStreamId > 65599 {
-			return nil, fmt.Errorf("invalid chunk stream ID %d for 3-byte header (must be 320-65599)", chunkStreamId)
+			return nil, protocolErrorf("invalid chunk stream ID %d for 3-byte header (must be 320-65599)", chunkStreamId)
 		}
 		
 	default:
@@ -159,10 +237,10 @@ func readBasicHeader(r io.Reader) (*basicHeader, error) {
 		
 		// 유효한 범위 검증 (2-63)
 		if chunkStreamId < 2 {
-			return nil, fmt.Errorf("invalid chunk stream ID %d (must be >= 2)", chunkStreamId)
+			return nil, protocolErrorf("invalid chunk stream ID %d (must be >= 2)", chunkStreamId)
 		}
 		
-		slog.Info("chunkStreamId", "chunkStreamId", chunkStreamId)
+		traceLog("chunkStreamId", "chunkStreamId", chunkStreamId)
 	}
 
 	return newBasicHeader(format, chunkStreamId), nil
@@ -179,7 +257,7 @@ func readMessageHeader(r io.Reader, fmt byte, header *messageHeader) (*messageHe
 	case 3:
 		return readFmt3MessageHeader(r, header)
 	}
-	return nil, errors.New("fmt must be 0-3")
+	return nil, protocolErrorf("invalid chunk header fmt %d (must be 0-3)", fmt)
 }
 
 func readFmt0MessageHeader(r io.Reader, header *messageHeader) (*messageHeader, error) {
@@ -214,7 +292,7 @@ func readFmt0MessageHeader(r io.Reader, header *messageHeader) (*messageHeader,
 		}
 	}
 
-	slog.Info("Fmt0MessageHeade", "timestamp", timestamp, "MessageLength", length, "MessageTypeID", typeId, "MessageStreamID", streamId)
+	traceLog("Fmt0MessageHeade", "timestamp", timestamp, "MessageLength", length, "MessageTypeID", typeId, "MessageStreamID", streamId)
 
 	return newMessageHeader(timestamp, length, typeId, streamId), nil
 }
@@ -303,21 +381,6 @@ func readExtendedTimestamp(r io.Reader) (uint32, error) {
 	return binary.BigEndian.Uint32(buf[:]), nil
 }
 
-func readPayload(r io.Reader, bufferPool *sync.Pool, size uint32) ([]byte, error) {
-	buf := bufferPool.Get().([]byte)[:size]
-	if _, err := io.ReadFull(r, buf); err != nil {
-		bufferPool.Put(buf[:cap(buf)]) // 오류 시에도 버퍼 반환
-		return nil, err
-	}
-
-	// 데이터를 복사해서 반환 (버퍼 풀 안전성 보장)
-	result := make([]byte, size)
-	copy(result, buf)
-	bufferPool.Put(buf[:cap(buf)]) // 버퍼 풀에 반환
-
-	return result, nil
-}
-
 func readUint24BE(buf []byte) uint32 {
 	return uint32(buf[0])<<16 | uint32(buf[1])<<8 | uint32(buf[2])
 }
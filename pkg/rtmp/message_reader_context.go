@@ -1,41 +1,87 @@
 package rtmp
 
 import (
-	"fmt"
+	"encoding/hex"
+	"errors"
+	"io"
 	"log/slog"
-	"sync"
+	"sync/atomic"
 )
 
+// 길이 불일치 진단 로그에 덤프할 최대 페이로드 바이트 수
+const lengthMismatchDumpSize = 32
+
+// errNoCompleteMessage는 아직 완성된 메시지가 없는 경우 (청크마다 반환되므로 새로 만들지 않음)
+var errNoCompleteMessage = errors.New("no complete message available")
+
 type messageReaderContext struct {
 	messageHeaders map[uint32]*messageHeader
-	payloads       map[uint32][][]byte
-	payloadLengths map[uint32]uint32
+	// 청크 스트림별로 조립 중인 메시지 (청크를 메시지 버퍼에 바로 읽어 넣으므로 청크마다 할당/복사하지 않음)
+	// 완성된 버퍼는 그대로 메시지 페이로드가 되어 캐시와 플레이어 전송에 복사 없이 쓰인다
+	payloads       map[uint32][]byte
 	chunkSize      uint32
-	bufferPool     *sync.Pool
+	maxMessageSize uint32 // 조립할 수 있는 최대 메시지 길이
+
+	// 디버그용 메시지 길이 검증 (nil이면 비활성화, 불일치 횟수 누적)
+	lengthMismatches *atomic.Uint64
 }
 
 func newMessageReaderContext() *messageReaderContext {
 	return &messageReaderContext{
 		messageHeaders: make(map[uint32]*messageHeader),
-		payloads:       make(map[uint32][][]byte),
-		payloadLengths: make(map[uint32]uint32),
+		payloads:       make(map[uint32][]byte),
 		chunkSize:      DEFAULT_CHUNK_SIZE,
-		bufferPool:     NewBufferPool(DEFAULT_CHUNK_SIZE),
+		maxMessageSize: DEFAULT_MAX_MESSAGE_SIZE,
 	}
 }
 
 func (mrc *messageReaderContext) setChunkSize(size uint32) {
 	mrc.chunkSize = size
-	mrc.bufferPool = NewBufferPool(mrc.chunkSize)
 }
 
 func (ms *messageReaderContext) updateMsgHeader(chunkStreamId uint32, messageHeader *messageHeader) {
 	ms.messageHeaders[chunkStreamId] = messageHeader
 }
 
-func (ms *messageReaderContext) appendPayload(chunkStreamId uint32, payload []byte) {
-	ms.payloads[chunkStreamId] = append(ms.payloads[chunkStreamId], payload)
-	ms.payloadLengths[chunkStreamId] = ms.payloadLengths[chunkStreamId] + uint32(len(payload))
+// readPayload는 size 바이트를 조립 중인 메시지 버퍼 끝에 바로 읽어 넣고, 읽은 부분을 반환
+// 첫 청크에서 선언된 길이만큼 (최대 MAX_PREALLOCATED_PAYLOAD_SIZE) 버퍼를 잡는다
+func (ms *messageReaderContext) readPayload(r io.Reader, chunkStreamId uint32, size uint32) ([]byte, error) {
+	buf, ok := ms.payloads[chunkStreamId]
+	if !ok {
+		length := ms.messageHeaders[chunkStreamId].length
+		buf = make([]byte, 0, min(length, MAX_PREALLOCATED_PAYLOAD_SIZE))
+	}
+	buf = ms.growPayload(chunkStreamId, buf, size)
+
+	start := len(buf)
+	buf = buf[:start+int(size)]
+	if _, err := io.ReadFull(r, buf[start:]); err != nil {
+		return nil, err
+	}
+	ms.payloads[chunkStreamId] = buf
+	return buf[start:], nil
+}
+
+// growPayload는 버퍼에 size 바이트를 더 넣을 수 있게 늘린다
+// 두 배씩 늘리되 선언된 길이를 넘기지 않아, 메시지가 완성되면 버퍼가 길이에 딱 맞는다
+func (ms *messageReaderContext) growPayload(chunkStreamId uint32, buf []byte, size uint32) []byte {
+	needed := len(buf) + int(size)
+	if needed <= cap(buf) {
+		return buf
+	}
+	newCap := max(2*cap(buf), needed)
+	if length := int(ms.messageHeaders[chunkStreamId].length); newCap > length && needed <= length {
+		newCap = length
+	}
+	grown := make([]byte, len(buf), newCap)
+	copy(grown, buf)
+	return grown
+}
+
+// startEmptyMessage는 길이 0 메시지를 페이로드 없이 완성된 상태로 등록
+// (새 헤더가 왔으므로 덜 조립된 이전 페이로드는 버림)
+func (ms *messageReaderContext) startEmptyMessage(chunkStreamId uint32) {
+	ms.payloads[chunkStreamId] = []byte{}
 }
 
 func (ms *messageReaderContext) isInitialChunk(chunkStreamId uint32) bool {
@@ -49,7 +95,7 @@ func (ms *messageReaderContext) nextChunkSize(chunkStreamId uint32) uint32 {
 		slog.Warn("message header not found", "chunkStreamId", chunkStreamId)
 		return 0
 	}
-	currentLength := ms.payloadLengths[chunkStreamId]
+	currentLength := uint32(len(ms.payloads[chunkStreamId]))
 	remain := header.length - currentLength
 	if remain > ms.chunkSize {
 		return ms.chunkSize
@@ -67,13 +113,15 @@ func (ms *messageReaderContext) getMsgHeader(chunkStreamId uint32) *messageHeade
 
 func (ms *messageReaderContext) popMessageIfPossible() (*Message, error) {
 	for chunkStreamId, messageHeader := range ms.messageHeaders {
-		payloadLength, ok := ms.payloadLengths[chunkStreamId]
+		payload, ok := ms.payloads[chunkStreamId]
 		if !ok {
 			continue
 		}
+		payloadLength := uint32(len(payload))
 
-		payload, ok := ms.payloads[chunkStreamId]
-		if !ok {
+		// 조립된 길이가 선언된 길이를 넘으면 영원히 완성되지 않으므로 버림
+		if ms.lengthMismatches != nil && payloadLength > messageHeader.length {
+			ms.reportLengthMismatch(chunkStreamId, messageHeader, "payload exceeds declared length")
 			continue
 		}
 
@@ -81,19 +129,49 @@ func (ms *messageReaderContext) popMessageIfPossible() (*Message, error) {
 			continue
 		}
 
-		msg := NewMessage(messageHeader, payload)
-		delete(ms.payloadLengths, chunkStreamId)
+		// 조립된 버퍼 하나를 그대로 페이로드로 사용 (길이 0 메시지는 청크 없음)
+		var chunks [][]byte
+		if len(payload) > 0 {
+			chunks = [][]byte{payload}
+		}
+		msg := NewMessage(messageHeader, chunks)
 		delete(ms.payloads, chunkStreamId)
 		return msg, nil
 
 	}
-	return nil, fmt.Errorf("no complete message available")
+	return nil, errNoCompleteMessage
 }
 
-func NewBufferPool(size uint32) *sync.Pool {
-	return &sync.Pool{
-		New: func() any {
-			return make([]byte, size)
-		},
+// checkIncompleteMessage는 새 메시지 헤더(fmt 0/1/2)가 도착했을 때 이전 메시지가
+// 선언된 길이만큼 조립되지 않은 채 남아 있는지 검사 (검증이 켜진 경우만)
+func (ms *messageReaderContext) checkIncompleteMessage(chunkStreamId uint32) {
+	if ms.lengthMismatches == nil || ms.isInitialChunk(chunkStreamId) {
+		return
+	}
+	header := ms.messageHeaders[chunkStreamId]
+	if header == nil {
+		return
 	}
+	ms.reportLengthMismatch(chunkStreamId, header, "new message started before previous one completed")
+}
+
+// reportLengthMismatch는 길이 불일치를 기록하고 상세 덤프를 남긴 뒤 조립 중인 페이로드를 버림
+func (ms *messageReaderContext) reportLengthMismatch(chunkStreamId uint32, header *messageHeader, reason string) {
+	ms.lengthMismatches.Add(1)
+
+	payload := ms.payloads[chunkStreamId]
+	head := payload[:min(len(payload), lengthMismatchDumpSize)]
+
+	slog.Error("Message length mismatch",
+		"reason", reason,
+		"chunkStreamId", chunkStreamId,
+		"typeId", header.typeId,
+		"streamId", header.streamId,
+		"timestamp", header.Timestamp,
+		"declaredLength", header.length,
+		"receivedLength", len(payload),
+		"chunkSize", ms.chunkSize,
+		"head", hex.EncodeToString(head))
+
+	delete(ms.payloads, chunkStreamId)
 }
@@ -2,9 +2,13 @@ package rtmp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type testReadWriter struct {
@@ -105,3 +109,347 @@ func TestHandshakeFailReadC2(t *testing.T) {
 		t.Fatal("expected error but got nil")
 	}
 }
+
+// Fmt0 헤더로 선언된 길이만 지정한 청크 (페이로드 없음)
+func oversizedChunkHeader(length uint32) []byte {
+	return []byte{
+		0x03,             // fmt 0, chunk stream 3
+		0x00, 0x00, 0x00, // timestamp
+		byte(length >> 16), byte(length >> 8), byte(length), // message length
+		MSG_TYPE_AMF0_COMMAND,  // type ID
+		0x00, 0x00, 0x00, 0x00, // stream ID
+	}
+}
+
+func TestReadNextMessageRejectsOversizedLength(t *testing.T) {
+	reader := newMessageReader()
+	reader.setMaxMessageSize(1024)
+
+	_, err := reader.readNextMessage(bytes.NewReader(oversizedChunkHeader(MAX_MESSAGE_LENGTH)))
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestReadNextMessageAcceptsLengthAtLimit(t *testing.T) {
+	reader := newMessageReader()
+	reader.setMaxMessageSize(4)
+
+	data := append(oversizedChunkHeader(4), 1, 2, 3, 4)
+	msg, err := reader.readNextMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if msg.messageHeader.length != 4 {
+		t.Fatalf("expected length 4, got %d", msg.messageHeader.length)
+	}
+}
+
+func TestLengthValidationReportsIncompleteMessage(t *testing.T) {
+	var mismatches atomic.Uint64
+	reader := newMessageReader()
+	reader.enableLengthValidation(&mismatches)
+
+	// 200바이트로 선언했지만 첫 청크(128바이트)만 보낸 뒤 같은 청크 스트림에서 새 메시지 시작
+	data := append(oversizedChunkHeader(200), make([]byte, DEFAULT_CHUNK_SIZE)...)
+	data = append(data, oversizedChunkHeader(4)...)
+	data = append(data, 1, 2, 3, 4)
+
+	msg, err := reader.readNextMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if got := mismatches.Load(); got != 1 {
+		t.Fatalf("expected 1 length mismatch, got %d", got)
+	}
+	if msg.messageHeader.length != 4 || !bytes.Equal(concatChunks(msg.payload), []byte{1, 2, 3, 4}) {
+		t.Fatalf("expected the following message to be assembled intact, got %d bytes", msg.messageHeader.length)
+	}
+}
+
+func TestLengthValidationQuietForValidMessages(t *testing.T) {
+	var mismatches atomic.Uint64
+	reader := newMessageReader()
+	reader.enableLengthValidation(&mismatches)
+
+	// 두 청크로 나뉜 200바이트 메시지 (fmt 3 연속 청크)
+	data := append(oversizedChunkHeader(200), make([]byte, DEFAULT_CHUNK_SIZE)...)
+	data = append(data, 0xC3)
+	data = append(data, make([]byte, 200-DEFAULT_CHUNK_SIZE)...)
+
+	msg, err := reader.readNextMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if msg.messageHeader.length != 200 {
+		t.Fatalf("expected length 200, got %d", msg.messageHeader.length)
+	}
+	if got := mismatches.Load(); got != 0 {
+		t.Fatalf("expected no length mismatch, got %d", got)
+	}
+}
+
+func TestReadNextMessageZeroLengthMessage(t *testing.T) {
+	reader := newMessageReader()
+
+	// 길이 0 메시지 다음에 같은 청크 스트림에서 fmt 3으로 같은 헤더의 길이 0 메시지, 이어서 일반 메시지
+	data := oversizedChunkHeader(0)
+	data = append(data, 0xC3)
+	data = append(data, oversizedChunkHeader(4)...)
+	data = append(data, 1, 2, 3, 4)
+	r := bytes.NewReader(data)
+
+	for i := 0; i < 2; i++ {
+		msg, err := reader.readNextMessage(r)
+		if err != nil {
+			t.Fatalf("expected zero-length message %d, got error: %v", i, err)
+		}
+		if msg.messageHeader.length != 0 || len(msg.payload) != 0 {
+			t.Fatalf("expected empty payload for message %d, got length %d with %d chunks", i, msg.messageHeader.length, len(msg.payload))
+		}
+	}
+
+	msg, err := reader.readNextMessage(r)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !bytes.Equal(concatChunks(msg.payload), []byte{1, 2, 3, 4}) {
+		t.Fatalf("expected the following message to be assembled intact, got %v", msg.payload)
+	}
+}
+
+func TestZeroLengthMessageDropsIncompletePayload(t *testing.T) {
+	reader := newMessageReader()
+
+	// 200바이트 메시지의 첫 청크만 보낸 뒤 같은 청크 스트림에서 길이 0 메시지 시작
+	data := append(oversizedChunkHeader(200), make([]byte, DEFAULT_CHUNK_SIZE)...)
+	data = append(data, oversizedChunkHeader(0)...)
+
+	msg, err := reader.readNextMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if msg.messageHeader.length != 0 || len(msg.payload) != 0 {
+		t.Fatalf("expected empty zero-length message, got length %d with %d chunks", msg.messageHeader.length, len(msg.payload))
+	}
+}
+
+// chunkedMessage는 length 바이트 메시지를 chunkSize 단위의 fmt 0 + fmt 3 청크로 나눈 바이트열
+func chunkedMessage(typeId byte, length, chunkSize int) []byte {
+	data := []byte{
+		0x04,             // fmt 0, chunk stream 4
+		0x00, 0x00, 0x00, // timestamp
+		byte(length >> 16), byte(length >> 8), byte(length), // message length
+		typeId,                 // type ID
+		0x01, 0x00, 0x00, 0x00, // stream ID
+	}
+	for offset := 0; offset < length; offset += chunkSize {
+		if offset > 0 {
+			data = append(data, 0xC4) // fmt 3, chunk stream 4
+		}
+		for i := offset; i < min(offset+chunkSize, length); i++ {
+			data = append(data, byte(i))
+		}
+	}
+	return data
+}
+
+func TestReadNextMessageAssemblesChunksIntoOneBuffer(t *testing.T) {
+	for _, length := range []int{100, 4096, 3 * MAX_PREALLOCATED_PAYLOAD_SIZE} {
+		reader := newMessageReader()
+		msg, err := reader.readNextMessage(bytes.NewReader(chunkedMessage(MSG_TYPE_VIDEO, length, DEFAULT_CHUNK_SIZE)))
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		// 청크마다 따로 할당하지 않고 메시지 길이에 딱 맞는 버퍼 하나로 조립
+		if len(msg.payload) != 1 || len(msg.payload[0]) != length || cap(msg.payload[0]) != length {
+			t.Fatalf("expected one %d byte buffer, got %d chunks", length, len(msg.payload))
+		}
+		for i, b := range msg.payload[0] {
+			if b != byte(i) {
+				t.Fatalf("expected byte %d to be %d, got %d", i, byte(i), b)
+			}
+		}
+	}
+}
+
+func TestReadNextMessageDoesNotReuseDeliveredPayload(t *testing.T) {
+	reader := newMessageReader()
+	data := append(chunkedMessage(MSG_TYPE_VIDEO, 300, DEFAULT_CHUNK_SIZE), chunkedMessage(MSG_TYPE_VIDEO, 300, DEFAULT_CHUNK_SIZE)...)
+	r := bytes.NewReader(data)
+
+	first, err := reader.readNextMessage(r)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	expected := bytes.Clone(first.payload[0])
+
+	// 캐시에 남은 페이로드는 다음 메시지 조립에 덮어쓰이면 안 됨
+	first.payload[0][0] = 0xFF
+	second, err := reader.readNextMessage(r)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if second.payload[0][0] != expected[0] || !bytes.Equal(second.payload[0][1:], expected[1:]) {
+		t.Fatal("expected the second message to be assembled in its own buffer")
+	}
+	if first.payload[0][0] != 0xFF {
+		t.Fatal("expected the first payload to be left untouched")
+	}
+}
+
+func TestReadNextMessageAcrossChunkSizeChanges(t *testing.T) {
+	reader := newMessageReader()
+
+	// 청크 크기를 줄였다가 다시 늘려도 각 크기의 메시지를 그대로 조립
+	for _, chunkSize := range []int{4096, DEFAULT_CHUNK_SIZE, 16, 8192, 64, 65536} {
+		reader.setChunkSize(uint32(chunkSize))
+		msg, err := reader.readNextMessage(bytes.NewReader(chunkedMessage(MSG_TYPE_VIDEO, 10000, chunkSize)))
+		if err != nil {
+			t.Fatalf("chunk size %d: expected no error but got: %v", chunkSize, err)
+		}
+		if len(msg.payload) != 1 || len(msg.payload[0]) != 10000 || msg.payload[0][9999] != byte(9999%256) {
+			t.Fatalf("chunk size %d: expected a 10000 byte payload", chunkSize)
+		}
+	}
+
+	// 작은 청크로 조립 중인 메시지가 있을 때 청크 크기가 커지면 남은 부분이 한 번에 들어온다
+	reader.setChunkSize(64)
+	partial := chunkedMessage(MSG_TYPE_VIDEO, 1000, 64)
+	control := []byte{
+		0x02,             // fmt 0, chunk stream 2
+		0x00, 0x00, 0x00, // timestamp
+		0x00, 0x00, 0x04, // message length
+		MSG_TYPE_SET_CHUNK_SIZE,
+		0x00, 0x00, 0x00, 0x00, // stream ID
+		0x00, 0x00, 0x10, 0x00, // chunk size 4096
+	}
+	rest := []byte{0xC4} // fmt 3, chunk stream 4
+	for i := 64; i < 1000; i++ {
+		rest = append(rest, byte(i))
+	}
+	r := bytes.NewReader(append(append(partial[:12+64:12+64], control...), rest...))
+
+	msg, err := reader.readNextMessage(r)
+	if err != nil || msg.messageHeader.typeId != MSG_TYPE_SET_CHUNK_SIZE {
+		t.Fatalf("expected Set Chunk Size first, got %v (err: %v)", msg, err)
+	}
+	reader.setChunkSize(4096)
+	msg, err = reader.readNextMessage(r)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(msg.payload[0]) != 1000 {
+		t.Fatalf("expected a 1000 byte payload, got %d", len(msg.payload[0]))
+	}
+	for i, b := range msg.payload[0] {
+		if b != byte(i) {
+			t.Fatalf("expected byte %d to be %d, got %d", i, byte(i), b)
+		}
+	}
+}
+
+func BenchmarkReadVideoFrame(b *testing.B) {
+	for _, chunkSize := range []int{DEFAULT_CHUNK_SIZE, 4096} {
+		b.Run(fmt.Sprintf("chunk%d", chunkSize), func(b *testing.B) {
+			reader := newMessageReader()
+			reader.setChunkSize(uint32(chunkSize))
+			frame := chunkedMessage(MSG_TYPE_VIDEO, 32*1024, chunkSize)
+			r := bytes.NewReader(frame)
+
+			b.SetBytes(int64(len(frame)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Reset(frame)
+				if _, err := reader.readNextMessage(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadNextMessageErrorCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		protocol bool
+		ioErr    error // 프로토콜 위반이 아니면 그대로 전달되어야 하는 I/O 에러
+		reason   string
+	}{
+		{"client disconnect", nil, false, io.EOF, CloseReasonClientClosed},
+		{"disconnect inside header", []byte{0x03, 0x00, 0x00}, false, io.ErrUnexpectedEOF, CloseReasonReadError},
+		{"disconnect inside 3-byte basic header", []byte{0x01, 0x40}, false, io.ErrUnexpectedEOF, CloseReasonReadError},
+		{"3-byte header for chunk stream 64", []byte{0x01, 0x00, 0x00}, true, nil, CloseReasonProtocolError},
+		{"3-byte header for chunk stream 319", []byte{0x01, 0xFF, 0x00}, true, nil, CloseReasonProtocolError},
+		{"fmt 1 without previous header", []byte{0x43, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, MSG_TYPE_AUDIO}, true, nil, CloseReasonProtocolError},
+		{"fmt 3 without previous header", []byte{0xC3, 0x00}, true, nil, CloseReasonProtocolError},
+		{"oversized message", oversizedChunkHeader(MAX_MESSAGE_LENGTH), true, nil, CloseReasonMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		reader := newMessageReader()
+		reader.setMaxMessageSize(1024)
+		_, err := reader.readNextMessage(bytes.NewReader(tt.input))
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+
+		var protocolErr *ProtocolError
+		if errors.As(err, &protocolErr) != tt.protocol || IsProtocolError(err) != tt.protocol {
+			t.Errorf("%s: expected protocol violation %t, got %v", tt.name, tt.protocol, err)
+		}
+		if tt.ioErr != nil && err != tt.ioErr && !errors.Is(err, tt.ioErr) {
+			t.Errorf("%s: expected I/O error %v to be passed through, got %v", tt.name, tt.ioErr, err)
+		}
+		if reason := closeReasonForReadError(err); reason != tt.reason {
+			t.Errorf("%s: expected close reason %s, got %s", tt.name, tt.reason, reason)
+		}
+	}
+
+	// 크기 초과는 프로토콜 위반이면서 ErrMessageTooLarge로도 구분됨
+	_, err := newMessageReader().readNextMessage(bytes.NewReader(oversizedChunkHeader(MAX_MESSAGE_LENGTH)))
+	if !IsProtocolError(err) || !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected oversized message to be a protocol violation wrapping ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestProtocolViolationsCounted(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	// 핸드셰이크 후 chunk stream 64를 3바이트 헤더로 보내는 클라이언트와 그냥 끊는 클라이언트
+	for _, trailer := range [][]byte{{0x01, 0x00, 0x00}, nil} {
+		serverConn, clientConn := net.Pipe()
+		server.newSessionWithChannel(serverConn)
+		clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+		handshakeBytes := append([]byte{RTMP_VERSION}, make([]byte, HANDSHAKE_SIZE*2)...)
+		if _, err := clientConn.Write(handshakeBytes[:1+HANDSHAKE_SIZE]); err != nil {
+			t.Fatalf("failed to write C0+C1: %v", err)
+		}
+		if _, err := io.ReadFull(clientConn, make([]byte, 1+HANDSHAKE_SIZE*2)); err != nil {
+			t.Fatalf("failed to read handshake response: %v", err)
+		}
+		if _, err := clientConn.Write(append(make([]byte, HANDSHAKE_SIZE), trailer...)); err != nil {
+			t.Fatalf("failed to write C2: %v", err)
+		}
+		if trailer != nil {
+			// 서버가 위반을 감지하고 연결을 닫음
+			if _, err := clientConn.Read(make([]byte, 1)); err == nil {
+				t.Fatal("expected server to close the connection")
+			}
+		}
+		clientConn.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.GetProtocolErrorCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := server.GetProtocolErrorCount(); got != 1 {
+		t.Fatalf("expected 1 protocol violation, got %d", got)
+	}
+}
@@ -2,27 +2,61 @@ package rtmp
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"sol/pkg/amf"
+	"sync"
+	"time"
 )
 
+// ErrChunkStreamIDsExhausted는 메시지 스트림별로 할당할 청크 스트림 ID가 남지 않았을 때 반환
+var ErrChunkStreamIDsExhausted = errors.New("no chunk stream ID left to allocate")
+
+// chunkStreamKey는 청크 스트림 ID를 할당하는 단위 (메시지 스트림 ID, 메시지 타입의 기본 청크 스트림 ID)
+type chunkStreamKey struct {
+	streamId    uint32
+	baseChunkID byte
+}
+
 type messageWriter struct {
 	chunkSize uint32
+
+	// 청크 인코딩 시간 기록 (nil이면 기록하지 않음)
+	timings *eventTimings
+
+	// 청크 스트림별 마지막으로 보낸 메시지 헤더 (Type 1/2 델타 헤더 계산용)
+	// 세션 goroutine과 이벤트 루프가 같은 연결에 쓰므로 메시지 단위로 잠가서
+	// 상대가 받는 순서와 헤더 상태가 어긋나지 않도록 한다
+	mu          sync.Mutex
+	lastHeaders map[uint32]messageHeader
+
+	// 메시지 스트림 ID가 1보다 큰 스트림에 할당한 청크 스트림 ID
+	// 여러 스트림의 비디오가 같은 청크 스트림을 쓰면 델타 헤더가 섞이므로 스트림마다 따로 쓴다
+	chunkStreams    map[chunkStreamKey]uint32
+	nextChunkStream uint32
 }
 
 func newMessageWriter() *messageWriter {
 	return &messageWriter{
-		chunkSize: DEFAULT_CHUNK_SIZE,
+		chunkSize:       DEFAULT_CHUNK_SIZE,
+		lastHeaders:     make(map[uint32]messageHeader),
+		chunkStreams:    make(map[chunkStreamKey]uint32),
+		nextChunkStream: CHUNK_STREAM_DYNAMIC_START,
 	}
 }
 
 // 공통 메시지 쓰기 함수 - 모든 RTMP 메시지는 이 함수를 통해 전송
 func (mw *messageWriter) writeMessage(w io.Writer, msg *Message) error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	start := time.Now()
 	chunks, err := mw.buildChunks(msg)
 	if err != nil {
 		return err
 	}
+	mw.timings.observeEncode(start)
 
 	// 모든 청크를 순차적으로 전송 (zero-copy)
 	for _, chunk := range chunks {
@@ -42,9 +76,14 @@ func (mw *messageWriter) buildChunks(msg *Message) ([]*Chunk, error) {
 		totalPayloadLength += len(chunk)
 	}
 
+	chunkStreamID, err := mw.chunkStreamID(msg.messageHeader.typeId, msg.messageHeader.streamId)
+	if err != nil {
+		return nil, err
+	}
+
 	if totalPayloadLength == 0 {
 		// 페이로드가 없는 메시지 (예: Set Chunk Size)
-		return []*Chunk{mw.buildFirstChunk(msg, 0, 0, totalPayloadLength)}, nil
+		return []*Chunk{mw.buildFirstChunk(msg, chunkStreamID, 0, 0, totalPayloadLength)}, nil
 	}
 
 	var chunks []*Chunk
@@ -58,11 +97,11 @@ func (mw *messageWriter) buildChunks(msg *Message) ([]*Chunk, error) {
 		}
 
 		if offset == 0 {
-			// 첫 번째 청크: Full header (fmt=0)
-			chunks = append(chunks, mw.buildFirstChunk(msg, offset, chunkSize, totalPayloadLength))
+			// 첫 번째 청크: 이전 메시지와 비교해 fmt=0/1/2 헤더
+			chunks = append(chunks, mw.buildFirstChunk(msg, chunkStreamID, offset, chunkSize, totalPayloadLength))
 		} else {
 			// 나머지 청크: Type 3 header (fmt=3)
-			chunks = append(chunks, mw.buildContinuationChunk(msg, offset, chunkSize))
+			chunks = append(chunks, mw.buildContinuationChunk(msg, chunkStreamID, offset, chunkSize))
 		}
 
 		offset += chunkSize
@@ -139,23 +178,57 @@ func getChunkStreamIDForMessageType(messageType byte) byte {
 	}
 }
 
-// 첫 번째 청크 생성 (fmt=0 - full header)
-func (mw *messageWriter) buildFirstChunk(msg *Message, offset, chunkSize, totalPayloadLength int) *Chunk {
-	// 확장 타임스탬프 처리
-	headerTimestamp := msg.messageHeader.Timestamp
-	if msg.messageHeader.Timestamp >= EXTENDED_TIMESTAMP_THRESHOLD {
-		headerTimestamp = EXTENDED_TIMESTAMP_THRESHOLD
+// chunkStreamID는 메시지를 보낼 청크 스트림 ID를 결정
+// 제어/명령 메시지와 메시지 스트림 0, 1은 타입별 고정 ID를 쓰고
+// 그 밖의 스트림은 (메시지 스트림 ID, 타입)마다 CHUNK_STREAM_DYNAMIC_START부터 새 ID를 할당
+func (mw *messageWriter) chunkStreamID(messageType byte, streamId uint32) (uint32, error) {
+	base := getChunkStreamIDForMessageType(messageType)
+	if streamId <= 1 || base == CHUNK_STREAM_PROTOCOL || base == CHUNK_STREAM_COMMAND {
+		return uint32(base), nil
 	}
 
-	// 메시지 타입에 따라 청크 스트림 ID 결정
-	chunkStreamID := getChunkStreamIDForMessageType(msg.messageHeader.typeId)
-	basicHdr := newBasicHeader(FMT_TYPE_0, uint32(chunkStreamID))
-	msgHdr := newMessageHeader(
-		headerTimestamp,
-		uint32(totalPayloadLength),
-		msg.messageHeader.typeId,
-		msg.messageHeader.streamId,
-	)
+	key := chunkStreamKey{streamId: streamId, baseChunkID: base}
+	if id, ok := mw.chunkStreams[key]; ok {
+		return id, nil
+	}
+	if mw.nextChunkStream > CHUNK_STREAM_MAX {
+		return 0, fmt.Errorf("%w (stream %d, message type %d)", ErrChunkStreamIDsExhausted, streamId, messageType)
+	}
+	id := mw.nextChunkStream
+	mw.nextChunkStream++
+	mw.chunkStreams[key] = id
+	return id, nil
+}
+
+// 첫 번째 청크 생성
+// 오디오/비디오는 같은 청크 스트림의 이전 메시지와 스트림 ID가 같고 타임스탬프가 증가하면 델타 헤더 사용
+// (길이/타입도 같으면 fmt=2 - 3바이트, 다르면 fmt=1 - 7바이트, 그 외와 명령/제어 메시지는 fmt=0 - 11바이트)
+// fmt=1/2의 messageHeader.Timestamp는 이전 메시지와의 타임스탬프 차이
+func (mw *messageWriter) buildFirstChunk(msg *Message, chunkStreamID uint32, offset, chunkSize, totalPayloadLength int) *Chunk {
+	current := messageHeader{
+		Timestamp: msg.messageHeader.Timestamp,
+		length:    uint32(totalPayloadLength),
+		typeId:    msg.messageHeader.typeId,
+		streamId:  msg.messageHeader.streamId,
+	}
+
+	format := byte(FMT_TYPE_0)
+	headerTimestamp := current.Timestamp
+	if previous, ok := mw.lastHeaders[chunkStreamID]; ok && isMediaMessage(current.typeId) &&
+		previous.streamId == current.streamId &&
+		current.Timestamp >= previous.Timestamp &&
+		current.Timestamp-previous.Timestamp < EXTENDED_TIMESTAMP_THRESHOLD {
+		headerTimestamp = current.Timestamp - previous.Timestamp
+		if previous.length == current.length && previous.typeId == current.typeId {
+			format = FMT_TYPE_2
+		} else {
+			format = FMT_TYPE_1
+		}
+	}
+	mw.lastHeaders[chunkStreamID] = current
+
+	basicHdr := newBasicHeader(format, chunkStreamID)
+	msgHdr := newMessageHeader(headerTimestamp, current.length, current.typeId, current.streamId)
 
 	// payload 슬라이스 (복사 없이 참조)
 	var payloadSlice []byte
@@ -166,11 +239,14 @@ func (mw *messageWriter) buildFirstChunk(msg *Message, offset, chunkSize, totalP
 	return NewChunk(basicHdr, msgHdr, payloadSlice)
 }
 
+// 델타 헤더를 사용하는 메시지 타입 (프레임마다 반복 전송되는 오디오/비디오)
+func isMediaMessage(typeId uint8) bool {
+	return typeId == MSG_TYPE_AUDIO || typeId == MSG_TYPE_VIDEO
+}
+
 // 연속 청크 생성 (fmt=3 - no header)
-func (mw *messageWriter) buildContinuationChunk(msg *Message, offset, chunkSize int) *Chunk {
-	// 메시지 타입에 따라 청크 스트림 ID 결정
-	chunkStreamID := getChunkStreamIDForMessageType(msg.messageHeader.typeId)
-	basicHdr := newBasicHeader(FMT_TYPE_3, uint32(chunkStreamID))
+func (mw *messageWriter) buildContinuationChunk(msg *Message, chunkStreamID uint32, offset, chunkSize int) *Chunk {
+	basicHdr := newBasicHeader(FMT_TYPE_3, chunkStreamID)
 
 	// Type 3는 message header가 없음
 	var msgHdr *messageHeader = nil
@@ -192,7 +268,7 @@ func (mw *messageWriter) writeChunk(w io.Writer, chunk *Chunk) error {
 	var needsExtendedTimestamp bool
 	var extendedTimestamp uint32
 	if chunk.messageHeader != nil {
-		if err := mw.writeMessageHeader(w, chunk.messageHeader); err != nil {
+		if err := mw.writeMessageHeader(w, chunk.basicHeader.fmt, chunk.messageHeader); err != nil {
 			return err
 		}
 		// 확장 타임스탬프가 필요한지 확인
@@ -254,13 +330,26 @@ func (mw *messageWriter) writeBasicHeader(w io.Writer, bh *basicHeader) error {
 	return err
 }
 
-// Message Header 인코딩 및 전송
-func (mw *messageWriter) writeMessageHeader(w io.Writer, mh *messageHeader) error {
+// Message Header 인코딩 및 전송 (fmt=0: 11바이트, fmt=1: 7바이트, fmt=2: 3바이트)
+// 타임스탬프(또는 델타)가 임계값 이상이면 임계값을 쓰고 실제 값은 확장 타임스탬프로 전송
+func (mw *messageWriter) writeMessageHeader(w io.Writer, format byte, mh *messageHeader) error {
+	timestamp := mh.Timestamp
+	if timestamp >= EXTENDED_TIMESTAMP_THRESHOLD {
+		timestamp = EXTENDED_TIMESTAMP_THRESHOLD
+	}
+
 	header := make([]byte, 11)
-	PutUint24(header[0:], mh.Timestamp)                    // 3 bytes timestamp
+	PutUint24(header[0:], timestamp)                       // 3 bytes timestamp (delta)
 	PutUint24(header[3:], mh.length)                       // 3 bytes message length
 	header[6] = mh.typeId                                  // 1 byte type ID
 	binary.LittleEndian.PutUint32(header[7:], mh.streamId) // 4 bytes stream ID
+
+	switch format {
+	case FMT_TYPE_1:
+		header = header[:7]
+	case FMT_TYPE_2:
+		header = header[:3]
+	}
 	_, err := w.Write(header)
 	return err
 }
@@ -288,6 +377,15 @@ func (mw *messageWriter) writeSetChunkSize(w io.Writer, chunkSize uint32) error
 	return nil
 }
 
+// Window Acknowledgement Size 전송 (클라이언트는 windowSize 바이트를 받을 때마다 Acknowledgement를 보낸다)
+func (mw *messageWriter) writeWindowAckSize(w io.Writer, windowSize uint32) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, windowSize)
+
+	header := newMessageHeader(0, 4, MSG_TYPE_WINDOW_ACK_SIZE, 0)
+	return mw.writeMessage(w, NewMessage(header, [][]byte{payload}))
+}
+
 func PutUint24(b []byte, v uint32) {
 	b[0] = byte((v >> 16) & 0xFF)
 	b[1] = byte((v >> 8) & 0xFF)
@@ -295,40 +393,55 @@ func PutUint24(b []byte, v uint32) {
 }
 
 // 오디오 데이터 전송 (zero-copy)
-func (mw *messageWriter) writeAudioData(w io.Writer, audioData [][]byte, timestamp uint32) error {
+func (mw *messageWriter) writeAudioData(w io.Writer, audioData [][]byte, timestamp uint32, streamID uint32) error {
 	// 전체 데이터 크기 계산
 	totalLength := 0
 	for _, chunk := range audioData {
 		totalLength += len(chunk)
 	}
 
-	header := newMessageHeader(timestamp, uint32(totalLength), MSG_TYPE_AUDIO, 0)
+	// 플레이어가 createStream으로 받은 스트림 ID
+	header := newMessageHeader(timestamp, uint32(totalLength), MSG_TYPE_AUDIO, streamID)
 	msg := NewMessage(header, audioData) // [][]byte 그대로 전달
 	return mw.writeMessage(w, msg)
 }
 
 // 비디오 데이터 전송 (zero-copy)
-func (mw *messageWriter) writeVideoData(w io.Writer, videoData [][]byte, timestamp uint32) error {
+func (mw *messageWriter) writeVideoData(w io.Writer, videoData [][]byte, timestamp uint32, streamID uint32) error {
 	// 전체 데이터 크기 계산
 	totalLength := 0
 	for _, chunk := range videoData {
 		totalLength += len(chunk)
 	}
 
-	header := newMessageHeader(timestamp, uint32(totalLength), MSG_TYPE_VIDEO, 0)
+	// 플레이어가 createStream으로 받은 스트림 ID
+	header := newMessageHeader(timestamp, uint32(totalLength), MSG_TYPE_VIDEO, streamID)
 	msg := NewMessage(header, videoData) // [][]byte 그대로 전달
 	return mw.writeMessage(w, msg)
 }
 
+// 데이터 메시지 원본 전송 (zero-copy, 발행자가 보낸 AMF0 payload 그대로)
+func (mw *messageWriter) writeDataMessage(w io.Writer, data [][]byte, timestamp uint32, streamID uint32) error {
+	totalLength := 0
+	for _, chunk := range data {
+		totalLength += len(chunk)
+	}
+
+	header := newMessageHeader(timestamp, uint32(totalLength), MSG_TYPE_AMF0_DATA, streamID)
+	msg := NewMessage(header, data)
+	return mw.writeMessage(w, msg)
+}
+
 // 메타데이터 전송
-func (mw *messageWriter) writeScriptData(w io.Writer, commandName string, metadata map[string]any) error {
+// 중간에 입장한 플레이어를 위해 현재 재생 위치의 타임스탬프와 플레이어의 스트림 ID로 전송
+func (mw *messageWriter) writeScriptData(w io.Writer, commandName string, metadata map[string]any, timestamp uint32, streamID uint32) error {
 	// AMF 데이터 인코딩
 	payload, err := amf.EncodeAMF0Sequence(commandName, metadata)
 	if err != nil {
 		return err
 	}
 
-	header := newMessageHeader(0, uint32(len(payload)), MSG_TYPE_AMF0_DATA, 0) // 메타데이터는 timestamp 0
+	header := newMessageHeader(timestamp, uint32(len(payload)), MSG_TYPE_AMF0_DATA, streamID)
 	msg := NewMessage(header, [][]byte{payload})
 	return mw.writeMessage(w, msg)
 }
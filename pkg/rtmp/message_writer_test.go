@@ -0,0 +1,340 @@
+package rtmp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 플레이어 세션 출력 캡처용 연결 (Write/Read/RemoteAddr/SetWriteDeadline만 지원)
+type bufferConn struct {
+	net.Conn
+	buf    bytes.Buffer
+	closed bool
+	remote net.Addr // nil이면 127.0.0.1:50000
+
+	reader *messageReader // readMessages용 (델타 헤더 해석을 위해 이전 메시지 헤더 유지)
+}
+
+func (c *bufferConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *bufferConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *bufferConn) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
+
+func (c *bufferConn) Read(p []byte) (int, error) {
+	return c.buf.Read(p)
+}
+
+func (c *bufferConn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}
+}
+
+func newTestPlayer(streamID uint32) (*session, *bufferConn) {
+	conn := &bufferConn{}
+	player := &session{
+		reader:   newMessageReader(),
+		writer:   newMessageWriter(),
+		conn:     conn,
+		streamID: streamID,
+	}
+	player.sessionId = "test-player"
+	player.playStreamID = streamID
+	return player, conn
+}
+
+// 캡처된 출력에서 모든 메시지를 읽어온다
+func readAllMessages(t *testing.T, data []byte) []*Message {
+	t.Helper()
+	reader := newMessageReader()
+	r := bytes.NewReader(data)
+	var messages []*Message
+	for r.Len() > 0 {
+		msg, err := reader.readNextMessage(r)
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// 캡처된 출력을 연결별 reader로 읽고 비운다
+// 오디오/비디오는 이전 메시지 기준 델타 헤더로 전송되므로 중간부터 읽으려면 이전 출력도 같은 reader로 읽어야 한다
+func (c *bufferConn) readMessages(t *testing.T) []*Message {
+	t.Helper()
+	if c.reader == nil {
+		c.reader = newMessageReader()
+	}
+	var messages []*Message
+	for c.buf.Len() > 0 {
+		msg, err := c.reader.readNextMessage(&c.buf)
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestWriteScriptDataTimestampAndStreamID(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newMessageWriter()
+
+	err := writer.writeScriptData(&buf, "onMetaData", map[string]any{"width": 1280.0}, 5000, 1)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	messages := readAllMessages(t, buf.Bytes())
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	header := messages[0].messageHeader
+	if header.typeId != MSG_TYPE_AMF0_DATA {
+		t.Errorf("expected type %d, got %d", MSG_TYPE_AMF0_DATA, header.typeId)
+	}
+	if header.Timestamp != 5000 {
+		t.Errorf("expected timestamp 5000, got %d", header.Timestamp)
+	}
+	if header.streamId != 1 {
+		t.Errorf("expected stream ID 1, got %d", header.streamId)
+	}
+}
+
+func TestForwardedMetadataCarriesStreamTimestamp(t *testing.T) {
+	for _, tt := range []struct {
+		stamp     bool
+		timestamp uint32
+		streamID  uint32
+	}{
+		{false, 0, 0}, // 기본값: 이전처럼 타임스탬프 0, 스트림 ID 0
+		{true, 3000, 1},
+	} {
+		stream := NewStream("live/test", 10, 0)
+		stream.SetMetadataStamping(tt.stamp)
+		player, conn := newTestPlayer(1)
+		stream.AddPlayer(player)
+
+		// 미디어가 진행된 후 메타데이터 수신
+		stream.ProcessVideoData(VideoData{Timestamp: 3000, FrameType: "key frame", Data: [][]byte{{0x17, 0x01}}})
+		stream.ProcessMetaData(MetaData{Metadata: map[string]any{"width": 1280.0}})
+
+		messages := readAllMessages(t, conn.buf.Bytes())
+		var metadata *Message
+		for _, msg := range messages {
+			if msg.messageHeader.typeId == MSG_TYPE_AMF0_DATA {
+				metadata = msg
+			}
+		}
+		if metadata == nil {
+			t.Fatalf("stamp %t: expected metadata message to be forwarded", tt.stamp)
+		}
+		if metadata.messageHeader.Timestamp != tt.timestamp {
+			t.Errorf("stamp %t: expected metadata timestamp %d, got %d", tt.stamp, tt.timestamp, metadata.messageHeader.Timestamp)
+		}
+		if metadata.messageHeader.streamId != tt.streamID {
+			t.Errorf("stamp %t: expected metadata stream ID %d, got %d", tt.stamp, tt.streamID, metadata.messageHeader.streamId)
+		}
+	}
+}
+
+func TestMediaUsesCreatedStreamID(t *testing.T) {
+	player, conn := newTestPlayer(0)
+	player.handleCreateStream([]any{"createStream", 2.0, nil})
+	if player.streamID == 0 {
+		t.Fatal("expected createStream to assign a stream ID")
+	}
+
+	// play가 보낸 PlayStarted를 서버가 처리 (세션의 스트림 ID는 이벤트로 전달됨)
+	server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+	defer server.cancel()
+	server.sessions[player.sessionId] = player
+	events := make(chan interface{}, 10)
+	player.externalChannel = events
+	player.appName = "live"
+	player.handlePlay([]any{"play", 3.0, nil, "test"})
+	for len(events) > 0 {
+		server.channelHandler(<-events)
+	}
+	conn.buf.Reset() // createStream/play 응답 제거
+
+	stream := server.GetStream("live/test")
+	stream.ProcessVideoData(VideoData{Timestamp: 40, FrameType: "key frame", Data: [][]byte{{0x17, 0x01}}})
+	stream.ProcessAudioData(AudioData{Timestamp: 40, Data: [][]byte{{0xAF, 0x01}}})
+
+	messages := readAllMessages(t, conn.buf.Bytes())
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	for _, msg := range messages {
+		if msg.messageHeader.streamId != player.streamID {
+			t.Errorf("message type %d: expected stream ID %d, got %d",
+				msg.messageHeader.typeId, player.streamID, msg.messageHeader.streamId)
+		}
+	}
+}
+
+// 출력에서 각 메시지 첫 청크의 fmt를 읽는다 (청크 크기보다 작은 1바이트 basic header 메시지만)
+func chunkFormats(t *testing.T, data []byte, headerSizes map[byte]int, payloadSize int) []byte {
+	t.Helper()
+	var formats []byte
+	for len(data) > 0 {
+		format := data[0] >> 6
+		size := 1 + headerSizes[format] + payloadSize
+		if len(data) < size {
+			t.Fatalf("truncated chunk: %d bytes left, need %d", len(data), size)
+		}
+		formats = append(formats, format)
+		data = data[size:]
+	}
+	return formats
+}
+
+func TestConsecutiveMediaUsesDeltaHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newMessageWriter()
+	frame := [][]byte{{0x27, 0x01, 0x00, 0x00}}
+	keyFrame := [][]byte{{0x17, 0x01, 0x00, 0x00}}
+
+	writer.writeVideoData(&buf, frame, 0, 1)                                // 첫 메시지: fmt=0
+	writer.writeVideoData(&buf, frame, 40, 1)                               // 길이/타입 동일: fmt=2
+	writer.writeVideoData(&buf, keyFrame, 80, 1)                            // 길이/타입 동일: fmt=2
+	writer.writeAudioData(&buf, frame, 80, 1)                               // 다른 청크 스트림의 첫 메시지: fmt=0
+	writer.writeVideoData(&buf, [][]byte{{0x27, 0x01, 0x00, 0x00}}, 120, 2) // 스트림 ID 변경: fmt=0
+	writer.writeVideoData(&buf, frame, 100, 2)                              // 타임스탬프 역행: fmt=0
+
+	formats := chunkFormats(t, buf.Bytes(), map[byte]int{0: 11, 1: 7, 2: 3}, 4)
+	expected := []byte{FMT_TYPE_0, FMT_TYPE_2, FMT_TYPE_2, FMT_TYPE_0, FMT_TYPE_0, FMT_TYPE_0}
+	if !bytes.Equal(formats, expected) {
+		t.Fatalf("expected formats %v, got %v", expected, formats)
+	}
+
+	// 델타 헤더로 보낸 메시지의 타임스탬프 복원 (역행한 마지막 메시지는 reader가 보정하므로 제외)
+	messages := readAllMessages(t, buf.Bytes())
+	timestamps := []uint32{0, 40, 80, 80, 120}
+	for i, timestamp := range timestamps {
+		if msg := messages[i]; msg.messageHeader.Timestamp != timestamp {
+			t.Errorf("message %d: expected timestamp %d, got %d", i, timestamp, msg.messageHeader.Timestamp)
+		}
+	}
+}
+
+func TestMediaLengthChangeUsesType1Header(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newMessageWriter()
+
+	writer.writeAudioData(&buf, [][]byte{{0xAF, 0x01, 0x00}}, 0, 1)
+	writer.writeAudioData(&buf, [][]byte{{0xAF, 0x01, 0x00, 0x00, 0x00}}, 23, 1)
+
+	data := buf.Bytes()
+	second := data[1+11+3:]
+	if format := second[0] >> 6; format != FMT_TYPE_1 {
+		t.Fatalf("expected fmt=1 for changed length, got %d", format)
+	}
+	if len(second) != 1+7+5 {
+		t.Fatalf("expected 7-byte message header, got %d bytes for the chunk", len(second))
+	}
+
+	messages := readAllMessages(t, data)
+	if len(messages) != 2 || messages[1].messageHeader.Timestamp != 23 || messages[1].messageHeader.length != 5 {
+		t.Fatalf("expected second message at 23ms with 5 bytes, got %+v", messages[1].messageHeader)
+	}
+}
+
+func TestCommandsAlwaysUseFullHeader(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newMessageWriter()
+	payload := []byte{0x05}
+
+	writer.writeCommand(&buf, payload)
+	writer.writeCommand(&buf, payload)
+
+	formats := chunkFormats(t, buf.Bytes(), map[byte]int{0: 11}, 1)
+	if !bytes.Equal(formats, []byte{FMT_TYPE_0, FMT_TYPE_0}) {
+		t.Fatalf("expected full headers for commands, got %v", formats)
+	}
+}
+
+func TestExtendedTimestampCarriesActualValue(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newMessageWriter()
+
+	writer.writeVideoData(&buf, [][]byte{{0x17, 0x01}}, 0x1000000, 1)
+
+	messages := readAllMessages(t, buf.Bytes())
+	if len(messages) != 1 || messages[0].messageHeader.Timestamp != 0x1000000 {
+		t.Fatalf("expected extended timestamp 0x1000000, got %+v", messages[0].messageHeader)
+	}
+}
+
+func TestVideoOfEachMessageStreamUsesOwnChunkStream(t *testing.T) {
+	mw := newMessageWriter()
+	var buf bytes.Buffer
+	payload := bytes.Repeat([]byte{0x27}, 200) // 청크 2개로 나뉘는 크기
+
+	// 두 스트림의 비디오를 번갈아 전송하고 각 메시지 첫 청크의 basic header를 기록
+	type sent struct {
+		streamID  uint32
+		timestamp uint32
+		header    byte
+	}
+	var writes []sent
+	for _, ts := range []uint32{0, 40, 80} {
+		for _, streamID := range []uint32{1, 2} {
+			start := buf.Len()
+			if err := mw.writeVideoData(&buf, [][]byte{payload}, ts, streamID); err != nil {
+				t.Fatalf("failed to write video: %v", err)
+			}
+			writes = append(writes, sent{streamID, ts, buf.Bytes()[start]})
+		}
+	}
+
+	expectedChunkStream := map[uint32]byte{1: CHUNK_STREAM_VIDEO, 2: CHUNK_STREAM_DYNAMIC_START}
+	for i, w := range writes {
+		if csid := w.header & 0x3F; csid != expectedChunkStream[w.streamID] {
+			t.Fatalf("expected stream %d video on chunk stream %d, got %d", w.streamID, expectedChunkStream[w.streamID], csid)
+		}
+		// 스트림마다 청크 스트림이 따로 있으므로 두 번째 프레임부터 델타 헤더를 쓸 수 있다
+		if format := w.header >> 6; i >= 2 && format != FMT_TYPE_2 {
+			t.Fatalf("expected delta header for stream %d at %d, got fmt=%d", w.streamID, w.timestamp, format)
+		}
+	}
+
+	messages := readAllMessages(t, buf.Bytes())
+	if len(messages) != len(writes) {
+		t.Fatalf("expected %d messages, got %d", len(writes), len(messages))
+	}
+	for i, msg := range messages {
+		if msg.messageHeader.streamId != writes[i].streamID || msg.messageHeader.Timestamp != writes[i].timestamp {
+			t.Fatalf("expected message %d on stream %d at %d, got stream %d at %d", i, writes[i].streamID, writes[i].timestamp, msg.messageHeader.streamId, msg.messageHeader.Timestamp)
+		}
+	}
+}
+
+func TestChunkStreamIDsExhausted(t *testing.T) {
+	mw := newMessageWriter()
+	mw.nextChunkStream = CHUNK_STREAM_MAX + 1
+
+	err := mw.writeVideoData(io.Discard, testAVCKeyFrame, 0, 2)
+	if !errors.Is(err, ErrChunkStreamIDsExhausted) {
+		t.Fatalf("expected ErrChunkStreamIDsExhausted, got %v", err)
+	}
+	// 고정 청크 스트림을 쓰는 스트림 1은 영향 없음
+	if err := mw.writeVideoData(io.Discard, testAVCKeyFrame, 0, 1); err != nil {
+		t.Fatalf("expected stream 1 to keep working, got %v", err)
+	}
+}
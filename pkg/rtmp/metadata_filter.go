@@ -0,0 +1,39 @@
+package rtmp
+
+import "slices"
+
+// MetadataFilter는 플레이어에게 전달할 onMetaData 키를 고르는 필터
+// (인코더 내부 필드, 송출 측 IP처럼 플레이어에게 보일 필요 없는 키를 제거)
+// Deny에 있는 키는 항상 빼고, Allow가 비어 있지 않으면 Allow에 있는 키만 전달
+// 스트림 캐시와 녹화에는 필터링하지 않은 원본을 그대로 사용
+type MetadataFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// IsEmpty는 걸러낼 키가 없는지 확인
+func (f MetadataFilter) IsEmpty() bool {
+	return len(f.Allow) == 0 && len(f.Deny) == 0
+}
+
+// allows는 키를 플레이어에게 전달하는지 확인
+func (f MetadataFilter) allows(key string) bool {
+	if slices.Contains(f.Deny, key) {
+		return false
+	}
+	return len(f.Allow) == 0 || slices.Contains(f.Allow, key)
+}
+
+// apply는 전달할 키만 담은 메타데이터를 반환 (필터가 비어 있으면 원본을 그대로 반환, 원본은 수정하지 않음)
+func (f MetadataFilter) apply(metadata map[string]any) map[string]any {
+	if f.IsEmpty() || metadata == nil {
+		return metadata
+	}
+	filtered := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		if f.allows(key) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
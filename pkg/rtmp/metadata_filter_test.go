@@ -0,0 +1,84 @@
+package rtmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 캡처된 출력에서 전달된 onMetaData 객체들을 읽어온다
+func readForwardedMetadata(t *testing.T, conn *bufferConn) []map[string]any {
+	t.Helper()
+	var forwarded []map[string]any
+	for _, values := range decodeAMF0Messages(t, conn.readMessages(t), MSG_TYPE_AMF0_DATA) {
+		if len(values) < 2 || values[0] != "onMetaData" {
+			continue
+		}
+		metadata, ok := values[1].(map[string]any)
+		if !ok {
+			t.Fatalf("expected metadata object, got %T", values[1])
+		}
+		forwarded = append(forwarded, metadata)
+	}
+	return forwarded
+}
+
+func testPublisherMetadata() map[string]any {
+	return map[string]any{
+		"width":         1280.0,
+		"height":        720.0,
+		"encoder":       "obs-output module (libobs version 30.0.0)",
+		"sourceAddress": "10.0.0.5",
+	}
+}
+
+func TestMetadataFilterDeniesKeys(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetMetadataFilter(MetadataFilter{Deny: []string{"encoder", "sourceAddress"}})
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+
+	stream.ProcessMetaData(MetaData{Metadata: testPublisherMetadata()})
+
+	forwarded := readForwardedMetadata(t, conn)
+	expected := map[string]any{"width": 1280.0, "height": 720.0}
+	if len(forwarded) != 1 || !reflect.DeepEqual(forwarded[0], expected) {
+		t.Fatalf("expected forwarded metadata %v, got %v", expected, forwarded)
+	}
+
+	// 캐시에는 원본 유지
+	if !reflect.DeepEqual(stream.GetMetadata(), testPublisherMetadata()) {
+		t.Fatalf("expected cached metadata to keep every key, got %v", stream.GetMetadata())
+	}
+}
+
+func TestMetadataFilterAllowsOnlyListedKeys(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetMetadataFilter(MetadataFilter{Allow: []string{"width", "height", "sourceAddress"}, Deny: []string{"sourceAddress"}})
+	stream.ProcessMetaData(MetaData{Metadata: testPublisherMetadata()})
+
+	// 나중에 입장한 플레이어가 받는 캐시된 메타데이터도 필터링
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+
+	forwarded := readForwardedMetadata(t, conn)
+	expected := map[string]any{"width": 1280.0, "height": 720.0}
+	if len(forwarded) != 1 || !reflect.DeepEqual(forwarded[0], expected) {
+		t.Fatalf("expected forwarded metadata %v, got %v", expected, forwarded)
+	}
+	if len(stream.GetMetadata()) != len(testPublisherMetadata()) {
+		t.Fatalf("expected cached metadata to keep every key, got %v", stream.GetMetadata())
+	}
+}
+
+func TestEmptyMetadataFilterForwardsEverything(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+
+	stream.ProcessMetaData(MetaData{Metadata: testPublisherMetadata()})
+
+	forwarded := readForwardedMetadata(t, conn)
+	if len(forwarded) != 1 || !reflect.DeepEqual(forwarded[0], testPublisherMetadata()) {
+		t.Fatalf("expected every metadata key to be forwarded, got %v", forwarded)
+	}
+}
@@ -0,0 +1,39 @@
+package rtmp
+
+import (
+	"fmt"
+	"io"
+)
+
+// policyFileRequest는 레거시 Flash 클라이언트가 RTMP 연결 전에 보내는 소켓 정책 파일 요청 (NUL 종료)
+// RTMP 핸드셰이크의 C0(버전 바이트) 대신 '<'로 시작하므로 첫 바이트로 구분할 수 있다
+const policyFileRequest = "<policy-file-request/>\x00"
+
+// DefaultCrossDomainPolicy는 정책 XML을 따로 지정하지 않은 경우 응답하는 정책 (모든 도메인, 모든 포트 허용)
+const DefaultCrossDomainPolicy = `<?xml version="1.0"?>
+<!DOCTYPE cross-domain-policy SYSTEM "/xml/dtds/cross-domain-policy.dtd">
+<cross-domain-policy>
+  <site-control permitted-cross-domain-policies="master-only"/>
+  <allow-access-from domain="*" to-ports="*"/>
+</cross-domain-policy>`
+
+// isPolicyRequestStart는 연결의 첫 바이트가 정책 파일 요청의 시작인지 확인
+func isPolicyRequestStart(first byte) bool {
+	return first == policyFileRequest[0]
+}
+
+// servePolicyRequest는 첫 바이트를 읽은 뒤 나머지 정책 파일 요청을 확인하고 정책 XML(NUL 종료)을 응답
+func servePolicyRequest(rw io.ReadWriter, policy string) error {
+	rest := make([]byte, len(policyFileRequest)-1)
+	if _, err := io.ReadFull(rw, rest); err != nil {
+		return fmt.Errorf("failed to read policy file request: %w", err)
+	}
+	if string(rest) != policyFileRequest[1:] {
+		return fmt.Errorf("invalid policy file request: %q", rest)
+	}
+
+	if _, err := io.WriteString(rw, policy+"\x00"); err != nil {
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	return nil
+}
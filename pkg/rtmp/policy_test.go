@@ -0,0 +1,44 @@
+package rtmp
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 정책 파일 요청을 보내고 서버가 연결을 닫을 때까지의 응답을 읽는다
+func requestPolicyFile(t *testing.T, config StreamConfig) []byte {
+	t.Helper()
+	server := NewServer(0, config, nil)
+	defer server.cancel()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+	server.newSessionWithChannel(serverConn)
+
+	// 서버가 첫 바이트만 읽고 연결을 닫을 수 있으므로 쓰기 실패는 무시
+	io.WriteString(clientConn, policyFileRequest)
+	response, err := io.ReadAll(clientConn)
+	if err != nil {
+		t.Fatalf("failed to read policy response: %v", err)
+	}
+	return response
+}
+
+func TestPolicyFileRequestServed(t *testing.T) {
+	policy := `<cross-domain-policy><allow-access-from domain="example.com" to-ports="1935"/></cross-domain-policy>`
+	response := requestPolicyFile(t, StreamConfig{CrossDomainPolicy: policy})
+
+	if string(response) != policy+"\x00" {
+		t.Fatalf("expected NUL-terminated policy XML, got %q", response)
+	}
+}
+
+func TestPolicyFileRequestIgnoredWhenDisabled(t *testing.T) {
+	// 비활성화 상태에서는 RTMP 버전이 아니므로 핸드셰이크 실패로 연결 종료
+	if response := requestPolicyFile(t, StreamConfig{}); len(response) != 0 {
+		t.Fatalf("expected no response without a configured policy, got %q", response)
+	}
+}
@@ -0,0 +1,52 @@
+package rtmp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// 마지막 플레이어가 나간 스트림의 프레즌스 TTL 만료를 확인하는 주기
+const presenceCheckInterval = time.Second
+
+// SetIdleHandler는 마지막 플레이어가 나간 뒤 프레즌스 TTL이 지나면 호출할 함수를 설정 (nil이면 호출하지 않음)
+// 풀(pull)로 가져오는 스트림이 보는 사람이 없을 때 업스트림 연결을 끊는 데 쓰며,
+// TTL 안에 플레이어가 다시 입장하면 타이머가 취소되어 호출하지 않는다
+func (s *Stream) SetIdleHandler(onIdle func()) {
+	s.onIdle = onIdle
+}
+
+// markIdle은 플레이어(대기 포함)가 모두 나갔으면 유휴 시작 시각을 기록 (이미 유휴면 유지)
+func (s *Stream) markIdle(now time.Time) {
+	if len(s.players) == 0 && len(s.pendingPlayers) == 0 && s.idleSince.IsZero() {
+		s.idleSince = now
+	}
+}
+
+// clearIdle은 플레이어가 입장하면 유휴 타이머를 취소
+func (s *Stream) clearIdle() {
+	s.idleSince = time.Time{}
+}
+
+// PresenceExpired는 마지막 플레이어가 나간 뒤 ttl이 지났는지 확인 (ttl이 0이면 항상 false)
+func (s *Stream) PresenceExpired(now time.Time, ttl time.Duration) bool {
+	return ttl > 0 && !s.idleSince.IsZero() && now.Sub(s.idleSince) >= ttl
+}
+
+// expirePresence는 유휴 타이머를 끝내고 유휴 핸들러를 호출 (다음 입장 후 다시 나갈 때까지 한 번만)
+func (s *Stream) expirePresence() {
+	s.idleSince = time.Time{}
+	if s.onIdle != nil {
+		s.onIdle()
+	}
+}
+
+// expireStreamPresence는 프레즌스 TTL이 지난 스트림의 유휴 핸들러를 호출 (이벤트 루프에서 주기적으로 호출)
+func (s *Server) expireStreamPresence(now time.Time) {
+	for streamName, stream := range s.streams {
+		if !stream.PresenceExpired(now, s.streamConfig.StreamPresenceTTL) {
+			continue
+		}
+		slog.Info("Stream presence TTL expired without players", "streamName", streamName, "ttl", s.streamConfig.StreamPresenceTTL)
+		stream.expirePresence()
+	}
+}
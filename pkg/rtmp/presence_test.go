@@ -0,0 +1,63 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceTTLStopsIdleStream(t *testing.T) {
+	const ttl = 30 * time.Second
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, StreamPresenceTTL: ttl}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	stream := server.GetStream("live/test")
+
+	// 풀 소스 대신 유휴 핸들러 호출 횟수를 센다
+	stopped := 0
+	stream.SetIdleHandler(func() { stopped++ })
+
+	player, _ := newTestPlayer(1)
+	stream.AddPlayer(player)
+	server.expireStreamPresence(time.Now().Add(time.Hour))
+	if stopped != 0 {
+		t.Fatal("expected a watched stream not to be stopped")
+	}
+
+	// TTL 안에 다시 입장하면 타이머가 취소됨
+	stream.RemovePlayer(player)
+	left := time.Now()
+	server.expireStreamPresence(left.Add(ttl / 2))
+	rejoined, _ := newTestPlayer(1)
+	stream.AddPlayer(rejoined)
+	server.expireStreamPresence(left.Add(2 * ttl))
+	if stopped != 0 {
+		t.Fatal("expected a rejoin within the TTL to keep the stream")
+	}
+
+	// 마지막 플레이어가 나가고 TTL이 지나면 한 번만 정지
+	stream.CleanupSession(rejoined)
+	left = time.Now()
+	server.expireStreamPresence(left.Add(ttl - time.Second))
+	if stopped != 0 {
+		t.Fatal("expected the stream to survive until the TTL")
+	}
+	server.expireStreamPresence(left.Add(ttl))
+	server.expireStreamPresence(left.Add(2 * ttl))
+	if stopped != 1 {
+		t.Fatalf("expected the idle stream to be stopped once after the TTL, got %d", stopped)
+	}
+}
+
+func TestPresenceTTLDisabled(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, _ := newTestPlayer(1)
+	stream.AddPlayer(player)
+	stream.RemovePlayer(player)
+
+	if stream.PresenceExpired(time.Now().Add(time.Hour), 0) {
+		t.Fatal("expected no presence expiry without a TTL")
+	}
+}
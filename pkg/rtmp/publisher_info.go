@@ -0,0 +1,41 @@
+package rtmp
+
+import (
+	"time"
+)
+
+// PublisherInfo는 스트림 발행자와 발행 시작 시각 (모니터링용)
+type PublisherInfo struct {
+	SessionID  string
+	RemoteAddr string
+	StartedAt  time.Time // 발행 시작 시각 (재연결 유예 후 이어서 발행하면 처음 시작한 시각 유지)
+}
+
+// Uptime은 now 기준 발행 시간 (발행 중이 아니면 0)
+func (p PublisherInfo) Uptime(now time.Time) time.Duration {
+	if p.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(p.StartedAt)
+}
+
+// PublisherInfo는 현재 발행자 정보를 반환 (발행자가 없으면 false, 이벤트 루프에서 호출)
+func (s *Stream) PublisherInfo() (PublisherInfo, bool) {
+	if s.publisher == nil {
+		return PublisherInfo{}, false
+	}
+	return s.publisherInfo, true
+}
+
+// recordPublisher는 발행자가 설정될 때 발행자 정보를 기록
+// 재연결 유예 중에 이어서 발행하면 스트림이 끊기지 않은 것으로 보고 시작 시각을 유지
+func (s *Stream) recordPublisher(publisher *session, now time.Time) {
+	startedAt := s.publisherInfo.StartedAt
+	if startedAt.IsZero() {
+		startedAt = now
+	}
+	s.publisherInfo = PublisherInfo{SessionID: publisher.sessionId, StartedAt: startedAt}
+	if publisher.conn != nil {
+		s.publisherInfo.RemoteAddr = publisher.conn.RemoteAddr().String()
+	}
+}
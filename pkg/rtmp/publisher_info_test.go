@@ -0,0 +1,49 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublisherInfoWhilePublishing(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	if _, ok := stream.PublisherInfo(); ok {
+		t.Fatal("expected no publisher info before publishing")
+	}
+
+	publisher, _ := newTestPlayer(1)
+	stream.SetPublisher(publisher)
+	info, ok := stream.PublisherInfo()
+	if !ok || info.SessionID != "test-player" || info.RemoteAddr != "127.0.0.1:50000" || info.StartedAt.IsZero() {
+		t.Fatalf("expected publisher identity, got %+v", info)
+	}
+
+	first := stream.CacheSummary()
+	time.Sleep(10 * time.Millisecond)
+	second := stream.CacheSummary()
+	if first.Publisher != info || second.Uptime <= first.Uptime || second.Uptime < 10*time.Millisecond {
+		t.Fatalf("expected uptime to advance, got %v then %v (%+v)", first.Uptime, second.Uptime, second.Publisher)
+	}
+
+	// 재연결 유예 후 이어서 발행하면 시작 시각 유지
+	stream.SuspendPublisher(time.Now())
+	if _, ok := stream.PublisherInfo(); ok {
+		t.Fatal("expected no publisher info while suspended")
+	}
+	reconnected, _ := newTestPlayer(1)
+	reconnected.sessionId = "reconnected"
+	stream.ResumePublisher()
+	stream.SetPublisher(reconnected)
+	if resumed, _ := stream.PublisherInfo(); resumed.SessionID != "reconnected" || !resumed.StartedAt.Equal(info.StartedAt) {
+		t.Fatalf("expected new session with original start time %v, got %+v", info.StartedAt, resumed)
+	}
+
+	stream.RemovePublisher()
+	if _, ok := stream.PublisherInfo(); ok || stream.CacheSummary().Uptime != 0 {
+		t.Fatal("expected publisher info to be cleared on removal")
+	}
+	stream.SetPublisher(publisher)
+	if restarted, _ := stream.PublisherInfo(); !restarted.StartedAt.After(info.StartedAt) {
+		t.Fatalf("expected a new start time after republishing, got %v", restarted.StartedAt)
+	}
+}
@@ -0,0 +1,138 @@
+package rtmp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 녹화 경로 템플릿의 기본값 (RecordPath 아래 스트림 전체 경로.flv, 템플릿 도입 전과 같은 위치)
+const DEFAULT_RECORD_PATH_TEMPLATE = "{path}.flv"
+
+// ErrUnsafeRecordPath는 스트림 이름 등 클라이언트가 정한 값이 녹화 경로를 벗어나게 할 수 있는 경우의 에러
+var ErrUnsafeRecordPath = errors.New("unsafe record path")
+
+// recordPathPlaceholders는 녹화 경로 템플릿에서 쓸 수 있는 자리표시자
+//
+//	{path}      스트림 전체 경로 ([vhost/]app/stream)
+//	{vhost}     가상 호스트 (사용하지 않으면 빈 값)
+//	{app}       앱 이름
+//	{stream}    스트림 이름
+//	{yyyy}      녹화 시작 연도 (4자리)
+//	{mm}        월 (2자리)
+//	{dd}        일 (2자리)
+//	{hh}        시 (2자리)
+//	{timestamp} 녹화 시작 시각 (Unix 초)
+var recordPathPlaceholders = map[string]bool{
+	"path": true, "vhost": true, "app": true, "stream": true,
+	"yyyy": true, "mm": true, "dd": true, "hh": true, "timestamp": true,
+}
+
+// ValidateRecordPathTemplate는 설정 로드 시 녹화 경로 템플릿을 검사
+// 알 수 없는 자리표시자, 닫히지 않은 중괄호, ".." 경로 요소를 거부하고,
+// 스트림마다 다른 파일이 되도록 {path} 또는 {stream}을 포함해야 한다
+func ValidateRecordPathTemplate(template string) error {
+	if template == "" {
+		return errors.New("empty template")
+	}
+	hasStream := false
+	rest := template
+	for {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			break
+		}
+		if rest[start] == '}' {
+			return fmt.Errorf("unmatched '}' in %q", template)
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return fmt.Errorf("unclosed '{' in %q", template)
+		}
+		name := rest[start+1 : start+end]
+		if !recordPathPlaceholders[name] {
+			return fmt.Errorf("unknown placeholder {%s} in %q", name, template)
+		}
+		if name == "path" || name == "stream" {
+			hasStream = true
+		}
+		rest = rest[start+end+1:]
+	}
+	if !hasStream {
+		return fmt.Errorf("template %q must contain {path} or {stream}", template)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(template), "/") {
+		if segment == ".." {
+			return fmt.Errorf("template %q must not contain '..'", template)
+		}
+	}
+	return nil
+}
+
+// checkRecordPathValue는 경로에 들어갈 클라이언트 값이 한 단계 아래 경로로만 풀리는지 확인
+// 구분자는 "/"만 허용하고, 빈 요소와 "." ".." 요소, 역슬래시, NUL은 거부
+func checkRecordPathValue(name, value string) error {
+	if strings.ContainsAny(value, "\\\x00") {
+		return fmt.Errorf("%w: %s %q", ErrUnsafeRecordPath, name, value)
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %s %q", ErrUnsafeRecordPath, name, value)
+		}
+	}
+	return nil
+}
+
+// expandRecordPath는 템플릿의 자리표시자를 채워 녹화 파일 경로를 만든다
+// 상대 경로 템플릿은 recordDir 아래에 두며, 결과가 recordDir을 벗어나면 에러
+func expandRecordPath(recordDir, template, vhost, app, stream string, now time.Time) (string, error) {
+	if err := checkRecordPathValue("app", app); err != nil {
+		return "", err
+	}
+	if err := checkRecordPathValue("stream", stream); err != nil {
+		return "", err
+	}
+	path := app + "/" + stream
+	if vhost != "" {
+		if err := checkRecordPathValue("vhost", vhost); err != nil {
+			return "", err
+		}
+		path = vhost + "/" + path
+	}
+
+	expanded := strings.NewReplacer(
+		"{path}", path,
+		"{vhost}", vhost,
+		"{app}", app,
+		"{stream}", stream,
+		"{yyyy}", fmt.Sprintf("%04d", now.Year()),
+		"{mm}", fmt.Sprintf("%02d", int(now.Month())),
+		"{dd}", fmt.Sprintf("%02d", now.Day()),
+		"{hh}", fmt.Sprintf("%02d", now.Hour()),
+		"{timestamp}", strconv.FormatInt(now.Unix(), 10),
+	).Replace(template)
+	expanded = filepath.FromSlash(expanded)
+
+	if filepath.IsAbs(expanded) {
+		return filepath.Clean(expanded), nil
+	}
+	full := filepath.Join(recordDir, expanded)
+	if rel, err := filepath.Rel(recordDir, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s escapes %s", ErrUnsafeRecordPath, expanded, recordDir)
+	}
+	return full, nil
+}
+
+// recordPathTemplate는 앱에 적용할 녹화 경로 템플릿 (앱별 템플릿, 전체 템플릿, 기본값 순)
+func (c StreamConfig) recordPathTemplate(app string) string {
+	if template, ok := c.AppRecordPathTemplates[app]; ok && template != "" {
+		return template
+	}
+	if c.RecordPathTemplate != "" {
+		return c.RecordPathTemplate
+	}
+	return DEFAULT_RECORD_PATH_TEMPLATE
+}
@@ -0,0 +1,82 @@
+package rtmp
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandRecordPath(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 7, 30, 0, 0, time.UTC)
+	dir := filepath.FromSlash("/var/recordings")
+
+	tests := []struct {
+		template string
+		vhost    string
+		expected string
+	}{
+		{DEFAULT_RECORD_PATH_TEMPLATE, "", "/var/recordings/live/cam1.flv"},
+		{DEFAULT_RECORD_PATH_TEMPLATE, "example.com", "/var/recordings/example.com/live/cam1.flv"},
+		{"{app}/{stream}/{yyyy}/{mm}/{dd}/{timestamp}.flv", "", "/var/recordings/live/cam1/2024/03/05/1709623800.flv"},
+		{"{stream}-{yyyy}{mm}{dd}{hh}.flv", "", "/var/recordings/cam1-2024030507.flv"},
+		{"/archive/{app}/{stream}.flv", "", "/archive/live/cam1.flv"},
+	}
+	for _, tt := range tests {
+		if err := ValidateRecordPathTemplate(tt.template); err != nil {
+			t.Fatalf("%s: expected valid template, got %v", tt.template, err)
+		}
+		path, err := expandRecordPath(dir, tt.template, tt.vhost, "live", "cam1", now)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.template, err)
+		}
+		if expected := filepath.FromSlash(tt.expected); path != expected {
+			t.Errorf("%s: expected %s, got %s", tt.template, expected, path)
+		}
+	}
+}
+
+func TestExpandRecordPathRejectsTraversal(t *testing.T) {
+	now := time.Now()
+	for _, stream := range []string{"../cam1", "../../etc/passwd", "cam1/../../x", "a//b", "./cam1", "..", "cam1\\..\\x", "cam1\x00"} {
+		if _, err := expandRecordPath("recordings", "{app}/{stream}.flv", "", "live", stream, now); !errors.Is(err, ErrUnsafeRecordPath) {
+			t.Errorf("stream %q: expected %v, got %v", stream, ErrUnsafeRecordPath, err)
+		}
+	}
+	if _, err := expandRecordPath("recordings", "{path}.flv", "..", "live", "cam1", now); !errors.Is(err, ErrUnsafeRecordPath) {
+		t.Errorf("expected vhost '..' to be rejected, got %v", err)
+	}
+	if _, err := expandRecordPath("recordings", "{path}.flv", "", "..", "cam1", now); !errors.Is(err, ErrUnsafeRecordPath) {
+		t.Errorf("expected app '..' to be rejected, got %v", err)
+	}
+
+	// 하위 디렉토리가 있는 스트림 이름은 허용
+	path, err := expandRecordPath("recordings", "{app}/{stream}.flv", "", "live", "room/cam1", now)
+	if err != nil || path != filepath.Join("recordings", "live", "room", "cam1.flv") {
+		t.Errorf("expected nested stream name to stay under the record path, got %s, %v", path, err)
+	}
+}
+
+func TestValidateRecordPathTemplate(t *testing.T) {
+	for _, template := range []string{"", "{app}.flv", "{stream", "{stream}}.flv", "{app}/{unknown}/{stream}.flv", "../{stream}.flv", "{app}/../{stream}.flv"} {
+		if err := ValidateRecordPathTemplate(template); err == nil {
+			t.Errorf("%q: expected template to be rejected", template)
+		}
+	}
+}
+
+func TestRecordPathTemplatePerApp(t *testing.T) {
+	config := StreamConfig{
+		RecordPathTemplate:     "{app}/{stream}/{timestamp}.flv",
+		AppRecordPathTemplates: map[string]string{"event": "/archive/{stream}.flv"},
+	}
+	if template := config.recordPathTemplate("event"); template != "/archive/{stream}.flv" {
+		t.Errorf("expected the app template, got %s", template)
+	}
+	if template := config.recordPathTemplate("live"); template != config.RecordPathTemplate {
+		t.Errorf("expected the default template, got %s", template)
+	}
+	if template := (StreamConfig{}).recordPathTemplate("live"); template != DEFAULT_RECORD_PATH_TEMPLATE {
+		t.Errorf("expected %s without templates, got %s", DEFAULT_RECORD_PATH_TEMPLATE, template)
+	}
+}
@@ -0,0 +1,99 @@
+package rtmp
+
+import (
+	"fmt"
+	"io"
+	"sol/pkg/flv"
+)
+
+// 발행 유형 (publish 명령어의 publishingType)
+const (
+	PUBLISH_TYPE_LIVE   = "live"   // 녹화 없음
+	PUBLISH_TYPE_RECORD = "record" // 기존 파일을 덮어쓰고 녹화
+	PUBLISH_TYPE_APPEND = "append" // 기존 파일 뒤에 이어서 녹화
+)
+
+// RecordMode는 발행 유형에 따른 녹화 방식
+type RecordMode int
+
+const (
+	RecordModeNone RecordMode = iota
+	RecordModeRecord
+	RecordModeAppend
+)
+
+// FLV 파일 상수
+const (
+	FLV_HEADER_SIZE     = flv.HeaderSize
+	FLV_TAG_HEADER_SIZE = flv.TagHeaderSize
+)
+
+// parsePublishType은 발행 유형 문자열을 녹화 방식으로 변환
+func parsePublishType(publishType string) (RecordMode, error) {
+	switch publishType {
+	case PUBLISH_TYPE_LIVE:
+		return RecordModeNone, nil
+	case PUBLISH_TYPE_RECORD:
+		return RecordModeRecord, nil
+	case PUBLISH_TYPE_APPEND:
+		return RecordModeAppend, nil
+	default:
+		return RecordModeNone, fmt.Errorf("unsupported publish type: %s", publishType)
+	}
+}
+
+// recorder는 발행 중인 스트림을 FLV 파일로 기록
+type recorder struct {
+	writer io.WriteCloser
+	flv    *flv.Writer // writer 위의 FLV 태그 인코더
+	sink   RecordingSink
+	path   string
+}
+
+// newRecorder는 녹화 방식에 맞게 sink에 파일을 열어 recorder를 생성 (sink가 nil이면 로컬 파일)
+// record는 파일을 비우고 새로 쓰며, append는 기존 파일 끝에 이어서 쓴다
+func newRecorder(sink RecordingSink, path string, mode RecordMode) (*recorder, error) {
+	if sink == nil {
+		sink = FileRecordingSink{}
+	}
+
+	writer, size, err := sink.Create(path, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recorder{
+		writer: writer,
+		flv:    flv.NewWriter(writer),
+		sink:   sink,
+		path:   path,
+	}
+
+	// 빈 파일인 경우에만 FLV 헤더 기록 (append 시 헤더 중복 방지)
+	if size == 0 {
+		if err := r.writeHeader(); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// writeHeader는 FLV 파일 헤더와 첫 PreviousTagSize(0)를 기록
+func (r *recorder) writeHeader() error {
+	return r.flv.WriteHeader(flv.Header{HasAudio: true, HasVideo: true})
+}
+
+// writeTag는 하나의 FLV 태그를 기록 (tagType은 RTMP 메시지 타입과 동일: 8/9/18)
+func (r *recorder) writeTag(tagType uint8, timestamp uint32, data [][]byte) error {
+	return r.flv.WriteTag(tagType, timestamp, data)
+}
+
+// close는 녹화 파일을 닫고 sink에 저장 확정을 알린다
+func (r *recorder) close() error {
+	if err := r.writer.Close(); err != nil {
+		return err
+	}
+	return r.sink.Finalize(r.path)
+}
@@ -0,0 +1,228 @@
+package rtmp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePublishType(t *testing.T) {
+	tests := []struct {
+		publishType string
+		expected    RecordMode
+		expectErr   bool
+	}{
+		{PUBLISH_TYPE_LIVE, RecordModeNone, false},
+		{PUBLISH_TYPE_RECORD, RecordModeRecord, false},
+		{PUBLISH_TYPE_APPEND, RecordModeAppend, false},
+		{"unknown", RecordModeNone, true},
+	}
+
+	for _, tt := range tests {
+		mode, err := parsePublishType(tt.publishType)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error but got nil", tt.publishType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error but got: %v", tt.publishType, err)
+		}
+		if mode != tt.expected {
+			t.Errorf("%s: expected mode %d, got %d", tt.publishType, tt.expected, mode)
+		}
+	}
+}
+
+func TestStreamRecordingLive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live", "test.flv")
+
+	stream := NewStream("live/test", 10, 0)
+	if err := stream.StartRecording(path, RecordModeNone); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if stream.IsRecording() {
+		t.Fatal("expected live publish not to record")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected no record file for live publish")
+	}
+}
+
+func TestStreamRecordingRecordTruncates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live", "test.flv")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("old recording data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("live/test", 10, 0)
+	if err := stream.StartRecording(path, RecordModeRecord); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	stream.ProcessAudioData(AudioData{Timestamp: 10, Data: [][]byte{{0xAF, 0x01, 0x02}}})
+	stream.RemovePublisher()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("FLV")) {
+		t.Fatal("expected record file to start with FLV header")
+	}
+	if bytes.Contains(data, []byte("old recording data")) {
+		t.Fatal("expected record publish to truncate existing file")
+	}
+
+	expectedSize := FLV_HEADER_SIZE + 4 + FLV_TAG_HEADER_SIZE + 3 + 4
+	if len(data) != expectedSize {
+		t.Fatalf("expected file size %d, got %d", expectedSize, len(data))
+	}
+}
+
+func TestStreamRecordingAppend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live", "test.flv")
+
+	stream := NewStream("live/test", 10, 0)
+	for i := 0; i < 2; i++ {
+		if err := stream.StartRecording(path, RecordModeAppend); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		stream.ProcessAudioData(AudioData{Timestamp: 10, Data: [][]byte{{0xAF, 0x01, 0x02}}})
+		stream.RemovePublisher()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := bytes.Count(data, []byte("FLV")); count != 1 {
+		t.Fatalf("expected exactly one FLV header, got %d", count)
+	}
+
+	expectedSize := FLV_HEADER_SIZE + 4 + 2*(FLV_TAG_HEADER_SIZE+3+4)
+	if len(data) != expectedSize {
+		t.Fatalf("expected file size %d, got %d", expectedSize, len(data))
+	}
+}
+
+// memoryRecordingSink는 녹화 데이터를 메모리에 저장하는 테스트용 RecordingSink
+type memoryRecordingSink struct {
+	files     map[string]*bytes.Buffer
+	finalized []string
+}
+
+func newMemoryRecordingSink() *memoryRecordingSink {
+	return &memoryRecordingSink{files: make(map[string]*bytes.Buffer)}
+}
+
+type memoryRecordingFile struct {
+	*bytes.Buffer
+}
+
+func (memoryRecordingFile) Close() error { return nil }
+
+func (m *memoryRecordingSink) Create(path string, mode RecordMode) (io.WriteCloser, int64, error) {
+	buf, ok := m.files[path]
+	if !ok || mode == RecordModeRecord {
+		buf = &bytes.Buffer{}
+		m.files[path] = buf
+	}
+	return memoryRecordingFile{buf}, int64(buf.Len()), nil
+}
+
+func (m *memoryRecordingSink) Finalize(path string) error {
+	m.finalized = append(m.finalized, path)
+	return nil
+}
+
+func TestStreamRecordingToSink(t *testing.T) {
+	sink := newMemoryRecordingSink()
+	stream := NewStream("live/test", 10, 0)
+	stream.SetRecordingSink(sink)
+
+	if err := stream.StartRecording("live/test.flv", RecordModeRecord); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	stream.ProcessAudioData(AudioData{Timestamp: 0x01020304, Data: [][]byte{{0xAF, 0x01}, {0x02}}})
+	stream.RemovePublisher()
+
+	expected := []byte{
+		'F', 'L', 'V', 0x01, 0x05, 0, 0, 0, 9, // FLV 헤더
+		0, 0, 0, 0, // PreviousTagSize0
+		MSG_TYPE_AUDIO, 0, 0, 3, // 태그 타입, 데이터 크기
+		0x02, 0x03, 0x04, 0x01, // 타임스탬프 (하위 24비트 + 확장)
+		0, 0, 0, // 스트림 ID
+		0xAF, 0x01, 0x02, // 데이터
+		0, 0, 0, FLV_TAG_HEADER_SIZE + 3, // PreviousTagSize
+	}
+	if got := sink.files["live/test.flv"].Bytes(); !bytes.Equal(got, expected) {
+		t.Fatalf("expected recording %x, got %x", expected, got)
+	}
+	if len(sink.finalized) != 1 || sink.finalized[0] != "live/test.flv" {
+		t.Fatalf("expected recording to be finalized once, got %v", sink.finalized)
+	}
+}
+
+func TestStreamRecordingToSinkAppend(t *testing.T) {
+	sink := newMemoryRecordingSink()
+	stream := NewStream("live/test", 10, 0)
+	stream.SetRecordingSink(sink)
+
+	for i := 0; i < 2; i++ {
+		if err := stream.StartRecording("live/test.flv", RecordModeAppend); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		stream.ProcessAudioData(AudioData{Timestamp: 10, Data: [][]byte{{0xAF, 0x01, 0x02}}})
+		stream.RemovePublisher()
+	}
+
+	data := sink.files["live/test.flv"].Bytes()
+	if count := bytes.Count(data, []byte("FLV")); count != 1 {
+		t.Fatalf("expected exactly one FLV header, got %d", count)
+	}
+	expectedSize := FLV_HEADER_SIZE + 4 + 2*(FLV_TAG_HEADER_SIZE+3+4)
+	if len(data) != expectedSize {
+		t.Fatalf("expected recording size %d, got %d", expectedSize, len(data))
+	}
+}
+
+func TestRecordPublishStaysInRecordPath(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, RecordPath: filepath.Join(dir, "records")}, nil)
+	defer server.cancel()
+
+	// 세션의 스트림 이름 검사를 거치지 않은 이름이어도 녹화 디렉토리를 벗어나지 않아야 함
+	for _, name := range []string{"../../escaped", "../escaped", "a/../../../escaped"} {
+		publisher, _ := newTestPlayer(1)
+		publisher.sessionId = "publisher-" + name
+		publisher.appName = "live"
+		publisher.streamName = name
+		server.sessions[publisher.sessionId] = publisher
+		server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/" + name, StreamId: 1, PublishType: PUBLISH_TYPE_RECORD})
+
+		if stream := server.GetStream("live/" + name); stream == nil || stream.IsRecording() {
+			t.Errorf("%s: expected publish to continue without recording", name)
+		}
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			t.Errorf("expected no record file, found %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to walk %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,55 @@
+package rtmp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RecordingSink는 녹화 데이터를 저장하는 백엔드 (로컬 디스크, 오브젝트 스토리지 등)
+type RecordingSink interface {
+	// Create는 path의 녹화 파일을 열고 이미 기록된 크기를 반환
+	// record는 기존 내용을 버리고(0 반환), append는 기존 내용 뒤에 이어서 쓴다
+	Create(path string, mode RecordMode) (io.WriteCloser, int64, error)
+
+	// Finalize는 녹화 파일을 닫은 뒤 호출되어 저장을 확정 (업로드, 이름 변경 등)
+	Finalize(path string) error
+}
+
+// FileRecordingSink는 로컬 파일 시스템에 녹화하는 기본 RecordingSink
+type FileRecordingSink struct{}
+
+// Create는 녹화 방식에 맞게 파일을 연다 (상위 디렉토리는 자동 생성)
+func (FileRecordingSink) Create(path string, mode RecordMode) (io.WriteCloser, int64, error) {
+	flags := os.O_CREATE | os.O_WRONLY
+	switch mode {
+	case RecordModeRecord:
+		flags |= os.O_TRUNC
+	case RecordModeAppend:
+		flags |= os.O_APPEND
+	default:
+		return nil, 0, fmt.Errorf("record mode %d does not record", mode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create record directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open record file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat record file: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+// Finalize는 로컬 파일은 닫는 것으로 충분하므로 아무것도 하지 않음
+func (FileRecordingSink) Finalize(path string) error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package rtmp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 재생 재개 토큰을 지정하지 않은 경우의 유효 시간
+const defaultResumeTokenTTL = 30 * time.Second
+
+// 재생 재개 토큰을 전달하는 onStatus 코드와 필드, play 스트림 이름의 쿼리 파라미터
+const (
+	resumeTokenStatusCode = "NetStream.Play.ResumeToken"
+	resumeTokenField      = "resumeToken"
+	resumeTokenParam      = "resume"
+)
+
+// resumeState는 연결이 끊긴 플레이어의 재생 위치 (토큰 유효 시간 동안 보관)
+type resumeState struct {
+	streamName string
+	timestamp  uint32 // 마지막으로 전송한 미디어 타임스탬프
+	expiresAt  time.Time
+}
+
+// newResumeToken은 추측할 수 없는 재생 재개 토큰을 생성
+func newResumeToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		slog.Error("Failed to generate resume token", "err", err)
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// splitResumeToken은 play 스트림 이름에서 재개 토큰 쿼리를 분리 ("test?resume=token")
+// 토큰이 없으면 스트림 이름을 그대로 반환
+func splitResumeToken(streamName string) (string, string) {
+	name, query, found := strings.Cut(streamName, "?")
+	if !found {
+		return streamName, ""
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil || !values.Has(resumeTokenParam) {
+		return streamName, ""
+	}
+	return name, values.Get(resumeTokenParam)
+}
+
+// resumeTokenTTL은 설정된 재생 재개 토큰 유효 시간 (0이면 기본값)
+func (s *Server) resumeTokenTTL() time.Duration {
+	if s.streamConfig.ResumeTokenTTL > 0 {
+		return s.streamConfig.ResumeTokenTTL
+	}
+	return defaultResumeTokenTTL
+}
+
+// resumePlayer는 새 재개 토큰을 발급한 뒤, 전달받은 토큰이 유효하면 플레이어를 이어서 재생
+// 이어서 재생하지 못하면 false (호출자가 처음 입장처럼 처리)
+func (s *Server) resumePlayer(stream *Stream, player *session, token string) bool {
+	s.issueResumeToken(stream, player)
+	if token == "" {
+		return false
+	}
+
+	if state, ok := s.takeResumeState(token, stream.name, time.Now()); ok && stream.ResumePlayer(player, state.timestamp) {
+		return true
+	}
+	slog.Info("Play resume not possible, starting from cache", "streamName", stream.name, "sessionId", player.sessionId)
+	return false
+}
+
+// issueResumeToken은 플레이어에게 새 재생 재개 토큰을 발급하고 onStatus로 전달
+func (s *Server) issueResumeToken(stream *Stream, player *session) {
+	token := newResumeToken()
+	if token == "" {
+		return
+	}
+	player.resumeToken = token
+
+	statusObj := newStatusObject("status", resumeTokenStatusCode, "Reconnect with this token to resume playback", stream.name)
+	statusObj[resumeTokenField] = token
+	stream.sendStatusToPlayer(player, statusObj)
+}
+
+// saveResumeState는 재생을 멈춘 플레이어의 위치를 토큰으로 보관 (만료된 위치는 함께 정리)
+func (s *Server) saveResumeState(player *session, streamName string, now time.Time) {
+	if player.resumeToken == "" {
+		return
+	}
+	for token, state := range s.resumeStates {
+		if now.After(state.expiresAt) {
+			delete(s.resumeStates, token)
+		}
+	}
+
+	s.resumeStates[player.resumeToken] = resumeState{
+		streamName: streamName,
+		timestamp:  player.lastPlayTimestamp,
+		expiresAt:  now.Add(s.resumeTokenTTL()),
+	}
+	player.resumeToken = ""
+}
+
+// takeResumeState는 토큰에 보관된 재생 위치를 꺼낸다 (토큰은 한 번만 사용 가능)
+func (s *Server) takeResumeState(token, streamName string, now time.Time) (resumeState, bool) {
+	state, ok := s.resumeStates[token]
+	if !ok {
+		return resumeState{}, false
+	}
+	delete(s.resumeStates, token)
+	if state.streamName != streamName || now.After(state.expiresAt) {
+		return resumeState{}, false
+	}
+	return state, true
+}
@@ -0,0 +1,127 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+// 플레이어 출력에서 비디오 메시지 타임스탬프와 onMetaData 포함 여부를 읽어온다
+func readVideoTimestamps(t *testing.T, conn *bufferConn) ([]uint32, bool) {
+	t.Helper()
+	var timestamps []uint32
+	hasMetadata := false
+	for _, msg := range readAllMessages(t, conn.buf.Bytes()) {
+		switch msg.messageHeader.typeId {
+		case MSG_TYPE_VIDEO:
+			timestamps = append(timestamps, msg.messageHeader.Timestamp)
+		case MSG_TYPE_AMF0_DATA:
+			hasMetadata = true
+		}
+	}
+	return timestamps, hasMetadata
+}
+
+// 캡처된 출력에서 발급된 재생 재개 토큰을 읽어온다
+func readResumeToken(t *testing.T, conn *bufferConn) string {
+	t.Helper()
+	for _, info := range readStatusObjects(t, conn) {
+		if info["code"] == resumeTokenStatusCode {
+			token, _ := info[resumeTokenField].(string)
+			return token
+		}
+	}
+	t.Fatal("expected a resume token onStatus")
+	return ""
+}
+
+func TestResumePlayAfterDisconnect(t *testing.T) {
+	server := NewServer(0, StreamConfig{
+		GopCacheSize:  10,
+		CacheEviction: CacheEvictionDuration,
+		CacheDuration: 10 * time.Second,
+		ResumablePlay: true,
+	}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher"
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	stream := server.GetStream("live/test")
+	stream.ProcessMetaData(MetaData{Metadata: map[string]any{"width": 1280.0}})
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+
+	// 1초마다 키프레임인 100ms 간격 프레임
+	feed := func(from, to uint32) {
+		for timestamp := from; timestamp < to; timestamp += 100 {
+			data := testAVCInterFrame
+			if timestamp%1000 == 0 {
+				data = testAVCKeyFrame
+			}
+			stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: timestamp, Data: data})
+		}
+	}
+	feed(0, 1000)
+
+	first, firstConn := newTestPlayer(1)
+	first.sessionId = "player-1"
+	server.sessions[first.sessionId] = first
+	server.handlePlayStarted(PlayStarted{SessionId: first.sessionId, StreamName: "live/test", StreamId: 1})
+	token := readResumeToken(t, firstConn)
+
+	// 1400ms까지 재생한 뒤 연결이 끊기고, 그 사이 발행은 계속됨
+	feed(1000, 1500)
+	server.handlePlayStopped(PlayStopped{SessionId: first.sessionId, StreamName: "live/test", StreamId: 1})
+	feed(1500, 3000)
+
+	// 토큰으로 재연결하면 끊긴 위치 이전 마지막 키프레임(1000ms)부터 이어서 재생
+	resumed, resumedConn := newTestPlayer(1)
+	resumed.sessionId = "player-1-resumed"
+	server.sessions[resumed.sessionId] = resumed
+	server.handlePlayStarted(PlayStarted{SessionId: resumed.sessionId, StreamName: "live/test", StreamId: 1, ResumeToken: token})
+
+	timestamps, hasMetadata := readVideoTimestamps(t, resumedConn)
+	if hasMetadata {
+		t.Error("expected resumed player not to receive metadata again")
+	}
+	if len(timestamps) != 21 || timestamps[0] != 0 || timestamps[1] != 1000 || timestamps[20] != 2900 {
+		t.Fatalf("expected sequence header then frames 1000-2900, got %v", timestamps)
+	}
+	if newToken := readResumeToken(t, resumedConn); newToken == "" || newToken == token {
+		t.Fatalf("expected a fresh resume token, got %q", newToken)
+	}
+	if stream.GetPlayerCount() != 1 {
+		t.Fatalf("expected resumed player to be registered, got %d players", stream.GetPlayerCount())
+	}
+
+	// 토큰은 한 번만 사용 가능: 같은 토큰으로 다시 오면 처음 입장처럼 전체 캐시를 받음
+	replay, replayConn := newTestPlayer(1)
+	replay.sessionId = "player-2"
+	server.sessions[replay.sessionId] = replay
+	server.handlePlayStarted(PlayStarted{SessionId: replay.sessionId, StreamName: "live/test", StreamId: 1, ResumeToken: token})
+
+	timestamps, hasMetadata = readVideoTimestamps(t, replayConn)
+	if !hasMetadata || len(timestamps) != 31 || timestamps[1] != 0 {
+		t.Fatalf("expected cold start with metadata and frames from 0, got metadata=%t %v", hasMetadata, timestamps)
+	}
+}
+
+func TestSplitResumeToken(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		token string
+	}{
+		{"test", "test", ""},
+		{"test?resume=abc123", "test", "abc123"},
+		{"test?foo=bar&resume=abc123", "test", "abc123"},
+		{"test?foo=bar", "test?foo=bar", ""},
+	}
+
+	for _, tt := range tests {
+		name, token := splitResumeToken(tt.input)
+		if name != tt.name || token != tt.token {
+			t.Errorf("splitResumeToken(%q): expected (%q, %q), got (%q, %q)", tt.input, tt.name, tt.token, name, token)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package rtmp
+
+import "strings"
+
+// SessionRole은 세션의 역할 (발행자 또는 플레이어)
+type SessionRole string
+
+const (
+	RoleUnknown   SessionRole = ""
+	RolePublisher SessionRole = "publisher"
+	RolePlayer    SessionRole = "player"
+)
+
+// roleFromConnect는 connect 명령 객체로 역할을 미리 추정
+// 인코더(OBS, FFmpeg 발행)는 flashVer를 "FMLE/"로 보내고, 플레이어는 audioCodecs/videoCodecs를 함께 보낸다
+func roleFromConnect(commandObj map[string]any) SessionRole {
+	if flashVer, _ := commandObj["flashVer"].(string); strings.HasPrefix(flashVer, "FMLE/") {
+		return RolePublisher
+	}
+	if _, ok := commandObj["videoCodecs"]; ok {
+		return RolePlayer
+	}
+	if _, ok := commandObj["audioCodecs"]; ok {
+		return RolePlayer
+	}
+	return RoleUnknown
+}
+
+// Role은 지금까지 알려진 세션의 역할을 반환 (아직 모르면 RoleUnknown)
+// connect/releaseStream/FCPublish 단계의 추정값이며 publish/play 시 확정된다
+func (s *session) Role() SessionRole {
+	role, _ := s.role.Load().(SessionRole)
+	return role
+}
+
+// assignRole은 세션의 역할을 설정하고, 바뀐 경우 RoleAssigned 이벤트를 전송
+func (s *session) assignRole(role SessionRole, source string) {
+	if role == RoleUnknown || s.Role() == role {
+		return
+	}
+	s.role.Store(role)
+
+	s.commandLogger().Info("session role assigned", "role", role, "source", source)
+	s.sendEvent(RoleAssigned{
+		SessionId:  s.sessionId,
+		Role:       role,
+		Source:     source,
+		CommandSeq: s.commandSeq,
+	})
+}
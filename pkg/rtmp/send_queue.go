@@ -0,0 +1,327 @@
+package rtmp
+
+import (
+	"log/slog"
+	"sol/pkg/codec"
+	"sync"
+	"time"
+)
+
+// 버린 프레임이 한도를 넘은 플레이어에게 보내는 onStatus
+const (
+	slowPlayerStatusCode        = "NetStream.Play.Failed"
+	slowPlayerStatusDescription = "Player too slow, too many frames dropped"
+
+	// 느린 플레이어에게 마지막 onStatus를 보낼 때 기다리는 최대 시간 (넘으면 보내지 못한 채 연결 종료)
+	slowPlayerStatusTimeout = 2 * time.Second
+)
+
+// 송신 큐의 절대 한도: 건너뛸 키프레임이 없어도 이를 넘으면 가장 오래된 미디어부터 버린다
+const (
+	maxQueuedMessages = 4096
+	maxQueuedBytes    = 16 * 1024 * 1024
+)
+
+// queuedMessage는 플레이어 송신 큐에 쌓인 미디어/메타데이터/상태 메시지
+type queuedMessage struct {
+	typeId         uint8 // MSG_TYPE_AUDIO, MSG_TYPE_VIDEO, MSG_TYPE_AMF0_DATA, MSG_TYPE_AMF0_COMMAND
+	timestamp      uint32
+	streamID       uint32         // 보낼 메시지 스트림 ID (큐에 넣을 때의 재생 스트림 ID, 스탬프하지 않는 onMetaData는 0)
+	data           [][]byte       // 오디오/비디오/데이터 메시지 payload (zero-copy)
+	metadata       map[string]any // onMetaData (MSG_TYPE_AMF0_DATA이고 data가 없는 경우)
+	status         map[string]any // onStatus (MSG_TYPE_AMF0_COMMAND인 경우)
+	keyFrame       bool
+	sequenceHeader bool
+	delay          time.Duration // 보내기 전에 기다릴 시간 (초기 캐시 버스트 페이싱)
+}
+
+// isMedia는 지연 계산과 드롭 대상이 되는 일반 미디어 프레임인지 확인
+func (m *queuedMessage) isMedia() bool {
+	return (m.typeId == MSG_TYPE_AUDIO || m.typeId == MSG_TYPE_VIDEO) && !m.sequenceHeader
+}
+
+// sendQueue는 저지연 모드 플레이어의 송신 큐
+// 쌓인 미디어 구간이 지연 예산을 넘으면 최신 키프레임 이전 프레임을 버리고 앞으로 건너뛴다
+type sendQueue struct {
+	mu            sync.Mutex
+	cond          *sync.Cond
+	messages      []queuedMessage
+	queuedBytes   int    // 쌓인 메시지 payload 크기
+	latencyBudget uint32 // 밀리초 (RTMP 타임스탬프 단위)
+	dropped       uint64 // 버린 메시지 수
+	closed        bool
+	closing       bool          // 남은 메시지(마지막 onStatus)만 보내고 연결을 끊는 중
+	done          chan struct{} // close 시 닫힘 (페이싱 대기 중단)
+
+	// 초기 캐시 버스트 페이싱: 다음 burstRemaining개의 미디어 메시지를 burstInterval 간격으로 전송
+	burstInterval  time.Duration
+	burstRemaining int
+}
+
+func newSendQueue(latencyBudget time.Duration) *sendQueue {
+	q := &sendQueue{
+		messages:      make([]queuedMessage, 0),
+		latencyBudget: uint32(latencyBudget.Milliseconds()),
+		done:          make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push는 메시지를 큐에 넣고 지연 예산을 넘으면 최신 키프레임으로 건너뛴다
+func (q *sendQueue) push(msg queuedMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || q.closing {
+		return
+	}
+
+	if q.burstRemaining > 0 && msg.isMedia() {
+		msg.delay = q.burstInterval
+		q.burstRemaining--
+	}
+	q.messages = append(q.messages, msg)
+	q.queuedBytes += chunksSize(msg.data)
+	if q.latencyBudget > 0 && q.queuedDuration() > q.latencyBudget {
+		q.dropToLatestKeyFrame()
+	}
+	q.enforceLimits()
+	q.cond.Signal()
+}
+
+// pop은 다음 메시지를 꺼낸다 (비어 있으면 대기, 닫히면 false)
+func (q *sendQueue) pop() (queuedMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.messages) == 0 && !q.closed && !q.closing {
+		q.cond.Wait()
+	}
+	if q.closed || len(q.messages) == 0 {
+		return queuedMessage{}, false
+	}
+
+	msg := q.messages[0]
+	q.messages = q.messages[1:]
+	q.queuedBytes -= chunksSize(msg.data)
+	return msg, true
+}
+
+// paceBurst는 이어서 넣을 frames개의 미디어 메시지를 window 동안 고르게 나눠 보내도록 설정
+// 시퀀스 헤더와 메타데이터는 디코더 설정에 필요하므로 기다리지 않고 보낸다
+func (q *sendQueue) paceBurst(frames int, window time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if frames <= 0 || window <= 0 {
+		return
+	}
+	q.burstInterval = window / time.Duration(frames)
+	q.burstRemaining = frames
+}
+
+// wait는 delay만큼 기다린다 (그 사이 큐가 닫히면 false)
+func (q *sendQueue) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-q.done:
+		return false
+	}
+}
+
+// close는 큐를 닫고 대기 중인 pop을 깨운다
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		close(q.done)
+	}
+	q.closed = true
+	q.messages = nil
+	q.queuedBytes = 0
+	q.cond.Broadcast()
+}
+
+// closeWithStatus는 쌓인 메시지를 버리고 statusObj만 보낸 뒤 연결을 끊도록 한다
+func (q *sendQueue) closeWithStatus(statusObj map[string]any, timestamp uint32) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || q.closing {
+		return
+	}
+	q.dropped += uint64(len(q.messages))
+	q.messages = []queuedMessage{{typeId: MSG_TYPE_AMF0_COMMAND, timestamp: timestamp, status: statusObj}}
+	q.queuedBytes = 0
+	q.closing = true
+	q.cond.Signal()
+}
+
+// isClosing은 closeWithStatus로 연결을 끊는 중인지 확인 (세션 정리로 닫혔으면 false)
+func (q *sendQueue) isClosing() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closing && !q.closed
+}
+
+// droppedCount는 지금까지 버린 메시지 수를 반환
+func (q *sendQueue) droppedCount() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// enforceMaxDroppedFrames는 송신 큐에서 버린 프레임이 한도를 넘은 플레이어를 스트림에서 빼고
+// 마지막 onStatus를 보낸 뒤 연결을 끊는다 (끊었으면 true)
+func (s *Stream) enforceMaxDroppedFrames(player *session) bool {
+	if player.dropLimit == 0 || player.sendQueue == nil {
+		return false
+	}
+	dropped := player.sendQueue.droppedCount()
+	if dropped <= player.dropLimit {
+		return false
+	}
+
+	slog.Warn("Disconnecting slow player", "streamName", s.name, "sessionId", player.sessionId, "droppedFrames", dropped, "dropLimit", player.dropLimit)
+	delete(s.players, player)
+	player.sendQueue.closeWithStatus(newStatusObject("error", slowPlayerStatusCode, slowPlayerStatusDescription, s.name), s.lastTimestamp)
+	return true
+}
+
+// queuedDuration은 큐에 쌓인 미디어의 시간 길이 (가장 오래된 프레임 ~ 최신 프레임, 밀리초)
+func (q *sendQueue) queuedDuration() uint32 {
+	var oldest, newest uint32
+	found := false
+	for i := range q.messages {
+		if !q.messages[i].isMedia() {
+			continue
+		}
+		ts := q.messages[i].timestamp
+		if !found {
+			oldest, newest = ts, ts
+			found = true
+			continue
+		}
+		if ts < oldest {
+			oldest = ts
+		}
+		if ts > newest {
+			newest = ts
+		}
+	}
+	return newest - oldest
+}
+
+// dropToLatestKeyFrame은 최신 키프레임보다 앞선 미디어 프레임을 버린다
+// 시퀀스 헤더와 메타데이터는 디코더 설정에 필요하므로 유지
+func (q *sendQueue) dropToLatestKeyFrame() {
+	keyIndex := -1
+	for i := len(q.messages) - 1; i >= 0; i-- {
+		if q.messages[i].keyFrame {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex <= 0 {
+		return // 건너뛸 키프레임이 없음
+	}
+
+	keyTimestamp := q.messages[keyIndex].timestamp
+	kept := make([]queuedMessage, 0, len(q.messages)-keyIndex)
+	for i, msg := range q.messages {
+		// 키프레임 이전의 일반 미디어만 버림 (키프레임과 같은 시점 이후의 오디오는 유지)
+		if i < keyIndex && msg.isMedia() && (msg.typeId == MSG_TYPE_VIDEO || msg.timestamp < keyTimestamp) {
+			q.dropped++
+			q.queuedBytes -= chunksSize(msg.data)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	q.messages = kept
+}
+
+// enforceLimits는 큐가 절대 한도를 넘으면 가장 오래된 메시지부터 버린다
+// 키프레임이 오지 않아 dropToLatestKeyFrame이 건너뛰지 못해도 메모리가 무한히 늘지 않도록 한다
+// 일반 미디어를 먼저 버리고, 미디어가 없으면 가장 오래된 메시지를 버린다
+func (q *sendQueue) enforceLimits() {
+	for len(q.messages) > maxQueuedMessages || q.queuedBytes > maxQueuedBytes {
+		index := 0
+		for i := range q.messages {
+			if q.messages[i].isMedia() {
+				index = i
+				break
+			}
+		}
+		q.queuedBytes -= chunksSize(q.messages[index].data)
+		q.messages = append(q.messages[:index], q.messages[index+1:]...)
+		q.dropped++
+	}
+}
+
+// isVideoKeyFrame은 FLV 비디오 태그의 첫 바이트로 키프레임 여부를 판단 (시퀀스 헤더 제외)
+func isVideoKeyFrame(data [][]byte) bool {
+	if len(data) == 0 || len(data[0]) == 0 {
+		return false
+	}
+	return (data[0][0]>>4)&0x0F == 1 && !isVideoSequenceHeader(data)
+}
+
+// isVideoSequenceHeader는 AVC sequence header 여부를 판단
+func isVideoSequenceHeader(data [][]byte) bool {
+	return videoCodec(data) == codec.H264 && len(data[0]) > 1 && data[0][1] == 0
+}
+
+// compositionTime은 AVC NALU 패킷의 composition time offset(밀리초)을 반환 (PTS = DTS + 오프셋)
+// AVCPacketType 뒤의 3바이트 부호 있는 값이며, AVC NALU가 아니거나 길이가 짧으면 0
+func compositionTime(data [][]byte) int32 {
+	if videoCodec(data) != codec.H264 {
+		return 0
+	}
+	var header [5]byte
+	n := 0
+	for _, chunk := range data {
+		n += copy(header[n:], chunk)
+		if n == len(header) {
+			break
+		}
+	}
+	if n < len(header) || header[1] != 1 {
+		return 0
+	}
+	cts := int32(header[2])<<16 | int32(header[3])<<8 | int32(header[4])
+	return cts << 8 >> 8 // 24비트 부호 확장
+}
+
+// isAudioSequenceHeader는 AAC sequence header 여부를 판단
+func isAudioSequenceHeader(data [][]byte) bool {
+	return audioCodec(data) == codec.AAC && len(data[0]) > 1 && data[0][1] == 0
+}
+
+// videoCodec은 FLV 비디오 태그의 첫 바이트(CodecID 하위 4비트)로 코덱을 판단
+// enhanced RTMP 확장 헤더(최상위 비트)면 하위 4비트는 PacketType이므로 뒤따르는 FourCC로 판단
+func videoCodec(data [][]byte) codec.Codec {
+	if len(data) == 0 || len(data[0]) == 0 {
+		return codec.Unknown
+	}
+	if data[0][0]&0x80 != 0 {
+		if len(data[0]) < 5 {
+			return codec.Unknown
+		}
+		return codec.FromFourCC(string(data[0][1:5]))
+	}
+	return codec.FromFLVVideoID(data[0][0] & 0x0F)
+}
+
+// audioCodec은 FLV 오디오 태그의 첫 바이트(SoundFormat 상위 4비트)로 코덱을 판단
+func audioCodec(data [][]byte) codec.Codec {
+	if len(data) == 0 || len(data[0]) == 0 {
+		return codec.Unknown
+	}
+	return codec.FromFLVAudioID(data[0][0] >> 4)
+}
@@ -0,0 +1,278 @@
+package rtmp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 큐에 남은 비디오 프레임의 타임스탬프 목록
+func queuedVideoTimestamps(q *sendQueue) []uint32 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var timestamps []uint32
+	for _, msg := range q.messages {
+		if msg.typeId == MSG_TYPE_VIDEO && !msg.sequenceHeader {
+			timestamps = append(timestamps, msg.timestamp)
+		}
+	}
+	return timestamps
+}
+
+func TestSlowPlayerJumpsToLatestKeyFrame(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, _ := newTestPlayer(1)
+	// 전송 루프 없이 큐만 설정 (큐를 비우지 못하는 느린 플레이어)
+	player.sendQueue = newSendQueue(500 * time.Millisecond)
+	stream.AddPlayer(player)
+
+	stream.ProcessVideoData(VideoData{Timestamp: 0, Data: [][]byte{{0x17, 0x00}}}) // AVC sequence header
+	for ts := uint32(0); ts <= 1200; ts += 100 {
+		data := [][]byte{{0x27, 0x01}} // inter frame
+		if ts%600 == 0 {
+			data = [][]byte{{0x17, 0x01}} // key frame (0, 600, 1200)
+		}
+		stream.ProcessVideoData(VideoData{Timestamp: ts, Data: data})
+	}
+
+	// 지연 예산 초과 시 최신 키프레임(1200)으로 건너뜀
+	timestamps := queuedVideoTimestamps(player.sendQueue)
+	if len(timestamps) == 0 || timestamps[0] != 1200 {
+		t.Fatalf("expected queue to start at key frame 1200, got %v", timestamps)
+	}
+	if player.sendQueue.droppedCount() == 0 {
+		t.Fatal("expected frames to be dropped")
+	}
+
+	// 시퀀스 헤더는 유지
+	msg, ok := player.sendQueue.pop()
+	if !ok || !msg.sequenceHeader {
+		t.Fatalf("expected sequence header to be kept at the front, got %+v", msg)
+	}
+}
+
+func TestSlowPlayerDisconnectedAfterDropLimit(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, conn := newTestPlayer(1)
+	// 전송 루프 없이 큐만 설정 (계속 따라오지 못하는 플레이어)
+	player.sendQueue = newSendQueue(500 * time.Millisecond)
+	player.dropLimit = 10
+	stream.AddPlayer(player)
+
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	disconnectedAt := uint32(0)
+	for ts := uint32(0); ts <= 6000 && disconnectedAt == 0; ts += 100 {
+		frameType, data := "AVC NALU", testAVCInterFrame
+		if ts%600 == 0 {
+			frameType, data = "key frame", testAVCKeyFrame
+		}
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: frameType, Data: data})
+		if _, ok := stream.players[player]; !ok {
+			disconnectedAt = ts
+		}
+	}
+
+	if disconnectedAt == 0 {
+		t.Fatalf("expected player to be removed after exceeding the drop limit, dropped %d", player.sendQueue.droppedCount())
+	}
+	if !player.sendQueue.isClosing() {
+		t.Fatal("expected send queue to be closing")
+	}
+
+	// 이후 프레임은 더 쌓이지 않음
+	stream.ProcessVideoData(VideoData{Timestamp: disconnectedAt + 100, FrameType: "key frame", Data: testAVCKeyFrame})
+	if timestamps := queuedVideoTimestamps(player.sendQueue); len(timestamps) != 0 {
+		t.Fatalf("expected queued frames to be discarded, got %v", timestamps)
+	}
+
+	// 전송 루프는 onStatus 하나만 보내고 연결을 끊음
+	player.handleSendQueue(player.sendQueue)
+	statuses := readStatusObjects(t, conn)
+	if len(statuses) != 1 || statuses[0]["code"] != slowPlayerStatusCode || statuses[0]["level"] != "error" {
+		t.Fatalf("expected a single %s status, got %v", slowPlayerStatusCode, statuses)
+	}
+	if !conn.closed {
+		t.Fatal("expected slow player connection to be closed")
+	}
+}
+
+func TestSendQueueDropLimitDisabledByDefault(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	player, _ := newTestPlayer(1)
+	player.sendQueue = newSendQueue(500 * time.Millisecond)
+	stream.AddPlayer(player)
+
+	for ts := uint32(0); ts <= 6000; ts += 100 {
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "key frame", Data: testAVCKeyFrame})
+	}
+	if player.sendQueue.droppedCount() == 0 {
+		t.Fatal("expected frames to be dropped")
+	}
+	if _, ok := stream.players[player]; !ok || player.sendQueue.isClosing() {
+		t.Fatal("expected player to stay connected without a drop limit")
+	}
+}
+
+func TestSendQueueWithinBudgetKeepsFrames(t *testing.T) {
+	q := newSendQueue(time.Second)
+	for ts := uint32(0); ts <= 500; ts += 100 {
+		q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, timestamp: ts, keyFrame: ts == 0 || ts == 300})
+	}
+
+	if timestamps := queuedVideoTimestamps(q); len(timestamps) != 6 {
+		t.Fatalf("expected 6 frames to be kept, got %v", timestamps)
+	}
+	if q.droppedCount() != 0 {
+		t.Fatalf("expected no dropped frames, got %d", q.droppedCount())
+	}
+}
+
+func TestSendQueueLimitsWithoutKeyFrame(t *testing.T) {
+	// 키프레임이 오지 않아 지연 예산으로는 건너뛸 수 없는 큐
+	q := newSendQueue(500 * time.Millisecond)
+	q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, sequenceHeader: true})
+	for i := 0; i < maxQueuedMessages+100; i++ {
+		q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, timestamp: uint32(i)})
+	}
+
+	if len(q.messages) != maxQueuedMessages {
+		t.Fatalf("expected the queue to be capped at %d messages, got %d", maxQueuedMessages, len(q.messages))
+	}
+	if !q.messages[0].sequenceHeader {
+		t.Fatal("expected the sequence header to be kept")
+	}
+	if q.messages[1].timestamp != 101 {
+		t.Fatalf("expected the oldest frames to be dropped, first frame is %d", q.messages[1].timestamp)
+	}
+	if q.droppedCount() != 101 {
+		t.Fatalf("expected 101 dropped frames, got %d", q.droppedCount())
+	}
+
+	// 크기 한도
+	q = newSendQueue(500 * time.Millisecond)
+	frame := [][]byte{make([]byte, 1024*1024)}
+	for i := 0; i < 20; i++ {
+		q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, timestamp: uint32(i), data: frame})
+	}
+	if q.queuedBytes > maxQueuedBytes || len(q.messages) != maxQueuedBytes/len(frame[0]) {
+		t.Fatalf("expected the queue to be capped at %d bytes, got %d bytes in %d messages", maxQueuedBytes, q.queuedBytes, len(q.messages))
+	}
+}
+
+func TestSendQueueNotCreatedAfterCleanup(t *testing.T) {
+	player, _ := newTestPlayer(1)
+	player.cleanup()
+
+	player.enableLowLatency(time.Second)
+	if player.sendQueue != nil {
+		t.Fatal("expected no send queue for a cleaned up session")
+	}
+}
+
+func TestSendQueueCloseUnblocksPop(t *testing.T) {
+	q := newSendQueue(time.Second)
+	done := make(chan bool)
+	go func() {
+		_, ok := q.pop()
+		done <- ok
+	}()
+
+	q.close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("expected pop to report closed queue")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected pop to return after close")
+	}
+}
+
+// timedConn은 쓰기 시각과 누적 바이트를 기록하는 연결 (송신 큐 goroutine에서 쓰므로 잠금으로 보호)
+type timedConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written int
+	last    time.Time
+}
+
+func (c *timedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written += len(p)
+	c.last = time.Now()
+	return len(p), nil
+}
+
+func (c *timedConn) progress() (int, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written, c.last
+}
+
+func TestSendQueuePacesBurstMediaOnly(t *testing.T) {
+	q := newSendQueue(0)
+	q.paceBurst(2, 100*time.Millisecond)
+	q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, sequenceHeader: true})
+	for ts := uint32(0); ts < 3; ts++ {
+		q.push(queuedMessage{typeId: MSG_TYPE_VIDEO, timestamp: ts})
+	}
+
+	expected := []time.Duration{0, 50 * time.Millisecond, 50 * time.Millisecond, 0}
+	for i, delay := range expected {
+		if q.messages[i].delay != delay {
+			t.Fatalf("expected message %d to wait %v, got %v", i, delay, q.messages[i].delay)
+		}
+	}
+}
+
+func TestCachedBurstIsPaced(t *testing.T) {
+	const window = 200 * time.Millisecond
+
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+	for ts := uint32(100); ts < 400; ts += 100 {
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "AVC NALU", Data: testAVCInterFrame})
+	}
+
+	// 페이싱 없이 받는 플레이어로 전체 버스트 크기를 구함 (한 번에 동기 전송)
+	reference, referenceConn := newTestPlayer(1)
+	stream.AddPlayer(reference)
+	total := referenceConn.buf.Len()
+
+	player, _ := newTestPlayer(1)
+	conn := &timedConn{}
+	player.conn = conn
+	player.burstPacing = window
+	player.enableLowLatency(0)
+	defer player.sendQueue.close()
+
+	start := time.Now()
+	stream.AddPlayer(player)
+	if time.Since(start) > window/2 {
+		t.Fatal("expected AddPlayer not to block on pacing")
+	}
+
+	// 시퀀스 헤더는 바로 가지만 GOP는 아직 다 가지 않음
+	time.Sleep(window / 8)
+	if written, _ := conn.progress(); written == 0 || written >= total {
+		t.Fatalf("expected a partial burst after %v, got %d of %d bytes", window/8, written, total)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		written, last := conn.progress()
+		if written == total {
+			if last.Sub(start) < window {
+				t.Fatalf("expected cached burst to be spread over %v, finished after %v", window, last.Sub(start))
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d bytes to be sent, got %d", total, written)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
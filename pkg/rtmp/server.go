@@ -2,18 +2,193 @@ package rtmp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"sol/pkg/acl"
+	"sol/pkg/deadletter"
+	"sol/pkg/tcpopt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // StreamConfig는 스트림 설정을 담는 구조체
 type StreamConfig struct {
 	GopCacheSize        int
 	MaxPlayersPerStream int
+	MaxStreams          int    // 서버 전체 최대 스트림 수 (0이면 무제한)
+	RecordPath          string // record/append 발행 시 FLV 파일을 저장할 디렉토리
+
+	// 녹화 파일 경로 템플릿 (RecordPath 기준 상대 경로 또는 절대 경로, 빈 값이면 DEFAULT_RECORD_PATH_TEMPLATE)
+	// AppRecordPathTemplates에 앱 이름이 있으면 그 템플릿을 우선 사용
+	RecordPathTemplate     string
+	AppRecordPathTemplates map[string]string
+
+	// 비디오 캐시 제거 정책 (빈 값이면 frames: GopCacheSize 프레임 수 기준)
+	// duration이면 최근 CacheDuration 구간을 키프레임부터 유지 (0이면 2초)
+	CacheEviction CacheEvictionPolicy
+	CacheDuration time.Duration
+
+	// 녹화 파일 저장소 (nil이면 RecordPath 아래 로컬 파일 시스템)
+	RecordingSink RecordingSink
+
+	// 발행자 연결이 끊긴 뒤 스트림과 플레이어를 유지하는 시간 (0이면 즉시 정리)
+	// 유예 시간 안에 같은 스트림 키로 다시 발행하면 기존 스트림에 이어서 발행
+	PublisherReconnectGrace time.Duration
+
+	// 조립할 수 있는 최대 메시지 길이 (초과 선언 시 연결 종료, 0이면 DEFAULT_MAX_MESSAGE_SIZE)
+	MaxMessageSize uint32
+
+	// 허용하는 최소 Set Chunk Size (미만이면 연결 종료, 0이면 DEFAULT_MIN_CHUNK_SIZE)
+	MinChunkSize uint32
+
+	// 디버그용: 조립된 메시지 길이를 선언된 길이와 비교하고 불일치 시 상세 덤프 로그
+	ValidateMessageLength bool
+
+	// 플레이어 송신 지연 예산 (0이면 비활성화)
+	// 플레이어 송신 큐에 쌓인 미디어가 이를 넘으면 최신 키프레임으로 건너뜀
+	LatencyBudget time.Duration
+
+	// 저지연 모드에서 플레이어 송신 큐가 버린 프레임이 이를 넘으면 onStatus(NetStream.Play.Failed) 후 연결 종료 (0이면 제한 없음)
+	// 계속 따라오지 못하는 플레이어에게 끝없이 프레임을 버리며 보내지 않도록 한다
+	MaxPlayerDroppedFrames uint64
+
+	// 새 플레이어에게 캐시된 GOP/오디오 프레임을 이 시간에 걸쳐 나눠 전송 (0이면 한 번에 전송)
+	// 입장 순간의 버스트로 플레이어 수신 버퍼나 대역폭이 넘치는 것을 막는다
+	InitialBurstPacing time.Duration
+
+	// 재생 준비(sequence header + 키프레임)가 될 때까지 플레이어 입장을 보류
+	WaitForPlayable bool
+
+	// 세션 종료 시 접근 로그를 기록 (nil이면 기록하지 않음)
+	AccessLogger AccessLogger
+
+	// connect 명령의 클라이언트 정보(app, tcUrl, flashVer 등)로 연결을 허용할지 결정 (nil이면 모두 허용)
+	ConnectAuthenticator ConnectAuthenticator
+
+	// 문제 분석용: 연결별 송수신 원본 바이트를 이 디렉토리의 파일에 hex 덤프로 기록 (빈 값이면 비활성화)
+	// ByteDumpLimit은 연결별로 기록할 최대 바이트 (0이면 DEFAULT_BYTE_DUMP_LIMIT, 넘는 바이트는 기록하지 않음)
+	ByteDumpDir   string
+	ByteDumpLimit int64
+
+	// 가상 호스트별로 스트림을 분리 (connect의 vhost 파라미터 또는 tcUrl 호스트, 스트림 경로는 vhost/app/stream)
+	VirtualHosts bool
+
+	// 스트림 온라인/오프라인, 플레이어 수 변경 알림을 보낼 채널 (nil이면 보내지 않음)
+	// 이벤트 루프를 막지 않도록 채널이 가득 차면 알림을 드롭
+	StatusEvents chan<- interface{}
+
+	// 서버 이벤트 채널 버퍼 크기 (0이면 DEFAULT_EVENT_CHANNEL_SIZE)
+	// 모든 세션이 공유하므로 스트림이 많을수록 크게 잡아야 버스트 시 이벤트 드롭이 줄어든다
+	EventChannelSize int
+
+	// 세션별 메시지 채널 버퍼 크기 (0이면 DEFAULT_SESSION_CHANNEL_SIZE)
+	SessionChannelSize int
+
+	// 연결별 읽기 버퍼 크기 (0이면 DEFAULT_READ_BUFFER_SIZE)
+	// 청크 헤더 같은 작은 읽기를 모아 소켓 읽기 시스템 콜 수를 줄인다
+	ReadBufferSize int
+
+	// 재생 시 재개 토큰을 발급하고, 토큰으로 재연결한 플레이어는 끊긴 위치 근처부터 이어서 재생
+	// ResumeTokenTTL은 연결이 끊긴 뒤 토큰이 유효한 시간 (0이면 30초)
+	ResumablePlay  bool
+	ResumeTokenTTL time.Duration
+
+	// 레거시 Flash 클라이언트의 소켓 정책 파일 요청에 응답할 cross-domain 정책 XML (빈 값이면 비활성화)
+	CrossDomainPolicy string
+
+	// 스트림별 최대 발행 비트레이트 (bps, 0이면 무제한)
+	// 최근 5초 평균 수신 비트레이트가 이를 넘으면 발행자에게 알리고 연결을 끊는다
+	MaxPublishBitrate int64
+
+	// FCPublish/FCUnpublish 응답 형식 (빈 값이면 srs: _result 후 onFCPublish, fms: onFCPublish만 전송)
+	FCPublishStyle FCPublishStyle
+
+	// 알 수 없는 AMF0 명령어 응답 방식 (빈 값이면 UnknownCommandError: transaction ID가 있으면 _error 응답)
+	UnknownCommandPolicy UnknownCommandPolicy
+
+	// publish/play 등 스트림 이름에 허용하는 문자 범위 (빈 값이면 StreamNameUnicode)
+	// 맞지 않는 이름은 BadName/StreamNotFound 상태로 거부
+	StreamNamePolicy StreamNamePolicy
+
+	// 플레이어가 connect의 audioCodecs/videoCodecs로 지원한다고 알리지 않은 코덱의 미디어 처리 방식 (빈 값이면 UnsupportedCodecWarn)
+	UnsupportedCodecPolicy UnsupportedCodecPolicy
+
+	// 세션별 초당 최대 AMF 명령어 수 (0이면 무제한), 초과하면 연결 종료
+	// 발행 시작 시퀀스 같은 짧은 버스트는 max(MaxCommandRate, 16)개까지 허용
+	MaxCommandRate int
+
+	// 이벤트 처리, 프레임 브로드캐스트, 청크 인코딩 시간을 히스토그램으로 집계 (GetEventTimings로 조회)
+	EventTiming bool
+
+	// 처리되지 않은 이벤트(알 수 없는 이벤트 타입)를 타입과 요약으로 경고 로그 (개수는 항상 집계)
+	LogUnhandledEvents bool
+
+	// 플레이어에게 전달할 onMetaData 키 필터 (비어 있으면 모든 키 전달)
+	MetadataFilter MetadataFilter
+
+	// onMetaData를 현재 스트림 타임스탬프와 플레이어의 스트림 ID로 전송 (false면 타임스탬프 0, 스트림 ID 0)
+	// 중간에 입장한 플레이어 중 미디어 뒤에 오는 타임스탬프 0 메타데이터를 거부하는 경우에 켠다
+	StampMetadata bool
+
+	// 중계 중 오디오와 비디오 타임스탬프가 서로 멀어지면 비디오 타임스탬프를 조금씩 옮겨 보정
+	// AVSyncTolerance는 보정하지 않고 허용하는 드리프트 (0이면 40ms)
+	AVSyncCorrection bool
+	AVSyncTolerance  time.Duration
+
+	// 발행자가 없는 스트림(재연결 유예 중, 발행 전 등)을 재생할 때의 동작 (빈 값이면 OfflinePlayHold)
+	OfflinePlay OfflinePlayPolicy
+
+	// connect 시 클라이언트에게 알릴 Window Acknowledgement Size (바이트, 0이면 알리지 않고 검사하지 않음)
+	// 클라이언트가 ack를 보내지 않아 미확인 바이트가 AckWindowSize * AckStallFactor를 넘으면 더 보내지 않고 연결 종료
+	// AckStallFactor가 0이면 DEFAULT_ACK_STALL_FACTOR
+	AckWindowSize  uint32
+	AckStallFactor int
+
+	// 플레이어가 없는 스트림의 캐시(GOP, sequence header, 오디오)가 이 시간 동안 쓰이지 않으면 압축 (0이면 압축하지 않음)
+	// 다음 플레이어 입장이나 새 프레임에서 풀며, IdleCacheMinBytes보다 작은 캐시는 압축하지 않는다
+	IdleCacheCompressAfter time.Duration
+	IdleCacheMinBytes      int
+
+	// 플레이어 없이 발행 중인 스트림이 유지할 워밍업 버퍼 길이 (0이면 비활성화)
+	// 예약 방송처럼 미리 발행해 둔 스트림의 첫 플레이어에게 이 길이의 캐시를 바로 보낸다
+	WarmUpBuffer time.Duration
+
+	// 마지막 플레이어가 나간 뒤 스트림의 유휴 핸들러(SetIdleHandler)를 호출하기까지의 시간 (0이면 비활성화)
+	// 풀로 가져오는 스트림이 보는 사람이 없을 때 업스트림을 끊으며, 그 안에 플레이어가 다시 입장하면 취소
+	StreamPresenceTTL time.Duration
+
+	// 비활성 스트림(IsActive가 false) 정리 주기 (0이면 주기적으로 정리하지 않음)
+	// 스트림은 마지막 플레이어/발행자가 나갈 때 제거되지만, 이벤트 드롭 등으로 남은 스트림을 회수한다
+	StreamSweepInterval time.Duration
+
+	// 수락한 TCP 연결에 설정할 소켓 옵션 (nil이면 Go 기본값 유지)
+	TCPOptions *tcpopt.Options
 }
 
+// OfflinePlayPolicy는 발행자가 없는 스트림 재생 요청 처리 방식
+type OfflinePlayPolicy string
+
+const (
+	OfflinePlayHold     OfflinePlayPolicy = "hold"      // 남은 캐시를 한 번 보내고 발행자가 올 때까지 대기
+	OfflinePlayNotFound OfflinePlayPolicy = "not_found" // NetStream.Play.StreamNotFound로 응답하고 플레이어를 추가하지 않음
+)
+
+// ErrTooManyStreams는 최대 스트림 수에 도달해 새 스트림을 만들 수 없는 경우의 에러
+var ErrTooManyStreams = errors.New("maximum number of streams reached")
+
+// 재연결 유예 만료 검사 주기
+const publisherGraceCheckInterval = time.Second
+
+// Accept 일시적 오류 시 재시도 대기 시간 (지수 증가)
+const (
+	acceptRetryMinDelay = 5 * time.Millisecond
+	acceptRetryMaxDelay = time.Second
+)
+
 type Server struct {
 	sessions map[string]*session // sessionId를 키로 사용
 	streams  map[string]*Stream  // 스트림 직접 관리
@@ -22,20 +197,46 @@ type Server struct {
 	listener net.Listener        // 리스너 참조 저장
 	ctx      context.Context     // 컨텍스트
 	cancel   context.CancelFunc  // 컨텍스트 취소 함수
+	stopOnce sync.Once           // Stop을 여러 번 호출해도 한 번만 종료
 	streamConfig StreamConfig     // 스트림 설정
+	access       *acl.Policy      // 접속/발행/재생 IP 접근 제어 (nil이면 모두 허용)
+
+	resumeStates map[string]resumeState // 재생 재개 토큰별 끊긴 플레이어의 재생 위치
+
+	rejectedStreams  atomic.Uint64 // 최대 스트림 수 초과로 거부된 스트림 생성 횟수
+	sweptStreams     atomic.Uint64 // 주기적 정리로 회수한 비활성 스트림 수
+	lengthMismatches atomic.Uint64 // 메시지 길이 불일치 횟수 (ValidateMessageLength 설정 시, 세션 goroutine에서 갱신)
+	droppedEvents    atomic.Uint64 // 이벤트 채널이 가득 차 드롭된 이벤트 수 (세션 goroutine에서 갱신)
+	protocolErrors   atomic.Uint64 // 프로토콜 위반으로 끊은 연결 수 (세션 goroutine에서 갱신)
+
+	deadLetters *deadletter.Recorder // 처리되지 않은 이벤트 기록
+	timings     *eventTimings        // 내부 처리 시간 히스토그램 (EventTiming이 꺼져 있으면 nil)
+
+	// 스트림 상태 알림 (StatusEvents 설정 시)
+	notifiedStatus      map[string]streamStatus // 스트림별 마지막으로 알린 상태
+	droppedStatusEvents atomic.Uint64           // 채널이 가득 차 드롭된 상태 알림 수
+	codecMismatches     atomic.Uint64           // 메타데이터와 프레임의 코덱 불일치 수
 }
 
-func NewServer(port int, streamConfig StreamConfig) *Server {
+func NewServer(port int, streamConfig StreamConfig, access *acl.Policy) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	server := &Server{
 		sessions: make(map[string]*session), // sessionId를 키로 사용
 		streams:  make(map[string]*Stream),  // 스트림 맵 초기화
 		port:     port,
-		channel:  make(chan interface{}, 100),
+		channel:  make(chan interface{}, channelSize(streamConfig.EventChannelSize, DEFAULT_EVENT_CHANNEL_SIZE)),
 		ctx:      ctx,
 		cancel:   cancel,
 		streamConfig: streamConfig,
+		access:       access,
+		resumeStates: make(map[string]resumeState),
+		deadLetters:  deadletter.New("rtmp", streamConfig.LogUnhandledEvents),
+
+		notifiedStatus: make(map[string]streamStatus),
+	}
+	if streamConfig.EventTiming {
+		server.timings = newEventTimings()
 	}
 	return server
 }
@@ -56,7 +257,12 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Stop은 서버를 종료 (여러 번 호출해도 안전)
 func (s *Server) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
 	slog.Info("Server stopping...")
 
 	// 1. 컨텍스트 취소 (모든 고루틴에 종료 신호)
@@ -109,10 +315,50 @@ cleanup_done:
 }
 
 func (s *Server) eventLoop() {
+	// 재연결 유예가 설정된 경우에만 만료 검사 (nil 채널은 select에서 무시됨)
+	var graceCheck <-chan time.Time
+	if s.streamConfig.PublisherReconnectGrace > 0 {
+		ticker := time.NewTicker(publisherGraceCheckInterval)
+		defer ticker.Stop()
+		graceCheck = ticker.C
+	}
+	// 유휴 캐시 압축이 설정된 경우에만 검사
+	var idleCacheCheck <-chan time.Time
+	if s.streamConfig.IdleCacheCompressAfter > 0 {
+		ticker := time.NewTicker(idleCacheCheckInterval)
+		defer ticker.Stop()
+		idleCacheCheck = ticker.C
+	}
+	// 프레즌스 TTL이 설정된 경우에만 검사
+	var presenceCheck <-chan time.Time
+	if s.streamConfig.StreamPresenceTTL > 0 {
+		ticker := time.NewTicker(presenceCheckInterval)
+		defer ticker.Stop()
+		presenceCheck = ticker.C
+	}
+	// 비활성 스트림 정리가 설정된 경우에만 검사
+	var streamSweep <-chan time.Time
+	if s.streamConfig.StreamSweepInterval > 0 {
+		ticker := time.NewTicker(s.streamConfig.StreamSweepInterval)
+		defer ticker.Stop()
+		streamSweep = ticker.C
+	}
+
 	for {
 		select {
 		case data := <-s.channel:
+			start := time.Now()
 			s.channelHandler(data)
+			s.timings.observeEventHandling(start)
+		case now := <-graceCheck:
+			s.expirePublisherGrace(now)
+			s.notifyStatusChanges()
+		case now := <-idleCacheCheck:
+			s.compressIdleCaches(now)
+		case now := <-presenceCheck:
+			s.expireStreamPresence(now)
+		case <-streamSweep:
+			s.sweepInactiveStreams()
 		case <-s.ctx.Done():
 			slog.Info("Event loop stopping...")
 			return
@@ -124,18 +370,35 @@ func (s *Server) channelHandler(data interface{}) {
 	switch v := data.(type) {
 	case Terminated:
 		s.TerminatedEventHandler(v.Id)
+		s.notifyStatusChanges()
+	case RoleAssigned:
+		slog.Info("Session role assigned", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "role", v.Role, "source", v.Source)
 	case PublishStarted:
-		slog.Info("Publish started", "sessionId", v.SessionId, "streamName", v.StreamName, "streamId", v.StreamId)
+		slog.Info("Publish started", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName, "streamId", v.StreamId)
 		s.handlePublishStarted(v)
+		s.notifyStatusChanges()
+	case ReleaseStreamRequested:
+		slog.Info("Release stream requested", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName)
+		s.handleReleaseStream(v)
+		s.notifyStatusChanges()
+	case PublishReserved:
+		slog.Info("Publish reserved", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName)
+		s.handlePublishReserved(v)
+	case StreamError:
+		slog.Warn("Stream error", "sessionId", v.SessionId, "streamName", v.StreamName, "code", v.Code, "description", v.Description)
+		s.handleStreamError(v)
 	case PublishStopped:
-		slog.Info("Publish stopped", "sessionId", v.SessionId, "streamName", v.StreamName, "streamId", v.StreamId)
+		slog.Info("Publish stopped", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName, "streamId", v.StreamId)
 		s.handlePublishStopped(v)
+		s.notifyStatusChanges()
 	case PlayStarted:
-		slog.Info("Play started", "sessionId", v.SessionId, "streamName", v.StreamName, "streamId", v.StreamId)
+		slog.Info("Play started", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName, "streamId", v.StreamId)
 		s.handlePlayStarted(v)
+		s.notifyStatusChanges()
 	case PlayStopped:
-		slog.Info("Play stopped", "sessionId", v.SessionId, "streamName", v.StreamName, "streamId", v.StreamId)
+		slog.Info("Play stopped", "sessionId", v.SessionId, "commandSeq", v.CommandSeq, "streamName", v.StreamName, "streamId", v.StreamId)
 		s.handlePlayStopped(v)
+		s.notifyStatusChanges()
 	case AudioData:
 		slog.Debug("Audio data received", "sessionId", v.SessionId, "streamName", v.StreamName, "timestamp", v.Timestamp, "dataSize", len(v.Data))
 		s.handleAudioData(v)
@@ -145,8 +408,13 @@ func (s *Server) channelHandler(data interface{}) {
 	case MetaData:
 		slog.Info("Metadata received", "sessionId", v.SessionId, "streamName", v.StreamName, "metadata", v.Metadata)
 		s.handleMetaData(v)
+	case DataMessage:
+		slog.Debug("Data message received", "sessionId", v.SessionId, "streamName", v.StreamName, "name", v.Name, "timestamp", v.Timestamp)
+		s.handleDataMessage(v)
+	case cacheSummaryRequest:
+		s.handleCacheSummaryRequest(v)
 	default:
-		slog.Warn("Unknown event type", "eventType", fmt.Sprintf("%T", v))
+		s.deadLetters.Record(v)
 	}
 }
 
@@ -186,9 +454,41 @@ func (s *Server) handlePublishStarted(event PublishStarted) {
 		return
 	}
 
-	// 스트림 생성 또는 가져오기
-	stream := s.GetOrCreateStream(event.StreamName, s.streamConfig)
-	stream.SetPublisher(publisher) // session 객체 직접 전달
+	// releaseStream 없이 재발행한 경우에도 남아있는 이전 발행자는 해제 (마지막 발행자 우선)
+	if existing := s.GetStream(event.StreamName); existing != nil {
+		if previous := existing.GetPublisher(); previous != nil && previous != publisher {
+			s.evictPublisher(existing, previous, event.StreamName)
+		}
+	}
+
+	// 스트림 생성 또는 가져오기 (최대 스트림 수 초과 시 발행 거부 후 연결 종료)
+	stream, err := s.GetOrCreateStream(event.StreamName, s.streamConfig)
+	if err != nil {
+		slog.Warn("Publish rejected", "streamName", event.StreamName, "sessionId", event.SessionId, "err", err)
+		if err := publisher.sendStatus("error", "NetStream.Publish.Rejected", "Too many streams", event.StreamName); err != nil {
+			slog.Error("Failed to send publish rejection", "sessionId", event.SessionId, "err", err)
+		}
+		closeWithLog(publisher.conn)
+		return
+	}
+	resumed := stream.ResumePublisher() // 재연결 유예 중이면 기존 스트림에 이어서 발행
+	stream.SetPublisher(publisher)      // session 객체 직접 전달
+
+	// 발행 유형에 따른 녹화 시작 (live는 녹화하지 않음, 재연결 시 진행 중인 녹화는 유지)
+	mode, err := parsePublishType(event.PublishType)
+	if resumed && stream.IsRecording() {
+		slog.Info("Continuing recording after publisher reconnect", "streamName", event.StreamName)
+	} else if err != nil {
+		slog.Error("Invalid publish type", "streamName", event.StreamName, "publishType", event.PublishType, "err", err)
+	} else if mode != RecordModeNone {
+		path, err := s.recordFilePath(publisher, time.Now())
+		if err == nil {
+			err = stream.StartRecording(path, mode)
+		}
+		if err != nil {
+			slog.Error("Failed to start recording", "streamName", event.StreamName, "publishType", event.PublishType, "err", err)
+		}
+	}
 
 	slog.Info("Publisher registered", "streamName", event.StreamName, "sessionId", event.SessionId)
 }
@@ -200,12 +500,139 @@ func (s *Server) handlePublishStopped(event PublishStopped) {
 		return
 	}
 
+	// 이미 해제되어 다른 세션이 발행 중인 스트림의 늦은 종료 이벤트는 무시
+	if publisher := stream.GetPublisher(); publisher == nil || publisher.sessionId != event.SessionId {
+		slog.Info("Ignoring publish stop from replaced publisher", "streamName", event.StreamName, "sessionId", event.SessionId)
+		return
+	}
+
+	// 명시적인 발행 종료는 재연결을 기다리지 않고 바로 정리 (유예는 예기치 않은 연결 끊김에만 적용)
+	if event.Unpublished {
+		s.removePublisher(stream, event.StreamName)
+		return
+	}
+	s.detachPublisher(stream, event.StreamName)
+}
+
+// releaseStream으로 다른 주소의 발행자를 해제할 수 있는 미디어 무수신 시간 (끊긴 연결로 판단)
+const releaseStreamStaleAfter = 10 * time.Second
+
+// releaseStream 처리: 같은 주소에서 재연결했거나 미디어가 끊긴 이전 발행자만 연결을 끊고 해제
+// (다른 클라이언트의 releaseStream으로 정상 발행 중인 스트림을 빼앗지 않음)
+func (s *Server) handleReleaseStream(event ReleaseStreamRequested) {
+	stream := s.GetStream(event.StreamName)
+	if stream == nil {
+		return
+	}
+
+	publisher := stream.GetPublisher()
+	if publisher == nil || publisher.sessionId == event.SessionId {
+		return
+	}
+	requester := s.findSessionById(event.SessionId)
+	if requester == nil {
+		return
+	}
+	if !sameClientIP(requester, publisher) && !stream.publisherStale(time.Now(), releaseStreamStaleAfter) {
+		slog.Warn("Ignoring releaseStream for an active publisher from another address", "streamName", event.StreamName, "sessionId", event.SessionId, "publisherSessionId", publisher.sessionId)
+		return
+	}
+	s.evictPublisher(stream, publisher, event.StreamName)
+}
+
+// sameClientIP는 두 세션이 같은 IP에서 연결했는지 확인
+func sameClientIP(a, b *session) bool {
+	ipA, ipB := acl.AddrIP(a.conn.RemoteAddr()), acl.AddrIP(b.conn.RemoteAddr())
+	return ipA != nil && ipA.Equal(ipB)
+}
+
+// 스트림 오류 처리: 현재 발행자가 보고한 오류를 플레이어에게 error 레벨 onStatus로 전달
+func (s *Server) handleStreamError(event StreamError) {
+	stream := s.GetStream(event.StreamName)
+	if stream == nil {
+		return
+	}
+
+	// 이미 교체된 발행자의 오류는 새 발행자의 스트림과 무관
+	if publisher := stream.GetPublisher(); publisher == nil || publisher.sessionId != event.SessionId {
+		slog.Info("Ignoring stream error from replaced publisher", "streamName", event.StreamName, "sessionId", event.SessionId)
+		return
+	}
+
+	stream.BroadcastError(event.Code, event.Description)
+}
+
+// FCPublish 처리: publish 전까지 스트림을 만들어 두고 발행 의사를 기록
+func (s *Server) handlePublishReserved(event PublishReserved) {
+	publisher := s.findSessionById(event.SessionId)
+	if publisher == nil {
+		slog.Error("Publisher session not found", "sessionId", event.SessionId)
+		return
+	}
+
+	stream, err := s.GetOrCreateStream(event.StreamName, s.streamConfig)
+	if err != nil {
+		slog.Warn("Publish reservation rejected", "streamName", event.StreamName, "sessionId", event.SessionId, "err", err)
+		return
+	}
+	stream.ReservePublisher(publisher)
+}
+
+// 이전 발행자의 연결을 끊고 스트림에서 분리 (세션의 늦은 종료 이벤트는 무시됨)
+func (s *Server) evictPublisher(stream *Stream, publisher *session, streamName string) {
+	slog.Info("Evicting previous publisher", "streamName", streamName, "sessionId", publisher.sessionId)
+	closeWithLog(publisher.conn)
+	s.detachPublisher(stream, streamName)
+}
+
+// 발행자를 스트림에서 분리 (재연결 유예 설정 시 유지, 아니면 정리)
+func (s *Server) detachPublisher(stream *Stream, streamName string) {
+	// 재연결 유예가 설정되어 있으면 캐시/녹화/플레이어를 유지한 채 대기
+	if s.streamConfig.PublisherReconnectGrace > 0 {
+		stream.SuspendPublisher(time.Now())
+		slog.Info("Publisher disconnected, stream kept for reconnect", "streamName", streamName, "grace", s.streamConfig.PublisherReconnectGrace)
+		return
+	}
+	s.removePublisher(stream, streamName)
+}
+
+// 발행자를 스트림에서 제거하고 비활성 스트림 정리
+func (s *Server) removePublisher(stream *Stream, streamName string) {
 	stream.RemovePublisher()
-	slog.Info("Publisher unregistered", "streamName", event.StreamName, "sessionId", event.SessionId)
+	slog.Info("Publisher unregistered", "streamName", streamName)
 
 	// 스트림이 비활성 상태면 제거
 	if !stream.IsActive() {
-		s.RemoveStream(event.StreamName)
+		s.RemoveStream(streamName)
+	}
+}
+
+// 재연결 유예 시간이 지난 스트림의 발행자 정리
+func (s *Server) expirePublisherGrace(now time.Time) {
+	for streamName, stream := range s.streams {
+		if !stream.PublisherGraceExpired(now, s.streamConfig.PublisherReconnectGrace) {
+			continue
+		}
+
+		stream.RemovePublisher()
+		slog.Info("Publisher reconnect grace expired", "streamName", streamName)
+
+		// 스트림이 비활성 상태면 제거
+		if !stream.IsActive() {
+			s.RemoveStream(streamName)
+		}
+	}
+}
+
+// sweepInactiveStreams는 이벤트로 제거되지 못하고 남은 비활성 스트림을 제거 (이벤트 루프에서 주기적으로 호출)
+func (s *Server) sweepInactiveStreams() {
+	for streamName, stream := range s.streams {
+		if stream.IsActive() {
+			continue
+		}
+		swept := s.sweptStreams.Add(1)
+		slog.Warn("Sweeping inactive stream left behind", "streamName", streamName, "sweptStreams", swept)
+		s.RemoveStream(streamName)
 	}
 }
 
@@ -217,10 +644,51 @@ func (s *Server) handlePlayStarted(event PlayStarted) {
 		slog.Error("Player session not found", "sessionId", event.SessionId)
 		return
 	}
+	// 세션이 play를 받은 시점의 스트림 ID (세션 goroutine이 바꾸는 streamID는 읽지 않음)
+	player.playStreamID = event.StreamId
+
+	// 발행자가 없는 스트림이면 정책에 따라 오프라인으로 응답 (스트림을 만들지 않음)
+	if s.streamConfig.OfflinePlay == OfflinePlayNotFound {
+		if existing := s.GetStream(event.StreamName); existing == nil || existing.GetPublisher() == nil {
+			slog.Info("Play rejected, stream is offline", "streamName", event.StreamName, "sessionId", event.SessionId)
+			if err := player.sendStatus("error", "NetStream.Play.StreamNotFound", "Stream is offline", event.StreamName); err != nil {
+				slog.Error("Failed to send play rejection", "sessionId", event.SessionId, "err", err)
+			}
+			return
+		}
+	}
 
-	// 스트림 생성 또는 가져오기
-	stream := s.GetOrCreateStream(event.StreamName, s.streamConfig)
-	stream.AddPlayer(player) // session 객체 직접 전달 (캐시 데이터 자동 전송)
+	// 스트림 생성 또는 가져오기 (최대 스트림 수 초과 시 재생 실패 응답)
+	stream, err := s.GetOrCreateStream(event.StreamName, s.streamConfig)
+	if err != nil {
+		slog.Warn("Play rejected", "streamName", event.StreamName, "sessionId", event.SessionId, "err", err)
+		if err := player.sendStatus("error", "NetStream.Play.Failed", "Too many streams", event.StreamName); err != nil {
+			slog.Error("Failed to send play rejection", "sessionId", event.SessionId, "err", err)
+		}
+		return
+	}
+
+	// 저지연 모드: 캐시 전송 전에 송신 큐를 준비
+	if s.streamConfig.LatencyBudget > 0 {
+		player.enableLowLatency(player.latencyBudget(s.streamConfig.LatencyBudget))
+		player.dropLimit = s.streamConfig.MaxPlayerDroppedFrames
+	}
+
+	// 초기 버스트 페이싱: 캐시를 송신 큐 goroutine에서 나눠 보낸다 (이벤트 루프는 기다리지 않음)
+	// 플레이어가 SetBufferLength로 알린 버퍼 길이보다 길게 나눠 보내지는 않는다
+	if s.streamConfig.InitialBurstPacing > 0 {
+		player.burstPacing = player.burstWindow(s.streamConfig.InitialBurstPacing)
+		player.enableLowLatency(player.latencyBudget(s.streamConfig.LatencyBudget))
+	}
+
+	// 재생 재개 토큰으로 재연결했으면 끊긴 위치 근처부터 이어서 재생
+	resumed := false
+	if s.streamConfig.ResumablePlay {
+		resumed = s.resumePlayer(stream, player, event.ResumeToken)
+	}
+	if !resumed {
+		stream.AddPlayer(player) // session 객체 직접 전달 (캐시 데이터 자동 전송)
+	}
 
 	slog.Info("Player registered", "streamName", event.StreamName, "sessionId", event.SessionId, "playerCount", stream.GetPlayerCount())
 }
@@ -240,6 +708,9 @@ func (s *Server) handlePlayStopped(event PlayStopped) {
 	}
 
 	stream.RemovePlayer(player) // session 객체 직접 전달
+	if s.streamConfig.ResumablePlay {
+		s.saveResumeState(player, event.StreamName, time.Now())
+	}
 	slog.Info("Player unregistered", "streamName", event.StreamName, "sessionId", event.SessionId, "playerCount", stream.GetPlayerCount())
 
 	// 스트림이 비활성 상태면 제거
@@ -254,6 +725,11 @@ func (s *Server) handleAudioData(event AudioData) {
 	if stream == nil {
 		return
 	}
+	now := time.Now()
+	if s.enforceMaxPublishBitrate(stream, event.SessionId, chunksSize(event.Data), now) {
+		return
+	}
+	stream.notePublisherMedia(event.SessionId, now)
 
 	// Stream에서 직접 처리 및 전송
 	stream.ProcessAudioData(event)
@@ -265,6 +741,11 @@ func (s *Server) handleVideoData(event VideoData) {
 	if stream == nil {
 		return
 	}
+	now := time.Now()
+	if s.enforceMaxPublishBitrate(stream, event.SessionId, chunksSize(event.Data), now) {
+		return
+	}
+	stream.notePublisherMedia(event.SessionId, now)
 
 	// Stream에서 직접 처리 및 전송 (GOP 캐시 업데이트 포함)
 	stream.ProcessVideoData(event)
@@ -281,20 +762,75 @@ func (s *Server) handleMetaData(event MetaData) {
 	stream.ProcessMetaData(event)
 }
 
+// 데이터 메시지 처리 (미디어 없이 데이터만 발행하는 스트림 포함)
+func (s *Server) handleDataMessage(event DataMessage) {
+	stream := s.GetStream(event.StreamName)
+	if stream == nil {
+		return
+	}
+
+	// Stream에서 직접 처리 및 전송 (데이터 메시지 캐시 포함)
+	stream.ProcessDataMessage(event)
+}
+
 // 세션 ID로 세션 찾기
 func (s *Server) findSessionById(sessionId string) *session {
 	return s.sessions[sessionId] // nil이 자동으로 반환됨
 }
 
-// GetOrCreateStream은 스트림을 가져오거나 생성
-func (s *Server) GetOrCreateStream(streamName string, config StreamConfig) *Stream {
+// GetOrCreateStream은 스트림을 가져오거나 생성 (최대 스트림 수에 도달하면 ErrTooManyStreams)
+func (s *Server) GetOrCreateStream(streamName string, config StreamConfig) (*Stream, error) {
 	stream, exists := s.streams[streamName]
 	if !exists {
+		if config.MaxStreams > 0 && len(s.streams) >= config.MaxStreams {
+			rejected := s.rejectedStreams.Add(1)
+			slog.Warn("Maximum streams reached", "streamName", streamName, "maxStreams", config.MaxStreams, "rejectedStreams", rejected)
+			return nil, ErrTooManyStreams
+		}
 		stream = NewStream(streamName, config.GopCacheSize, config.MaxPlayersPerStream)
+		stream.SetWaitForPlayable(config.WaitForPlayable)
+		stream.SetRecordingSink(config.RecordingSink)
+		stream.SetCacheEviction(config.CacheEviction, config.CacheDuration)
+		stream.SetWarmUp(config.WarmUpBuffer)
+		stream.SetMetadataFilter(config.MetadataFilter)
+		stream.SetMetadataStamping(config.StampMetadata)
+		stream.SetAVSyncCorrection(config.AVSyncCorrection, config.AVSyncTolerance)
+		stream.onCodecMismatch = s.reportCodecMismatch
+		stream.timings = s.timings
 		s.streams[streamName] = stream
 		slog.Info("Created new stream", "streamName", streamName, "gopCacheSize", config.GopCacheSize, "maxPlayers", config.MaxPlayersPerStream)
 	}
-	return stream
+	return stream, nil
+}
+
+// GetMessageLengthMismatchCount는 메시지 길이 검증에서 발견된 불일치 횟수를 반환
+func (s *Server) GetMessageLengthMismatchCount() uint64 {
+	return s.lengthMismatches.Load()
+}
+
+// GetDroppedEventCount는 이벤트 채널이 가득 차 드롭된 세션 이벤트 수를 반환
+func (s *Server) GetDroppedEventCount() uint64 {
+	return s.droppedEvents.Load()
+}
+
+// GetProtocolErrorCount는 프로토콜 위반(잘못된 chunk stream ID 등)으로 끊은 연결 수를 반환
+func (s *Server) GetProtocolErrorCount() uint64 {
+	return s.protocolErrors.Load()
+}
+
+// GetUnhandledEventCount는 처리할 핸들러가 없어 버려진 이벤트 수를 반환
+func (s *Server) GetUnhandledEventCount() uint64 {
+	return s.deadLetters.Count()
+}
+
+// GetRejectedStreamCount는 최대 스트림 수 초과로 거부된 스트림 생성 횟수를 반환
+func (s *Server) GetRejectedStreamCount() uint64 {
+	return s.rejectedStreams.Load()
+}
+
+// GetSweptStreamCount는 주기적 정리로 회수한 비활성 스트림 수를 반환
+func (s *Server) GetSweptStreamCount() uint64 {
+	return s.sweptStreams.Load()
 }
 
 // GetStream은 스트림을 가져옴 (없으면 nil 반환)
@@ -302,6 +838,12 @@ func (s *Server) GetStream(streamName string) *Stream {
 	return s.streams[streamName]
 }
 
+// recordFilePath는 발행자의 앱에 맞는 템플릿으로 녹화 파일 경로를 만든다
+func (s *Server) recordFilePath(publisher *session, now time.Time) (string, error) {
+	template := s.streamConfig.recordPathTemplate(publisher.appName)
+	return expandRecordPath(s.streamConfig.RecordPath, template, publisher.vhost, publisher.appName, publisher.streamName, now)
+}
+
 // RemoveStream은 스트림을 제거
 func (s *Server) RemoveStream(streamName string) {
 	delete(s.streams, streamName)
@@ -323,6 +865,7 @@ func (s *Server) createListener() (net.Listener, error) {
 
 func (s *Server) acceptConnections(ln net.Listener) {
 	defer closeWithLog(ln)
+	var retryDelay time.Duration
 	for {
 		// 컨텍스트 취소 확인
 		select {
@@ -341,9 +884,37 @@ func (s *Server) acceptConnections(ln net.Listener) {
 				slog.Info("Accept loop stopped (listener closed)")
 				return
 			default:
-				slog.Error("Accept failed", "err", err)
+			}
+
+			// 리스너 자체가 닫혔으면 종료
+			if errors.Is(err, net.ErrClosed) {
+				slog.Info("Accept loop stopped (listener closed)")
 				return
 			}
+
+			// EMFILE 등 일시적 오류는 잠시 대기 후 재시도
+			retryDelay = nextAcceptRetryDelay(retryDelay)
+			slog.Error("Accept failed, retrying", "err", err, "retryDelay", retryDelay)
+			select {
+			case <-s.ctx.Done():
+				slog.Info("Accept loop stopping...")
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		retryDelay = 0
+
+		// 허용되지 않은 주소는 핸드셰이크 전에 연결 종료
+		if !s.access.AllowConnect(conn.RemoteAddr()) {
+			slog.Warn("Connection rejected by access control", "remoteAddr", conn.RemoteAddr())
+			closeWithLog(conn)
+			continue
+		}
+
+		// 소켓 옵션 설정 실패는 연결을 끊을 이유가 아니므로 경고만 남김
+		if err := s.streamConfig.TCPOptions.Apply(conn); err != nil {
+			slog.Warn("Failed to apply TCP options", "remoteAddr", conn.RemoteAddr(), "err", err)
 		}
 
 		// 세션 생성 시 서버의 이벤트 채널을 전달
@@ -354,6 +925,26 @@ func (s *Server) acceptConnections(ln net.Listener) {
 	}
 }
 
+// 다음 Accept 재시도 대기 시간 계산
+func nextAcceptRetryDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return acceptRetryMinDelay
+	}
+	delay *= 2
+	if delay > acceptRetryMaxDelay {
+		return acceptRetryMaxDelay
+	}
+	return delay
+}
+
+// 설정된 채널 버퍼 크기 (0 이하이면 기본값)
+func channelSize(configured, defaultSize int) int {
+	if configured <= 0 {
+		return defaultSize
+	}
+	return configured
+}
+
 // 채널을 연결한 세션 생성
 func (s *Server) newSessionWithChannel(conn net.Conn) *session {
 	session := &session{
@@ -361,7 +952,45 @@ func (s *Server) newSessionWithChannel(conn net.Conn) *session {
 		writer:          newMessageWriter(),
 		conn:            conn,
 		externalChannel: s.channel, // 서버의 이벤트 채널 연결
-		messageChannel:  make(chan *Message, 10),
+		messageChannel:  make(chan *Message, channelSize(s.streamConfig.SessionChannelSize, DEFAULT_SESSION_CHANNEL_SIZE)),
+		droppedEvents:   &s.droppedEvents,
+		protocolErrors:  &s.protocolErrors,
+		access:          s.access,
+		minChunkSize:    s.streamConfig.MinChunkSize,
+		readBufferSize:  channelSize(s.streamConfig.ReadBufferSize, DEFAULT_READ_BUFFER_SIZE),
+	}
+	session.connectedAt = time.Now()
+	if s.streamConfig.ByteDumpDir != "" {
+		if dump, err := newDumpConn(conn, s.streamConfig.ByteDumpDir, s.streamConfig.ByteDumpLimit); err != nil {
+			slog.Error("Failed to start byte dump", "addr", conn.RemoteAddr(), "err", err)
+		} else {
+			conn = dump
+			session.conn = conn
+		}
+	}
+	session.crossDomainPolicy = s.streamConfig.CrossDomainPolicy
+	session.fcPublishStyle = s.streamConfig.FCPublishStyle
+	session.unknownCommandPolicy = s.streamConfig.UnknownCommandPolicy
+	session.streamNamePolicy = s.streamConfig.StreamNamePolicy
+	session.unsupportedCodecPolicy = s.streamConfig.UnsupportedCodecPolicy
+	session.commandLimiter = newCommandLimiter(s.streamConfig.MaxCommandRate)
+	session.connectAuthenticator = s.streamConfig.ConnectAuthenticator
+	session.virtualHosts = s.streamConfig.VirtualHosts
+	session.writer.timings = s.timings
+	if s.streamConfig.AccessLogger != nil {
+		session.accessLogger = s.streamConfig.AccessLogger
+		session.counter = &countingConn{Conn: conn}
+		session.conn = session.counter
+	}
+	if s.streamConfig.AckWindowSize > 0 {
+		session.ackWindow = &ackWindowConn{Conn: session.conn}
+		session.ackWindowSize = s.streamConfig.AckWindowSize
+		session.ackStallFactor = s.streamConfig.AckStallFactor
+		session.conn = session.ackWindow
+	}
+	session.reader.setMaxMessageSize(s.streamConfig.MaxMessageSize)
+	if s.streamConfig.ValidateMessageLength {
+		session.reader.enableLengthValidation(&s.lengthMismatches)
 	}
 
 	// 포인터 주소값을 sessionId로 사용
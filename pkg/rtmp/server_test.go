@@ -0,0 +1,925 @@
+package rtmp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sol/pkg/acl"
+	"sol/pkg/amf"
+	"testing"
+	"time"
+)
+
+func mustNewList(t *testing.T, allow, deny []string) *acl.List {
+	t.Helper()
+	list, err := acl.NewList(allow, deny)
+	if err != nil {
+		t.Fatalf("failed to create access list: %v", err)
+	}
+	return list
+}
+
+func TestAcceptConnectionsRejectsDeniedAddress(t *testing.T) {
+	access := &acl.Policy{Connect: mustNewList(t, nil, []string{"127.0.0.0/8"})}
+	server := NewServer(0, StreamConfig{}, access)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	defer server.cancel()
+	go server.acceptConnections(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 핸드셰이크 전에 서버가 연결을 끊어야 함
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected connection to be closed, got %v", err)
+	}
+}
+
+func TestPublishDeniedByAccessControl(t *testing.T) {
+	// 발행은 내부망에서만 허용 (테스트 연결은 127.0.0.1)
+	publisher, conn := newTestPlayer(1)
+	publisher.access = &acl.Policy{Publish: mustNewList(t, []string{"10.0.0.0/8"}, nil)}
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+
+	publisher.handlePublish([]any{"publish", 5.0, nil, "test", "live"})
+
+	if publisher.isPublishing {
+		t.Fatal("expected publish to be rejected")
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %d", len(events))
+	}
+
+	// onStatus + _error
+	messages := readAllMessages(t, conn.buf.Bytes())
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	values, err := amf.DecodeAMF0Sequence(bytes.NewReader(bytes.Join(messages[0].payload, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, ok := values[3].(map[string]any)
+	if !ok || status["code"] != "NetStream.Publish.Denied" {
+		t.Fatalf("expected NetStream.Publish.Denied, got %v", values)
+	}
+}
+
+func TestPublishAllowedByAccessControl(t *testing.T) {
+	publisher, _ := newTestPlayer(1)
+	publisher.access = &acl.Policy{Publish: mustNewList(t, []string{"127.0.0.0/8"}, nil)}
+	publisher.appName = "live"
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+
+	publisher.handlePublish([]any{"publish", 5.0, nil, "test", "live"})
+
+	if !publisher.isPublishing {
+		t.Fatal("expected publish to be allowed")
+	}
+}
+
+func TestPublisherReconnectWithinGrace(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, PublisherReconnectGrace: 5 * time.Second}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-1"
+	player, conn := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.sessions[player.sessionId] = player
+
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+	stream := server.GetStream("live/test")
+
+	// 발행자 연결 끊김 (세션 종료까지)
+	server.handlePublishStopped(PublishStopped{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1})
+	server.TerminatedEventHandler(publisher.sessionId)
+
+	if server.GetStream("live/test") != stream {
+		t.Fatal("expected stream to be kept during reconnect grace")
+	}
+	if !stream.IsPublisherSuspended() {
+		t.Fatal("expected publisher to be suspended")
+	}
+
+	// 유예 시간 안에 같은 스트림 키로 재연결
+	reconnected, _ := newTestPlayer(1)
+	reconnected.sessionId = "publisher-2"
+	server.sessions[reconnected.sessionId] = reconnected
+	server.handlePublishStarted(PublishStarted{SessionId: reconnected.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+
+	if server.GetStream("live/test") != stream {
+		t.Fatal("expected reconnect to resume the existing stream")
+	}
+	if stream.IsPublisherSuspended() {
+		t.Fatal("expected publisher to be resumed")
+	}
+	if stream.GetPlayerCount() != 1 {
+		t.Fatalf("expected player to be kept, got %d players", stream.GetPlayerCount())
+	}
+
+	// 기존 플레이어가 재연결된 발행자의 미디어를 수신
+	conn.buf.Reset()
+	server.handleVideoData(VideoData{SessionId: reconnected.sessionId, StreamName: "live/test", Timestamp: 100, FrameType: "key frame", Data: [][]byte{{0x17, 0x01}}})
+	if messages := readAllMessages(t, conn.buf.Bytes()); len(messages) != 1 {
+		t.Fatalf("expected player to receive 1 message, got %d", len(messages))
+	}
+}
+
+func TestPlayOfflineStreamPolicy(t *testing.T) {
+	for _, policy := range []OfflinePlayPolicy{"", OfflinePlayHold, OfflinePlayNotFound} {
+		server := NewServer(0, StreamConfig{GopCacheSize: 10, PublisherReconnectGrace: 5 * time.Second, OfflinePlay: policy}, nil)
+
+		// 발행자 연결이 끊겨 캐시만 남은 스트림
+		publisher, _ := newTestPlayer(1)
+		publisher.sessionId = "publisher-1"
+		server.sessions[publisher.sessionId] = publisher
+		server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+		server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+		server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+		server.handlePublishStopped(PublishStopped{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1})
+		stream := server.GetStream("live/test")
+		if stream == nil || stream.GetPublisher() != nil {
+			t.Fatalf("%q: expected a publisher-less stream during reconnect grace", policy)
+		}
+
+		player, conn := newTestPlayer(1)
+		server.sessions[player.sessionId] = player
+		server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+
+		if policy == OfflinePlayNotFound {
+			if codes := readStatusCodes(t, conn); len(codes) != 1 || codes[0] != "NetStream.Play.StreamNotFound" {
+				t.Fatalf("%q: expected NetStream.Play.StreamNotFound, got %v", policy, codes)
+			}
+			if stream.GetPlayerCount() != 0 {
+				t.Fatalf("%q: expected player not to be added, got %d players", policy, stream.GetPlayerCount())
+			}
+
+			// 없는 스트림도 만들지 않고 같은 응답
+			conn.buf.Reset()
+			server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/unknown", StreamId: 1})
+			if codes := readStatusCodes(t, conn); len(codes) != 1 || codes[0] != "NetStream.Play.StreamNotFound" {
+				t.Fatalf("%q: expected NetStream.Play.StreamNotFound for unknown stream, got %v", policy, codes)
+			}
+			if server.GetStream("live/unknown") != nil {
+				t.Fatalf("%q: expected unknown stream not to be created", policy)
+			}
+		} else {
+			// 캐시를 한 번 받고 발행자를 기다림
+			videos := 0
+			for _, msg := range readAllMessages(t, conn.buf.Bytes()) {
+				if msg.messageHeader.typeId == MSG_TYPE_VIDEO {
+					videos++
+				}
+			}
+			if videos != 2 || stream.GetPlayerCount() != 1 {
+				t.Fatalf("%q: expected cached video once and a waiting player, got %d videos and %d players", policy, videos, stream.GetPlayerCount())
+			}
+		}
+		server.cancel()
+	}
+}
+
+func TestExplicitUnpublishSkipsReconnectGrace(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, PublisherReconnectGrace: 5 * time.Second}, nil)
+	defer server.cancel()
+
+	for _, command := range []string{"FCUnpublish", "closeStream", "deleteStream"} {
+		publisher, _ := newTestPlayer(0)
+		runOBSPublishSequence(t, server, publisher)
+		if server.GetStream("live/test") == nil {
+			t.Fatalf("%s: expected live/test to be published", command)
+		}
+
+		// 명시적으로 발행을 끝내면 재연결을 기다리지 않음
+		events := make(chan interface{}, 10)
+		publisher.externalChannel = events
+		var argument any = "test"
+		if command == "deleteStream" {
+			argument = float64(publisher.streamID)
+		}
+		publisher.handleAMF0Command(newTestCommand(t, command, 6.0, nil, argument))
+		for len(events) > 0 {
+			server.channelHandler(<-events)
+		}
+
+		if server.GetStream("live/test") != nil {
+			t.Fatalf("%s: expected stream to be removed without reconnect grace", command)
+		}
+		server.TerminatedEventHandler(publisher.sessionId)
+	}
+}
+
+func TestPublisherReconnectGraceExpires(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, PublisherReconnectGrace: 5 * time.Second}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	server.handleVideoData(VideoData{SessionId: publisher.sessionId, StreamName: "live/test", Timestamp: 0, FrameType: "key frame", Data: [][]byte{{0x17, 0x01}}})
+	server.handlePublishStopped(PublishStopped{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1})
+
+	// 유예 시간 전에는 유지
+	server.expirePublisherGrace(time.Now().Add(time.Second))
+	if server.GetStream("live/test") == nil {
+		t.Fatal("expected stream to be kept before grace expires")
+	}
+
+	// 유예 시간이 지나면 정리 (플레이어가 없으므로 스트림도 제거)
+	server.expirePublisherGrace(time.Now().Add(10 * time.Second))
+	if server.GetStream("live/test") != nil {
+		t.Fatal("expected stream to be removed after grace expires")
+	}
+}
+
+func TestInactiveStreamSweep(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, StreamSweepInterval: 10 * time.Millisecond}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/active", StreamId: 1, PublishType: "live"})
+
+	// 이벤트 드롭 등으로 제거되지 못하고 남은 빈 스트림
+	server.streams["live/stranded"] = NewStream("live/stranded", 10, 0)
+
+	go server.eventLoop()
+
+	// 이벤트 루프를 거쳐 조회하므로 정리 결과를 경쟁 없이 확인할 수 있음
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := server.GetCacheSummary("live/stranded")
+		if errors.Is(err, ErrStreamNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected stranded stream to be swept, got %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := server.GetCacheSummary("live/active"); err != nil {
+		t.Fatalf("expected published stream to be kept, got %v", err)
+	}
+	if server.GetSweptStreamCount() != 1 {
+		t.Fatalf("expected 1 swept stream, got %d", server.GetSweptStreamCount())
+	}
+}
+
+func TestOversizedMessageClosesConnection(t *testing.T) {
+	server := NewServer(0, StreamConfig{MaxMessageSize: 1024}, nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	defer server.cancel()
+	go server.acceptConnections(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// C0 + C1 + C2 후 16MB 길이를 선언한 청크 전송
+	data := append([]byte{RTMP_VERSION}, make([]byte, HANDSHAKE_SIZE*2)...)
+	data = append(data, oversizedChunkHeader(MAX_MESSAGE_LENGTH)...)
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// S0 + S1 + S2 수신 후 서버가 연결을 끊어야 함
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	received, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected connection to be closed, got %v", err)
+	}
+	if len(received) != 1+HANDSHAKE_SIZE*2 {
+		t.Fatalf("expected only handshake response, got %d bytes", len(received))
+	}
+}
+
+// 일시적 오류를 지정 횟수만큼 반환한 뒤 연결을 넘겨주는 리스너
+type flakyListener struct {
+	failures int
+	conns    chan net.Conn
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "accept: too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, temporaryError{}
+	}
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return conn, nil
+}
+
+func (l *flakyListener) Close() error   { return nil }
+func (l *flakyListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestAcceptConnectionsRecoversFromTemporaryError(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	ln := &flakyListener{failures: 3, conns: make(chan net.Conn, 1)}
+	ln.conns <- serverConn
+	close(ln.conns)
+
+	done := make(chan struct{})
+	go func() {
+		server.acceptConnections(ln)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected accept loop to exit after listener closed")
+	}
+	if ln.failures != 0 {
+		t.Fatalf("expected all temporary errors to be consumed, %d left", ln.failures)
+	}
+	if len(server.sessions) != 1 {
+		t.Fatalf("expected 1 session after recovery, got %d", len(server.sessions))
+	}
+}
+
+// OBS 발행 순서(releaseStream, FCPublish, createStream, publish)를 실행하고 발생한 이벤트를 서버에서 처리
+func runOBSPublishSequence(t *testing.T, server *Server, publisher *session) {
+	t.Helper()
+	events := make(chan interface{}, 10)
+	publisher.appName = "live"
+	publisher.externalChannel = events
+	server.sessions[publisher.sessionId] = publisher
+
+	publisher.handleAMF0Command(newTestCommand(t, "releaseStream", 2.0, nil, "test"))
+	publisher.handleAMF0Command(newTestCommand(t, "FCPublish", 3.0, nil, "test"))
+	publisher.handleAMF0Command(newTestCommand(t, "createStream", 4.0, nil))
+	publisher.handleAMF0Command(newTestCommand(t, "publish", 5.0, nil, "test", "live"))
+
+	for len(events) > 0 {
+		server.channelHandler(<-events)
+	}
+}
+
+func TestOBSPublishSequenceWithReconnect(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+	defer server.cancel()
+
+	first, firstConn := newTestPlayer(0)
+	first.sessionId = "publisher-1"
+	runOBSPublishSequence(t, server, first)
+
+	stream := server.GetStream("live/test")
+	if stream == nil || stream.GetPublisher() != first {
+		t.Fatal("expected first session to publish live/test")
+	}
+	if stream.IsPublishReserved() {
+		t.Fatal("expected reservation to be cleared after publish")
+	}
+
+	// 이전 연결이 남아있는 상태에서 OBS가 재연결
+	second, _ := newTestPlayer(0)
+	second.sessionId = "publisher-2"
+	runOBSPublishSequence(t, server, second)
+
+	if !firstConn.closed {
+		t.Fatal("expected lingering publisher connection to be closed by releaseStream")
+	}
+	stream = server.GetStream("live/test")
+	if stream == nil || stream.GetPublisher() != second {
+		t.Fatal("expected reconnected session to publish live/test")
+	}
+
+	// 끊긴 이전 세션의 늦은 종료 이벤트는 새 발행자에 영향 없음
+	server.handleVideoData(VideoData{SessionId: second.sessionId, StreamName: "live/test", Timestamp: 0, FrameType: "key frame", Data: [][]byte{{0x17, 0x01}}})
+	server.handlePublishStopped(PublishStopped{SessionId: first.sessionId, StreamName: "live/test", StreamId: 1})
+	server.TerminatedEventHandler(first.sessionId)
+
+	if server.GetStream("live/test") != stream || stream.GetPublisher() != second {
+		t.Fatal("expected stale publish stop to be ignored")
+	}
+	if len(stream.GetGOPCache()) != 1 {
+		t.Fatalf("expected cache of new publisher to be kept, got %d frames", len(stream.GetGOPCache()))
+	}
+}
+
+// 다른 세션이 releaseStream만 보내고 발생한 이벤트를 서버에서 처리
+func sendReleaseStream(t *testing.T, server *Server, client *session) {
+	t.Helper()
+	events := make(chan interface{}, 10)
+	client.appName = "live"
+	client.externalChannel = events
+	server.sessions[client.sessionId] = client
+
+	client.handleAMF0Command(newTestCommand(t, "releaseStream", 2.0, nil, "test"))
+	for len(events) > 0 {
+		server.channelHandler(<-events)
+	}
+}
+
+func TestReleaseStreamKeepsActivePublisher(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote net.Addr
+		access *acl.Policy
+		stale  bool
+		evict  bool
+	}{
+		{name: "unrelated client", remote: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 40000}},
+		{name: "denied client", access: &acl.Policy{Publish: mustNewList(t, []string{"10.0.0.0/8"}, nil)}},
+		{name: "same address", evict: true},
+		{name: "stale publisher", remote: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 40000}, stale: true, evict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+			defer server.cancel()
+
+			publisher, publisherConn := newTestPlayer(0)
+			publisher.sessionId = "publisher"
+			runOBSPublishSequence(t, server, publisher)
+			stream := server.GetStream("live/test")
+			if tt.stale {
+				stream.publisherMediaAt = time.Now().Add(-2 * releaseStreamStaleAfter)
+			}
+
+			client, clientConn := newTestPlayer(0)
+			client.sessionId = "client"
+			clientConn.remote = tt.remote
+			client.access = tt.access
+			sendReleaseStream(t, server, client)
+
+			if publisherConn.closed != tt.evict {
+				t.Fatalf("expected publisher closed=%v, got %v", tt.evict, publisherConn.closed)
+			}
+			if evicted := stream.GetPublisher() != publisher; evicted != tt.evict {
+				t.Fatalf("expected publisher evicted=%v, got %v", tt.evict, evicted)
+			}
+			if tt.access != nil {
+				commands := readCommands(t, clientConn)
+				if len(commands) != 1 || commands[0][0] != "_error" {
+					t.Fatalf("expected releaseStream to be denied with _error, got %v", commands)
+				}
+				if info, _ := commands[0][3].(map[string]any); info["code"] != "NetStream.Publish.Denied" {
+					t.Fatalf("expected NetStream.Publish.Denied, got %v", commands[0][3])
+				}
+			}
+		})
+	}
+}
+
+func TestFCPublishReservationKeepsStream(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(0)
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishReserved(PublishReserved{SessionId: publisher.sessionId, StreamName: "live/test"})
+
+	stream := server.GetStream("live/test")
+	if stream == nil || !stream.IsPublishReserved() || !stream.IsActive() {
+		t.Fatal("expected reserved stream to be kept active")
+	}
+
+	// publish 없이 종료하면 예약과 스트림 정리
+	server.TerminatedEventHandler(publisher.sessionId)
+	if server.GetStream("live/test") != nil {
+		t.Fatal("expected reserved stream to be removed when session terminates")
+	}
+}
+
+// 캡처된 출력에서 onStatus 정보 객체 목록을 읽어온다
+func readStatusObjects(t *testing.T, conn *bufferConn) []map[string]any {
+	t.Helper()
+	var statuses []map[string]any
+	for _, values := range readCommands(t, conn) {
+		if values[0] != "onStatus" || len(values) < 4 {
+			continue
+		}
+		if info, ok := values[3].(map[string]any); ok {
+			statuses = append(statuses, info)
+		}
+	}
+	return statuses
+}
+
+// 캡처된 출력에서 onStatus 코드 목록을 읽어온다
+func readStatusCodes(t *testing.T, conn *bufferConn) []string {
+	t.Helper()
+	var codes []string
+	for _, info := range readStatusObjects(t, conn) {
+		codes = append(codes, fmt.Sprint(info["code"]))
+	}
+	return codes
+}
+
+func TestMaxStreamsRejectsNewStreams(t *testing.T) {
+	server := NewServer(0, StreamConfig{MaxStreams: 2}, nil)
+	defer server.cancel()
+
+	for i := 1; i <= 2; i++ {
+		publisher, _ := newTestPlayer(1)
+		publisher.sessionId = fmt.Sprintf("publisher-%d", i)
+		server.sessions[publisher.sessionId] = publisher
+		server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: fmt.Sprintf("live/test%d", i), StreamId: 1, PublishType: "live"})
+	}
+
+	// 한도를 넘는 새 스트림 발행은 거부 후 연결 종료
+	rejected, rejectedConn := newTestPlayer(1)
+	rejected.sessionId = "publisher-3"
+	server.sessions[rejected.sessionId] = rejected
+	server.handlePublishStarted(PublishStarted{SessionId: rejected.sessionId, StreamName: "live/test3", StreamId: 1, PublishType: "live"})
+
+	if server.GetStream("live/test3") != nil {
+		t.Fatal("expected stream beyond the limit not to be created")
+	}
+	if codes := readStatusCodes(t, rejectedConn); len(codes) != 1 || codes[0] != "NetStream.Publish.Rejected" {
+		t.Fatalf("expected NetStream.Publish.Rejected, got %v", codes)
+	}
+	if !rejectedConn.closed {
+		t.Fatal("expected rejected publisher connection to be closed")
+	}
+
+	// 새 스트림 재생도 거부
+	player, playerConn := newTestPlayer(1)
+	server.sessions[player.sessionId] = player
+	server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/unknown", StreamId: 1})
+	if codes := readStatusCodes(t, playerConn); len(codes) != 1 || codes[0] != "NetStream.Play.Failed" {
+		t.Fatalf("expected NetStream.Play.Failed, got %v", codes)
+	}
+
+	// 기존 스트림 재생은 한도와 무관하게 허용
+	playerConn.buf.Reset()
+	server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/test1", StreamId: 1})
+	if server.GetStream("live/test1").GetPlayerCount() != 1 {
+		t.Fatal("expected player to join existing stream")
+	}
+
+	if len(server.streams) != 2 {
+		t.Fatalf("expected 2 streams, got %d", len(server.streams))
+	}
+	if got := server.GetRejectedStreamCount(); got != 2 {
+		t.Fatalf("expected 2 rejected streams, got %d", got)
+	}
+}
+
+func TestStreamErrorSendsErrorStatusToPlayers(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-1"
+	publisher.appName = "live"
+	publisher.streamName = "test"
+	publisher.isPublishing = true
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+
+	var conns []*bufferConn
+	for i := 0; i < 2; i++ {
+		player, conn := newTestPlayer(1)
+		player.sessionId = fmt.Sprintf("player-%d", i)
+		server.sessions[player.sessionId] = player
+		server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+		conn.buf.Reset()
+		conns = append(conns, conn)
+	}
+
+	// 발행자가 보고한 스트림 오류를 서버 이벤트로 처리
+	publisher.reportStreamError("NetStream.Play.Failed", "Publisher sent an oversized message")
+	server.channelHandler(<-events)
+
+	for i, conn := range conns {
+		statuses := readStatusObjects(t, conn)
+		if len(statuses) != 1 {
+			t.Fatalf("player %d: expected 1 onStatus, got %d", i, len(statuses))
+		}
+		if statuses[0]["level"] != "error" || statuses[0]["code"] != "NetStream.Play.Failed" || statuses[0]["details"] != "live/test" {
+			t.Fatalf("player %d: expected error level NetStream.Play.Failed, got %v", i, statuses[0])
+		}
+	}
+}
+
+func TestStreamErrorFromReplacedPublisherIgnored(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-2"
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+
+	player, conn := newTestPlayer(1)
+	server.sessions[player.sessionId] = player
+	server.handlePlayStarted(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+	conn.buf.Reset()
+
+	server.handleStreamError(StreamError{SessionId: "publisher-1", StreamName: "live/test", Code: "NetStream.Play.Failed"})
+	if statuses := readStatusObjects(t, conn); len(statuses) != 0 {
+		t.Fatalf("expected no onStatus from replaced publisher, got %v", statuses)
+	}
+}
+
+// 데이터 메시지(onTextData 등)를 담은 AMF0 Data 메시지 생성
+func newTestDataMessage(t *testing.T, timestamp uint32, values ...any) *Message {
+	t.Helper()
+	payload, err := amf.EncodeAMF0Sequence(values...)
+	if err != nil {
+		t.Fatalf("failed to encode data message: %v", err)
+	}
+	header := newMessageHeader(timestamp, uint32(len(payload)), MSG_TYPE_AMF0_DATA, 1)
+	return NewMessage(header, [][]byte{payload})
+}
+
+// 캡처된 출력에서 데이터 메시지를 읽어온다
+func readDataMessages(t *testing.T, conn *bufferConn) [][]any {
+	t.Helper()
+	return decodeAMF0Messages(t, readAllMessages(t, conn.buf.Bytes()), MSG_TYPE_AMF0_DATA)
+}
+
+func TestDataOnlyStreamRelayedAndCached(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-data"
+	publisher.appName = "live"
+	publisher.streamName = "test"
+	publisher.isPublishing = true
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+	server.sessions[publisher.sessionId] = publisher
+	server.handlePublishStarted(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+
+	first, firstConn := newTestPlayer(1)
+	first.sessionId = "player-1"
+	server.sessions[first.sessionId] = first
+	server.handlePlayStarted(PlayStarted{SessionId: first.sessionId, StreamName: "live/test", StreamId: 1})
+	firstConn.buf.Reset()
+
+	// 미디어 없이 데이터 메시지만 발행
+	publisher.handleMessage(newTestDataMessage(t, 1000, "onTextData", map[string]any{"text": "first"}))
+	publisher.handleMessage(newTestDataMessage(t, 2000, "onCuePoint", map[string]any{"name": "scte35", "time": 2.0}))
+	publisher.handleMessage(newTestDataMessage(t, 3000, "onTextData", map[string]any{"text": "second"}))
+	for len(events) > 0 {
+		server.channelHandler(<-events)
+	}
+
+	relayed := readDataMessages(t, firstConn)
+	if len(relayed) != 3 {
+		t.Fatalf("expected 3 relayed data messages, got %d", len(relayed))
+	}
+	if text := relayed[2][1].(map[string]any)["text"]; relayed[2][0] != "onTextData" || text != "second" {
+		t.Fatalf("expected onTextData second, got %v", relayed[2])
+	}
+
+	// 마지막 플레이어가 나가도 발행 중인 데이터 전용 스트림은 유지
+	server.handlePlayStopped(PlayStopped{SessionId: first.sessionId, StreamName: "live/test", StreamId: 1})
+	if server.GetStream("live/test") == nil {
+		t.Fatal("expected data-only stream to be kept alive")
+	}
+
+	// 새 플레이어는 이름별 마지막 데이터 메시지를 입장 시 받음
+	second, secondConn := newTestPlayer(1)
+	second.sessionId = "player-2"
+	server.sessions[second.sessionId] = second
+	server.handlePlayStarted(PlayStarted{SessionId: second.sessionId, StreamName: "live/test", StreamId: 1})
+
+	cached := readDataMessages(t, secondConn)
+	if len(cached) != 2 {
+		t.Fatalf("expected 2 cached data messages on join, got %d", len(cached))
+	}
+	if cached[0][0] != "onCuePoint" || cached[1][0] != "onTextData" {
+		t.Fatalf("expected cached onCuePoint and onTextData, got %v and %v", cached[0][0], cached[1][0])
+	}
+	if text := cached[1][1].(map[string]any)["text"]; text != "second" {
+		t.Fatalf("expected latest onTextData to be cached, got %v", text)
+	}
+}
+
+func TestEventChannelSizeReducesDrops(t *testing.T) {
+	const burst = 400
+
+	tests := []struct {
+		name            string
+		config          StreamConfig
+		eventCapacity   int
+		sessionCapacity int
+		dropped         uint64
+	}{
+		{"default", StreamConfig{}, DEFAULT_EVENT_CHANNEL_SIZE, DEFAULT_SESSION_CHANNEL_SIZE, burst - DEFAULT_EVENT_CHANNEL_SIZE},
+		{"configured", StreamConfig{EventChannelSize: 1000, SessionChannelSize: 64}, 1000, 64, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(0, tt.config, nil)
+			defer server.cancel()
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+			defer serverConn.Close()
+			session := server.newSessionWithChannel(serverConn)
+
+			if got := cap(server.channel); got != tt.eventCapacity {
+				t.Fatalf("expected event channel capacity %d, got %d", tt.eventCapacity, got)
+			}
+			if got := cap(session.messageChannel); got != tt.sessionCapacity {
+				t.Fatalf("expected session channel capacity %d, got %d", tt.sessionCapacity, got)
+			}
+
+			// 이벤트 루프가 돌지 않는 동안 몰린 이벤트는 버퍼를 넘는 만큼 드롭
+			for i := 0; i < burst; i++ {
+				session.sendEvent(AudioData{SessionId: session.sessionId, Timestamp: uint32(i)})
+			}
+			if got := server.GetDroppedEventCount(); got != tt.dropped {
+				t.Fatalf("expected %d dropped events, got %d", tt.dropped, got)
+			}
+		})
+	}
+}
+
+// 이벤트 루프에 핸들러가 등록되지 않은 이벤트 타입
+type unregisteredEvent struct {
+	SessionId string
+	Data      []byte
+}
+
+func TestUnhandledEventRecorded(t *testing.T) {
+	server := NewServer(0, StreamConfig{LogUnhandledEvents: true}, nil)
+	defer server.cancel()
+	go server.eventLoop()
+
+	server.channel <- unregisteredEvent{SessionId: "test", Data: make([]byte, 1024)}
+
+	deadline := time.Now().Add(time.Second)
+	for server.GetUnhandledEventCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected unhandled event to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if counts := server.deadLetters.CountByType(); counts["rtmp.unregisteredEvent"] != 1 {
+		t.Fatalf("expected one rtmp.unregisteredEvent, got %v", counts)
+	}
+
+	// 등록된 이벤트는 dead-letter로 기록되지 않음
+	server.channel <- Terminated{Id: "unknown-session"}
+	server.channel <- unregisteredEvent{}
+	for server.GetUnhandledEventCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected second unhandled event to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := server.GetUnhandledEventCount(); got != 2 {
+		t.Fatalf("expected 2 unhandled events, got %d", got)
+	}
+}
+
+// readStatusEvent는 상태 알림 채널에서 이벤트 하나를 읽는다
+func readStatusEvent(t *testing.T, events <-chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	default:
+		t.Fatal("expected status event")
+		return nil
+	}
+}
+
+func TestStatusEventsOnPublishAndPlay(t *testing.T) {
+	events := make(chan interface{}, 10)
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, StatusEvents: events}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-1"
+	player, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.sessions[player.sessionId] = player
+
+	server.channelHandler(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	if event := readStatusEvent(t, events); event != (StreamOnline{StreamName: "live/test"}) {
+		t.Fatalf("expected stream online event, got %#v", event)
+	}
+
+	server.channelHandler(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+	if event := readStatusEvent(t, events); event != (PlayerCountChanged{StreamName: "live/test", Players: 1}) {
+		t.Fatalf("expected player count 1, got %#v", event)
+	}
+
+	// 같은 상태로 다시 처리해도 중복 알림 없음
+	server.notifyStatusChanges()
+	if len(events) != 0 {
+		t.Fatalf("expected no duplicate status events, got %d", len(events))
+	}
+
+	server.channelHandler(PublishStopped{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1})
+	if event := readStatusEvent(t, events); event != (StreamOffline{StreamName: "live/test", Players: 1}) {
+		t.Fatalf("expected stream offline event, got %#v", event)
+	}
+
+	// 마지막 플레이어가 떠나 스트림이 제거되면 플레이어 수 0
+	server.channelHandler(Terminated{Id: player.sessionId})
+	if event := readStatusEvent(t, events); event != (PlayerCountChanged{StreamName: "live/test", Players: 0}) {
+		t.Fatalf("expected player count 0, got %#v", event)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no more status events, got %d", len(events))
+	}
+}
+
+func TestDroppedStatusEventIsResent(t *testing.T) {
+	events := make(chan interface{}, 1)
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, StatusEvents: events}, nil)
+	defer server.cancel()
+
+	publisher, _ := newTestPlayer(1)
+	publisher.sessionId = "publisher-1"
+	player, _ := newTestPlayer(1)
+	server.sessions[publisher.sessionId] = publisher
+	server.sessions[player.sessionId] = player
+
+	// 채널이 가득 찬 동안의 플레이어 수 알림은 드롭
+	server.channelHandler(PublishStarted{SessionId: publisher.sessionId, StreamName: "live/test", StreamId: 1, PublishType: "live"})
+	server.channelHandler(PlayStarted{SessionId: player.sessionId, StreamName: "live/test", StreamId: 1})
+	if dropped := server.GetDroppedStatusEventCount(); dropped != 1 {
+		t.Fatalf("expected 1 dropped status event, got %d", dropped)
+	}
+	if event := readStatusEvent(t, events); event != (StreamOnline{StreamName: "live/test"}) {
+		t.Fatalf("expected stream online event, got %#v", event)
+	}
+
+	// 자리가 나면 드롭된 알림을 다시 보냄
+	server.notifyStatusChanges()
+	if event := readStatusEvent(t, events); event != (PlayerCountChanged{StreamName: "live/test", Players: 1}) {
+		t.Fatalf("expected the dropped player count to be resent, got %#v", event)
+	}
+
+	// 제거된 스트림의 드롭된 오프라인 알림도 다시 보냄
+	events <- "full"
+	server.channelHandler(Terminated{Id: player.sessionId})
+	server.channelHandler(Terminated{Id: publisher.sessionId})
+	<-events
+	server.notifyStatusChanges()
+	if event := readStatusEvent(t, events); event != (PlayerCountChanged{StreamName: "live/test", Players: 0}) {
+		t.Fatalf("expected player count 0, got %#v", event)
+	}
+	server.notifyStatusChanges()
+	if event := readStatusEvent(t, events); event != (StreamOffline{StreamName: "live/test"}) {
+		t.Fatalf("expected stream offline event, got %#v", event)
+	}
+	if _, exists := server.notifiedStatus["live/test"]; exists {
+		t.Fatal("expected the removed stream to be forgotten once fully notified")
+	}
+}
+
+func TestStopTwiceIsSafe(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	player, conn := newTestPlayer(1)
+	server.sessions[player.sessionId] = player
+
+	// 시그널 처리와 defer에서 모두 Stop을 호출하는 경우
+	server.Stop()
+	server.Stop()
+
+	if !conn.closed {
+		t.Fatal("expected session connection to be closed")
+	}
+	if server.ctx.Err() == nil {
+		t.Fatal("expected server context to be cancelled")
+	}
+}
@@ -1,13 +1,20 @@
 package rtmp
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"sol/pkg/acl"
 	"sol/pkg/amf"
+	"sol/pkg/codec"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type session struct {
@@ -16,16 +23,83 @@ type session struct {
 	conn            net.Conn
 	externalChannel chan<- interface{}
 	messageChannel  chan *Message
+	droppedEvents   *atomic.Uint64 // 이벤트 드롭 횟수 (nil이면 세지 않음)
+	protocolErrors  *atomic.Uint64 // 프로토콜 위반으로 끊은 연결 수 (nil이면 세지 않음)
+	access          *acl.Policy    // 발행/재생 IP 접근 제어 (nil이면 모두 허용)
+	sendQueue       *sendQueue     // 저지연 모드 송신 큐 (nil이면 동기 전송, 서버 goroutine이 설정)
+	sendQueueMu     sync.Mutex     // 서버 goroutine의 송신 큐 설정과 세션 정리의 종료를 직렬화
+	sendQueueClosed bool           // 세션이 정리되어 송신 큐를 더 만들지 않음
+	playStreamID    uint32         // 미디어를 보낼 메시지 스트림 ID (서버 goroutine이 PlayStarted에서 기록)
+	burstPacing     time.Duration  // 입장 시 캐시 버스트를 나눠 보낼 시간 (0이면 한 번에 전송, 송신 큐 필요)
+	bufferLength    atomic.Int64   // 플레이어가 SetBufferLength로 알린 버퍼 길이 (밀리초, 0이면 알리지 않음)
+	dropLimit       uint64         // 송신 큐에서 버린 프레임이 이를 넘으면 연결 종료 (0이면 제한 없음)
+	minChunkSize    uint32         // 허용하는 최소 Set Chunk Size (0이면 DEFAULT_MIN_CHUNK_SIZE)
+	readBufferSize  int            // 연결 읽기 버퍼 크기
+
+	// 소켓 정책 파일 요청에 응답할 cross-domain 정책 XML (빈 값이면 응답하지 않음)
+	crossDomainPolicy string
+
+	// FCPublish/FCUnpublish 응답 형식 (빈 값이면 FCPublishStyleSRS)
+	fcPublishStyle FCPublishStyle
+
+	// 알 수 없는 AMF0 명령어 처리 방식 (빈 값이면 UnknownCommandError)
+	unknownCommandPolicy UnknownCommandPolicy
+
+	// 스트림 이름에 허용하는 문자 범위 (빈 값이면 StreamNameUnicode)
+	streamNamePolicy StreamNamePolicy
+
+	// connect에서 지원한다고 알리지 않은 코덱의 처리 방식 (빈 값이면 UnsupportedCodecWarn)과 이미 경고한 코덱
+	unsupportedCodecPolicy UnsupportedCodecPolicy
+	reportedCodecs         map[codec.Codec]struct{}
+
+	// AMF 명령어 속도 제한 (nil이면 제한 없음), 초과하면 commandFlooded 설정 후 연결 종료
+	commandLimiter *commandLimiter
+	commandFlooded bool
+
+	// connect 명령의 클라이언트 정보와 연결 인증 (connectAuthenticator가 nil이면 모두 허용)
+	connectInfo          ConnectInfo
+	connectAuthenticator ConnectAuthenticator
+	connectRejected      bool
+
+	// 가상 호스트 (virtualHosts 설정 시 connect에서 결정, 빈 값이면 기본 가상 호스트)
+	// 같은 app/stream이라도 가상 호스트가 다르면 다른 스트림 (vhost/app/stream)
+	virtualHosts bool
+	vhost        string
+
+	// 접근 로그 (accessLogger가 nil이면 기록하지 않음)
+	accessLogger AccessLogger
+	counter      *countingConn // 송수신 바이트 수 (accessLogger 설정 시 conn을 감싼다)
+	connectedAt  time.Time
+	role         atomic.Value // SessionRole, 역할이 알려지는 즉시 설정 (서버 이벤트 루프에서도 조회)
+
+	// ack 윈도우 (ackWindow가 nil이면 Window Acknowledgement Size를 알리지 않고 검사하지 않음)
+	ackWindow      *ackWindowConn
+	ackWindowSize  uint32
+	ackStallFactor int
+
+	// 재생 재개 (ResumablePlay 설정 시, 서버 이벤트 루프에서만 접근)
+	resumeToken       string // 발급된 재생 재개 토큰
+	lastPlayTimestamp uint32 // 마지막으로 전송한 미디어 타임스탬프
 
 	// Session 식별자 - 포인터 주소값 기반
 	sessionId string
 
+	// 명령어 순번 - AMF0 명령어마다 1씩 증가 (로그/이벤트 상관관계 추적용)
+	commandSeq uint64
+
 	// Stream 관리
 	streamID     uint32
 	streamName   string // streamkey
 	appName      string // appname
 	isPublishing bool
 	isPlaying    bool
+
+	// createStream으로 할당한 메시지 스트림 ID (deleteStream으로 해제)
+	lastStreamID   uint32
+	createdStreams map[uint32]struct{}
+
+	// 처리 중인 명령 메시지의 스트림 ID (publish/play를 해당 스트림에 연결)
+	commandStreamID uint32
 }
 
 // GetID는 세션의 ID를 반환 (sessionId 필드)
@@ -35,57 +109,71 @@ func (s *session) GetID() string {
 
 // createStream 명령어 처리
 func (s *session) handleCreateStream(values []any) {
-	slog.Info("handling createStream", "params", values)
+	s.commandLogger().Info("handling createStream", "params", values)
 
 	if len(values) < 2 {
-		slog.Error("createStream: not enough parameters", "length", len(values))
+		s.commandLogger().Error("createStream: not enough parameters", "length", len(values))
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("createStream: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("createStream: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
-	// 새로운 스트림 ID 생성 (1부터 시작)
-	s.streamID = 1
+	// 새로운 스트림 ID 할당 (1부터 시작, 연결 안에서 증가)
+	s.lastStreamID++
+	streamID := s.lastStreamID
+	if s.createdStreams == nil {
+		s.createdStreams = make(map[uint32]struct{})
+	}
+	s.createdStreams[streamID] = struct{}{}
+
+	// 발행/재생 중인 스트림이 없으면 새 스트림을 현재 스트림으로 사용
+	if !s.isPublishing && !s.isPlaying {
+		s.streamID = streamID
+	}
 
 	// _result 응답 전송
-	sequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, float64(s.streamID))
-	if err != nil {
-		slog.Error("createStream: failed to encode response", "err", err)
+	sequence, ok := s.encodeResponse("createStream", transactionID, "_result", transactionID, nil, float64(streamID))
+	if !ok {
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, sequence)
+	err := s.writer.writeCommand(s.conn, sequence)
 	if err != nil {
-		slog.Error("createStream: failed to write response", "err", err)
+		s.commandLogger().Error("createStream: failed to write response", "err", err)
 		return
 	}
 
-	slog.Info("createStream successful", "streamID", s.streamID, "transactionID", transactionID)
+	s.commandLogger().Info("createStream successful", "streamID", streamID, "transactionID", transactionID)
 }
 
 // publish 명령어 처리
 func (s *session) handlePublish(values []any) {
-	slog.Info("handling publish", "params", values)
+	s.commandLogger().Info("handling publish", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("publish: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("publish: not enough parameters", "length", len(values))
+		s.replyMissingStreamName("publish", values, "NetStream.Publish.BadName")
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("publish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("publish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
 	// 스트림 이름
 	streamName, ok := values[3].(string)
 	if !ok {
-		slog.Error("publish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("publish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.replyError("publish", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
+		return
+	}
+	if !s.checkStreamName("publish", transactionID, streamName, "NetStream.Publish.BadName") {
 		return
 	}
 
@@ -97,22 +185,47 @@ func (s *session) handlePublish(values []any) {
 		}
 	}
 
+	// 허용되지 않은 주소의 발행 거부
+	if !s.access.AllowPublish(s.conn.RemoteAddr()) {
+		s.commandLogger().Warn("publish: rejected by access control", "remoteAddr", s.conn.RemoteAddr(), "streamName", streamName)
+		if err := s.sendStatus("error", "NetStream.Publish.Denied", "Publishing is not allowed from this address", streamName); err != nil {
+			s.commandLogger().Error("publish: failed to write onStatus", "err", err)
+		}
+		s.replyError("publish", transactionID, "NetStream.Publish.Denied", "Publishing is not allowed from this address")
+		return
+	}
+
+	// 지원하지 않는 발행 유형은 거부
+	if _, err := parsePublishType(publishType); err != nil {
+		s.commandLogger().Error("publish: unsupported publish type", "publishType", publishType, "streamName", streamName)
+		if err := s.sendStatus("error", "NetStream.Publish.Denied", fmt.Sprintf("Unsupported publish type %s", publishType), streamName); err != nil {
+			s.commandLogger().Error("publish: failed to write onStatus", "err", err)
+		}
+		s.replyError("publish", transactionID, "NetStream.Publish.Denied", fmt.Sprintf("Unsupported publish type %s", publishType))
+		return
+	}
+
+	s.bindCommandStream()
 	s.streamName = streamName
 	s.isPublishing = true
+	s.assignRole(RolePublisher, "publish")
 
 	fullStreamPath := s.GetFullStreamPath()
 	if fullStreamPath == "" {
-		slog.Error("publish: invalid stream path", "appName", s.appName, "streamName", streamName)
+		s.commandLogger().Error("publish: invalid stream path", "appName", s.appName, "streamName", streamName)
+		s.replyError("publish", transactionID, "NetStream.Publish.BadName", "Invalid stream path")
 		return
 	}
 
-	slog.Info("publish request", "fullStreamPath", fullStreamPath, "publishType", publishType, "transactionID", transactionID)
+	s.commandLogger().Info("publish request", "fullStreamPath", fullStreamPath, "publishType", publishType, "transactionID", transactionID)
 
 	// Publish 시작 이벤트 전송
 	s.sendEvent(PublishStarted{
-		SessionId:  s.sessionId,
-		StreamName: fullStreamPath, // full path 사용
-		StreamId:   s.streamID,
+		SessionId:   s.sessionId,
+		StreamName:  fullStreamPath, // full path 사용
+		StreamId:    s.streamID,
+		PublishType: publishType,
+		CommandSeq:  s.commandSeq,
 	})
 
 	// onStatus 이벤트 전송: NetStream.Publish.Start
@@ -124,53 +237,144 @@ func (s *session) handlePublish(values []any) {
 	}
 
 	// onStatus 이벤트 전송 (transaction ID는 0)
-	statusSequence, err := amf.EncodeAMF0Sequence("onStatus", 0.0, nil, statusObj)
+	statusSequence, ok := s.encodeResponse("publish", transactionID, "onStatus", 0.0, nil, statusObj)
+	if !ok {
+		return
+	}
+
+	err := s.writer.writeCommand(s.conn, statusSequence)
 	if err != nil {
-		slog.Error("publish: failed to encode onStatus", "err", err)
+		s.commandLogger().Error("publish: failed to write onStatus", "err", err)
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, statusSequence)
+	s.commandLogger().Info("publish started successfully", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
+}
+
+// sendStatus는 onStatus 이벤트를 전송 (transaction ID는 0)
+func (s *session) sendStatus(level, code, description, details string) error {
+	return s.writeStatus(newStatusObject(level, code, description, details))
+}
+
+// newStatusObject는 onStatus 정보 객체를 생성
+func newStatusObject(level, code, description, details string) map[string]any {
+	return map[string]any{
+		"level":       level,
+		"code":        code,
+		"description": description,
+		"details":     details,
+	}
+}
+
+// writeStatus는 onStatus 정보 객체를 전송
+func (s *session) writeStatus(statusObj map[string]any) error {
+	sequence, err := amf.EncodeAMF0Sequence("onStatus", 0.0, nil, statusObj)
 	if err != nil {
-		slog.Error("publish: failed to write onStatus", "err", err)
+		return err
+	}
+
+	return s.writer.writeCommand(s.conn, sequence)
+}
+
+// sendError는 실패한 명령어의 transaction ID로 _error 응답을 전송
+func (s *session) sendError(transactionID float64, code, description string) error {
+	statusObj := map[string]any{
+		"level":       "error",
+		"code":        code,
+		"description": description,
+	}
+
+	sequence, err := amf.EncodeAMF0Sequence("_error", transactionID, nil, statusObj)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.writeCommand(s.conn, sequence)
+}
+
+// replyError는 _error 응답을 전송하고 전송 실패를 로그로 남김
+func (s *session) replyError(command string, transactionID float64, code, description string) {
+	if err := s.sendError(transactionID, code, description); err != nil {
+		s.commandLogger().Error(command+": failed to write _error", "transactionID", transactionID, "err", err)
+	}
+}
+
+// replyMissingStreamName은 스트림 이름이 빠진 명령어에 transaction ID를 읽을 수 있으면 _error로 응답
+func (s *session) replyMissingStreamName(command string, values []any, code string) {
+	if len(values) < 2 {
 		return
 	}
+	if transactionID, ok := values[1].(float64); ok {
+		s.replyError(command, transactionID, code, "Missing stream name")
+	}
+}
 
-	slog.Info("publish started successfully", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
+// encodeResponse는 명령어 응답을 인코딩 (실패하면 false)
+// 서버가 만든 값의 인코딩 실패는 서버 버그이므로 값과 함께 에러 로그를 남기고,
+// 클라이언트가 응답을 계속 기다리지 않도록 일반 _error를 대신 전송
+func (s *session) encodeResponse(command string, transactionID float64, values ...any) ([]byte, bool) {
+	sequence, err := amf.EncodeAMF0Sequence(values...)
+	if err == nil {
+		return sequence, true
+	}
+	s.commandLogger().Error(command+": failed to encode response (server bug)", "values", fmt.Sprintf("%#v", values), "err", err)
+	s.replyError(command, transactionID, "NetConnection.Call.Failed", "Internal server error")
+	return nil, false
 }
 
 // handlePlay의 transactionID 사용
 func (s *session) handlePlay(values []any) {
-	slog.Info("handling play", "params", values)
+	s.commandLogger().Info("handling play", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("play: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("play: not enough parameters", "length", len(values))
+		s.replyMissingStreamName("play", values, "NetStream.Play.StreamNotFound")
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("play: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("play: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
 	// 스트림 이름
 	streamName, ok := values[3].(string)
 	if !ok {
-		slog.Error("play: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("play: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.replyError("play", transactionID, "NetStream.Play.StreamNotFound", "Invalid stream name")
 		return
 	}
 
+	// 허용되지 않은 주소의 재생 거부
+	if !s.access.AllowPlay(s.conn.RemoteAddr()) {
+		s.commandLogger().Warn("play: rejected by access control", "remoteAddr", s.conn.RemoteAddr(), "streamName", streamName)
+		if err := s.sendStatus("error", "NetStream.Play.Failed", "Playing is not allowed from this address", streamName); err != nil {
+			s.commandLogger().Error("play: failed to write onStatus", "err", err)
+		}
+		s.replyError("play", transactionID, "NetStream.Play.Failed", "Playing is not allowed from this address")
+		return
+	}
+
+	// 재연결한 플레이어는 스트림 이름 쿼리로 재생 재개 토큰을 전달 ("test?resume=token")
+	streamName, resumeToken := splitResumeToken(streamName)
+	if !s.checkStreamName("play", transactionID, streamName, "NetStream.Play.StreamNotFound") {
+		return
+	}
+
+	s.bindCommandStream()
 	s.streamName = streamName
 	s.isPlaying = true
+	s.assignRole(RolePlayer, "play")
 
 	fullStreamPath := s.GetFullStreamPath()
 	if fullStreamPath == "" {
-		slog.Error("play: invalid stream path", "appName", s.appName, "streamName", streamName)
+		s.commandLogger().Error("play: invalid stream path", "appName", s.appName, "streamName", streamName)
+		s.replyError("play", transactionID, "NetStream.Play.StreamNotFound", "Invalid stream path")
 		return
 	}
 
-	slog.Info("play request", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
+	s.commandLogger().Info("play request", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
 
 	// 1. NetStream.Play.Reset 전송
 	resetStatusObj := map[string]any{
@@ -180,15 +384,14 @@ func (s *session) handlePlay(values []any) {
 		"details":     fullStreamPath,
 	}
 
-	resetSequence, err := amf.EncodeAMF0Sequence("onStatus", 0.0, nil, resetStatusObj)
-	if err != nil {
-		slog.Error("play: failed to encode reset onStatus", "err", err)
+	resetSequence, ok := s.encodeResponse("play", transactionID, "onStatus", 0.0, nil, resetStatusObj)
+	if !ok {
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, resetSequence)
+	err := s.writer.writeCommand(s.conn, resetSequence)
 	if err != nil {
-		slog.Error("play: failed to write reset onStatus", "err", err)
+		s.commandLogger().Error("play: failed to write reset onStatus", "err", err)
 		return
 	}
 
@@ -200,204 +403,245 @@ func (s *session) handlePlay(values []any) {
 		"details":     fullStreamPath,
 	}
 
-	startSequence, err := amf.EncodeAMF0Sequence("onStatus", 0.0, nil, startStatusObj)
-	if err != nil {
-		slog.Error("play: failed to encode start onStatus", "err", err)
+	startSequence, ok := s.encodeResponse("play", transactionID, "onStatus", 0.0, nil, startStatusObj)
+	if !ok {
 		return
 	}
 
 	err = s.writer.writeCommand(s.conn, startSequence)
 	if err != nil {
-		slog.Error("play: failed to write start onStatus", "err", err)
+		s.commandLogger().Error("play: failed to write start onStatus", "err", err)
 		return
 	}
 
 	// 3. Play 시작 이벤트 전송
 	s.sendEvent(PlayStarted{
-		SessionId:  s.sessionId,
-		StreamName: fullStreamPath, // full path 사용
-		StreamId:   s.streamID,
+		SessionId:   s.sessionId,
+		StreamName:  fullStreamPath, // full path 사용
+		StreamId:    s.streamID,
+		CommandSeq:  s.commandSeq,
+		ResumeToken: resumeToken,
 	})
 
-	slog.Info("play started successfully", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
+	s.commandLogger().Info("play started successfully", "fullStreamPath", fullStreamPath, "transactionID", transactionID)
 }
 
 // releaseStream 명령어 처리
 func (s *session) handleReleaseStream(values []any) {
-	slog.Info("handling releaseStream", "params", values)
+	s.commandLogger().Info("handling releaseStream", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("releaseStream: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("releaseStream: not enough parameters", "length", len(values))
+		s.replyMissingStreamName("releaseStream", values, "NetStream.Publish.BadName")
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("releaseStream: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("releaseStream: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
 	streamName, ok := values[3].(string)
 	if !ok {
-		slog.Error("releaseStream: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("releaseStream: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.replyError("releaseStream", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
+		return
+	}
+	if err := validateStreamName(streamName, s.streamNamePolicy); err != nil {
+		s.commandLogger().Warn("releaseStream: rejected stream name", "streamName", fmt.Sprintf("%q", streamName), "err", err)
+		s.replyError("releaseStream", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
 		return
 	}
 
-	slog.Info("releaseStream request", "streamName", streamName, "transactionID", transactionID)
+	// 발행이 허용되지 않은 클라이언트는 다른 발행자를 해제할 수 없음
+	if s.connectRejected || !s.access.AllowPublish(s.conn.RemoteAddr()) {
+		s.commandLogger().Warn("releaseStream: rejected by access control", "remoteAddr", s.conn.RemoteAddr(), "streamName", streamName)
+		s.replyError("releaseStream", transactionID, "NetStream.Publish.Denied", "Publishing is not allowed from this address")
+		return
+	}
+
+	s.commandLogger().Info("releaseStream request", "streamName", streamName, "transactionID", transactionID)
+
+	// releaseStream은 발행 전에만 보내므로 발행자로 확인
+	s.assignRole(RolePublisher, "releaseStream")
 
 	// _result 응답 전송
-	sequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, nil)
-	if err != nil {
-		slog.Error("releaseStream: failed to encode response", "err", err)
+	sequence, ok := s.encodeResponse("releaseStream", transactionID, "_result", transactionID, nil, nil)
+	if !ok {
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, sequence)
+	err := s.writer.writeCommand(s.conn, sequence)
 	if err != nil {
-		slog.Error("releaseStream: failed to write response", "err", err)
+		s.commandLogger().Error("releaseStream: failed to write response", "err", err)
 		return
 	}
 
-	slog.Info("releaseStream successful", "streamName", streamName, "transactionID", transactionID)
+	// 같은 스트림에 남아있는 이전 발행자 해제 요청 (OBS 재연결)
+	if fullStreamPath := s.streamPathFor(streamName); fullStreamPath != "" {
+		s.sendEvent(ReleaseStreamRequested{
+			SessionId:  s.sessionId,
+			StreamName: fullStreamPath,
+			CommandSeq: s.commandSeq,
+		})
+	}
+
+	s.commandLogger().Info("releaseStream successful", "streamName", streamName, "transactionID", transactionID)
+}
+
+// FCPublishStyle은 FCPublish/FCUnpublish 응답 형식 (클라이언트가 기대하는 서버 구현에 맞춤)
+type FCPublishStyle string
+
+const (
+	FCPublishStyleSRS FCPublishStyle = "srs" // _result(null) 후 onFCPublish {code, description}
+	FCPublishStyleFMS FCPublishStyle = "fms" // _result 없이 onFCPublish {level, code, description}
+)
+
+// writeFCResponse는 FCPublish/FCUnpublish에 대한 응답을 fcPublishStyle 형식으로 전송
+func (s *session) writeFCResponse(command, callback string, transactionID float64, code, streamName string) error {
+	info := map[string]any{
+		"code":        code,
+		"description": fmt.Sprintf("%s to stream %s", command, streamName),
+	}
+
+	if s.fcPublishStyle == FCPublishStyleFMS {
+		info["level"] = "status"
+	} else {
+		resultSequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, nil)
+		if err != nil {
+			return fmt.Errorf("encode _result: %w", err)
+		}
+		if err := s.writer.writeCommand(s.conn, resultSequence); err != nil {
+			return fmt.Errorf("write _result: %w", err)
+		}
+	}
+
+	sequence, err := amf.EncodeAMF0Sequence(callback, 0.0, nil, info)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", callback, err)
+	}
+	if err := s.writer.writeCommand(s.conn, sequence); err != nil {
+		return fmt.Errorf("write %s: %w", callback, err)
+	}
+	return nil
 }
 
 // FCPublish 명령어 처리
 func (s *session) handleFCPublish(values []any) {
-	slog.Info("handling FCPublish", "params", values)
+	s.commandLogger().Info("handling FCPublish", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("FCPublish: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("FCPublish: not enough parameters", "length", len(values))
+		s.replyMissingStreamName("FCPublish", values, "NetStream.Publish.BadName")
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("FCPublish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("FCPublish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
 	streamName, ok := values[3].(string)
 	if !ok {
-		slog.Error("FCPublish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
-		return
-	}
-
-	slog.Info("FCPublish request", "streamName", streamName, "transactionID", transactionID)
-
-	// 1. _result 응답 전송
-	resultSequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, nil)
-	if err != nil {
-		slog.Error("FCPublish: failed to encode _result", "err", err)
+		s.commandLogger().Error("FCPublish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.replyError("FCPublish", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
 		return
 	}
-
-	err = s.writer.writeCommand(s.conn, resultSequence)
-	if err != nil {
-		slog.Error("FCPublish: failed to write _result", "err", err)
+	if err := validateStreamName(streamName, s.streamNamePolicy); err != nil {
+		s.commandLogger().Warn("FCPublish: rejected stream name", "streamName", fmt.Sprintf("%q", streamName), "err", err)
+		s.replyError("FCPublish", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
 		return
 	}
 
-	// 2. onFCPublish 이벤트 전송
-	fcPublishObj := map[string]any{
-		"code":        "NetStream.Publish.Start",
-		"description": fmt.Sprintf("FCPublish to stream %s", streamName),
-	}
+	s.commandLogger().Info("FCPublish request", "streamName", streamName, "transactionID", transactionID)
+	s.assignRole(RolePublisher, "FCPublish")
 
-	onFCPublishSequence, err := amf.EncodeAMF0Sequence("onFCPublish", 0.0, nil, fcPublishObj)
-	if err != nil {
-		slog.Error("FCPublish: failed to encode onFCPublish", "err", err)
-		return
+	// publish 전에 발행 의사 등록
+	if fullStreamPath := s.streamPathFor(streamName); fullStreamPath != "" {
+		s.sendEvent(PublishReserved{
+			SessionId:  s.sessionId,
+			StreamName: fullStreamPath,
+			CommandSeq: s.commandSeq,
+		})
 	}
 
-	err = s.writer.writeCommand(s.conn, onFCPublishSequence)
-	if err != nil {
-		slog.Error("FCPublish: failed to write onFCPublish", "err", err)
+	// 설정된 서버 스타일로 _result / onFCPublish 응답
+	if err := s.writeFCResponse("FCPublish", "onFCPublish", transactionID, "NetStream.Publish.Start", streamName); err != nil {
+		s.commandLogger().Error("FCPublish: failed to write response", "err", err)
 		return
 	}
 
-	slog.Info("FCPublish successful", "streamName", streamName, "transactionID", transactionID)
+	s.commandLogger().Info("FCPublish successful", "streamName", streamName, "transactionID", transactionID)
 }
 
 // FCUnpublish 명령어 처리
 func (s *session) handleFCUnpublish(values []any) {
-	slog.Info("handling FCUnpublish", "params", values)
+	s.commandLogger().Info("handling FCUnpublish", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("FCUnpublish: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("FCUnpublish: not enough parameters", "length", len(values))
+		s.replyMissingStreamName("FCUnpublish", values, "NetStream.Publish.BadName")
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("FCUnpublish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("FCUnpublish: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
 	streamName, ok := values[3].(string)
 	if !ok {
-		slog.Error("FCUnpublish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("FCUnpublish: invalid stream name", "type", fmt.Sprintf("%T", values[3]))
+		s.replyError("FCUnpublish", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
 		return
 	}
-
-	slog.Info("FCUnpublish request", "streamName", streamName, "transactionID", transactionID)
-
-	// 1. _result 응답 전송 (SRS 스타일)
-	resultSequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, nil)
-	if err != nil {
-		slog.Error("FCUnpublish: failed to encode _result", "err", err)
+	if err := validateStreamName(streamName, s.streamNamePolicy); err != nil {
+		s.commandLogger().Warn("FCUnpublish: rejected stream name", "streamName", fmt.Sprintf("%q", streamName), "err", err)
+		s.replyError("FCUnpublish", transactionID, "NetStream.Publish.BadName", "Invalid stream name")
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, resultSequence)
-	if err != nil {
-		slog.Error("FCUnpublish: failed to write _result", "err", err)
-		return
-	}
+	s.commandLogger().Info("FCUnpublish request", "streamName", streamName, "transactionID", transactionID)
 
 	// FCUnpublish 는 publish 종료를 예고하는 명령어이므로 별도 처리가 필요할 수 있음
 	fullStreamPath := s.GetFullStreamPath()
 	// Publish 종료 이벤트 전송 (FCUnpublish는 publish 종료를 의미)
 	if s.isPublishing && fullStreamPath != "" {
 		s.sendEvent(PublishStopped{
-			SessionId:  s.sessionId,
-			StreamName: fullStreamPath,
-			StreamId:   s.streamID,
+			SessionId:   s.sessionId,
+			StreamName:  fullStreamPath,
+			StreamId:    s.streamID,
+			CommandSeq:  s.commandSeq,
+			Unpublished: true,
 		})
 		s.isPublishing = false
 	}
 
-	// 2. onFCUnpublish 이벤트 전송
-	fcUnpublishObj := map[string]any{
-		"code":        "NetStream.Unpublish.Success",
-		"description": fmt.Sprintf("FCUnpublish to stream %s", streamName),
-	}
-
-	onFCUnpublishSequence, err := amf.EncodeAMF0Sequence("onFCUnpublish", 0.0, nil, fcUnpublishObj)
-	if err != nil {
-		slog.Error("FCUnpublish: failed to encode onFCUnpublish", "err", err)
+	// 설정된 서버 스타일로 _result / onFCUnpublish 응답
+	if err := s.writeFCResponse("FCUnpublish", "onFCUnpublish", transactionID, "NetStream.Unpublish.Success", streamName); err != nil {
+		s.commandLogger().Error("FCUnpublish: failed to write response", "err", err)
 		return
 	}
 
-	err = s.writer.writeCommand(s.conn, onFCUnpublishSequence)
-	if err != nil {
-		slog.Error("FCUnpublish: failed to write onFCUnpublish", "err", err)
-		return
-	}
-
-	slog.Info("FCUnpublish successful", "streamName", streamName, "transactionID", transactionID)
+	s.commandLogger().Info("FCUnpublish successful", "streamName", streamName, "transactionID", transactionID)
 }
 
 // closeStream 명령어 처리
 func (s *session) handleCloseStream(values []any) {
-	slog.Info("handling closeStream", "params", values)
+	s.commandLogger().Info("handling closeStream", "params", values)
 
 	fullStreamPath := s.GetFullStreamPath()
 	// 이벤트 전송
 	if s.isPublishing && fullStreamPath != "" {
 		s.sendEvent(PublishStopped{
-			SessionId:  s.sessionId,
-			StreamName: fullStreamPath,
-			StreamId:   s.streamID,
+			SessionId:   s.sessionId,
+			StreamName:  fullStreamPath,
+			StreamId:    s.streamID,
+			CommandSeq:  s.commandSeq,
+			Unpublished: true,
 		})
 	}
 	if s.isPlaying && fullStreamPath != "" {
@@ -405,27 +649,36 @@ func (s *session) handleCloseStream(values []any) {
 			SessionId:  s.sessionId,
 			StreamName: fullStreamPath,
 			StreamId:   s.streamID,
+			CommandSeq: s.commandSeq,
 		})
 	}
 
 	s.isPublishing = false
 	s.isPlaying = false
 
-	slog.Info("stream closed", "fullStreamPath", fullStreamPath)
+	s.commandLogger().Info("stream closed", "fullStreamPath", fullStreamPath)
 }
 
 // deleteStream 명령어 처리
 func (s *session) handleDeleteStream(values []any) {
-	slog.Info("handling deleteStream", "params", values)
+	s.commandLogger().Info("handling deleteStream", "params", values)
 
-	if len(values) < 3 {
-		slog.Error("deleteStream: not enough parameters", "length", len(values))
+	if len(values) < 4 {
+		s.commandLogger().Error("deleteStream: not enough parameters", "length", len(values))
 		return
 	}
 
-	streamID, ok := values[3].(float64)
+	value, ok := values[3].(float64)
 	if !ok {
-		slog.Error("deleteStream: invalid stream ID", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("deleteStream: invalid stream ID", "type", fmt.Sprintf("%T", values[3]))
+		return
+	}
+	streamID := uint32(value)
+	delete(s.createdStreams, streamID)
+
+	// 다른 스트림 ID면 해당 ID만 해제하고 현재 발행/재생 중인 스트림은 유지
+	if streamID != s.streamID {
+		s.commandLogger().Info("stream deleted", "streamID", streamID, "activeStreamID", s.streamID)
 		return
 	}
 
@@ -433,9 +686,11 @@ func (s *session) handleDeleteStream(values []any) {
 	// 이벤트 전송
 	if s.isPublishing && fullStreamPath != "" {
 		s.sendEvent(PublishStopped{
-			SessionId:  s.sessionId,
-			StreamName: fullStreamPath,
-			StreamId:   s.streamID,
+			SessionId:   s.sessionId,
+			StreamName:  fullStreamPath,
+			StreamId:    s.streamID,
+			CommandSeq:  s.commandSeq,
+			Unpublished: true,
 		})
 	}
 	if s.isPlaying && fullStreamPath != "" {
@@ -443,50 +698,59 @@ func (s *session) handleDeleteStream(values []any) {
 			SessionId:  s.sessionId,
 			StreamName: fullStreamPath,
 			StreamId:   s.streamID,
+			CommandSeq: s.commandSeq,
 		})
 	}
 
 	s.isPublishing = false
 	s.isPlaying = false
 
-	slog.Info("stream deleted", "streamID", streamID, "fullStreamPath", fullStreamPath)
+	s.commandLogger().Info("stream deleted", "streamID", streamID, "fullStreamPath", fullStreamPath)
+}
+
+// bindCommandStream은 publish/play 명령이 온 메시지 스트림을 현재 스트림으로 사용
+// (createStream으로 할당하지 않은 스트림 ID면 기존 스트림 유지)
+func (s *session) bindCommandStream() {
+	if _, ok := s.createdStreams[s.commandStreamID]; ok {
+		s.streamID = s.commandStreamID
+	}
 }
 
 // pause 명령어 처리
 func (s *session) handlePause(values []any) {
-	slog.Info("handling pause", "params", values)
+	s.commandLogger().Info("handling pause", "params", values)
 
 	if len(values) < 4 {
-		slog.Error("pause: not enough parameters", "length", len(values))
+		s.commandLogger().Error("pause: not enough parameters", "length", len(values))
 		return
 	}
 
 	pauseFlag, ok := values[3].(bool)
 	if !ok {
-		slog.Error("pause: invalid pause flag", "type", fmt.Sprintf("%T", values[3]))
+		s.commandLogger().Error("pause: invalid pause flag", "type", fmt.Sprintf("%T", values[3]))
 		return
 	}
 
 	if pauseFlag {
-		slog.Info("stream paused")
+		s.commandLogger().Info("stream paused")
 	} else {
-		slog.Info("stream resumed")
+		s.commandLogger().Info("stream resumed")
 	}
 }
 
 // receiveAudio 명령어 처리
 func (s *session) handleReceiveAudio(values []any) {
-	slog.Info("handling receiveAudio", "params", values)
+	s.commandLogger().Info("handling receiveAudio", "params", values)
 }
 
 // receiveVideo 명령어 처리
 func (s *session) handleReceiveVideo(values []any) {
-	slog.Info("handling receiveVideo", "params", values)
+	s.commandLogger().Info("handling receiveVideo", "params", values)
 }
 
 // onBWDone 명령어 처리
 func (s *session) handleOnBWDone(values []any) {
-	slog.Info("handling onBWDone", "params", values)
+	s.commandLogger().Info("handling onBWDone", "params", values)
 }
 
 // 오디오 데이터 처리
@@ -502,50 +766,20 @@ func (s *session) handleAudio(message *Message) {
 		return
 	}
 
-	// Zero-copy: payload를 그대로 사용
-	if len(message.payload) == 0 {
+	// Zero-copy: payload를 그대로 사용 (길이 0 메시지는 청크가 없거나 첫 청크가 비어 있음)
+	if len(message.payload) == 0 || len(message.payload[0]) == 0 {
 		slog.Warn("empty audio data received")
 		return
 	}
 
 	// 첫 번째 청크의 첫 범째 바이트로 오디오 정보 추출
 	firstByte := message.payload[0][0]
-	codecId := "unknown"
+	audio := audioCodec(message.payload)
 	sampleRate := "unknown"
 	sampleSize := "unknown"
 	channels := "unknown"
 	aacPacketType := ""
 
-	// 오디오 코덱 ID (4비트)
-	switch (firstByte >> 4) & 0x0F {
-	case 0:
-		codecId = "Linear PCM, platform endian"
-	case 1:
-		codecId = "ADPCM"
-	case 2:
-		codecId = "MP3"
-	case 3:
-		codecId = "Linear PCM, little endian"
-	case 4:
-		codecId = "Nellymoser 16kHz mono"
-	case 5:
-		codecId = "Nellymoser 8kHz mono"
-	case 6:
-		codecId = "Nellymoser"
-	case 7:
-		codecId = "G.711 A-law"
-	case 8:
-		codecId = "G.711 mu-law"
-	case 10:
-		codecId = "AAC"
-	case 11:
-		codecId = "Speex"
-	case 14:
-		codecId = "MP3 8kHz"
-	case 15:
-		codecId = "Device-specific sound"
-	}
-
 	// 샘플링 비율 (2비트)
 	switch (firstByte >> 2) & 0x03 {
 	case 0:
@@ -573,7 +807,7 @@ func (s *session) handleAudio(message *Message) {
 	}
 
 	// AAC 특수 처리
-	if ((firstByte>>4)&0x0F) == 10 && len(message.payload[0]) > 1 {
+	if audio == codec.AAC && len(message.payload[0]) > 1 {
 		aacPacketType = ""
 		switch message.payload[0][1] {
 		case 0:
@@ -603,7 +837,8 @@ func (s *session) handleAudio(message *Message) {
 	slog.Debug("received audio data",
 		"fullStreamPath", fullStreamPath,
 		"dataSize", totalSize,
-		"codecId", codecId,
+		"codec", audio,
+		"codecId", (firstByte>>4)&0x0F,
 		"sampleRate", sampleRate,
 		"sampleSize", sampleSize,
 		"channels", channels,
@@ -612,12 +847,7 @@ func (s *session) handleAudio(message *Message) {
 		"firstByte", fmt.Sprintf("0x%02x", firstByte))
 
 	// Zero-copy: 오디오 데이터 이벤트 전송
-	s.sendEvent(AudioData{
-		SessionId:  s.sessionId,
-		StreamName: fullStreamPath,
-		Timestamp:  message.messageHeader.Timestamp,
-		Data:       message.payload, // [][]byte 그대로 전달
-	})
+	s.sendEvent(newAudioData(s.sessionId, fullStreamPath, message.messageHeader.Timestamp, message.payload)) // [][]byte 그대로 전달
 }
 
 // 비디오 데이터 처리
@@ -633,8 +863,8 @@ func (s *session) handleVideo(message *Message) {
 		return
 	}
 
-	// Zero-copy: payload를 그대로 사용
-	if len(message.payload) == 0 {
+	// Zero-copy: payload를 그대로 사용 (길이 0 메시지는 청크가 없거나 첫 청크가 비어 있음)
+	if len(message.payload) == 0 || len(message.payload[0]) == 0 {
 		slog.Warn("empty video data received")
 		return
 	}
@@ -642,7 +872,7 @@ func (s *session) handleVideo(message *Message) {
 	// 첫 번째 청크의 첫 번째 바이트로 비디오 정보 추출
 	firstByte := message.payload[0][0]
 	frameType := "unknown"
-	codecId := "unknown"
+	video := videoCodec(message.payload)
 
 	// 프레임 타입 (4비트)
 	switch (firstByte >> 4) & 0x0F {
@@ -658,24 +888,8 @@ func (s *session) handleVideo(message *Message) {
 		frameType = "video info/command frame"
 	}
 
-	// 코덱 ID (4비트)
-	switch firstByte & 0x0F {
-	case 2:
-		codecId = "Sorenson H.263"
-	case 3:
-		codecId = "Screen video"
-	case 4:
-		codecId = "On2 VP6"
-	case 5:
-		codecId = "On2 VP6 with alpha"
-	case 6:
-		codecId = "Screen video version 2"
-	case 7:
-		codecId = "AVC (H.264)"
-	}
-
 	// H.264 특수 처리
-	if (firstByte&0x0F) == 7 && len(message.payload[0]) > 1 {
+	if video == codec.H264 && len(message.payload[0]) > 1 {
 		avcPacketType := message.payload[0][1]
 		switch avcPacketType {
 		case 0:
@@ -723,18 +937,22 @@ func (s *session) handleVideo(message *Message) {
 		"fullStreamPath", fullStreamPath,
 		"dataSize", totalSize,
 		"frameType", frameType,
-		"codecId", codecId,
+		"codec", video,
+		"codecId", firstByte&0x0F,
 		"timestamp", message.messageHeader.Timestamp,
 		"firstByte", fmt.Sprintf("0x%02x", firstByte))
 
 	// Zero-copy: 비디오 데이터 이벤트 전송
-	s.sendEvent(VideoData{
-		SessionId:  s.sessionId,
-		StreamName: fullStreamPath,
-		Timestamp:  message.messageHeader.Timestamp,
-		FrameType:  frameType,
-		Data:       message.payload, // [][]byte 그대로 전달
-	})
+	s.sendEvent(newVideoData(s.sessionId, fullStreamPath, message.messageHeader.Timestamp, frameType, message.payload)) // [][]byte 그대로 전달
+}
+
+// amfDecodeOptions는 중복 키를 경고 로그로 남기는 AMF0 디코딩 옵션을 반환 (마지막 값 유지)
+func (s *session) amfDecodeOptions() amf.DecodeOptions {
+	opts := amf.DefaultDecodeOptions()
+	opts.OnDuplicateKey = func(key string) {
+		slog.Warn("duplicate AMF0 object key", "sessionId", s.sessionId, "key", key)
+	}
+	return opts
 }
 
 // 스크립트 데이터 처리 (메타데이터 등)
@@ -743,7 +961,7 @@ func (s *session) handleScriptData(message *Message) {
 
 	// AMF 데이터 디코딩
 	reader := ConcatByteSlicesReader(message.payload)
-	values, err := amf.DecodeAMF0Sequence(reader)
+	values, err := amf.DecodeAMF0SequenceWithOptions(reader, s.amfDecodeOptions())
 	if err != nil {
 		slog.Error("failed to decode script data", "err", err)
 		return
@@ -764,8 +982,8 @@ func (s *session) handleScriptData(message *Message) {
 	switch commandName {
 	case "onMetaData":
 		s.handleOnMetaData(values)
-	case "onTextData":
-		s.handleOnTextData(values)
+	case "onTextData", "onCuePoint":
+		s.handleDataMessage(commandName, message)
 	default:
 		slog.Info("unknown script command", "command", commandName, "values", values)
 	}
@@ -821,6 +1039,11 @@ func (s *session) handleOnMetaData(values []any) {
 	if videocodecid, ok := metadata["videocodecid"]; ok {
 		slog.Info("video codec", "codecid", videocodecid)
 	}
+	if colorInfo, ok := ColorInfoFromMetadata(metadata); ok {
+		slog.Info("video color info", "videoRange", colorInfo.VideoRange(), "bitDepth", colorInfo.BitDepth,
+			"primaries", colorInfo.ColorPrimaries, "transfer", colorInfo.TransferCharacteristics, "matrix", colorInfo.MatrixCoefficients,
+			"maxCLL", colorInfo.MaxCLL, "maxFALL", colorInfo.MaxFALL)
+	}
 
 	// 메타데이터 이벤트 전송
 	s.sendEvent(MetaData{
@@ -832,19 +1055,51 @@ func (s *session) handleOnMetaData(values []any) {
 	slog.Info("metadata processed successfully", "fullStreamPath", fullStreamPath, "metadataKeys", len(metadata))
 }
 
-// 텍스트 데이터 처리
-func (s *session) handleOnTextData(values []any) {
-	slog.Info("received onTextData", "values", values)
+// 미디어 외 데이터 메시지 처리 (onTextData, onCuePoint 등, 플레이어에게 원본 그대로 중계)
+func (s *session) handleDataMessage(name string, message *Message) {
+	fullStreamPath := s.GetFullStreamPath()
+	if !s.isPublishing || fullStreamPath == "" {
+		slog.Warn("received data message but not publishing", "sessionId", s.sessionId, "name", name)
+		return
+	}
 
-	// TODO: 텍스트 데이터 처리
+	s.sendEvent(DataMessage{
+		SessionId:  s.sessionId,
+		StreamName: fullStreamPath,
+		Timestamp:  message.messageHeader.Timestamp,
+		Name:       name,
+		Data:       message.payload,
+	})
 }
 
-// GetFullStreamPath는 appname/streamkey 조합의 전체 스트림 경로를 반환
+// GetFullStreamPath는 appname/streamkey 조합의 전체 스트림 경로를 반환 (가상 호스트가 있으면 vhost/appname/streamkey)
 func (s *session) GetFullStreamPath() string {
-	if s.appName == "" || s.streamName == "" {
+	return s.streamPathFor(s.streamName)
+}
+
+// reportStreamError는 발행 중인 스트림을 더 이상 진행할 수 없음을 서버에 알림 (플레이어에게 오류 전달)
+func (s *session) reportStreamError(code, description string) {
+	fullStreamPath := s.GetFullStreamPath()
+	if !s.isPublishing || fullStreamPath == "" {
+		return
+	}
+	s.sendEvent(StreamError{
+		SessionId:   s.sessionId,
+		StreamName:  fullStreamPath,
+		Code:        code,
+		Description: description,
+	})
+}
+
+// streamPathFor는 현재 앱 기준의 스트림 전체 경로를 반환 (publish 전 명령어용)
+func (s *session) streamPathFor(streamName string) string {
+	if s.appName == "" || streamName == "" {
 		return ""
 	}
-	return s.appName + "/" + s.streamName
+	if s.vhost != "" {
+		return s.vhost + "/" + s.appName + "/" + streamName
+	}
+	return s.appName + "/" + streamName
 }
 
 // GetStreamInfo는 세션 정보를 반환
@@ -861,6 +1116,7 @@ func (s *session) cleanup() {
 			SessionId:  s.sessionId,
 			StreamName: fullStreamPath,
 			StreamId:   s.streamID,
+			CommandSeq: s.commandSeq,
 		})
 	}
 	if s.isPlaying && fullStreamPath != "" {
@@ -868,25 +1124,91 @@ func (s *session) cleanup() {
 			SessionId:  s.sessionId,
 			StreamName: fullStreamPath,
 			StreamId:   s.streamID,
+			CommandSeq: s.commandSeq,
 		})
 	}
 
+	// 저지연 송신 큐 종료 (이후 서버가 송신 큐를 만들지 않도록 표시)
+	s.sendQueueMu.Lock()
+	if s.sendQueue != nil {
+		s.sendQueue.close()
+	}
+	s.sendQueueClosed = true
+	s.sendQueueMu.Unlock()
+
 	s.isPublishing = false
 	s.isPlaying = false
 	s.streamID = 0
 	s.streamName = ""
 	s.appName = ""
+	s.vhost = ""
 
 	slog.Info("session cleanup completed", "sessionId", s.sessionId, "fullStreamPath", fullStreamPath)
 }
 
+// enableLowLatency는 지연 예산을 넘으면 최신 키프레임으로 건너뛰는 송신 큐를 사용하도록 설정
+func (s *session) enableLowLatency(latencyBudget time.Duration) {
+	s.sendQueueMu.Lock()
+	defer s.sendQueueMu.Unlock()
+
+	if s.sendQueue != nil || s.sendQueueClosed {
+		return
+	}
+	s.sendQueue = newSendQueue(latencyBudget)
+	go s.handleSendQueue(s.sendQueue)
+	slog.Info("Low latency mode enabled", "sessionId", s.sessionId, "latencyBudget", latencyBudget)
+}
+
+// handleSendQueue는 송신 큐의 메시지를 순서대로 플레이어에게 전송
+func (s *session) handleSendQueue(queue *sendQueue) {
+	for {
+		msg, ok := queue.pop()
+		if !ok {
+			if dropped := queue.droppedCount(); dropped > 0 {
+				slog.Info("Low latency send queue closed", "sessionId", s.sessionId, "droppedMessages", dropped)
+			}
+			// 느린 플레이어: 마지막 onStatus까지 보냈으면 연결 종료 (세션 정리는 읽기 goroutine에서)
+			if queue.isClosing() {
+				closeWithLog(s.conn)
+			}
+			return
+		}
+		if queue.isClosing() {
+			s.conn.SetWriteDeadline(time.Now().Add(slowPlayerStatusTimeout))
+		}
+		if msg.delay > 0 && !queue.wait(msg.delay) {
+			return
+		}
+
+		var err error
+		switch msg.typeId {
+		case MSG_TYPE_AUDIO:
+			err = s.writer.writeAudioData(s.conn, msg.data, msg.timestamp, msg.streamID)
+		case MSG_TYPE_VIDEO:
+			err = s.writer.writeVideoData(s.conn, msg.data, msg.timestamp, msg.streamID)
+		case MSG_TYPE_AMF0_DATA:
+			if msg.data != nil {
+				err = s.writer.writeDataMessage(s.conn, msg.data, msg.timestamp, msg.streamID)
+			} else {
+				err = s.writer.writeScriptData(s.conn, "onMetaData", msg.metadata, msg.timestamp, msg.streamID)
+			}
+		case MSG_TYPE_AMF0_COMMAND:
+			err = s.writeStatus(msg.status)
+		}
+		if err != nil {
+			slog.Error("Failed to send queued message", "sessionId", s.sessionId, "typeId", msg.typeId, "err", err)
+		}
+	}
+}
+
 func newSession(conn net.Conn) *session {
 	s := &session{
 		reader:          newMessageReader(),
 		writer:          newMessageWriter(),
 		conn:            conn,
-		externalChannel: make(chan interface{}, 10),
-		messageChannel:  make(chan *Message, 10),
+		externalChannel: make(chan interface{}, DEFAULT_SESSION_CHANNEL_SIZE),
+		messageChannel:  make(chan *Message, DEFAULT_SESSION_CHANNEL_SIZE),
+		connectedAt:     time.Now(),
 	}
 
 	// 포인터 주소값을 sessionId로 사용
@@ -905,33 +1227,89 @@ func (s *session) sendEvent(event interface{}) {
 		// 이벤트 전송 성공
 	default:
 		// 채널이 꽉 찬 경우 이벤트 드롭
+		if s.droppedEvents != nil {
+			s.droppedEvents.Add(1)
+		}
 		slog.Warn("event channel full, dropping event", "sessionId", s.sessionId, "eventType", fmt.Sprintf("%T", event))
 	}
 }
 
 func (s *session) handleRead() {
+	reason := CloseReasonClientClosed
 	defer func() {
+		s.logAccess(reason) // cleanup이 app/stream 정보를 지우기 전에 기록
 		s.cleanup()
 		closeWithLog(s.conn)
 	}()
 
-	if err := handshake(s.conn); err != nil {
+	// 핸드셰이크 이후 읽기가 버퍼에 남은 바이트를 이어 읽도록 모든 읽기를 같은 버퍼로
+	r := bufio.NewReaderSize(s.conn, s.readBufferSize)
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{r, s.conn}
+
+	c0, err := readC0(r)
+	if err != nil {
+		slog.Info("Handshake failed:", "err", err)
+		reason = CloseReasonHandshakeFailed
+		return
+	}
+
+	// 레거시 Flash 클라이언트의 소켓 정책 파일 요청이면 정책 XML만 응답하고 종료
+	if s.crossDomainPolicy != "" && isPolicyRequestStart(c0) {
+		if err := servePolicyRequest(rw, s.crossDomainPolicy); err != nil {
+			slog.Info("Policy file request failed", "addr", s.conn.RemoteAddr(), "err", err)
+			reason = CloseReasonHandshakeFailed
+			return
+		}
+		slog.Info("Served socket policy file", "addr", s.conn.RemoteAddr())
+		reason = CloseReasonPolicyServed
+		return
+	}
+
+	if err := handshakeAfterC0(rw, c0); err != nil {
 		slog.Info("Handshake failed:", "err", err)
+		reason = CloseReasonHandshakeFailed
 		return
 	}
 
 	slog.Info("Handshake successful with", "addr", s.conn.RemoteAddr())
 
 	for {
-		slog.Info("loop")
-		message, err := s.reader.readNextMessage(s.conn)
+		traceLog("loop")
+		message, err := s.reader.readNextMessage(r)
 		if err != nil {
+			// 연결 종료는 흔한 일이라 조용히, 프로토콜 위반은 경고와 함께 집계
+			if IsProtocolError(err) {
+				slog.Warn("Closing connection after protocol violation", "sessionId", s.sessionId, "addr", s.conn.RemoteAddr(), "err", err)
+				if s.protocolErrors != nil {
+					s.protocolErrors.Add(1)
+				}
+			} else {
+				slog.Debug("Connection read ended", "sessionId", s.sessionId, "err", err)
+			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				s.reportStreamError("NetStream.Play.Failed", "Publisher sent an oversized message")
+			}
+			reason = closeReasonForReadError(err)
+			if s.commandFlooded {
+				reason = CloseReasonCommandFlood
+			}
+			if s.connectRejected {
+				reason = CloseReasonConnectRejected
+			}
 			return
 		}
 
 		switch message.messageHeader.typeId {
 		case MSG_TYPE_SET_CHUNK_SIZE: // Set Chunk Size
-			s.handleSetChunkSize(message)
+			if err := s.handleSetChunkSize(message); err != nil {
+				slog.Warn("Closing connection with rejected chunk size", "sessionId", s.sessionId, "err", err)
+				s.reportStreamError("NetStream.Play.Failed", "Publisher sent an invalid chunk size")
+				reason = CloseReasonInvalidChunkSize
+				return
+			}
 		default:
 			s.handleMessage(message)
 			//s.messageChannel <- message
@@ -939,6 +1317,49 @@ func (s *session) handleRead() {
 	}
 }
 
+// closeReasonForReadError는 수신 에러를 접근 로그의 종료 사유 코드로 변환
+func closeReasonForReadError(err error) string {
+	switch {
+	case errors.Is(err, ErrMessageTooLarge):
+		return CloseReasonMessageTooLarge
+	case IsProtocolError(err):
+		return CloseReasonProtocolError
+	case errors.Is(err, io.EOF):
+		return CloseReasonClientClosed
+	case errors.Is(err, net.ErrClosed):
+		return CloseReasonServerClosed
+	default:
+		return CloseReasonReadError
+	}
+}
+
+// logAccess는 세션 종료 시 접근 로그를 한 번 기록
+func (s *session) logAccess(reason string) {
+	if s.accessLogger == nil {
+		return
+	}
+
+	record := AccessRecord{
+		SessionId:   s.sessionId,
+		RemoteAddr:  s.conn.RemoteAddr().String(),
+		ConnectedAt: s.connectedAt,
+		App:         s.appName,
+		Stream:      s.streamName,
+		FlashVer:    s.connectInfo.FlashVer,
+		TcUrl:       s.connectInfo.TcUrl,
+		SwfUrl:      s.connectInfo.SwfUrl,
+		PageUrl:     s.connectInfo.PageUrl,
+		Role:        string(s.Role()),
+		Duration:    time.Since(s.connectedAt),
+		Reason:      reason,
+	}
+	if s.counter != nil {
+		record.BytesIn = s.counter.bytesIn.Load()
+		record.BytesOut = s.counter.bytesOut.Load()
+	}
+	s.accessLogger.LogAccess(record)
+}
+
 func (s *session) handleEvent() {
 	for {
 		select {
@@ -949,16 +1370,19 @@ func (s *session) handleEvent() {
 }
 
 func (s *session) handleMessage(message *Message) {
-	slog.Info("receive message", "typeId", message.messageHeader.typeId)
+	traceLog("receive message", "typeId", message.messageHeader.typeId)
 	switch message.messageHeader.typeId {
 	case MSG_TYPE_SET_CHUNK_SIZE: // Set Chunk Size
-		s.handleSetChunkSize(message)
+		if err := s.handleSetChunkSize(message); err != nil {
+			slog.Warn("Closing connection with rejected chunk size", "sessionId", s.sessionId, "err", err)
+			closeWithLog(s.conn)
+		}
 	case MSG_TYPE_ABORT: // Abort Message
 		// Optional: ignore or log
 	case MSG_TYPE_ACKNOWLEDGEMENT: // Acknowledgement
-		// 서버용: 클라이언트의 ack 수신
+		s.handleAcknowledgement(message)
 	case MSG_TYPE_USER_CONTROL: // User Control Messages
-		//s.handleUserControl(message)
+		s.handleUserControl(message)
 	case MSG_TYPE_WINDOW_ACK_SIZE: // Window Acknowledgement Size
 		// 클라이언트가 설정한 ack 윈도우 크기
 	case MSG_TYPE_SET_PEER_BW: // Set Peer Bandwidth
@@ -980,7 +1404,8 @@ func (s *session) handleMessage(message *Message) {
 	}
 }
 
-func (s *session) handleSetChunkSize(message *Message) {
+// handleSetChunkSize는 수신 청크 크기를 변경 (연결을 끊어야 하면 에러 반환)
+func (s *session) handleSetChunkSize(message *Message) error {
 	slog.Info("handleSetChunkSize")
 
 	// 전체 payload 길이 계산
@@ -991,7 +1416,7 @@ func (s *session) handleSetChunkSize(message *Message) {
 
 	if totalLength != 4 {
 		slog.Error("Invalid Set Chunk Size message length", "length", totalLength)
-		return
+		return nil
 	}
 
 	// 첫 번째 청크에서 4바이트 읽기 (big endian)
@@ -1016,33 +1441,68 @@ func (s *session) handleSetChunkSize(message *Message) {
 	if newChunkSize&0x80000000 != 0 {
 		slog.Error("Set Chunk Size has reserved highest bit set", "value", newChunkSize)
 		// TODO: 에러 처리후 연결 종료??
-		return
+		return nil
 	}
 
 	// RTMP 최대 청크 크기 제한 (1 ~ 16777215)
 	if newChunkSize < 1 || newChunkSize > EXTENDED_TIMESTAMP_THRESHOLD {
 		slog.Error("Set Chunk Size out of valid range", "value", newChunkSize)
-		return
+		return nil
+	}
+
+	// 너무 작은 청크 크기는 바이트마다 헤더를 처리하게 만드므로 거부
+	if newChunkSize < s.getMinChunkSize() {
+		return fmt.Errorf("%w: %d (minimum %d)", ErrChunkSizeTooSmall, newChunkSize, s.getMinChunkSize())
 	}
 
 	// 실제 세션 청크 크기 적용
 	s.reader.setChunkSize(newChunkSize)
+	return nil
+}
+
+// getMinChunkSize는 허용하는 최소 청크 크기를 반환
+func (s *session) getMinChunkSize() uint32 {
+	if s.minChunkSize == 0 {
+		return DEFAULT_MIN_CHUNK_SIZE
+	}
+	return s.minChunkSize
 }
 
 func (s *session) handleAMF0Command(message *Message) {
-	slog.Info("handleAMF0Command")
+	// 명령어 폭주로 끊은 세션에 남은 명령어는 디코딩하지 않음
+	if s.commandFlooded {
+		return
+	}
+	s.commandSeq++
+	s.commandStreamID = message.messageHeader.streamId
+	logger := s.commandLogger()
+
+	if !s.commandLimiter.allow(time.Now()) {
+		logger.Warn("Closing connection exceeding command rate", "maxCommandRate", s.commandLimiter.rate)
+		s.commandFlooded = true
+		closeWithLog(s.conn)
+		return
+	}
+
+	logger.Info("handleAMF0Command")
 	reader := ConcatByteSlicesReader(message.payload)
-	values, err := amf.DecodeAMF0Sequence(reader)
+	values, err := amf.DecodeAMF0SequenceWithOptions(reader, s.amfDecodeOptions())
 	if err != nil {
 		// TODO: handle error
 	}
 	for _, v := range values {
-		slog.Info("amf", "value", v)
+		logger.Info("amf", "value", v)
+	}
+
+	// 길이 0 메시지나 디코딩 실패로 값이 없으면 명령어 이름을 알 수 없음
+	if len(values) == 0 {
+		logger.Warn("Empty AMF0 command", "length", message.messageHeader.length, "err", err)
+		return
 	}
 
 	commandName, ok := values[0].(string)
 	if !ok {
-		slog.Error("Invalid command name type", "actual", fmt.Sprintf("%T", values[0]))
+		logger.Error("Invalid command name type", "actual", fmt.Sprintf("%T", values[0]))
 		return
 	}
 
@@ -1074,44 +1534,93 @@ func (s *session) handleAMF0Command(message *Message) {
 	case "onBWDone":
 		s.handleOnBWDone(values)
 	default:
-		slog.Error("Unknown AMF0 command", "name", commandName)
+		s.handleUnknownCommand(commandName, values)
 	}
 }
 
+// UnknownCommandPolicy는 서버가 처리하지 않는 AMF0 명령어(벤더 확장 명령어 등)에 대한 응답 방식
+type UnknownCommandPolicy string
+
+const (
+	UnknownCommandError  UnknownCommandPolicy = "error"  // transaction ID가 있으면 _error로 응답 (기본값)
+	UnknownCommandIgnore UnknownCommandPolicy = "ignore" // 로그만 남기고 응답하지 않음
+)
+
+// handleUnknownCommand는 알 수 없는 명령어를 unknownCommandPolicy에 따라 처리
+// 응답을 기다리는 클라이언트가 멈추지 않도록 기본적으로 transaction ID(0 제외)가 있으면 _error로 종료
+func (s *session) handleUnknownCommand(commandName string, values []any) {
+	var transactionID float64
+	if len(values) > 1 {
+		transactionID, _ = values[1].(float64)
+	}
+	reply := s.unknownCommandPolicy != UnknownCommandIgnore && transactionID != 0
+	s.commandLogger().Error("Unknown AMF0 command", "name", commandName, "transactionID", transactionID, "replied", reply)
+	if reply {
+		s.replyError(commandName, transactionID, "NetConnection.Call.Failed", fmt.Sprintf("Unknown command %s", commandName))
+	}
+}
+
+// commandLogger는 세션 ID와 현재 명령어 순번이 붙은 로거를 반환
+func (s *session) commandLogger() *slog.Logger {
+	return slog.With("sessionId", s.sessionId, "commandSeq", s.commandSeq)
+}
+
 func (s *session) handleConnect(values []any) {
-	slog.Info("handling connect", "params", values)
+	s.commandLogger().Info("handling connect", "params", values)
 
 	// 최소 3개 요소: "connect", transaction ID, command object
 	if len(values) < 3 {
-		slog.Error("connect: not enough parameters", "length", len(values))
+		s.commandLogger().Error("connect: not enough parameters", "length", len(values))
 		return
 	}
 
 	transactionID, ok := values[1].(float64)
 	if !ok {
-		slog.Error("connect: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
+		s.commandLogger().Error("connect: invalid transaction ID", "type", fmt.Sprintf("%T", values[1]))
 		return
 	}
 
-	slog.Info("handling connect", "transactionID", transactionID)
+	s.commandLogger().Info("handling connect", "transactionID", transactionID)
 
 	// command object (map)
 	commandObj, ok := values[2].(map[string]any)
 	if !ok {
-		slog.Error("connect: invalid command object", "type", fmt.Sprintf("%T", values[2]))
-		return
-	}
-
-	slog.Info("object", "commandObj", commandObj)
-
-	// app 이름 추출
-	if app, ok := commandObj["app"]; ok {
-		if appName, ok := app.(string); ok {
-			s.appName = appName
-			slog.Info("app name extracted", "appName", appName)
+		s.commandLogger().Error("connect: invalid command object", "type", fmt.Sprintf("%T", values[2]))
+		s.replyError("connect", transactionID, "NetConnection.Connect.Rejected", "Invalid command object")
+		return
+	}
+
+	s.commandLogger().Info("object", "commandObj", commandObj)
+
+	// app 이름과 클라이언트 정보 추출
+	s.connectInfo = connectInfoFromCommand(commandObj)
+	s.appName = s.connectInfo.App
+	s.commandLogger().Info("client info extracted",
+		"appName", s.connectInfo.App,
+		"flashVer", s.connectInfo.FlashVer,
+		"tcUrl", s.connectInfo.TcUrl,
+		"swfUrl", s.connectInfo.SwfUrl,
+		"pageUrl", s.connectInfo.PageUrl,
+		"audioCodecs", s.connectInfo.AudioCodecs,
+		"videoCodecs", s.connectInfo.VideoCodecs)
+	if s.virtualHosts {
+		s.vhost = connectVhost(commandObj, s.connectInfo.TcUrl)
+		s.commandLogger().Info("virtual host resolved", "vhost", s.vhost)
+	}
+
+	if s.connectAuthenticator != nil {
+		if err := s.connectAuthenticator.AuthenticateConnect(s.conn.RemoteAddr(), s.connectInfo); err != nil {
+			s.commandLogger().Warn("connect: rejected by authenticator", "err", err)
+			s.replyError("connect", transactionID, "NetConnection.Connect.Rejected", "Connection rejected")
+			s.connectRejected = true
+			closeWithLog(s.conn)
+			return
 		}
 	}
 
+	// 클라이언트 종류로 역할을 미리 추정 (publish/play 시 확정)
+	s.assignRole(roleFromConnect(commandObj), "connect")
+
 	obj := map[string]any{
 		"level":          "status",
 		"code":           "NetConnection.Connect.Success",
@@ -1119,13 +1628,20 @@ func (s *session) handleConnect(values []any) {
 		"objectEncoding": 0,
 	}
 
-	sequence, err := amf.EncodeAMF0Sequence("_result", transactionID, nil, obj)
-	if err != nil {
+	sequence, ok := s.encodeResponse("connect", transactionID, "_result", transactionID, nil, obj)
+	if !ok {
 		return
 	}
 
-	slog.Info("encoded _result sequence", "sequence", sequence)
-	err = s.writer.writeSetChunkSize(s.conn, 4096)
+	s.commandLogger().Info("encoded _result sequence", "sequence", sequence)
+	if s.ackWindow != nil {
+		if err := s.writer.writeWindowAckSize(s.conn, s.ackWindowSize); err != nil {
+			return
+		}
+		s.ackWindow.start(s.ackWindowSize, s.ackStallFactor)
+	}
+
+	err := s.writer.writeSetChunkSize(s.conn, 4096)
 	if err != nil {
 		return
 	}
@@ -1,15 +1,27 @@
 package rtmp
 
+import "sol/pkg/codec"
+
 // 세션 종료 이벤트
 type Terminated struct {
 	Id string
 }
 
+// 세션 역할 확인 이벤트 (역할이 처음 알려지거나 바뀔 때)
+type RoleAssigned struct {
+	SessionId  string
+	Role       SessionRole
+	Source     string // 역할을 알게 된 명령어 (connect, releaseStream, FCPublish, publish, play)
+	CommandSeq uint64 // 이벤트를 발생시킨 명령어 순번
+}
+
 // Publish 시작 이벤트
 type PublishStarted struct {
-	SessionId  string
-	StreamName string
-	StreamId   uint32
+	SessionId   string
+	StreamName  string
+	StreamId    uint32
+	PublishType string // live, record, append
+	CommandSeq  uint64 // 이벤트를 발생시킨 명령어 순번
 }
 
 // Publish 종료 이벤트
@@ -17,13 +29,40 @@ type PublishStopped struct {
 	SessionId  string
 	StreamName string
 	StreamId   uint32
+	CommandSeq uint64 // 이벤트를 발생시킨 명령어 순번
+
+	Unpublished bool // FCUnpublish/closeStream/deleteStream로 명시적으로 종료 (재연결 유예 없음)
 }
 
-// Play 시작 이벤트
-type PlayStarted struct {
+// releaseStream 요청 이벤트 (같은 스트림의 기존 발행자 해제)
+type ReleaseStreamRequested struct {
 	SessionId  string
 	StreamName string
-	StreamId   uint32
+	CommandSeq uint64 // 이벤트를 발생시킨 명령어 순번
+}
+
+// FCPublish 발행 예약 이벤트
+type PublishReserved struct {
+	SessionId  string
+	StreamName string
+	CommandSeq uint64 // 이벤트를 발생시킨 명령어 순번
+}
+
+// 스트림 오류 이벤트 (발행 중인 스트림을 더 이상 진행할 수 없는 경우)
+type StreamError struct {
+	SessionId   string
+	StreamName  string
+	Code        string // 플레이어에게 전달할 onStatus 코드
+	Description string
+}
+
+// Play 시작 이벤트
+type PlayStarted struct {
+	SessionId   string
+	StreamName  string
+	StreamId    uint32
+	CommandSeq  uint64 // 이벤트를 발생시킨 명령어 순번
+	ResumeToken string // 재연결한 플레이어가 전달한 재생 재개 토큰 (없으면 빈 값)
 }
 
 // Play 종료 이벤트
@@ -31,6 +70,7 @@ type PlayStopped struct {
 	SessionId  string
 	StreamName string
 	StreamId   uint32
+	CommandSeq uint64 // 이벤트를 발생시킨 명령어 순번
 }
 
 // 오디오 데이터 수신 이벤트
@@ -39,15 +79,57 @@ type AudioData struct {
 	StreamName string
 	Timestamp  uint32
 	Data       [][]byte // Zero-copy payload chunks
+
+	// 오디오 태그 헤더에서 한 번 해석한 값 (newAudioData가 채움)
+	Codec          codec.Codec
+	SequenceHeader bool // AAC sequence header (AudioSpecificConfig)
+}
+
+// newAudioData는 오디오 태그 헤더를 해석해서 구조화된 필드를 채운 이벤트를 만든다
+func newAudioData(sessionId, streamName string, timestamp uint32, data [][]byte) AudioData {
+	return AudioData{
+		SessionId:      sessionId,
+		StreamName:     streamName,
+		Timestamp:      timestamp,
+		Data:           data,
+		Codec:          audioCodec(data),
+		SequenceHeader: isAudioSequenceHeader(data),
+	}
 }
 
 // 비디오 데이터 수신 이벤트
 type VideoData struct {
-	SessionId  string
-	StreamName string
-	Timestamp  uint32
-	FrameType  string
-	Data       [][]byte // Zero-copy payload chunks
+	SessionId       string
+	StreamName      string
+	Timestamp       uint32 // DTS
+	FrameType       string
+	Data            [][]byte // Zero-copy payload chunks
+	CompositionTime int32    // AVC NALU의 composition time offset (밀리초, B-프레임이 있으면 0이 아님)
+
+	// 비디오 태그 헤더에서 한 번 해석한 값 (newVideoData가 채움)
+	Codec          codec.Codec
+	SequenceHeader bool // AVC sequence header (SPS/PPS)
+	KeyFrame       bool // 키프레임 (sequence header 제외)
+}
+
+// newVideoData는 비디오 태그 헤더를 해석해서 구조화된 필드를 채운 이벤트를 만든다
+func newVideoData(sessionId, streamName string, timestamp uint32, frameType string, data [][]byte) VideoData {
+	return VideoData{
+		SessionId:       sessionId,
+		StreamName:      streamName,
+		Timestamp:       timestamp,
+		FrameType:       frameType,
+		Data:            data,
+		CompositionTime: compositionTime(data),
+		Codec:           videoCodec(data),
+		SequenceHeader:  isVideoSequenceHeader(data),
+		KeyFrame:        isVideoKeyFrame(data),
+	}
+}
+
+// PTS는 프레임의 표시 타임스탬프 (DTS + composition time offset)
+func (v VideoData) PTS() uint32 {
+	return v.Timestamp + uint32(v.CompositionTime)
 }
 
 // 메타데이터 수신 이벤트
@@ -56,3 +138,12 @@ type MetaData struct {
 	StreamName string
 	Metadata   map[string]any
 }
+
+// 미디어 외 데이터 메시지 수신 이벤트 (onTextData, onCuePoint 등 timed metadata)
+type DataMessage struct {
+	SessionId  string
+	StreamName string
+	Timestamp  uint32
+	Name       string   // 데이터 메시지 이름 (예: onTextData)
+	Data       [][]byte // AMF0 payload 원본 (Zero-copy)
+}
@@ -0,0 +1,560 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"sol/pkg/acl"
+	"sol/pkg/amf"
+	"sol/pkg/codec"
+	"testing"
+)
+
+// AMF0 명령어 메시지 생성
+func newTestCommand(t *testing.T, values ...any) *Message {
+	t.Helper()
+	payload, err := amf.EncodeAMF0Sequence(values...)
+	if err != nil {
+		t.Fatalf("failed to encode command: %v", err)
+	}
+	header := newMessageHeader(0, uint32(len(payload)), MSG_TYPE_AMF0_COMMAND, 0)
+	return NewMessage(header, [][]byte{payload})
+}
+
+func TestCommandSeqIncrementsPerCommand(t *testing.T) {
+	s, _ := newTestPlayer(0)
+	s.appName = "live"
+	events := make(chan interface{}, 10)
+	s.externalChannel = events
+
+	s.handleAMF0Command(newTestCommand(t, "createStream", 2.0, nil))
+	if s.commandSeq != 1 {
+		t.Fatalf("expected command seq 1, got %d", s.commandSeq)
+	}
+
+	s.handleAMF0Command(newTestCommand(t, "publish", 3.0, nil, "test", "live"))
+	if s.commandSeq != 2 {
+		t.Fatalf("expected command seq 2, got %d", s.commandSeq)
+	}
+
+	if _, ok := (<-events).(RoleAssigned); !ok {
+		t.Fatal("expected RoleAssigned event before PublishStarted")
+	}
+	event, ok := (<-events).(PublishStarted)
+	if !ok {
+		t.Fatal("expected PublishStarted event")
+	}
+	if event.CommandSeq != 2 {
+		t.Errorf("expected event command seq 2, got %d", event.CommandSeq)
+	}
+
+	// 알 수 없는 명령어도 순번을 소비
+	s.handleAMF0Command(newTestCommand(t, "unknownCommand", 4.0, nil))
+	if s.commandSeq != 3 {
+		t.Errorf("expected command seq 3, got %d", s.commandSeq)
+	}
+}
+
+func TestDeleteStreamTargetsOnlyNamedStream(t *testing.T) {
+	s, _ := newTestPlayer(0)
+	s.appName = "live"
+	events := make(chan interface{}, 10)
+	s.externalChannel = events
+
+	// 한 연결에서 스트림 두 개를 만들고 첫 번째 스트림으로 발행
+	s.handleAMF0Command(newTestCommand(t, "createStream", 2.0, nil))
+	s.handleAMF0Command(newTestCommand(t, "createStream", 3.0, nil))
+	publish := newTestCommand(t, "publish", 4.0, nil, "test", "live")
+	publish.messageHeader.streamId = 1
+	s.handleAMF0Command(publish)
+
+	if _, ok := (<-events).(RoleAssigned); !ok {
+		t.Fatal("expected RoleAssigned event before PublishStarted")
+	}
+	started, ok := (<-events).(PublishStarted)
+	if !ok || started.StreamId != 1 {
+		t.Fatalf("expected PublishStarted on stream 1, got %+v", started)
+	}
+
+	// 두 번째 스트림 삭제는 발행 중인 첫 번째 스트림에 영향을 주지 않음
+	s.handleAMF0Command(newTestCommand(t, "deleteStream", 5.0, nil, 2.0))
+	if len(events) != 0 {
+		t.Fatalf("expected no events for deleting the idle stream, got %d", len(events))
+	}
+	if !s.isPublishing || s.streamID != 1 {
+		t.Fatalf("expected stream 1 to keep publishing, got publishing=%t streamID=%d", s.isPublishing, s.streamID)
+	}
+	if _, ok := s.createdStreams[2]; ok {
+		t.Error("expected deleted stream ID to be released")
+	}
+
+	// 발행 중인 스트림을 삭제하면 발행 종료
+	s.handleAMF0Command(newTestCommand(t, "deleteStream", 6.0, nil, 1.0))
+	stopped, ok := (<-events).(PublishStopped)
+	if !ok || stopped.StreamId != 1 {
+		t.Fatalf("expected PublishStopped on stream 1, got %+v", stopped)
+	}
+	if s.isPublishing {
+		t.Error("expected publishing to stop after deleting its stream")
+	}
+}
+
+func TestRoleAssignedOnPublishAndPlay(t *testing.T) {
+	tests := []struct {
+		command []any
+		role    SessionRole
+	}{
+		{[]any{"publish", 2.0, nil, "test", "live"}, RolePublisher},
+		{[]any{"play", 2.0, nil, "test"}, RolePlayer},
+	}
+
+	for _, tt := range tests {
+		s, _ := newTestPlayer(1)
+		s.appName = "live"
+		events := make(chan interface{}, 10)
+		s.externalChannel = events
+
+		if s.Role() != RoleUnknown {
+			t.Fatalf("%s: expected unknown role before command, got %q", tt.command[0], s.Role())
+		}
+		s.handleAMF0Command(newTestCommand(t, tt.command...))
+
+		if s.Role() != tt.role {
+			t.Errorf("%s: expected role %q, got %q", tt.command[0], tt.role, s.Role())
+		}
+		event, ok := (<-events).(RoleAssigned)
+		if !ok || event.Role != tt.role || event.Source != tt.command[0] || event.CommandSeq != 1 {
+			t.Errorf("%s: expected RoleAssigned event for %q, got %+v", tt.command[0], tt.role, event)
+		}
+	}
+}
+
+func TestRoleDetectedBeforePublish(t *testing.T) {
+	// FMLE 계열 인코더는 connect 단계에서 발행자로 추정
+	s, _ := newTestPlayer(0)
+	events := make(chan interface{}, 10)
+	s.externalChannel = events
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live", "flashVer": "FMLE/3.0 (compatible; FMSc/1.0)"}))
+
+	event, ok := (<-events).(RoleAssigned)
+	if !ok || event.Role != RolePublisher || event.Source != "connect" {
+		t.Fatalf("expected publisher role from connect, got %+v", event)
+	}
+
+	// 이미 알려진 역할이면 FCPublish에서 이벤트를 다시 보내지 않음
+	s.handleAMF0Command(newTestCommand(t, "FCPublish", 2.0, nil, "test"))
+	for len(events) > 0 {
+		if event, ok := (<-events).(RoleAssigned); ok {
+			t.Fatalf("expected no further RoleAssigned events, got %+v", event)
+		}
+	}
+
+	// 역할 정보가 없는 connect 후에는 FCPublish로 발행자 확인
+	s, _ = newTestPlayer(0)
+	events = make(chan interface{}, 10)
+	s.externalChannel = events
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live"}))
+	if s.Role() != RoleUnknown || len(events) != 0 {
+		t.Fatalf("expected unknown role after plain connect, got %q", s.Role())
+	}
+	s.handleAMF0Command(newTestCommand(t, "FCPublish", 2.0, nil, "test"))
+	if event, ok := (<-events).(RoleAssigned); !ok || event.Role != RolePublisher || event.Source != "FCPublish" {
+		t.Fatalf("expected publisher role from FCPublish, got %+v", event)
+	}
+}
+
+func TestRoleFromConnect(t *testing.T) {
+	tests := []struct {
+		commandObj map[string]any
+		role       SessionRole
+	}{
+		{map[string]any{"flashVer": "FMLE/3.0 (compatible; FMSc/1.0)"}, RolePublisher},
+		{map[string]any{"flashVer": "LNX 9,0,124,2", "audioCodecs": 3575.0, "videoCodecs": 252.0}, RolePlayer},
+		{map[string]any{"app": "live"}, RoleUnknown},
+	}
+
+	for _, tt := range tests {
+		if role := roleFromConnect(tt.commandObj); role != tt.role {
+			t.Errorf("roleFromConnect(%v): expected %q, got %q", tt.commandObj, tt.role, role)
+		}
+	}
+}
+
+// 메시지 중 typeId 타입의 AMF0 메시지를 디코딩
+func decodeAMF0Messages(t *testing.T, messages []*Message, typeId uint8) [][]any {
+	t.Helper()
+	var decoded [][]any
+	for _, msg := range messages {
+		if msg.messageHeader.typeId != typeId {
+			continue
+		}
+		values, err := amf.DecodeAMF0Sequence(ConcatByteSlicesReader(msg.payload))
+		if err != nil {
+			t.Fatalf("failed to decode AMF0 message (type %d): %v", typeId, err)
+		}
+		decoded = append(decoded, values)
+	}
+	return decoded
+}
+
+// 전송된 명령어 메시지를 모두 디코딩
+func readCommands(t *testing.T, conn *bufferConn) [][]any {
+	t.Helper()
+	return decodeAMF0Messages(t, readAllMessages(t, conn.buf.Bytes()), MSG_TYPE_AMF0_COMMAND)
+}
+
+// 전송된 명령어 메시지 중 _error 응답만 디코딩
+func readErrorResponses(t *testing.T, conn *bufferConn) [][]any {
+	t.Helper()
+	var responses [][]any
+	for _, values := range readCommands(t, conn) {
+		if values[0] == "_error" {
+			responses = append(responses, values)
+		}
+	}
+	return responses
+}
+
+func TestFCPublishResponseStyle(t *testing.T) {
+	tests := []struct {
+		style     FCPublishStyle
+		publish   [][]any
+		unpublish [][]any
+	}{
+		{
+			style: FCPublishStyleSRS,
+			publish: [][]any{
+				{"_result", 3.0, nil, nil},
+				{"onFCPublish", 0.0, nil, map[string]any{"code": "NetStream.Publish.Start", "description": "FCPublish to stream test"}},
+			},
+			unpublish: [][]any{
+				{"_result", 4.0, nil, nil},
+				{"onFCUnpublish", 0.0, nil, map[string]any{"code": "NetStream.Unpublish.Success", "description": "FCUnpublish to stream test"}},
+			},
+		},
+		{
+			style: FCPublishStyleFMS,
+			publish: [][]any{
+				{"onFCPublish", 0.0, nil, map[string]any{"level": "status", "code": "NetStream.Publish.Start", "description": "FCPublish to stream test"}},
+			},
+			unpublish: [][]any{
+				{"onFCUnpublish", 0.0, nil, map[string]any{"level": "status", "code": "NetStream.Unpublish.Success", "description": "FCUnpublish to stream test"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			s, conn := newTestPlayer(1)
+			s.appName = "live"
+			s.externalChannel = make(chan interface{}, 10)
+			s.fcPublishStyle = tt.style
+
+			s.handleAMF0Command(newTestCommand(t, "FCPublish", 3.0, nil, "test"))
+			if commands := readCommands(t, conn); !reflect.DeepEqual(commands, tt.publish) {
+				t.Fatalf("expected FCPublish response %v, got %v", tt.publish, commands)
+			}
+
+			conn.buf.Reset()
+			s.handleAMF0Command(newTestCommand(t, "FCUnpublish", 4.0, nil, "test"))
+			if commands := readCommands(t, conn); !reflect.DeepEqual(commands, tt.unpublish) {
+				t.Fatalf("expected FCUnpublish response %v, got %v", tt.unpublish, commands)
+			}
+		})
+	}
+}
+
+func TestPublishDeniedSendsError(t *testing.T) {
+	s, conn := newTestPlayer(1)
+	s.appName = "live"
+	s.access = &acl.Policy{Publish: mustNewList(t, nil, []string{"127.0.0.1"})}
+
+	s.handleAMF0Command(newTestCommand(t, "publish", 7.0, nil, "test", "live"))
+
+	responses := readErrorResponses(t, conn)
+	if len(responses) != 1 {
+		t.Fatalf("expected 1 _error response, got %d", len(responses))
+	}
+	if responses[0][1] != 7.0 {
+		t.Errorf("expected transaction ID 7, got %v", responses[0][1])
+	}
+	status, ok := responses[0][3].(map[string]any)
+	if !ok || status["level"] != "error" || status["code"] != "NetStream.Publish.Denied" {
+		t.Errorf("expected NetStream.Publish.Denied error status, got %v", responses[0][3])
+	}
+}
+
+func TestUnknownCommandSendsError(t *testing.T) {
+	s, conn := newTestPlayer(0)
+
+	s.handleAMF0Command(newTestCommand(t, "getStreamLength", 9.0, nil, "test"))
+
+	responses := readErrorResponses(t, conn)
+	if len(responses) != 1 || responses[0][1] != 9.0 {
+		t.Fatalf("expected _error for transaction 9, got %v", responses)
+	}
+	status, ok := responses[0][3].(map[string]any)
+	if !ok || status["code"] != "NetConnection.Call.Failed" {
+		t.Errorf("expected NetConnection.Call.Failed status, got %v", responses[0][3])
+	}
+
+	// transaction ID 0은 응답을 기다리지 않음
+	s.handleAMF0Command(newTestCommand(t, "vendorNotify", 0.0, nil))
+	if responses := readErrorResponses(t, conn); len(responses) != 1 {
+		t.Errorf("expected no _error for transaction 0, got %v", responses)
+	}
+}
+
+func TestUnknownCommandIgnorePolicy(t *testing.T) {
+	s, conn := newTestPlayer(0)
+	s.unknownCommandPolicy = UnknownCommandIgnore
+
+	s.handleAMF0Command(newTestCommand(t, "getStreamLength", 9.0, nil, "test"))
+
+	if responses := readErrorResponses(t, conn); len(responses) != 0 {
+		t.Fatalf("expected no _error with ignore policy, got %v", responses)
+	}
+}
+
+func TestUnencodableResponseFallsBackToError(t *testing.T) {
+	s, conn := newTestPlayer(1)
+
+	// 서버가 만든 응답에 AMF0로 인코딩할 수 없는 값이 들어간 경우
+	obj := map[string]any{"code": "NetConnection.Connect.Success", "bad": make(chan int)}
+	if _, ok := s.encodeResponse("connect", 4.0, "_result", 4.0, nil, obj); ok {
+		t.Fatal("expected encoding to fail")
+	}
+
+	responses := readErrorResponses(t, conn)
+	if len(responses) != 1 || responses[0][1] != 4.0 {
+		t.Fatalf("expected _error for transaction 4, got %v", responses)
+	}
+	status, ok := responses[0][3].(map[string]any)
+	if !ok || status["level"] != "error" || status["code"] != "NetConnection.Call.Failed" {
+		t.Errorf("expected generic error status, got %v", responses[0][3])
+	}
+}
+
+func TestSuccessfulPublishSendsNoError(t *testing.T) {
+	s, conn := newTestPlayer(1)
+	s.appName = "live"
+	s.externalChannel = make(chan interface{}, 10)
+
+	s.handleAMF0Command(newTestCommand(t, "publish", 7.0, nil, "test", "live"))
+
+	if responses := readErrorResponses(t, conn); len(responses) != 0 {
+		t.Fatalf("expected no _error response, got %v", responses)
+	}
+}
+
+func TestCommandWithoutStreamNameSendsError(t *testing.T) {
+	tests := []struct {
+		command string
+		code    string
+	}{
+		{"publish", "NetStream.Publish.BadName"},
+		{"play", "NetStream.Play.StreamNotFound"},
+		{"releaseStream", "NetStream.Publish.BadName"},
+		{"FCPublish", "NetStream.Publish.BadName"},
+		{"FCUnpublish", "NetStream.Publish.BadName"},
+	}
+	for _, tt := range tests {
+		s, conn := newTestPlayer(1)
+		s.appName = "live"
+		s.externalChannel = make(chan interface{}, 10)
+
+		// 스트림 이름 없이 명령어 이름, transaction ID, null만 보냄
+		s.handleAMF0Command(newTestCommand(t, tt.command, 6.0, nil))
+
+		responses := readErrorResponses(t, conn)
+		if len(responses) != 1 || responses[0][1] != 6.0 {
+			t.Errorf("%s: expected _error for transaction 6, got %v", tt.command, responses)
+			continue
+		}
+		status, ok := responses[0][3].(map[string]any)
+		if !ok || status["code"] != tt.code {
+			t.Errorf("%s: expected %s status, got %v", tt.command, tt.code, responses[0][3])
+		}
+		if s.streamName != "" {
+			t.Errorf("%s: expected no stream to be started, got %q", tt.command, s.streamName)
+		}
+	}
+}
+
+// Set Chunk Size 메시지 생성
+func newSetChunkSizeMessage(size uint32) *Message {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, size)
+	header := newMessageHeader(0, 4, MSG_TYPE_SET_CHUNK_SIZE, 0)
+	return NewMessage(header, [][]byte{payload})
+}
+
+func TestSetChunkSizeBelowMinimumRejected(t *testing.T) {
+	s, _ := newTestPlayer(0)
+
+	err := s.handleSetChunkSize(newSetChunkSizeMessage(1))
+	if !errors.Is(err, ErrChunkSizeTooSmall) {
+		t.Fatalf("expected ErrChunkSizeTooSmall, got %v", err)
+	}
+	if got := s.reader.readerContext.chunkSize; got != DEFAULT_CHUNK_SIZE {
+		t.Fatalf("expected chunk size to stay %d, got %d", DEFAULT_CHUNK_SIZE, got)
+	}
+}
+
+func TestSetChunkSizeAboveMinimumApplied(t *testing.T) {
+	s, _ := newTestPlayer(0)
+
+	if err := s.handleSetChunkSize(newSetChunkSizeMessage(4096)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := s.reader.readerContext.chunkSize; got != 4096 {
+		t.Fatalf("expected chunk size 4096, got %d", got)
+	}
+}
+
+func TestSetChunkSizeConfiguredMinimum(t *testing.T) {
+	s, _ := newTestPlayer(0)
+	s.minChunkSize = 1
+
+	if err := s.handleSetChunkSize(newSetChunkSizeMessage(1)); err != nil {
+		t.Fatalf("unexpected error with minimum 1: %v", err)
+	}
+	if got := s.reader.readerContext.chunkSize; got != 1 {
+		t.Fatalf("expected chunk size 1, got %d", got)
+	}
+}
+
+func TestEmptyPayloadMessagesHandled(t *testing.T) {
+	s, _ := newTestPlayer(1)
+	s.isPublishing = true
+	s.appName = "live"
+	s.streamName = "test"
+	events := make(chan interface{}, 10)
+	s.externalChannel = events
+
+	typeIds := []byte{
+		MSG_TYPE_SET_CHUNK_SIZE, MSG_TYPE_ABORT, MSG_TYPE_ACKNOWLEDGEMENT, MSG_TYPE_USER_CONTROL,
+		MSG_TYPE_WINDOW_ACK_SIZE, MSG_TYPE_SET_PEER_BW, MSG_TYPE_AUDIO, MSG_TYPE_VIDEO,
+		MSG_TYPE_AMF0_DATA, MSG_TYPE_AMF0_COMMAND,
+	}
+	for _, typeId := range typeIds {
+		// 길이 0 메시지 (청크 없음)와 빈 청크 하나짜리 메시지
+		for _, payload := range [][][]byte{{}, {{}}} {
+			s.handleMessage(NewMessage(newMessageHeader(0, 0, typeId, 1), payload))
+		}
+	}
+
+	if got := s.reader.readerContext.chunkSize; got != DEFAULT_CHUNK_SIZE {
+		t.Fatalf("expected chunk size to stay %d, got %d", DEFAULT_CHUNK_SIZE, got)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events for empty payloads, got %v", <-events)
+	}
+}
+
+func TestConnectCapturesClientInfo(t *testing.T) {
+	s, _ := newTestPlayer(0)
+	var authenticated []ConnectInfo
+	s.connectAuthenticator = ConnectAuthenticatorFunc(func(remoteAddr net.Addr, info ConnectInfo) error {
+		authenticated = append(authenticated, info)
+		return nil
+	})
+
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{
+		"app":      "live",
+		"flashVer": "LNX 9,0,124,2",
+		"swfUrl":   "http://example.com/player.swf",
+		"pageUrl":  "http://example.com/watch",
+		"tcUrl":    "rtmp://example.com/live",
+		"fpad":     false,
+	}))
+
+	expected := ConnectInfo{
+		App:      "live",
+		FlashVer: "LNX 9,0,124,2",
+		SwfUrl:   "http://example.com/player.swf",
+		PageUrl:  "http://example.com/watch",
+		TcUrl:    "rtmp://example.com/live",
+	}
+	if info := s.ConnectInfo(); info != expected {
+		t.Fatalf("expected %+v, got %+v", expected, info)
+	}
+	if s.appName != "live" {
+		t.Fatalf("expected app name live, got %q", s.appName)
+	}
+	if len(authenticated) != 1 || authenticated[0] != expected {
+		t.Fatalf("expected authenticator to see %+v, got %+v", expected, authenticated)
+	}
+}
+
+func TestConnectRejectedByAuthenticator(t *testing.T) {
+	s, conn := newTestPlayer(0)
+	s.connectAuthenticator = ConnectAuthenticatorFunc(func(remoteAddr net.Addr, info ConnectInfo) error {
+		if info.TcUrl != "rtmp://example.com/live" {
+			return errors.New("unexpected tcUrl")
+		}
+		return nil
+	})
+
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live", "tcUrl": "rtmp://other.example.com/live"}))
+
+	commands := readCommands(t, conn)
+	if len(commands) != 1 || commands[0][0] != "_error" {
+		t.Fatalf("expected a single _error reply, got %v", commands)
+	}
+	info, _ := commands[0][3].(map[string]any)
+	if info["code"] != "NetConnection.Connect.Rejected" {
+		t.Fatalf("expected NetConnection.Connect.Rejected, got %v", info["code"])
+	}
+	if !s.connectRejected || !conn.closed {
+		t.Fatal("expected the rejected connection to be closed")
+	}
+}
+
+func TestMediaEventsCarryParsedHeader(t *testing.T) {
+	publisher, _ := newTestPlayer(1)
+	publisher.appName = "live"
+	publisher.streamName = "test"
+	publisher.isPublishing = true
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+
+	videoTests := []struct {
+		name           string
+		data           [][]byte
+		sequenceHeader bool
+		keyFrame       bool
+		cts            int32
+	}{
+		{"sequence header", testAVCSequenceHeader, true, false, 0},
+		{"key frame", [][]byte{{0x17, 0x01, 0x00, 0x00, 0x21, 0x65}}, false, true, 33},
+		{"inter frame", testAVCInterFrame, false, false, 0},
+	}
+	for _, tt := range videoTests {
+		publisher.handleVideo(NewMessage(newMessageHeader(0, 0, MSG_TYPE_VIDEO, 1), tt.data))
+		event := (<-events).(VideoData)
+		if event.Codec != codec.H264 || event.SequenceHeader != tt.sequenceHeader || event.KeyFrame != tt.keyFrame || event.CompositionTime != tt.cts {
+			t.Errorf("%s: expected H.264 sequenceHeader=%v keyFrame=%v cts=%d, got %s %v %v %d", tt.name, tt.sequenceHeader, tt.keyFrame, tt.cts,
+				event.Codec, event.SequenceHeader, event.KeyFrame, event.CompositionTime)
+		}
+	}
+
+	audioTests := []struct {
+		name           string
+		data           [][]byte
+		codec          codec.Codec
+		sequenceHeader bool
+	}{
+		{"AAC sequence header", testAACSequenceHeader, codec.AAC, true},
+		{"AAC frame", testAACFrame, codec.AAC, false},
+		{"MP3 frame", [][]byte{{0x2F, 0xFF, 0xFB}}, codec.MP3, false},
+	}
+	for _, tt := range audioTests {
+		publisher.handleAudio(NewMessage(newMessageHeader(0, 0, MSG_TYPE_AUDIO, 1), tt.data))
+		event := (<-events).(AudioData)
+		if event.Codec != tt.codec || event.SequenceHeader != tt.sequenceHeader {
+			t.Errorf("%s: expected %s sequenceHeader=%v, got %s %v", tt.name, tt.codec, tt.sequenceHeader, event.Codec, event.SequenceHeader)
+		}
+	}
+}
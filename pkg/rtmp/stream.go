@@ -1,7 +1,12 @@
 package rtmp
 
 import (
+	"bytes"
 	"log/slog"
+	"sol/pkg/amf"
+	"sol/pkg/codec"
+	"sort"
+	"time"
 )
 
 // Stream은 개별 스트림 정보를 관리
@@ -9,25 +14,108 @@ type Stream struct {
 	name    string
 	players map[*session]struct{} // player sessions 직접 참조
 
-	// 메타데이터 캐시
+	// 재생 준비가 될 때까지 대기 중인 플레이어 (waitForPlayable 설정 시)
+	pendingPlayers  map[*session]struct{}
+	waitForPlayable bool
+
+	// 메타데이터 캐시 (필터링하지 않은 원본)
 	lastMetadata map[string]any
 
+	// 플레이어에게 전달할 메타데이터 키 필터
+	metadataFilter MetadataFilter
+
+	// onMetaData를 스트림 타임스탬프와 플레이어 스트림 ID로 전송할지 여부
+	stampMetadata bool
+
+	// 데이터 메시지 캐시 (이름별 마지막 메시지, onTextData/onCuePoint 등)
+	lastDataMessages map[string]DataMessage
+
 	// 비디오 캐시 (GOP 기반)
 	videoCache VideoCache
 
 	// 오디오 캐시 (최근 프레임들)
 	audioCache AudioCache
 
+	// 유휴 캐시 압축 (compressedCache가 nil이 아니면 캐시 프레임의 payload가 압축되어 있음)
+	compressedCache    *compressedCache
+	cacheUsedAt        time.Time // 캐시를 마지막으로 읽거나 바꾼 시각
+	cacheCompressTried bool      // 캐시를 마지막으로 쓴 뒤 압축을 이미 시도함
+
+	// 녹화 (publish 유형이 record/append인 경우)
+	recorder      *recorder
+	recordingSink RecordingSink // 녹화 저장소 (nil이면 로컬 파일 시스템)
+
+	// 마지막으로 수신한 미디어 타임스탬프 (메타데이터 타임스탬프 정렬용)
+	lastTimestamp uint32
+
+	// 비디오에 composition time offset이 있는 프레임(B-프레임)이 있었는지
+	hasBFrames bool
+
+	// 현재 발행자 세션과 FCPublish로 발행을 예약한 세션
+	publisher  *session
+	reservedBy *session
+
+	// 발행자 연결이 끊긴 시각 (재연결 유예 중이 아니면 zero)
+	publisherLostAt time.Time
+
+	// 발행자 세션/주소와 발행 시작 시각 (발행자가 제거되면 zero)
+	publisherInfo PublisherInfo
+
+	// 현재 발행자의 수신 비트레이트 (최대 발행 비트레이트 설정 시)
+	inboundBitrate bitrateMeter
+
+	// 현재 발행자의 마지막 미디어 수신 시각 (발행 시작 시각으로 초기화, releaseStream 시 끊긴 연결 판단)
+	publisherMediaAt time.Time
+
+	// 프레임 브로드캐스트 시간 기록 (nil이면 기록하지 않음)
+	timings *eventTimings
+
+	// 오디오/비디오 드리프트 보정 (nil이면 비활성화)
+	avSync *avSyncCorrector
+
+	// 메타데이터에 선언된 코덱과 프레임에서 감지한 코덱 (불일치 시 onCodecMismatch 호출)
+	videoCodecs     codecTrack
+	audioCodecs     codecTrack
+	onCodecMismatch func(CodecMismatch)
+
 	// 설정 값들
 	gopCacheSize        int
 	maxPlayersPerStream int
+	cacheEviction       CacheEvictionPolicy
+	cacheDuration       time.Duration
+
+	// 플레이어 없이 발행 중일 때 유지할 워밍업 버퍼 길이 (0이면 비활성화)
+	// warmBuffer는 캐시가 워밍업 버퍼를 담고 있는지 (플레이어가 입장하면 평소 크기로 줄임)
+	warmUp     time.Duration
+	warmBuffer bool
+
+	// 마지막으로 캐시한 GOP/오디오 프레임의 순서 번호 (캐시 재전송 시 발행 순서 복원용)
+	frameSeq uint64
+
+	// 마지막 플레이어가 나간 시각 (플레이어가 있거나 TTL이 만료되면 zero)
+	// 프레즌스 TTL이 지나면 onIdle 호출 (풀 소스가 업스트림을 끊는 훅, nil이면 없음)
+	idleSince time.Time
+	onIdle    func()
 }
 
+// CacheEvictionPolicy는 비디오 캐시에서 오래된 프레임을 버리는 기준
+type CacheEvictionPolicy string
+
+const (
+	CacheEvictionFrames   CacheEvictionPolicy = "frames"   // 프레임 수 기준 (gopCacheSize)
+	CacheEvictionDuration CacheEvictionPolicy = "duration" // 프레임 타임스탬프 기준 (최근 cacheDuration)
+)
+
+// duration 정책에서 유지 시간을 지정하지 않은 경우의 기본값
+const defaultCacheDuration = 2 * time.Second
+
 // VideoFrame은 비디오 프레임 정보
 type VideoFrame struct {
-	frameType string // "key frame", "inter frame", "AVC sequence header", "AVC NALU"
-	timestamp uint32
-	data      [][]byte // Zero-copy payload chunks
+	frameType       string // "key frame", "inter frame", "AVC sequence header", "AVC NALU"
+	timestamp       uint32
+	compositionTime int32    // composition time offset (PTS = timestamp + compositionTime)
+	data            [][]byte // Zero-copy payload chunks
+	seq             uint64   // 스트림에 캐시된 순서 (오디오와 합쳐 발행 순서대로 재전송)
 }
 
 // AudioFrame은 오디오 프레임 정보  
@@ -35,12 +123,15 @@ type AudioFrame struct {
 	frameType string // "audio", "AAC sequence header"
 	timestamp uint32
 	data      [][]byte // Zero-copy payload chunks
+	seq       uint64   // 스트림에 캐시된 순서 (비디오와 합쳐 발행 순서대로 재전송)
 }
 
 // VideoCache는 비디오 프레임 캐시를 관리
 type VideoCache struct {
 	sequenceHeader *VideoFrame   // AVC sequence header
 	gopFrames      []VideoFrame  // GOP 프레임들 (키프레임 + 후속 프레임들)
+	keyframes      keyframeIndex // gopFrames의 키프레임 (위치 = evicted + gopFrames 인덱스)
+	evicted        int           // 지금까지 gopFrames 앞에서 제거된 프레임 수
 }
 
 // AudioCache는 오디오 프레임 캐시를 관리
@@ -52,21 +143,28 @@ type AudioCache struct {
 
 // CachedFrame은 호환성을 위한 통합 프레임 정보 (기존 코드와의 호환성)
 type CachedFrame struct {
-	frameType string
-	timestamp uint32
-	data      []byte
-	msgType   uint8 // 8=audio, 9=video
+	frameType       string
+	timestamp       uint32
+	compositionTime int32 // 비디오의 composition time offset
+	data            []byte
+	msgType         uint8 // 8=audio, 9=video
 }
 
 // copyChunks 함수는 zero-copy 최적화로 인해 제거됨
 // 대신 data를 직접 참조하여 메모리 효율성 향상
 
-// concatChunks는 [][]byte를 하나의 []byte로 합친다 (호환성 지원용)
-func concatChunks(chunks [][]byte) []byte {
+// chunksSize는 [][]byte payload의 전체 바이트 수를 반환
+func chunksSize(chunks [][]byte) int {
 	totalLen := 0
 	for _, chunk := range chunks {
 		totalLen += len(chunk)
 	}
+	return totalLen
+}
+
+// concatChunks는 [][]byte를 하나의 []byte로 합친다 (호환성 지원용)
+func concatChunks(chunks [][]byte) []byte {
+	totalLen := chunksSize(chunks)
 	
 	result := make([]byte, 0, totalLen)
 	for _, chunk := range chunks {
@@ -74,11 +172,18 @@ func concatChunks(chunks [][]byte) []byte {
 	}
 	return result
 }
+
+// sameChunks는 청크 경계와 무관하게 두 payload의 내용이 같은지 비교
+func sameChunks(a, b [][]byte) bool {
+	return bytes.Equal(concatChunks(a), concatChunks(b))
+}
+
 // NewStream은 새로운 스트림을 생성
 func NewStream(name string, gopCacheSize, maxPlayersPerStream int) *Stream {
 	return &Stream{
-		name:    name,
-		players: make(map[*session]struct{}),
+		name:           name,
+		players:        make(map[*session]struct{}),
+		pendingPlayers: make(map[*session]struct{}),
 		videoCache: VideoCache{
 			gopFrames: make([]VideoFrame, 0),
 		},
@@ -92,9 +197,15 @@ func NewStream(name string, gopCacheSize, maxPlayersPerStream int) *Stream {
 }
 
 // addAudioFrame은 오디오 프레임을 오디오 캐시에 추가
-func (s *Stream) addAudioFrame(timestamp uint32, data [][]byte) {
-	// AAC sequence header 특수 처리 - 첫 번째 청크를 기준으로 판단
-	if len(data) > 0 && len(data[0]) > 1 && ((data[0][0]>>4)&0x0F) == 10 && data[0][1] == 0 {
+func (s *Stream) addAudioFrame(timestamp uint32, sequenceHeader bool, data [][]byte) {
+	// AAC sequence header 특수 처리
+	if sequenceHeader {
+		// 코덱 설정이 바뀌면 이전 설정으로 인코딩된 캐시 프레임은 새 플레이어가 디코딩할 수 없으므로 버림
+		if s.audioCache.sequenceHeader != nil && !sameChunks(s.audioCache.sequenceHeader.data, data) {
+			s.audioCache.recentFrames = make([]AudioFrame, 0)
+			slog.Info("AAC sequence header changed", "streamName", s.name, "timestamp", timestamp)
+		}
+
 		// AAC sequence header 설정 (zero-copy)
 		s.audioCache.sequenceHeader = &AudioFrame{
 			frameType: "AAC sequence header",
@@ -106,62 +217,270 @@ func (s *Stream) addAudioFrame(timestamp uint32, data [][]byte) {
 	}
 
 	// 일반 오디오 프레임 추가 (zero-copy)
+	s.frameSeq++
 	audioFrame := AudioFrame{
 		frameType: "audio",
 		timestamp: timestamp,
 		data:      data, // Direct reference for zero-copy
+		seq:       s.frameSeq,
 	}
 
 	// 오디오 프레임을 최근 프레임 리스트에 추가
+	warming := s.updateWarmUp(timestamp)
 	s.audioCache.recentFrames = append(s.audioCache.recentFrames, audioFrame)
 
-	// 최대 프레임 수 제한
-	if len(s.audioCache.recentFrames) > s.audioCache.maxFrames {
+	// 최대 프레임 수 제한 (워밍업 중에는 워밍업 버퍼 길이만큼 유지)
+	if warming {
+		s.trimWarmAudio(timestamp)
+	} else if len(s.audioCache.recentFrames) > s.audioCache.maxFrames {
 		s.audioCache.recentFrames = s.audioCache.recentFrames[len(s.audioCache.recentFrames)-s.audioCache.maxFrames:]
 	}
 }
 
 // ProcessAudioData는 오디오 데이터를 받아서 캐시 업데이트 후 모든 플레이어에게 전송
 func (s *Stream) ProcessAudioData(event AudioData) {
+	// 세션 밖에서 만든 이벤트는 구조화된 필드가 비어 있을 수 있으므로 한 번 해석
+	if event.Codec == codec.Unknown {
+		event = newAudioData(event.SessionId, event.StreamName, event.Timestamp, event.Data)
+	}
+	if s.avSync != nil && !event.SequenceHeader {
+		s.avSync.observeAudio(event.Timestamp)
+	}
+	s.useCache()
+	s.detectAudioCodec(event.Codec)
+
 	// 오디오 프레임 캐시
-	s.addAudioFrame(event.Timestamp, event.Data)
+	s.addAudioFrame(event.Timestamp, event.SequenceHeader, event.Data)
+	s.lastTimestamp = event.Timestamp
+
+	// 녹화 중이면 파일에 기록
+	s.recordTag(MSG_TYPE_AUDIO, event.Timestamp, event.Data)
 
 	// 모든 플레이어에게 동기적으로 전송 (race condition 방지)
+	// 새 sequence header도 그대로 전달되어 기존 플레이어가 디코더를 재설정
+	start := time.Now()
 	for player := range s.players {
 		s.sendAudioToPlayer(player, event)
 	}
+	s.timings.observeBroadcast(start)
+
+	// 재생 준비가 끝났으면 대기 중인 플레이어 입장
+	s.admitPendingPlayers()
 }
 
 // ProcessVideoData는 비디오 데이터를 받아서 비디오 캐시 업데이트 후 모든 플레이어에게 전송
 func (s *Stream) ProcessVideoData(event VideoData) {
+	// 세션 밖에서 만든 이벤트는 구조화된 필드가 비어 있을 수 있으므로 한 번 해석
+	if event.Codec == codec.Unknown {
+		event = newVideoData(event.SessionId, event.StreamName, event.Timestamp, event.FrameType, event.Data)
+	}
+
+	// 드리프트 보정은 캐시, 녹화, 플레이어 전송 모두에 같은 타임스탬프로 적용
+	if s.avSync != nil && !event.SequenceHeader {
+		event.Timestamp = s.avSync.correctVideo(event.Timestamp)
+	}
+	s.useCache()
+	s.detectVideoCodec(event.Codec)
+
 	// 비디오 프레임 캐시 업데이트
-	s.addVideoFrame(event.FrameType, event.Timestamp, event.Data)
+	s.addVideoFrame(event.FrameType, event.Timestamp, event.CompositionTime, event.Data)
+	s.lastTimestamp = event.Timestamp
+
+	// B-프레임(재정렬된 프레임)이 있으면 DTS와 PTS가 달라진다
+	if event.CompositionTime != 0 && !s.hasBFrames {
+		s.hasBFrames = true
+		slog.Info("B-frames detected", "streamName", s.name, "timestamp", event.Timestamp, "compositionTime", event.CompositionTime)
+	}
+
+	// 녹화 중이면 파일에 기록
+	s.recordTag(MSG_TYPE_VIDEO, event.Timestamp, event.Data)
 
 	// 모든 플레이어에게 동기적으로 전송 (race condition 방지)
+	// 새 sequence header도 그대로 전달되어 기존 플레이어가 디코더를 재설정
+	start := time.Now()
 	for player := range s.players {
 		s.sendVideoToPlayer(player, event)
 	}
+	s.timings.observeBroadcast(start)
+
+	// 재생 준비가 끝났으면 대기 중인 플레이어 입장
+	s.admitPendingPlayers()
 }
 
 // ProcessMetaData는 메타데이터를 받아서 캐시 업데이트 후 모든 플레이어에게 전송
 func (s *Stream) ProcessMetaData(event MetaData) {
 	// 메타데이터 캐시
 	s.SetMetadata(event.Metadata)
+	s.checkMetadataCodecs(event.Metadata)
+
+	// 녹화 중이면 파일에 기록
+	if s.recorder != nil {
+		payload, err := amf.EncodeAMF0Sequence("onMetaData", event.Metadata)
+		if err != nil {
+			slog.Error("Failed to encode metadata for recording", "streamName", s.name, "err", err)
+		} else {
+			s.recordTag(MSG_TYPE_AMF0_DATA, 0, [][]byte{payload})
+		}
+	}
 
 	// 모든 플레이어에게 동기적으로 전송 (race condition 방지)
+	// 현재 재생 위치에 맞춰 마지막 미디어 타임스탬프로 전송
 	for player := range s.players {
-		s.sendMetaDataToPlayer(player, event)
+		s.sendMetaDataToPlayer(player, event, s.lastTimestamp)
 	}
+
+	// 비디오가 없다고 알리는 메타데이터면 오디오만으로 재생 준비가 끝났을 수 있음
+	s.admitPendingPlayers()
 }
 
-// SetPublisher는 스트림의 발행자를 설정 (로깅만 수행)
+// ProcessDataMessage는 데이터 메시지를 캐시하고 모든 플레이어에게 원본 그대로 전송
+// 미디어 없이 데이터만 발행하는 스트림도 같은 경로로 중계됨
+func (s *Stream) ProcessDataMessage(event DataMessage) {
+	if s.lastDataMessages == nil {
+		s.lastDataMessages = make(map[string]DataMessage)
+	}
+	s.lastDataMessages[event.Name] = event
+	s.lastTimestamp = event.Timestamp
+
+	// 녹화 중이면 파일에 기록
+	s.recordTag(MSG_TYPE_AMF0_DATA, event.Timestamp, event.Data)
+
+	for player := range s.players {
+		s.sendDataMessageToPlayer(player, event)
+	}
+}
+
+// SetPublisher는 스트림의 발행자를 설정 (발행 예약은 해제됨)
 func (s *Stream) SetPublisher(publisher *session) {
+	s.publisher = publisher
+	s.reservedBy = nil
+	now := time.Now()
+	s.recordPublisher(publisher, now)
+	s.publisherMediaAt = now
+	s.inboundBitrate = bitrateMeter{}
+	if s.avSync != nil {
+		s.avSync.reset()
+	}
 	slog.Info("Publisher set", "streamName", s.name, "sessionId", publisher.sessionId)
 }
 
-// RemovePublisher는 스트림의 발행자를 제거 (캐시 청소만 수행)
+// GetPublisher는 현재 발행자 세션을 반환 (없으면 nil)
+func (s *Stream) GetPublisher() *session {
+	return s.publisher
+}
+
+// notePublisherMedia는 현재 발행자가 보낸 미디어의 수신 시각을 기록
+func (s *Stream) notePublisherMedia(sessionId string, now time.Time) {
+	if s.publisher != nil && s.publisher.sessionId == sessionId {
+		s.publisherMediaAt = now
+	}
+}
+
+// publisherStale은 발행자가 staleAfter 넘게 미디어를 보내지 않았는지 확인
+func (s *Stream) publisherStale(now time.Time, staleAfter time.Duration) bool {
+	return now.Sub(s.publisherMediaAt) > staleAfter
+}
+
+// ReservePublisher는 FCPublish를 보낸 세션의 발행 의사를 기록 (publish 전까지 스트림 유지)
+func (s *Stream) ReservePublisher(publisher *session) {
+	s.reservedBy = publisher
+	slog.Info("Publisher reserved", "streamName", s.name, "sessionId", publisher.sessionId)
+}
+
+// IsPublishReserved는 발행이 예약된 상태인지 확인
+func (s *Stream) IsPublishReserved() bool {
+	return s.reservedBy != nil
+}
+
+// StartRecording은 발행 유형에 맞게 녹화를 시작 (RecordModeNone이면 아무것도 하지 않음)
+func (s *Stream) StartRecording(path string, mode RecordMode) error {
+	if mode == RecordModeNone {
+		return nil
+	}
+
+	// 이전 녹화가 남아있으면 정리
+	s.StopRecording()
+
+	rec, err := newRecorder(s.recordingSink, path, mode)
+	if err != nil {
+		return err
+	}
+	s.recorder = rec
+	slog.Info("Recording started", "streamName", s.name, "path", path, "mode", mode)
+	return nil
+}
+
+// SetRecordingSink는 녹화 파일을 저장할 백엔드를 설정 (nil이면 로컬 파일 시스템)
+func (s *Stream) SetRecordingSink(sink RecordingSink) {
+	s.recordingSink = sink
+}
+
+// StopRecording은 진행 중인 녹화를 종료
+func (s *Stream) StopRecording() {
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.close(); err != nil {
+		slog.Error("Failed to close record file", "streamName", s.name, "path", s.recorder.path, "err", err)
+	}
+	slog.Info("Recording stopped", "streamName", s.name, "path", s.recorder.path)
+	s.recorder = nil
+}
+
+// IsRecording은 녹화 중인지 확인
+func (s *Stream) IsRecording() bool {
+	return s.recorder != nil
+}
+
+// recordTag는 녹화 중인 경우 FLV 태그를 기록
+func (s *Stream) recordTag(tagType uint8, timestamp uint32, data [][]byte) {
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.writeTag(tagType, timestamp, data); err != nil {
+		slog.Error("Failed to record tag, stopping recording", "streamName", s.name, "err", err)
+		s.StopRecording()
+	}
+}
+
+// SuspendPublisher는 발행자 연결이 끊겼지만 재연결 유예 동안 캐시/녹화/플레이어를 유지
+func (s *Stream) SuspendPublisher(now time.Time) {
+	s.publisher = nil
+	s.publisherLostAt = now
+	slog.Info("Publisher suspended, waiting for reconnect", "streamName", s.name, "playerCount", len(s.players))
+}
+
+// ResumePublisher는 재연결 유예 중인 스트림의 발행을 재개 (유예 중이었는지 반환)
+func (s *Stream) ResumePublisher() bool {
+	if !s.IsPublisherSuspended() {
+		return false
+	}
+	slog.Info("Publisher resumed", "streamName", s.name, "downtime", time.Since(s.publisherLostAt))
+	s.publisherLostAt = time.Time{}
+	return true
+}
+
+// IsPublisherSuspended는 발행자 재연결을 기다리는 중인지 확인
+func (s *Stream) IsPublisherSuspended() bool {
+	return !s.publisherLostAt.IsZero()
+}
+
+// PublisherGraceExpired는 재연결 유예 시간이 지났는지 확인
+func (s *Stream) PublisherGraceExpired(now time.Time, grace time.Duration) bool {
+	return s.IsPublisherSuspended() && now.Sub(s.publisherLostAt) >= grace
+}
+
+// RemovePublisher는 스트림의 발행자를 제거 (녹화 종료 및 캐시 청소)
 func (s *Stream) RemovePublisher() {
+	s.publisher = nil
+	s.publisherLostAt = time.Time{}
+	s.publisherInfo = PublisherInfo{}
+
+	// 녹화 종료
+	s.StopRecording()
+
 	// 모든 캐시 청소
+	s.compressedCache = nil
 	s.videoCache = VideoCache{
 		gopFrames: make([]VideoFrame, 0),
 	}
@@ -170,17 +489,35 @@ func (s *Stream) RemovePublisher() {
 		maxFrames:    10,
 	}
 	s.lastMetadata = nil
+	s.lastDataMessages = nil
+	s.lastTimestamp = 0
+	s.videoCodecs = codecTrack{}
+	s.audioCodecs = codecTrack{}
 	slog.Info("Publisher removed and all caches cleared", "streamName", s.name)
 }
 
 // AddPlayer는 플레이어를 추가하고 즉시 캐시된 데이터를 전송
+// waitForPlayable이 설정되어 있고 아직 재생 준비가 안 됐으면 준비될 때까지 대기열에 넣음
 func (s *Stream) AddPlayer(player *session) {
-	// 최대 플레이어 수 체크
-	if s.maxPlayersPerStream > 0 && len(s.players) >= s.maxPlayersPerStream {
+	// 최대 플레이어 수 체크 (대기 중인 플레이어 포함)
+	if s.maxPlayersPerStream > 0 && len(s.players)+len(s.pendingPlayers) >= s.maxPlayersPerStream {
 		slog.Warn("Maximum players reached for stream", "streamName", s.name, "maxPlayers", s.maxPlayersPerStream, "currentPlayers", len(s.players))
 		return
 	}
 
+	if s.waitForPlayable && !s.IsPlayable() {
+		s.clearIdle()
+		s.pendingPlayers[player] = struct{}{}
+		slog.Info("Player waiting for stream to become playable", "streamName", s.name, "sessionId", player.sessionId, "pendingCount", len(s.pendingPlayers))
+		return
+	}
+
+	s.addPlayer(player)
+}
+
+// addPlayer는 플레이어를 등록하고 캐시된 데이터를 전송
+func (s *Stream) addPlayer(player *session) {
+	s.clearIdle()
 	s.players[player] = struct{}{}
 	slog.Info("Player added", "streamName", s.name, "sessionId", player.sessionId, "playerCount", len(s.players))
 
@@ -188,9 +525,115 @@ func (s *Stream) AddPlayer(player *session) {
 	s.SendCachedDataToPlayer(player)
 }
 
+// ResumePlayer는 재연결한 플레이어를 timestamp 근처부터 이어서 재생
+// 캐시가 timestamp를 아직 포함하면 sequence header와 그 이전 마지막 키프레임부터의 프레임만 전송하고
+// (메타데이터와 데이터 메시지는 이미 받았으므로 생략) true를 반환. 포함하지 않으면 false (처음 입장처럼 처리)
+func (s *Stream) ResumePlayer(player *session, timestamp uint32) bool {
+	if s.maxPlayersPerStream > 0 && len(s.players)+len(s.pendingPlayers) >= s.maxPlayersPerStream {
+		return false
+	}
+
+	s.useCache()
+	start := s.videoCache.seekKeyFrame(timestamp)
+	if start < 0 || s.videoCache.sequenceHeader == nil {
+		return false
+	}
+
+	s.clearIdle()
+	s.players[player] = struct{}{}
+	resumeFrom := s.videoCache.gopFrames[start].timestamp
+	slog.Info("Player resumed", "streamName", s.name, "sessionId", player.sessionId, "timestamp", timestamp, "resumeFrom", resumeFrom, "playerCount", len(s.players))
+
+	s.sendVideoToPlayer(player, newVideoData("cache", s.name, s.videoCache.sequenceHeader.timestamp, s.videoCache.sequenceHeader.frameType, s.videoCache.sequenceHeader.data))
+	if s.audioCache.sequenceHeader != nil {
+		s.sendAudioToPlayer(player, newAudioData("cache", s.name, s.audioCache.sequenceHeader.timestamp, s.audioCache.sequenceHeader.data))
+	}
+	audio := s.audioCache.recentFrames
+	for len(audio) > 0 && audio[0].timestamp < resumeFrom {
+		audio = audio[1:]
+	}
+	s.sendCachedFrames(player, s.videoCache.gopFrames[start:], audio)
+	return true
+}
+
+// admitPendingPlayers는 스트림이 재생 가능해지면 대기 중인 플레이어를 모두 등록
+func (s *Stream) admitPendingPlayers() {
+	if len(s.pendingPlayers) == 0 || !s.IsPlayable() {
+		return
+	}
+	for player := range s.pendingPlayers {
+		delete(s.pendingPlayers, player)
+		s.addPlayer(player)
+	}
+}
+
+// SetWaitForPlayable은 재생 준비 전 입장한 플레이어를 대기시킬지 설정
+func (s *Stream) SetWaitForPlayable(wait bool) {
+	s.waitForPlayable = wait
+}
+
+// IsPlayable은 재생을 바로 시작할 수 있는지 확인
+// AVC sequence header와 키프레임이 캐시되어 있고, AAC 오디오가 있으면 AAC sequence header도 캐시되어 있어야 함
+// 비디오가 없는 스트림은 오디오 디코더 설정(AAC sequence header, 그 외 코덱은 첫 오디오 프레임)만 있으면 재생 가능
+func (s *Stream) IsPlayable() bool {
+	s.useCache()
+	if s.videoCache.sequenceHeader == nil {
+		return s.isAudioOnly() && s.hasPlayableAudio()
+	}
+
+	hasKeyFrame := false
+	for _, frame := range s.videoCache.gopFrames {
+		if frame.frameType == "key frame" || isVideoKeyFrame(frame.data) {
+			hasKeyFrame = true
+			break
+		}
+	}
+	if !hasKeyFrame {
+		return false
+	}
+
+	// AAC 오디오가 들어오고 있으면 디코더 설정이 필요
+	for _, frame := range s.audioCache.recentFrames {
+		if audioCodec(frame.data) == codec.AAC {
+			return s.audioCache.sequenceHeader != nil
+		}
+	}
+	return true
+}
+
+// isAudioOnly는 비디오 없이 오디오만 발행되는 스트림인지 확인
+// 메타데이터가 있으면 hasVideo/videocodecid로, 없으면 아직 비디오 프레임이 오지 않았는지로 판단
+func (s *Stream) isAudioOnly() bool {
+	if s.lastMetadata != nil {
+		if hasVideo, ok := s.lastMetadata["hasVideo"].(bool); ok {
+			return !hasVideo
+		}
+		_, hasVideoCodec := s.lastMetadata["videocodecid"]
+		return !hasVideoCodec
+	}
+	return len(s.videoCache.gopFrames) == 0
+}
+
+// hasPlayableAudio는 오디오만으로 재생을 시작할 수 있는지 확인
+func (s *Stream) hasPlayableAudio() bool {
+	if s.audioCache.sequenceHeader != nil {
+		return true
+	}
+	// AAC가 아닌 코덱은 디코더 설정 없이 첫 프레임부터 재생 가능
+	detected := s.audioCodecs.detected
+	return detected != codec.Unknown && detected != codec.AAC
+}
+
+// GetPendingPlayerCount는 재생 준비를 기다리는 플레이어 수를 반환
+func (s *Stream) GetPendingPlayerCount() int {
+	return len(s.pendingPlayers)
+}
+
 // RemovePlayer는 플레이어를 제거
 func (s *Stream) RemovePlayer(player *session) {
 	delete(s.players, player)
+	delete(s.pendingPlayers, player)
+	s.markIdle(time.Now())
 	slog.Info("Player removed", "streamName", s.name, "sessionId", player.sessionId, "playerCount", len(s.players))
 }
 
@@ -214,15 +657,41 @@ func (s *Stream) SetMetadata(metadata map[string]any) {
 	slog.Debug("Metadata cached", "streamName", s.name)
 }
 
-// GetMetadata는 캐시된 메타데이터를 반환
+// SetAVSyncCorrection은 오디오/비디오 드리프트 보정을 켜거나 끈다 (tolerance가 0이면 기본값)
+func (s *Stream) SetAVSyncCorrection(enabled bool, tolerance time.Duration) {
+	if !enabled {
+		s.avSync = nil
+		return
+	}
+	s.avSync = newAVSyncCorrector(s.name, tolerance)
+}
+
+// SetMetadataFilter는 플레이어에게 전달할 메타데이터 키 필터를 설정
+func (s *Stream) SetMetadataFilter(filter MetadataFilter) {
+	s.metadataFilter = filter
+}
+
+// SetMetadataStamping은 onMetaData를 현재 스트림 타임스탬프와 플레이어 스트림 ID로 전송할지 설정
+// 끄면 이전처럼 타임스탬프 0, 스트림 ID 0으로 전송
+func (s *Stream) SetMetadataStamping(enabled bool) {
+	s.stampMetadata = enabled
+}
+
+// GetMetadata는 캐시된 메타데이터를 반환 (필터링하지 않은 원본)
 func (s *Stream) GetMetadata() map[string]any {
 	return s.lastMetadata
 }
 
 // addVideoFrame은 비디오 프레임을 비디오 캐시에 추가
-func (s *Stream) addVideoFrame(frameType string, timestamp uint32, data [][]byte) {
+func (s *Stream) addVideoFrame(frameType string, timestamp uint32, compositionTime int32, data [][]byte) {
 	// H.264 AVC sequence header는 별도 처리
 	if frameType == "AVC sequence header" {
+		// 해상도/코덱이 바뀌면 이전 GOP는 새 설정으로 디코딩할 수 없으므로 다음 키프레임부터 다시 캐시
+		if s.videoCache.sequenceHeader != nil && !sameChunks(s.videoCache.sequenceHeader.data, data) {
+			s.videoCache.clearFrames()
+			slog.Info("AVC sequence header changed", "streamName", s.name, "timestamp", timestamp)
+		}
+
 		// AVC sequence header 설정 (zero-copy)
 		s.videoCache.sequenceHeader = &VideoFrame{
 			frameType: frameType,
@@ -233,42 +702,105 @@ func (s *Stream) addVideoFrame(frameType string, timestamp uint32, data [][]byte
 		return
 	}
 
+	// 워밍업 중에는 정책과 관계없이 워밍업 버퍼 길이만큼 여러 GOP를 유지
+	warming := s.updateWarmUp(timestamp)
+	window := s.cacheDuration
+	if warming {
+		window = s.warmUp
+	}
+
 	if frameType == "key frame" || frameType == "AVC NALU" {
-		// key frame인 경우 새 GOP 시작
-		if frameType == "key frame" {
+		// key frame인 경우 새 GOP 시작 (duration 정책은 여러 GOP를 유지)
+		if frameType == "key frame" && s.cacheEviction != CacheEvictionDuration && !warming {
 			// 새 GOP 시작 - 기존 GOP 프레임들 제거
-			s.videoCache.gopFrames = make([]VideoFrame, 0)
+			s.videoCache.clearFrames()
 			slog.Debug("New GOP started", "streamName", s.name, "timestamp", timestamp)
 		}
 
 		// 새 비디오 프레임 추가 (zero-copy)
+		s.frameSeq++
 		videoFrame := VideoFrame{
-			frameType: frameType,
-			timestamp: timestamp,
-			data:      data, // Direct reference for zero-copy
+			frameType:       frameType,
+			timestamp:       timestamp,
+			compositionTime: compositionTime,
+			data:            data, // Direct reference for zero-copy
+			seq:             s.frameSeq,
+		}
+		s.videoCache.appendFrame(videoFrame)
+
+		if s.cacheEviction == CacheEvictionDuration || warming {
+			s.evictVideoFramesByDuration(timestamp, window)
 		}
-		s.videoCache.gopFrames = append(s.videoCache.gopFrames, videoFrame)
 
 	} else if frameType == "inter frame" {
 		// 키프레임 이후 프레임들 캐시에 추가
 		if len(s.videoCache.gopFrames) > 0 { // 키프레임이 있는 경우만
+			s.frameSeq++
 			videoFrame := VideoFrame{
-				frameType: frameType,
-				timestamp: timestamp,
-				data:      data, // Direct reference for zero-copy
+				frameType:       frameType,
+				timestamp:       timestamp,
+				compositionTime: compositionTime,
+				data:            data, // Direct reference for zero-copy
+				seq:             s.frameSeq,
 			}
-			s.videoCache.gopFrames = append(s.videoCache.gopFrames, videoFrame)
+			s.videoCache.appendFrame(videoFrame)
 
 			// 캐시 크기 제한 (설정에서 가져오기)
-			if s.gopCacheSize > 0 && len(s.videoCache.gopFrames) > s.gopCacheSize {
-				s.videoCache.gopFrames = s.videoCache.gopFrames[len(s.videoCache.gopFrames)-s.gopCacheSize:]
+			if s.cacheEviction == CacheEvictionDuration || warming {
+				s.evictVideoFramesByDuration(timestamp, window)
+			} else if s.gopCacheSize > 0 && len(s.videoCache.gopFrames) > s.gopCacheSize {
+				s.videoCache.dropFront(len(s.videoCache.gopFrames) - s.gopCacheSize)
 			}
 		}
 	}
 }
 
+// SetCacheEviction은 비디오 캐시 제거 정책을 설정 (duration이 0이면 defaultCacheDuration)
+func (s *Stream) SetCacheEviction(policy CacheEvictionPolicy, duration time.Duration) {
+	if duration <= 0 {
+		duration = defaultCacheDuration
+	}
+	s.cacheEviction = policy
+	s.cacheDuration = duration
+}
+
+// evictVideoFramesByDuration은 latest 기준 window보다 오래된 프레임을 제거
+// 새 플레이어가 디코딩을 시작할 수 있도록 캐시는 항상 키프레임에서 시작하며,
+// 유지 구간 안에 키프레임이 없으면 가장 최근 키프레임부터 유지
+func (s *Stream) evictVideoFramesByDuration(latest uint32, window time.Duration) {
+	budget := uint32(window / time.Millisecond)
+	frames := s.videoCache.gopFrames
+
+	start := -1
+	for i, frame := range frames {
+		if frame.frameType != "key frame" && !isVideoKeyFrame(frame.data) {
+			continue
+		}
+		start = i
+		if latest-frame.timestamp <= budget {
+			break
+		}
+	}
+
+	// 키프레임이 없으면 타임스탬프만으로 제거
+	if start < 0 {
+		start = len(frames)
+		for i, frame := range frames {
+			if latest-frame.timestamp <= budget {
+				start = i
+				break
+			}
+		}
+	}
+
+	if start > 0 {
+		s.videoCache.dropFront(start)
+	}
+}
+
 // GetGOPCache는 호환성을 위해 통합된 캐시를 CachedFrame 형태로 반환
 func (s *Stream) GetGOPCache() []CachedFrame {
+	s.useCache()
 	cachedFrames := make([]CachedFrame, 0)
 
 	// 1. AVC sequence header 추가
@@ -294,10 +826,11 @@ func (s *Stream) GetGOPCache() []CachedFrame {
 	// 3. 비디오 GOP 프레임들 추가
 	for _, frame := range s.videoCache.gopFrames {
 		cachedFrames = append(cachedFrames, CachedFrame{
-			frameType: frame.frameType,
-			timestamp: frame.timestamp,
-			data:      concatChunks(frame.data), // [][]byte를 []byte로 변환
-			msgType:   9, // video
+			frameType:       frame.frameType,
+			timestamp:       frame.timestamp,
+			compositionTime: frame.compositionTime,
+			data:            concatChunks(frame.data), // [][]byte를 []byte로 변환
+			msgType:         9, // video
 		})
 	}
 
@@ -314,68 +847,217 @@ func (s *Stream) GetGOPCache() []CachedFrame {
 	return cachedFrames
 }
 
+// HasBFrames는 비디오에 B-프레임(0이 아닌 composition time offset)이 있었는지 반환
+// true면 DTS 순서와 표시 순서가 다르므로 muxer는 PTS를 DTS + composition time으로 계산해야 한다
+func (s *Stream) HasBFrames() bool {
+	return s.hasBFrames
+}
+
 // GetName은 스트림 이름을 반환
 func (s *Stream) GetName() string {
 	return s.name
 }
 
-// IsActive는 스트림이 활성 상태인지 확인 (플레이어가 있는 경우 또는 캐시된 데이터가 있는 경우)
+// IsActive는 스트림이 활성 상태인지 확인 (플레이어(대기 포함)가 있거나 캐시된 데이터가 있거나 발행자 재연결 대기 중인 경우)
 func (s *Stream) IsActive() bool {
 	return len(s.players) > 0 || 
+		   len(s.pendingPlayers) > 0 ||
 		   len(s.videoCache.gopFrames) > 0 || 
 		   len(s.audioCache.recentFrames) > 0 ||
 		   s.videoCache.sequenceHeader != nil ||
 		   s.audioCache.sequenceHeader != nil ||
-		   s.lastMetadata != nil
+		   s.lastMetadata != nil ||
+		   len(s.lastDataMessages) > 0 ||
+		   s.publisher != nil ||
+		   s.IsPublishReserved() ||
+		   s.IsPublisherSuspended()
 }
 
 // CleanupSession은 세션 종료 시 스트림에서 해당 세션을 정리
 func (s *Stream) CleanupSession(session *session) {
 	// player 정리
-	if _, exists := s.players[session]; exists {
+	_, playing := s.players[session]
+	_, pending := s.pendingPlayers[session]
+	if playing {
 		delete(s.players, session)
 		slog.Info("Cleaned up player from stream", "streamName", s.name, "sessionId", session.sessionId, "playerCount", len(s.players))
 	}
+	delete(s.pendingPlayers, session)
+	if playing || pending {
+		s.markIdle(time.Now())
+	}
+
+	// 예약만 하고 종료한 세션의 발행 예약 해제
+	if s.reservedBy == session {
+		s.reservedBy = nil
+	}
 
 	// 발행자가 종료되면 캐시 청소 (이는 서버에서 PublishStopped 이벤트로 처리됨)
+	// PublishStopped가 유실된 경우를 대비해 발행자 참조만 정리
+	if s.publisher == session {
+		s.publisher = nil
+	}
 }
 
-// sendAudioToPlayer는 플레이어에게 오디오 데이터를 전송
+// sendAudioToPlayer는 플레이어에게 오디오 데이터를 전송 (플레이어의 스트림 ID 사용, 저지연 모드면 송신 큐에 추가)
 func (s *Stream) sendAudioToPlayer(player *session, event AudioData) {
-	err := player.writer.writeAudioData(player.conn, event.Data, event.Timestamp)
+	if !player.acceptsCodec("audio", event.Codec, s.name) {
+		return
+	}
+	player.lastPlayTimestamp = event.Timestamp
+	if player.sendQueue != nil {
+		player.sendQueue.push(queuedMessage{
+			typeId:         MSG_TYPE_AUDIO,
+			timestamp:      event.Timestamp,
+			streamID:       player.playStreamID,
+			data:           event.Data,
+			sequenceHeader: event.SequenceHeader,
+		})
+		s.enforceMaxDroppedFrames(player)
+		return
+	}
+
+	err := player.writer.writeAudioData(player.conn, event.Data, event.Timestamp, player.playStreamID)
 	if err != nil {
 		slog.Error("Failed to send audio to player", "streamName", s.name, "sessionId", player.sessionId, "err", err)
 	}
 }
 
-// sendVideoToPlayer는 플레이어에게 비디오 데이터를 전송
+// sendVideoToPlayer는 플레이어에게 비디오 데이터를 전송 (플레이어의 스트림 ID 사용, 저지연 모드면 송신 큐에 추가)
 func (s *Stream) sendVideoToPlayer(player *session, event VideoData) {
-	err := player.writer.writeVideoData(player.conn, event.Data, event.Timestamp)
+	if !player.acceptsCodec("video", event.Codec, s.name) {
+		return
+	}
+	player.lastPlayTimestamp = event.Timestamp
+	if player.sendQueue != nil {
+		player.sendQueue.push(queuedMessage{
+			typeId:         MSG_TYPE_VIDEO,
+			timestamp:      event.Timestamp,
+			streamID:       player.playStreamID,
+			data:           event.Data,
+			keyFrame:       event.KeyFrame,
+			sequenceHeader: event.SequenceHeader,
+		})
+		s.enforceMaxDroppedFrames(player)
+		return
+	}
+
+	err := player.writer.writeVideoData(player.conn, event.Data, event.Timestamp, player.playStreamID)
 	if err != nil {
 		slog.Error("Failed to send video to player", "streamName", s.name, "sessionId", player.sessionId, "err", err)
 	}
 }
 
-// sendMetaDataToPlayer는 플레이어에게 메타데이터를 전송
-func (s *Stream) sendMetaDataToPlayer(player *session, event MetaData) {
-	err := player.writer.writeScriptData(player.conn, "onMetaData", event.Metadata)
+// sendDataMessageToPlayer는 플레이어에게 데이터 메시지를 전송 (플레이어의 스트림 ID 사용, 저지연 모드면 송신 큐에 추가)
+func (s *Stream) sendDataMessageToPlayer(player *session, event DataMessage) {
+	if player.sendQueue != nil {
+		player.sendQueue.push(queuedMessage{
+			typeId:    MSG_TYPE_AMF0_DATA,
+			timestamp: event.Timestamp,
+			streamID:  player.playStreamID,
+			data:      event.Data,
+		})
+		return
+	}
+
+	err := player.writer.writeDataMessage(player.conn, event.Data, event.Timestamp, player.playStreamID)
+	if err != nil {
+		slog.Error("Failed to send data message to player", "streamName", s.name, "sessionId", player.sessionId, "err", err)
+	}
+}
+
+// sendMetaDataToPlayer는 플레이어에게 메타데이터를 전송 (저지연 모드면 송신 큐에 추가)
+// 메타데이터 필터에 걸린 키는 빼고, 스탬프 설정이 꺼져 있으면 타임스탬프 0, 스트림 ID 0으로 전송
+func (s *Stream) sendMetaDataToPlayer(player *session, event MetaData, timestamp uint32) {
+	metadata := s.metadataFilter.apply(event.Metadata)
+	if !s.stampMetadata {
+		timestamp = 0
+	}
+
+	streamID := player.playStreamID
+	if !s.stampMetadata {
+		streamID = 0
+	}
+
+	if player.sendQueue != nil {
+		player.sendQueue.push(queuedMessage{
+			typeId:    MSG_TYPE_AMF0_DATA,
+			timestamp: timestamp,
+			streamID:  streamID,
+			metadata:  metadata,
+		})
+		return
+	}
+
+	err := player.writer.writeScriptData(player.conn, "onMetaData", metadata, timestamp, streamID)
 	if err != nil {
 		slog.Error("Failed to send metadata to player", "streamName", s.name, "sessionId", player.sessionId, "err", err)
 	}
 }
 
+// BroadcastError는 재생 중인(대기 포함) 모든 플레이어에게 error 레벨 onStatus를 전송
+func (s *Stream) BroadcastError(code, description string) {
+	statusObj := newStatusObject("error", code, description, s.name)
+	for player := range s.players {
+		s.sendStatusToPlayer(player, statusObj)
+	}
+	for player := range s.pendingPlayers {
+		s.sendStatusToPlayer(player, statusObj)
+	}
+	slog.Warn("Stream error sent to players", "streamName", s.name, "code", code, "description", description, "playerCount", len(s.players)+len(s.pendingPlayers))
+}
+
+// sendStatusToPlayer는 플레이어에게 onStatus를 전송 (저지연 모드면 미디어 순서를 지키도록 송신 큐에 추가)
+func (s *Stream) sendStatusToPlayer(player *session, statusObj map[string]any) {
+	if player.sendQueue != nil {
+		player.sendQueue.push(queuedMessage{
+			typeId:    MSG_TYPE_AMF0_COMMAND,
+			timestamp: s.lastTimestamp,
+			status:    statusObj,
+		})
+		return
+	}
+
+	if err := player.writeStatus(statusObj); err != nil {
+		slog.Error("Failed to send status to player", "streamName", s.name, "sessionId", player.sessionId, "err", err)
+	}
+}
+
+// cacheStartTimestamp는 캐시된 GOP의 시작 타임스탬프를 반환 (캐시가 없으면 마지막 타임스탬프)
+func (s *Stream) cacheStartTimestamp() uint32 {
+	if len(s.videoCache.gopFrames) > 0 {
+		return s.videoCache.gopFrames[0].timestamp
+	}
+	return s.lastTimestamp
+}
+
 // SendCachedDataToPlayer는 새로 입장하는 플레이어에게 캐시된 데이터를 순서대로 전송
 func (s *Stream) SendCachedDataToPlayer(player *session) {
+	s.useCache()
+
 	// 1. 메타데이터 먼저 전송 (동기)
+	// 뒤따르는 캐시 프레임보다 타임스탬프가 앞서지 않도록 캐시 시작 시점으로 전송
 	if s.lastMetadata != nil {
 		s.sendMetaDataToPlayer(player, MetaData{
 			SessionId:  "cache", // 캐시된 데이터는 cache로 표시
 			StreamName: s.name,
 			Metadata:   s.lastMetadata,
-		})
+		}, s.cacheStartTimestamp())
 		slog.Debug("Sent cached metadata to new player", "streamName", s.name, "sessionId", player.sessionId)
 	}
 
+	// 캐시된 데이터 메시지 전송 (이름 순서로, 메타데이터와 같은 시점)
+	names := make([]string, 0, len(s.lastDataMessages))
+	for name := range s.lastDataMessages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		event := s.lastDataMessages[name]
+		event.Timestamp = s.cacheStartTimestamp()
+		s.sendDataMessageToPlayer(player, event)
+	}
+
 	// 2. 캐시된 데이터가 있으면 순서대로 전송 (동기적 처리)
 	hasCachedData := s.videoCache.sequenceHeader != nil || 
 		           len(s.videoCache.gopFrames) > 0 || 
@@ -394,48 +1076,40 @@ func (s *Stream) SendCachedDataToPlayer(player *session) {
 
 		slog.Debug("Sending cached data to new player", "streamName", s.name, "sessionId", player.sessionId, "frameCount", totalFrames)
 
+		// 초기 버스트 페이싱: GOP/오디오 프레임을 송신 큐에서 나눠 보냄
+		if player.burstPacing > 0 && player.sendQueue != nil {
+			player.sendQueue.paceBurst(len(s.videoCache.gopFrames)+len(s.audioCache.recentFrames), player.burstPacing)
+		}
+
 		// 1) AVC sequence header 먼저 전송
 		if s.videoCache.sequenceHeader != nil {
-			s.sendVideoToPlayer(player, VideoData{
-				SessionId:  "cache",
-				StreamName: s.name,
-				Timestamp:  s.videoCache.sequenceHeader.timestamp,
-				FrameType:  s.videoCache.sequenceHeader.frameType,
-				Data:       s.videoCache.sequenceHeader.data,
-			})
+			s.sendVideoToPlayer(player, newVideoData("cache", s.name, s.videoCache.sequenceHeader.timestamp, s.videoCache.sequenceHeader.frameType, s.videoCache.sequenceHeader.data))
 		}
 
 		// 2) AAC sequence header 전송
 		if s.audioCache.sequenceHeader != nil {
-			s.sendAudioToPlayer(player, AudioData{
-				SessionId:  "cache",
-				StreamName: s.name,
-				Timestamp:  s.audioCache.sequenceHeader.timestamp,
-				Data:       s.audioCache.sequenceHeader.data,
-			})
+			s.sendAudioToPlayer(player, newAudioData("cache", s.name, s.audioCache.sequenceHeader.timestamp, s.audioCache.sequenceHeader.data))
 		}
 
-		// 3) 비디오 GOP 프레임들 전송
-		for _, frame := range s.videoCache.gopFrames {
-			s.sendVideoToPlayer(player, VideoData{
-				SessionId:  "cache",
-				StreamName: s.name,
-				Timestamp:  frame.timestamp,
-				FrameType:  frame.frameType,
-				Data:       frame.data,
-			})
-		}
-
-		// 4) 최근 오디오 프레임들 전송
-		for _, frame := range s.audioCache.recentFrames {
-			s.sendAudioToPlayer(player, AudioData{
-				SessionId:  "cache",
-				StreamName: s.name,
-				Timestamp:  frame.timestamp,
-				Data:       frame.data,
-			})
-		}
+		// 3) 비디오 GOP 프레임과 최근 오디오 프레임을 발행 순서대로 전송
+		s.sendCachedFrames(player, s.videoCache.gopFrames, s.audioCache.recentFrames)
 
 		slog.Debug("Finished sending cached data to new player", "streamName", s.name, "sessionId", player.sessionId)
 	}
 }
+
+// sendCachedFrames는 캐시된 비디오 GOP 프레임과 오디오 프레임을 캐시된 순서(발행 순서)대로 섞어 전송
+// 비디오를 모두 보낸 뒤 오디오를 보내면 플레이어에게 타임스탬프가 되돌아가는 오디오가 도착한다
+func (s *Stream) sendCachedFrames(player *session, video []VideoFrame, audio []AudioFrame) {
+	for len(video) > 0 || len(audio) > 0 {
+		if len(audio) == 0 || (len(video) > 0 && video[0].seq < audio[0].seq) {
+			frame := video[0]
+			video = video[1:]
+			s.sendVideoToPlayer(player, newVideoData("cache", s.name, frame.timestamp, frame.frameType, frame.data))
+			continue
+		}
+		frame := audio[0]
+		audio = audio[1:]
+		s.sendAudioToPlayer(player, newAudioData("cache", s.name, frame.timestamp, frame.data))
+	}
+}
@@ -0,0 +1,85 @@
+package rtmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StreamNamePolicy는 publish/play 스트림 이름에 허용하는 문자 범위
+// 어떤 정책이든 빈 이름, 잘못된 UTF-8, 제어 문자, 역슬래시, 빈 경로 요소와 "." ".." 요소는 거부한다
+// (스트림 이름은 맵 키, 로그, SDP, 녹화 파일 경로에 그대로 쓰인다)
+type StreamNamePolicy string
+
+const (
+	StreamNameUnicode StreamNamePolicy = "unicode" // 유니코드 문자와 숫자, streamNamePunctuation (기본값)
+	StreamNameASCII   StreamNamePolicy = "ascii"   // ASCII 영숫자와 streamNamePunctuation
+	StreamNameAny     StreamNamePolicy = "any"     // 공통 규칙만 적용
+)
+
+// 정책에 관계없이 허용하는 구두점 (경로 구분자 "/"와 OBS 등이 붙이는 쿼리 문자열 포함)
+const streamNamePunctuation = "-_.~/?=&%+@"
+
+// 스트림 이름의 최대 바이트 길이
+const maxStreamNameLength = 256
+
+// ErrInvalidStreamName은 스트림 이름이 정책에 맞지 않는 경우의 에러
+var ErrInvalidStreamName = errors.New("invalid stream name")
+
+// validateStreamName은 스트림 이름을 정책에 따라 검사
+func validateStreamName(name string, policy StreamNamePolicy) error {
+	if name == "" || len(name) > maxStreamNameLength {
+		return fmt.Errorf("%w: length %d", ErrInvalidStreamName, len(name))
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("%w: not valid UTF-8", ErrInvalidStreamName)
+	}
+	for _, r := range name {
+		if !streamNameRuneAllowed(r, policy) {
+			return fmt.Errorf("%w: character %q", ErrInvalidStreamName, r)
+		}
+	}
+
+	// 쿼리 문자열 앞의 경로 부분은 파일 경로로 풀려도 한 단계 아래로만 내려가야 함
+	path, _, _ := strings.Cut(name, "?")
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: path segment %q", ErrInvalidStreamName, segment)
+		}
+	}
+	return nil
+}
+
+// streamNameRuneAllowed는 문자 하나가 정책에서 허용되는지 확인
+func streamNameRuneAllowed(r rune, policy StreamNamePolicy) bool {
+	if unicode.IsControl(r) || r == '\\' || r == utf8.RuneError {
+		return false
+	}
+	if strings.ContainsRune(streamNamePunctuation, r) {
+		return true
+	}
+	switch policy {
+	case StreamNameAny:
+		return true
+	case StreamNameASCII:
+		return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+	default:
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+	}
+}
+
+// checkStreamName은 명령어의 스트림 이름을 검사하고, 맞지 않으면 onStatus와 _error로 거부 (허용하면 true)
+func (s *session) checkStreamName(command string, transactionID float64, streamName, code string) bool {
+	err := validateStreamName(streamName, s.streamNamePolicy)
+	if err == nil {
+		return true
+	}
+	s.commandLogger().Warn(command+": rejected stream name", "streamName", fmt.Sprintf("%q", streamName), "err", err)
+	if err := s.sendStatus("error", code, "Invalid stream name", ""); err != nil {
+		s.commandLogger().Error(command+": failed to write onStatus", "err", err)
+	}
+	s.replyError(command, transactionID, code, "Invalid stream name")
+	return false
+}
@@ -0,0 +1,107 @@
+package rtmp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateStreamName(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  StreamNamePolicy
+		allowed bool
+	}{
+		{"test", StreamNameASCII, true},
+		{"room-1/cam_2.v~1", StreamNameASCII, true},
+		{"test?key=abc&t=1", StreamNameASCII, true},
+
+		// 경로 탈출
+		{"../../etc", StreamNameAny, false},
+		{"test/../../etc", StreamNameAny, false},
+		{"..", StreamNameAny, false},
+		{"./test", StreamNameAny, false},
+		{"/etc/passwd", StreamNameAny, false},
+		{"test/", StreamNameAny, false},
+		{"a//b", StreamNameAny, false},
+		{"..\\..\\etc", StreamNameAny, false},
+		{"test?next=../x", StreamNameAny, true}, // 쿼리 문자열은 경로가 아님
+
+		// 제어 문자
+		{"test\x00", StreamNameAny, false},
+		{"te\nst", StreamNameAny, false},
+		{"test\x1b[31m", StreamNameAny, false},
+		{"test\u0085", StreamNameAny, false},
+		{"test\xff", StreamNameAny, false},
+
+		// 유니코드
+		{"라이브/방송1", StreamNameUnicode, true},
+		{"café", StreamNameUnicode, true},
+		{"라이브", StreamNameASCII, false},
+		{"test stream", StreamNameUnicode, false},
+		{"test<script>", StreamNameUnicode, false},
+		{"test stream", StreamNameAny, true},
+		{"📺", StreamNameUnicode, false},
+		{"📺", StreamNameAny, true},
+
+		{"", StreamNameAny, false},
+		{strings.Repeat("a", maxStreamNameLength+1), StreamNameAny, false},
+	}
+	for _, tt := range tests {
+		err := validateStreamName(tt.name, tt.policy)
+		if tt.allowed && err != nil {
+			t.Errorf("%q (%s): expected allowed, got %v", tt.name, tt.policy, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrInvalidStreamName) {
+			t.Errorf("%q (%s): expected %v, got %v", tt.name, tt.policy, ErrInvalidStreamName, err)
+		}
+	}
+}
+
+func TestInvalidStreamNameRejected(t *testing.T) {
+	tests := []struct {
+		command string
+		name    string
+		code    string
+	}{
+		{"publish", "../../etc", "NetStream.Publish.BadName"},
+		{"publish", "test\r\nX-Injected: 1", "NetStream.Publish.BadName"},
+		{"play", "../../etc", "NetStream.Play.StreamNotFound"},
+		{"play", "test\x00", "NetStream.Play.StreamNotFound"},
+	}
+	for _, tt := range tests {
+		s, conn := newTestPlayer(1)
+		s.appName = "live"
+		events := make(chan interface{}, 10)
+		s.externalChannel = events
+
+		s.handleAMF0Command(newTestCommand(t, tt.command, 5.0, nil, tt.name))
+
+		if codes := readStatusCodes(t, conn); len(codes) != 1 || codes[0] != tt.code {
+			t.Errorf("%s %q: expected %s status, got %v", tt.command, tt.name, tt.code, codes)
+		}
+		if responses := readErrorResponses(t, conn); len(responses) != 1 || responses[0][1] != 5.0 {
+			t.Errorf("%s %q: expected _error for transaction 5, got %v", tt.command, tt.name, responses)
+		}
+		if len(events) != 0 || s.streamName != "" {
+			t.Errorf("%s %q: expected no stream to be started, got %d events, stream %q", tt.command, tt.name, len(events), s.streamName)
+		}
+	}
+
+	// 유니코드 이름은 기본 정책에서 허용, ascii 정책에서 거부
+	s, _ := newTestPlayer(1)
+	s.appName = "live"
+	s.externalChannel = make(chan interface{}, 10)
+	s.handleAMF0Command(newTestCommand(t, "publish", 5.0, nil, "라이브"))
+	if s.GetFullStreamPath() != "live/라이브" {
+		t.Errorf("expected unicode stream name to be accepted, got %q", s.GetFullStreamPath())
+	}
+
+	s, conn := newTestPlayer(1)
+	s.appName = "live"
+	s.streamNamePolicy = StreamNameASCII
+	s.handleAMF0Command(newTestCommand(t, "FCPublish", 4.0, nil, "라이브"))
+	if responses := readErrorResponses(t, conn); len(responses) != 1 || responses[0][1] != 4.0 {
+		t.Errorf("expected FCPublish _error under the ascii policy, got %v", responses)
+	}
+}
@@ -0,0 +1,117 @@
+package rtmp
+
+import (
+	"log/slog"
+	"sol/pkg/codec"
+)
+
+// 스트림 상태 알림 (StreamConfig.StatusEvents로 전달, 대시보드 등 외부 구독용)
+
+// StreamOnline은 스트림에 발행자가 붙어 방송이 시작됨을 알림
+type StreamOnline struct {
+	StreamName string
+	Players    int
+}
+
+// StreamOffline은 스트림의 발행자가 떠났음을 알림 (재연결 유예 중에도 발행자가 없으면 오프라인)
+type StreamOffline struct {
+	StreamName string
+	Players    int
+}
+
+// PlayerCountChanged는 스트림의 플레이어 수가 바뀌었음을 알림 (재생 준비를 기다리는 플레이어 포함)
+type PlayerCountChanged struct {
+	StreamName string
+	Players    int
+}
+
+// CodecMismatch는 onMetaData에 선언된 코덱과 실제 프레임의 코덱이 다름을 알림
+// 여러 렌디션을 보내는 발행자나 코덱을 바꾸고 메타데이터를 갱신하지 않은 발행자에서 발생 (불일치마다 한 번)
+type CodecMismatch struct {
+	StreamName string
+	Media      string      // "video" 또는 "audio"
+	Declared   codec.Codec // 메타데이터의 videocodecid/audiocodecid
+	Detected   codec.Codec // 프레임에서 감지한 코덱
+}
+
+// streamStatus는 마지막으로 알린 스트림 상태
+type streamStatus struct {
+	online  bool
+	players int
+}
+
+// notifyStatusChanges는 마지막으로 알린 상태와 현재 스트림을 비교해 바뀐 부분만 알림
+// 발행/재생 시작·종료, 세션 종료 등 스트림 구성이 바뀌는 이벤트 처리 후 호출
+// 채널이 가득 차 드롭된 알림은 알린 상태에 반영하지 않아 다음 호출에서 다시 보낸다
+func (s *Server) notifyStatusChanges() {
+	if s.streamConfig.StatusEvents == nil {
+		return
+	}
+
+	for streamName, stream := range s.streams {
+		current := streamStatus{online: stream.GetPublisher() != nil, players: stream.GetPlayerCount() + stream.GetPendingPlayerCount()}
+		notified := s.notifiedStatus[streamName]
+		if current == notified {
+			continue
+		}
+
+		if current.online && !notified.online && s.sendStatusEvent(StreamOnline{StreamName: streamName, Players: current.players}) {
+			notified.online = true
+		}
+		if current.players != notified.players && s.sendStatusEvent(PlayerCountChanged{StreamName: streamName, Players: current.players}) {
+			notified.players = current.players
+		}
+		if !current.online && notified.online && s.sendStatusEvent(StreamOffline{StreamName: streamName, Players: current.players}) {
+			notified.online = false
+		}
+		s.notifiedStatus[streamName] = notified
+	}
+
+	// 제거된 스트림은 플레이어 0명, 오프라인으로 알림
+	for streamName, notified := range s.notifiedStatus {
+		if _, exists := s.streams[streamName]; exists {
+			continue
+		}
+		if notified.players != 0 && s.sendStatusEvent(PlayerCountChanged{StreamName: streamName, Players: 0}) {
+			notified.players = 0
+		}
+		if notified.online && s.sendStatusEvent(StreamOffline{StreamName: streamName}) {
+			notified.online = false
+		}
+		if notified == (streamStatus{}) {
+			delete(s.notifiedStatus, streamName)
+		} else {
+			s.notifiedStatus[streamName] = notified
+		}
+	}
+}
+
+// reportCodecMismatch는 스트림의 코덱 불일치를 상태 알림으로 보낸다
+func (s *Server) reportCodecMismatch(mismatch CodecMismatch) {
+	s.codecMismatches.Add(1)
+	if s.streamConfig.StatusEvents != nil {
+		s.sendStatusEvent(mismatch)
+	}
+}
+
+// GetCodecMismatchCount는 감지된 메타데이터/프레임 코덱 불일치 수를 반환
+func (s *Server) GetCodecMismatchCount() uint64 {
+	return s.codecMismatches.Load()
+}
+
+// sendStatusEvent는 상태 알림을 보낸다 (이벤트 루프를 막지 않도록 채널이 가득 차면 드롭, 보냈으면 true)
+func (s *Server) sendStatusEvent(event interface{}) bool {
+	select {
+	case s.streamConfig.StatusEvents <- event:
+		return true
+	default:
+		s.droppedStatusEvents.Add(1)
+		slog.Warn("Status event channel full, dropping event", "event", event)
+		return false
+	}
+}
+
+// GetDroppedStatusEventCount는 채널이 가득 차 드롭된 상태 알림 수를 반환
+func (s *Server) GetDroppedStatusEventCount() uint64 {
+	return s.droppedStatusEvents.Load()
+}
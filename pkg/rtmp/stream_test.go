@@ -0,0 +1,374 @@
+package rtmp
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+var (
+	testAVCSequenceHeader = [][]byte{{0x17, 0x00, 0x00, 0x00, 0x00}}
+	testAVCKeyFrame       = [][]byte{{0x17, 0x01, 0x00, 0x00, 0x00}}
+	testAVCInterFrame     = [][]byte{{0x27, 0x01, 0x00, 0x00, 0x00}}
+	testAACSequenceHeader = [][]byte{{0xAF, 0x00, 0x12, 0x10}}
+	testAACFrame          = [][]byte{{0xAF, 0x01, 0x21}}
+)
+
+func TestIsPlayableNotReady(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	if stream.IsPlayable() {
+		t.Fatal("expected empty stream to be not playable")
+	}
+
+	// sequence header만 있고 키프레임 없음
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	if stream.IsPlayable() {
+		t.Fatal("expected stream without key frame to be not playable")
+	}
+
+	// 인터 프레임만으로는 부족
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: 40, Data: testAVCInterFrame})
+	if stream.IsPlayable() {
+		t.Fatal("expected stream with only inter frames to be not playable")
+	}
+
+	// AAC 오디오가 있는데 AAC sequence header가 없음
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: 80, Data: testAVCKeyFrame})
+	stream.ProcessAudioData(AudioData{Timestamp: 80, Data: testAACFrame})
+	if stream.IsPlayable() {
+		t.Fatal("expected stream with AAC audio but no AAC sequence header to be not playable")
+	}
+}
+
+func TestIsPlayableReady(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Data: testAVCKeyFrame})
+	if !stream.IsPlayable() {
+		t.Fatal("expected video-only stream with key frame to be playable")
+	}
+
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	stream.ProcessAudioData(AudioData{Timestamp: 20, Data: testAACFrame})
+	if !stream.IsPlayable() {
+		t.Fatal("expected stream with AAC sequence header to be playable")
+	}
+
+	stream.RemovePublisher()
+	if stream.IsPlayable() {
+		t.Fatal("expected stream to be not playable after caches are cleared")
+	}
+}
+
+func TestIsPlayableAudioOnly(t *testing.T) {
+	// 메타데이터 없이 오디오만 발행: AAC sequence header가 오면 재생 가능
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACFrame})
+	if stream.IsPlayable() {
+		t.Fatal("expected AAC audio without sequence header to be not playable")
+	}
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	if !stream.IsPlayable() {
+		t.Fatal("expected audio-only stream with AAC sequence header to be playable")
+	}
+
+	// AAC가 아닌 코덱은 첫 프레임부터 재생 가능
+	stream = NewStream("live/test", 10, 0)
+	stream.ProcessAudioData(AudioData{Timestamp: 0, Data: [][]byte{{0x2F, 0x01}}}) // MP3
+	if !stream.IsPlayable() {
+		t.Fatal("expected audio-only MP3 stream to be playable")
+	}
+
+	// 메타데이터에 비디오 코덱이 있으면 비디오를 기다림
+	stream = NewStream("live/test", 10, 0)
+	stream.ProcessMetaData(MetaData{Metadata: map[string]any{"videocodecid": 7.0, "audiocodecid": 10.0}})
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	if stream.IsPlayable() {
+		t.Fatal("expected stream announcing video to wait for it")
+	}
+}
+
+func TestWaitForPlayableAdmitsAudioOnlyPlayer(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetWaitForPlayable(true)
+	player, conn := newTestPlayer(1)
+
+	stream.ProcessMetaData(MetaData{Metadata: map[string]any{"audiocodecid": 10.0, "hasVideo": false}})
+	stream.AddPlayer(player)
+	if stream.GetPendingPlayerCount() != 1 {
+		t.Fatalf("expected player to wait for the audio sequence header, got pending=%d", stream.GetPendingPlayerCount())
+	}
+
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	if stream.GetPlayerCount() != 1 || stream.GetPendingPlayerCount() != 0 {
+		t.Fatalf("expected player to be admitted, got players=%d pending=%d", stream.GetPlayerCount(), stream.GetPendingPlayerCount())
+	}
+	audioCount := 0
+	for _, msg := range readAllMessages(t, conn.buf.Bytes()) {
+		if msg.messageHeader.typeId == MSG_TYPE_AUDIO {
+			audioCount++
+		}
+	}
+	if audioCount != 1 {
+		t.Fatalf("expected the cached AAC sequence header once, got %d audio messages", audioCount)
+	}
+}
+
+func TestWaitForPlayableDefersPlayer(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetWaitForPlayable(true)
+	player, conn := newTestPlayer(1)
+
+	stream.AddPlayer(player)
+	if stream.GetPlayerCount() != 0 || stream.GetPendingPlayerCount() != 1 {
+		t.Fatalf("expected player to be pending, got players=%d pending=%d", stream.GetPlayerCount(), stream.GetPendingPlayerCount())
+	}
+
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	if conn.buf.Len() != 0 {
+		t.Fatal("expected nothing to be sent before the stream is playable")
+	}
+
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: 40, Data: testAVCKeyFrame})
+	if stream.GetPlayerCount() != 1 || stream.GetPendingPlayerCount() != 0 {
+		t.Fatalf("expected player to be admitted, got players=%d pending=%d", stream.GetPlayerCount(), stream.GetPendingPlayerCount())
+	}
+
+	// 캐시된 sequence header와 키프레임을 한 번씩만 받아야 함
+	messages := readAllMessages(t, conn.buf.Bytes())
+	videoCount := 0
+	for _, msg := range messages {
+		if msg.messageHeader.typeId == MSG_TYPE_VIDEO {
+			videoCount++
+		}
+	}
+	if videoCount != 2 {
+		t.Fatalf("expected 2 video messages, got %d", videoCount)
+	}
+}
+
+func TestPendingPlayerRemoved(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.SetWaitForPlayable(true)
+	player, _ := newTestPlayer(1)
+
+	stream.AddPlayer(player)
+	if !stream.IsActive() {
+		t.Fatal("expected stream with pending player to be active")
+	}
+	stream.RemovePlayer(player)
+	if stream.GetPendingPlayerCount() != 0 || stream.IsActive() {
+		t.Fatal("expected pending player to be removed")
+	}
+}
+
+// feedTestVideo는 interval(ms) 간격으로 비디오 프레임을 넣고 gopInterval마다 키프레임을 넣음
+func feedTestVideo(stream *Stream, count int, interval, gopInterval uint32) {
+	for i := 0; i < count; i++ {
+		timestamp := uint32(i) * interval
+		data := testAVCInterFrame
+		if timestamp%gopInterval == 0 {
+			data = testAVCKeyFrame
+		}
+		stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: timestamp, Data: data})
+	}
+}
+
+func TestCacheEvictionFrames(t *testing.T) {
+	stream := NewStream("live/test", 5, 0)
+	stream.SetCacheEviction(CacheEvictionFrames, 0)
+
+	// 프레임 수 제한은 키프레임 이후의 inter frame에 적용
+	for i := 0; i < 30; i++ {
+		frameType := "inter frame"
+		if i%10 == 0 {
+			frameType = "key frame"
+		}
+		stream.ProcessVideoData(VideoData{FrameType: frameType, Timestamp: uint32(i) * 100, Data: testAVCInterFrame})
+	}
+
+	frames := stream.videoCache.gopFrames
+	if len(frames) != 5 {
+		t.Fatalf("expected cache capped at 5 frames, got %d", len(frames))
+	}
+	if frames[4].timestamp != 2900 {
+		t.Fatalf("expected latest frame at 2900, got %d", frames[4].timestamp)
+	}
+}
+
+func TestCacheEvictionDuration(t *testing.T) {
+	stream := NewStream("live/test", 5, 0)
+	stream.SetCacheEviction(CacheEvictionDuration, 1500*time.Millisecond)
+	feedTestVideo(stream, 30, 100, 500)
+
+	// 최신 2900 기준 1500ms 안의 가장 오래된 키프레임은 1500
+	frames := stream.videoCache.gopFrames
+	if len(frames) != 15 {
+		t.Fatalf("expected 15 cached frames, got %d", len(frames))
+	}
+	if !isVideoKeyFrame(frames[0].data) || frames[0].timestamp != 1500 {
+		t.Fatalf("expected cache to start at key frame 1500, got %d", frames[0].timestamp)
+	}
+}
+
+func TestCacheEvictionDurationKeepsLatestKeyFrame(t *testing.T) {
+	stream := NewStream("live/test", 5, 0)
+	stream.SetCacheEviction(CacheEvictionDuration, time.Second)
+
+	// GOP(4초)가 유지 시간보다 길어도 키프레임은 버리지 않음
+	feedTestVideo(stream, 30, 100, 4000)
+
+	frames := stream.videoCache.gopFrames
+	if len(frames) != 30 || frames[0].timestamp != 0 {
+		t.Fatalf("expected whole GOP from key frame 0 to be kept, got %d frames", len(frames))
+	}
+}
+
+func TestChangedSequenceHeaderRebroadcast(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: 40, Data: testAVCKeyFrame})
+	stream.ProcessAudioData(AudioData{Timestamp: 40, Data: testAACFrame})
+
+	first, firstConn := newTestPlayer(1)
+	second, secondConn := newTestPlayer(1)
+	stream.AddPlayer(first)
+	stream.AddPlayer(second)
+	firstConn.readMessages(t)
+	secondConn.readMessages(t)
+
+	// 해상도 변경 후 새 sequence header 수신
+	newAVCHeader := [][]byte{{0x17, 0x00, 0x00, 0x00, 0x00, 0x01, 0x64}}
+	newAACHeader := [][]byte{{0xAF, 0x00, 0x11, 0x90}}
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Timestamp: 80, Data: newAVCHeader})
+	stream.ProcessAudioData(AudioData{Timestamp: 80, Data: newAACHeader})
+
+	for i, conn := range []*bufferConn{firstConn, secondConn} {
+		messages := conn.readMessages(t)
+		if len(messages) != 2 {
+			t.Fatalf("player %d: expected 2 messages, got %d", i, len(messages))
+		}
+		if !bytes.Equal(concatChunks(messages[0].payload), newAVCHeader[0]) {
+			t.Fatalf("player %d: expected new AVC sequence header, got %x", i, concatChunks(messages[0].payload))
+		}
+		if !bytes.Equal(concatChunks(messages[1].payload), newAACHeader[0]) {
+			t.Fatalf("player %d: expected new AAC sequence header, got %x", i, concatChunks(messages[1].payload))
+		}
+	}
+
+	if !sameChunks(stream.videoCache.sequenceHeader.data, newAVCHeader) || !sameChunks(stream.audioCache.sequenceHeader.data, newAACHeader) {
+		t.Fatal("expected cached sequence headers to be replaced")
+	}
+	if len(stream.videoCache.gopFrames) != 0 || len(stream.audioCache.recentFrames) != 0 {
+		t.Fatal("expected frames encoded with the old configuration to be dropped")
+	}
+}
+
+func TestSameSequenceHeaderKeepsCache(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{FrameType: "AVC NALU", Timestamp: 40, Data: testAVCKeyFrame})
+
+	// 내용이 같으면 청크가 나뉘어 있어도 같은 설정
+	split := [][]byte{testAVCSequenceHeader[0][:2], testAVCSequenceHeader[0][2:]}
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Timestamp: 80, Data: split})
+
+	if len(stream.videoCache.gopFrames) != 1 {
+		t.Fatalf("expected GOP cache to be kept, got %d frames", len(stream.videoCache.gopFrames))
+	}
+}
+
+func TestCompositionTimeOffset(t *testing.T) {
+	publisher, _ := newTestPlayer(1)
+	publisher.appName = "live"
+	publisher.streamName = "test"
+	publisher.isPublishing = true
+	events := make(chan interface{}, 10)
+	publisher.externalChannel = events
+
+	// CTS는 AVCPacketType 뒤 3바이트 부호 있는 값 (청크 경계에 걸쳐도 읽음)
+	frames := []struct {
+		payload [][]byte
+		cts     int32
+	}{
+		{[][]byte{{0x17, 0x01, 0x00}, {0x00, 0x50, 0x65}}, 80},
+		{[][]byte{{0x27, 0x01, 0xFF, 0xFF, 0xD8, 0x41}}, -40},
+		{[][]byte{{0x27, 0x01, 0x00, 0x00, 0x00, 0x41}}, 0},
+		{testAVCSequenceHeader, 0},
+	}
+	stream := NewStream("live/test", 10, 0)
+	for i, frame := range frames {
+		publisher.handleVideo(NewMessage(newMessageHeader(uint32(1000+40*i), 0, MSG_TYPE_VIDEO, 1), frame.payload))
+		event, ok := (<-events).(VideoData)
+		if !ok {
+			t.Fatalf("frame %d: expected a VideoData event", i)
+		}
+		if event.CompositionTime != frame.cts || event.PTS() != event.Timestamp+uint32(frame.cts) {
+			t.Errorf("frame %d: expected composition time %d, got %d (PTS %d)", i, frame.cts, event.CompositionTime, event.PTS())
+		}
+		stream.ProcessVideoData(event)
+	}
+
+	if !stream.HasBFrames() {
+		t.Error("expected non-zero composition time to mark the stream as having B-frames")
+	}
+	cache := stream.GetGOPCache()
+	var offsets []int32
+	for _, frame := range cache {
+		if frame.frameType == "AVC NALU" {
+			offsets = append(offsets, frame.compositionTime)
+		}
+	}
+	if len(offsets) != 3 || offsets[0] != 80 || offsets[1] != -40 || offsets[2] != 0 {
+		t.Fatalf("expected cached composition times [80 -40 0], got %v", offsets)
+	}
+}
+
+func TestPlayersReceiveMediaInPublishOrder(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	live, liveConn := newTestPlayer(1)
+	stream.AddPlayer(live)
+
+	type delivered struct {
+		typeId    uint8
+		timestamp uint32
+	}
+	publish := func() {
+		stream.ProcessMetaData(MetaData{Metadata: map[string]any{"width": 1280.0}})
+		stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+		stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACSequenceHeader})
+		stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+		stream.ProcessAudioData(AudioData{Timestamp: 20, Data: testAACFrame})
+		stream.ProcessVideoData(VideoData{Timestamp: 40, FrameType: "inter frame", Data: testAVCInterFrame})
+		stream.ProcessAudioData(AudioData{Timestamp: 43, Data: testAACFrame})
+	}
+	publish()
+
+	// 메타데이터 -> sequence header -> 키프레임 -> 오디오/비디오를 발행한 순서대로
+	expected := []delivered{
+		{MSG_TYPE_AMF0_DATA, 0},
+		{MSG_TYPE_VIDEO, 0},
+		{MSG_TYPE_AUDIO, 0},
+		{MSG_TYPE_VIDEO, 0},
+		{MSG_TYPE_AUDIO, 20},
+		{MSG_TYPE_VIDEO, 40},
+		{MSG_TYPE_AUDIO, 43},
+	}
+	check := func(name string, conn *bufferConn) {
+		var got []delivered
+		for _, msg := range conn.readMessages(t) {
+			got = append(got, delivered{msg.messageHeader.typeId, msg.messageHeader.Timestamp})
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("%s: expected messages %v, got %v", name, expected, got)
+		}
+	}
+	check("live player", liveConn)
+
+	// 늦게 입장한 플레이어도 캐시를 비디오/오디오 순서를 섞어 발행 순서대로 받음
+	late, lateConn := newTestPlayer(1)
+	stream.AddPlayer(late)
+	check("late player", lateConn)
+}
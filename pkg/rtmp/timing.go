@@ -0,0 +1,127 @@
+package rtmp
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 처리 시간 히스토그램의 버킷 상한 (마지막 상한을 넘는 값은 초과 버킷에 집계)
+var timingBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// LatencyHistogram은 처리 시간 히스토그램의 스냅샷
+type LatencyHistogram struct {
+	Buckets []time.Duration // 버킷 상한
+	Counts  []uint64        // 버킷별 샘플 수 (len(Buckets)+1, 마지막은 상한 초과)
+	Count   uint64          // 전체 샘플 수
+	Sum     time.Duration   // 전체 처리 시간 합
+	Max     time.Duration   // 최대 처리 시간
+}
+
+// EventTimings는 서버 내부 처리 시간 히스토그램 (EventTiming 설정 시)
+type EventTimings struct {
+	EventHandling LatencyHistogram // 이벤트 루프에서 이벤트 하나를 처리한 시간
+	Broadcast     LatencyHistogram // 미디어 프레임 하나를 모든 플레이어에게 전송한 시간
+	Encode        LatencyHistogram // 메시지 하나를 RTMP 청크로 인코딩한 시간
+}
+
+// latencyHistogram은 처리 시간 샘플을 버킷별로 집계 (이벤트 루프와 송신 큐 goroutine에서 기록)
+type latencyHistogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]uint64, len(timingBuckets)+1)}
+}
+
+// observe는 start부터 지금까지의 처리 시간을 기록
+func (h *latencyHistogram) observe(start time.Time) {
+	elapsed := time.Since(start)
+	bucket := sort.Search(len(timingBuckets), func(i int) bool { return elapsed <= timingBuckets[i] })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[bucket]++
+	h.count++
+	h.sum += elapsed
+	if elapsed > h.max {
+		h.max = elapsed
+	}
+}
+
+// snapshot은 현재까지 집계된 히스토그램을 복사해서 반환
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return LatencyHistogram{
+		Buckets: append([]time.Duration(nil), timingBuckets...),
+		Counts:  append([]uint64(nil), h.counts...),
+		Count:   h.count,
+		Sum:     h.sum,
+		Max:     h.max,
+	}
+}
+
+// eventTimings는 서버 내부 처리 시간 히스토그램 모음 (nil이면 기록하지 않음)
+type eventTimings struct {
+	eventHandling *latencyHistogram
+	broadcast     *latencyHistogram
+	encode        *latencyHistogram
+}
+
+func newEventTimings() *eventTimings {
+	return &eventTimings{
+		eventHandling: newLatencyHistogram(),
+		broadcast:     newLatencyHistogram(),
+		encode:        newLatencyHistogram(),
+	}
+}
+
+// observeEventHandling은 이벤트 하나의 처리 시간을 기록
+func (t *eventTimings) observeEventHandling(start time.Time) {
+	if t != nil {
+		t.eventHandling.observe(start)
+	}
+}
+
+// observeBroadcast는 프레임 하나의 플레이어 전송 시간을 기록
+func (t *eventTimings) observeBroadcast(start time.Time) {
+	if t != nil {
+		t.broadcast.observe(start)
+	}
+}
+
+// observeEncode는 메시지 하나의 청크 인코딩 시간을 기록
+func (t *eventTimings) observeEncode(start time.Time) {
+	if t != nil {
+		t.encode.observe(start)
+	}
+}
+
+// GetEventTimings는 내부 처리 시간 히스토그램을 반환 (EventTiming이 꺼져 있으면 false)
+func (s *Server) GetEventTimings() (EventTimings, bool) {
+	if s.timings == nil {
+		return EventTimings{}, false
+	}
+	return EventTimings{
+		EventHandling: s.timings.eventHandling.snapshot(),
+		Broadcast:     s.timings.broadcast.snapshot(),
+		Encode:        s.timings.encode.snapshot(),
+	}, true
+}
@@ -0,0 +1,66 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventTimingsRecordSamples(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, EventTiming: true}, nil)
+	defer server.cancel()
+	go server.eventLoop()
+
+	// 이벤트 루프를 거친 이벤트의 처리 시간 기록
+	server.channel <- Terminated{Id: "unknown-session"}
+	deadline := time.Now().Add(time.Second)
+	for {
+		timings, _ := server.GetEventTimings()
+		if timings.EventHandling.Count > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected event handling time to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	server.cancel()
+
+	// 프레임 브로드캐스트와 플레이어 전송 시 청크 인코딩 시간 기록
+	stream, err := server.GetOrCreateStream("live/test", server.streamConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player, _ := newTestPlayer(1)
+	player.writer.timings = server.timings
+	stream.AddPlayer(player)
+	stream.ProcessVideoData(VideoData{FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessAudioData(AudioData{Data: testAACSequenceHeader})
+
+	timings, enabled := server.GetEventTimings()
+	if !enabled {
+		t.Fatal("expected event timings to be enabled")
+	}
+	if timings.Broadcast.Count != 2 {
+		t.Errorf("expected 2 broadcast samples, got %d", timings.Broadcast.Count)
+	}
+	if timings.Encode.Count < 2 {
+		t.Errorf("expected encode samples for sent frames, got %d", timings.Encode.Count)
+	}
+	var bucketTotal uint64
+	for _, count := range timings.Broadcast.Counts {
+		bucketTotal += count
+	}
+	if bucketTotal != timings.Broadcast.Count || len(timings.Broadcast.Counts) != len(timings.Broadcast.Buckets)+1 {
+		t.Errorf("expected bucket counts to add up to %d, got %v", timings.Broadcast.Count, timings.Broadcast.Counts)
+	}
+}
+
+func TestEventTimingsDisabledByDefault(t *testing.T) {
+	server := NewServer(0, StreamConfig{}, nil)
+	defer server.cancel()
+
+	server.channelHandler(Terminated{Id: "unknown-session"})
+	if _, enabled := server.GetEventTimings(); enabled {
+		t.Fatal("expected event timings to be disabled by default")
+	}
+}
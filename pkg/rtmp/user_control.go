@@ -0,0 +1,57 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"time"
+)
+
+// handleUserControl은 클라이언트의 User Control 메시지를 처리 (SetBufferLength 외의 이벤트는 무시)
+func (s *session) handleUserControl(message *Message) {
+	payload := make([]byte, 0, 10)
+	for _, chunk := range message.payload {
+		payload = append(payload, chunk...)
+	}
+	if len(payload) < 2 {
+		slog.Warn("Invalid User Control message length", "sessionId", s.sessionId, "length", len(payload))
+		return
+	}
+
+	eventType := binary.BigEndian.Uint16(payload)
+	switch eventType {
+	case USER_CONTROL_SET_BUFFER_LENGTH:
+		if len(payload) != 10 {
+			slog.Warn("Invalid SetBufferLength length", "sessionId", s.sessionId, "length", len(payload))
+			return
+		}
+		streamID := binary.BigEndian.Uint32(payload[2:6])
+		bufferLength := binary.BigEndian.Uint32(payload[6:10])
+		s.bufferLength.Store(int64(bufferLength))
+		slog.Debug("Player buffer length set", "sessionId", s.sessionId, "streamID", streamID, "bufferLengthMs", bufferLength)
+	default:
+		traceLog("user control event ignored", "sessionId", s.sessionId, "eventType", eventType)
+	}
+}
+
+// BufferLength는 플레이어가 SetBufferLength로 알린 버퍼 길이 (알리지 않았으면 0)
+func (s *session) BufferLength() time.Duration {
+	return time.Duration(s.bufferLength.Load()) * time.Millisecond
+}
+
+// burstWindow는 이 플레이어에게 캐시 버스트를 나눠 보낼 시간
+// 플레이어는 버퍼 길이만큼 받으면 재생을 시작하므로 그보다 오래 나눠 보내면 시작이 늦어진다
+func (s *session) burstWindow(configured time.Duration) time.Duration {
+	if buffer := s.BufferLength(); buffer > 0 && buffer < configured {
+		return buffer
+	}
+	return configured
+}
+
+// latencyBudget은 이 플레이어의 송신 큐 지연 예산 (configured가 0이면 비활성화 그대로)
+// 플레이어가 스스로 더 길게 버퍼링하면 그보다 짧게 유지하려고 프레임을 버려도 지연이 줄지 않으므로 버퍼 길이까지 늘린다
+func (s *session) latencyBudget(configured time.Duration) time.Duration {
+	if buffer := s.BufferLength(); configured > 0 && buffer > configured {
+		return buffer
+	}
+	return configured
+}
@@ -0,0 +1,73 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func newTestSetBufferLength(streamID, bufferLength uint32) *Message {
+	payload := make([]byte, 10)
+	binary.BigEndian.PutUint16(payload, USER_CONTROL_SET_BUFFER_LENGTH)
+	binary.BigEndian.PutUint32(payload[2:], streamID)
+	binary.BigEndian.PutUint32(payload[6:], bufferLength)
+	return NewMessage(newMessageHeader(0, 10, MSG_TYPE_USER_CONTROL, 0), [][]byte{payload})
+}
+
+func TestSetBufferLength(t *testing.T) {
+	player, _ := newTestPlayer(1)
+	if player.burstWindow(time.Second) != time.Second || player.latencyBudget(time.Second) != time.Second {
+		t.Fatal("expected configured values to be used before SetBufferLength")
+	}
+
+	player.handleMessage(newTestSetBufferLength(1, 100))
+	if player.BufferLength() != 100*time.Millisecond {
+		t.Fatalf("expected buffer length 100ms, got %v", player.BufferLength())
+	}
+
+	// 잘못된 길이의 메시지와 다른 이벤트는 버퍼 길이를 바꾸지 않음
+	player.handleMessage(NewMessage(newMessageHeader(0, 6, MSG_TYPE_USER_CONTROL, 0), [][]byte{{0, 3, 0, 0, 0, 1}}))
+	player.handleMessage(NewMessage(newMessageHeader(0, 6, MSG_TYPE_USER_CONTROL, 0), [][]byte{{0, 7, 0, 0, 0, 1}}))
+	if player.BufferLength() != 100*time.Millisecond {
+		t.Fatalf("expected buffer length to stay 100ms, got %v", player.BufferLength())
+	}
+
+	if window := player.burstWindow(time.Second); window != 100*time.Millisecond {
+		t.Errorf("expected burst window to shrink to the buffer length, got %v", window)
+	}
+	if window := player.burstWindow(50 * time.Millisecond); window != 50*time.Millisecond {
+		t.Errorf("expected a shorter burst window to be kept, got %v", window)
+	}
+	if budget := player.latencyBudget(50 * time.Millisecond); budget != 100*time.Millisecond {
+		t.Errorf("expected latency budget to grow to the buffer length, got %v", budget)
+	}
+	if budget := player.latencyBudget(0); budget != 0 {
+		t.Errorf("expected a disabled latency budget to stay disabled, got %v", budget)
+	}
+}
+
+func TestBufferLengthPacesCachedBurst(t *testing.T) {
+	stream := NewStream("live/test", 10, 0)
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "key frame", Data: testAVCKeyFrame})
+	for ts := uint32(100); ts < 400; ts += 100 {
+		stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "AVC NALU", Data: testAVCInterFrame})
+	}
+
+	player, _ := newTestPlayer(1)
+	player.conn = &timedConn{}
+	player.handleMessage(newTestSetBufferLength(1, 200))
+	player.burstPacing = player.burstWindow(time.Second)
+	player.enableLowLatency(0)
+	defer player.sendQueue.close()
+
+	stream.AddPlayer(player)
+
+	// GOP 4프레임을 1초가 아닌 버퍼 길이 200ms 동안 나눠 보냄
+	player.sendQueue.mu.Lock()
+	interval := player.sendQueue.burstInterval
+	player.sendQueue.mu.Unlock()
+	if interval != 50*time.Millisecond {
+		t.Fatalf("expected burst interval 50ms, got %v", interval)
+	}
+}
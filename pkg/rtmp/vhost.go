@@ -0,0 +1,32 @@
+package rtmp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// connectVhost는 connect 명령에서 가상 호스트 이름을 결정 (VirtualHosts 설정 시)
+// connect 명령 객체의 vhost 파라미터, tcUrl의 ?vhost= 쿼리, tcUrl 호스트 순으로 사용하고
+// 모두 없으면 빈 값 (기본 가상 호스트, 스트림 경로에 접두어를 붙이지 않음)
+func connectVhost(commandObj map[string]any, tcUrl string) string {
+	if vhost, ok := commandObj["vhost"].(string); ok && validVhost(vhost) {
+		return strings.ToLower(vhost)
+	}
+
+	u, err := url.Parse(tcUrl)
+	if err != nil {
+		return ""
+	}
+	if vhost := u.Query().Get("vhost"); validVhost(vhost) {
+		return strings.ToLower(vhost)
+	}
+	if host := u.Hostname(); validVhost(host) {
+		return strings.ToLower(host)
+	}
+	return ""
+}
+
+// validVhost는 스트림 경로의 한 구간으로 쓸 수 있는 이름인지 확인
+func validVhost(vhost string) bool {
+	return vhost != "" && !strings.ContainsAny(vhost, "/?")
+}
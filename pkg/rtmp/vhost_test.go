@@ -0,0 +1,76 @@
+package rtmp
+
+import "testing"
+
+func TestConnectVhost(t *testing.T) {
+	tests := []struct {
+		name       string
+		commandObj map[string]any
+		tcUrl      string
+		expected   string
+	}{
+		{"tcUrl host", nil, "rtmp://A.Example.com:1935/live", "a.example.com"},
+		{"tcUrl query", nil, "rtmp://10.0.0.1/live?vhost=b.example.com", "b.example.com"},
+		{"connect param", map[string]any{"vhost": "c.example.com"}, "rtmp://10.0.0.1/live?vhost=b.example.com", "c.example.com"},
+		{"invalid connect param", map[string]any{"vhost": "a/b"}, "rtmp://d.example.com/live", "d.example.com"},
+		{"no tcUrl", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := connectVhost(tt.commandObj, tt.tcUrl); got != tt.expected {
+			t.Errorf("%s: expected vhost %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestVirtualHostsIsolateStreams(t *testing.T) {
+	server := NewServer(0, StreamConfig{GopCacheSize: 10, VirtualHosts: true}, nil)
+	defer server.cancel()
+
+	// 서로 다른 가상 호스트에서 같은 live/test로 발행
+	publish := func(sessionId, tcUrl string) *session {
+		publisher, _ := newTestPlayer(0)
+		publisher.sessionId = sessionId
+		publisher.virtualHosts = true
+		events := make(chan interface{}, 10)
+		publisher.externalChannel = events
+		server.sessions[sessionId] = publisher
+
+		publisher.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live", "tcUrl": tcUrl}))
+		publisher.handleAMF0Command(newTestCommand(t, "createStream", 2.0, nil))
+		publisher.handleAMF0Command(newTestCommand(t, "publish", 3.0, nil, "test", "live"))
+		for len(events) > 0 {
+			server.channelHandler(<-events)
+		}
+		return publisher
+	}
+	first := publish("publisher-a", "rtmp://a.example.com/live")
+	second := publish("publisher-b", "rtmp://b.example.com/live")
+
+	if len(server.streams) != 2 {
+		t.Fatalf("expected 2 streams, got %d", len(server.streams))
+	}
+	for name, publisher := range map[string]*session{"a.example.com/live/test": first, "b.example.com/live/test": second} {
+		stream := server.streams[name]
+		if stream == nil || stream.GetPublisher() != publisher {
+			t.Fatalf("expected %s to be published by %s", name, publisher.sessionId)
+		}
+	}
+
+	// 한 가상 호스트의 미디어는 다른 가상 호스트의 스트림으로 가지 않음
+	server.handleVideoData(VideoData{SessionId: first.sessionId, StreamName: first.GetFullStreamPath(), FrameType: "key frame", Data: testAVCKeyFrame})
+	if frames := len(server.streams["a.example.com/live/test"].videoCache.gopFrames); frames != 1 {
+		t.Fatalf("expected 1 cached frame on the first vhost, got %d", frames)
+	}
+	if frames := len(server.streams["b.example.com/live/test"].videoCache.gopFrames); frames != 0 {
+		t.Fatalf("expected no cached frames on the second vhost, got %d", frames)
+	}
+}
+
+func TestVirtualHostsDisabledKeepsAppStreamPath(t *testing.T) {
+	s, _ := newTestPlayer(0)
+	s.handleAMF0Command(newTestCommand(t, "connect", 1.0, map[string]any{"app": "live", "tcUrl": "rtmp://a.example.com/live"}))
+	if path := s.streamPathFor("test"); path != "live/test" {
+		t.Fatalf("expected live/test without virtual hosts, got %q", path)
+	}
+}
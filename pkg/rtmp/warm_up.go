@@ -0,0 +1,53 @@
+package rtmp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// SetWarmUp은 플레이어 없이 발행 중인 스트림이 유지할 워밍업 버퍼 길이를 설정 (0이면 비활성화)
+// 예약 방송처럼 발행을 먼저 시작해 두면 첫 플레이어는 가득 찬 버퍼로 바로 재생을 시작한다
+func (s *Stream) SetWarmUp(window time.Duration) {
+	s.warmUp = window
+}
+
+// warming은 워밍업 버퍼를 유지하는 중인지 확인 (발행자가 있고 플레이어(대기 포함)가 없음)
+func (s *Stream) warming() bool {
+	return s.warmUp > 0 && s.publisher != nil && len(s.players) == 0 && len(s.pendingPlayers) == 0
+}
+
+// updateWarmUp은 프레임을 캐시하기 전에 호출해서 워밍업 상태를 갱신 (워밍업 중이면 true)
+// 플레이어가 입장해 워밍업이 끝나면 평소 캐시 크기로 돌아가도록 최근 키프레임 앞의 프레임을 버린다
+func (s *Stream) updateWarmUp(timestamp uint32) bool {
+	if s.warming() {
+		if !s.warmBuffer {
+			s.warmBuffer = true
+			slog.Info("Stream warming up without players", "streamName", s.name, "window", s.warmUp)
+		}
+		return true
+	}
+	if !s.warmBuffer {
+		return false
+	}
+
+	s.warmBuffer = false
+	if start := s.videoCache.seekKeyFrame(timestamp); start > 0 {
+		s.videoCache.dropFront(start)
+	}
+	if len(s.audioCache.recentFrames) > s.audioCache.maxFrames {
+		s.audioCache.recentFrames = s.audioCache.recentFrames[len(s.audioCache.recentFrames)-s.audioCache.maxFrames:]
+	}
+	slog.Info("Stream warm-up ended", "streamName", s.name, "cachedFrames", len(s.videoCache.gopFrames))
+	return false
+}
+
+// trimWarmAudio는 워밍업 중 latest 기준 워밍업 버퍼 길이보다 오래된 오디오 프레임을 버린다
+func (s *Stream) trimWarmAudio(latest uint32) {
+	budget := uint32(s.warmUp / time.Millisecond)
+	frames := s.audioCache.recentFrames
+	start := 0
+	for start < len(frames) && latest-frames[start].timestamp > budget {
+		start++
+	}
+	s.audioCache.recentFrames = frames[start:]
+}
@@ -0,0 +1,76 @@
+package rtmp
+
+import (
+	"testing"
+	"time"
+)
+
+// publishWarmUpFrames는 1초 GOP(키프레임 + 500ms 뒤 inter frame) 5개와 250ms 간격 오디오를 발행
+func publishWarmUpFrames(stream *Stream) {
+	stream.ProcessVideoData(VideoData{Timestamp: 0, FrameType: "AVC sequence header", Data: testAVCSequenceHeader})
+	stream.ProcessAudioData(AudioData{Timestamp: 0, Data: testAACSequenceHeader})
+	for ts := uint32(0); ts < 5000; ts += 250 {
+		switch ts % 1000 {
+		case 0:
+			stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "key frame", Data: testAVCKeyFrame})
+		case 500:
+			stream.ProcessVideoData(VideoData{Timestamp: ts, FrameType: "inter frame", Data: testAVCInterFrame})
+		}
+		stream.ProcessAudioData(AudioData{Timestamp: ts, Data: testAACFrame})
+	}
+}
+
+func TestWarmUpServesFirstPlayerFromWarmBuffer(t *testing.T) {
+	publisher, _ := newTestPlayer(1)
+
+	// 워밍업 없이는 마지막 GOP만 캐시
+	cold := NewStream("live/cold", 10, 0)
+	cold.SetPublisher(publisher)
+	publishWarmUpFrames(cold)
+	if len(cold.videoCache.gopFrames) != 2 {
+		t.Fatalf("expected 2 cached frames without warm-up, got %d", len(cold.videoCache.gopFrames))
+	}
+
+	stream := NewStream("live/test", 10, 0)
+	stream.SetWarmUp(3 * time.Second)
+	stream.SetPublisher(publisher)
+	publishWarmUpFrames(stream)
+
+	if !stream.IsActive() || !stream.warming() {
+		t.Fatal("expected publisher-only stream to stay active and warming")
+	}
+	if stream.compressIdleCache(time.Now().Add(time.Hour), time.Second, 0) {
+		t.Fatal("expected warm buffer not to be compressed")
+	}
+	// 4750 기준 3초 안의 가장 오래된 키프레임(2000)부터 유지
+	if len(stream.videoCache.gopFrames) != 6 || stream.videoCache.gopFrames[0].timestamp != 2000 {
+		t.Fatalf("expected 6 frames from keyframe 2000, got %d", len(stream.videoCache.gopFrames))
+	}
+	if len(stream.audioCache.recentFrames) != 13 {
+		t.Fatalf("expected 3s of audio (13 frames), got %d", len(stream.audioCache.recentFrames))
+	}
+
+	player, conn := newTestPlayer(1)
+	stream.AddPlayer(player)
+	var audio, video int
+	for _, msg := range conn.readMessages(t) {
+		switch msg.messageHeader.typeId {
+		case MSG_TYPE_AUDIO:
+			audio++
+		case MSG_TYPE_VIDEO:
+			video++
+		}
+	}
+	if video != 7 || audio != 14 {
+		t.Fatalf("expected warm buffer (7 video, 14 audio messages with sequence headers), got %d video and %d audio", video, audio)
+	}
+
+	// 플레이어가 입장하면 다음 프레임에서 평소 캐시 크기로 돌아감
+	stream.ProcessVideoData(VideoData{Timestamp: 4750, FrameType: "inter frame", Data: testAVCInterFrame})
+	if stream.warming() || len(stream.videoCache.gopFrames) != 3 || stream.videoCache.gopFrames[0].timestamp != 4000 {
+		t.Fatalf("expected cache trimmed to the last GOP after warm-up, got %d frames", len(stream.videoCache.gopFrames))
+	}
+	if len(stream.audioCache.recentFrames) > stream.audioCache.maxFrames {
+		t.Fatalf("expected at most %d cached audio frames after warm-up, got %d", stream.audioCache.maxFrames, len(stream.audioCache.recentFrames))
+	}
+}
@@ -0,0 +1,134 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sol/pkg/codec"
+)
+
+// AAC RTP payload constants (RFC 3640, mode=AAC-hbr)
+const (
+	aacAUHeadersLengthSize = 2  // AU-headers-length field (in bits)
+	aacAUHeaderSize        = 2  // sizelength=13, indexlength=3
+	aacAUHeaderBits        = 16 // bits of a single AU header
+)
+
+// aacMaxAUSize is the largest access unit the 13-bit AU-size field can describe
+const aacMaxAUSize = 1<<aacSizeLength - 1
+
+// ErrAUTooLarge is returned for an AAC access unit whose size does not fit the AU header
+var ErrAUTooLarge = errors.New("AAC access unit exceeds the 13-bit AU-size field")
+
+// AACPacketizer splits AAC access units into RTP payloads that fit the MTU
+type AACPacketizer struct {
+	mtu        int                 // maximum RTP packet size including the RTP header
+	timestamps *TimestampGenerator // stamps locally built frames (nil when only relaying)
+}
+
+// NewAACPacketizer creates a new AAC packetizer for the given MTU
+func NewAACPacketizer(mtu int) *AACPacketizer {
+	return &AACPacketizer{
+		mtu: mtu,
+	}
+}
+
+// Codec returns the codec this packetizer produces payloads for
+func (p *AACPacketizer) Codec() codec.Codec {
+	return codec.AAC
+}
+
+// maxPayloadSize returns the AU data budget left after the RTP header and AU header section
+func (p *AACPacketizer) maxPayloadSize() int {
+	return p.mtu - MinRTPHeaderSize - aacAUHeadersLengthSize - aacAUHeaderSize
+}
+
+// Packetize converts a single AAC access unit (raw, without ADTS) into RTP payloads.
+// An access unit larger than the MTU is fragmented; every fragment carries an AU header
+// with the full AU size as required by RFC 3640. The marker bit belongs on the last fragment.
+// An access unit larger than the AU-size field can describe is rejected with ErrAUTooLarge.
+func (p *AACPacketizer) Packetize(au []byte) ([][]byte, error) {
+	if len(au) == 0 {
+		return nil, nil
+	}
+	if len(au) > aacMaxAUSize {
+		return nil, fmt.Errorf("%w: %d bytes (max: %d)", ErrAUTooLarge, len(au), aacMaxAUSize)
+	}
+	return p.fragment(au, len(au)), nil
+}
+
+// SetTimestampGenerator sets the generator that stamps frames passed to PacketizeFrame
+// (an audio generator counting samples at the sample-rate clock)
+func (p *AACPacketizer) SetTimestampGenerator(g *TimestampGenerator) {
+	p.timestamps = g
+}
+
+// PacketizeFrame packetizes one locally built AAC access unit and stamps every
+// payload with the generator's timestamp, advancing it by one frame of samples
+func (p *AACPacketizer) PacketizeFrame(au []byte) ([]RelayPayload, error) {
+	if p.timestamps == nil {
+		return nil, ErrNoTimestampGenerator
+	}
+	payloads, err := p.Packetize(au)
+	if err != nil {
+		return nil, err
+	}
+	return relayPayloads(payloads, p.timestamps.Next(aacSamplesPerFrame), true), nil
+}
+
+// Repacketize fits a relayed mode=AAC-hbr payload to the MTU: the access units
+// of a multi-AU packet are sent in packets of their own, timestamped from their
+// AU-index-delta, and a fragment of an access unit is split into smaller
+// fragments that keep the AU header with the full access unit size.
+func (p *AACPacketizer) Repacketize(payload []byte, timestamp uint32, marker bool) ([]RelayPayload, error) {
+	if len(payload) <= p.mtu-MinRTPHeaderSize {
+		return []RelayPayload{{Payload: payload, Timestamp: timestamp, Marker: marker}}, nil
+	}
+
+	hbr := AACDepacketizer{sizeLength: aacSizeLength, indexLength: aacIndexLength, indexDeltaLength: aacIndexLength}
+	headers, data, err := hbr.parseAUHeaders(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single access unit larger than the payload is a fragment (RFC 3640 3.2.3)
+	if len(headers) == 1 && headers[0].size > len(data) {
+		return relayPayloads(p.fragment(data, headers[0].size), timestamp, marker), nil
+	}
+
+	var relayed []RelayPayload
+	index := 0
+	for i, header := range headers {
+		if header.size > len(data) {
+			return nil, fmt.Errorf("%w: access unit %d of %d bytes with %d left", ErrInvalidAACPayload, i, header.size, len(data))
+		}
+		if i > 0 {
+			index += header.index + 1
+		}
+		// Complete access units carry the marker bit (RFC 3640 3.2.3)
+		auTimestamp := timestamp + uint32(index*aacSamplesPerFrame)
+		payloads, err := p.Packetize(data[:header.size])
+		if err != nil {
+			return nil, err
+		}
+		relayed = append(relayed, relayPayloads(payloads, auTimestamp, true)...)
+		data = data[header.size:]
+	}
+	return relayed, nil
+}
+
+// fragment splits part of an access unit of auSize bytes into payloads that fit the MTU
+func (p *AACPacketizer) fragment(data []byte, auSize int) [][]byte {
+	fragmentSize := p.maxPayloadSize()
+	payloads := make([][]byte, 0, (len(data)+fragmentSize-1)/fragmentSize)
+	for offset := 0; offset < len(data); offset += fragmentSize {
+		end := min(offset+fragmentSize, len(data))
+
+		payload := make([]byte, aacAUHeadersLengthSize+aacAUHeaderSize, aacAUHeadersLengthSize+aacAUHeaderSize+end-offset)
+		binary.BigEndian.PutUint16(payload[0:2], aacAUHeaderBits)
+		binary.BigEndian.PutUint16(payload[2:4], uint16(auSize)<<3) // AU-size(13) + AU-index(3)=0
+		payload = append(payload, data[offset:end]...)
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
@@ -0,0 +1,250 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	aacSamplesPerFrame   = 1024  // samples in one AAC-LC access unit, the RTP timestamp step between them
+	aacDefaultSampleRate = 44100 // FLV's AAC sample rate, used when the clock rate is unknown
+	aacSizeLength        = 13    // AAC-hbr AU-size bits
+	aacIndexLength       = 3     // AAC-hbr AU-index and AU-index-delta bits
+)
+
+// ADTS framing constants (ISO/IEC 13818-7)
+const (
+	adtsHeaderSize       = 7         // header without CRC
+	adtsMaxFrameSize     = 1<<13 - 1 // 13-bit frame length, header included
+	adtsMaxObjectType    = 4         // the 2-bit profile field holds object types 1-4 (Main, LC, SSR, LTP)
+	aacExplicitFreqIndex = 15        // sampling frequency index followed by an explicit rate, which ADTS cannot carry
+)
+
+// ErrInvalidAACPayload is returned for a truncated or malformed MPEG4-GENERIC RTP payload
+var ErrInvalidAACPayload = errors.New("invalid AAC RTP payload")
+
+// ErrUnsupportedAACConfig is returned when an AudioSpecificConfig cannot be expressed in an ADTS header
+var ErrUnsupportedAACConfig = errors.New("unsupported AAC AudioSpecificConfig")
+
+// AACAccessUnit is one raw AAC frame (without ADTS) extracted from RTP packets
+type AACAccessUnit struct {
+	Data      []byte
+	Timestamp uint32        // RTP timestamp of the access unit (sample clock)
+	Time      time.Duration // presentation time relative to the first access unit, from the clock rate
+}
+
+// ADTS returns the access unit with an ADTS header built from the stream's
+// AudioSpecificConfig (the SDP config parameter or the FLV sequence header)
+func (au *AACAccessUnit) ADTS(audioSpecificConfig []byte) ([]byte, error) {
+	if len(audioSpecificConfig) < 2 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrUnsupportedAACConfig, len(audioSpecificConfig))
+	}
+	objectType := audioSpecificConfig[0] >> 3
+	freqIndex := (audioSpecificConfig[0]&0x07)<<1 | audioSpecificConfig[1]>>7
+	channels := (audioSpecificConfig[1] >> 3) & 0x0F
+	if objectType == 0 || objectType > adtsMaxObjectType || freqIndex >= aacExplicitFreqIndex {
+		return nil, fmt.Errorf("%w: object type %d, frequency index %d", ErrUnsupportedAACConfig, objectType, freqIndex)
+	}
+
+	frameSize := adtsHeaderSize + len(au.Data)
+	if frameSize > adtsMaxFrameSize {
+		return nil, fmt.Errorf("%w: %d byte frame does not fit ADTS", ErrInvalidAACPayload, frameSize)
+	}
+	frame := make([]byte, adtsHeaderSize, frameSize)
+	frame[0] = 0xFF
+	frame[1] = 0xF1 // syncword, MPEG-4, layer 0, no CRC
+	frame[2] = (objectType-1)<<6 | freqIndex<<2 | channels>>2
+	frame[3] = (channels&0x03)<<6 | byte(frameSize>>11)
+	frame[4] = byte(frameSize >> 3)
+	frame[5] = byte(frameSize&0x07)<<5 | 0x1F // buffer fullness 0x7FF (VBR)
+	frame[6] = 0xFC
+	return append(frame, au.Data...), nil
+}
+
+// AACDepacketizer reverses AACPacketizer: it parses the AU header section of
+// MPEG4-GENERIC payloads (RFC 3640) and extracts the access units, whether a
+// packet carries one, several, or a fragment of one. Packets must arrive in
+// sequence order; late or duplicate packets are ignored, and after a loss
+// fragments are dropped up to the next marker, as the access unit they
+// belong to may be incomplete.
+type AACDepacketizer struct {
+	sizeLength       int // AU-size bits in each AU header
+	indexLength      int // AU-index bits in the first AU header
+	indexDeltaLength int // AU-index-delta bits in the following AU headers
+
+	fragment     []byte // access unit being reassembled (nil if none)
+	fragmentSize int    // full size of the access unit being reassembled
+	timestamp    uint32 // RTP timestamp of the access unit being reassembled
+	resync       bool   // packets were lost; skip fragments until a marker
+
+	sequence sequenceTracker
+	clock    mediaClock
+}
+
+// NewAACDepacketizer creates a depacketizer for mode=AAC-hbr payloads
+// (sizelength=13, indexlength=3, indexdeltalength=3, as AACPacketizer sends).
+// clockRate is the sample rate from the rtpmap (0 = 44100Hz).
+func NewAACDepacketizer(clockRate uint32) *AACDepacketizer {
+	return NewAACDepacketizerWithHeader(clockRate, aacSizeLength, aacIndexLength, aacIndexLength)
+}
+
+// NewAACDepacketizerWithHeader creates a depacketizer for the AU header layout
+// given by the sizelength, indexlength and indexdeltalength fmtp parameters
+func NewAACDepacketizerWithHeader(clockRate uint32, sizeLength, indexLength, indexDeltaLength int) *AACDepacketizer {
+	if clockRate == 0 {
+		clockRate = aacDefaultSampleRate
+	}
+	return &AACDepacketizer{
+		sizeLength:       sizeLength,
+		indexLength:      indexLength,
+		indexDeltaLength: indexDeltaLength,
+		clock:            newMediaClock(clockRate),
+	}
+}
+
+// aacAUHeader is one parsed AU header
+type aacAUHeader struct {
+	size  int
+	index int // AU-index of the first header, AU-index-delta of the others
+}
+
+// Depacketize adds a received packet and returns the access units it
+// completes, none while a fragmented access unit needs more packets
+func (d *AACDepacketizer) Depacketize(packet *RTPPacket) ([]*AACAccessUnit, error) {
+	seq := packet.Header.SequenceNumber
+	ok, lost := d.sequence.next(seq)
+	if !ok {
+		slog.Debug("Late or duplicate AAC RTP packet ignored", "seq", seq)
+		return nil, nil
+	}
+	if lost {
+		// The lost packets may have started a fragmented access unit, so a
+		// fragment cannot be trusted as a start until the next marker
+		if d.fragment != nil {
+			slog.Debug("AAC RTP packet loss, dropping fragmented access unit", "seq", seq, "timestamp", d.timestamp)
+			d.fragment = nil
+		}
+		d.resync = true
+	}
+
+	headers, data, err := d.parseAUHeaders(packet.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single access unit larger than the payload is a fragment (RFC 3640 3.2.3)
+	if len(headers) == 1 && headers[0].size > len(data) {
+		return d.addFragment(packet, headers[0].size, data)
+	}
+	if d.fragment != nil {
+		slog.Debug("AAC fragmented access unit interrupted", "timestamp", d.timestamp)
+		d.fragment = nil
+	}
+	d.resync = false
+
+	accessUnits := make([]*AACAccessUnit, 0, len(headers))
+	index := 0
+	for i, header := range headers {
+		if header.size > len(data) {
+			return accessUnits, fmt.Errorf("%w: access unit %d of %d bytes with %d left", ErrInvalidAACPayload, i, header.size, len(data))
+		}
+		if i > 0 {
+			index += header.index + 1
+		}
+		timestamp := packet.Header.Timestamp + uint32(index*aacSamplesPerFrame)
+		accessUnits = append(accessUnits, &AACAccessUnit{
+			Data:      append([]byte(nil), data[:header.size]...),
+			Timestamp: timestamp,
+			Time:      d.clock.at(timestamp),
+		})
+		data = data[header.size:]
+	}
+	return accessUnits, nil
+}
+
+// addFragment appends one fragment of an access unit and returns the access unit once it is complete
+func (d *AACDepacketizer) addFragment(packet *RTPPacket, size int, data []byte) ([]*AACAccessUnit, error) {
+	if d.fragment != nil && (packet.Header.Timestamp != d.timestamp || size != d.fragmentSize) {
+		slog.Debug("AAC fragmented access unit interrupted", "timestamp", d.timestamp)
+		d.fragment = nil
+	}
+	if d.fragment == nil {
+		if d.resync {
+			if packet.Header.Marker {
+				d.resync = false
+			}
+			return nil, nil
+		}
+		d.fragment = make([]byte, 0, size)
+		d.fragmentSize = size
+		d.timestamp = packet.Header.Timestamp
+	}
+
+	d.fragment = append(d.fragment, data...)
+	if len(d.fragment) > d.fragmentSize {
+		d.fragment = nil
+		return nil, fmt.Errorf("%w: fragments exceed the %d byte access unit", ErrInvalidAACPayload, d.fragmentSize)
+	}
+	if len(d.fragment) < d.fragmentSize {
+		if packet.Header.Marker {
+			short := d.fragmentSize - len(d.fragment)
+			d.fragment = nil
+			return nil, fmt.Errorf("%w: last fragment leaves the access unit %d bytes short", ErrInvalidAACPayload, short)
+		}
+		return nil, nil
+	}
+
+	au := &AACAccessUnit{
+		Data:      d.fragment,
+		Timestamp: d.timestamp,
+		Time:      d.clock.at(d.timestamp),
+	}
+	d.fragment = nil
+	return []*AACAccessUnit{au}, nil
+}
+
+// parseAUHeaders splits a payload into its AU headers and the access unit data after them
+func (d *AACDepacketizer) parseAUHeaders(payload []byte) ([]aacAUHeader, []byte, error) {
+	if len(payload) < aacAUHeadersLengthSize {
+		return nil, nil, fmt.Errorf("%w: missing AU-headers-length", ErrInvalidAACPayload)
+	}
+	headersBits := int(binary.BigEndian.Uint16(payload))
+	headersBytes := (headersBits + 7) / 8
+	if len(payload) < aacAUHeadersLengthSize+headersBytes {
+		return nil, nil, fmt.Errorf("%w: %d bits of AU headers in a %d byte payload", ErrInvalidAACPayload, headersBits, len(payload))
+	}
+	section := payload[aacAUHeadersLengthSize : aacAUHeadersLengthSize+headersBytes]
+
+	var headers []aacAUHeader
+	for offset := 0; offset < headersBits; {
+		indexBits := d.indexDeltaLength
+		if len(headers) == 0 {
+			indexBits = d.indexLength
+		}
+		if offset+d.sizeLength+indexBits > headersBits {
+			return nil, nil, fmt.Errorf("%w: truncated AU header at bit %d of %d", ErrInvalidAACPayload, offset, headersBits)
+		}
+		header := aacAUHeader{
+			size:  readBits(section, offset, d.sizeLength),
+			index: readBits(section, offset+d.sizeLength, indexBits),
+		}
+		headers = append(headers, header)
+		offset += d.sizeLength + indexBits
+	}
+	if len(headers) == 0 {
+		return nil, nil, fmt.Errorf("%w: no AU headers", ErrInvalidAACPayload)
+	}
+	return headers, payload[aacAUHeadersLengthSize+headersBytes:], nil
+}
+
+// readBits reads an n-bit big-endian value starting at bit offset of data
+func readBits(data []byte, offset, n int) int {
+	value := 0
+	for i := offset; i < offset+n; i++ {
+		value = value<<1 | int(data[i/8]>>(7-i%8)&1)
+	}
+	return value
+}
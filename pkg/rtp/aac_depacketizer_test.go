@@ -0,0 +1,211 @@
+package rtp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testAAC returns an access unit of the given size with a recognizable body
+func testAAC(size int, seed byte) []byte {
+	au := make([]byte, size)
+	for i := range au {
+		au[i] = seed + byte(i*3)
+	}
+	return au
+}
+
+func depacketizeAAC(t *testing.T, d *AACDepacketizer, packets []*RTPPacket) []*AACAccessUnit {
+	t.Helper()
+	var accessUnits []*AACAccessUnit
+	for _, packet := range packets {
+		completed, err := d.Depacketize(packet)
+		if err != nil {
+			t.Fatalf("Failed to depacketize packet %d: %v", packet.Header.SequenceNumber, err)
+		}
+		accessUnits = append(accessUnits, completed...)
+	}
+	return accessUnits
+}
+
+func TestAACDepacketizerSingleAccessUnit(t *testing.T) {
+	frames := [][]byte{testAAC(300, 1), testAAC(280, 2), testAAC(310, 3)}
+	packetizer := NewAACPacketizer(1200)
+	var packets []*RTPPacket
+	for i, frame := range frames {
+		payloads, err := packetizer.Packetize(frame)
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		if len(payloads) != 1 {
+			t.Fatalf("Expected one packet per access unit, got %d", len(payloads))
+		}
+		packet := NewRTPPacket(PayloadTypeAAC, uint16(65535+i), uint32(4294966272+i*aacSamplesPerFrame), 0x5678, payloads[0])
+		packet.SetMarker(true)
+		packets = append(packets, packet)
+	}
+
+	got := depacketizeAAC(t, NewAACDepacketizer(48000), packets)
+	if len(got) != len(frames) {
+		t.Fatalf("Expected %d access units, got %d", len(frames), len(got))
+	}
+	for i, au := range got {
+		if !bytes.Equal(au.Data, frames[i]) {
+			t.Errorf("Access unit %d: data differs from the original", i)
+		}
+		if expected := time.Duration(i) * aacSamplesPerFrame * time.Second / 48000; au.Time != expected {
+			t.Errorf("Access unit %d: expected time %v, got %v", i, expected, au.Time)
+		}
+	}
+	if got[1].Timestamp != 0 {
+		t.Errorf("Expected the timestamp to wrap to 0, got %d", got[1].Timestamp)
+	}
+
+	// Duplicates are ignored
+	d := NewAACDepacketizer(48000)
+	depacketizeAAC(t, d, packets)
+	if completed, err := d.Depacketize(packets[1]); err != nil || completed != nil {
+		t.Fatalf("Expected duplicate packet to be ignored, got %+v, %v", completed, err)
+	}
+}
+
+func TestAACDepacketizerMultipleAccessUnits(t *testing.T) {
+	// Three AU headers (size 13 bits + index/index-delta 3 bits) and the concatenated access units
+	frames := [][]byte{testAAC(10, 1), testAAC(200, 2), testAAC(7, 3)}
+	payload := []byte{0x00, 3 * aacAUHeaderBits}
+	for _, frame := range frames {
+		payload = append(payload, byte(len(frame)>>5), byte(len(frame)<<3))
+	}
+	for _, frame := range frames {
+		payload = append(payload, frame...)
+	}
+	packet := NewRTPPacket(PayloadTypeAAC, 1, 9000, 0x5678, payload)
+	packet.SetMarker(true)
+
+	got, err := NewAACDepacketizer(44100).Depacketize(packet)
+	if err != nil || len(got) != len(frames) {
+		t.Fatalf("Expected %d access units, got %d, %v", len(frames), len(got), err)
+	}
+	for i, au := range got {
+		if !bytes.Equal(au.Data, frames[i]) {
+			t.Errorf("Access unit %d: data differs from the original", i)
+		}
+		if expected := uint32(9000 + i*aacSamplesPerFrame); au.Timestamp != expected {
+			t.Errorf("Access unit %d: expected timestamp %d, got %d", i, expected, au.Timestamp)
+		}
+	}
+
+	// AU-index-delta 1 skips an access unit (interleaving)
+	payload[5] |= 0x01
+	got, err = NewAACDepacketizer(44100).Depacketize(NewRTPPacket(PayloadTypeAAC, 1, 9000, 0x5678, payload))
+	if err != nil || len(got) != 3 || got[1].Timestamp != 9000+2*aacSamplesPerFrame || got[2].Timestamp != 9000+3*aacSamplesPerFrame {
+		t.Fatalf("Expected the index delta to shift the timestamps, got %+v, %v", got, err)
+	}
+
+	// Other header layouts from the fmtp: sizelength=6, indexlength=indexdeltalength=2
+	payload = []byte{0x00, 16, byte(len(frames[0]) << 2), byte(len(frames[2]) << 2)}
+	payload = append(append(payload, frames[0]...), frames[2]...)
+	got, err = NewAACDepacketizerWithHeader(16000, 6, 2, 2).Depacketize(NewRTPPacket(PayloadTypeAAC, 1, 0, 0x5678, payload))
+	if err != nil || len(got) != 2 || !bytes.Equal(got[0].Data, frames[0]) || !bytes.Equal(got[1].Data, frames[2]) {
+		t.Fatalf("Expected two access units with 8-bit AU headers, got %+v, %v", got, err)
+	}
+}
+
+func TestAACDepacketizerFragmentedAccessUnit(t *testing.T) {
+	large, small := testAAC(2500, 7), testAAC(200, 9)
+	packetizer := NewAACPacketizer(1000)
+	var packets []*RTPPacket
+	seq := uint16(100)
+	for i, frame := range [][]byte{large, small, large} {
+		payloads, err := packetizer.Packetize(frame)
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		for j, payload := range payloads {
+			packet := NewRTPPacket(PayloadTypeAAC, seq, uint32(i*aacSamplesPerFrame), 0x5678, payload)
+			packet.SetMarker(j == len(payloads)-1)
+			packets = append(packets, packet)
+			seq++
+		}
+	}
+	if len(packets) != 7 {
+		t.Fatalf("Expected 3 fragments per large access unit, got %d packets", len(packets))
+	}
+
+	got := depacketizeAAC(t, NewAACDepacketizer(0), packets)
+	if len(got) != 3 || !bytes.Equal(got[0].Data, large) || !bytes.Equal(got[1].Data, small) || !bytes.Equal(got[2].Data, large) {
+		t.Fatalf("Expected the reassembled access units, got %d", len(got))
+	}
+	if got[2].Timestamp != 2*aacSamplesPerFrame {
+		t.Errorf("Expected the reassembled access unit to keep its timestamp, got %d", got[2].Timestamp)
+	}
+
+	// Losing a middle or a first fragment drops that access unit only
+	for _, tt := range []struct {
+		lost       int
+		timestamps []uint32
+	}{
+		{1, []uint32{aacSamplesPerFrame, 2 * aacSamplesPerFrame}},
+		{4, []uint32{0, aacSamplesPerFrame}},
+	} {
+		received := append(append([]*RTPPacket{}, packets[:tt.lost]...), packets[tt.lost+1:]...)
+		got = depacketizeAAC(t, NewAACDepacketizer(0), received)
+		if len(got) != len(tt.timestamps) {
+			t.Fatalf("Lost packet %d: expected %d access units, got %d", tt.lost, len(tt.timestamps), len(got))
+		}
+		for i, au := range got {
+			expected := large
+			if au.Timestamp == aacSamplesPerFrame {
+				expected = small
+			}
+			if au.Timestamp != tt.timestamps[i] || !bytes.Equal(au.Data, expected) {
+				t.Errorf("Lost packet %d: unexpected %d byte access unit at %d", tt.lost, len(au.Data), au.Timestamp)
+			}
+		}
+	}
+
+	// Marker before the access unit is complete
+	d := NewAACDepacketizer(0)
+	truncated := *packets[1]
+	truncated.Header.Marker = true
+	d.Depacketize(packets[0])
+	if _, err := d.Depacketize(&truncated); !errors.Is(err, ErrInvalidAACPayload) {
+		t.Errorf("Expected %v for a short access unit, got %v", ErrInvalidAACPayload, err)
+	}
+}
+
+func TestAACDepacketizerRejectsInvalidPayloads(t *testing.T) {
+	tests := [][]byte{
+		{},
+		{0x00},                   // truncated AU-headers-length
+		{0x00, 0x00},             // no AU headers
+		{0x00, 0x20, 0x00, 0x50}, // second AU header missing
+		{0x00, 0x0C, 0x00, 0x00}, // AU header shorter than sizelength+indexlength
+		{0x00, 0x20, 0x00, 0x10, 0x00, 0x10, 0xAA, 0xBB}, // two 2-byte access units with 2 bytes of data
+	}
+	for i, payload := range tests {
+		_, err := NewAACDepacketizer(0).Depacketize(NewRTPPacket(PayloadTypeAAC, 1, 0, 0x5678, payload))
+		if !errors.Is(err, ErrInvalidAACPayload) {
+			t.Errorf("Payload %d (%x): expected %v, got %v", i, payload, ErrInvalidAACPayload, err)
+		}
+	}
+}
+
+func TestAACAccessUnitADTS(t *testing.T) {
+	au := &AACAccessUnit{Data: testAAC(100, 1)}
+	frame, err := au.ADTS([]byte{0x12, 0x10}) // AAC-LC, 44100Hz, stereo
+	if err != nil {
+		t.Fatalf("Failed to build ADTS frame: %v", err)
+	}
+	expected := []byte{0xFF, 0xF1, 0x50, 0x80, 0x0D, 0x7F, 0xFC}
+	if !bytes.Equal(frame[:adtsHeaderSize], expected) || !bytes.Equal(frame[adtsHeaderSize:], au.Data) {
+		t.Errorf("Expected ADTS header %x, got %x", expected, frame[:adtsHeaderSize])
+	}
+
+	for _, config := range [][]byte{{0x12}, {0x2A, 0x10}, {0x17, 0x90}} { // short, object type 5 (SBR), explicit frequency
+		if _, err := au.ADTS(config); !errors.Is(err, ErrUnsupportedAACConfig) {
+			t.Errorf("Config %x: expected %v, got %v", config, ErrUnsupportedAACConfig, err)
+		}
+	}
+}
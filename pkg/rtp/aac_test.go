@@ -0,0 +1,148 @@
+package rtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestAACPacketizerSingleAU(t *testing.T) {
+	au := make([]byte, 300)
+
+	payloads, err := NewAACPacketizer(1200).Packetize(au)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 payload, got %d", len(payloads))
+	}
+	if binary.BigEndian.Uint16(payloads[0][0:2]) != 16 {
+		t.Errorf("Expected AU-headers-length 16, got %d", binary.BigEndian.Uint16(payloads[0][0:2]))
+	}
+	if size := binary.BigEndian.Uint16(payloads[0][2:4]) >> 3; size != 300 {
+		t.Errorf("Expected AU size 300, got %d", size)
+	}
+	if !bytes.Equal(payloads[0][4:], au) {
+		t.Errorf("Expected AU data to follow the AU header section")
+	}
+}
+
+func TestAACPacketizerFragmentsToMTU(t *testing.T) {
+	const mtu = 1200
+	au := make([]byte, 3000)
+	for i := range au {
+		au[i] = byte(i)
+	}
+
+	payloads, err := NewAACPacketizer(mtu).Packetize(au)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 3 fragments, got %d", len(payloads))
+	}
+
+	reassembled := []byte{}
+	for i, payload := range payloads {
+		data, err := NewRTPPacket(PayloadTypeAAC, uint16(i), 0, 1, payload).Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal fragment %d: %v", i, err)
+		}
+		if len(data) > mtu {
+			t.Errorf("Fragment %d exceeds MTU: %d bytes", i, len(data))
+		}
+		// Every fragment carries the size of the whole AU
+		if size := binary.BigEndian.Uint16(payload[2:4]) >> 3; int(size) != len(au) {
+			t.Errorf("Fragment %d: expected AU size %d, got %d", i, len(au), size)
+		}
+		reassembled = append(reassembled, payload[4:]...)
+	}
+
+	if !bytes.Equal(reassembled, au) {
+		t.Errorf("Reassembled AU does not match original")
+	}
+}
+
+func TestAACPacketizerRejectsOversizedAU(t *testing.T) {
+	packetizer := NewAACPacketizer(1200)
+
+	if _, err := packetizer.Packetize(make([]byte, aacMaxAUSize)); err != nil {
+		t.Fatalf("Expected largest AU to be accepted, got %v", err)
+	}
+	// One byte more would be truncated by the 13-bit AU-size field
+	if _, err := packetizer.Packetize(make([]byte, aacMaxAUSize+1)); !errors.Is(err, ErrAUTooLarge) {
+		t.Errorf("Expected ErrAUTooLarge, got %v", err)
+	}
+}
+
+func TestAACPacketizerRepacketizesMultipleAUs(t *testing.T) {
+	frames := [][]byte{bytes.Repeat([]byte{1}, 500), bytes.Repeat([]byte{2}, 500), bytes.Repeat([]byte{3}, 500)}
+
+	// Three AU headers (size 500, index/index-delta 0) followed by the access units
+	payload := binary.BigEndian.AppendUint16(nil, 3*aacAUHeaderBits)
+	for range frames {
+		payload = binary.BigEndian.AppendUint16(payload, 500<<3)
+	}
+	for _, frame := range frames {
+		payload = append(payload, frame...)
+	}
+
+	relayed, err := NewAACPacketizer(1200).Repacketize(payload, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to repacketize: %v", err)
+	}
+	if len(relayed) != len(frames) {
+		t.Fatalf("Expected one payload per access unit, got %d", len(relayed))
+	}
+	for i, r := range relayed {
+		if want := uint32(1000 + i*aacSamplesPerFrame); r.Timestamp != want {
+			t.Errorf("Access unit %d: expected timestamp %d, got %d", i, want, r.Timestamp)
+		}
+		if !r.Marker {
+			t.Errorf("Access unit %d: expected the marker on a complete access unit", i)
+		}
+		if !bytes.Equal(r.Payload[4:], frames[i]) {
+			t.Errorf("Access unit %d: data does not match the original", i)
+		}
+	}
+}
+
+func TestAACPacketizerRepacketizesFragments(t *testing.T) {
+	au := make([]byte, 3000)
+	for i := range au {
+		au[i] = byte(i)
+	}
+
+	repacketizer := NewAACPacketizer(1200)
+	depacketizer := NewAACDepacketizer(0)
+	var completed []*AACAccessUnit
+	seq := uint16(0)
+	payloads, err := NewAACPacketizer(2500).Packetize(au)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	for i, payload := range payloads {
+		relayed, err := repacketizer.Repacketize(payload, 1000, i == len(payloads)-1)
+		if err != nil {
+			t.Fatalf("Failed to repacketize fragment %d: %v", i, err)
+		}
+		for _, r := range relayed {
+			packet := NewRTPPacket(PayloadTypeAAC, seq, r.Timestamp, 1, r.Payload)
+			packet.SetMarker(r.Marker)
+			if data, _ := packet.Marshal(); len(data) > 1200 {
+				t.Errorf("Expected packets within the MTU, got %d bytes", len(data))
+			}
+			aus, err := depacketizer.Depacketize(packet)
+			if err != nil {
+				t.Fatalf("Failed to depacketize: %v", err)
+			}
+			completed = append(completed, aus...)
+			seq++
+		}
+	}
+
+	if len(completed) != 1 || !bytes.Equal(completed[0].Data, au) {
+		t.Fatalf("Expected the access unit to be reassembled from the smaller fragments")
+	}
+}
@@ -0,0 +1,48 @@
+package rtp
+
+import "time"
+
+// sequenceTracker follows the sequence numbers of received packets for the depacketizers
+type sequenceTracker struct {
+	last    uint16
+	started bool
+}
+
+// next records seq and reports whether the packet is new (false for late or
+// duplicate packets, which are dropped) and whether packets were lost before it
+func (s *sequenceTracker) next(seq uint16) (ok, lost bool) {
+	if s.started {
+		diff := int16(seq - s.last)
+		if diff <= 0 {
+			return false, false
+		}
+		lost = diff > 1
+	}
+	s.last = seq
+	s.started = true
+	return true, lost
+}
+
+// mediaClock converts RTP timestamps to presentation times since the first
+// one, following the timestamp across 32-bit wraparound
+type mediaClock struct {
+	rate    int64 // clock ticks per second
+	elapsed int64 // ticks from the first timestamp to the last
+	last    uint32
+	started bool
+}
+
+func newMediaClock(rate uint32) mediaClock {
+	return mediaClock{rate: int64(rate)}
+}
+
+// at returns the presentation time of timestamp
+func (c *mediaClock) at(timestamp uint32) time.Duration {
+	if !c.started {
+		c.last = timestamp
+		c.started = true
+	}
+	c.elapsed += int64(int32(timestamp - c.last))
+	c.last = timestamp
+	return time.Duration(c.elapsed/c.rate)*time.Second + time.Duration(c.elapsed%c.rate)*time.Second/time.Duration(c.rate)
+}
@@ -0,0 +1,284 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sol/pkg/codec"
+)
+
+// H.264 NAL unit types used by the packetizer (RFC 6184)
+const (
+	NALTypeSTAPA = 24 // Single-time aggregation packet A
+	NALTypeFUA   = 28 // Fragmentation unit A
+
+	stapAHeaderSize  = 1 // STAP-A NAL header
+	stapANALUSizeLen = 2 // 16-bit size prefix of each aggregated NAL unit
+	fuaHeaderSize    = 2 // FU indicator + FU header
+)
+
+// PacketizationMode is the H.264 packetization-mode negotiated in the SDP fmtp (RFC 6184 section 6.2)
+type PacketizationMode int
+
+const (
+	PacketizationModeSingleNAL      PacketizationMode = 0 // one NAL unit per packet, no fragmentation
+	PacketizationModeNonInterleaved PacketizationMode = 1 // single NAL unit packets and FU-A fragments
+)
+
+// ErrNALUTooLarge is returned in packetization-mode 0 for a NAL unit that does not fit the MTU
+var ErrNALUTooLarge = errors.New("NAL unit exceeds MTU in single NAL unit mode")
+
+// H264Packetizer splits H.264 NAL units into RTP payloads that fit the MTU
+type H264Packetizer struct {
+	mtu        int                 // maximum RTP packet size including the RTP header
+	mode       PacketizationMode   // whether oversized NAL units may be fragmented
+	timestamps *TimestampGenerator // stamps locally built frames (nil when only relaying)
+}
+
+// NewH264Packetizer creates a new H.264 packetizer for the given MTU (packetization-mode 1)
+func NewH264Packetizer(mtu int) *H264Packetizer {
+	return NewH264PacketizerWithMode(mtu, PacketizationModeNonInterleaved)
+}
+
+// NewH264PacketizerWithMode creates a new H.264 packetizer for the given MTU and packetization mode
+func NewH264PacketizerWithMode(mtu int, mode PacketizationMode) *H264Packetizer {
+	return &H264Packetizer{
+		mtu:  mtu,
+		mode: mode,
+	}
+}
+
+// Codec returns the codec this packetizer produces payloads for
+func (p *H264Packetizer) Codec() codec.Codec {
+	return codec.H264
+}
+
+// Mode returns the packetization mode the packetizer follows
+func (p *H264Packetizer) Mode() PacketizationMode {
+	return p.mode
+}
+
+// maxPayloadSize returns the payload budget left after the RTP header
+func (p *H264Packetizer) maxPayloadSize() int {
+	return p.mtu - MinRTPHeaderSize
+}
+
+// Packetize converts a single NAL unit into one or more RTP payloads.
+// NAL units that fit are sent as-is (single NAL unit packet), larger ones are
+// fragmented with FU-A, or rejected with ErrNALUTooLarge in packetization-mode 0.
+// The marker bit belongs on the last payload of an access unit.
+func (p *H264Packetizer) Packetize(nalu []byte) ([][]byte, error) {
+	if len(nalu) == 0 {
+		return nil, nil
+	}
+
+	if len(nalu) <= p.maxPayloadSize() {
+		return [][]byte{nalu}, nil
+	}
+
+	if p.mode == PacketizationModeSingleNAL {
+		return nil, fmt.Errorf("%w: %d bytes (max: %d)", ErrNALUTooLarge, len(nalu), p.maxPayloadSize())
+	}
+	return p.fragmentFUA(nalu), nil
+}
+
+// PacketizeAccessUnit converts the NAL units of one access unit into RTP payloads.
+// Consecutive small NAL units (e.g. SPS + PPS) are aggregated into STAP-A packets
+// up to the MTU, larger ones are packetized as by Packetize. Packetization-mode 0
+// does not allow aggregation, so there every NAL unit is sent on its own.
+func (p *H264Packetizer) PacketizeAccessUnit(nalus [][]byte) ([][]byte, error) {
+	var payloads [][]byte
+	var pending [][]byte // small NAL units waiting to be aggregated
+	pendingSize := stapAHeaderSize
+
+	flush := func() {
+		switch len(pending) {
+		case 0:
+		case 1:
+			payloads = append(payloads, pending[0]) // nothing to aggregate with
+		default:
+			payloads = append(payloads, buildSTAPA(pending, pendingSize))
+		}
+		pending = nil
+		pendingSize = stapAHeaderSize
+	}
+
+	for _, nalu := range nalus {
+		if len(nalu) == 0 {
+			continue
+		}
+
+		aggregatable := p.mode != PacketizationModeSingleNAL &&
+			stapAHeaderSize+stapANALUSizeLen+len(nalu) <= p.maxPayloadSize()
+		if !aggregatable {
+			flush()
+			nalPayloads, err := p.Packetize(nalu)
+			if err != nil {
+				return nil, err
+			}
+			payloads = append(payloads, nalPayloads...)
+			continue
+		}
+
+		if pendingSize+stapANALUSizeLen+len(nalu) > p.maxPayloadSize() {
+			flush()
+		}
+		pending = append(pending, nalu)
+		pendingSize += stapANALUSizeLen + len(nalu)
+	}
+	flush()
+
+	return payloads, nil
+}
+
+// SetTimestampGenerator sets the generator that stamps frames passed to PacketizeFrame
+func (p *H264Packetizer) SetTimestampGenerator(g *TimestampGenerator) {
+	p.timestamps = g
+}
+
+// PacketizeFrame packetizes one locally built access unit (e.g. a synthesized
+// stream) and stamps every payload with the next frame timestamp of the
+// generator; the marker bit is set on the last payload
+func (p *H264Packetizer) PacketizeFrame(nalus [][]byte) ([]RelayPayload, error) {
+	if p.timestamps == nil {
+		return nil, ErrNoTimestampGenerator
+	}
+	payloads, err := p.PacketizeAccessUnit(nalus)
+	if err != nil {
+		return nil, err
+	}
+	return relayPayloads(payloads, p.timestamps.Next(1), true), nil
+}
+
+// buildSTAPA aggregates NAL units into one STAP-A payload of the given size.
+// The STAP-A header carries the highest NRI and the OR of the F bits (RFC 6184 section 5.7.1)
+func buildSTAPA(nalus [][]byte, size int) []byte {
+	var forbidden, nri byte
+	for _, nalu := range nalus {
+		forbidden |= nalu[0] & 0x80
+		if nalu[0]&0x60 > nri {
+			nri = nalu[0] & 0x60
+		}
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, forbidden|nri|NALTypeSTAPA)
+	for _, nalu := range nalus {
+		payload = append(payload, byte(len(nalu)>>8), byte(len(nalu)))
+		payload = append(payload, nalu...)
+	}
+	return payload
+}
+
+// Repacketize fits a relayed H.264 payload to the MTU: a single NAL unit is
+// fragmented with FU-A, a STAP-A is unpacked and packetized again, and an FU-A
+// fragment is split into smaller fragments keeping its start and end bits.
+// All payloads share the timestamp; the marker stays on the last one.
+func (p *H264Packetizer) Repacketize(payload []byte, timestamp uint32, marker bool) ([]RelayPayload, error) {
+	if len(payload) <= p.maxPayloadSize() {
+		return []RelayPayload{{Payload: payload, Timestamp: timestamp, Marker: marker}}, nil
+	}
+
+	var payloads [][]byte
+	switch payload[0] & 0x1F {
+	case NALTypeSTAPA:
+		nalus, err := splitSTAPA(payload)
+		if err != nil {
+			return nil, err
+		}
+		if payloads, err = p.PacketizeAccessUnit(nalus); err != nil {
+			return nil, err
+		}
+	case NALTypeFUA:
+		if len(payload) < fuaHeaderSize {
+			return nil, fmt.Errorf("%w: truncated FU-A header", ErrInvalidH264Payload)
+		}
+		payloads = p.splitFUA(payload)
+	default:
+		var err error
+		if payloads, err = p.Packetize(payload); err != nil {
+			return nil, err
+		}
+	}
+	return relayPayloads(payloads, timestamp, marker), nil
+}
+
+// splitSTAPA returns the NAL units aggregated in a STAP-A payload
+func splitSTAPA(payload []byte) ([][]byte, error) {
+	var nalus [][]byte
+	data := payload[stapAHeaderSize:]
+	for len(data) > 0 {
+		if len(data) < stapANALUSizeLen {
+			return nil, fmt.Errorf("%w: truncated STAP-A size", ErrInvalidH264Payload)
+		}
+		size := int(binary.BigEndian.Uint16(data))
+		data = data[stapANALUSizeLen:]
+		if size == 0 || size > len(data) {
+			return nil, fmt.Errorf("%w: STAP-A NAL unit of %d bytes with %d left", ErrInvalidH264Payload, size, len(data))
+		}
+		nalus = append(nalus, data[:size])
+		data = data[size:]
+	}
+	return nalus, nil
+}
+
+// splitFUA splits an FU-A fragment into smaller ones; only the first keeps the
+// start bit and only the last the end bit of the original
+func (p *H264Packetizer) splitFUA(payload []byte) [][]byte {
+	fuIndicator, fuHeader := payload[0], payload[1]
+	data := payload[fuaHeaderSize:]
+	fragmentSize := p.maxPayloadSize() - fuaHeaderSize
+
+	payloads := make([][]byte, 0, (len(data)+fragmentSize-1)/fragmentSize)
+	for offset := 0; offset < len(data); offset += fragmentSize {
+		end := min(offset+fragmentSize, len(data))
+
+		header := fuHeader &^ 0xC0
+		if offset == 0 {
+			header |= fuHeader & 0x80 // Start bit
+		}
+		if end == len(data) {
+			header |= fuHeader & 0x40 // End bit
+		}
+
+		fragment := make([]byte, 0, fuaHeaderSize+end-offset)
+		fragment = append(fragment, fuIndicator, header)
+		fragment = append(fragment, data[offset:end]...)
+		payloads = append(payloads, fragment)
+	}
+	return payloads
+}
+
+// fragmentFUA splits a NAL unit into FU-A fragments
+func (p *H264Packetizer) fragmentFUA(nalu []byte) [][]byte {
+	naluHeader := nalu[0]
+	fuIndicator := (naluHeader & 0xE0) | NALTypeFUA // F + NRI from original, type 28
+	naluType := naluHeader & 0x1F
+
+	// The original NAL header is carried in the FU indicator/header, not the payload
+	data := nalu[1:]
+	fragmentSize := p.maxPayloadSize() - fuaHeaderSize
+
+	payloads := make([][]byte, 0, (len(data)+fragmentSize-1)/fragmentSize)
+	for offset := 0; offset < len(data); offset += fragmentSize {
+		end := offset + fragmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		fuHeader := naluType
+		if offset == 0 {
+			fuHeader |= 0x80 // Start bit
+		}
+		if end == len(data) {
+			fuHeader |= 0x40 // End bit
+		}
+
+		payload := make([]byte, 0, fuaHeaderSize+end-offset)
+		payload = append(payload, fuIndicator, fuHeader)
+		payload = append(payload, data[offset:end]...)
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
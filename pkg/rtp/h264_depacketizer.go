@@ -0,0 +1,218 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sol/pkg/codec"
+	"time"
+)
+
+// H.264 NAL unit types that start a key frame
+const (
+	nalTypeIDR = 5 // IDR slice
+	nalTypeSPS = 7 // sequence parameter set, sent ahead of the IDR slice
+)
+
+// ErrUnsupportedNALType is returned for H.264 payload structures the depacketizer
+// does not handle (STAP-B, MTAP and FU-B are only used in interleaved mode)
+var ErrUnsupportedNALType = errors.New("unsupported H.264 RTP payload type")
+
+// ErrInvalidH264Payload is returned for a truncated or malformed H.264 RTP payload
+var ErrInvalidH264Payload = errors.New("invalid H.264 RTP payload")
+
+// H264AccessUnit is one picture reassembled from RTP packets
+type H264AccessUnit struct {
+	NALUs     [][]byte      // NAL units in decoding order, without start codes
+	Timestamp uint32        // RTP timestamp shared by the packets of the access unit
+	Time      time.Duration // presentation time relative to the first access unit, from the clock rate
+}
+
+// IsKeyFrame reports whether the access unit contains an IDR slice
+func (au *H264AccessUnit) IsKeyFrame() bool {
+	for _, nalu := range au.NALUs {
+		if nalu[0]&0x1F == nalTypeIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// IsH264KeyFramePayload reports whether an H.264 RTP payload starts a key
+// frame: an IDR slice or SPS sent as a single NAL unit, aggregated in a
+// STAP-A, or as the start fragment of an FU-A
+func IsH264KeyFramePayload(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	isKeyNALType := func(naluType byte) bool {
+		return naluType == nalTypeIDR || naluType == nalTypeSPS
+	}
+
+	switch naluType := payload[0] & 0x1F; naluType {
+	case NALTypeSTAPA:
+		data := payload[stapAHeaderSize:]
+		for len(data) > stapANALUSizeLen {
+			size := int(binary.BigEndian.Uint16(data))
+			data = data[stapANALUSizeLen:]
+			if size == 0 || size > len(data) {
+				return false
+			}
+			if isKeyNALType(data[0] & 0x1F) {
+				return true
+			}
+			data = data[size:]
+		}
+		return false
+
+	case NALTypeFUA:
+		return len(payload) >= fuaHeaderSize && payload[1]&0x80 != 0 && isKeyNALType(payload[1]&0x1F)
+
+	default:
+		return isKeyNALType(naluType)
+	}
+}
+
+// AVCC returns the NAL units with 4-byte length prefixes, the layout of FLV/MP4 video samples
+func (au *H264AccessUnit) AVCC() []byte {
+	size := 0
+	for _, nalu := range au.NALUs {
+		size += 4 + len(nalu)
+	}
+	buf := make([]byte, 0, size)
+	for _, nalu := range au.NALUs {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(nalu)))
+		buf = append(buf, nalu...)
+	}
+	return buf
+}
+
+// H264Depacketizer reverses H264Packetizer: it reassembles single NAL unit,
+// STAP-A and FU-A payloads (RFC 6184 packetization-mode 0 and 1) into NAL
+// units, and groups them into access units by RTP timestamp and marker bit.
+// Packets must arrive in sequence order; late or duplicate packets are
+// ignored, and a gap discards the FU-A fragment in progress since the NAL
+// unit can no longer be rebuilt.
+type H264Depacketizer struct {
+	nalus     [][]byte // complete NAL units of the access unit being assembled
+	timestamp uint32   // RTP timestamp of the access unit being assembled
+	fragment  []byte   // FU-A NAL unit being reassembled (nil if none)
+
+	sequence sequenceTracker
+	clock    mediaClock
+}
+
+// NewH264Depacketizer creates a depacketizer for an RTP clock of clockRate
+// ticks per second (0 = the 90kHz H.264 clock)
+func NewH264Depacketizer(clockRate uint32) *H264Depacketizer {
+	if clockRate == 0 {
+		clockRate = uint32(codec.H264.RTPClockRate())
+	}
+	return &H264Depacketizer{clock: newMediaClock(clockRate)}
+}
+
+// Depacketize adds a received packet and returns the access units it completes,
+// none while more packets are needed. A packet with a new timestamp completes
+// the previous access unit even if its marker bit was lost. A malformed payload
+// is skipped with an error; the access units completed before it are still returned.
+func (d *H264Depacketizer) Depacketize(packet *RTPPacket) ([]*H264AccessUnit, error) {
+	seq := packet.Header.SequenceNumber
+	ok, lost := d.sequence.next(seq)
+	if !ok {
+		slog.Debug("Late or duplicate H.264 RTP packet ignored", "seq", seq)
+		return nil, nil
+	}
+	if lost && d.fragment != nil {
+		slog.Debug("H.264 RTP packet loss, dropping FU-A fragment", "seq", seq)
+		d.fragment = nil
+	}
+
+	var completed []*H264AccessUnit
+	if packet.Header.Timestamp != d.timestamp {
+		if au := d.flush(); au != nil {
+			slog.Debug("H.264 access unit completed without marker", "timestamp", au.Timestamp)
+			completed = append(completed, au)
+		}
+	}
+	d.timestamp = packet.Header.Timestamp
+
+	if err := d.addPayload(packet.Payload); err != nil {
+		return completed, err
+	}
+
+	if packet.Header.Marker {
+		if au := d.flush(); au != nil {
+			completed = append(completed, au)
+		}
+	}
+	return completed, nil
+}
+
+// addPayload unpacks one RTP payload into the current access unit
+func (d *H264Depacketizer) addPayload(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: empty payload", ErrInvalidH264Payload)
+	}
+
+	switch naluType := payload[0] & 0x1F; {
+	case naluType >= 1 && naluType <= 23:
+		d.fragment = nil
+		d.nalus = append(d.nalus, append([]byte(nil), payload...))
+		return nil
+
+	case naluType == NALTypeSTAPA:
+		d.fragment = nil
+		nalus, err := splitSTAPA(payload)
+		if err != nil {
+			return err
+		}
+		for _, nalu := range nalus {
+			d.nalus = append(d.nalus, append([]byte(nil), nalu...))
+		}
+		return nil
+
+	case naluType == NALTypeFUA:
+		if len(payload) < fuaHeaderSize {
+			return fmt.Errorf("%w: truncated FU-A header", ErrInvalidH264Payload)
+		}
+		fuHeader := payload[1]
+		start, end := fuHeader&0x80 != 0, fuHeader&0x40 != 0
+		if start {
+			// Rebuild the NAL header from the F/NRI bits of the FU indicator and the type of the FU header
+			d.fragment = append([]byte{payload[0]&0xE0 | fuHeader&0x1F}, payload[fuaHeaderSize:]...)
+		} else if d.fragment != nil {
+			d.fragment = append(d.fragment, payload[fuaHeaderSize:]...)
+		} else {
+			// Start fragment lost: nothing to append to until the next start
+			return nil
+		}
+		if end {
+			d.nalus = append(d.nalus, d.fragment)
+			d.fragment = nil
+		}
+		return nil
+
+	case naluType == 0:
+		return fmt.Errorf("%w: NAL unit type 0", ErrInvalidH264Payload)
+
+	default:
+		return fmt.Errorf("%w: %d", ErrUnsupportedNALType, naluType)
+	}
+}
+
+// flush returns the access unit assembled so far and starts a new one (nil if it has no NAL units)
+func (d *H264Depacketizer) flush() *H264AccessUnit {
+	d.fragment = nil
+	if len(d.nalus) == 0 {
+		return nil
+	}
+	au := &H264AccessUnit{
+		NALUs:     d.nalus,
+		Timestamp: d.timestamp,
+		Time:      d.clock.at(d.timestamp),
+	}
+	d.nalus = nil
+	return au
+}
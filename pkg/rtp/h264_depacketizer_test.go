@@ -0,0 +1,199 @@
+package rtp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testNALU returns a NAL unit with the given header and size and a recognizable body
+func testNALU(header byte, size int) []byte {
+	nalu := make([]byte, size)
+	nalu[0] = header
+	for i := 1; i < size; i++ {
+		nalu[i] = byte(i * 7)
+	}
+	return nalu
+}
+
+// packetizeAccessUnits turns access units into RTP packets the way a sender
+// does: consecutive sequence numbers, one timestamp per access unit, marker on its last packet
+func packetizeAccessUnits(t *testing.T, packetizer *H264Packetizer, accessUnits [][][]byte, timestamps []uint32) []*RTPPacket {
+	t.Helper()
+	var packets []*RTPPacket
+	seq := uint16(65530) // wraps during the test
+	for i, nalus := range accessUnits {
+		payloads, err := packetizer.PacketizeAccessUnit(nalus)
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		for j, payload := range payloads {
+			packet := NewRTPPacket(PayloadTypeH264, seq, timestamps[i], 0x1234, payload)
+			packet.SetMarker(j == len(payloads)-1)
+			packets = append(packets, packet)
+			seq++
+		}
+	}
+	return packets
+}
+
+func depacketizeAll(t *testing.T, d *H264Depacketizer, packets []*RTPPacket) []*H264AccessUnit {
+	t.Helper()
+	var accessUnits []*H264AccessUnit
+	for _, packet := range packets {
+		completed, err := d.Depacketize(packet)
+		if err != nil {
+			t.Fatalf("Failed to depacketize packet %d: %v", packet.Header.SequenceNumber, err)
+		}
+		accessUnits = append(accessUnits, completed...)
+	}
+	return accessUnits
+}
+
+func TestH264DepacketizerRecoversFragmentedAndAggregatedNALUs(t *testing.T) {
+	sps, pps := testNALU(0x67, 20), testNALU(0x68, 6)
+	accessUnits := [][][]byte{
+		{sps, pps, testNALU(0x65, 5000)},           // STAP-A + FU-A key frame
+		{testNALU(0x41, 800)},                      // single NAL unit
+		{testNALU(0x06, 30), testNALU(0x41, 3000)}, // SEI alone, then FU-A
+	}
+	timestamps := []uint32{4294964296, 0, 3000} // wraps at 2^32
+
+	packets := packetizeAccessUnits(t, NewH264Packetizer(1200), accessUnits, timestamps)
+	if len(packets) < 10 {
+		t.Fatalf("Expected fragmented access units, got %d packets", len(packets))
+	}
+
+	got := depacketizeAll(t, NewH264Depacketizer(0), packets)
+	if len(got) != len(accessUnits) {
+		t.Fatalf("Expected %d access units, got %d", len(accessUnits), len(got))
+	}
+	for i, au := range got {
+		if !reflect.DeepEqual(au.NALUs, accessUnits[i]) {
+			t.Errorf("Access unit %d: NAL units differ from the original", i)
+		}
+		if au.Timestamp != timestamps[i] {
+			t.Errorf("Access unit %d: expected timestamp %d, got %d", i, timestamps[i], au.Timestamp)
+		}
+		if expected := time.Duration(i) * time.Second / 30; au.Time != expected {
+			t.Errorf("Access unit %d: expected time %v on the 90kHz clock, got %v", i, expected, au.Time)
+		}
+	}
+	if !got[0].IsKeyFrame() || got[1].IsKeyFrame() {
+		t.Error("Expected only the first access unit to be a key frame")
+	}
+
+	avcc := got[0].AVCC()
+	if len(avcc) != 3*4+20+6+5000 || !bytes.Equal(avcc[:4], []byte{0, 0, 0, 20}) || !bytes.Equal(avcc[4:24], sps) {
+		t.Errorf("Expected length-prefixed AVCC sample, got %d bytes starting %x", len(avcc), avcc[:8])
+	}
+}
+
+func TestH264DepacketizerSingleNALMode(t *testing.T) {
+	accessUnits := [][][]byte{{testNALU(0x67, 20), testNALU(0x68, 6), testNALU(0x65, 900)}}
+	packets := packetizeAccessUnits(t, NewH264PacketizerWithMode(1200, PacketizationModeSingleNAL), accessUnits, []uint32{90000})
+	if len(packets) != 3 {
+		t.Fatalf("Expected one packet per NAL unit, got %d", len(packets))
+	}
+
+	// 8kHz clock: presentation time follows the given rate
+	d := NewH264Depacketizer(8000)
+	got := depacketizeAll(t, d, packets)
+	if len(got) != 1 || !reflect.DeepEqual(got[0].NALUs, accessUnits[0]) {
+		t.Fatalf("Expected the original access unit, got %+v", got)
+	}
+	next := NewRTPPacket(PayloadTypeH264, packets[2].Header.SequenceNumber+1, 90000+4000, 0x1234, testNALU(0x41, 10))
+	next.SetMarker(true)
+	if completed, err := d.Depacketize(next); err != nil || len(completed) != 1 || completed[0].Time != 500*time.Millisecond {
+		t.Fatalf("Expected access unit at 500ms, got %+v, %v", completed, err)
+	}
+}
+
+func TestH264DepacketizerLossAndMissingMarker(t *testing.T) {
+	key, inter := testNALU(0x65, 3000), testNALU(0x41, 400)
+	packets := packetizeAccessUnits(t, NewH264Packetizer(1200), [][][]byte{{key}, {inter}, {inter}}, []uint32{0, 3000, 6000})
+	if len(packets) != 5 {
+		t.Fatalf("Expected 3 FU-A packets and 2 single NAL packets, got %d", len(packets))
+	}
+
+	// Lose the middle FU-A fragment: the key frame cannot be rebuilt
+	d := NewH264Depacketizer(0)
+	got := depacketizeAll(t, d, append([]*RTPPacket{packets[0]}, packets[2:]...))
+	if len(got) != 2 || got[0].Timestamp != 3000 || !bytes.Equal(got[0].NALUs[0], inter) {
+		t.Fatalf("Expected only the inter frames after the loss, got %d access units", len(got))
+	}
+
+	// Duplicates and late packets are ignored
+	if completed, err := d.Depacketize(packets[3]); err != nil || completed != nil {
+		t.Fatalf("Expected duplicate packet to be ignored, got %+v, %v", completed, err)
+	}
+
+	// A lost marker: the next timestamp completes the previous access unit
+	d = NewH264Depacketizer(0)
+	first := NewRTPPacket(PayloadTypeH264, 1, 0, 0x1234, inter)
+	second := NewRTPPacket(PayloadTypeH264, 2, 3000, 0x1234, inter)
+	second.SetMarker(true)
+	if completed, _ := d.Depacketize(first); completed != nil {
+		t.Fatal("Expected no access unit before the marker")
+	}
+	completed, err := d.Depacketize(second)
+	if err != nil || len(completed) != 2 || completed[0].Timestamp != 0 || completed[1].Timestamp != 3000 {
+		t.Fatalf("Expected both access units, got %+v, %v", completed, err)
+	}
+}
+
+func TestH264DepacketizerRejectsInvalidPayloads(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		err     error
+	}{
+		{[]byte{}, ErrInvalidH264Payload},
+		{[]byte{0x18, 0x00, 0x10, 0x67}, ErrInvalidH264Payload}, // STAP-A size past the end
+		{[]byte{0x18, 0x00}, ErrInvalidH264Payload},             // truncated STAP-A size
+		{[]byte{0x1C}, ErrInvalidH264Payload},                   // FU-A without FU header
+		{[]byte{0x19, 0x00}, ErrUnsupportedNALType},             // STAP-B
+		{[]byte{0x1D, 0x85}, ErrUnsupportedNALType},             // FU-B
+	}
+	for i, tt := range tests {
+		_, err := NewH264Depacketizer(0).Depacketize(NewRTPPacket(PayloadTypeH264, 1, 0, 0x1234, tt.payload))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Payload %d (%x): expected %v, got %v", i, tt.payload, tt.err, err)
+		}
+	}
+}
+
+func TestIsH264KeyFramePayload(t *testing.T) {
+	packetizer := NewH264Packetizer(100)
+	sps, pps := testNALU(0x67, 10), testNALU(0x68, 4)
+
+	aggregated, err := packetizer.PacketizeAccessUnit([][]byte{sps, pps})
+	if err != nil || len(aggregated) != 1 {
+		t.Fatalf("Failed to aggregate SPS/PPS: %v (%d payloads)", err, len(aggregated))
+	}
+	idrFragments, _ := packetizer.Packetize(testNALU(0x65, 300))
+	sliceFragments, _ := packetizer.Packetize(testNALU(0x41, 300))
+
+	tests := []struct {
+		name    string
+		payload []byte
+		want    bool
+	}{
+		{"single IDR", testNALU(0x65, 20), true},
+		{"single SPS", sps, true},
+		{"single PPS", pps, false},
+		{"single non-IDR slice", testNALU(0x41, 20), false},
+		{"STAP-A with SPS", aggregated[0], true},
+		{"FU-A IDR start", idrFragments[0], true},
+		{"FU-A IDR continuation", idrFragments[1], false},
+		{"FU-A non-IDR start", sliceFragments[0], false},
+		{"truncated STAP-A", []byte{0x78, 0x00, 0x20, 0x67}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsH264KeyFramePayload(tt.payload); got != tt.want {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package rtp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestH264PacketizerSingleNALU(t *testing.T) {
+	nalu := append([]byte{0x65}, make([]byte, 500)...)
+
+	payloads, err := NewH264Packetizer(1200).Packetize(nalu)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 payload, got %d", len(payloads))
+	}
+	if !bytes.Equal(payloads[0], nalu) {
+		t.Errorf("Expected NAL unit to be sent unchanged")
+	}
+}
+
+func TestH264PacketizerFragmentsToMTU(t *testing.T) {
+	const mtu = 1200
+	nalu := make([]byte, 5000)
+	nalu[0] = 0x65 // NRI=3, IDR slice
+	for i := 1; i < len(nalu); i++ {
+		nalu[i] = byte(i)
+	}
+
+	payloads, err := NewH264Packetizer(mtu).Packetize(nalu)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if len(payloads) < 2 {
+		t.Fatalf("Expected fragmentation, got %d payloads", len(payloads))
+	}
+
+	reassembled := []byte{}
+	for i, payload := range payloads {
+		// Every packet including the RTP header must fit the MTU
+		data, err := NewRTPPacket(PayloadTypeH264, uint16(i), 0, 1, payload).Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal fragment %d: %v", i, err)
+		}
+		if len(data) > mtu {
+			t.Errorf("Fragment %d exceeds MTU: %d bytes", i, len(data))
+		}
+		if i < len(payloads)-1 && len(data) != mtu {
+			t.Errorf("Expected non-final fragment %d to fill the MTU, got %d bytes", i, len(data))
+		}
+
+		if payload[0]&0x1F != NALTypeFUA {
+			t.Fatalf("Expected FU-A indicator, got type %d", payload[0]&0x1F)
+		}
+		if payload[0]&0xE0 != nalu[0]&0xE0 {
+			t.Errorf("Expected FU indicator to keep F/NRI bits")
+		}
+		start := payload[1]&0x80 != 0
+		end := payload[1]&0x40 != 0
+		if start != (i == 0) {
+			t.Errorf("Fragment %d: unexpected start bit %v", i, start)
+		}
+		if end != (i == len(payloads)-1) {
+			t.Errorf("Fragment %d: unexpected end bit %v", i, end)
+		}
+		if i == 0 {
+			reassembled = append(reassembled, (payload[0]&0xE0)|(payload[1]&0x1F))
+		}
+		reassembled = append(reassembled, payload[2:]...)
+	}
+
+	if !bytes.Equal(reassembled, nalu) {
+		t.Errorf("Reassembled NAL unit does not match original")
+	}
+}
+
+func TestH264PacketizerSingleNALMode(t *testing.T) {
+	const mtu = 1200
+	packetizer := NewH264PacketizerWithMode(mtu, PacketizationModeSingleNAL)
+	if packetizer.Mode() != PacketizationModeSingleNAL {
+		t.Fatalf("Expected mode 0, got %d", packetizer.Mode())
+	}
+
+	// A NAL unit that fits is sent unchanged, exactly as in mode 1
+	nalu := append([]byte{0x41}, make([]byte, mtu-MinRTPHeaderSize-1)...)
+	payloads, err := packetizer.Packetize(nalu)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if len(payloads) != 1 || !bytes.Equal(payloads[0], nalu) {
+		t.Fatalf("Expected a single NAL unit packet, got %d payloads", len(payloads))
+	}
+
+	// Mode 0 never fragments: an oversized NAL unit is rejected instead of sent as FU-A
+	oversized := append([]byte{0x65}, make([]byte, mtu)...)
+	payloads, err = packetizer.Packetize(oversized)
+	if !errors.Is(err, ErrNALUTooLarge) {
+		t.Fatalf("Expected ErrNALUTooLarge, got %v", err)
+	}
+	if payloads != nil {
+		t.Errorf("Expected no payloads for a rejected NAL unit, got %d", len(payloads))
+	}
+
+	// The same NAL unit is fragmented in mode 1
+	payloads, err = NewH264PacketizerWithMode(mtu, PacketizationModeNonInterleaved).Packetize(oversized)
+	if err != nil {
+		t.Fatalf("Failed to packetize in mode 1: %v", err)
+	}
+	if len(payloads) != 2 || payloads[0][0]&0x1F != NALTypeFUA {
+		t.Errorf("Expected 2 FU-A fragments in mode 1, got %d payloads", len(payloads))
+	}
+}
+
+func TestH264PacketizerAggregatesSTAPA(t *testing.T) {
+	mtu := 1400
+	packetizer := NewH264Packetizer(mtu)
+	sps := []byte{0x67, 0x42, 0x00, 0x1F, 0xE9}
+	pps := []byte{0x68, 0xCE, 0x3C, 0x80}
+	idr := append([]byte{0x65}, make([]byte, mtu)...)
+
+	payloads, err := packetizer.PacketizeAccessUnit([][]byte{sps, pps, idr})
+	if err != nil {
+		t.Fatalf("Failed to packetize access unit: %v", err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 1 STAP-A + 2 FU-A payloads, got %d", len(payloads))
+	}
+
+	// SPS + PPS share one STAP-A packet: header, then size-prefixed NAL units
+	stapA := payloads[0]
+	if stapA[0]&0x1F != NALTypeSTAPA {
+		t.Fatalf("Expected STAP-A type %d, got %d", NALTypeSTAPA, stapA[0]&0x1F)
+	}
+	if stapA[0]&0x60 != 0x60 {
+		t.Errorf("Expected STAP-A header to carry the highest NRI, got 0x%02X", stapA[0]&0x60)
+	}
+	var expected []byte
+	expected = append(expected, stapA[0], 0x00, byte(len(sps)))
+	expected = append(expected, sps...)
+	expected = append(expected, 0x00, byte(len(pps)))
+	expected = append(expected, pps...)
+	if !bytes.Equal(stapA, expected) {
+		t.Errorf("Expected STAP-A payload %X, got %X", expected, stapA)
+	}
+
+	// The large IDR slice is still fragmented
+	for i, payload := range payloads[1:] {
+		if payload[0]&0x1F != NALTypeFUA {
+			t.Errorf("Expected FU-A fragment %d, got type %d", i, payload[0]&0x1F)
+		}
+	}
+
+	// A lone small NAL unit is not wrapped in a STAP-A
+	payloads, err = packetizer.PacketizeAccessUnit([][]byte{sps})
+	if err != nil {
+		t.Fatalf("Failed to packetize access unit: %v", err)
+	}
+	if len(payloads) != 1 || !bytes.Equal(payloads[0], sps) {
+		t.Errorf("Expected a single NAL unit packet for a lone SPS")
+	}
+
+	// Aggregation never exceeds the MTU
+	small := append([]byte{0x41}, make([]byte, 600)...)
+	payloads, err = packetizer.PacketizeAccessUnit([][]byte{small, small, small})
+	if err != nil {
+		t.Fatalf("Failed to packetize access unit: %v", err)
+	}
+	if len(payloads) != 2 || payloads[0][0]&0x1F != NALTypeSTAPA {
+		t.Fatalf("Expected a STAP-A followed by a single NAL unit, got %d payloads", len(payloads))
+	}
+	if len(payloads[0])+MinRTPHeaderSize > mtu {
+		t.Errorf("Expected STAP-A to fit the MTU, got %d bytes", len(payloads[0]))
+	}
+}
+
+func TestH264PacketizerSingleNALModeDoesNotAggregate(t *testing.T) {
+	packetizer := NewH264PacketizerWithMode(1400, PacketizationModeSingleNAL)
+	sps := []byte{0x67, 0x42, 0x00, 0x1F}
+	pps := []byte{0x68, 0xCE, 0x3C, 0x80}
+
+	payloads, err := packetizer.PacketizeAccessUnit([][]byte{sps, pps})
+	if err != nil {
+		t.Fatalf("Failed to packetize access unit: %v", err)
+	}
+	if len(payloads) != 2 || !bytes.Equal(payloads[0], sps) || !bytes.Equal(payloads[1], pps) {
+		t.Errorf("Expected SPS and PPS as separate NAL unit packets in mode 0")
+	}
+}
+
+func TestRTPTransportMTU(t *testing.T) {
+	transport := NewRTPTransport()
+	if transport.GetMTU() != DefaultMTU {
+		t.Errorf("Expected default MTU %d, got %d", DefaultMTU, transport.GetMTU())
+	}
+	if err := transport.SetMTU(1200); err != nil {
+		t.Fatalf("Failed to set MTU: %v", err)
+	}
+	if transport.GetMTU() != 1200 {
+		t.Errorf("Expected MTU 1200, got %d", transport.GetMTU())
+	}
+	if err := transport.SetMTU(MaxRTPPacketSize + 1); err == nil {
+		t.Errorf("Expected error for MTU above %d", MaxRTPPacketSize)
+	}
+	if err := transport.SetMTU(MinMTU - 1); err == nil {
+		t.Errorf("Expected error for MTU below %d", MinMTU)
+	}
+}
+
+func TestH264PacketizerRepacketizesToSmallerMTU(t *testing.T) {
+	const publisherMTU, playerMTU = 4000, 1200
+	// A STAP-A of two NAL units, a single NAL unit and an FU-A fragmented one, each over the player MTU
+	accessUnit := [][]byte{testNALU(0x06, 700), testNALU(0x06, 800), testNALU(0x65, 3000), testNALU(0x65, 9000)}
+
+	payloads, err := NewH264Packetizer(publisherMTU).PacketizeAccessUnit(accessUnit)
+	if err != nil {
+		t.Fatalf("Failed to packetize: %v", err)
+	}
+	if payloads[0][0]&0x1F != NALTypeSTAPA || payloads[len(payloads)-1][0]&0x1F != NALTypeFUA {
+		t.Fatalf("Expected STAP-A and FU-A payloads from the publisher packetizer")
+	}
+
+	repacketizer := NewH264Packetizer(playerMTU)
+	depacketizer := NewH264Depacketizer(0)
+	var completed []*H264AccessUnit
+	seq := uint16(0)
+	for i, payload := range payloads {
+		relayed, err := repacketizer.Repacketize(payload, 3000, i == len(payloads)-1)
+		if err != nil {
+			t.Fatalf("Failed to repacketize payload %d: %v", i, err)
+		}
+		for j, r := range relayed {
+			if r.Marker != (i == len(payloads)-1 && j == len(relayed)-1) {
+				t.Errorf("Expected the marker only on the last payload, got it on %d/%d", i, j)
+			}
+			packet := NewRTPPacket(PayloadTypeH264, seq, r.Timestamp, 1, r.Payload)
+			packet.SetMarker(r.Marker)
+			if data, _ := packet.Marshal(); len(data) > playerMTU {
+				t.Errorf("Expected packets within the player MTU, got %d bytes", len(data))
+			}
+			aus, err := depacketizer.Depacketize(packet)
+			if err != nil {
+				t.Fatalf("Failed to depacketize: %v", err)
+			}
+			completed = append(completed, aus...)
+			seq++
+		}
+	}
+
+	if len(completed) != 1 {
+		t.Fatalf("Expected 1 access unit, got %d", len(completed))
+	}
+	if !reflect.DeepEqual(completed[0].NALUs, accessUnit) {
+		t.Errorf("Expected the repacketized NAL units to match the original")
+	}
+}
+
+func TestH264PacketizerRepacketizeKeepsFittingPayload(t *testing.T) {
+	payload := testNALU(0x41, 100)
+
+	relayed, err := NewH264Packetizer(1200).Repacketize(payload, 90, true)
+	if err != nil {
+		t.Fatalf("Failed to repacketize: %v", err)
+	}
+	if len(relayed) != 1 || !bytes.Equal(relayed[0].Payload, payload) || relayed[0].Timestamp != 90 || !relayed[0].Marker {
+		t.Errorf("Expected the payload to be relayed unchanged, got %+v", relayed)
+	}
+}
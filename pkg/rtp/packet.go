@@ -3,6 +3,7 @@ package rtp
 import (
 	"encoding/binary"
 	"fmt"
+	"sol/pkg/codec"
 )
 
 // RTPHeader represents the RTP packet header
@@ -28,14 +29,52 @@ type RTPPacket struct {
 const (
 	MinRTPHeaderSize = 12   // Minimum RTP header size in bytes
 	MaxRTPPacketSize = 1500 // Maximum RTP packet size (MTU)
+	MinMTU           = 64   // Smallest MTU that still leaves room for payload after header/FU overhead
+	DefaultMTU       = MaxRTPPacketSize
 )
 
 // Common payload types
 const (
+	PayloadTypePCMU = 0   // G.711 mu-law (static, RFC 3551)
+	PayloadTypePCMA = 8   // G.711 A-law (static, RFC 3551)
 	PayloadTypeH264 = 96  // H.264 (dynamic)
 	PayloadTypeAAC  = 97  // AAC (dynamic)
 )
 
+// Dynamic payload types start here (RFC 3551 section 6)
+const firstDynamicPayloadType = 96
+
+// CodecForPayloadType identifies the codec of an RTP payload type. Static types
+// are fixed by RFC 3551; dynamic ones are resolved from their SDP rtpmap encoding
+func CodecForPayloadType(payloadType uint8, encoding string) codec.Codec {
+	switch payloadType {
+	case PayloadTypePCMU:
+		return codec.PCMU
+	case PayloadTypePCMA:
+		return codec.PCMA
+	}
+	if payloadType >= firstDynamicPayloadType {
+		return codec.FromRTPEncodingName(encoding)
+	}
+	return codec.Unknown
+}
+
+// PayloadTypeForCodec returns the payload type used to send c: the static type
+// for G.711, otherwise the dynamic type of its track (video tracks share one)
+func PayloadTypeForCodec(c codec.Codec) (uint8, bool) {
+	switch c {
+	case codec.PCMU:
+		return PayloadTypePCMU, true
+	case codec.PCMA:
+		return PayloadTypePCMA, true
+	case codec.H264, codec.H265:
+		return PayloadTypeH264, true
+	case codec.AAC:
+		return PayloadTypeAAC, true
+	}
+	return 0, false
+}
+
 // NewRTPPacket creates a new RTP packet
 func NewRTPPacket(payloadType uint8, sequenceNumber uint16, timestamp uint32, ssrc uint32, payload []byte) *RTPPacket {
 	return &RTPPacket{
@@ -140,6 +179,14 @@ func (p *RTPPacket) String() string {
 		len(p.Payload))
 }
 
+// ValidateMTU checks that an MTU (maximum RTP packet size including header) is usable
+func ValidateMTU(mtu int) error {
+	if mtu < MinMTU || mtu > MaxRTPPacketSize {
+		return fmt.Errorf("invalid RTP MTU: %d (must be between %d-%d)", mtu, MinMTU, MaxRTPPacketSize)
+	}
+	return nil
+}
+
 // boolToBit converts boolean to bit (0 or 1)
 func boolToBit(b bool) uint8 {
 	if b {
@@ -1,6 +1,8 @@
 package rtp
 
 import (
+	"fmt"
+	"sol/pkg/codec"
 	"testing"
 )
 
@@ -88,3 +90,40 @@ func TestRTPPacketStringRepresentation(t *testing.T) {
 		t.Errorf("Expected string representation %s, got %s", expected, str)
 	}
 }
+
+func TestCodecForPayloadType(t *testing.T) {
+	tests := []struct {
+		payloadType uint8
+		encoding    string
+		expected    codec.Codec
+	}{
+		{PayloadTypePCMU, "", codec.PCMU},
+		{PayloadTypePCMA, "PCMA/8000", codec.PCMA},
+		{PayloadTypeH264, "H264/90000", codec.H264},
+		{PayloadTypeH264, "H265/90000", codec.H265},
+		{PayloadTypeAAC, "MPEG4-GENERIC/44100/2", codec.AAC},
+		{PayloadTypeAAC, "", codec.Unknown},
+		{14, "MPA/90000", codec.Unknown}, // static types are not resolved from the encoding
+	}
+
+	for _, tt := range tests {
+		if got := CodecForPayloadType(tt.payloadType, tt.encoding); got != tt.expected {
+			t.Errorf("CodecForPayloadType(%d, %q): Expected %s, got %s", tt.payloadType, tt.encoding, tt.expected, got)
+		}
+	}
+
+	// Payload types chosen for sending map back to the same codec
+	for _, c := range []codec.Codec{codec.H264, codec.AAC, codec.PCMA, codec.PCMU} {
+		pt, ok := PayloadTypeForCodec(c)
+		if !ok {
+			t.Fatalf("Expected a payload type for %s", c)
+		}
+		encoding := fmt.Sprintf("%s/%d", c.RTPEncodingName(), c.RTPClockRate())
+		if got := CodecForPayloadType(pt, encoding); got != c {
+			t.Errorf("Expected payload type %d to map back to %s, got %s", pt, c, got)
+		}
+	}
+	if _, ok := PayloadTypeForCodec(codec.VP6); ok {
+		t.Error("Expected no payload type for VP6")
+	}
+}
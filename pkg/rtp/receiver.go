@@ -0,0 +1,113 @@
+package rtp
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// RTPHandler is called with each RTP packet received from a registered source.
+// data is a copy the handler may keep; header is its parsed RTP header
+type RTPHandler func(data []byte, header *RTPHeader)
+
+// rtpReceiver routes packets from one client RTP address (UDP ingest) to its handler
+type rtpReceiver struct {
+	handler RTPHandler
+	ip      net.IP // client IP; a changed source port is only accepted from it
+	ssrc    uint32 // SSRC learned from the first packet, used when the source port changes
+	hasSSRC bool
+}
+
+// RegisterReceiver routes RTP packets arriving on the server RTP port from the
+// client address clientIP:clientRTPPort to handler, and returns the key to unregister it
+func (t *RTPTransport) RegisterReceiver(clientIP string, clientRTPPort int, handler RTPHandler) (string, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(clientIP, fmt.Sprint(clientRTPPort)))
+	if err != nil {
+		return "", fmt.Errorf("invalid client RTP address: %v", err)
+	}
+	key := addr.String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.receivers[key]; exists {
+		return "", fmt.Errorf("RTP receiver already registered for %s", key)
+	}
+	t.receivers[key] = &rtpReceiver{handler: handler, ip: addr.IP}
+
+	slog.Info("RTP receiver registered", "client", key)
+	return key, nil
+}
+
+// UnregisterReceiver stops routing packets registered under key
+func (t *RTPTransport) UnregisterReceiver(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.receivers[key]; exists {
+		delete(t.receivers, key)
+		slog.Info("RTP receiver unregistered", "client", key)
+	}
+}
+
+// GetDroppedPacketCount returns the number of received RTP packets that were malformed or matched no receiver
+func (t *RTPTransport) GetDroppedPacketCount() uint64 {
+	return t.droppedPackets.Load()
+}
+
+// readRTP dispatches RTP packets from clients until the listener is closed
+func (t *RTPTransport) readRTP(listener net.PacketConn) {
+	buf := make([]byte, MaxRTPPacketSize)
+	for {
+		n, from, err := listener.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		t.dispatchRTP(buf[:n], from)
+	}
+}
+
+// dispatchRTP hands a received packet to the receiver of its source address,
+// or of its SSRC if the client's source port changed (e.g. NAT rebinding)
+// while its IP stayed the same
+func (t *RTPTransport) dispatchRTP(data []byte, from net.Addr) {
+	header, err := ValidateRTPPacket(data)
+	if err != nil {
+		t.droppedPackets.Add(1)
+		slog.Debug("Invalid RTP packet dropped", "from", from, "size", len(data), "err", err)
+		return
+	}
+
+	receiver := t.findReceiver(from, header.SSRC)
+	if receiver == nil {
+		t.droppedPackets.Add(1)
+		slog.Debug("RTP packet from unknown source dropped", "from", from, "ssrc", header.SSRC)
+		return
+	}
+
+	receiver.handler(append([]byte(nil), data...), header)
+}
+
+// findReceiver looks a receiver up by source address, then by learned SSRC
+// among the receivers registered for the source IP, so other hosts cannot
+// inject packets by guessing or sniffing the SSRC
+func (t *RTPTransport) findReceiver(source net.Addr, ssrc uint32) *rtpReceiver {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if receiver, ok := t.receivers[source.String()]; ok {
+		receiver.ssrc = ssrc
+		receiver.hasSSRC = true
+		return receiver
+	}
+	udpAddr, ok := source.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	for _, receiver := range t.receivers {
+		if receiver.hasSSRC && receiver.ssrc == ssrc && receiver.ip.Equal(udpAddr.IP) {
+			return receiver
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package rtp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDispatchRTPMatchesSourceThenSSRC(t *testing.T) {
+	transport := NewRTPTransport()
+	var received []uint16
+	key, err := transport.RegisterReceiver("127.0.0.1", 5000, func(data []byte, header *RTPHeader) {
+		received = append(received, header.SequenceNumber)
+	})
+	if err != nil {
+		t.Fatalf("Failed to register receiver: %v", err)
+	}
+	if _, err := transport.RegisterReceiver("127.0.0.1", 5000, func([]byte, *RTPHeader) {}); err == nil {
+		t.Error("Expected error registering the same client address twice")
+	}
+
+	packet := func(seq uint16, ssrc uint32) []byte {
+		data, _ := NewRTPPacket(PayloadTypeH264, seq, 0, ssrc, []byte{0x65}).Marshal()
+		return data
+	}
+	registered := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+	rebound := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6000}
+	otherHost := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 5000}
+
+	transport.dispatchRTP(packet(1, 0xAAAA), rebound)    // unknown source and SSRC
+	transport.dispatchRTP(packet(2, 0xAAAA), registered) // learns the SSRC
+	transport.dispatchRTP(packet(3, 0xAAAA), rebound)    // same SSRC from a new port
+	transport.dispatchRTP(packet(4, 0xBBBB), rebound)    // unknown SSRC
+	transport.dispatchRTP([]byte{0x80}, registered)      // not an RTP packet
+	transport.dispatchRTP(packet(6, 0xAAAA), otherHost)  // same SSRC from another IP
+
+	if len(received) != 2 || received[0] != 2 || received[1] != 3 {
+		t.Errorf("Expected packets 2 and 3, got %v", received)
+	}
+	if dropped := transport.GetDroppedPacketCount(); dropped != 4 {
+		t.Errorf("Expected 4 dropped packets, got %d", dropped)
+	}
+
+	transport.UnregisterReceiver(key)
+	transport.dispatchRTP(packet(5, 0xAAAA), registered)
+	if len(received) != 2 {
+		t.Errorf("Expected no packets after unregistering, got %v", received)
+	}
+}
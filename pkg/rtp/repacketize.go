@@ -0,0 +1,30 @@
+package rtp
+
+// RelayPayload is one RTP payload with its timestamp and marker, ready to be
+// sent: cut from a relayed packet or packetized from a locally built frame
+type RelayPayload struct {
+	Payload   []byte
+	Timestamp uint32 // RTP timestamp of the payload
+	Marker    bool
+}
+
+// Repacketizer fits the payloads of relayed RTP packets to the MTU of the
+// outgoing transport. A payload that fits is returned unchanged; a larger one
+// is cut along the payload format (e.g. into FU-A fragments), since the
+// publisher may have packetized for a larger MTU than the player's.
+type Repacketizer interface {
+	Repacketize(payload []byte, timestamp uint32, marker bool) ([]RelayPayload, error)
+}
+
+// relayPayloads wraps payloads sharing a timestamp; the marker stays on the last one
+func relayPayloads(payloads [][]byte, timestamp uint32, marker bool) []RelayPayload {
+	relayed := make([]RelayPayload, len(payloads))
+	for i, payload := range payloads {
+		relayed[i] = RelayPayload{
+			Payload:   payload,
+			Timestamp: timestamp,
+			Marker:    marker && i == len(payloads)-1,
+		}
+	}
+	return relayed
+}
@@ -0,0 +1,183 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// RTCP packet types (RFC 3550)
+const (
+	RTCPTypeSR   = 200 // Sender Report
+	RTCPTypeRR   = 201 // Receiver Report
+	RTCPTypeSDES = 202 // Source Description
+	RTCPTypeBYE  = 203 // Goodbye
+	RTCPTypeAPP  = 204 // Application-defined
+)
+
+// Constants for RTCP
+const (
+	RTCPHeaderSize       = 4   // Common RTCP header size in bytes
+	MaxRTCPSources       = 31  // Source count is a 5-bit field
+	MaxByeReasonSize     = 255 // Reason length is an 8-bit field
+	RTCPSenderReportSize = 28  // SR without reception report blocks
+)
+
+// ntpEpochOffset is the number of seconds from the NTP epoch (1900) to the Unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// RTCPSenderReport represents an RTCP SR packet without reception report
+// blocks (RFC 3550 Section 6.4.1), as sent by a source that receives nothing
+type RTCPSenderReport struct {
+	SSRC         uint32
+	NTPTime      time.Time // wallclock time the RTP timestamp corresponds to
+	RTPTimestamp uint32
+	PacketCount  uint32 // RTP packets sent since the start of transmission
+	OctetCount   uint32 // RTP payload bytes sent since the start of transmission
+}
+
+// Marshal serializes the sender report to bytes
+func (sr *RTCPSenderReport) Marshal() ([]byte, error) {
+	buf := make([]byte, RTCPSenderReportSize)
+
+	// First byte: V(2) + P(1) + RC(5), no report blocks
+	buf[0] = 2 << 6
+	buf[1] = RTCPTypeSR
+	binary.BigEndian.PutUint16(buf[2:4], RTCPSenderReportSize/4-1)
+
+	binary.BigEndian.PutUint32(buf[4:8], sr.SSRC)
+	binary.BigEndian.PutUint64(buf[8:16], NTPTimestamp(sr.NTPTime))
+	binary.BigEndian.PutUint32(buf[16:20], sr.RTPTimestamp)
+	binary.BigEndian.PutUint32(buf[20:24], sr.PacketCount)
+	binary.BigEndian.PutUint32(buf[24:28], sr.OctetCount)
+
+	return buf, nil
+}
+
+// Unmarshal deserializes bytes to an RTCP SR packet (report blocks are skipped)
+func (sr *RTCPSenderReport) Unmarshal(data []byte) error {
+	if len(data) < RTCPSenderReportSize {
+		return fmt.Errorf("RTCP SR too short: %d bytes (min: %d)", len(data), RTCPSenderReportSize)
+	}
+	if version := data[0] >> 6; version != 2 {
+		return fmt.Errorf("unsupported RTCP version: %d", version)
+	}
+	if data[1] != RTCPTypeSR {
+		return fmt.Errorf("not an RTCP SR packet: type %d", data[1])
+	}
+
+	sr.SSRC = binary.BigEndian.Uint32(data[4:8])
+	sr.NTPTime = timeFromNTP(binary.BigEndian.Uint64(data[8:16]))
+	sr.RTPTimestamp = binary.BigEndian.Uint32(data[16:20])
+	sr.PacketCount = binary.BigEndian.Uint32(data[20:24])
+	sr.OctetCount = binary.BigEndian.Uint32(data[24:28])
+	return nil
+}
+
+// NTPTimestamp converts a wallclock time to the 64-bit NTP format
+// (seconds since 1900 in the upper 32 bits, fraction in the lower 32)
+func NTPTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// timeFromNTP converts a 64-bit NTP timestamp to a wallclock time
+func timeFromNTP(ntp uint64) time.Time {
+	seconds := int64(ntp>>32) - ntpEpochOffset
+	nanos := (ntp & 0xFFFFFFFF) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanos))
+}
+
+// RTCPBye represents an RTCP BYE packet (RFC 3550 Section 6.6)
+type RTCPBye struct {
+	SSRCs  []uint32 // Sources leaving the session
+	Reason string   // Optional reason for leaving
+}
+
+// NewRTCPBye creates a BYE packet for the given sources
+func NewRTCPBye(reason string, ssrcs ...uint32) *RTCPBye {
+	return &RTCPBye{
+		SSRCs:  ssrcs,
+		Reason: reason,
+	}
+}
+
+// Marshal serializes the BYE packet to bytes (padded to a 32-bit boundary)
+func (b *RTCPBye) Marshal() ([]byte, error) {
+	if len(b.SSRCs) > MaxRTCPSources {
+		return nil, fmt.Errorf("too many sources for RTCP BYE: %d (max: %d)", len(b.SSRCs), MaxRTCPSources)
+	}
+	if len(b.Reason) > MaxByeReasonSize {
+		return nil, fmt.Errorf("RTCP BYE reason too long: %d bytes (max: %d)", len(b.Reason), MaxByeReasonSize)
+	}
+
+	size := RTCPHeaderSize + 4*len(b.SSRCs)
+	if b.Reason != "" {
+		size += 1 + len(b.Reason)
+	}
+	size = (size + 3) &^ 3 // pad to 32-bit words
+
+	buf := make([]byte, size)
+
+	// First byte: V(2) + P(1) + SC(5)
+	buf[0] = (2 << 6) | uint8(len(b.SSRCs))
+	buf[1] = RTCPTypeBYE
+
+	// Length in 32-bit words minus one
+	binary.BigEndian.PutUint16(buf[2:4], uint16(size/4-1))
+
+	offset := RTCPHeaderSize
+	for _, ssrc := range b.SSRCs {
+		binary.BigEndian.PutUint32(buf[offset:], ssrc)
+		offset += 4
+	}
+
+	if b.Reason != "" {
+		buf[offset] = uint8(len(b.Reason))
+		copy(buf[offset+1:], b.Reason)
+	}
+
+	return buf, nil
+}
+
+// Unmarshal deserializes bytes to an RTCP BYE packet
+func (b *RTCPBye) Unmarshal(data []byte) error {
+	if len(data) < RTCPHeaderSize {
+		return fmt.Errorf("RTCP packet too short: %d bytes (min: %d)", len(data), RTCPHeaderSize)
+	}
+	if version := data[0] >> 6; version != 2 {
+		return fmt.Errorf("unsupported RTCP version: %d", version)
+	}
+	if data[1] != RTCPTypeBYE {
+		return fmt.Errorf("not an RTCP BYE packet: type %d", data[1])
+	}
+
+	length := (int(binary.BigEndian.Uint16(data[2:4])) + 1) * 4
+	if length > len(data) {
+		return fmt.Errorf("RTCP BYE length %d exceeds packet size %d", length, len(data))
+	}
+
+	count := int(data[0] & 0x1F)
+	offset := RTCPHeaderSize
+	if offset+4*count > length {
+		return fmt.Errorf("RTCP BYE source count %d exceeds packet length %d", count, length)
+	}
+
+	b.SSRCs = make([]uint32, count)
+	for i := range b.SSRCs {
+		b.SSRCs[i] = binary.BigEndian.Uint32(data[offset:])
+		offset += 4
+	}
+
+	b.Reason = ""
+	if offset < length {
+		reasonLen := int(data[offset])
+		if offset+1+reasonLen > length {
+			return fmt.Errorf("RTCP BYE reason length %d exceeds packet length %d", reasonLen, length)
+		}
+		b.Reason = string(data[offset+1 : offset+1+reasonLen])
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTCPByeMarshalUnmarshal(t *testing.T) {
+	bye := NewRTCPBye("teardown", 0x12345678, 0x9abcdef0)
+
+	data, err := bye.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTCP BYE: %v", err)
+	}
+
+	// 4 header + 8 SSRC + 1 length + 8 reason = 21, padded to 24
+	if len(data) != 24 {
+		t.Fatalf("Expected 24 bytes, got %d", len(data))
+	}
+	if data[1] != RTCPTypeBYE {
+		t.Errorf("Expected packet type %d, got %d", RTCPTypeBYE, data[1])
+	}
+	if data[0]&0x1F != 2 {
+		t.Errorf("Expected source count 2, got %d", data[0]&0x1F)
+	}
+
+	parsed := &RTCPBye{}
+	if err := parsed.Unmarshal(data); err != nil {
+		t.Fatalf("Failed to unmarshal RTCP BYE: %v", err)
+	}
+	if len(parsed.SSRCs) != 2 || parsed.SSRCs[0] != 0x12345678 || parsed.SSRCs[1] != 0x9abcdef0 {
+		t.Errorf("Expected SSRCs [0x12345678 0x9abcdef0], got %x", parsed.SSRCs)
+	}
+	if parsed.Reason != "teardown" {
+		t.Errorf("Expected reason 'teardown', got %q", parsed.Reason)
+	}
+}
+
+func TestRTCPByeWithoutReason(t *testing.T) {
+	data, err := NewRTCPBye("", 0x12345678).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTCP BYE: %v", err)
+	}
+	if len(data) != 8 {
+		t.Fatalf("Expected 8 bytes, got %d", len(data))
+	}
+
+	parsed := &RTCPBye{}
+	if err := parsed.Unmarshal(data); err != nil {
+		t.Fatalf("Failed to unmarshal RTCP BYE: %v", err)
+	}
+	if parsed.Reason != "" {
+		t.Errorf("Expected empty reason, got %q", parsed.Reason)
+	}
+}
+
+func TestRTCPByeUnmarshalRejectsOtherTypes(t *testing.T) {
+	data := []byte{0x80, RTCPTypeSR, 0x00, 0x00}
+	if err := (&RTCPBye{}).Unmarshal(data); err == nil {
+		t.Error("Expected error for non-BYE packet")
+	}
+}
+
+func TestRTCPSenderReportMarshalUnmarshal(t *testing.T) {
+	ntpTime := time.Date(2024, 5, 1, 12, 0, 0, 250_000_000, time.UTC)
+	sr := &RTCPSenderReport{SSRC: 0x12345678, NTPTime: ntpTime, RTPTimestamp: 90000, PacketCount: 10, OctetCount: 12000}
+
+	data, err := sr.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTCP SR: %v", err)
+	}
+	if len(data) != RTCPSenderReportSize || data[1] != RTCPTypeSR || data[0] != 0x80 {
+		t.Fatalf("Expected a %d-byte SR without report blocks, got % x", RTCPSenderReportSize, data)
+	}
+	// 0.25s is a quarter of the 32-bit fraction
+	if ntp := NTPTimestamp(ntpTime); uint32(ntp) != 1<<30 || ntp>>32 != uint64(ntpTime.Unix()+ntpEpochOffset) {
+		t.Errorf("Unexpected NTP timestamp %#x", ntp)
+	}
+
+	parsed := &RTCPSenderReport{}
+	if err := parsed.Unmarshal(data); err != nil {
+		t.Fatalf("Failed to unmarshal RTCP SR: %v", err)
+	}
+	if parsed.SSRC != sr.SSRC || parsed.RTPTimestamp != sr.RTPTimestamp || parsed.PacketCount != sr.PacketCount || parsed.OctetCount != sr.OctetCount {
+		t.Errorf("Expected %+v, got %+v", sr, parsed)
+	}
+	if !parsed.NTPTime.Equal(ntpTime) {
+		t.Errorf("Expected NTP time %v, got %v", ntpTime, parsed.NTPTime)
+	}
+
+	if err := parsed.Unmarshal(data[:20]); err == nil {
+		t.Error("Expected a truncated SR to be rejected")
+	}
+}
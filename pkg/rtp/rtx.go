@@ -0,0 +1,172 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RTCP transport layer feedback (RFC 4585)
+const (
+	RTCPTypeRTPFB  = 205 // Transport layer feedback
+	RTCPFormatNACK = 1   // Generic NACK (FMT of RTPFB)
+)
+
+// RTX payload types (RFC 4588), one per track payload type
+const (
+	PayloadTypeH264RTX  = 98 // retransmissions of PayloadTypeH264
+	PayloadTypeAudioRTX = 99 // retransmissions of the audio track (AAC or G.711)
+)
+
+// DefaultRTXCacheSize is the number of sent packets kept per session for retransmission
+const DefaultRTXCacheSize = 512
+
+// rtxOSNSize is the original sequence number prepended to an RTX payload
+const rtxOSNSize = 2
+
+// RTXPayloadTypeFor returns the RTX payload type that retransmits payloadType
+func RTXPayloadTypeFor(payloadType uint8) (uint8, bool) {
+	switch payloadType {
+	case PayloadTypeH264:
+		return PayloadTypeH264RTX, true
+	case PayloadTypeAAC, PayloadTypePCMU, PayloadTypePCMA:
+		return PayloadTypeAudioRTX, true
+	}
+	return 0, false
+}
+
+// RTCPNack represents a generic NACK (RFC 4585 Section 6.2.1)
+type RTCPNack struct {
+	SenderSSRC uint32   // SSRC of the receiver sending the NACK
+	MediaSSRC  uint32   // SSRC of the source the lost packets belong to
+	Lost       []uint16 // Sequence numbers of the lost packets
+}
+
+// Marshal serializes the NACK, packing the lost sequence numbers into PID/BLP pairs
+func (n *RTCPNack) Marshal() ([]byte, error) {
+	var fci []byte
+	for i := 0; i < len(n.Lost); {
+		pid := n.Lost[i]
+		var blp uint16
+		i++
+		for i < len(n.Lost) {
+			diff := n.Lost[i] - pid
+			if diff == 0 || diff > 16 {
+				break
+			}
+			blp |= 1 << (diff - 1)
+			i++
+		}
+		fci = binary.BigEndian.AppendUint16(fci, pid)
+		fci = binary.BigEndian.AppendUint16(fci, blp)
+	}
+	if len(fci) == 0 {
+		return nil, fmt.Errorf("RTCP NACK has no lost packets")
+	}
+
+	buf := make([]byte, RTCPHeaderSize+8, RTCPHeaderSize+8+len(fci))
+	buf[0] = (2 << 6) | RTCPFormatNACK
+	buf[1] = RTCPTypeRTPFB
+	binary.BigEndian.PutUint16(buf[2:4], uint16((RTCPHeaderSize+8+len(fci))/4-1))
+	binary.BigEndian.PutUint32(buf[4:8], n.SenderSSRC)
+	binary.BigEndian.PutUint32(buf[8:12], n.MediaSSRC)
+	return append(buf, fci...), nil
+}
+
+// Unmarshal deserializes a generic NACK, expanding PID/BLP pairs into sequence numbers
+func (n *RTCPNack) Unmarshal(data []byte) error {
+	if len(data) < RTCPHeaderSize+8 {
+		return fmt.Errorf("RTCP NACK too short: %d bytes (min: %d)", len(data), RTCPHeaderSize+8)
+	}
+	if version := data[0] >> 6; version != 2 {
+		return fmt.Errorf("unsupported RTCP version: %d", version)
+	}
+	if data[1] != RTCPTypeRTPFB || data[0]&0x1F != RTCPFormatNACK {
+		return fmt.Errorf("not an RTCP NACK packet: type %d format %d", data[1], data[0]&0x1F)
+	}
+
+	length := (int(binary.BigEndian.Uint16(data[2:4])) + 1) * 4
+	if length > len(data) {
+		return fmt.Errorf("RTCP NACK length %d exceeds packet size %d", length, len(data))
+	}
+
+	n.SenderSSRC = binary.BigEndian.Uint32(data[4:8])
+	n.MediaSSRC = binary.BigEndian.Uint32(data[8:12])
+	n.Lost = nil
+	for offset := RTCPHeaderSize + 8; offset+4 <= length; offset += 4 {
+		pid := binary.BigEndian.Uint16(data[offset:])
+		blp := binary.BigEndian.Uint16(data[offset+2:])
+		n.Lost = append(n.Lost, pid)
+		for bit := uint16(0); bit < 16; bit++ {
+			if blp&(1<<bit) != 0 {
+				n.Lost = append(n.Lost, pid+bit+1)
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitRTCPCompound splits a compound RTCP packet into its individual packets
+func splitRTCPCompound(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	for len(data) > 0 {
+		if len(data) < RTCPHeaderSize {
+			return packets, fmt.Errorf("RTCP packet too short: %d bytes (min: %d)", len(data), RTCPHeaderSize)
+		}
+		length := (int(binary.BigEndian.Uint16(data[2:4])) + 1) * 4
+		if length > len(data) {
+			return packets, fmt.Errorf("RTCP packet length %d exceeds remaining size %d", length, len(data))
+		}
+		packets = append(packets, data[:length])
+		data = data[length:]
+	}
+	return packets, nil
+}
+
+// packetCache keeps the most recently sent RTP packets, indexed by sequence number
+type packetCache struct {
+	entries []cachedPacket
+}
+
+type cachedPacket struct {
+	sequence uint16
+	data     []byte // marshaled RTP packet
+	valid    bool
+}
+
+// newPacketCache creates a cache holding up to size packets
+func newPacketCache(size int) *packetCache {
+	return &packetCache{entries: make([]cachedPacket, size)}
+}
+
+// store keeps a sent packet, replacing the oldest one in its slot
+func (c *packetCache) store(sequence uint16, data []byte) {
+	c.entries[int(sequence)%len(c.entries)] = cachedPacket{sequence: sequence, data: data, valid: true}
+}
+
+// get returns a cached packet, or false if it was never sent or has been evicted
+func (c *packetCache) get(sequence uint16) ([]byte, bool) {
+	entry := c.entries[int(sequence)%len(c.entries)]
+	if !entry.valid || entry.sequence != sequence {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// newRTXPacket wraps a sent RTP packet for retransmission (RFC 4588 Section 4):
+// the RTX stream's payload type, sequence number and SSRC, with the original
+// sequence number prepended to the original payload
+func newRTXPacket(original []byte, payloadType uint8, sequenceNumber uint16, ssrc uint32) ([]byte, error) {
+	packet := &RTPPacket{}
+	if err := packet.Unmarshal(original); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, rtxOSNSize+len(packet.Payload))
+	binary.BigEndian.PutUint16(payload, packet.Header.SequenceNumber)
+	copy(payload[rtxOSNSize:], packet.Payload)
+
+	rtx := NewRTPPacket(payloadType, sequenceNumber, packet.Header.Timestamp, ssrc, payload)
+	rtx.SetMarker(packet.Header.Marker)
+	return rtx.Marshal()
+}
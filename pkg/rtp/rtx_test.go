@@ -0,0 +1,155 @@
+package rtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRTCPNackRoundTrip(t *testing.T) {
+	nack := &RTCPNack{SenderSSRC: 0x11111111, MediaSSRC: 0x22222222, Lost: []uint16{10, 11, 14, 26, 40}}
+	data, err := nack.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NACK: %v", err)
+	}
+	// 10-26 fit in one PID/BLP pair, 40 needs another
+	if len(data) != RTCPHeaderSize+8+2*4 {
+		t.Fatalf("Expected 2 FCI entries, got %d bytes", len(data))
+	}
+
+	parsed := &RTCPNack{}
+	if err := parsed.Unmarshal(data); err != nil {
+		t.Fatalf("Failed to unmarshal NACK: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, nack) {
+		t.Errorf("Expected %+v, got %+v", nack, parsed)
+	}
+}
+
+// newTestRTPClient listens where the client RTP port would be
+func newTestRTPClient(t *testing.T) (net.PacketConn, int) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// newTestRTPClientPair listens on a client RTP port and the RTCP port above it
+func newTestRTPClientPair(t *testing.T) (net.PacketConn, net.PacketConn, int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		rtpConn, port := newTestRTPClient(t)
+		rtcpConn, err := net.ListenPacket("udp", fmt.Sprintf("127.0.0.1:%d", port+1))
+		if err != nil {
+			rtpConn.Close()
+			continue
+		}
+		t.Cleanup(func() { rtcpConn.Close() })
+		return rtpConn, rtcpConn, port
+	}
+	t.Fatal("Failed to listen on a client RTP/RTCP port pair")
+	return nil, nil, 0
+}
+
+func readTestRTPPacket(t *testing.T, conn net.PacketConn) *RTPPacket {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, MaxRTPPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected RTP packet, got error: %v", err)
+	}
+	packet := &RTPPacket{}
+	if err := packet.Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Failed to parse RTP packet: %v", err)
+	}
+	return packet
+}
+
+func TestNackTriggersRetransmission(t *testing.T) {
+	transport := NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, clientRTCP, rtpPort := newTestRTPClientPair(t)
+	session, err := transport.CreateSession(0x12345678, PayloadTypeH264, rtpPort, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.EnableRTX(PayloadTypeH264RTX, 0x87654321, 0)
+
+	var sent []*RTPPacket
+	for i := byte(1); i <= 3; i++ {
+		if err := transport.SendRTPPacket(0x12345678, []byte{i, i, i}, uint32(i)*3000, i == 3); err != nil {
+			t.Fatalf("Failed to send RTP packet: %v", err)
+		}
+		sent = append(sent, readTestRTPPacket(t, client))
+	}
+
+	// Compound RTCP as clients send it: an empty receiver report followed by the NACK
+	report := []byte{0x80, RTCPTypeRR, 0, 1, 0, 0, 0, 0x01}
+	nack, err := (&RTCPNack{SenderSSRC: 1, MediaSSRC: 0x12345678, Lost: []uint16{sent[2].Header.SequenceNumber}}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NACK: %v", err)
+	}
+	transport.HandleRTCP(append(report, nack...), clientRTCP.LocalAddr())
+
+	rtx := readTestRTPPacket(t, client)
+	if rtx.Header.PayloadType != PayloadTypeH264RTX || rtx.Header.SSRC != 0x87654321 {
+		t.Errorf("Expected RTX stream PT %d SSRC 0x87654321, got PT %d SSRC %x", PayloadTypeH264RTX, rtx.Header.PayloadType, rtx.Header.SSRC)
+	}
+	if rtx.Header.Timestamp != sent[2].Header.Timestamp || !rtx.Header.Marker {
+		t.Errorf("Expected original timestamp %d and marker, got %d marker=%t", sent[2].Header.Timestamp, rtx.Header.Timestamp, rtx.Header.Marker)
+	}
+	if osn := binary.BigEndian.Uint16(rtx.Payload); osn != sent[2].Header.SequenceNumber {
+		t.Errorf("Expected original sequence number %d, got %d", sent[2].Header.SequenceNumber, osn)
+	}
+	if !bytes.Equal(rtx.Payload[2:], sent[2].Payload) {
+		t.Errorf("Expected original payload %x, got %x", sent[2].Payload, rtx.Payload[2:])
+	}
+
+	// The RTCP listener on the RTP port + 1 accepts NACKs from the network too,
+	// but only from the client's registered RTCP address
+	rtcpAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: transport.Port() + 1}
+	nack, _ = (&RTCPNack{SenderSSRC: 1, MediaSSRC: 0x12345678, Lost: []uint16{sent[1].Header.SequenceNumber}}).Marshal()
+	if _, err := client.WriteTo(nack, rtcpAddr); err != nil {
+		t.Fatalf("Failed to send NACK: %v", err)
+	}
+	nack, _ = (&RTCPNack{SenderSSRC: 1, MediaSSRC: 0x12345678, Lost: []uint16{sent[0].Header.SequenceNumber}}).Marshal()
+	if _, err := clientRTCP.WriteTo(nack, rtcpAddr); err != nil {
+		t.Fatalf("Failed to send NACK: %v", err)
+	}
+	rtx = readTestRTPPacket(t, client)
+	if osn := binary.BigEndian.Uint16(rtx.Payload); osn != sent[0].Header.SequenceNumber {
+		t.Errorf("Expected retransmission of %d only, got %d", sent[0].Header.SequenceNumber, osn)
+	}
+	client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _, err := client.ReadFrom(make([]byte, MaxRTPPacketSize)); err == nil {
+		t.Errorf("Expected no retransmission for a NACK from another address, got %d bytes", n)
+	}
+}
+
+func TestRetransmitSkipsUncachedPackets(t *testing.T) {
+	session := NewRTPSession(0x12345678, PayloadTypeH264)
+	if _, err := session.Retransmit([]uint16{1}, nil); err == nil {
+		t.Error("Expected error when RTX is not enabled")
+	}
+
+	session.EnableRTX(PayloadTypeH264RTX, 0x87654321, 4)
+	session.sentPackets.store(1, []byte{0x80, PayloadTypeH264, 0, 1, 0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78})
+	session.sentPackets.store(5, []byte{0x80, PayloadTypeH264, 0, 5, 0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78}) // evicts 1
+
+	sent, err := session.Retransmit([]uint16{1, 2}, nil)
+	if err != nil || sent != 0 {
+		t.Errorf("Expected nothing retransmitted for evicted or unsent packets, got %d (err: %v)", sent, err)
+	}
+}
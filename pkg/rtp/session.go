@@ -12,17 +12,32 @@ import (
 type RTPSession struct {
 	SSRC           uint32
 	sequenceNumber uint32
+	lastTimestamp  uint32 // RTP timestamp of the last packet sent (RTP-Info)
 	payloadType    uint8
 	clientRTPAddr  *net.UDPAddr
+	clientRTCPAddr *net.UDPAddr // RTP port + 1 (RFC 3550)
+	mtu            int // maximum RTP packet size including header
 	active         bool
 	mu             sync.RWMutex
+
+	// RTX retransmission (RFC 4588), nil cache when disabled
+	rtxPayloadType uint8
+	rtxSSRC        uint32
+	rtxSequence    uint16
+	sentPackets    *packetCache
 }
 
 // RTPTransport handles RTP transport over UDP (simplified)
 type RTPTransport struct {
-	rtpListener net.PacketConn
-	sessions    map[uint32]*RTPSession // SSRC -> Session
-	mu          sync.RWMutex
+	rtpListener  net.PacketConn
+	rtcpListener net.PacketConn         // receives RTCP feedback on the RTP port + 1 (nil if unavailable)
+	sessions     map[uint32]*RTPSession // SSRC -> Session
+	mtu          int                    // maximum RTP packet size for all sessions
+	mu           sync.RWMutex
+
+	// UDP ingest: client RTP address -> receiver
+	receivers      map[string]*rtpReceiver
+	droppedPackets atomic.Uint64 // received packets that were malformed or matched no receiver
 }
 
 // NewRTPSession creates a new RTP session
@@ -30,6 +45,7 @@ func NewRTPSession(ssrc uint32, payloadType uint8) *RTPSession {
 	return &RTPSession{
 		SSRC:        ssrc,
 		payloadType: payloadType,
+		mtu:         DefaultMTU,
 		active:      true,
 	}
 }
@@ -37,8 +53,29 @@ func NewRTPSession(ssrc uint32, payloadType uint8) *RTPSession {
 // NewRTPTransport creates a new RTP transport
 func NewRTPTransport() *RTPTransport {
 	return &RTPTransport{
-		sessions: make(map[uint32]*RTPSession),
+		sessions:  make(map[uint32]*RTPSession),
+		mtu:       DefaultMTU,
+		receivers: make(map[string]*rtpReceiver),
+	}
+}
+
+// SetMTU sets the maximum RTP packet size used by sessions created afterwards
+func (t *RTPTransport) SetMTU(mtu int) error {
+	if err := ValidateMTU(mtu); err != nil {
+		return err
 	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mtu = mtu
+	return nil
+}
+
+// GetMTU returns the maximum RTP packet size (use it to size packetizers)
+func (t *RTPTransport) GetMTU() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.mtu
 }
 
 // StartUDP starts UDP listener for RTP
@@ -49,12 +86,98 @@ func (t *RTPTransport) StartUDP(rtpPort int) error {
 	if err != nil {
 		return fmt.Errorf("failed to start RTP listener on %s: %v", rtpAddr, err)
 	}
+	t.mu.Lock()
 	t.rtpListener = rtpListener
+	t.mu.Unlock()
+	go t.readRTP(rtpListener)
+
+	// RTCP feedback (NACK) arrives on the port after RTP; without it, retransmission is unavailable
+	rtcpAddr := fmt.Sprintf(":%d", rtpListener.LocalAddr().(*net.UDPAddr).Port+1)
+	if rtcpListener, err := net.ListenPacket("udp", rtcpAddr); err != nil {
+		slog.Warn("RTCP listener not started, retransmission requests are ignored", "addr", rtcpAddr, "err", err)
+	} else {
+		t.mu.Lock()
+		t.rtcpListener = rtcpListener
+		t.mu.Unlock()
+		go t.readRTCP(rtcpListener)
+	}
 	
 	slog.Info("RTP transport started", "rtpPort", rtpPort)
 	return nil
 }
 
+// readRTCP handles RTCP packets from clients until the listener is closed
+func (t *RTPTransport) readRTCP(listener net.PacketConn) {
+	buf := make([]byte, MaxRTPPacketSize)
+	for {
+		n, from, err := listener.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		t.HandleRTCP(buf[:n], from)
+	}
+}
+
+// HandleRTCP processes a (compound) RTCP packet received from address from and
+// answers generic NACKs by retransmitting the lost packets on the RTX stream.
+// NACKs are only honored from the RTCP address registered for the session, so
+// other hosts cannot trigger retransmissions to its client
+func (t *RTPTransport) HandleRTCP(data []byte, from net.Addr) {
+	packets, err := splitRTCPCompound(data)
+	if err != nil {
+		slog.Debug("Invalid RTCP packet", "err", err)
+	}
+
+	for _, packet := range packets {
+		if packet[1] != RTCPTypeRTPFB || packet[0]&0x1F != RTCPFormatNACK {
+			continue
+		}
+		nack := &RTCPNack{}
+		if err := nack.Unmarshal(packet); err != nil {
+			slog.Debug("Invalid RTCP NACK", "err", err)
+			continue
+		}
+
+		session := t.GetSession(nack.MediaSSRC)
+		if session == nil {
+			continue
+		}
+		if !session.isClientRTCPAddr(from) {
+			slog.Debug("Ignoring RTCP NACK from unregistered address", "ssrc", nack.MediaSSRC, "from", from)
+			continue
+		}
+		t.mu.RLock()
+		listener := t.rtpListener
+		t.mu.RUnlock()
+		if listener == nil {
+			return
+		}
+		if _, err := session.Retransmit(nack.Lost, listener); err != nil {
+			slog.Debug("RTX retransmission failed", "ssrc", nack.MediaSSRC, "err", err)
+		}
+	}
+}
+
+// IsStarted returns true if the UDP listener is running
+func (t *RTPTransport) IsStarted() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rtpListener != nil
+}
+
+// Port returns the local UDP port of the RTP listener (0 if not started)
+func (t *RTPTransport) Port() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.rtpListener == nil {
+		return 0
+	}
+	if addr, ok := t.rtpListener.LocalAddr().(*net.UDPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
 // Stop stops the RTP transport
 func (t *RTPTransport) Stop() {
 	t.mu.Lock()
@@ -62,6 +185,11 @@ func (t *RTPTransport) Stop() {
 	
 	if t.rtpListener != nil {
 		t.rtpListener.Close()
+		t.rtpListener = nil
+	}
+	if t.rtcpListener != nil {
+		t.rtcpListener.Close()
+		t.rtcpListener = nil
 	}
 	
 	// Close all sessions
@@ -70,6 +198,7 @@ func (t *RTPTransport) Stop() {
 	}
 	
 	t.sessions = make(map[uint32]*RTPSession)
+	t.receivers = make(map[string]*rtpReceiver)
 	slog.Info("RTP transport stopped")
 }
 
@@ -79,14 +208,16 @@ func (t *RTPTransport) CreateSession(ssrc uint32, payloadType uint8, clientRTPPo
 	defer t.mu.Unlock()
 	
 	session := NewRTPSession(ssrc, payloadType)
+	session.mtu = t.mtu
 	
 	// Parse client address
-	clientRTPAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", clientIP, clientRTPPort))
+	clientRTPAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(clientIP, fmt.Sprint(clientRTPPort)))
 	if err != nil {
 		return nil, fmt.Errorf("invalid client RTP address: %v", err)
 	}
 	
 	session.clientRTPAddr = clientRTPAddr
+	session.clientRTCPAddr = &net.UDPAddr{IP: clientRTPAddr.IP, Port: clientRTPAddr.Port + 1, Zone: clientRTPAddr.Zone}
 	
 	// Store session
 	t.sessions[ssrc] = session
@@ -115,14 +246,41 @@ func (t *RTPTransport) RemoveSession(ssrc uint32) {
 	}
 }
 
+// CloseSession sends an RTCP BYE to the client and removes the RTP session,
+// so the client releases its resources instead of waiting for a timeout
+func (t *RTPTransport) CloseSession(ssrc uint32, reason string) {
+	session := t.GetSession(ssrc)
+	if session == nil {
+		return
+	}
+
+	// Stop clears the listener under the lock; a session closing meanwhile skips the BYE
+	t.mu.RLock()
+	listener := t.rtpListener
+	t.mu.RUnlock()
+	if listener != nil {
+		if err := session.SendBye(reason, listener); err != nil {
+			slog.Warn("Failed to send RTCP BYE", "ssrc", ssrc, "err", err)
+		}
+	}
+
+	t.RemoveSession(ssrc)
+}
+
 // SendRTPPacket sends an RTP packet to the client
 func (t *RTPTransport) SendRTPPacket(ssrc uint32, payload []byte, timestamp uint32, marker bool) error {
 	session := t.GetSession(ssrc)
 	if session == nil {
 		return fmt.Errorf("RTP session not found: %d", ssrc)
 	}
-	
-	return session.SendRTPPacket(payload, timestamp, marker, t.rtpListener)
+
+	t.mu.RLock()
+	listener := t.rtpListener
+	t.mu.RUnlock()
+	if listener == nil {
+		return fmt.Errorf("RTP transport is not listening")
+	}
+	return session.SendRTPPacket(payload, timestamp, marker, listener)
 }
 
 // SendRTPPacket sends an RTP packet
@@ -134,6 +292,12 @@ func (s *RTPSession) SendRTPPacket(payload []byte, timestamp uint32, marker bool
 		return fmt.Errorf("RTP session is not active")
 	}
 	
+	// Packets must be fragmented to the transport MTU before sending; a rejected
+	// packet must not use up a sequence number, or receivers would see a loss
+	if size := MinRTPHeaderSize + len(payload); size > s.mtu {
+		return fmt.Errorf("RTP packet exceeds MTU: %d bytes (mtu: %d)", size, s.mtu)
+	}
+
 	// Create RTP packet
 	seqNum := uint16(atomic.AddUint32(&s.sequenceNumber, 1))
 	packet := NewRTPPacket(s.payloadType, seqNum, timestamp, s.SSRC, payload)
@@ -150,11 +314,108 @@ func (s *RTPSession) SendRTPPacket(payload []byte, timestamp uint32, marker bool
 	if err != nil {
 		return fmt.Errorf("failed to send RTP packet: %v", err)
 	}
+
+	s.lastTimestamp = timestamp
+	if s.sentPackets != nil {
+		s.sentPackets.store(seqNum, data)
+	}
 	
 	slog.Debug("RTP packet sent", "ssrc", s.SSRC, "seq", seqNum, "ts", timestamp, "size", len(data))
 	return nil
 }
 
+// EnableRTX keeps the last cacheSize sent packets (0 = DefaultRTXCacheSize) so
+// NACKed ones can be retransmitted with payloadType on the RTX stream ssrc
+func (s *RTPSession) EnableRTX(payloadType uint8, ssrc uint32, cacheSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cacheSize <= 0 {
+		cacheSize = DefaultRTXCacheSize
+	}
+	s.rtxPayloadType = payloadType
+	s.rtxSSRC = ssrc
+	s.sentPackets = newPacketCache(cacheSize)
+}
+
+// isClientRTCPAddr reports whether addr is the RTCP address of the session's client
+func (s *RTPSession) isClientRTCPAddr(addr net.Addr) bool {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	return ok && s.clientRTCPAddr != nil && udpAddr.Port == s.clientRTCPAddr.Port && udpAddr.IP.Equal(s.clientRTCPAddr.IP)
+}
+
+// RTXEnabled returns true if the session retransmits NACKed packets
+func (s *RTPSession) RTXEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sentPackets != nil
+}
+
+// Retransmit resends the cached packets with the given sequence numbers on the
+// RTX stream and returns how many were sent; evicted packets are skipped
+func (s *RTPSession) Retransmit(sequences []uint16, listener net.PacketConn) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.active {
+		return 0, fmt.Errorf("RTP session is not active")
+	}
+	if s.sentPackets == nil {
+		return 0, fmt.Errorf("RTX is not enabled")
+	}
+
+	sent := 0
+	for _, seq := range sequences {
+		original, ok := s.sentPackets.get(seq)
+		if !ok {
+			slog.Debug("NACKed RTP packet not cached", "ssrc", s.SSRC, "seq", seq)
+			continue
+		}
+
+		data, err := newRTXPacket(original, s.rtxPayloadType, s.rtxSequence+1, s.rtxSSRC)
+		if err != nil {
+			return sent, fmt.Errorf("failed to build RTX packet: %v", err)
+		}
+		if len(data) > s.mtu {
+			slog.Debug("RTX packet exceeds MTU, not retransmitted", "ssrc", s.SSRC, "seq", seq, "size", len(data), "mtu", s.mtu)
+			continue
+		}
+		if _, err := listener.WriteTo(data, s.clientRTPAddr); err != nil {
+			return sent, fmt.Errorf("failed to send RTX packet: %v", err)
+		}
+		s.rtxSequence++
+		sent++
+	}
+
+	slog.Debug("RTP packets retransmitted", "ssrc", s.SSRC, "rtxSSRC", s.rtxSSRC, "requested", len(sequences), "sent", sent)
+	return sent, nil
+}
+
+// SendBye sends an RTCP BYE for this session's SSRC to the client RTCP port
+func (s *RTPSession) SendBye(reason string, listener net.PacketConn) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.active {
+		return fmt.Errorf("RTP session is not active")
+	}
+	if s.clientRTCPAddr == nil {
+		return fmt.Errorf("RTP session has no client RTCP address")
+	}
+
+	data, err := NewRTCPBye(reason, s.SSRC).Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal RTCP BYE: %v", err)
+	}
+
+	if _, err := listener.WriteTo(data, s.clientRTCPAddr); err != nil {
+		return fmt.Errorf("failed to send RTCP BYE: %v", err)
+	}
+
+	slog.Debug("RTCP BYE sent", "ssrc", s.SSRC, "reason", reason, "client", s.clientRTCPAddr)
+	return nil
+}
+
 // Close closes the RTP session
 func (s *RTPSession) Close() {
 	s.mu.Lock()
@@ -164,11 +425,28 @@ func (s *RTPSession) Close() {
 	slog.Info("RTP session closed", "ssrc", s.SSRC)
 }
 
+// ClientRTPAddr returns the client address RTP packets are sent to
+func (s *RTPSession) ClientRTPAddr() *net.UDPAddr {
+	return s.clientRTPAddr
+}
+
 // GetSSRC returns the SSRC
 func (s *RTPSession) GetSSRC() uint32 {
 	return s.SSRC
 }
 
+// NextSequenceNumber returns the sequence number of the next packet to be sent
+func (s *RTPSession) NextSequenceNumber() uint16 {
+	return uint16(atomic.LoadUint32(&s.sequenceNumber) + 1)
+}
+
+// LastTimestamp returns the RTP timestamp of the last packet sent (0 before the first)
+func (s *RTPSession) LastTimestamp() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastTimestamp
+}
+
 // GetPayloadType returns the payload type
 func (s *RTPSession) GetPayloadType() uint8 {
 	return s.payloadType
@@ -0,0 +1,194 @@
+package rtp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRTCPClient listens where the client RTCP port would be and returns the matching RTP port
+func newTestRTCPClient(t *testing.T) (net.PacketConn, int) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port - 1
+}
+
+func TestCloseSessionSendsBye(t *testing.T) {
+	transport := NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, rtpPort := newTestRTCPClient(t)
+	if _, err := transport.CreateSession(0x12345678, PayloadTypeH264, rtpPort, "127.0.0.1"); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	transport.CloseSession(0x12345678, "teardown")
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected RTCP BYE, got error: %v", err)
+	}
+
+	bye := &RTCPBye{}
+	if err := bye.Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Failed to parse RTCP BYE: %v", err)
+	}
+	if len(bye.SSRCs) != 1 || bye.SSRCs[0] != 0x12345678 {
+		t.Errorf("Expected SSRC 0x12345678, got %x", bye.SSRCs)
+	}
+	if transport.GetSession(0x12345678) != nil {
+		t.Error("Expected session to be removed after close")
+	}
+}
+
+func TestOversizedPacketKeepsSequence(t *testing.T) {
+	conn := &recordingPacketConn{}
+	transport := NewRTPTransport()
+	transport.rtpListener = conn
+	const ssrc = 0x12345678
+	session, err := transport.CreateSession(ssrc, PayloadTypeH264, 5000, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if err := transport.SendRTPPacket(ssrc, []byte{0x65}, 0, true); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+	if err := transport.SendRTPPacket(ssrc, make([]byte, session.mtu), 3000, true); err == nil {
+		t.Fatal("Expected a packet over the MTU to be rejected")
+	}
+	if err := transport.SendRTPPacket(ssrc, []byte{0x41}, 6000, true); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+
+	// The rejected packet was never sent, so the sequence shows no gap
+	if len(conn.packets) != 2 {
+		t.Fatalf("Expected 2 packets sent, got %d", len(conn.packets))
+	}
+	first, second := &RTPPacket{}, &RTPPacket{}
+	if err := first.Unmarshal(conn.packets[0]); err != nil {
+		t.Fatalf("Failed to parse packet: %v", err)
+	}
+	if err := second.Unmarshal(conn.packets[1]); err != nil {
+		t.Fatalf("Failed to parse packet: %v", err)
+	}
+	if second.Header.SequenceNumber != first.Header.SequenceNumber+1 {
+		t.Errorf("Expected consecutive sequence numbers, got %d then %d", first.Header.SequenceNumber, second.Header.SequenceNumber)
+	}
+}
+
+func TestCloseSessionWhileStopping(t *testing.T) {
+	// A session timing out while the server stops must not send on the cleared listener
+	for i := 0; i < 20; i++ {
+		transport := NewRTPTransport()
+		if err := transport.StartUDP(0); err != nil {
+			t.Fatalf("Failed to start transport: %v", err)
+		}
+		if _, err := transport.CreateSession(0x12345678, PayloadTypeH264, 5000, "127.0.0.1"); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			transport.CloseSession(0x12345678, "timeout")
+			close(done)
+		}()
+		transport.Stop()
+		<-done
+	}
+}
+
+// recordingPacketConn stands in for the transport's UDP listener and keeps every datagram sent
+type recordingPacketConn struct {
+	net.PacketConn
+	mu      sync.Mutex
+	packets [][]byte
+	addrs   []net.Addr
+}
+
+func (c *recordingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packets = append(c.packets, append([]byte(nil), p...))
+	c.addrs = append(c.addrs, addr)
+	return len(p), nil
+}
+
+func TestUDPSendCarriesFrameTimestampsAndMarkers(t *testing.T) {
+	conn := &recordingPacketConn{}
+	transport := NewRTPTransport()
+	transport.rtpListener = conn
+	const ssrc = 0x12345678
+	if _, err := transport.CreateSession(ssrc, PayloadTypeH264, 5000, "127.0.0.1"); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Three frames of several FU-A packets each, stamped the way a synthesized stream is
+	generator, err := NewVideoTimestampGenerator(30, 1, 1000)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	packetizer := NewH264Packetizer(200)
+	packetizer.SetTimestampGenerator(generator)
+	var frameSizes []int
+	for frame := 0; frame < 3; frame++ {
+		payloads, err := packetizer.PacketizeFrame([][]byte{append([]byte{0x65}, make([]byte, 1000)...)})
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		for _, payload := range payloads {
+			if err := transport.SendRTPPacket(ssrc, payload.Payload, payload.Timestamp, payload.Marker); err != nil {
+				t.Fatalf("Failed to send RTP packet: %v", err)
+			}
+		}
+		frameSizes = append(frameSizes, len(payloads))
+	}
+
+	var previous *RTPPacket
+	index := 0
+	for frame, size := range frameSizes {
+		for i := 0; i < size; i++ {
+			packet := &RTPPacket{}
+			if err := packet.Unmarshal(conn.packets[index]); err != nil {
+				t.Fatalf("Failed to parse packet %d: %v", index, err)
+			}
+			if addr := conn.addrs[index].String(); addr != "127.0.0.1:5000" {
+				t.Fatalf("Expected packet %d to go to 127.0.0.1:5000, got %s", index, addr)
+			}
+			if packet.Header.SSRC != ssrc {
+				t.Fatalf("Expected SSRC %#x, got %#x", ssrc, packet.Header.SSRC)
+			}
+			if previous != nil && packet.Header.SequenceNumber != previous.Header.SequenceNumber+1 {
+				t.Fatalf("Expected sequence %d after %d", previous.Header.SequenceNumber+1, previous.Header.SequenceNumber)
+			}
+			if expected := uint32(1000 + frame*3000); packet.Header.Timestamp != expected {
+				t.Fatalf("Expected frame %d packet at %d, got %d", frame, expected, packet.Header.Timestamp)
+			}
+			if last := i == size-1; packet.Header.Marker != last {
+				t.Fatalf("Expected marker %t on packet %d of frame %d", last, i, frame)
+			}
+			previous = packet
+			index++
+		}
+	}
+	if index != len(conn.packets) || frameSizes[0] < 2 {
+		t.Fatalf("Expected several packets per frame, sent %d for frames %v", len(conn.packets), frameSizes)
+	}
+	session := transport.GetSession(ssrc)
+	if next := session.NextSequenceNumber(); next != previous.Header.SequenceNumber+1 {
+		t.Errorf("Expected next sequence %d, got %d", previous.Header.SequenceNumber+1, next)
+	}
+	if last := session.LastTimestamp(); last != 7000 {
+		t.Errorf("Expected last timestamp 7000, got %d", last)
+	}
+}
@@ -0,0 +1,73 @@
+package rtp
+
+import (
+	"errors"
+	"sol/pkg/codec"
+)
+
+// ErrInvalidRate is returned for a zero clock rate or media rate
+var ErrInvalidRate = errors.New("rtp: clock rate and media rate must be positive")
+
+// ErrNoTimestampGenerator is returned when a packetizer is asked to stamp a
+// locally built frame without a timestamp generator
+var ErrNoTimestampGenerator = errors.New("rtp: packetizer has no timestamp generator")
+
+// TimestampGenerator maps positions on a media clock to RTP timestamps for
+// synthesized streams. Positions are counted in media units (audio samples or
+// video frames) at a rational rate, e.g. 44100/1 samples or 30000/1001 frames
+// per second, and every timestamp is computed from the position rather than
+// accumulated, so fractional frame durations never drift. Timestamps wrap at
+// 2^32 as RTP requires.
+type TimestampGenerator struct {
+	clockRate uint64 // RTP clock ticks per second
+	rateNum   uint64 // media units per second = rateNum/rateDen
+	rateDen   uint64
+	offset    uint32 // RTP timestamp of position 0 (random per RFC 3550 section 5.1)
+	position  uint64 // media units generated so far
+}
+
+// NewTimestampGenerator creates a generator for an RTP clock of clockRate ticks
+// per second whose media position advances at rateNum/rateDen units per second
+func NewTimestampGenerator(clockRate, rateNum, rateDen, offset uint32) (*TimestampGenerator, error) {
+	if clockRate == 0 || rateNum == 0 || rateDen == 0 {
+		return nil, ErrInvalidRate
+	}
+	return &TimestampGenerator{
+		clockRate: uint64(clockRate),
+		rateNum:   uint64(rateNum),
+		rateDen:   uint64(rateDen),
+		offset:    offset,
+	}, nil
+}
+
+// NewVideoTimestampGenerator creates a generator counting video frames at
+// frameRateNum/frameRateDen fps on the 90kHz video clock
+func NewVideoTimestampGenerator(frameRateNum, frameRateDen, offset uint32) (*TimestampGenerator, error) {
+	return NewTimestampGenerator(uint32(codec.H264.RTPClockRate()), frameRateNum, frameRateDen, offset)
+}
+
+// NewAudioTimestampGenerator creates a generator counting audio samples. AAC
+// (RFC 3640) is clocked at its sample rate, so one sample is one tick.
+func NewAudioTimestampGenerator(sampleRate, offset uint32) (*TimestampGenerator, error) {
+	return NewTimestampGenerator(sampleRate, sampleRate, 1, offset)
+}
+
+// TimestampAt returns the RTP timestamp of a media position
+func (g *TimestampGenerator) TimestampAt(position uint64) uint32 {
+	ticks := position * g.clockRate * g.rateDen / g.rateNum
+	return g.offset + uint32(ticks) // wraps modulo 2^32
+}
+
+// Next returns the timestamp of the current position and advances it by units
+// (1 for a video frame, the samples per frame for audio, e.g. 1024 for AAC).
+// Every packet the packetizer produces for that frame carries this timestamp.
+func (g *TimestampGenerator) Next(units uint64) uint32 {
+	timestamp := g.TimestampAt(g.position)
+	g.position += units
+	return timestamp
+}
+
+// Position returns the number of media units generated so far
+func (g *TimestampGenerator) Position() uint64 {
+	return g.position
+}
@@ -0,0 +1,134 @@
+package rtp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVideoTimestampProgression(t *testing.T) {
+	tests := []struct {
+		name         string
+		rateNum      uint32
+		rateDen      uint32
+		frames       uint64
+		expectedLast uint32
+		frameTicks   []uint32 // expected timestamps of the first frames
+	}{
+		{"25 fps", 25, 1, 25, 90000, []uint32{0, 3600, 7200}},
+		{"30 fps", 30, 1, 30, 90000, []uint32{0, 3000, 6000}},
+		{"29.97 fps", 30000, 1001, 30000, 90090000, []uint32{0, 3003, 6006}},
+		// 3753.75 ticks per frame: the fraction is carried, not accumulated
+		{"23.976 fps", 24000, 1001, 4, 15015, []uint32{0, 3753, 7507, 11261}},
+	}
+
+	for _, tt := range tests {
+		g, err := NewVideoTimestampGenerator(tt.rateNum, tt.rateDen, 0)
+		if err != nil {
+			t.Fatalf("%s: Failed to create generator: %v", tt.name, err)
+		}
+		for i, expected := range tt.frameTicks {
+			if timestamp := g.Next(1); timestamp != expected {
+				t.Errorf("%s: Expected frame %d at %d, got %d", tt.name, i, expected, timestamp)
+			}
+		}
+		if timestamp := g.TimestampAt(tt.frames); timestamp != tt.expectedLast {
+			t.Errorf("%s: Expected frame %d at %d, got %d", tt.name, tt.frames, tt.expectedLast, timestamp)
+		}
+	}
+}
+
+func TestAudioTimestampProgression(t *testing.T) {
+	g, err := NewAudioTimestampGenerator(44100, 1000)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	// One AAC frame is 1024 samples at the sample-rate clock
+	for i := uint32(0); i < 5; i++ {
+		if timestamp := g.Next(1024); timestamp != 1000+i*1024 {
+			t.Fatalf("Expected frame %d at %d, got %d", i, 1000+i*1024, timestamp)
+		}
+	}
+	if g.Position() != 5*1024 {
+		t.Fatalf("Expected position %d, got %d", 5*1024, g.Position())
+	}
+}
+
+func TestTimestampWrapsAt32Bits(t *testing.T) {
+	g, err := NewVideoTimestampGenerator(30, 1, 1<<32-3000)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	expected := []uint32{1<<32 - 3000, 0, 3000}
+	for i, want := range expected {
+		if timestamp := g.Next(1); timestamp != want {
+			t.Fatalf("Expected frame %d at %d, got %d", i, want, timestamp)
+		}
+	}
+
+	// Far past the first wrap (about 13 hours of 90kHz ticks per wrap)
+	const frames = 3 * (1 << 32) / 3000
+	if timestamp := g.TimestampAt(frames); timestamp != uint32((frames*3000+(1<<32-3000))%(1<<32)) {
+		t.Fatalf("Expected timestamp modulo 2^32, got %d", timestamp)
+	}
+}
+
+func TestTimestampsForPacketizedAccessUnits(t *testing.T) {
+	g, err := NewVideoTimestampGenerator(30, 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	packetizer := NewH264Packetizer(200)
+	if _, err := packetizer.PacketizeFrame([][]byte{{0x65}}); !errors.Is(err, ErrNoTimestampGenerator) {
+		t.Fatalf("Expected ErrNoTimestampGenerator without a generator, got %v", err)
+	}
+	packetizer.SetTimestampGenerator(g)
+
+	// All packets of an access unit share its timestamp, the next one is a frame later
+	for frame := uint32(0); frame < 3; frame++ {
+		payloads, err := packetizer.PacketizeFrame([][]byte{append([]byte{0x65}, make([]byte, 1000)...)})
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		if len(payloads) < 2 {
+			t.Fatalf("Expected the frame to be fragmented, got %d payloads", len(payloads))
+		}
+		for i, payload := range payloads {
+			if payload.Timestamp != frame*3000 {
+				t.Fatalf("Expected frame %d packets at %d, got %d", frame, frame*3000, payload.Timestamp)
+			}
+			if payload.Marker != (i == len(payloads)-1) {
+				t.Fatalf("Expected the marker only on the last payload of frame %d", frame)
+			}
+		}
+	}
+}
+
+func TestTimestampsForPacketizedAACFrames(t *testing.T) {
+	g, err := NewAudioTimestampGenerator(48000, 0)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	packetizer := NewAACPacketizer(1200)
+	packetizer.SetTimestampGenerator(g)
+
+	for frame := uint32(0); frame < 3; frame++ {
+		payloads, err := packetizer.PacketizeFrame(make([]byte, 300))
+		if err != nil {
+			t.Fatalf("Failed to packetize: %v", err)
+		}
+		if len(payloads) != 1 || payloads[0].Timestamp != frame*1024 || !payloads[0].Marker {
+			t.Fatalf("Expected frame %d in one marked payload at %d, got %+v", frame, frame*1024, payloads)
+		}
+	}
+}
+
+func TestTimestampGeneratorRejectsZeroRates(t *testing.T) {
+	if _, err := NewTimestampGenerator(90000, 0, 1, 0); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("Expected ErrInvalidRate for a zero frame rate, got %v", err)
+	}
+	if _, err := NewAudioTimestampGenerator(0, 0); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("Expected ErrInvalidRate for a zero sample rate, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RTCP packet types 200-204 read as payload types 72-76 with the marker bit
+// set; RFC 5761 reserves that range so RTP and RTCP can be told apart
+const (
+	firstRTCPConflictPayloadType = 72
+	lastRTCPConflictPayloadType  = 76
+)
+
+// ValidateRTPPacket checks that data received from a client is a plausible
+// RTP packet: version 2, a payload type outside the RTCP range, and CSRCs,
+// header extension and padding that fit in the packet. It returns the parsed header
+func ValidateRTPPacket(data []byte) (*RTPHeader, error) {
+	if len(data) < MinRTPHeaderSize {
+		return nil, fmt.Errorf("RTP packet too short: %d bytes (min: %d)", len(data), MinRTPHeaderSize)
+	}
+
+	packet := &RTPPacket{}
+	if err := packet.Unmarshal(data[:MinRTPHeaderSize]); err != nil {
+		return nil, err
+	}
+	header := packet.Header
+	if header.Version != 2 {
+		return nil, fmt.Errorf("unsupported RTP version: %d", header.Version)
+	}
+	if header.PayloadType >= firstRTCPConflictPayloadType && header.PayloadType <= lastRTCPConflictPayloadType {
+		return nil, fmt.Errorf("payload type %d is reserved for RTCP", header.PayloadType)
+	}
+
+	headerSize := MinRTPHeaderSize + 4*int(header.CSRCCount)
+	if header.Extension {
+		if len(data) < headerSize+4 {
+			return nil, fmt.Errorf("RTP header extension truncated: %d bytes", len(data))
+		}
+		headerSize += 4 + 4*int(binary.BigEndian.Uint16(data[headerSize+2:]))
+	}
+	if headerSize > len(data) {
+		return nil, fmt.Errorf("RTP header (%d bytes) exceeds packet size %d", headerSize, len(data))
+	}
+	if header.Padding {
+		padding := int(data[len(data)-1])
+		if padding == 0 || headerSize+padding > len(data) {
+			return nil, fmt.Errorf("invalid RTP padding: %d bytes after a %d-byte header in %d bytes", padding, headerSize, len(data))
+		}
+	}
+
+	return header, nil
+}
@@ -0,0 +1,48 @@
+package rtp
+
+import (
+	"testing"
+)
+
+func TestValidateRTPPacket(t *testing.T) {
+	valid, err := NewRTPPacket(PayloadTypeH264, 7, 3000, 0x1234, []byte{0x65, 0x88}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	header, err := ValidateRTPPacket(valid)
+	if err != nil {
+		t.Fatalf("Expected valid RTP packet, got %v", err)
+	}
+	if header.PayloadType != PayloadTypeH264 || header.SequenceNumber != 7 || header.Timestamp != 3000 || header.SSRC != 0x1234 {
+		t.Errorf("Unexpected header %+v", header)
+	}
+
+	withCSRC := append([]byte{0x81}, valid[1:12]...)
+	withCSRC = append(withCSRC, 0, 0, 0, 1, 0x65)
+	if _, err := ValidateRTPPacket(withCSRC); err != nil {
+		t.Errorf("Expected packet with one CSRC to be valid, got %v", err)
+	}
+
+	modify := func(f func(p []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", valid[:11]},
+		{"version 1", modify(func(p []byte) []byte { p[0] = 0x40; return p })},
+		{"RTCP sender report", modify(func(p []byte) []byte { p[1] = 200; return p })},
+		{"CSRCs past the end", modify(func(p []byte) []byte { p[0] |= 0x03; return p })},
+		{"truncated extension", modify(func(p []byte) []byte { p[0] |= 0x10; return p })},
+		{"extension past the end", modify(func(p []byte) []byte { p[0] |= 0x10; return append(p, 0xBE, 0xDE, 0, 4) })},
+		{"zero padding", modify(func(p []byte) []byte { p[0] |= 0x20; p[len(p)-1] = 0; return p })},
+		{"padding past the header", modify(func(p []byte) []byte { p[0] |= 0x20; p[len(p)-1] = 3; return p })},
+		{"text", []byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n")},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateRTPPacket(tt.data); err == nil {
+			t.Errorf("%s: expected packet to be rejected", tt.name)
+		}
+	}
+}
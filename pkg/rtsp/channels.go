@@ -0,0 +1,100 @@
+package rtsp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxInterleavedChannel is the highest channel of the one-byte interleaved frame header
+const maxInterleavedChannel = 255
+
+// ErrNoInterleavedChannel is returned when no channel pair is left for a TCP track
+var ErrNoInterleavedChannel = errors.New("no free interleaved channel pair")
+
+// ChannelAssignment decides which interleaved channel pair SETUP gives a TCP track
+type ChannelAssignment int
+
+const (
+	ChannelsFromClient ChannelAssignment = iota
+	ChannelsByTrack
+)
+
+// ParseChannelAssignment converts a config value ("client" or "track") to a ChannelAssignment
+func ParseChannelAssignment(value string) (ChannelAssignment, error) {
+	switch value {
+	case ChannelAssignmentClient:
+		return ChannelsFromClient, nil
+	case ChannelAssignmentTrack:
+		return ChannelsByTrack, nil
+	default:
+		return ChannelsFromClient, fmt.Errorf("invalid interleaved channel assignment: %s (must be %s or %s)", value, ChannelAssignmentClient, ChannelAssignmentTrack)
+	}
+}
+
+// String returns the config value of the channel assignment
+func (a ChannelAssignment) String() string {
+	switch a {
+	case ChannelsByTrack:
+		return ChannelAssignmentTrack
+	default:
+		return ChannelAssignmentClient
+	}
+}
+
+// channelAllocator hands out the interleaved channels of a session's tracks:
+// each track gets an RTP channel and the RTCP channel after it, and no two
+// tracks share a channel
+type channelAllocator struct {
+	assignment  ChannelAssignment
+	rtpChannels map[TrackType]int // RTP channel of each track with a pair
+}
+
+func newChannelAllocator(assignment ChannelAssignment) *channelAllocator {
+	return &channelAllocator{
+		assignment:  assignment,
+		rtpChannels: make(map[TrackType]int),
+	}
+}
+
+// allocate assigns trackType a channel pair and returns its RTP channel.
+// requested is the RTP channel from the client's Transport header (-1 if none);
+// without one, or if it overlaps another track, the track's own pair (video
+// 0-1, audio 2-3) is used, and if that is taken too the lowest free pair.
+func (a *channelAllocator) allocate(trackType TrackType, requested int) (int, error) {
+	delete(a.rtpChannels, trackType) // a track set up again gives up its previous pair
+
+	trackPair := 2 * int(trackType)
+	candidates := []int{trackPair}
+	if a.assignment == ChannelsFromClient && requested >= 0 {
+		candidates = []int{requested, trackPair}
+	}
+	for channel := 0; channel < maxInterleavedChannel; channel += 2 {
+		candidates = append(candidates, channel)
+	}
+
+	for _, channel := range candidates {
+		if a.free(channel) {
+			a.rtpChannels[trackType] = channel
+			return channel, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %d tracks set up", ErrNoInterleavedChannel, len(a.rtpChannels))
+}
+
+// free reports whether channel and channel+1 can be given to a track
+func (a *channelAllocator) free(channel int) bool {
+	if channel < 0 || channel+1 > maxInterleavedChannel {
+		return false
+	}
+	for _, used := range a.rtpChannels {
+		if channel+1 >= used && channel <= used+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// reset releases every pair (TEARDOWN)
+func (a *channelAllocator) reset() {
+	a.rtpChannels = make(map[TrackType]int)
+}
@@ -0,0 +1,94 @@
+package rtsp
+
+import (
+	"strings"
+	"testing"
+)
+
+// setupWithTransport sends a SETUP for one track and returns the Transport header of the response
+func setupWithTransport(t *testing.T, session *Session, conn *bufferConn, control, transport string) string {
+	t.Helper()
+	req := NewRequest(MethodSetup, "rtsp://localhost/live/test/"+control)
+	req.SetCSeq(session.cseq + 1)
+	req.SetHeader(HeaderTransport, transport)
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	response := readResponse(t, conn)
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for SETUP, got %d", response.StatusCode)
+	}
+	conn.buf.Reset()
+	return response.GetHeader(HeaderTransport)
+}
+
+func TestInterleavedSetupAssignsDistinctChannelPairs(t *testing.T) {
+	tests := []struct {
+		name                 string
+		assignment           ChannelAssignment
+		videoRequest         string
+		audioRequest         string
+		videoPair, audioPair string
+		videoRTP, audioRTP   int
+	}{
+		{"no channels requested", ChannelsFromClient, "", "", "interleaved=0-1", "interleaved=2-3", 0, 2},
+		{"same pair requested twice", ChannelsFromClient, ";interleaved=0-1", ";interleaved=0-1", "interleaved=0-1", "interleaved=2-3", 0, 2},
+		{"overlapping pair requested", ChannelsFromClient, ";interleaved=2-3", ";interleaved=3-4", "interleaved=2-3", "interleaved=0-1", 2, 0},
+		{"free pairs honored", ChannelsFromClient, ";interleaved=10-11", ";interleaved=6-7", "interleaved=10-11", "interleaved=6-7", 10, 6},
+		{"fixed pairs by track", ChannelsByTrack, ";interleaved=4-5", ";interleaved=0-1", "interleaved=0-1", "interleaved=2-3", 0, 2},
+	}
+	for _, tt := range tests {
+		session, conn, _ := newTestSession()
+		session.channels = newChannelAllocator(tt.assignment)
+
+		video := setupWithTransport(t, session, conn, "track1", "RTP/AVP/TCP;unicast"+tt.videoRequest)
+		audio := setupWithTransport(t, session, conn, "track2", "RTP/AVP/TCP;unicast"+tt.audioRequest)
+
+		for _, c := range []struct{ transport, pair string }{{video, tt.videoPair}, {audio, tt.audioPair}} {
+			if strings.Count(c.transport, "interleaved=") != 1 || !strings.Contains(c.transport, ";"+c.pair+";") {
+				t.Errorf("%s: expected %s once in Transport, got %q", tt.name, c.pair, c.transport)
+			}
+		}
+		if session.tracks[TrackVideo].rtpChannel != tt.videoRTP || session.tracks[TrackAudio].rtpChannel != tt.audioRTP {
+			t.Errorf("%s: expected RTP channels %d and %d, got %d and %d", tt.name, tt.videoRTP, tt.audioRTP,
+				session.tracks[TrackVideo].rtpChannel, session.tracks[TrackAudio].rtpChannel)
+		}
+
+		// Each channel leads to the track that owns it
+		if trackType, _, ok := session.trackForChannel(tt.audioRTP); !ok || trackType != TrackAudio {
+			t.Errorf("%s: expected channel %d to carry the audio track", tt.name, tt.audioRTP)
+		}
+	}
+}
+
+func TestChannelAllocatorReleasesPairs(t *testing.T) {
+	channels := newChannelAllocator(ChannelsFromClient)
+	if channel, err := channels.allocate(TrackVideo, 255); err != nil || channel != 0 {
+		t.Fatalf("Expected an RTCP channel past 255 to fall back to 0, got %d, %v", channel, err)
+	}
+
+	// Setting the track up again gives up its previous pair
+	if channel, err := channels.allocate(TrackVideo, 6); err != nil || channel != 6 {
+		t.Fatalf("Expected video to move to 6, got %d, %v", channel, err)
+	}
+	if channel, err := channels.allocate(TrackAudio, 0); err != nil || channel != 0 {
+		t.Fatalf("Expected audio to take the released pair 0, got %d, %v", channel, err)
+	}
+
+	channels.reset()
+	if channel, err := channels.allocate(TrackAudio, 7); err != nil || channel != 7 {
+		t.Fatalf("Expected every pair to be free after reset, got %d, %v", channel, err)
+	}
+}
+
+func TestParseChannelAssignment(t *testing.T) {
+	for _, assignment := range []ChannelAssignment{ChannelsFromClient, ChannelsByTrack} {
+		parsed, err := ParseChannelAssignment(assignment.String())
+		if err != nil || parsed != assignment {
+			t.Errorf("Expected %s to round-trip, got %v, %v", assignment, parsed, err)
+		}
+	}
+	if _, err := ParseChannelAssignment("random"); err == nil {
+		t.Error("Expected an unknown channel assignment to be rejected")
+	}
+}
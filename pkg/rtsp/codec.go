@@ -0,0 +1,261 @@
+package rtsp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sol/pkg/codec"
+	"sol/pkg/rtp"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedCodec is returned when a stream's codec cannot be described in SDP
+var ErrUnsupportedCodec = errors.New("unsupported codec")
+
+// Default audio parameters when the metadata does not carry them
+const (
+	DefaultAudioSampleRate = 48000
+	DefaultAudioChannels   = 2
+)
+
+// MetadataParameterSets is the metadata key carrying the H.264
+// sprop-parameter-sets (base64 SPS and PPS, comma separated)
+const MetadataParameterSets = "sprop-parameter-sets"
+
+// StreamCodecs holds the codecs detected for a stream (empty if the track is absent)
+type StreamCodecs struct {
+	Video           codec.Codec
+	Audio           codec.Codec
+	AudioSampleRate int
+	AudioChannels   int
+
+	VideoParameterSets string // H.264 sprop-parameter-sets, "" until known
+}
+
+// DefaultStreamCodecs returns the codecs advertised when nothing is known about a stream
+func DefaultStreamCodecs() StreamCodecs {
+	return StreamCodecs{
+		Video:           codec.H264,
+		Audio:           codec.AAC,
+		AudioSampleRate: DefaultAudioSampleRate,
+		AudioChannels:   DefaultAudioChannels,
+	}
+}
+
+// CodecsFromMetadata detects stream codecs from onMetaData-style metadata
+// (videocodecid, audiocodecid, audiosamplerate, audiochannels/stereo and
+// the H.264 parameter sets).
+// Tracks without a codec id are left out; unknown codec ids and codecs
+// without an RTP payload format are an error.
+func CodecsFromMetadata(metadata map[string]any) (StreamCodecs, error) {
+	var codecs StreamCodecs
+
+	if id, ok := metadata["videocodecid"]; ok {
+		video, err := lookupCodec(id, codec.FromFLVVideoID)
+		if err != nil {
+			return codecs, fmt.Errorf("video: %w", err)
+		}
+		codecs.Video = video
+		if sprop, ok := metadata[MetadataParameterSets].(string); ok && video == codec.H264 {
+			codecs.VideoParameterSets = sprop
+		}
+	}
+
+	if id, ok := metadata["audiocodecid"]; ok {
+		audio, err := lookupCodec(id, codec.FromFLVAudioID)
+		if err != nil {
+			return codecs, fmt.Errorf("audio: %w", err)
+		}
+		codecs.Audio = audio
+
+		codecs.AudioSampleRate = DefaultAudioSampleRate
+		if rate, ok := metadata["audiosamplerate"].(float64); ok && rate > 0 {
+			codecs.AudioSampleRate = int(rate)
+		}
+		codecs.AudioChannels = DefaultAudioChannels
+		if channels, ok := metadata["audiochannels"].(float64); ok && channels > 0 {
+			codecs.AudioChannels = int(channels)
+		} else if stereo, ok := metadata["stereo"].(bool); ok && !stereo {
+			codecs.AudioChannels = 1
+		}
+	}
+
+	if codecs.Video == "" && codecs.Audio == "" {
+		return codecs, fmt.Errorf("%w: no codec in metadata", ErrUnsupportedCodec)
+	}
+	return codecs, nil
+}
+
+// lookupCodec maps a numeric FLV codec id, a FourCC string or a codec
+// detected from an SDP to a codec that RTP can carry
+func lookupCodec(id any, fromFLVID func(uint8) codec.Codec) (codec.Codec, error) {
+	detected := codec.Unknown
+	switch v := id.(type) {
+	case codec.Codec:
+		detected = v
+	case float64:
+		if v >= 0 && v <= 255 && v == float64(uint8(v)) {
+			detected = fromFLVID(uint8(v))
+		}
+	case string:
+		detected = codec.FromFourCC(v)
+	}
+	if _, ok := rtp.PayloadTypeForCodec(detected); !ok {
+		return codec.Unknown, fmt.Errorf("%w: %v", ErrUnsupportedCodec, id)
+	}
+	return detected, nil
+}
+
+// aacSampleRates are the sampling frequencies indexed by AudioSpecificConfig
+var aacSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// aacConfig returns the hex AudioSpecificConfig for AAC-LC (RFC 3640 "config" parameter)
+func aacConfig(sampleRate, channels int) (string, error) {
+	for index, rate := range aacSampleRates {
+		if rate == sampleRate {
+			config := 2<<11 | index<<7 | channels<<3 // object type 2 = AAC LC
+			return fmt.Sprintf("%04X", config), nil
+		}
+	}
+	return "", fmt.Errorf("%w: AAC sample rate %d", ErrUnsupportedCodec, sampleRate)
+}
+
+// metadataFromSDP describes an announced SDP as stream metadata: the codec of
+// the first format of each media, the audio sample rate and channels from its
+// rtpmap and the H.264 sprop-parameter-sets. Media with a codec RTP cannot
+// relay are kept as codec.Unknown so describing the stream fails instead of
+// advertising the wrong codec
+func metadataFromSDP(sdp *SDP) map[string]any {
+	metadata := make(map[string]any)
+	for _, media := range sdp.Media {
+		if len(media.Formats) == 0 {
+			continue
+		}
+		pt, err := strconv.ParseUint(media.Formats[0], 10, 7)
+		if err != nil {
+			continue
+		}
+		encoding := media.RTPMap(int(pt))
+		detected := rtp.CodecForPayloadType(uint8(pt), encoding)
+
+		switch media.Type {
+		case "video":
+			if _, exists := metadata["videocodecid"]; exists {
+				continue
+			}
+			metadata["videocodecid"] = detected
+			if detected == codec.H264 {
+				if sprop, ok := fmtpParameter(media.Fmtp(int(pt)), "sprop-parameter-sets"); ok && sprop != "" {
+					metadata[MetadataParameterSets] = sprop
+				}
+			}
+		case "audio":
+			if _, exists := metadata["audiocodecid"]; exists {
+				continue
+			}
+			metadata["audiocodecid"] = detected
+			if rate, ok := media.ClockRate(int(pt)); ok && rate > 0 {
+				metadata["audiosamplerate"] = float64(rate)
+			}
+			parts := strings.Split(encoding, "/")
+			if len(parts) == 3 {
+				if channels, err := strconv.Atoi(parts[2]); err == nil && channels > 0 {
+					metadata["audiochannels"] = float64(channels)
+				}
+			}
+		}
+	}
+	return metadata
+}
+
+// rtpMap returns the rtpmap encoding of a codec with a fixed clock rate
+func rtpMap(c codec.Codec) string {
+	return fmt.Sprintf("%s/%d", c.RTPEncodingName(), c.RTPClockRate())
+}
+
+// addVideoMedia adds the m=video section for the video codec. H.264 parameter
+// sets are only advertised once known; without them players take SPS and PPS
+// from the stream (RFC 6184 section 8.1)
+func addVideoMedia(sdp *SDP, codecs StreamCodecs) error {
+	video := codecs.Video
+	pt, ok := rtp.PayloadTypeForCodec(video)
+	payloadType := int(pt)
+	switch {
+	case video == codec.H264:
+		fmtp := "packetization-mode=1"
+		if codecs.VideoParameterSets != "" {
+			fmtp += ";sprop-parameter-sets=" + codecs.VideoParameterSets
+		}
+		sdp.AddMedia("video", 0, "RTP/AVP", payloadType).
+			SetConnection(sdp.Connection).
+			SetBandwidth("AS:500").
+			AddRTPMap(payloadType, rtpMap(video)).
+			AddFmtp(payloadType, fmtp).
+			SetControl("track1")
+	case ok && video.IsVideo():
+		sdp.AddMedia("video", 0, "RTP/AVP", payloadType).
+			SetConnection(sdp.Connection).
+			SetBandwidth("AS:500").
+			AddRTPMap(payloadType, rtpMap(video)).
+			SetControl("track1")
+	default:
+		return fmt.Errorf("%w: video %s", ErrUnsupportedCodec, video)
+	}
+	return nil
+}
+
+// addRTXFormats offers RTX retransmission for the first payload type of each
+// media section, unless the media already has an RTX format or uses its RTX payload type
+func addRTXFormats(sdp *SDP) {
+	for _, media := range sdp.Media {
+		if len(media.Formats) == 0 {
+			continue
+		}
+		pt, err := strconv.ParseUint(media.Formats[0], 10, 7)
+		if err != nil {
+			continue
+		}
+		rtxPT, ok := rtp.RTXPayloadTypeFor(uint8(pt))
+		if !ok || slices.Contains(media.Formats, strconv.Itoa(int(rtxPT))) {
+			continue
+		}
+		if _, exists := media.RTXPayloadType(int(pt)); exists {
+			continue
+		}
+
+		clockRate, ok := media.ClockRate(int(pt))
+		if !ok {
+			continue
+		}
+		media.AddRTX(int(rtxPT), int(pt), clockRate)
+	}
+}
+
+// addAudioMedia adds the m=audio section for the audio codec; G.711 uses its
+// static payload type, other codecs the dynamic one of the audio track
+func addAudioMedia(sdp *SDP, codecs StreamCodecs) error {
+	pt, ok := rtp.PayloadTypeForCodec(codecs.Audio)
+	payloadType := int(pt)
+	switch {
+	case codecs.Audio == codec.AAC:
+		config, err := aacConfig(codecs.AudioSampleRate, codecs.AudioChannels)
+		if err != nil {
+			return err
+		}
+		sdp.AddMedia("audio", 0, "RTP/AVP", payloadType).
+			SetConnection(sdp.Connection).
+			SetBandwidth("AS:128").
+			AddRTPMap(payloadType, fmt.Sprintf("%s/%d/%d", codecs.Audio.RTPEncodingName(), codecs.AudioSampleRate, codecs.AudioChannels)).
+			AddFmtp(payloadType, "streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config="+config).
+			SetControl("track2")
+	case ok && codecs.Audio.IsAudio():
+		sdp.AddMedia("audio", 0, "RTP/AVP", payloadType).
+			SetConnection(sdp.Connection).
+			AddRTPMap(payloadType, rtpMap(codecs.Audio)).
+			SetControl("track2")
+	default:
+		return fmt.Errorf("%w: audio %s", ErrUnsupportedCodec, codecs.Audio)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package rtsp
+
+import (
+	"errors"
+	"sol/pkg/codec"
+	"strings"
+	"testing"
+)
+
+// describeWithMetadata sends DESCRIBE for a stream carrying the given metadata
+func describeWithMetadata(t *testing.T, metadata map[string]any) *Response {
+	t.Helper()
+	manager := NewStreamManager()
+	manager.GetOrCreateStream("rtsp://localhost/live/test").SetMetadata(metadata)
+
+	session, conn, _ := newTestSession()
+	session.streamManager = manager
+	if err := session.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	return readResponse(t, conn)
+}
+
+func TestCodecsFromMetadataH264AAC(t *testing.T) {
+	codecs, err := CodecsFromMetadata(map[string]any{
+		"videocodecid":    7.0,
+		"audiocodecid":    10.0,
+		"audiosamplerate": 44100.0,
+		"stereo":          false,
+	})
+	if err != nil {
+		t.Fatalf("Failed to detect codecs: %v", err)
+	}
+
+	expected := StreamCodecs{Video: codec.H264, Audio: codec.AAC, AudioSampleRate: 44100, AudioChannels: 1}
+	if codecs != expected {
+		t.Errorf("Expected %+v, got %+v", expected, codecs)
+	}
+}
+
+func TestCodecsFromMetadataFourCC(t *testing.T) {
+	codecs, err := CodecsFromMetadata(map[string]any{"videocodecid": "hvc1"})
+	if err != nil {
+		t.Fatalf("Failed to detect codecs: %v", err)
+	}
+	if codecs.Video != codec.H265 || codecs.Audio != codec.Unknown {
+		t.Errorf("Expected H265 video only, got %+v", codecs)
+	}
+}
+
+func TestDescribeH264AACStream(t *testing.T) {
+	response := describeWithMetadata(t, map[string]any{
+		"videocodecid":    7.0,
+		"audiocodecid":    10.0,
+		"audiosamplerate": 44100.0,
+	})
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	sdp := string(response.Body)
+	for _, line := range []string{
+		"m=video 0 RTP/AVP 96\r\n",
+		"a=rtpmap:96 H264/90000\r\n",
+		"m=audio 0 RTP/AVP 97\r\n",
+		"a=rtpmap:97 MPEG4-GENERIC/44100/2\r\n",
+		"config=1210\r\n",
+	} {
+		if !strings.Contains(sdp, line) {
+			t.Errorf("Expected SDP to contain %q:\n%s", line, sdp)
+		}
+	}
+}
+
+func TestDescribeG711Stream(t *testing.T) {
+	response := describeWithMetadata(t, map[string]any{"audiocodecid": 7.0})
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	sdp := string(response.Body)
+	if strings.Contains(sdp, "m=video") {
+		t.Error("Expected no video media for an audio-only stream")
+	}
+	if !strings.Contains(sdp, "m=audio 0 RTP/AVP 8\r\n") || !strings.Contains(sdp, "a=rtpmap:8 PCMA/8000\r\n") {
+		t.Errorf("Expected static PCMA payload type:\n%s", sdp)
+	}
+}
+
+func TestDescribeUnsupportedCodec(t *testing.T) {
+	// videocodecid 4 = On2 VP6, not describable in RTP
+	metadata := map[string]any{"videocodecid": 4.0, "audiocodecid": 10.0}
+	if _, err := CodecsFromMetadata(metadata); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Expected ErrUnsupportedCodec, got %v", err)
+	}
+
+	response := describeWithMetadata(t, metadata)
+	if response.StatusCode != StatusUnsupportedMediaType {
+		t.Fatalf("Expected %d, got %d", StatusUnsupportedMediaType, response.StatusCode)
+	}
+	if len(response.Body) != 0 {
+		t.Errorf("Expected no SDP body, got %q", response.Body)
+	}
+}
+
+func TestUnsupportedAACSampleRate(t *testing.T) {
+	session, _, _ := newTestSession()
+	_, err := session.generateSDP(StreamCodecs{Audio: codec.AAC, AudioSampleRate: 12345, AudioChannels: 2})
+	if !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Expected ErrUnsupportedCodec, got %v", err)
+	}
+}
+
+func TestDescribeOmitsUnknownParameterSets(t *testing.T) {
+	response := describeWithMetadata(t, map[string]any{"videocodecid": 7.0})
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	sdp := string(response.Body)
+	if !strings.Contains(sdp, "a=fmtp:96 packetization-mode=1\r\n") {
+		t.Errorf("Expected fmtp without sprop-parameter-sets:\n%s", sdp)
+	}
+
+	response = describeWithMetadata(t, map[string]any{"videocodecid": 7.0, MetadataParameterSets: "Z2QAH6w=,aO4xsg=="})
+	if !strings.Contains(string(response.Body), "a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO4xsg==\r\n") {
+		t.Errorf("Expected the stream's parameter sets:\n%s", response.Body)
+	}
+}
+
+func TestMetadataFromSDP(t *testing.T) {
+	sdp, err := ParseSDP("v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=Publisher\r\n" +
+		"t=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 packetization-mode=1; sprop-parameter-sets=Z2QAH6w=,aO4xsg==; profile-level-id=64001F\r\n" +
+		"m=audio 0 RTP/AVP 97\r\n" +
+		"a=rtpmap:97 MPEG4-GENERIC/44100/1\r\n")
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+
+	codecs, err := CodecsFromMetadata(metadataFromSDP(sdp))
+	if err != nil {
+		t.Fatalf("Failed to detect codecs: %v", err)
+	}
+	expected := StreamCodecs{
+		Video:              codec.H264,
+		Audio:              codec.AAC,
+		AudioSampleRate:    44100,
+		AudioChannels:      1,
+		VideoParameterSets: "Z2QAH6w=,aO4xsg==",
+	}
+	if codecs != expected {
+		t.Errorf("Expected %+v, got %+v", expected, codecs)
+	}
+}
+
+func TestMetadataFromSDPUnsupportedCodec(t *testing.T) {
+	sdp, err := ParseSDP("v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=Publisher\r\n" +
+		"t=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 VP8/90000\r\n")
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	if _, err := CodecsFromMetadata(metadataFromSDP(sdp)); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Expected ErrUnsupportedCodec, got %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package rtsp
 
+import "time"
+
 // RTSP Methods
 const (
 	MethodOptions    = "OPTIONS"
@@ -83,6 +85,7 @@ const (
 	HeaderFrom            = "From"
 	HeaderIfModifiedSince = "If-Modified-Since"
 	HeaderLastModified    = "Last-Modified"
+	HeaderLocation        = "Location"
 	HeaderProxyAuthenticate = "Proxy-Authenticate"
 	HeaderProxyRequire    = "Proxy-Require"
 	HeaderPublic          = "Public"
@@ -114,8 +117,43 @@ const (
 // RTSP Version
 const RTSPVersion = "RTSP/1.0"
 
+// Server identification
+const ServerName = "Sol RTSP Server"
+
+// Default SDP session name (s=) and information (i=) of generated descriptions
+const (
+	DefaultSDPSessionName = "Sol RTSP Stream"
+	DefaultSDPSessionInfo = "RTSP Server Stream"
+)
+
+// DateFormat is the RFC 1123 date format used for the Date header (always GMT)
+const DateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
 // Default Values
 const (
-	DefaultRTSPPort = 554
-	DefaultTimeout  = 60 // seconds
+	DefaultRTSPPort         = 554
+	DefaultTimeout          = 60   // seconds
+	DefaultPacketBufferSize = 1024 // buffered RTP packets per stream
+	RTPPortOffset           = 1000 // UDP RTP port = RTSP port + offset
+
+	// Longest time a batched interleaved frame waits before it is flushed
+	DefaultInterleavedFlushInterval = 10 * time.Millisecond
+
+	// Largest interleaved frame accepted from a client (RTP packets stay within the MTU)
+	DefaultMaxInterleavedFrameSize = 1500
+)
+
+// MaxInterleavedFrameSize is the largest payload the 16-bit interleaved frame length can carry
+const MaxInterleavedFrameSize = 0xFFFF
+
+// Play start policies (where a PLAY without Range starts)
+const (
+	PlayStartLive  = "live"  // start at the live edge (low latency)
+	PlayStartStart = "start" // start from the earliest buffered packet (catch-up)
+)
+
+// Interleaved channel assignments (how SETUP picks a TCP track's channel pair)
+const (
+	ChannelAssignmentClient = "client" // the pair the client asked for when free, otherwise the lowest free pair
+	ChannelAssignmentTrack  = "track"  // fixed pairs by track (video 0-1, audio 2-3) whatever the client asked for
 )
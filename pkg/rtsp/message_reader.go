@@ -20,6 +20,22 @@ func NewMessageReader(r io.Reader) *MessageReader {
 	}
 }
 
+// PeekByte returns the next byte without consuming it, telling an RTSP
+// message apart from an interleaved frame ('$')
+func (mr *MessageReader) PeekByte() (byte, error) {
+	b, err := mr.reader.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// Read reads raw bytes, such as interleaved frames, from the same buffer the
+// messages are parsed from
+func (mr *MessageReader) Read(p []byte) (int, error) {
+	return mr.reader.Read(p)
+}
+
 // ReadRequest reads and parses an RTSP request
 func (mr *MessageReader) ReadRequest() (*Request, error) {
 	// Read request line
@@ -2,35 +2,179 @@ package rtsp
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"sync"
+	"time"
 )
 
 // MessageWriter handles RTSP message writing
 type MessageWriter struct {
+	out    io.Writer
 	writer *bufio.Writer
+	mutex  sync.Mutex // serializes responses and interleaved frames on the connection
+
+	// Interleaved frame batching (disabled when flushSize is 0)
+	flushSize     int
+	flushInterval time.Duration
+	flushTimer    *time.Timer
+
+	// First write or flush failure (including a timer-driven flush). The
+	// connection is unusable after it, so every later write returns it at once.
+	err error
 }
 
 // NewMessageWriter creates a new RTSP message writer
 func NewMessageWriter(w io.Writer) *MessageWriter {
 	return &MessageWriter{
+		out:    w,
 		writer: bufio.NewWriter(w),
 	}
 }
 
+// EnableFrameBatching buffers interleaved frames until flushSize bytes are
+// pending or flushInterval has passed since the first unflushed frame
+func (mw *MessageWriter) EnableFrameBatching(flushSize int, flushInterval time.Duration) {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	if flushInterval <= 0 {
+		flushInterval = DefaultInterleavedFlushInterval
+	}
+	mw.flushSize = flushSize
+	mw.flushInterval = flushInterval
+
+	// Make room for a whole batch so bufio does not split it into partial writes
+	if flushSize > mw.writer.Size() && mw.writer.Buffered() == 0 {
+		mw.writer = bufio.NewWriterSize(mw.out, flushSize)
+	}
+}
+
+// Err returns the write failure that broke the connection, or nil
+func (mw *MessageWriter) Err() error {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	return mw.err
+}
+
 // WriteRequest writes an RTSP request
 func (mw *MessageWriter) WriteRequest(req *Request) error {
-	data := req.Bytes()
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	return mw.writeAndFlush(req.Bytes())
+}
+
+// WriteResponse writes an RTSP response, flushing any batched frames before it
+func (mw *MessageWriter) WriteResponse(resp *Response) error {
+	finalizeResponse(resp)
+
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	return mw.writeAndFlush(resp.Bytes())
+}
+
+// WriteInterleavedFrame writes an RTP packet as a '$'-framed interleaved frame
+func (mw *MessageWriter) WriteInterleavedFrame(channel int, data []byte) error {
+	// A longer frame would wrap the length field and desync the stream
+	if len(data) > MaxInterleavedFrameSize {
+		return fmt.Errorf("interleaved frame too large: %d bytes (max %d)", len(data), MaxInterleavedFrameSize)
+	}
+	if channel < 0 || channel > 0xFF {
+		return fmt.Errorf("invalid interleaved channel: %d", channel)
+	}
+
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	if mw.err != nil {
+		return mw.err
+	}
+
+	// Interleaved frame format:
+	// '$' + channel + length(2 bytes) + data
+	length := uint16(len(data))
+	header := [4]byte{
+		'$',                 // Magic byte
+		byte(channel),       // Channel number
+		byte(length >> 8),   // Length high byte
+		byte(length & 0xFF), // Length low byte
+	}
+	if _, err := mw.writer.Write(header[:]); err != nil {
+		return mw.fail(err)
+	}
 	if _, err := mw.writer.Write(data); err != nil {
-		return err
+		return mw.fail(err)
+	}
+
+	if mw.flushSize == 0 || mw.writer.Buffered() >= mw.flushSize {
+		return mw.flush()
 	}
-	return mw.writer.Flush()
+
+	// First frame of a new batch: make sure it goes out within flushInterval
+	if mw.flushTimer == nil {
+		mw.flushTimer = time.AfterFunc(mw.flushInterval, mw.timedFlush)
+	}
+	return nil
 }
 
-// WriteResponse writes an RTSP response
-func (mw *MessageWriter) WriteResponse(resp *Response) error {
-	data := resp.Bytes()
+// Flush writes out any batched frames
+func (mw *MessageWriter) Flush() error {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	return mw.flush()
+}
+
+// timedFlush flushes a batch whose interval expired
+func (mw *MessageWriter) timedFlush() {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	mw.flushTimer = nil
+	mw.fail(mw.writer.Flush())
+}
+
+// writeAndFlush writes a whole message and flushes it (caller holds the lock)
+func (mw *MessageWriter) writeAndFlush(data []byte) error {
+	if mw.err != nil {
+		return mw.err
+	}
 	if _, err := mw.writer.Write(data); err != nil {
-		return err
+		return mw.fail(err)
+	}
+	return mw.flush()
+}
+
+// fail records the first write failure and returns err (caller holds the lock)
+func (mw *MessageWriter) fail(err error) error {
+	if err != nil && mw.err == nil {
+		mw.err = err
+	}
+	return err
+}
+
+// flush writes out the buffer and cancels the pending batch timer (caller holds the lock)
+func (mw *MessageWriter) flush() error {
+	if mw.flushTimer != nil {
+		mw.flushTimer.Stop()
+		mw.flushTimer = nil
+	}
+	if mw.err != nil {
+		return mw.err
+	}
+	return mw.fail(mw.writer.Flush())
+}
+
+// finalizeResponse sets the headers every response must carry (Date, Server)
+// unless the handler already set them
+func finalizeResponse(resp *Response) {
+	if resp.GetHeader(HeaderDate) == "" {
+		resp.SetHeader(HeaderDate, time.Now().UTC().Format(DateFormat))
+	}
+	if resp.GetHeader(HeaderServer) == "" {
+		resp.SetHeader(HeaderServer, ServerName)
 	}
-	return mw.writer.Flush()
 }
@@ -0,0 +1,192 @@
+package rtsp
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteResponseSetsDateAndServer(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewMessageWriter(&buf)
+
+	response := NewResponse(StatusOK)
+	response.SetCSeq(1)
+	if err := writer.WriteResponse(response); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+
+	parsed, err := NewMessageReader(&buf).ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	if parsed.GetHeader(HeaderServer) != ServerName {
+		t.Errorf("Expected Server header %q, got %q", ServerName, parsed.GetHeader(HeaderServer))
+	}
+
+	date := parsed.GetHeader(HeaderDate)
+	if date == "" {
+		t.Fatal("Expected Date header to be set")
+	}
+	if _, err := time.Parse(DateFormat, date); err != nil {
+		t.Errorf("Expected RFC 1123 Date header, got %q: %v", date, err)
+	}
+}
+
+func TestWriteResponseKeepsExistingHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewMessageWriter(&buf)
+
+	response := NewResponse(StatusNotFound)
+	response.SetCSeq(2)
+	response.SetHeader(HeaderServer, "Custom Server")
+	response.SetHeader(HeaderDate, "Thu, 01 Jan 2026 00:00:00 GMT")
+	if err := writer.WriteResponse(response); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+
+	parsed, err := NewMessageReader(&buf).ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	if parsed.GetHeader(HeaderServer) != "Custom Server" {
+		t.Errorf("Expected Server header to be kept, got %q", parsed.GetHeader(HeaderServer))
+	}
+	if parsed.GetHeader(HeaderDate) != "Thu, 01 Jan 2026 00:00:00 GMT" {
+		t.Errorf("Expected Date header to be kept, got %q", parsed.GetHeader(HeaderDate))
+	}
+}
+
+// countingWriter records each Write call separately
+type countingWriter struct {
+	mutex  sync.Mutex
+	writes [][]byte
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.writes = append(w.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (w *countingWriter) count() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.writes)
+}
+
+func TestInterleavedFramesWithoutBatching(t *testing.T) {
+	out := &countingWriter{}
+	writer := NewMessageWriter(out)
+
+	for i := 0; i < 3; i++ {
+		if err := writer.WriteInterleavedFrame(0, make([]byte, 100)); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	if out.count() != 3 {
+		t.Fatalf("Expected one write per frame, got %d writes", out.count())
+	}
+}
+
+func TestInterleavedFramesBatchedBySize(t *testing.T) {
+	out := &countingWriter{}
+	writer := NewMessageWriter(out)
+	writer.EnableFrameBatching(500, time.Hour)
+
+	// 4 frames of 104 bytes stay below the flush size
+	for i := 0; i < 4; i++ {
+		if err := writer.WriteInterleavedFrame(0, make([]byte, 100)); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	if out.count() != 0 {
+		t.Fatalf("Expected frames to be batched, got %d writes", out.count())
+	}
+
+	// The fifth frame reaches the flush size
+	if err := writer.WriteInterleavedFrame(0, make([]byte, 100)); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	if out.count() != 1 || len(out.writes[0]) != 5*104 {
+		t.Fatalf("Expected one batched write of %d bytes, got %d writes", 5*104, out.count())
+	}
+}
+
+func TestInterleavedFramesFlushedByInterval(t *testing.T) {
+	out := &countingWriter{}
+	writer := NewMessageWriter(out)
+	writer.EnableFrameBatching(64*1024, 10*time.Millisecond)
+
+	if err := writer.WriteInterleavedFrame(2, []byte{0xAA}); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for out.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if out.count() != 1 {
+		t.Fatal("Expected batched frame to be flushed after the interval")
+	}
+	if !bytes.Equal(out.writes[0], []byte{'$', 2, 0, 1, 0xAA}) {
+		t.Errorf("Unexpected interleaved frame: %x", out.writes[0])
+	}
+}
+
+func TestResponseFlushesBatchedFramesFirst(t *testing.T) {
+	out := &countingWriter{}
+	writer := NewMessageWriter(out)
+	writer.EnableFrameBatching(64*1024, time.Hour)
+
+	if err := writer.WriteInterleavedFrame(0, []byte{0x01}); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	response := NewResponse(StatusOK)
+	response.SetCSeq(1)
+	if err := writer.WriteResponse(response); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+
+	if out.count() != 1 {
+		t.Fatalf("Expected frame and response in one write, got %d writes", out.count())
+	}
+	if !bytes.HasPrefix(out.writes[0], []byte{'$', 0, 0, 1, 0x01}) || !bytes.Contains(out.writes[0], []byte(RTSPVersion+" 200")) {
+		t.Errorf("Expected the frame to precede the response, got %q", out.writes[0])
+	}
+}
+
+func TestInterleavedFrameEncoding(t *testing.T) {
+	// Lengths that exercise the high and low byte of the length field
+	for _, length := range []int{0, 1, 255, 256, 300, MaxInterleavedFrameSize} {
+		var out bytes.Buffer
+		data := bytes.Repeat([]byte{0xAB}, length)
+		if err := NewMessageWriter(&out).WriteInterleavedFrame(3, data); err != nil {
+			t.Fatalf("Failed to write %d byte frame: %v", length, err)
+		}
+
+		expected := append([]byte{'$', 3, byte(length >> 8), byte(length)}, data...)
+		if !bytes.Equal(out.Bytes(), expected) {
+			t.Errorf("Unexpected framing for %d bytes: header %x", length, out.Bytes()[:4])
+		}
+	}
+}
+
+func TestInterleavedFrameTooLarge(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewMessageWriter(&out)
+
+	if err := writer.WriteInterleavedFrame(0, make([]byte, MaxInterleavedFrameSize+1)); err == nil {
+		t.Fatal("Expected error for frame larger than the length field")
+	}
+	if err := writer.WriteInterleavedFrame(256, []byte{0x01}); err == nil {
+		t.Fatal("Expected error for channel above 255")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing written for rejected frames, got %d bytes", out.Len())
+	}
+}
@@ -0,0 +1,411 @@
+package rtsp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SDP line ending (RFC 4566 requires CRLF)
+const sdpLineEnding = "\r\n"
+
+// SDPAttribute represents an "a=" line, either "key:value" or a flag "key"
+type SDPAttribute struct {
+	Key   string
+	Value string
+}
+
+// String returns the attribute as it appears after "a="
+func (a SDPAttribute) String() string {
+	if a.Value == "" {
+		return a.Key
+	}
+	return a.Key + ":" + a.Value
+}
+
+// MediaDescription represents an "m=" section of an SDP
+type MediaDescription struct {
+	Type       string   // video, audio, ...
+	Port       int      // 0 for RTSP (ports are negotiated by SETUP)
+	Protocol   string   // RTP/AVP
+	Formats    []string // payload types
+	Connection string   // c= (optional)
+	Bandwidth  string   // b= (optional, e.g. "AS:500")
+	Attributes []SDPAttribute
+}
+
+// SDP is a Session Description Protocol builder (RFC 4566)
+type SDP struct {
+	Version     int
+	Origin      string   // o=
+	SessionName string   // s=
+	Info        string   // i= (optional)
+	Connection  string   // c= (optional)
+	Timing      string   // t=
+	Other       []string // other session-level lines kept verbatim (e.g. "u=...")
+	Attributes  []SDPAttribute
+	Media       []*MediaDescription
+}
+
+// NewSDP creates an SDP with the mandatory session fields filled in
+func NewSDP(sessionName string) *SDP {
+	now := time.Now().Unix()
+	return &SDP{
+		Version:     0,
+		Origin:      fmt.Sprintf("- %d %d IN IP4 127.0.0.1", now, now),
+		SessionName: sessionName,
+		Timing:      "0 0",
+	}
+}
+
+// SetOriginAddress sets the address of the "o=" line ("IN IP4 <addr>"), keeping its session ID and version
+func (s *SDP) SetOriginAddress(address string) *SDP {
+	fields := strings.Fields(s.Origin)
+	if len(fields) < 3 {
+		fields = []string{"-", "0", "0"}
+	}
+	s.Origin = strings.Join(fields[:3], " ") + " " + address
+	return s
+}
+
+// SetAddress sets the address of the "o=" line and of every "c=" line the SDP has
+func (s *SDP) SetAddress(address string) *SDP {
+	s.SetOriginAddress(address)
+	if s.Connection != "" {
+		s.Connection = address
+	}
+	for _, media := range s.Media {
+		if media.Connection != "" {
+			media.Connection = address
+		}
+	}
+	return s
+}
+
+// sdpAddress formats an IP as the network/address type and address of "o=" and "c=" lines
+func sdpAddress(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return "IN IP4 " + ip4.String()
+	}
+	return "IN IP6 " + ip.String()
+}
+
+// AddAttribute adds a session-level attribute (value may be empty for flags)
+func (s *SDP) AddAttribute(key, value string) *SDP {
+	s.Attributes = append(s.Attributes, SDPAttribute{Key: key, Value: value})
+	return s
+}
+
+// AddMedia adds a media description with the given payload types
+func (s *SDP) AddMedia(mediaType string, port int, protocol string, payloadTypes ...int) *MediaDescription {
+	formats := make([]string, len(payloadTypes))
+	for i, pt := range payloadTypes {
+		formats[i] = strconv.Itoa(pt)
+	}
+
+	media := &MediaDescription{
+		Type:     mediaType,
+		Port:     port,
+		Protocol: protocol,
+		Formats:  formats,
+	}
+	s.Media = append(s.Media, media)
+	return media
+}
+
+// AddAttribute adds a media-level attribute (value may be empty for flags)
+func (m *MediaDescription) AddAttribute(key, value string) *MediaDescription {
+	m.Attributes = append(m.Attributes, SDPAttribute{Key: key, Value: value})
+	return m
+}
+
+// AddRTPMap adds "a=rtpmap:<pt> <encoding>" (e.g. "H264/90000")
+func (m *MediaDescription) AddRTPMap(payloadType int, encoding string) *MediaDescription {
+	return m.AddAttribute("rtpmap", fmt.Sprintf("%d %s", payloadType, encoding))
+}
+
+// AddFmtp adds "a=fmtp:<pt> <params>"
+func (m *MediaDescription) AddFmtp(payloadType int, params string) *MediaDescription {
+	return m.AddAttribute("fmtp", fmt.Sprintf("%d %s", payloadType, params))
+}
+
+// Fmtp returns the parameters of "a=fmtp:<pt> <params>", or "" if missing
+func (m *MediaDescription) Fmtp(payloadType int) string {
+	prefix := fmt.Sprintf("%d ", payloadType)
+	for _, attr := range m.Attributes {
+		if attr.Key == "fmtp" && strings.HasPrefix(attr.Value, prefix) {
+			return strings.TrimSpace(attr.Value[len(prefix):])
+		}
+	}
+	return ""
+}
+
+// RTPMap returns the encoding of "a=rtpmap:<pt> <encoding>", or "" if missing
+func (m *MediaDescription) RTPMap(payloadType int) string {
+	prefix := fmt.Sprintf("%d ", payloadType)
+	for _, attr := range m.Attributes {
+		if attr.Key == "rtpmap" && strings.HasPrefix(attr.Value, prefix) {
+			return strings.TrimSpace(attr.Value[len(prefix):])
+		}
+	}
+	return ""
+}
+
+// ClockRate returns the clock rate from the rtpmap encoding of payloadType
+// ("H264/90000", "MPEG4-GENERIC/44100/2"), or false if missing
+func (m *MediaDescription) ClockRate(payloadType int) (int, bool) {
+	_, clock, _ := strings.Cut(m.RTPMap(payloadType), "/")
+	clock, _, _ = strings.Cut(clock, "/")
+	clockRate, err := strconv.Atoi(clock)
+	if err != nil || clockRate <= 0 {
+		return 0, false
+	}
+	return clockRate, true
+}
+
+// AddRTX adds an RTX payload type (RFC 4588) retransmitting payloadType,
+// with "a=rtpmap:<rtx> rtx/<clock>" and "a=fmtp:<rtx> apt=<pt>"
+func (m *MediaDescription) AddRTX(rtxPayloadType, payloadType, clockRate int) *MediaDescription {
+	m.Formats = append(m.Formats, strconv.Itoa(rtxPayloadType))
+	return m.AddRTPMap(rtxPayloadType, fmt.Sprintf("rtx/%d", clockRate)).
+		AddFmtp(rtxPayloadType, fmt.Sprintf("apt=%d", payloadType))
+}
+
+// RTXPayloadType returns the RTX payload type whose fmtp "apt" refers to payloadType
+func (m *MediaDescription) RTXPayloadType(payloadType int) (int, bool) {
+	for _, format := range m.Formats {
+		pt, err := strconv.Atoi(format)
+		if err != nil {
+			continue
+		}
+		encoding, _, _ := strings.Cut(m.RTPMap(pt), "/")
+		if !strings.EqualFold(encoding, "rtx") {
+			continue
+		}
+		if m.Fmtp(pt) == fmt.Sprintf("apt=%d", payloadType) {
+			return pt, true
+		}
+	}
+	return 0, false
+}
+
+// SetControl sets "a=control:<url>", replacing an existing control attribute
+func (m *MediaDescription) SetControl(control string) *MediaDescription {
+	for i := range m.Attributes {
+		if m.Attributes[i].Key == "control" {
+			m.Attributes[i].Value = control
+			return m
+		}
+	}
+	return m.AddAttribute("control", control)
+}
+
+// Control returns the control attribute of the media, or "" if missing
+func (m *MediaDescription) Control() string {
+	for _, attr := range m.Attributes {
+		if attr.Key == "control" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// SetSSRC replaces any "a=ssrc:" and "a=ssrc-group:" lines with "a=ssrc:<ssrc> cname:<cname>" (RFC 5576)
+func (m *MediaDescription) SetSSRC(ssrc uint32, cname string) *MediaDescription {
+	attributes := m.Attributes[:0]
+	for _, attr := range m.Attributes {
+		if attr.Key != "ssrc" && attr.Key != "ssrc-group" {
+			attributes = append(attributes, attr)
+		}
+	}
+	m.Attributes = attributes
+	return m.AddAttribute("ssrc", fmt.Sprintf("%d cname:%s", ssrc, cname))
+}
+
+// SetRTXSSRC adds the SSRC of the RTX stream retransmitting ssrc, grouped with
+// it as "a=ssrc-group:FID <ssrc> <rtx ssrc>" (RFC 4588 section 8.3, RFC 5576)
+func (m *MediaDescription) SetRTXSSRC(ssrc, rtxSSRC uint32, cname string) *MediaDescription {
+	return m.AddAttribute("ssrc-group", fmt.Sprintf("FID %d %d", ssrc, rtxSSRC)).
+		AddAttribute("ssrc", fmt.Sprintf("%d cname:%s", rtxSSRC, cname))
+}
+
+// SSRC returns the SSRC of the first "a=ssrc:" line
+func (m *MediaDescription) SSRC() (uint32, bool) {
+	for _, attr := range m.Attributes {
+		if attr.Key != "ssrc" {
+			continue
+		}
+		id, _, _ := strings.Cut(attr.Value, " ")
+		if ssrc, err := strconv.ParseUint(id, 10, 32); err == nil {
+			return uint32(ssrc), true
+		}
+	}
+	return 0, false
+}
+
+// SetBandwidth sets the "b=" line (e.g. "AS:500")
+func (m *MediaDescription) SetBandwidth(bandwidth string) *MediaDescription {
+	m.Bandwidth = bandwidth
+	return m
+}
+
+// SetConnection sets the media-level "c=" line
+func (m *MediaDescription) SetConnection(connection string) *MediaDescription {
+	m.Connection = connection
+	return m
+}
+
+// String serializes the SDP with CRLF line endings
+func (s *SDP) String() string {
+	var b strings.Builder
+
+	writeLine := func(key byte, value string) {
+		b.WriteByte(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteString(sdpLineEnding)
+	}
+
+	writeLine('v', strconv.Itoa(s.Version))
+	writeLine('o', s.Origin)
+	writeLine('s', s.SessionName)
+	if s.Info != "" {
+		writeLine('i', s.Info)
+	}
+	if s.Connection != "" {
+		writeLine('c', s.Connection)
+	}
+	writeLine('t', s.Timing)
+	for _, line := range s.Other {
+		b.WriteString(line)
+		b.WriteString(sdpLineEnding)
+	}
+	for _, attr := range s.Attributes {
+		writeLine('a', attr.String())
+	}
+
+	for _, m := range s.Media {
+		writeLine('m', fmt.Sprintf("%s %d %s %s", m.Type, m.Port, m.Protocol, strings.Join(m.Formats, " ")))
+		if m.Connection != "" {
+			writeLine('c', m.Connection)
+		}
+		if m.Bandwidth != "" {
+			writeLine('b', m.Bandwidth)
+		}
+		for _, attr := range m.Attributes {
+			writeLine('a', attr.String())
+		}
+	}
+
+	return b.String()
+}
+
+// ParseSDP parses an SDP body (CRLF or LF line endings)
+func ParseSDP(text string) (*SDP, error) {
+	sdp := &SDP{}
+	var media *MediaDescription
+	seenVersion := false
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, fmt.Errorf("invalid SDP line %d: %q", i+1, line)
+		}
+
+		key, value := line[0], line[2:]
+		if !seenVersion {
+			if key != 'v' {
+				return nil, fmt.Errorf("SDP must start with v=, got %q", line)
+			}
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SDP version: %q", value)
+			}
+			sdp.Version = version
+			seenVersion = true
+			continue
+		}
+
+		if key == 'm' {
+			parsed, err := parseMediaLine(value)
+			if err != nil {
+				return nil, err
+			}
+			media = parsed
+			sdp.Media = append(sdp.Media, media)
+			continue
+		}
+
+		// Media-level lines
+		if media != nil {
+			switch key {
+			case 'c':
+				media.Connection = value
+			case 'b':
+				media.Bandwidth = value
+			case 'a':
+				media.Attributes = append(media.Attributes, parseAttribute(value))
+			}
+			continue
+		}
+
+		// Session-level lines
+		switch key {
+		case 'o':
+			sdp.Origin = value
+		case 's':
+			sdp.SessionName = value
+		case 'i':
+			sdp.Info = value
+		case 'c':
+			sdp.Connection = value
+		case 't':
+			sdp.Timing = value
+		case 'a':
+			sdp.Attributes = append(sdp.Attributes, parseAttribute(value))
+		default:
+			sdp.Other = append(sdp.Other, line)
+		}
+	}
+
+	if !seenVersion {
+		return nil, fmt.Errorf("empty SDP")
+	}
+	if sdp.Origin == "" || sdp.Timing == "" {
+		return nil, fmt.Errorf("SDP is missing required o= or t= line")
+	}
+
+	return sdp, nil
+}
+
+// parseMediaLine parses the value of an "m=" line
+func parseMediaLine(value string) (*MediaDescription, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid SDP media line: %q", value)
+	}
+
+	// Port may carry a count ("<port>/<count>"); only the port is kept
+	port, err := strconv.Atoi(strings.SplitN(fields[1], "/", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid SDP media port: %q", fields[1])
+	}
+
+	return &MediaDescription{
+		Type:     fields[0],
+		Port:     port,
+		Protocol: fields[2],
+		Formats:  fields[3:],
+	}, nil
+}
+
+// parseAttribute parses the value of an "a=" line
+func parseAttribute(value string) SDPAttribute {
+	key, attrValue, _ := strings.Cut(value, ":")
+	return SDPAttribute{Key: key, Value: attrValue}
+}
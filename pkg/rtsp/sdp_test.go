@@ -0,0 +1,221 @@
+package rtsp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSDPBuilderString(t *testing.T) {
+	sdp := NewSDP("Test Stream")
+	sdp.Origin = "- 1 1 IN IP4 127.0.0.1"
+	sdp.AddAttribute("range", "npt=0-")
+	sdp.AddMedia("video", 0, "RTP/AVP", 96).
+		AddRTPMap(96, "H264/90000").
+		AddFmtp(96, "packetization-mode=1").
+		SetControl("track1")
+	sdp.AddMedia("audio", 0, "RTP/AVP", 97).
+		SetBandwidth("AS:128").
+		AddRTPMap(97, "MPEG4-GENERIC/48000/2").
+		SetControl("track2")
+
+	expected := "v=0\r\n" +
+		"o=- 1 1 IN IP4 127.0.0.1\r\n" +
+		"s=Test Stream\r\n" +
+		"t=0 0\r\n" +
+		"a=range:npt=0-\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 packetization-mode=1\r\n" +
+		"a=control:track1\r\n" +
+		"m=audio 0 RTP/AVP 97\r\n" +
+		"b=AS:128\r\n" +
+		"a=rtpmap:97 MPEG4-GENERIC/48000/2\r\n" +
+		"a=control:track2\r\n"
+
+	if got := sdp.String(); got != expected {
+		t.Errorf("Unexpected SDP:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestDefaultSDPLineEndings(t *testing.T) {
+	session, _, _ := newTestSession()
+	sdp := session.generateDetailedSDP().String()
+
+	if strings.Contains(sdp, `\r`) {
+		t.Error("Expected no literal backslash-r sequences in SDP")
+	}
+	for _, line := range strings.SplitAfter(sdp, "\n") {
+		if line != "" && !strings.HasSuffix(line, "\r\n") {
+			t.Errorf("Expected CRLF line ending, got %q", line)
+		}
+	}
+}
+
+func TestGeneratedSDPSessionFields(t *testing.T) {
+	// Defaults: the local address the client connected to
+	session, _, _ := newTestSession()
+	sdp := session.generateDetailedSDP().String()
+	for _, line := range []string{
+		"s=" + DefaultSDPSessionName + "\r\n",
+		"i=" + DefaultSDPSessionInfo + "\r\n",
+		" IN IP4 127.0.0.1\r\n",
+		"c=IN IP4 127.0.0.1\r\n",
+	} {
+		if !strings.Contains(sdp, line) {
+			t.Errorf("Expected SDP to contain %q:\n%s", line, sdp)
+		}
+	}
+
+	tests := []struct {
+		address string
+		line    string
+	}{
+		{"203.0.113.10", "IN IP4 203.0.113.10"},
+		{"2001:db8::1", "IN IP6 2001:db8::1"},
+	}
+	for _, tt := range tests {
+		session, _, _ := newTestSession()
+		session.sdpOptions = SDPOptions{SessionName: "Camera 1", Info: "Front door", Address: tt.address}
+		parsed, err := ParseSDP(session.generateDetailedSDP().String())
+		if err != nil {
+			t.Fatalf("Failed to parse generated SDP: %v", err)
+		}
+
+		if parsed.SessionName != "Camera 1" || parsed.Info != "Front door" {
+			t.Errorf("Expected configured session name and info, got %q / %q", parsed.SessionName, parsed.Info)
+		}
+		if !strings.HasSuffix(parsed.Origin, " "+tt.line) {
+			t.Errorf("Expected origin address %q, got %q", tt.line, parsed.Origin)
+		}
+		if parsed.Connection != tt.line {
+			t.Errorf("Expected connection %q, got %q", tt.line, parsed.Connection)
+		}
+		for _, media := range parsed.Media {
+			if media.Connection != tt.line {
+				t.Errorf("Expected %s connection %q, got %q", media.Type, tt.line, media.Connection)
+			}
+		}
+	}
+}
+
+func TestParseSDPRoundTrip(t *testing.T) {
+	// LF-only input from a lenient encoder
+	input := "v=0\n" +
+		"o=- 123 1 IN IP4 192.168.0.10\n" +
+		"s=Encoder\n" +
+		"t=0 0\n" +
+		"m=video 0 RTP/AVP 96\n" +
+		"a=rtpmap:96 H264/90000\n" +
+		"a=control:streamid=0\n"
+
+	sdp, err := ParseSDP(input)
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	if len(sdp.Media) != 1 || sdp.Media[0].Type != "video" || sdp.Media[0].Control() != "streamid=0" {
+		t.Fatalf("Unexpected media: %+v", sdp.Media)
+	}
+
+	expected := strings.ReplaceAll(input, "\n", "\r\n")
+	if got := sdp.String(); got != expected {
+		t.Errorf("Unexpected round trip:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestParseSDPInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"s=No Version\r\n",
+		"v=0\r\ns=Missing origin\r\n",
+		"v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=x\r\nt=0 0\r\nm=video\r\n",
+	}
+	for _, input := range inputs {
+		if _, err := ParseSDP(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestAnnounceDescribeRoundTrip(t *testing.T) {
+	manager := NewStreamManager()
+
+	publisher, conn, channel := newTestSession()
+	publisher.streamManager = manager
+	announce := newTestRequest(MethodAnnounce, 1, map[string]string{HeaderContentType: "application/sdp"})
+	announce.Body = []byte("v=0\no=- 1 1 IN IP4 127.0.0.1\ns=Encoder\nt=0 0\nm=video 0 RTP/AVP 96\na=rtpmap:96 H264/90000\n")
+	if err := publisher.handleRequest(announce); err != nil {
+		t.Fatalf("Failed to handle ANNOUNCE: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for ANNOUNCE, got %d", response.StatusCode)
+	}
+
+	// What the server does on AnnounceReceived
+	event := (<-channel).(AnnounceReceived)
+	manager.GetOrCreateStream(event.StreamPath).SetPublisher(publisher, event.SDP)
+
+	player, playerConn, _ := newTestSession()
+	player.streamManager = manager
+	if err := player.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	response := readResponse(t, playerConn)
+
+	// The player's own SSRC is advertised in place of the publisher's
+	expected := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=Encoder\r\nt=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\na=control:track1\r\n" +
+		fmt.Sprintf("a=ssrc:%d cname:%s\r\n", player.offeredSSRCs[TrackVideo], player.sessionId)
+	if string(response.Body) != expected {
+		t.Errorf("Unexpected DESCRIBE SDP:\n%q\nexpected:\n%q", string(response.Body), expected)
+	}
+}
+
+func TestAnnouncedSDPAdvertisesConfiguredAddress(t *testing.T) {
+	manager := NewStreamManager()
+	publisher, _, _ := newTestSession()
+	// An encoder on a private network puts its own address in the SDP
+	manager.GetOrCreateStream("/live/test").SetPublisher(publisher,
+		"v=0\no=- 1 1 IN IP4 192.168.0.10\ns=Encoder\nc=IN IP4 192.168.0.10\nt=0 0\n"+
+			"m=video 0 RTP/AVP 96\nc=IN IP4 192.168.0.10\na=rtpmap:96 H264/90000\n")
+
+	tests := []struct {
+		address string
+		line    string
+	}{
+		{"203.0.113.10", "IN IP4 203.0.113.10"},
+		{"", "IN IP4 192.168.0.10"}, // without an override the announced SDP is relayed as is
+	}
+	for _, tt := range tests {
+		player, _, _ := newTestSession()
+		player.streamManager = manager
+		player.streamPath = "/live/test"
+		player.sdpOptions.Address = tt.address
+
+		sdp, err := player.sessionSDP()
+		if err != nil {
+			t.Fatalf("Failed to get session SDP: %v", err)
+		}
+		if !strings.HasSuffix(sdp.Origin, " "+tt.line) {
+			t.Errorf("Expected origin address %q, got %q", tt.line, sdp.Origin)
+		}
+		if sdp.Connection != tt.line || sdp.Media[0].Connection != tt.line {
+			t.Errorf("Expected connection %q, got %q / %q", tt.line, sdp.Connection, sdp.Media[0].Connection)
+		}
+	}
+}
+
+func TestAnnounceRejectsInvalidSDP(t *testing.T) {
+	session, conn, channel := newTestSession()
+	announce := newTestRequest(MethodAnnounce, 1, nil)
+	announce.Body = []byte("not an sdp")
+	if err := session.handleRequest(announce); err != nil {
+		t.Fatalf("Failed to handle ANNOUNCE: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", response.StatusCode)
+	}
+	if len(channel) != 0 {
+		t.Fatalf("Expected no events, got %d", len(channel))
+	}
+}
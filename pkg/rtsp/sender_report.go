@@ -0,0 +1,60 @@
+package rtsp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// senderReportInterval is how often interleaved tracks get an RTCP sender
+// report (the RFC 3550 minimum interval); a track that sent nothing for two
+// intervals gets none
+const senderReportInterval = 5 * time.Second
+
+// startSenderReports sends periodic RTCP sender reports for the session's
+// interleaved tracks on their RTCP channels until the session stops, so TCP
+// players can map RTP timestamps to wallclock time (only the first call starts them)
+func (s *Session) startSenderReports() {
+	s.senderReports.Do(func() {
+		tracks := make([]*sessionTrack, 0, len(s.tracks))
+		for _, track := range s.tracks {
+			tracks = append(tracks, track)
+		}
+		go s.runSenderReports(tracks)
+	})
+}
+
+func (s *Session) runSenderReports(tracks []*sessionTrack) {
+	ticker := time.NewTicker(senderReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.sendSenderReports(tracks, now); err != nil {
+				slog.Warn("Failed to send RTCP sender report", "sessionId", s.sessionId, "err", err)
+				return
+			}
+		}
+	}
+}
+
+// sendSenderReports sends an SR for each track that relayed packets recently
+// on the RTCP channel after its RTP channel
+func (s *Session) sendSenderReports(tracks []*sessionTrack, now time.Time) error {
+	for _, track := range tracks {
+		report, ok := track.senderReport(now, 2*senderReportInterval)
+		if !ok {
+			continue
+		}
+		data, err := report.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := s.writeInterleavedFrame(track.rtpChannel+1, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
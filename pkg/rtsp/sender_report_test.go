@@ -0,0 +1,56 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"sol/pkg/rtp"
+	"testing"
+	"time"
+)
+
+func TestInterleavedSenderReport(t *testing.T) {
+	session, conn, _ := newTestSession()
+	setupWithTransport(t, session, conn, "track1", "RTP/AVP/TCP;unicast;interleaved=4-5")
+	setupWithTransport(t, session, conn, "track2", "RTP/AVP/TCP;unicast;interleaved=6-7")
+	video := session.tracks[TrackVideo]
+
+	payload := []byte{0x65, 0x01, 0x02, 0x03}
+	data, err := rtp.NewRTPPacket(rtp.PayloadTypeH264, 1, 90000, 0x11111111, payload).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	if err := session.SendTrackRTPPacket(TrackVideo, data); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+	conn.buf.Reset()
+
+	// Only the video track sent packets, so only it gets a report
+	now := video.lastSentAt.Add(time.Second)
+	if err := session.sendSenderReports([]*sessionTrack{video, session.tracks[TrackAudio]}, now); err != nil {
+		t.Fatalf("Failed to send sender reports: %v", err)
+	}
+	frame := conn.buf.Bytes()
+	if len(frame) != 4+rtp.RTCPSenderReportSize {
+		t.Fatalf("Expected one interleaved SR frame, got %d bytes", len(frame))
+	}
+	if frame[0] != '$' || frame[1] != 5 || int(binary.BigEndian.Uint16(frame[2:4])) != rtp.RTCPSenderReportSize {
+		t.Fatalf("Expected an SR frame on RTCP channel 5, got header %v", frame[:4])
+	}
+
+	report := &rtp.RTCPSenderReport{}
+	if err := report.Unmarshal(frame[4:]); err != nil {
+		t.Fatalf("Failed to parse sender report: %v", err)
+	}
+	if report.SSRC != video.ssrc || report.PacketCount != 1 || report.OctetCount != uint32(len(payload)) {
+		t.Errorf("Expected SSRC %#x with 1 packet of %d bytes, got %+v", video.ssrc, len(payload), report)
+	}
+	// One second after the packet on the 90kHz clock
+	if report.RTPTimestamp != 180000 || report.NTPTime.Sub(now).Abs() > time.Microsecond {
+		t.Errorf("Expected RTP timestamp 180000 at %v, got %d at %v", now, report.RTPTimestamp, report.NTPTime)
+	}
+
+	// A track idle for more than two intervals gets no report
+	conn.buf.Reset()
+	if err := session.sendSenderReports([]*sessionTrack{video}, now.Add(3*senderReportInterval)); err != nil || conn.buf.Len() != 0 {
+		t.Fatalf("Expected no report for an idle track, got %d bytes, %v", conn.buf.Len(), err)
+	}
+}
@@ -2,49 +2,154 @@ package rtsp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"sol/pkg/acl"
+	"sol/pkg/deadletter"
 	"sol/pkg/rtp"
+	"sol/pkg/tcpopt"
+	"sync"
+	"time"
 )
 
 // RTSPConfig represents RTSP server configuration
 type RTSPConfig struct {
-	Port    int
-	Timeout int // seconds
+	Port      int
+	Timeout   int             // seconds
+	PlayStart PlayStartPolicy // starting point for PLAY without Range
+	RTPMTU    int             // maximum RTP packet size (0 = rtp.DefaultMTU)
+	Access    *acl.Policy     // source address access control (nil allows all)
+
+	// Batch interleaved RTP frames up to this many bytes (0 = write each frame)
+	InterleavedFlushSize int
+	// Longest a batched frame waits (0 = DefaultInterleavedFlushInterval)
+	InterleavedFlushInterval time.Duration
+	// Largest interleaved frame accepted from clients; larger ones close the session (0 = DefaultMaxInterleavedFrameSize)
+	MaxInterleavedFrameSize int
+	// How SETUP assigns interleaved channel pairs to TCP tracks (zero value = ChannelsFromClient)
+	InterleavedChannels ChannelAssignment
+
+	// Log events that reach the event loop without a handler (they are always counted)
+	LogUnhandledEvents bool
+
+	// Redirects DESCRIBE to another node, e.g. for load distribution (nil serves every stream locally)
+	RedirectResolver RedirectResolver
+
+	// Offer RTX (RFC 4588) in the SDP and retransmit NACKed packets to UDP players
+	RTX bool
+
+	// Session name, info and advertised address of generated SDPs (empty fields use the defaults)
+	SDP SDPOptions
+
+	// Socket options set on accepted TCP connections (nil keeps the Go defaults)
+	TCPOptions *tcpopt.Options
+}
+
+// SDPOptions are the session-level fields of SDPs generated for DESCRIBE
+type SDPOptions struct {
+	SessionName string // s= (empty = DefaultSDPSessionName)
+	Info        string // i= (empty = DefaultSDPSessionInfo)
+	Address     string // IP for the o= and c= lines of generated and relayed SDPs, e.g. the public address behind NAT (empty = the address the client connected to)
+}
+
+// withDefaults fills empty options with the default values
+func (o SDPOptions) withDefaults() SDPOptions {
+	if o.SessionName == "" {
+		o.SessionName = DefaultSDPSessionName
+	}
+	if o.Info == "" {
+		o.Info = DefaultSDPSessionInfo
+	}
+	return o
 }
 
+// RedirectResolver returns the URL a DESCRIBE of streamPath (the request URI)
+// should be redirected to, or "" to serve the stream from this server.
+// permanent selects 301 Moved Permanently instead of 302 Moved Temporarily.
+type RedirectResolver func(streamPath string) (location string, permanent bool)
+
+// Accept retry backoff bounds for temporary listener errors
+const (
+	acceptRetryMinDelay = 5 * time.Millisecond
+	acceptRetryMaxDelay = time.Second
+)
+
 // Server represents an RTSP server
 type Server struct {
 	port            int
 	timeout         int
+	playStartPolicy PlayStartPolicy
+	channels        ChannelAssignment
+	access          *acl.Policy
+	flushSize       int
+	flushInterval   time.Duration
+	maxFrameSize    int
+	redirect        RedirectResolver
+	rtx             bool
+	sdpOptions      SDPOptions
+	tcpOptions      *tcpopt.Options
 	sessions        map[string]*Session // sessionId -> session
+	sessionsMu      sync.Mutex          // guards sessions (accept loop, event loop and Stop)
 	streamManager   *StreamManager
 	rtpTransport    *rtp.RTPTransport
-	rtpStarted      bool
+	rtpStarted      bool       // the server started rtpTransport and stops it on Stop
+	rtpMu           sync.Mutex // guards starting the transport from session goroutines
+	deadLetters     *deadletter.Recorder // records events without a handler
 	channel         chan interface{}
 	listener        net.Listener
 	ctx             context.Context
 	cancel          context.CancelFunc
+	stopOnce        sync.Once
 }
 
-// NewServer creates a new RTSP server
+// NewServer creates a new RTSP server with its own RTP transport
 func NewServer(config RTSPConfig) *Server {
+	return NewServerWithTransport(config, rtp.NewRTPTransport())
+}
+
+// NewServerWithTransport creates a new RTSP server that hands rtpTransport to
+// every session. A transport whose UDP listener is already started is used
+// as is and left running on Stop; the caller owns it.
+func NewServerWithTransport(config RTSPConfig, rtpTransport *rtp.RTPTransport) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
-	
+
+	if config.RTPMTU != 0 {
+		if err := rtpTransport.SetMTU(config.RTPMTU); err != nil {
+			slog.Warn("Invalid RTP MTU, using default", "mtu", config.RTPMTU, "default", rtp.DefaultMTU, "err", err)
+		}
+	}
+
 	return &Server{
-		port:          config.Port,
-		timeout:       config.Timeout,
-		sessions:      make(map[string]*Session),
-		streamManager: NewStreamManager(),
-		rtpTransport:  rtp.NewRTPTransport(),
-		channel:       make(chan interface{}, 100),
-		ctx:           ctx,
-		cancel:        cancel,
+		port:            config.Port,
+		timeout:         config.Timeout,
+		playStartPolicy: config.PlayStart,
+		channels:        config.InterleavedChannels,
+		access:          config.Access,
+		flushSize:       config.InterleavedFlushSize,
+		flushInterval:   config.InterleavedFlushInterval,
+		maxFrameSize:    config.MaxInterleavedFrameSize,
+		redirect:        config.RedirectResolver,
+		rtx:             config.RTX,
+		sdpOptions:      config.SDP.withDefaults(),
+		tcpOptions:      config.TCPOptions,
+		sessions:        make(map[string]*Session),
+		streamManager:   NewStreamManager(),
+		rtpTransport:    rtpTransport,
+		deadLetters:     deadletter.New("rtsp", config.LogUnhandledEvents),
+		channel:         make(chan interface{}, 100),
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 }
 
+// GetUnhandledEventCount returns the number of events dropped for lack of a handler
+func (s *Server) GetUnhandledEventCount() uint64 {
+	return s.deadLetters.Count()
+}
+
 // Start starts the RTSP server
 func (s *Server) Start() error {
 	ln, err := s.createListener()
@@ -64,18 +169,20 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Stop stops the RTSP server
+// Stop stops the RTSP server (safe to call more than once)
 func (s *Server) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
 	slog.Info("RTSP Server stopping...")
 	
 	// Cancel context
 	s.cancel()
 	
-	// Stop RTP transport
-	if s.rtpStarted {
-		s.rtpTransport.Stop()
-	}
-	
+	// Shutdown order: stop accepting, close sessions (sending RTCP BYE over
+	// the transport), then stop the RTP transport so no RTP outlives its session
+
 	// Close listener
 	if s.listener != nil {
 		if err := s.listener.Close(); err != nil {
@@ -85,15 +192,26 @@ func (s *Server) Stop() {
 		}
 	}
 	
-	// Close all sessions
-	slog.Info("Closing all RTSP sessions", "sessionCount", len(s.sessions))
-	for sessionId, session := range s.sessions {
+	// Close all sessions (outside the lock: stopping a session can report its termination)
+	s.sessionsMu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*Session)
+	s.sessionsMu.Unlock()
+
+	slog.Info("Closing all RTSP sessions", "sessionCount", len(sessions))
+	for sessionId, session := range sessions {
 		session.Stop()
 		slog.Debug("RTSP session stopped", "sessionId", sessionId)
 	}
-	
-	// Clear data structures
-	s.sessions = make(map[string]*Session)
+
+	// Stop RTP transport last and release its UDP ports (only if this server started it)
+	s.rtpMu.Lock()
+	if s.rtpStarted {
+		s.rtpTransport.Stop()
+		s.rtpStarted = false
+		slog.Info("RTP transport stopped with RTSP server")
+	}
+	s.rtpMu.Unlock()
 	
 	// Clean up channel
 	for {
@@ -143,13 +261,13 @@ func (s *Server) handleEvent(event interface{}) {
 	case RTPPacketReceived:
 		s.handleRTPPacketReceived(e)
 	default:
-		slog.Warn("Unknown RTSP event type", "eventType", fmt.Sprintf("%T", e))
+		s.deadLetters.Record(e)
 	}
 }
 
 // handleSessionTerminated handles session termination
 func (s *Server) handleSessionTerminated(event SessionTerminated) {
-	session := s.sessions[event.SessionId]
+	session := s.getSession(event.SessionId)
 	if session == nil {
 		slog.Warn("Session not found for termination", "sessionId", event.SessionId)
 		return
@@ -166,7 +284,7 @@ func (s *Server) handleSessionTerminated(event SessionTerminated) {
 	}
 
 	// Remove session from server
-	delete(s.sessions, event.SessionId)
+	s.removeSession(event.SessionId)
 	slog.Info("RTSP session terminated", "sessionId", event.SessionId)
 }
 
@@ -178,7 +296,7 @@ func (s *Server) handleDescribeRequested(event DescribeRequested) {
 	stream := s.streamManager.GetOrCreateStream(event.StreamPath)
 	
 	// Add session to stream
-	if session := s.sessions[event.SessionId]; session != nil {
+	if session := s.getSession(event.SessionId); session != nil {
 		stream.AddSession(session)
 	}
 }
@@ -195,8 +313,13 @@ func (s *Server) handlePlayStarted(event PlayStarted) {
 	}
 	
 	// Add session as player
-	if session := s.sessions[event.SessionId]; session != nil {
+	if session := s.getSession(event.SessionId); session != nil {
 		stream.AddPlayer(session)
+
+		// Catch-up playback: replay buffered packets before live data
+		if event.FromStart {
+			stream.SendBufferedPackets(session)
+		}
 	}
 }
 
@@ -211,7 +334,7 @@ func (s *Server) handlePlayStopped(event PlayStopped) {
 	}
 	
 	// Remove session as player
-	if session := s.sessions[event.SessionId]; session != nil {
+	if session := s.getSession(event.SessionId); session != nil {
 		stream.RemovePlayer(session)
 	}
 }
@@ -224,7 +347,7 @@ func (s *Server) handleRecordStarted(event RecordStarted) {
 	stream := s.streamManager.GetOrCreateStream(event.StreamPath)
 	
 	// Set session as publisher
-	if session := s.sessions[event.SessionId]; session != nil {
+	if session := s.getSession(event.SessionId); session != nil {
 		stream.SetPublisher(session, "")
 	}
 }
@@ -244,10 +367,15 @@ func (s *Server) handleAnnounceReceived(event AnnounceReceived) {
 	stream := s.streamManager.GetOrCreateStream(event.StreamPath)
 	
 	// Set session as publisher with SDP
-	if session := s.sessions[event.SessionId]; session != nil {
+	if session := s.getSession(event.SessionId); session != nil {
 		stream.SetPublisher(session, event.SDP)
 		stream.AddSession(session)
 	}
+
+	// Describe the stream to players from what the publisher announced
+	if sdp, err := ParseSDP(event.SDP); err == nil {
+		stream.SetMetadata(metadataFromSDP(sdp))
+	}
 }
 
 // handleRTPPacketReceived handles RTP packets
@@ -259,9 +387,20 @@ func (s *Server) handleRTPPacketReceived(event RTPPacketReceived) {
 	if stream == nil {
 		return
 	}
+
+	// Relay only the stream's own publisher
+	if session := s.getSession(event.SessionId); session == nil || !stream.IsPublisher(session) {
+		slog.Debug("Dropped RTP packet from a session that is not the stream publisher", "sessionId", event.SessionId, "streamPath", event.StreamPath)
+		return
+	}
 	
-	// Broadcast to all players
-	stream.BroadcastRTPPacket(event.Data)
+	// Broadcast to the players of the packet's track
+	switch event.Track {
+	case TrackAudio:
+		stream.BroadcastAudioRTP(event.Data)
+	default:
+		stream.BroadcastVideoRTP(event.Data)
+	}
 }
 
 // createListener creates a TCP listener
@@ -280,6 +419,7 @@ func (s *Server) createListener() (net.Listener, error) {
 func (s *Server) acceptConnections(ln net.Listener) {
 	defer closeWithLog(ln)
 	
+	var retryDelay time.Duration
 	for {
 		// Check for context cancellation
 		select {
@@ -297,14 +437,58 @@ func (s *Server) acceptConnections(ln net.Listener) {
 				slog.Info("RTSP accept loop stopped (listener closed)")
 				return
 			default:
-				slog.Error("RTSP accept failed", "err", err)
+			}
+
+			if errors.Is(err, net.ErrClosed) {
+				slog.Info("RTSP accept loop stopped (listener closed)")
 				return
 			}
+
+			// Temporary failures such as EMFILE: back off and keep accepting
+			retryDelay = nextAcceptRetryDelay(retryDelay)
+			slog.Error("RTSP accept failed, retrying", "err", err, "retryDelay", retryDelay)
+			select {
+			case <-s.ctx.Done():
+				slog.Info("RTSP accept loop stopping...")
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
 		}
+		retryDelay = 0
 		
+		// Reject disallowed source addresses before reading any request
+		if !s.access.AllowConnect(conn.RemoteAddr()) {
+			slog.Warn("RTSP connection rejected by access control", "remoteAddr", conn.RemoteAddr())
+			closeWithLog(conn)
+			continue
+		}
+
+		// A connection whose options could not be set is still served
+		if err := s.tcpOptions.Apply(conn); err != nil {
+			slog.Warn("Failed to apply TCP options", "remoteAddr", conn.RemoteAddr(), "err", err)
+		}
+
 		// Create new session
 		session := NewSession(conn, s.channel, s.rtpTransport)
-		s.sessions[session.sessionId] = session
+		session.playStartPolicy = s.playStartPolicy
+		session.channels = newChannelAllocator(s.channels)
+		session.access = s.access
+		session.streamManager = s.streamManager
+		session.redirect = s.redirect
+		session.startRTP = s.ensureRTPTransport
+		session.rtx = s.rtx
+		session.sdpOptions = s.sdpOptions
+		if s.timeout > 0 {
+			session.timeout = time.Duration(s.timeout) * time.Second
+		}
+		if s.flushSize > 0 {
+			session.writer.EnableFrameBatching(s.flushSize, s.flushInterval)
+		}
+		if s.maxFrameSize > 0 {
+			session.maxFrameSize = s.maxFrameSize
+		}
+		s.addSession(session)
 		
 		// Start session handling
 		session.Start()
@@ -313,6 +497,46 @@ func (s *Server) acceptConnections(ln net.Listener) {
 	}
 }
 
+// addSession registers a session accepted by the accept loop
+func (s *Server) addSession(session *Session) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.sessions[session.sessionId] = session
+}
+
+// getSession returns the session with sessionId (nil if it is gone)
+func (s *Server) getSession(sessionId string) *Session {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return s.sessions[sessionId]
+}
+
+// removeSession forgets a terminated session
+func (s *Server) removeSession(sessionId string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	delete(s.sessions, sessionId)
+}
+
+// sessionCount returns the number of sessions the server tracks
+func (s *Server) sessionCount() int {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return len(s.sessions)
+}
+
+// nextAcceptRetryDelay doubles the accept retry delay up to acceptRetryMaxDelay
+func nextAcceptRetryDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return acceptRetryMinDelay
+	}
+	delay *= 2
+	if delay > acceptRetryMaxDelay {
+		return acceptRetryMaxDelay
+	}
+	return delay
+}
+
 // closeWithLog closes a resource with logging
 func closeWithLog(c io.Closer) {
 	if err := c.Close(); err != nil {
@@ -321,13 +545,17 @@ func closeWithLog(c io.Closer) {
 }
 
 // ensureRTPTransport starts RTP transport if not already started
+// (called by sessions on their first UDP SETUP)
 func (s *Server) ensureRTPTransport() error {
-	if s.rtpStarted {
+	s.rtpMu.Lock()
+	defer s.rtpMu.Unlock()
+
+	if s.rtpStarted || s.rtpTransport.IsStarted() {
 		return nil
 	}
 	
-	// Start RTP transport (use base port + 1000 for RTP port)
-	rtpPort := s.port + 1000
+	// Start RTP transport (use base port + RTPPortOffset for RTP port)
+	rtpPort := s.port + RTPPortOffset
 	if err := s.rtpTransport.StartUDP(rtpPort); err != nil {
 		slog.Error("Failed to start RTP transport", "err", err)
 		return err
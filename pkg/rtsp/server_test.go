@@ -0,0 +1,501 @@
+package rtsp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sol/pkg/rtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyListener returns a number of temporary errors before handing out connections
+type flakyListener struct {
+	failures int
+	conns    chan net.Conn
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "accept: too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, temporaryError{}
+	}
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return conn, nil
+}
+
+func (l *flakyListener) Close() error   { return nil }
+func (l *flakyListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestAcceptConnectionsRecoversFromTemporaryError(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	defer server.cancel()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	ln := &flakyListener{failures: 3, conns: make(chan net.Conn, 1)}
+	ln.conns <- serverConn
+	close(ln.conns)
+
+	done := make(chan struct{})
+	go func() {
+		server.acceptConnections(ln)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected accept loop to exit after listener closed")
+	}
+	if ln.failures != 0 {
+		t.Fatalf("Expected all temporary errors to be consumed, %d left", ln.failures)
+	}
+	if len(server.sessions) != 1 {
+		t.Fatalf("Expected 1 session after recovery, got %d", len(server.sessions))
+	}
+}
+
+func TestRecordedStreamDescribedFromAnnounce(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	defer server.cancel()
+
+	publisher, _, _ := newTestSession()
+	server.sessions[publisher.sessionId] = publisher
+	announced := "v=0\r\n" +
+		"o=- 0 0 IN IP4 192.168.0.10\r\n" +
+		"s=Publisher\r\n" +
+		"t=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO4xsg==\r\n" +
+		"m=audio 0 RTP/AVP 8\r\n"
+	server.handleAnnounceReceived(AnnounceReceived{SessionId: publisher.sessionId, StreamPath: "rtsp://localhost/live/test", SDP: announced})
+	server.handleRecordStarted(RecordStarted{SessionId: publisher.sessionId, StreamPath: "rtsp://localhost/live/test"})
+
+	player, conn, _ := newTestSession()
+	player.streamManager = server.streamManager
+	if err := player.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	response := readResponse(t, conn)
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	sdp := string(response.Body)
+	for _, line := range []string{
+		"a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO4xsg==\r\n",
+		"m=audio 0 RTP/AVP 8\r\n",
+	} {
+		if !strings.Contains(sdp, line) {
+			t.Errorf("Expected SDP to contain %q:\n%s", line, sdp)
+		}
+	}
+	if strings.Contains(sdp, "m=audio 0 RTP/AVP 97") {
+		t.Errorf("Expected the announced G.711 audio, not the default AAC:\n%s", sdp)
+	}
+}
+
+func TestRTPFromNonPublisherNotRelayed(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	defer server.cancel()
+
+	streamPath := "rtsp://localhost/live/test"
+	publisher, _, _ := newTestSession()
+	server.sessions[publisher.sessionId] = publisher
+	server.handleAnnounceReceived(AnnounceReceived{SessionId: publisher.sessionId, StreamPath: streamPath, SDP: "v=0\r\nm=video 0 RTP/AVP 96\r\n"})
+
+	player, conn, _ := newTestSession()
+	setupTrack(t, player, conn, "track1", 0)
+	server.sessions[player.sessionId] = player
+	server.streamManager.GetStream(streamPath).AddPlayer(player)
+
+	data := newTestRTPPacket(t, rtp.PayloadTypeH264, 0x65)
+	server.handleRTPPacketReceived(RTPPacketReceived{SessionId: player.sessionId, StreamPath: streamPath, Track: TrackVideo, Data: data})
+	if frames := readInterleavedFrames(t, conn); len(frames) != 0 {
+		t.Fatalf("Expected a player's packet not to be relayed, got %d frames", len(frames))
+	}
+
+	server.handleRTPPacketReceived(RTPPacketReceived{SessionId: publisher.sessionId, StreamPath: streamPath, Track: TrackVideo, Data: data})
+	if frames := readInterleavedFrames(t, conn); len(frames) != 1 {
+		t.Fatalf("Expected the publisher's packet to be relayed, got %d frames", len(frames))
+	}
+}
+
+func TestSessionsCreatedAndTerminatedConcurrently(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.listener = ln
+	go server.eventLoop()
+	go server.acceptConnections(ln)
+
+	// Each client waits for its OPTIONS response so the session is registered,
+	// then disconnects while other sessions are still being accepted
+	const clients = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer client.Close()
+			if err := NewMessageWriter(client).WriteRequest(newTestRequest(MethodOptions, 1, nil)); err != nil {
+				errs <- err
+				return
+			}
+			client.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if _, err := NewMessageReader(client).ReadResponse(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed to exchange OPTIONS: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.sessionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected every session to be removed, %d left", server.sessionCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.Stop()
+}
+
+func TestNextAcceptRetryDelay(t *testing.T) {
+	delay := nextAcceptRetryDelay(0)
+	if delay != acceptRetryMinDelay {
+		t.Errorf("Expected %v, got %v", acceptRetryMinDelay, delay)
+	}
+	for i := 0; i < 20; i++ {
+		delay = nextAcceptRetryDelay(delay)
+	}
+	if delay != acceptRetryMaxDelay {
+		t.Errorf("Expected delay capped at %v, got %v", acceptRetryMaxDelay, delay)
+	}
+}
+
+func TestServerSessionsUseInjectedTransport(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	server := NewServerWithTransport(RTSPConfig{}, transport)
+	defer server.cancel()
+	if err := server.ensureRTPTransport(); err != nil || server.rtpStarted {
+		t.Fatalf("Expected started transport to be used as is, err=%v started=%v", err, server.rtpStarted)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		server.acceptConnections(ln)
+		close(done)
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	setup := newTestRequest(MethodSetup, 1, map[string]string{
+		HeaderTransport: "RTP/AVP;unicast;client_port=5000-5001",
+	})
+	if err := NewMessageWriter(client).WriteRequest(setup); err != nil {
+		t.Fatalf("Failed to write SETUP: %v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	response, err := NewMessageReader(client).ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for UDP SETUP, got %d", response.StatusCode)
+	}
+	serverPort := fmt.Sprintf("server_port=%d", transport.Port())
+	if !strings.Contains(response.GetHeader(HeaderTransport), serverPort) {
+		t.Errorf("Expected Transport with %s, got %q", serverPort, response.GetHeader(HeaderTransport))
+	}
+
+	ln.Close()
+	<-done
+	if len(server.sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(server.sessions))
+	}
+	for _, session := range server.sessions {
+		if session.rtpTransport != transport {
+			t.Errorf("Expected session to use the injected transport")
+		}
+		if !session.HasTrack(TrackVideo) || session.tracks[TrackVideo].rtpSession == nil {
+			t.Errorf("Expected UDP RTP session for the video track")
+		}
+	}
+}
+
+func TestStopClosesSessionsBeforeTransport(t *testing.T) {
+	// Pick a free UDP port pair for the transport the server starts on demand
+	probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	rtpPort := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	server := NewServer(RTSPConfig{Port: rtpPort - RTPPortOffset})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.listener = ln
+	go server.acceptConnections(ln)
+
+	// The client RTCP socket receives the BYE; its (even) RTP port is one below
+	var clientRTCP net.PacketConn
+	for clientRTCP == nil {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		if conn.LocalAddr().(*net.UDPAddr).Port%2 == 1 {
+			clientRTCP = conn
+		} else {
+			conn.Close()
+		}
+	}
+	defer clientRTCP.Close()
+	clientRTPPort := clientRTCP.LocalAddr().(*net.UDPAddr).Port - 1
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	setup := newTestRequest(MethodSetup, 1, map[string]string{
+		HeaderTransport: fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", clientRTPPort, clientRTPPort+1),
+	})
+	if err := NewMessageWriter(client).WriteRequest(setup); err != nil {
+		t.Fatalf("Failed to write SETUP: %v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	response, err := NewMessageReader(client).ReadResponse()
+	if err != nil || response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for UDP SETUP, got %v (err: %v)", response, err)
+	}
+	if !server.rtpTransport.IsStarted() || server.rtpTransport.Port() != rtpPort {
+		t.Fatalf("Expected the first UDP SETUP to start the transport on port %d", rtpPort)
+	}
+
+	server.Stop()
+
+	// The session's BYE still went out over the running transport
+	clientRTCP.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := clientRTCP.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected RTCP BYE before the transport stopped, got error: %v", err)
+	}
+	if err := (&rtp.RTCPBye{}).Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Failed to parse RTCP BYE: %v", err)
+	}
+
+	// Then the transport stopped and released its RTP and RTCP ports
+	if server.rtpTransport.IsStarted() {
+		t.Error("Expected RTP transport to be stopped")
+	}
+	for _, port := range []int{rtpPort, rtpPort + 1} {
+		conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			t.Errorf("Expected UDP port %d to be released, got %v", port, err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+func TestStopTwiceIsSafe(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.listener = ln
+
+	// A signal handler and a deferred Stop may both run
+	server.Stop()
+	server.Stop()
+
+	if server.ctx.Err() == nil {
+		t.Fatal("Expected the server context to be cancelled")
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Fatal("Expected the listener to be closed")
+	}
+}
+
+func TestInterleavedPlaybackLifecycle(t *testing.T) {
+	server := NewServer(RTSPConfig{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.listener = ln
+	go server.eventLoop()
+	go server.acceptConnections(ln)
+	defer server.Stop()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := NewMessageReader(client)
+	writer := NewMessageWriter(client)
+
+	uri := fmt.Sprintf("rtsp://%s/live/test", ln.Addr())
+	expectOK := func(method string) *Response {
+		t.Helper()
+		response, err := reader.ReadResponse()
+		if err != nil {
+			t.Fatalf("Failed to read %s response: %v", method, err)
+		}
+		if response.StatusCode != StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", method, response.StatusCode)
+		}
+		return response
+	}
+
+	// OPTIONS and DESCRIBE pipelined in one write, as some clients do
+	options := NewRequest(MethodOptions, uri)
+	options.SetCSeq(1)
+	describe := NewRequest(MethodDescribe, uri)
+	describe.SetCSeq(2)
+	describe.SetHeader(HeaderAccept, "application/sdp")
+	if _, err := client.Write(append(options.Bytes(), describe.Bytes()...)); err != nil {
+		t.Fatalf("Failed to write OPTIONS and DESCRIBE: %v", err)
+	}
+	if response := expectOK(MethodOptions); !strings.Contains(response.GetHeader(HeaderPublic), MethodDescribe) {
+		t.Fatalf("Expected DESCRIBE in Public, got %q", response.GetHeader(HeaderPublic))
+	}
+	response := expectOK(MethodDescribe)
+	if response.CSeq != 2 || response.GetHeader(HeaderContentType) != "application/sdp" {
+		t.Fatalf("Expected SDP answer to CSeq 2, got CSeq %d with %q", response.CSeq, response.GetHeader(HeaderContentType))
+	}
+	body := string(response.Body)
+	if strings.Count(body, "\n") != strings.Count(body, "\r\n") || !strings.HasSuffix(body, "\r\n") {
+		t.Fatalf("Expected every SDP line to end with CRLF, got %q", body)
+	}
+	sdp, err := ParseSDP(body)
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	var videoControl string
+	for _, media := range sdp.Media {
+		if media.Type == "video" {
+			videoControl = media.Control()
+		}
+	}
+	if videoControl == "" {
+		t.Fatalf("Expected a video track with a control attribute, got %q", body)
+	}
+
+	setup := NewRequest(MethodSetup, uri+"/"+videoControl)
+	setup.SetCSeq(3)
+	setup.SetHeader(HeaderTransport, "RTP/AVP/TCP;unicast;interleaved=0-1")
+	if err := writer.WriteRequest(setup); err != nil {
+		t.Fatalf("Failed to write SETUP: %v", err)
+	}
+	response = expectOK(MethodSetup)
+	if transport := response.GetHeader(HeaderTransport); !strings.Contains(transport, "interleaved=0-1") {
+		t.Fatalf("Expected interleaved=0-1 in Transport, got %q", transport)
+	}
+	sessionId := strings.Split(response.GetHeader(HeaderSession), ";")[0]
+
+	play := NewRequest(MethodPlay, uri)
+	play.SetCSeq(4)
+	play.SetHeader(HeaderSession, sessionId)
+	if err := writer.WriteRequest(play); err != nil {
+		t.Fatalf("Failed to write PLAY: %v", err)
+	}
+	expectOK(MethodPlay)
+
+	// Once the event loop has added the player, a published packet arrives interleaved on channel 0
+	deadline := time.Now().Add(2 * time.Second)
+	stream := server.streamManager.GetStream(uri)
+	for stream == nil || stream.GetPlayerCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the PLAY session to be added as a player")
+		}
+		time.Sleep(5 * time.Millisecond)
+		stream = server.streamManager.GetStream(uri)
+	}
+	packet := rtp.NewRTPPacket(rtp.PayloadTypeH264, 100, 90000, 0x11111111, []byte{0x65, 0x01, 0x02})
+	packet.SetMarker(true)
+	data, err := packet.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	stream.BroadcastVideoRTP(data)
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatalf("Failed to read interleaved frame: %v", err)
+	}
+	if header[0] != '$' || header[1] != 0 {
+		t.Fatalf("Expected an interleaved frame on channel 0, got %v", header)
+	}
+	frame := make([]byte, int(header[2])<<8|int(header[3]))
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		t.Fatalf("Failed to read interleaved frame: %v", err)
+	}
+	received := &rtp.RTPPacket{}
+	if err := received.Unmarshal(frame); err != nil {
+		t.Fatalf("Failed to parse RTP packet: %v", err)
+	}
+	if received.Header.Timestamp != 90000 || !received.Header.Marker || !bytes.Equal(received.Payload, []byte{0x65, 0x01, 0x02}) {
+		t.Fatalf("Expected the published frame, got %+v", received.Header)
+	}
+
+	teardown := NewRequest(MethodTeardown, uri)
+	teardown.SetCSeq(5)
+	teardown.SetHeader(HeaderSession, sessionId)
+	if err := writer.WriteRequest(teardown); err != nil {
+		t.Fatalf("Failed to write TEARDOWN: %v", err)
+	}
+	expectOK(MethodTeardown)
+
+	// The server closes the connection after answering TEARDOWN
+	if _, err := reader.PeekByte(); err != io.EOF {
+		t.Fatalf("Expected the connection to be closed after TEARDOWN, got %v", err)
+	}
+}
@@ -2,16 +2,38 @@ package rtsp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"slices"
+	"sol/pkg/acl"
 	"sol/pkg/rtp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrInvalidClientPort is returned when a Transport header carries an unusable client_port pair
+var ErrInvalidClientPort = errors.New("invalid client_port")
+
+// ErrUnsupportedTransportMode is returned when a Transport header asks for an unknown mode
+var ErrUnsupportedTransportMode = errors.New("unsupported transport mode")
+
+// ErrInterleavedFrameTooLarge is returned when a client claims an interleaved frame
+// longer than the session allows; the stream cannot be trusted and the session is closed
+var ErrInterleavedFrameTooLarge = errors.New("interleaved frame too large")
+
+// maxTimeoutCheckInterval is the longest time between session idle checks
+const maxTimeoutCheckInterval = 10 * time.Second
+
+// closeFlushTimeout bounds the final flush on close so a client that stopped
+// reading cannot hold the writer lock and keep the session from shutting down
+const closeFlushTimeout = 2 * time.Second
+
 // Session represents an RTSP client session
 type Session struct {
 	sessionId       string
@@ -24,16 +46,33 @@ type Session struct {
 	clientPorts     []int // RTP port (UDP only)
 	serverPorts     []int // RTP port (UDP only)
 	transport       string
-	transportMode   TransportMode     // UDP or TCP mode
-	interleavedMode bool              // RTP over TCP interleaved
-	rtpChannel      int               // RTP channel number for TCP
-	rtpSession      *rtp.RTPSession   // RTP session for this RTSP session
-	rtpTransport    *rtp.RTPTransport // Reference to RTP transport
+	setupTracks     map[string]bool             // track URIs that completed SETUP
+	transportMode   TransportMode               // UDP or TCP mode
+	interleavedMode bool                        // RTP over TCP interleaved
+	direction       TransportDirection          // play (server sends) or record (client sends), from SETUP
+	rtpChannel      int                         // RTP channel requested by the last SETUP (TCP, -1 if none), then the one assigned
+	channels        *channelAllocator           // interleaved channel pairs of the TCP tracks
+	tracks          map[TrackType]*sessionTrack // per-track transport state from SETUP
+	offeredSSRCs    map[TrackType]uint32        // SSRCs advertised in the DESCRIBE SDP, used by SETUP
+	offeredRTXSSRCs map[TrackType]uint32        // RTX SSRCs advertised in the DESCRIBE SDP, used by SETUP
+	rtpTransport    *rtp.RTPTransport           // Reference to RTP transport
+	startRTP        func() error                // starts the server's RTP transport on the first UDP SETUP (nil if managed elsewhere)
 	timeout         time.Duration
-	lastActivity    time.Time
+	playStartPolicy PlayStartPolicy  // starting point for PLAY without Range
+	access          *acl.Policy      // source address access control (nil allows all)
+	streamManager   *StreamManager   // stream lookup for DESCRIBE (announced SDP)
+	redirect        RedirectResolver // DESCRIBE redirect to another node (nil serves locally)
+	announcedSDP    *SDP             // SDP from this session's ANNOUNCE (publishers)
+	rtx             bool             // offer RTX retransmission to UDP players
+	sdpOptions      SDPOptions       // session name, info and address of generated SDPs
+	maxFrameSize    int              // largest interleaved frame accepted from the client
+	lastActivity    atomic.Int64     // unix nanoseconds of the last request or media from the client
+	malformedRTP    atomic.Uint64    // RTP packets from the client dropped by validation
 	externalChannel chan interface{}
 	ctx             context.Context
 	cancel          context.CancelFunc
+	stopOnce        sync.Once
+	senderReports   sync.Once // starts RTCP sender reports on the first interleaved PLAY
 }
 
 // SessionState represents the current state of an RTSP session
@@ -46,6 +85,36 @@ const (
 	StateRecording
 )
 
+// PlayStartPolicy decides where a PLAY without a Range header starts in the stream buffer
+type PlayStartPolicy int
+
+const (
+	PlayFromLiveEdge PlayStartPolicy = iota
+	PlayFromStart
+)
+
+// ParsePlayStartPolicy converts a config value ("live" or "start") to a PlayStartPolicy
+func ParsePlayStartPolicy(value string) (PlayStartPolicy, error) {
+	switch value {
+	case PlayStartLive:
+		return PlayFromLiveEdge, nil
+	case PlayStartStart:
+		return PlayFromStart, nil
+	default:
+		return PlayFromLiveEdge, fmt.Errorf("invalid play start policy: %s (must be %s or %s)", value, PlayStartLive, PlayStartStart)
+	}
+}
+
+// String returns the config value of the play start policy
+func (p PlayStartPolicy) String() string {
+	switch p {
+	case PlayFromStart:
+		return PlayStartStart
+	default:
+		return PlayStartLive
+	}
+}
+
 // TransportMode represents the transport mode (UDP or TCP)
 type TransportMode int
 
@@ -54,26 +123,13 @@ const (
 	TransportTCP
 )
 
-// combinedReader helps us put back the first byte we peeked
-type combinedReader struct {
-	firstByte byte
-	hasFirst  bool
-	reader    io.Reader
-}
+// TransportDirection is the media direction requested by the Transport "mode" parameter
+type TransportDirection int
 
-func (cr *combinedReader) Read(p []byte) (n int, err error) {
-	if cr.hasFirst && len(p) > 0 {
-		p[0] = cr.firstByte
-		cr.hasFirst = false
-		if len(p) == 1 {
-			return 1, nil
-		}
-		// Read the rest from the underlying reader
-		n2, err2 := cr.reader.Read(p[1:])
-		return n2 + 1, err2
-	}
-	return cr.reader.Read(p)
-}
+const (
+	DirectionPlay   TransportDirection = iota // server sends RTP to the client (mode=play, the default)
+	DirectionRecord                           // client sends RTP to the server (mode=record or mode=receive)
+)
 
 // String returns the string representation of the session state
 func (s SessionState) String() string {
@@ -101,16 +157,23 @@ func NewSession(conn net.Conn, externalChannel chan interface{}, rtpTransport *r
 		writer:          NewMessageWriter(conn),
 		cseq:            0,
 		state:           StateInit,
+		setupTracks:     make(map[string]bool),
+		tracks:          make(map[TrackType]*sessionTrack),
+		offeredSSRCs:    make(map[TrackType]uint32),
+		offeredRTXSSRCs: make(map[TrackType]uint32),
+		channels:        newChannelAllocator(ChannelsFromClient),
 		timeout:         DefaultTimeout * time.Second,
-		lastActivity:    time.Now(),
 		externalChannel: externalChannel,
 		rtpTransport:    rtpTransport,
+		sdpOptions:      SDPOptions{SessionName: DefaultSDPSessionName, Info: DefaultSDPSessionInfo},
+		maxFrameSize:    DefaultMaxInterleavedFrameSize,
 		ctx:             ctx,
 		cancel:          cancel,
 	}
 
 	// 포인터 주소값을 sessionId로 사용
 	session.sessionId = fmt.Sprintf("%p", session)
+	session.touch()
 
 	return session
 }
@@ -123,15 +186,33 @@ func (s *Session) Start() {
 	go s.handleTimeout()
 }
 
-// Stop stops the session
+// Stop stops the session (safe to call more than once)
 func (s *Session) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Session) stop() {
 	slog.Info("RTSP session stopping", "sessionId", s.sessionId)
 
 	// Cancel context
 	s.cancel()
 
-	// Close connection
+	// Tell UDP clients the RTP sessions are over (RTCP BYE) and stop UDP ingest
+	if s.rtpTransport != nil {
+		for _, track := range s.tracks {
+			if track.rtpSession != nil {
+				s.rtpTransport.CloseSession(track.ssrc, "session closed")
+			}
+			if track.receiverKey != "" {
+				s.rtpTransport.UnregisterReceiver(track.receiverKey)
+			}
+		}
+	}
+
+	// Close connection (after sending any batched frames, best effort)
 	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(closeFlushTimeout))
+		s.writer.Flush()
 		s.conn.Close()
 	}
 
@@ -158,19 +239,18 @@ func (s *Session) handleRequests() {
 		// Set read timeout
 		s.conn.SetReadDeadline(time.Now().Add(s.timeout))
 
-		// Peek first byte to determine if it's RTSP request or interleaved data
-		firstByte := make([]byte, 1)
-		n, err := s.conn.Read(firstByte)
+		// Peek first byte to determine if it's RTSP request or interleaved data.
+		// The one reader is kept for the whole connection: a client may send a
+		// request and the next request or frame in one segment, and a reader
+		// built per request would drop whatever it had buffered past the first.
+		firstByte, err := s.reader.PeekByte()
 		if err != nil {
 			slog.Error("Failed to read from connection", "sessionId", s.sessionId, "err", err)
 			return
 		}
-		if n == 0 {
-			continue
-		}
 
 		// Check if it's interleaved data (starts with '$')
-		if firstByte[0] == '$' {
+		if firstByte == '$' {
 			if err := s.handleInterleavedData(); err != nil {
 				slog.Error("Failed to handle interleaved data", "sessionId", s.sessionId, "err", err)
 				return
@@ -178,65 +258,82 @@ func (s *Session) handleRequests() {
 			continue
 		}
 
-		// It's an RTSP request - put the byte back and read normally
-		combinedReader := &combinedReader{
-			firstByte: firstByte[0],
-			hasFirst:  true,
-			reader:    s.conn,
-		}
-		s.reader = NewMessageReader(combinedReader)
-
 		request, err := s.reader.ReadRequest()
 		if err != nil {
 			slog.Error("Failed to read RTSP request", "sessionId", s.sessionId, "err", err)
 			return
 		}
 
-		// Reset reader to use original connection
-		s.reader = NewMessageReader(s.conn)
-
-		s.lastActivity = time.Now()
+		// Every request counts as activity, including OPTIONS/GET_PARAMETER keep-alives
+		s.touch()
 		slog.Debug("RTSP request received", "sessionId", s.sessionId, "method", request.Method, "uri", request.URI, "cseq", request.CSeq)
 
 		if err := s.handleRequest(request); err != nil {
+			// The client went away while the response was written; nothing more can be sent
+			if writeErr := s.writer.Err(); writeErr != nil {
+				slog.Info("RTSP client closed the connection mid-response", "sessionId", s.sessionId, "method", request.Method, "err", writeErr)
+				return
+			}
 			slog.Error("Failed to handle RTSP request", "sessionId", s.sessionId, "method", request.Method, "err", err)
-			s.sendErrorResponse(request.CSeq, StatusInternalServerError)
+			if err := s.sendErrorResponse(request.CSeq, StatusInternalServerError); err != nil {
+				slog.Info("Failed to send RTSP error response", "sessionId", s.sessionId, "err", err)
+				return
+			}
 		}
 	}
 }
 
 // handleInterleavedData handles interleaved RTP/RTCP data
 func (s *Session) handleInterleavedData() error {
-	// Read the rest of the interleaved frame header
-	header := make([]byte, 3) // channel(1) + length(2)
-	if _, err := io.ReadFull(s.conn, header); err != nil {
+	// Read the interleaved frame header
+	header := make([]byte, 4) // '$'(1) + channel(1) + length(2)
+	if _, err := io.ReadFull(s.reader, header); err != nil {
 		return fmt.Errorf("failed to read interleaved header: %v", err)
 	}
 
-	channel := header[0]
-	length := (uint16(header[1]) << 8) | uint16(header[2])
+	channel := header[1]
+	length := (uint16(header[2]) << 8) | uint16(header[3])
+
+	// Check the claimed length before allocating for it
+	if int(length) > s.maxFrameSize {
+		return fmt.Errorf("%w: %d bytes on channel %d (max %d)", ErrInterleavedFrameTooLarge, length, channel, s.maxFrameSize)
+	}
 
 	// Read the data
 	data := make([]byte, length)
-	if _, err := io.ReadFull(s.conn, data); err != nil {
+	if _, err := io.ReadFull(s.reader, data); err != nil {
 		return fmt.Errorf("failed to read interleaved data: %v", err)
 	}
 
-	s.lastActivity = time.Now()
+	s.touch()
 
-	// Process the data based on channel
-	if int(channel) == s.rtpChannel {
-		// RTP data from client
-		slog.Debug("Received interleaved RTP data from client", "sessionId", s.sessionId, "dataSize", len(data))
+	// Process the data based on the track set up on this channel
+	if trackType, track, ok := s.trackForChannel(int(channel)); ok {
+		// Only a publisher's RTP is relayed; a player must not inject media into the stream
+		if s.direction != DirectionRecord && s.state != StateRecording {
+			slog.Debug("Dropped interleaved RTP from a non-publishing session", "sessionId", s.sessionId, "track", trackType, "dataSize", len(data))
+			return nil
+		}
+		// RTP data from client; drop anything that is not an RTP packet of the track
+		header, err := rtp.ValidateRTPPacket(data)
+		if err == nil {
+			err = track.checkPayloadType(header.PayloadType)
+		}
+		if err != nil {
+			s.dropMalformedPacket(trackType, len(data), err)
+			return nil
+		}
+		slog.Debug("Received interleaved RTP data from client", "sessionId", s.sessionId, "track", trackType, "dataSize", len(data))
 		// Send RTP packet received event
 		if s.externalChannel != nil {
 			select {
 			case s.externalChannel <- RTPPacketReceived{
 				SessionId:   s.sessionId,
 				StreamPath:  s.streamPath,
+				Track:       trackType,
 				Data:        data,
-				Timestamp:   0, // TODO: extract from RTP header
-				PayloadType: rtp.PayloadTypeH264,
+				Timestamp:   header.Timestamp,
+				PayloadType: track.payloadType,
 			}:
 			default:
 			}
@@ -248,9 +345,26 @@ func (s *Session) handleInterleavedData() error {
 	return nil
 }
 
+// touch records client activity, postponing the session timeout
+// (called from the request loop and from UDP receive goroutines)
+func (s *Session) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleTime returns how long the client has been silent
+func (s *Session) idleTime() time.Duration {
+	return time.Since(time.Unix(0, s.lastActivity.Load()))
+}
+
+// timeoutCheckInterval returns how often the idle time is checked: every
+// maxTimeoutCheckInterval, or twice per timeout for shorter timeouts
+func (s *Session) timeoutCheckInterval() time.Duration {
+	return min(maxTimeoutCheckInterval, s.timeout/2)
+}
+
 // handleTimeout handles session timeout
 func (s *Session) handleTimeout() {
-	ticker := time.NewTicker(10 * time.Second) // Check every 10 seconds
+	ticker := time.NewTicker(s.timeoutCheckInterval())
 	defer ticker.Stop()
 
 	for {
@@ -258,7 +372,7 @@ func (s *Session) handleTimeout() {
 		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
-			if time.Since(s.lastActivity) > s.timeout {
+			if s.idleTime() > s.timeout {
 				slog.Info("RTSP session timed out", "sessionId", s.sessionId)
 				s.Stop()
 				return
@@ -269,6 +383,15 @@ func (s *Session) handleTimeout() {
 
 // handleRequest handles a specific RTSP request
 func (s *Session) handleRequest(req *Request) error {
+	// Refuse requests that depend on options this server does not implement
+	if unsupported := unsupportedOptionTags(req); len(unsupported) > 0 {
+		slog.Info("RTSP request requires unsupported options", "sessionId", s.sessionId, "method", req.Method, "unsupported", unsupported)
+		response := NewResponse(StatusOptionNotSupported)
+		response.SetCSeq(req.CSeq)
+		response.SetHeader(HeaderUnsupported, strings.Join(unsupported, ", "))
+		return s.writer.WriteResponse(response)
+	}
+
 	// Validate session ID for non-setup requests
 	if req.Method != MethodOptions && req.Method != MethodDescribe && req.Method != MethodSetup && req.Method != MethodAnnounce {
 		sessionHeader := req.GetHeader(HeaderSession)
@@ -309,18 +432,63 @@ func (s *Session) handleRequest(req *Request) error {
 	}
 }
 
-// handleOptions handles OPTIONS request
+// supportedOptionTags are the Require and Proxy-Require option tags the
+// server implements. RTSP 1.0 defines none, so only basic playback is listed.
+var supportedOptionTags = map[string]bool{
+	"play.basic": true,
+}
+
+// unsupportedOptionTags returns the option tags named in the request's Require
+// and Proxy-Require headers that the server does not implement. A proxy in
+// front of the server forwards Proxy-Require unchanged, so both are checked
+// here.
+func unsupportedOptionTags(req *Request) []string {
+	var unsupported []string
+	for _, header := range []string{HeaderRequire, HeaderProxyRequire} {
+		for _, tag := range strings.Split(req.GetHeader(header), ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !supportedOptionTags[tag] && !slices.Contains(unsupported, tag) {
+				unsupported = append(unsupported, tag)
+			}
+		}
+	}
+	return unsupported
+}
+
+// handleOptions handles OPTIONS request.
+// Clients also send OPTIONS as a keep-alive while playing, often without a
+// Session header: the connection is the session, so any request on it has
+// already refreshed the timeout in handleRequests. A Session header, when
+// present, must name this session and is echoed with the timeout.
 func (s *Session) handleOptions(req *Request) error {
+	sessionHeader := req.GetHeader(HeaderSession)
+	if sessionHeader != "" && strings.Split(sessionHeader, ";")[0] != s.sessionId {
+		return s.sendErrorResponse(req.CSeq, StatusSessionNotFound)
+	}
+
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
 	response.SetHeader(HeaderPublic, "OPTIONS, DESCRIBE, SETUP, TEARDOWN, PLAY, PAUSE, ANNOUNCE, RECORD, GET_PARAMETER, SET_PARAMETER")
-	response.SetHeader(HeaderServer, "Sol RTSP Server")
+	if sessionHeader != "" {
+		response.SetHeader(HeaderSession, s.sessionHeader())
+	}
 
 	return s.writer.WriteResponse(response)
 }
 
 // handleDescribe handles DESCRIBE request
 func (s *Session) handleDescribe(req *Request) error {
+	if !s.access.AllowPlay(s.conn.RemoteAddr()) {
+		return s.sendForbidden(req, "DESCRIBE")
+	}
+
+	// Another node serves this stream
+	if s.redirect != nil {
+		if location, permanent := s.redirect(req.URI); location != "" {
+			return s.sendRedirect(req, location, permanent)
+		}
+	}
+
 	s.streamPath = req.URI
 
 	// Send DESCRIBE event
@@ -334,8 +502,12 @@ func (s *Session) handleDescribe(req *Request) error {
 		}
 	}
 
-	// Generate more detailed SDP
-	sdp := s.generateDetailedSDP()
+	// Announced SDP of the stream, or the description of its codecs
+	sdp, err := s.describeSDP()
+	if err != nil {
+		slog.Warn("Cannot describe stream", "sessionId", s.sessionId, "streamPath", s.streamPath, "err", err)
+		return s.sendErrorResponse(req.CSeq, StatusUnsupportedMediaType)
+	}
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
@@ -348,46 +520,101 @@ func (s *Session) handleDescribe(req *Request) error {
 
 // handleSetup handles SETUP request
 func (s *Session) handleSetup(req *Request) error {
+	// SETUP while the stream is playing/recording would reset an active transport
+	if s.state == StatePlaying || s.state == StateRecording {
+		slog.Warn("SETUP rejected in current state", "sessionId", s.sessionId, "state", s.state, "uri", req.URI)
+		return s.sendErrorResponse(req.CSeq, StatusMethodNotValidInThisState)
+	}
+
+	// The same track can only be set up once per session
+	if s.setupTracks[req.URI] {
+		slog.Warn("Duplicate SETUP rejected", "sessionId", s.sessionId, "uri", req.URI)
+		return s.sendErrorResponse(req.CSeq, StatusAggregateOperationNotAllowed)
+	}
+
 	// Parse transport header
 	transportHeader := req.GetHeader(HeaderTransport)
 	if transportHeader == "" {
 		return s.sendErrorResponse(req.CSeq, StatusBadRequest)
 	}
 
+	if err := s.parseTransport(transportHeader); err != nil {
+		slog.Warn("SETUP rejected: unsupported transport", "sessionId", s.sessionId, "transport", transportHeader, "err", err)
+		return s.sendErrorResponse(req.CSeq, StatusUnsupportedTransport)
+	}
 	s.transport = transportHeader
-	s.parseTransport(transportHeader)
+
+	// Each track gets its own SSRC, payload type and packetization mode
+	trackType, track := s.trackForURI(req.URI)
+	track.uri = req.URI
+	track.ssrc = s.trackSSRC(trackType)
+
+	// UDP needs the RTP transport listening before server ports are advertised
+	if !s.IsInterleavedMode() && s.rtpTransport != nil && s.startRTP != nil {
+		if err := s.startRTP(); err != nil {
+			slog.Error("Failed to start RTP transport", "sessionId", s.sessionId, "err", err)
+			return s.sendErrorResponse(req.CSeq, StatusInternalServerError)
+		}
+	}
 
 	// Create RTP session based on transport mode
 	if s.transportMode == TransportTCP && s.interleavedMode {
 		// TCP interleaved mode - no separate UDP session needed
-		slog.Info("TCP interleaved mode setup", "sessionId", s.sessionId, "rtpChannel", s.rtpChannel)
+		requested := s.rtpChannel
+		channel, err := s.channels.allocate(trackType, requested)
+		if err != nil {
+			slog.Warn("SETUP rejected: no interleaved channels left", "sessionId", s.sessionId, "track", trackType, "err", err)
+			return s.sendErrorResponse(req.CSeq, StatusUnsupportedTransport)
+		}
+		s.rtpChannel = channel
+		track.rtpChannel = channel
+		slog.Info("TCP interleaved mode setup", "sessionId", s.sessionId, "track", trackType, "rtpChannel", channel, "requestedChannel", requested)
+	} else if s.direction == DirectionRecord && s.rtpTransport != nil {
+		// UDP ingest - the client sends RTP to the server listener, so no sender session is created
+		if len(s.clientPorts) >= 2 {
+			if err := s.registerUDPReceiver(trackType, track); err != nil {
+				slog.Error("Failed to register RTP receiver", "sessionId", s.sessionId, "track", trackType, "err", err)
+				return s.sendErrorResponse(req.CSeq, StatusInternalServerError)
+			}
+		}
+		s.serverPorts = s.udpServerPorts()
+		slog.Info("UDP record setup", "sessionId", s.sessionId, "track", trackType, "clientPorts", s.clientPorts)
 	} else if len(s.clientPorts) >= 2 && s.rtpTransport != nil {
 		// UDP mode - create RTP session
-		ssrc := uint32(0x12345678) // TODO: generate unique SSRC
-
 		// Get client IP from connection
-		clientIP := s.conn.RemoteAddr().(*net.TCPAddr).IP.String()
+		clientIP, err := udpClientHost(s.conn.RemoteAddr())
+		if err != nil {
+			slog.Error("Failed to get client IP for UDP transport", "sessionId", s.sessionId, "remoteAddr", s.conn.RemoteAddr(), "err", err)
+			return s.sendErrorResponse(req.CSeq, StatusUnsupportedTransport)
+		}
 
 		// Create RTP session
-		rtpSession, err := s.rtpTransport.CreateSession(ssrc, rtp.PayloadTypeH264,
+		rtpSession, err := s.rtpTransport.CreateSession(track.ssrc, track.payloadType,
 			s.clientPorts[0], clientIP)
 		if err != nil {
 			slog.Error("Failed to create RTP session", "err", err)
 			return s.sendErrorResponse(req.CSeq, StatusInternalServerError)
 		}
 
-		s.rtpSession = rtpSession
-		s.serverPorts = []int{8000, 8001} // TODO: get from RTP transport
-		slog.Info("UDP RTP session created", "sessionId", s.sessionId, "ssrc", ssrc)
+		track.rtpSession = rtpSession
+		track.packetizer = track.newRepacketizer(s.rtpTransport.GetMTU())
+		if s.rtx && track.rtxPayloadType != 0 {
+			track.rtxSSRC = s.trackRTXSSRC(trackType)
+			rtpSession.EnableRTX(track.rtxPayloadType, track.rtxSSRC, rtp.DefaultRTXCacheSize)
+		}
+		s.serverPorts = s.udpServerPorts()
+		slog.Info("UDP RTP session created", "sessionId", s.sessionId, "track", trackType, "ssrc", track.ssrc, "rtx", rtpSession.RTXEnabled())
 	} else {
 		s.serverPorts = []int{8000, 8001}
 	}
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderTransport, s.buildTransportResponse())
-	response.SetHeader(HeaderSession, fmt.Sprintf("%s;timeout=%d", s.sessionId, int(s.timeout.Seconds())))
+	response.SetHeader(HeaderTransport, s.buildTransportResponse(track.ssrc))
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
+	s.setupTracks[req.URI] = true
+	s.tracks[trackType] = track
 	s.state = StateReady
 
 	return s.writer.WriteResponse(response)
@@ -398,12 +625,18 @@ func (s *Session) handlePlay(req *Request) error {
 	if s.state != StateReady {
 		return s.sendErrorResponse(req.CSeq, StatusMethodNotValidInThisState)
 	}
+	if !s.access.AllowPlay(s.conn.RemoteAddr()) {
+		return s.sendForbidden(req, "PLAY")
+	}
 
 	// Parse Range header if present
 	rangeHeader := req.GetHeader(HeaderRange)
+	fromStart := s.playStartPolicy == PlayFromStart
 	if rangeHeader != "" {
 		slog.Debug("Range header received", "sessionId", s.sessionId, "range", rangeHeader)
 		// TODO: implement range support
+		// Until then an explicit Range starts at the live edge
+		fromStart = false
 	}
 
 	// Send PLAY event
@@ -412,6 +645,7 @@ func (s *Session) handlePlay(req *Request) error {
 		case s.externalChannel <- PlayStarted{
 			SessionId:  s.sessionId,
 			StreamPath: s.streamPath,
+			FromStart:  fromStart,
 		}:
 		default:
 		}
@@ -419,10 +653,13 @@ func (s *Session) handlePlay(req *Request) error {
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
-	response.SetHeader(HeaderRTPInfo, fmt.Sprintf("url=%s;seq=0;rtptime=0", req.URI))
+	response.SetHeader(HeaderSession, s.sessionHeader())
+	response.SetHeader(HeaderRTPInfo, s.rtpInfo(req.URI))
 
 	s.state = StatePlaying
+	if s.IsInterleavedMode() {
+		s.startSenderReports()
+	}
 
 	return s.writer.WriteResponse(response)
 }
@@ -446,7 +683,7 @@ func (s *Session) handlePause(req *Request) error {
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
 	s.state = StateReady
 
@@ -468,17 +705,17 @@ func (s *Session) handleTeardown(req *Request) error {
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
 	s.state = StateInit
-
-	// Schedule session termination after response
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		s.Stop()
-	}()
-
-	return s.writer.WriteResponse(response)
+	s.setupTracks = make(map[string]bool)
+	s.channels.reset()
+
+	// WriteResponse flushes under the writer lock, so the response is on the
+	// wire (after any batched frames) before Stop closes the connection
+	err := s.writer.WriteResponse(response)
+	s.Stop()
+	return err
 }
 
 // handleRecord handles RECORD request
@@ -486,6 +723,9 @@ func (s *Session) handleRecord(req *Request) error {
 	if s.state != StateReady {
 		return s.sendErrorResponse(req.CSeq, StatusMethodNotValidInThisState)
 	}
+	if !s.access.AllowPublish(s.conn.RemoteAddr()) {
+		return s.sendForbidden(req, "RECORD")
+	}
 
 	// Send RECORD event
 	if s.externalChannel != nil {
@@ -500,7 +740,7 @@ func (s *Session) handleRecord(req *Request) error {
 
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
 	s.state = StateRecording
 
@@ -509,7 +749,24 @@ func (s *Session) handleRecord(req *Request) error {
 
 // handleAnnounce handles ANNOUNCE request
 func (s *Session) handleAnnounce(req *Request) error {
+	if !s.access.AllowPublish(s.conn.RemoteAddr()) {
+		return s.sendForbidden(req, "ANNOUNCE")
+	}
+
+	// Parse the announced SDP so DESCRIBE can serve it back normalized
+	sdp, err := ParseSDP(string(req.Body))
+	if err != nil {
+		slog.Warn("Invalid SDP in ANNOUNCE", "sessionId", s.sessionId, "uri", req.URI, "err", err)
+		return s.sendErrorResponse(req.CSeq, StatusBadRequest)
+	}
+	for i, media := range sdp.Media {
+		if media.Control() == "" {
+			media.SetControl(fmt.Sprintf("track%d", i+1))
+		}
+	}
+
 	s.streamPath = req.URI
+	s.announcedSDP = sdp
 
 	// Send ANNOUNCE event
 	if s.externalChannel != nil {
@@ -517,7 +774,7 @@ func (s *Session) handleAnnounce(req *Request) error {
 		case s.externalChannel <- AnnounceReceived{
 			SessionId:  s.sessionId,
 			StreamPath: s.streamPath,
-			SDP:        string(req.Body),
+			SDP:        sdp.String(),
 		}:
 		default:
 		}
@@ -533,7 +790,7 @@ func (s *Session) handleAnnounce(req *Request) error {
 func (s *Session) handleGetParameter(req *Request) error {
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
 	// Basic keep-alive response
 	return s.writer.WriteResponse(response)
@@ -543,28 +800,62 @@ func (s *Session) handleGetParameter(req *Request) error {
 func (s *Session) handleSetParameter(req *Request) error {
 	response := NewResponse(StatusOK)
 	response.SetCSeq(req.CSeq)
-	response.SetHeader(HeaderSession, s.sessionId)
+	response.SetHeader(HeaderSession, s.sessionHeader())
 
 	return s.writer.WriteResponse(response)
 }
 
+// sessionHeader returns the Session header value with the negotiated timeout,
+// so clients refresh their keep-alive timer from any response
+func (s *Session) sessionHeader() string {
+	return fmt.Sprintf("%s;timeout=%d", s.sessionId, int(s.timeout.Seconds()))
+}
+
 // sendErrorResponse sends an error response
 func (s *Session) sendErrorResponse(cseq int, statusCode int) error {
 	response := NewResponse(statusCode)
 	response.SetCSeq(cseq)
-	response.SetHeader(HeaderServer, "Sol RTSP Server")
 
 	return s.writer.WriteResponse(response)
 }
 
+// sendForbidden rejects a request denied by access control
+func (s *Session) sendForbidden(req *Request, method string) error {
+	slog.Warn("Request rejected by access control", "sessionId", s.sessionId, "method", method, "remoteAddr", s.conn.RemoteAddr(), "uri", req.URI)
+	return s.sendErrorResponse(req.CSeq, StatusForbidden)
+}
+
+// sendRedirect answers a request with 301/302 and the Location of the node serving the stream
+func (s *Session) sendRedirect(req *Request, location string, permanent bool) error {
+	statusCode := StatusMovedTemporarily
+	if permanent {
+		statusCode = StatusMovedPermanently
+	}
+	slog.Info("RTSP request redirected", "sessionId", s.sessionId, "method", req.Method, "uri", req.URI, "location", location, "statusCode", statusCode)
+
+	response := NewResponse(statusCode)
+	response.SetCSeq(req.CSeq)
+	response.SetHeader(HeaderLocation, location)
+	return s.writer.WriteResponse(response)
+}
+
 // parseTransport parses the Transport header
-func (s *Session) parseTransport(transport string) {
+// A malformed client_port returns ErrInvalidClientPort and an unknown mode ErrUnsupportedTransportMode
+func (s *Session) parseTransport(transport string) error {
 	s.transportMode = TransportUDP // Default to UDP
+	s.clientPorts = nil            // Ports from a previous track SETUP must not accumulate
+
+	direction, err := parseTransportMode(transport)
+	if err != nil {
+		return err
+	}
+	s.direction = direction
 
 	// Check for TCP interleaved mode
 	if strings.Contains(transport, "RTP/AVP/TCP") {
 		s.transportMode = TransportTCP
 		s.interleavedMode = true
+		s.rtpChannel = -1 // the channel allocator picks a pair unless the client asks for one
 
 		// Parse interleaved channels
 		if strings.Contains(transport, "interleaved=") {
@@ -587,14 +878,11 @@ func (s *Session) parseTransport(transport string) {
 					break
 				}
 			}
-		} else {
-			// Default interleaved channels if not specified
-			s.rtpChannel = 0
 		}
 
 		slog.Info("TCP interleaved transport", "sessionId", s.sessionId,
 			"rtpChannel", s.rtpChannel)
-		return
+		return nil
 	}
 
 	// UDP mode - parse client_port
@@ -604,83 +892,222 @@ func (s *Session) parseTransport(transport string) {
 			part = strings.TrimSpace(part)
 			if strings.HasPrefix(part, "client_port=") {
 				portsStr := strings.TrimPrefix(part, "client_port=")
-				portParts := strings.Split(portsStr, "-")
-				for _, portStr := range portParts {
-					if port, err := strconv.Atoi(portStr); err == nil {
-						s.clientPorts = append(s.clientPorts, port)
-					}
+				ports, err := parseClientPorts(portsStr)
+				if err != nil {
+					return err
 				}
+				s.clientPorts = ports
 				break
 			}
 		}
 		slog.Info("UDP transport", "sessionId", s.sessionId, "clientPorts", s.clientPorts)
 	}
+	return nil
+}
+
+// parseTransportMode parses the Transport "mode" parameter (RFC 2326 section 12.39)
+// Without a mode parameter the session plays
+func parseTransportMode(transport string) (TransportDirection, error) {
+	for _, part := range strings.Split(transport, ";") {
+		part = strings.TrimSpace(part)
+		if len(part) < len("mode=") || !strings.EqualFold(part[:len("mode=")], "mode=") {
+			continue
+		}
+
+		mode := strings.ToLower(strings.Trim(part[len("mode="):], `"`))
+		switch mode {
+		case "play":
+			return DirectionPlay, nil
+		case "record", "receive":
+			return DirectionRecord, nil
+		default:
+			return DirectionPlay, fmt.Errorf("%w: %q", ErrUnsupportedTransportMode, mode)
+		}
+	}
+	return DirectionPlay, nil
+}
+
+// parseClientPorts parses a client_port value into an RTP/RTCP port pair
+// RTP must be even and RTCP must be RTP+1; a lone RTP port implies RTCP at RTP+1
+func parseClientPorts(value string) ([]int, error) {
+	portParts := strings.Split(value, "-")
+	if len(portParts) > 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidClientPort, value)
+	}
+
+	ports := make([]int, 0, 2)
+	for _, portStr := range portParts {
+		port, err := strconv.Atoi(strings.TrimSpace(portStr))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidClientPort, value)
+		}
+		ports = append(ports, port)
+	}
+
+	if ports[0]%2 != 0 {
+		return nil, fmt.Errorf("%w: RTP port %d is odd", ErrInvalidClientPort, ports[0])
+	}
+	if len(ports) == 1 {
+		ports = append(ports, ports[0]+1)
+	}
+	if ports[1] != ports[0]+1 {
+		return nil, fmt.Errorf("%w: RTCP port %d does not follow RTP port %d", ErrInvalidClientPort, ports[1], ports[0])
+	}
+	return ports, nil
 }
 
-// buildTransportResponse builds the Transport response header
-func (s *Session) buildTransportResponse() string {
+// buildTransportResponse builds the Transport response header for a track sent with ssrc
+func (s *Session) buildTransportResponse(ssrc uint32) string {
 	transport := s.transport
 
 	if s.transportMode == TransportTCP && s.interleavedMode {
-		// TCP interleaved mode
-		transport += fmt.Sprintf(";interleaved=%d", s.rtpChannel)
+		// TCP interleaved mode - the assigned pair replaces the requested one
+		transport = withoutTransportParam(transport, "interleaved")
+		transport += fmt.Sprintf(";interleaved=%d-%d", s.rtpChannel, s.rtpChannel+1)
 	} else {
 		// UDP mode - add server ports
 		if len(s.serverPorts) >= 1 {
 			transport += fmt.Sprintf(";server_port=%d", s.serverPorts[0])
 		}
 	}
+	transport += fmt.Sprintf(";ssrc=%08X", ssrc)
 
 	return transport
 }
 
-// generateDetailedSDP generates a detailed SDP
-func (s *Session) generateDetailedSDP() string {
-	return fmt.Sprintf(`v=0\r
-o=- %d %d IN IP4 127.0.0.1\r
-s=Sol RTSP Stream\r
-i=RTSP Server Stream\r
-c=IN IP4 0.0.0.0\r
-t=0 0\r
-a=tool:Sol RTSP Server\r
-a=range:npt=0-\r
-m=video 0 RTP/AVP 96\r
-c=IN IP4 0.0.0.0\r
-b=AS:500\r
-a=rtpmap:96 H264/90000\r
-a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0LAHpWgUH5PIAEAAAMAEAAAAwPA8UKZYA==,aMuBcsg=\r
-a=control:track1\r
-m=audio 0 RTP/AVP 97\r
-c=IN IP4 0.0.0.0\r
-b=AS:128\r
-a=rtpmap:97 MPEG4-GENERIC/48000/2\r
-a=fmtp:97 streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=119056E500\r
-a=control:track2\r
-`, time.Now().Unix(), time.Now().Unix())
-}
-
-// SendInterleavedRTPPacket sends RTP packet over TCP interleaved
-func (s *Session) SendInterleavedRTPPacket(data []byte) error {
-	if s.transportMode != TransportTCP || !s.interleavedMode {
-		return fmt.Errorf("session is not in TCP interleaved mode")
+// withoutTransportParam removes the name=value parameter from a Transport header value
+func withoutTransportParam(transport, name string) string {
+	parts := strings.Split(transport, ";")
+	kept := parts[:0]
+	for _, part := range parts {
+		key, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if !strings.EqualFold(key, name) {
+			kept = append(kept, part)
+		}
 	}
+	return strings.Join(kept, ";")
+}
 
-	// Interleaved frame format:
-	// '$' + channel + length(2 bytes) + data
-	frame := make([]byte, 4+len(data))
-	frame[0] = '$'                    // Magic byte
-	frame[1] = byte(s.rtpChannel)     // Channel number
-	frame[2] = byte(len(data) >> 8)   // Length high byte
-	frame[3] = byte(len(data) & 0xFF) // Length low byte
-	copy(frame[4:], data)             // RTP packet data
+// udpServerPorts returns the server RTP/RTCP ports advertised in the
+// Transport header, taken from the RTP transport's listener when it runs
+func (s *Session) udpServerPorts() []int {
+	if port := s.rtpTransport.Port(); port != 0 {
+		return []int{port, port + 1}
+	}
+	return []int{8000, 8001}
+}
 
-	_, err := s.conn.Write(frame)
+// describeSDP returns the SDP for DESCRIBE: the publisher's announced SDP
+// if the stream has one, otherwise the default H.264/AAC description, with
+// the SSRC the session will send each track with
+func (s *Session) describeSDP() (string, error) {
+	sdp, err := s.sessionSDP()
 	if err != nil {
-		return fmt.Errorf("failed to send interleaved RTP packet: %v", err)
+		return "", err
+	}
+	if s.announcedSDP == nil {
+		s.advertiseSSRCs(sdp)
+	}
+	return sdp.String(), nil
+}
+
+// generateDetailedSDP generates the default SDP (H.264 video + AAC audio)
+func (s *Session) generateDetailedSDP() *SDP {
+	sdp, _ := s.generateSDP(DefaultStreamCodecs()) // the default codecs are always supported
+	return sdp
+}
+
+// advertisedIP returns the server address put in generated SDPs: the
+// configured address, otherwise the local address the client connected to
+func (s *Session) advertisedIP() net.IP {
+	if ip := net.ParseIP(s.sdpOptions.Address); ip != nil {
+		return ip
+	}
+	if addr, ok := s.conn.LocalAddr().(*net.TCPAddr); ok && !addr.IP.IsUnspecified() {
+		return addr.IP
+	}
+	return net.IPv4zero
+}
+
+// udpClientHost returns the host to send UDP RTP to (and expect it from) for a
+// client connected from addr. Any net.Addr is accepted, so connections wrapped
+// by TLS or proxies work; IPv4-mapped IPv6 addresses become plain IPv4 and the
+// IPv6 zone is kept only for link-local addresses, which cannot be routed without it
+func udpClientHost(addr net.Addr) (string, error) {
+	var ip net.IP
+	var zone string
+	switch a := addr.(type) {
+	case nil:
+		return "", fmt.Errorf("no remote address")
+	case *net.TCPAddr:
+		ip, zone = a.IP, a.Zone
+	case *net.UDPAddr:
+		ip, zone = a.IP, a.Zone
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			host = addr.String()
+		}
+		host, zone, _ = strings.Cut(host, "%")
+		ip = net.ParseIP(host)
+	}
+
+	if ip == nil {
+		return "", fmt.Errorf("remote address %q has no IP", addr.String())
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String(), nil
+	}
+	if zone != "" && ip.IsLinkLocalUnicast() {
+		return ip.String() + "%" + zone, nil
+	}
+	return ip.String(), nil
+}
+
+// generateSDP generates an SDP advertising the given codecs, or fails with
+// ErrUnsupportedCodec rather than describing media the stream does not carry
+func (s *Session) generateSDP(codecs StreamCodecs) (*SDP, error) {
+	address := sdpAddress(s.advertisedIP())
+	sdp := NewSDP(s.sdpOptions.SessionName).SetOriginAddress(address)
+	sdp.Info = s.sdpOptions.Info
+	sdp.Connection = address
+	sdp.AddAttribute("tool", ServerName).
+		AddAttribute("range", "npt=0-")
+
+	if codecs.Video != "" {
+		if err := addVideoMedia(sdp, codecs); err != nil {
+			return nil, err
+		}
+	}
+	if codecs.Audio != "" {
+		if err := addAudioMedia(sdp, codecs); err != nil {
+			return nil, err
+		}
+	}
+	if len(sdp.Media) == 0 {
+		return nil, fmt.Errorf("%w: stream has no media", ErrUnsupportedCodec)
+	}
+
+	return sdp, nil
+}
+
+// writeInterleavedFrame sends an RTP or RTCP packet over TCP interleaved on the given channel
+func (s *Session) writeInterleavedFrame(channel int, data []byte) error {
+	if s.transportMode != TransportTCP || !s.interleavedMode {
+		return fmt.Errorf("session is not in TCP interleaved mode")
+	}
+
+	// Shares the response writer so frames never interleave with a response
+	if err := s.writer.WriteInterleavedFrame(channel, data); err != nil {
+		// The connection is broken: end the session instead of failing every later packet
+		if s.writer.Err() != nil {
+			s.Stop()
+		}
+		return fmt.Errorf("failed to send interleaved frame: %v", err)
 	}
 
-	slog.Debug("Interleaved RTP packet sent", "sessionId", s.sessionId,
-		"channel", s.rtpChannel, "dataSize", len(data))
+	slog.Debug("Interleaved frame sent", "sessionId", s.sessionId,
+		"channel", channel, "dataSize", len(data))
 	return nil
 }
 
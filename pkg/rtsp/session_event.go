@@ -15,6 +15,7 @@ type DescribeRequested struct {
 type PlayStarted struct {
 	SessionId  string
 	StreamPath string
+	FromStart  bool // start from the earliest buffered packet instead of the live edge
 }
 
 // PlayStopped represents PLAY stop
@@ -46,6 +47,7 @@ type AnnounceReceived struct {
 type RTPPacketReceived struct {
 	SessionId   string
 	StreamPath  string
+	Track       TrackType
 	Data        []byte
 	Timestamp   uint32
 	PayloadType uint8
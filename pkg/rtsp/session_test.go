@@ -0,0 +1,886 @@
+package rtsp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sol/pkg/acl"
+	"sol/pkg/rtp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bufferConn captures everything a session writes
+type bufferConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bufferConn) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
+
+func (c *bufferConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 50000}
+}
+
+func (c *bufferConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: DefaultRTSPPort}
+}
+
+func (c *bufferConn) Close() error {
+	return nil
+}
+
+func (c *bufferConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func newTestSession() (*Session, *bufferConn, chan interface{}) {
+	conn := &bufferConn{}
+	channel := make(chan interface{}, 10)
+	session := NewSession(conn, channel, nil)
+	return session, conn, channel
+}
+
+// readResponse parses the last response the session wrote
+func readResponse(t *testing.T, conn *bufferConn) *Response {
+	t.Helper()
+	response, err := NewMessageReader(&conn.buf).ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return response
+}
+
+func newTestRequest(method string, cseq int, headers map[string]string) *Request {
+	req := NewRequest(method, "rtsp://localhost/live/test")
+	req.SetCSeq(cseq)
+	for key, value := range headers {
+		req.SetHeader(key, value)
+	}
+	return req
+}
+
+func TestPlayStartPolicyWithoutRange(t *testing.T) {
+	policies := []PlayStartPolicy{PlayFromLiveEdge, PlayFromStart}
+	for _, policy := range policies {
+		session, conn, channel := newTestSession()
+		session.playStartPolicy = policy
+		session.state = StateReady
+
+		req := newTestRequest(MethodPlay, 1, map[string]string{HeaderSession: session.sessionId})
+		if err := session.handleRequest(req); err != nil {
+			t.Fatalf("Failed to handle PLAY: %v", err)
+		}
+		if response := readResponse(t, conn); response.StatusCode != StatusOK {
+			t.Fatalf("Expected 200, got %d", response.StatusCode)
+		}
+
+		event := (<-channel).(PlayStarted)
+		if event.FromStart != (policy == PlayFromStart) {
+			t.Errorf("Policy %s: expected FromStart=%v, got %v", policy, policy == PlayFromStart, event.FromStart)
+		}
+	}
+}
+
+func TestPlayWithRangeStartsAtLiveEdge(t *testing.T) {
+	session, _, channel := newTestSession()
+	session.playStartPolicy = PlayFromStart
+	session.state = StateReady
+
+	req := newTestRequest(MethodPlay, 1, map[string]string{
+		HeaderSession: session.sessionId,
+		HeaderRange:   "npt=10-",
+	})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle PLAY: %v", err)
+	}
+
+	event := (<-channel).(PlayStarted)
+	if event.FromStart {
+		t.Error("Expected PLAY with Range not to apply the start policy")
+	}
+}
+
+func TestSetupAfterPlayIsRejected(t *testing.T) {
+	session, conn, _ := newTestSession()
+	session.state = StatePlaying
+
+	req := newTestRequest(MethodSetup, 3, map[string]string{
+		HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1",
+		HeaderSession:   session.sessionId,
+	})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+
+	if response := readResponse(t, conn); response.StatusCode != StatusMethodNotValidInThisState {
+		t.Fatalf("Expected 455, got %d", response.StatusCode)
+	}
+	if session.state != StatePlaying {
+		t.Errorf("Expected state to stay Playing, got %s", session.state)
+	}
+}
+
+func TestDuplicateSetupIsRejected(t *testing.T) {
+	session, conn, _ := newTestSession()
+	headers := map[string]string{HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1"}
+
+	if err := session.handleRequest(newTestRequest(MethodSetup, 1, headers)); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for first SETUP, got %d", response.StatusCode)
+	}
+
+	if err := session.handleRequest(newTestRequest(MethodSetup, 2, headers)); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusAggregateOperationNotAllowed {
+		t.Fatalf("Expected 459 for duplicate SETUP, got %d", response.StatusCode)
+	}
+}
+
+func TestSetupOfAnotherTrackIsAllowed(t *testing.T) {
+	session, conn, _ := newTestSession()
+
+	first := newTestRequest(MethodSetup, 1, map[string]string{HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1"})
+	first.URI = "rtsp://localhost/live/test/track1"
+	if err := session.handleRequest(first); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	readResponse(t, conn)
+
+	second := newTestRequest(MethodSetup, 2, map[string]string{HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=2-3"})
+	second.URI = "rtsp://localhost/live/test/track2"
+	if err := session.handleRequest(second); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for SETUP of another track, got %d", response.StatusCode)
+	}
+}
+
+func TestParseClientPorts(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []int
+	}{
+		{"5000-5001", []int{5000, 5001}},
+		{"5000", []int{5000, 5001}},
+		{"65534-65535", []int{65534, 65535}},
+		{"5001-5002", nil},      // odd RTP port
+		{"5000-5002", nil},      // RTCP must be RTP+1
+		{"0-1", nil},            // out of range
+		{"65535", nil},          // odd and no room for RTCP
+		{"70000-70001", nil},    // out of range
+		{"abc-def", nil},        // not a number
+		{"5000-5001-5002", nil}, // too many ports
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		ports, err := parseClientPorts(tt.value)
+		if tt.expected == nil {
+			if !errors.Is(err, ErrInvalidClientPort) {
+				t.Errorf("client_port=%q: Expected ErrInvalidClientPort, got ports=%v err=%v", tt.value, ports, err)
+			}
+			continue
+		}
+		if err != nil || len(ports) != 2 || ports[0] != tt.expected[0] || ports[1] != tt.expected[1] {
+			t.Errorf("client_port=%q: Expected %v, got ports=%v err=%v", tt.value, tt.expected, ports, err)
+		}
+	}
+}
+
+func TestSetupWithMalformedClientPortIsRejected(t *testing.T) {
+	session, conn, _ := newTestSession()
+
+	req := newTestRequest(MethodSetup, 1, map[string]string{HeaderTransport: "RTP/AVP;unicast;client_port=5001-5000"})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusUnsupportedTransport {
+		t.Fatalf("Expected 461, got %d", response.StatusCode)
+	}
+	if session.state == StateReady || len(session.tracks) != 0 {
+		t.Errorf("Expected no track to be set up, got state=%s tracks=%d", session.state, len(session.tracks))
+	}
+
+	// A valid pair on retry succeeds
+	req = newTestRequest(MethodSetup, 2, map[string]string{HeaderTransport: "RTP/AVP;unicast;client_port=5000-5001"})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+}
+
+func TestAccessControlPerOperation(t *testing.T) {
+	// Publishing only from internal addresses, playing from anywhere
+	publish, err := acl.NewList([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create access list: %v", err)
+	}
+
+	session, conn, channel := newTestSession()
+	session.access = &acl.Policy{Publish: publish}
+
+	announce := newTestRequest(MethodAnnounce, 1, nil)
+	if err := session.handleRequest(announce); err != nil {
+		t.Fatalf("Failed to handle ANNOUNCE: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusForbidden {
+		t.Fatalf("Expected 403 for ANNOUNCE, got %d", response.StatusCode)
+	}
+	if len(channel) != 0 {
+		t.Fatalf("Expected no events for rejected ANNOUNCE, got %d", len(channel))
+	}
+
+	describe := newTestRequest(MethodDescribe, 2, nil)
+	if err := session.handleRequest(describe); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for DESCRIBE, got %d", response.StatusCode)
+	}
+}
+
+func TestDescribeRedirect(t *testing.T) {
+	session, conn, channel := newTestSession()
+	session.redirect = func(streamPath string) (string, bool) {
+		if streamPath == "rtsp://localhost/live/test" {
+			return "rtsp://edge-2.example.com/live/test", false
+		}
+		return "", false
+	}
+
+	describe := newTestRequest(MethodDescribe, 1, nil)
+	if err := session.handleRequest(describe); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	response := readResponse(t, conn)
+	if response.StatusCode != StatusMovedTemporarily {
+		t.Fatalf("Expected 302 for redirected DESCRIBE, got %d", response.StatusCode)
+	}
+	if location := response.GetHeader(HeaderLocation); location != "rtsp://edge-2.example.com/live/test" {
+		t.Fatalf("Expected Location of the redirect target, got %q", location)
+	}
+	if len(response.Body) != 0 || len(channel) != 0 {
+		t.Fatalf("Expected no SDP and no DESCRIBE event for a redirect, got body %q and %d events", response.Body, len(channel))
+	}
+
+	// Paths without a redirect target are described locally
+	local := NewRequest(MethodDescribe, "rtsp://localhost/live/other")
+	local.SetCSeq(2)
+	if err := session.handleRequest(local); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for local DESCRIBE, got %d", response.StatusCode)
+	}
+}
+
+func TestPlayResponseIncludesSessionTimeout(t *testing.T) {
+	session, conn, _ := newTestSession()
+	session.timeout = 30 * time.Second
+	session.state = StateReady
+
+	req := newTestRequest(MethodPlay, 4, map[string]string{HeaderSession: session.sessionId})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle PLAY: %v", err)
+	}
+
+	response := readResponse(t, conn)
+	expected := session.sessionId + ";timeout=30"
+	if response.GetHeader(HeaderSession) != expected {
+		t.Errorf("Expected Session header %q, got %q", expected, response.GetHeader(HeaderSession))
+	}
+}
+
+func TestStopSendsRTCPBye(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	// Client RTCP socket; the client RTP port is one below it
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer client.Close()
+	rtpPort := client.LocalAddr().(*net.UDPAddr).Port - 1
+
+	session, _, _ := newTestSession()
+	session.rtpTransport = transport
+	rtpSession, err := transport.CreateSession(0x12345678, rtp.PayloadTypeH264, rtpPort, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create RTP session: %v", err)
+	}
+	session.tracks[TrackVideo] = &sessionTrack{ssrc: 0x12345678, payloadType: rtp.PayloadTypeH264, rtpSession: rtpSession}
+
+	session.Stop()
+	session.Stop() // second stop must not send another BYE
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected RTCP BYE, got error: %v", err)
+	}
+	bye := &rtp.RTCPBye{}
+	if err := bye.Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Failed to parse RTCP BYE: %v", err)
+	}
+	if len(bye.SSRCs) != 1 || bye.SSRCs[0] != 0x12345678 {
+		t.Errorf("Expected SSRC 0x12345678, got %x", bye.SSRCs)
+	}
+}
+
+func TestTeardownResponseSentBeforeClose(t *testing.T) {
+	session, _, _ := newTestSession()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	session.conn = serverConn
+	session.reader = NewMessageReader(serverConn)
+	session.writer = NewMessageWriter(serverConn)
+
+	done := make(chan struct{})
+	go func() {
+		session.handleRequests()
+		close(done)
+	}()
+	clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if err := NewMessageWriter(clientConn).WriteRequest(newTestRequest(MethodTeardown, 5, map[string]string{HeaderSession: session.sessionId})); err != nil {
+		t.Fatalf("Failed to write TEARDOWN: %v", err)
+	}
+
+	// The whole response arrives before the connection closes
+	reader := bufio.NewReader(clientConn)
+	response, err := NewMessageReader(reader).ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read TEARDOWN response: %v", err)
+	}
+	if response.StatusCode != StatusOK || response.CSeq != 5 {
+		t.Fatalf("Expected 200 for CSeq 5, got %d for CSeq %d", response.StatusCode, response.CSeq)
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("Expected connection closed after the response, got %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected session to stop after TEARDOWN")
+	}
+}
+
+func TestStopDoesNotBlockOnStalledClient(t *testing.T) {
+	session, _, _ := newTestSession()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	session.conn = serverConn
+	session.writer = NewMessageWriter(serverConn)
+	session.writer.EnableFrameBatching(64*1024, time.Hour)
+
+	// Leave a batched frame pending; the client never reads it
+	if err := session.writer.WriteInterleavedFrame(0, make([]byte, 1024)); err != nil {
+		t.Fatalf("Failed to buffer interleaved frame: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		session.stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(closeFlushTimeout + time.Second):
+		t.Fatal("Expected stop to give up flushing to a stalled client")
+	}
+}
+
+func TestSetupWithRecordModeConfiguresIngest(t *testing.T) {
+	session, conn, _ := newTestSession()
+	transport := rtp.NewRTPTransport()
+	session.rtpTransport = transport
+
+	req := newTestRequest(MethodSetup, 1, map[string]string{HeaderTransport: "RTP/AVP;unicast;client_port=5000-5001;mode=record"})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	response := readResponse(t, conn)
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	if session.direction != DirectionRecord {
+		t.Fatalf("Expected record direction, got %d", session.direction)
+	}
+	track := session.tracks[TrackVideo]
+	if track == nil {
+		t.Fatal("Expected video track to be set up")
+	}
+	// Ingest receives on the server listener; nothing is sent to the client
+	if track.rtpSession != nil || transport.GetSession(track.ssrc) != nil {
+		t.Error("Expected no RTP sender session for a record SETUP")
+	}
+	if !strings.Contains(response.GetHeader(HeaderTransport), "server_port=") {
+		t.Errorf("Expected server_port in Transport, got %q", response.GetHeader(HeaderTransport))
+	}
+}
+
+// listenEvenUDPPort opens a client RTP socket on an even port, as client_port requires
+func listenEvenUDPPort(t *testing.T) (net.PacketConn, int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		if port := conn.LocalAddr().(*net.UDPAddr).Port; port%2 == 0 {
+			t.Cleanup(func() { conn.Close() })
+			return conn, port
+		}
+		conn.Close()
+	}
+	t.Fatal("Failed to find an even UDP port")
+	return nil, 0
+}
+
+func TestUDPIngestAttributesPacketsToSession(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+	serverAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: transport.Port()}
+
+	// Two publishers recording over UDP from their own client ports
+	type publisher struct {
+		session *Session
+		events  chan interface{}
+		client  net.PacketConn
+	}
+	var publishers []publisher
+	for _, path := range []string{"live/first", "live/second"} {
+		session, conn, events := newTestSession()
+		session.rtpTransport = transport
+		session.streamPath = path
+		client, port := listenEvenUDPPort(t)
+
+		transportHeader := fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d;mode=record", port, port+1)
+		if err := session.handleRequest(newTestRequest(MethodSetup, 1, map[string]string{HeaderTransport: transportHeader})); err != nil {
+			t.Fatalf("Failed to handle SETUP: %v", err)
+		}
+		if response := readResponse(t, conn); response.StatusCode != StatusOK {
+			t.Fatalf("Expected 200 for SETUP, got %d", response.StatusCode)
+		}
+		publishers = append(publishers, publisher{session: session, events: events, client: client})
+	}
+
+	// The second publisher's packet is attributed to the second session only
+	packet, err := rtp.NewRTPPacket(rtp.PayloadTypeH264, 7, 90000, 0xCAFEBABE, []byte{0x65, 0x01}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	if _, err := publishers[1].client.WriteTo(packet, serverAddr); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+
+	var event RTPPacketReceived
+	select {
+	case e := <-publishers[1].events:
+		var ok bool
+		if event, ok = e.(RTPPacketReceived); !ok {
+			t.Fatalf("Expected RTPPacketReceived, got %T", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected RTPPacketReceived for the second session")
+	}
+	if event.SessionId != publishers[1].session.sessionId || event.StreamPath != "live/second" {
+		t.Errorf("Expected packet attributed to live/second, got session %s path %s", event.SessionId, event.StreamPath)
+	}
+	if event.Track != TrackVideo || event.Timestamp != 90000 || !bytes.Equal(event.Data, packet) {
+		t.Errorf("Unexpected event: track=%s timestamp=%d data=%x", event.Track, event.Timestamp, event.Data)
+	}
+	if len(publishers[0].events) != 0 {
+		t.Error("Expected no events for the first session")
+	}
+
+	// Packets from an unknown source are dropped
+	stranger, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer stranger.Close()
+	unknown, _ := rtp.NewRTPPacket(rtp.PayloadTypeH264, 1, 0, 0x0BADF00D, []byte{0x65}).Marshal()
+	if _, err := stranger.WriteTo(unknown, serverAddr); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for transport.GetDroppedPacketCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if transport.GetDroppedPacketCount() != 1 {
+		t.Errorf("Expected 1 dropped packet, got %d", transport.GetDroppedPacketCount())
+	}
+	for i, p := range publishers {
+		if len(p.events) != 0 {
+			t.Errorf("Expected no events for session %d from an unknown source", i)
+		}
+	}
+
+	// Stopping the session stops its ingest
+	publishers[1].session.Stop()
+	for len(publishers[1].events) > 0 {
+		<-publishers[1].events
+	}
+	if _, err := publishers[1].client.WriteTo(packet, serverAddr); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for transport.GetDroppedPacketCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if transport.GetDroppedPacketCount() != 2 {
+		t.Errorf("Expected packet after stop to be dropped, got %d dropped", transport.GetDroppedPacketCount())
+	}
+}
+
+func TestParseTransportMode(t *testing.T) {
+	tests := []struct {
+		transport string
+		expected  TransportDirection
+		valid     bool
+	}{
+		{"RTP/AVP;unicast;client_port=5000-5001", DirectionPlay, true},
+		{"RTP/AVP;unicast;mode=play", DirectionPlay, true},
+		{"RTP/AVP/TCP;unicast;interleaved=0-1;mode=record", DirectionRecord, true},
+		{`RTP/AVP;unicast;mode="RECORD"`, DirectionRecord, true},
+		{"RTP/AVP;unicast;mode=receive", DirectionRecord, true},
+		{"RTP/AVP;unicast;mode=broadcast", DirectionPlay, false},
+	}
+
+	for _, tt := range tests {
+		direction, err := parseTransportMode(tt.transport)
+		if !tt.valid {
+			if !errors.Is(err, ErrUnsupportedTransportMode) {
+				t.Errorf("%q: Expected ErrUnsupportedTransportMode, got %v", tt.transport, err)
+			}
+			continue
+		}
+		if err != nil || direction != tt.expected {
+			t.Errorf("%q: Expected direction %d, got %d (err=%v)", tt.transport, tt.expected, direction, err)
+		}
+	}
+}
+
+// wrappedAddr is a remote address reported by a connection wrapper (TLS, proxy
+// protocol) that is not a *net.TCPAddr
+type wrappedAddr string
+
+func (a wrappedAddr) Network() string { return "tls" }
+func (a wrappedAddr) String() string  { return string(a) }
+
+// remoteAddrConn is a bufferConn reporting a custom remote address
+type remoteAddrConn struct {
+	*bufferConn
+	remote net.Addr
+}
+
+func (c *remoteAddrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestUDPClientHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     net.Addr
+		expected string
+	}{
+		{"IPv4", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000}, "192.0.2.1"},
+		{"IPv4-mapped IPv6", &net.TCPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 50000}, "192.0.2.1"},
+		{"IPv6", &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000}, "2001:db8::1"},
+		{"IPv6 with zone", &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000, Zone: "eth0"}, "2001:db8::1"},
+		{"link-local IPv6 keeps zone", &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 50000, Zone: "eth0"}, "fe80::1%eth0"},
+		{"wrapped IPv4", wrappedAddr("192.0.2.1:443"), "192.0.2.1"},
+		{"wrapped IPv6 with zone", wrappedAddr("[fe80::1%eth0]:443"), "fe80::1%eth0"},
+		{"wrapped IPv4-mapped", wrappedAddr("[::ffff:192.0.2.1]:443"), "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, err := udpClientHost(tt.addr)
+			if err != nil {
+				t.Fatalf("Failed to get client host: %v", err)
+			}
+			if host != tt.expected {
+				t.Fatalf("Expected %q, got %q", tt.expected, host)
+			}
+		})
+	}
+
+	for _, addr := range []net.Addr{nil, wrappedAddr("pipe")} {
+		if _, err := udpClientHost(addr); err == nil {
+			t.Errorf("Expected error for %v", addr)
+		}
+	}
+}
+
+func TestUDPSetupWithNonTCPRemoteAddr(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	tests := []struct {
+		name     string
+		remote   net.Addr
+		expected string // client RTP address, empty if SETUP must fail
+	}{
+		{"IPv6", &net.TCPAddr{IP: net.IPv6loopback, Port: 50000}, "[::1]:5000"},
+		{"IPv4-mapped IPv6", &net.TCPAddr{IP: net.ParseIP("::ffff:127.0.0.1"), Port: 50000}, "127.0.0.1:5000"},
+		{"TLS-wrapped", wrappedAddr("127.0.0.1:50000"), "127.0.0.1:5000"},
+		{"TLS-wrapped IPv6", wrappedAddr("[::1]:50000"), "[::1]:5000"},
+		{"no IP", wrappedAddr("pipe"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &remoteAddrConn{bufferConn: &bufferConn{}, remote: tt.remote}
+			session := NewSession(conn, make(chan interface{}, 10), nil)
+			session.rtpTransport = transport
+
+			req := NewRequest(MethodSetup, "rtsp://localhost/live/test/track1")
+			req.SetCSeq(1)
+			req.SetHeader(HeaderTransport, "RTP/AVP;unicast;client_port=5000-5001")
+			if err := session.handleRequest(req); err != nil {
+				t.Fatalf("Failed to handle SETUP: %v", err)
+			}
+			response := readResponse(t, conn.bufferConn)
+
+			if tt.expected == "" {
+				if response.StatusCode != StatusUnsupportedTransport {
+					t.Fatalf("Expected %d, got %d", StatusUnsupportedTransport, response.StatusCode)
+				}
+				return
+			}
+			if response.StatusCode != StatusOK {
+				t.Fatalf("Expected 200, got %d", response.StatusCode)
+			}
+			if addr := session.tracks[TrackVideo].rtpSession.ClientRTPAddr().String(); addr != tt.expected {
+				t.Fatalf("Expected client RTP address %s, got %s", tt.expected, addr)
+			}
+		})
+	}
+}
+
+// waitForTermination reports whether the session sent SessionTerminated within d
+func waitForTermination(channel chan interface{}, d time.Duration) bool {
+	deadline := time.After(d)
+	for {
+		select {
+		case event := <-channel:
+			if _, ok := event.(SessionTerminated); ok {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+func TestOptionsKeepAliveKeepsPlayingSessionAlive(t *testing.T) {
+	session, _, channel := newTestSession()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	session.conn = serverConn
+	session.reader = NewMessageReader(serverConn)
+	session.writer = NewMessageWriter(serverConn)
+	session.timeout = 300 * time.Millisecond
+	session.state = StatePlaying
+	session.Start()
+	defer session.Stop()
+
+	writer := NewMessageWriter(clientConn)
+	reader := NewMessageReader(bufio.NewReader(clientConn))
+
+	// OPTIONS without a Session header, well within the timeout, for several timeouts
+	for cseq := 1; cseq <= 10; cseq++ {
+		clientConn.SetDeadline(time.Now().Add(time.Second))
+		if err := writer.WriteRequest(newTestRequest(MethodOptions, cseq, nil)); err != nil {
+			t.Fatalf("Failed to write OPTIONS %d: %v", cseq, err)
+		}
+		response, err := reader.ReadResponse()
+		if err != nil {
+			t.Fatalf("Failed to read OPTIONS %d response: %v", cseq, err)
+		}
+		if response.StatusCode != StatusOK {
+			t.Fatalf("Expected 200 for OPTIONS %d, got %d", cseq, response.StatusCode)
+		}
+		if waitForTermination(channel, 100*time.Millisecond) {
+			t.Fatalf("Expected keep-alive OPTIONS to keep the session alive, terminated after %d", cseq)
+		}
+	}
+
+	// Once the keep-alives stop the session times out
+	if !waitForTermination(channel, 2*time.Second) {
+		t.Fatal("Expected session to time out after keep-alives stopped")
+	}
+}
+
+func TestOptionsSessionHeader(t *testing.T) {
+	session, conn, _ := newTestSession()
+
+	req := newTestRequest(MethodOptions, 1, map[string]string{HeaderSession: session.sessionId})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle OPTIONS: %v", err)
+	}
+	response := readResponse(t, conn)
+	if response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+	if expected := session.sessionHeader(); response.GetHeader(HeaderSession) != expected {
+		t.Fatalf("Expected Session %q, got %q", expected, response.GetHeader(HeaderSession))
+	}
+
+	req = newTestRequest(MethodOptions, 2, map[string]string{HeaderSession: "other-session"})
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle OPTIONS: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusSessionNotFound {
+		t.Fatalf("Expected 454 for another session's OPTIONS, got %d", response.StatusCode)
+	}
+}
+
+// hangUpConn delivers the client's requests and then blocks until closed,
+// like a client that sent a request and hung up before reading the response
+type hangUpConn struct {
+	bufferConn
+	requests  *bytes.Reader
+	writes    atomic.Int32
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newHangUpConn(requests ...*Request) *hangUpConn {
+	var data []byte
+	for _, request := range requests {
+		data = append(data, request.Bytes()...)
+	}
+	return &hangUpConn{requests: bytes.NewReader(data), closed: make(chan struct{})}
+}
+
+func (c *hangUpConn) Read(p []byte) (int, error) {
+	if c.requests.Len() > 0 {
+		return c.requests.Read(p)
+	}
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *hangUpConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return 0, io.ErrClosedPipe
+}
+
+func (c *hangUpConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (c *hangUpConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestClientClosingMidResponseTearsDownOnce(t *testing.T) {
+	session, _, channel := newTestSession()
+	conn := newHangUpConn(newTestRequest(MethodOptions, 1, nil), newTestRequest(MethodOptions, 2, nil))
+	session.conn = conn
+	session.reader = NewMessageReader(conn)
+	session.writer = NewMessageWriter(conn)
+	session.Start()
+
+	if !waitForTermination(channel, 2*time.Second) {
+		t.Fatal("Expected the session to end after the response could not be written")
+	}
+	// No error response and no second request after the failed write
+	if writes := conn.writes.Load(); writes != 1 {
+		t.Fatalf("Expected a single write attempt, got %d", writes)
+	}
+	if session.ctx.Err() == nil {
+		t.Fatal("Expected the session context to be cancelled")
+	}
+	if waitForTermination(channel, 100*time.Millisecond) {
+		t.Fatal("Expected a single SessionTerminated event")
+	}
+}
+
+func TestInterleavedSendFailureStopsSession(t *testing.T) {
+	session, _, channel := newTestSession()
+	conn := newHangUpConn()
+	session.conn = conn
+	session.reader = NewMessageReader(conn)
+	session.writer = NewMessageWriter(conn)
+	session.transportMode = TransportTCP
+	session.interleavedMode = true
+
+	if err := session.writeInterleavedFrame(0, []byte{0x80}); err == nil {
+		t.Fatal("Expected the interleaved write to fail")
+	}
+	if session.ctx.Err() == nil || !waitForTermination(channel, time.Second) {
+		t.Fatal("Expected a failed interleaved write to stop the session")
+	}
+
+	// Later packets fail at once without touching the connection
+	if err := session.writeInterleavedFrame(0, []byte{0x80}); err == nil {
+		t.Fatal("Expected writes on a broken session to fail")
+	}
+	if writes := conn.writes.Load(); writes != 1 {
+		t.Fatalf("Expected a single write attempt, got %d", writes)
+	}
+}
+
+func TestRequireAndProxyRequire(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		status      int
+		unsupported string
+	}{
+		{"no option tags", nil, StatusOK, ""},
+		{"known require", map[string]string{HeaderRequire: "play.basic"}, StatusOK, ""},
+		{"known proxy-require", map[string]string{HeaderProxyRequire: "play.basic"}, StatusOK, ""},
+		{"unknown require", map[string]string{HeaderRequire: "com.example.feature"}, StatusOptionNotSupported, "com.example.feature"},
+		{"unknown proxy-require", map[string]string{HeaderProxyRequire: "play.basic, com.example.proxy"}, StatusOptionNotSupported, "com.example.proxy"},
+		{"unknown in both", map[string]string{HeaderRequire: "a.tag", HeaderProxyRequire: "b.tag, a.tag"}, StatusOptionNotSupported, "a.tag, b.tag"},
+	}
+
+	for _, tt := range tests {
+		session, conn, _ := newTestSession()
+		if err := session.handleRequest(newTestRequest(MethodOptions, 1, tt.headers)); err != nil {
+			t.Fatalf("%s: Failed to handle OPTIONS: %v", tt.name, err)
+		}
+		response := readResponse(t, conn)
+		if response.StatusCode != tt.status {
+			t.Fatalf("%s: Expected %d, got %d", tt.name, tt.status, response.StatusCode)
+		}
+		if unsupported := response.GetHeader(HeaderUnsupported); unsupported != tt.unsupported {
+			t.Fatalf("%s: Expected Unsupported %q, got %q", tt.name, tt.unsupported, unsupported)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package rtsp
 
 import (
 	"log/slog"
+	"sol/pkg/codec"
+	"sol/pkg/rtp"
+	"strconv"
 	"sync"
 )
 
@@ -12,7 +15,9 @@ type Stream struct {
 	publisher *Session              // publishing session (for RECORD)
 	players   map[*Session]struct{} // playing sessions
 	sdp       string                // Session Description Protocol
+	metadata  map[string]any        // onMetaData-style stream metadata (codec detection)
 	isActive  bool
+	history   packetHistory // recent RTP packets for catch-up playback
 	mutex     sync.RWMutex
 }
 
@@ -105,6 +110,7 @@ func (s *Stream) RemoveSession(session *Session) {
 	if s.publisher == session {
 		s.publisher = nil
 		s.isActive = false
+		s.history = packetHistory{}
 		slog.Info("Publisher removed from RTSP stream", "streamPath", s.name)
 	}
 
@@ -119,10 +125,18 @@ func (s *Stream) SetPublisher(session *Session, sdp string) {
 	s.publisher = session
 	s.sdp = sdp
 	s.isActive = true
+	s.history = packetHistory{videoCodec: announcedVideoCodec(sdp)}
 
 	slog.Info("Publisher set for RTSP stream", "streamPath", s.name, "sessionId", session.sessionId)
 }
 
+// IsPublisher reports whether the session is the stream's publisher
+func (s *Stream) IsPublisher(session *Session) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.publisher == session
+}
+
 // AddPlayer adds a playing session
 func (s *Stream) AddPlayer(session *Session) {
 	s.mutex.Lock()
@@ -149,6 +163,22 @@ func (s *Stream) GetSDP() string {
 	return s.sdp
 }
 
+// SetMetadata sets the stream metadata used to describe streams without an announced SDP
+func (s *Stream) SetMetadata(metadata map[string]any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.metadata = metadata
+}
+
+// GetMetadata returns the stream metadata
+func (s *Stream) GetMetadata() map[string]any {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.metadata
+}
+
 // IsActive returns whether the stream is active
 func (s *Stream) IsActive() bool {
 	s.mutex.RLock()
@@ -173,43 +203,215 @@ func (s *Stream) GetSessionCount() int {
 	return len(s.sessions)
 }
 
-// BroadcastRTPPacket broadcasts RTP packet to all players
-func (s *Stream) BroadcastRTPPacket(data []byte) {
-	s.mutex.RLock()
+// TrackPacket is a buffered RTP packet together with the track it belongs to
+type TrackPacket struct {
+	Track TrackType
+	Data  []byte
+
+	accessUnitStart bool // first video packet of an access unit (a new RTP timestamp)
+	keyFrame        bool // first packet of a video access unit holding an SPS or IDR, where catch-up playback can begin
+}
+
+// packetHistory keeps the recent RTP packets for catch-up playback. Unlike the
+// live path, which only ever forwards the newest packet, it is trimmed a whole
+// GOP at a time so that it keeps starting at a video key frame, and never
+// inside a video access unit
+type packetHistory struct {
+	packets    []TrackPacket
+	videoCodec codec.Codec // codec of the video payloads (Unknown is treated as H.264)
+
+	accessUnit          int    // index of the current video access unit's first packet, -1 once it was trimmed
+	accessUnitTimestamp uint32 // RTP timestamp of the current video access unit
+	hasAccessUnit       bool   // a video packet has been seen
+}
+
+// announcedVideoCodec returns the codec of the first video media of an announced SDP
+func announcedVideoCodec(text string) codec.Codec {
+	sdp, err := ParseSDP(text)
+	if err != nil {
+		return codec.Unknown
+	}
+	for _, media := range sdp.Media {
+		if media.Type != "video" || len(media.Formats) == 0 {
+			continue
+		}
+		pt, err := strconv.ParseUint(media.Formats[0], 10, 7)
+		if err != nil {
+			return codec.Unknown
+		}
+		return rtp.CodecForPayloadType(uint8(pt), media.RTPMap(int(pt)))
+	}
+	return codec.Unknown
+}
+
+// isKeyFrame reports whether a video RTP packet starts a key frame. Only H.264
+// payloads are recognized, other codecs never start a catch-up
+func (h *packetHistory) isKeyFrame(data []byte) bool {
+	if h.videoCodec != codec.Unknown && h.videoCodec != codec.H264 {
+		return false
+	}
+	return rtp.IsH264KeyFramePayload(rtpPayload(data))
+}
+
+// add appends a packet and, over limit packets, drops the oldest GOPs. A GOP
+// longer than the whole history cannot be kept and slides an access unit at a
+// time until the next key frame arrives. A key frame is marked on the first
+// packet of its access unit, so the SPS/PPS sent ahead of the IDR are replayed
+func (h *packetHistory) add(packet TrackPacket, limit int) {
+	if packet.Track == TrackVideo {
+		timestamp, ok := rtpTimestamp(packet.Data)
+		if !ok {
+			return
+		}
+		if !h.hasAccessUnit || timestamp != h.accessUnitTimestamp {
+			packet.accessUnitStart = true
+			h.accessUnit = len(h.packets)
+			h.accessUnitTimestamp = timestamp
+			h.hasAccessUnit = true
+		} else if h.accessUnit < 0 {
+			// The start of this access unit was trimmed; the rest cannot be decoded
+			return
+		}
+		h.packets = append(h.packets, packet)
+		if h.isKeyFrame(packet.Data) {
+			h.packets[h.accessUnit].keyFrame = true
+		}
+	} else {
+		h.packets = append(h.packets, packet)
+	}
+
+	for len(h.packets) > limit {
+		next := h.keyFrameIndex(1)
+		if next < 0 {
+			next = h.accessUnitBoundary(1)
+		}
+		if next < 0 {
+			// A single access unit fills the whole history
+			next = len(h.packets)
+		}
+		h.trim(next)
+	}
+}
+
+// trim drops the packets before index n
+func (h *packetHistory) trim(n int) {
+	h.packets = h.packets[n:]
+	if h.accessUnit -= n; h.accessUnit < 0 {
+		h.accessUnit = -1
+	}
+}
+
+// keyFrameIndex returns the index of the first key frame packet at or after from, or -1
+func (h *packetHistory) keyFrameIndex(from int) int {
+	for i := from; i < len(h.packets); i++ {
+		if h.packets[i].keyFrame {
+			return i
+		}
+	}
+	return -1
+}
+
+// accessUnitBoundary returns the first index at or after from where the history
+// can be cut without splitting a video access unit, or -1
+func (h *packetHistory) accessUnitBoundary(from int) int {
+	boundary := -1
+	clean := true // no later video packet continues an earlier access unit
+	for i := len(h.packets) - 1; i >= from; i-- {
+		if h.packets[i].Track == TrackVideo {
+			clean = h.packets[i].accessUnitStart
+		}
+		if clean {
+			boundary = i
+		}
+	}
+	return boundary
+}
+
+// fromKeyFrame returns a copy of the history from its first key frame on. Video
+// before the first key frame cannot be decoded, so without one only audio is
+// replayed; a history without video (audio-only stream) is replayed whole
+func (h *packetHistory) fromKeyFrame() []TrackPacket {
+	if start := h.keyFrameIndex(0); start >= 0 {
+		return append([]TrackPacket(nil), h.packets[start:]...)
+	}
+
+	packets := make([]TrackPacket, 0, len(h.packets))
+	for _, packet := range h.packets {
+		if packet.Track != TrackVideo {
+			packets = append(packets, packet)
+		}
+	}
+	return packets
+}
+
+// BroadcastVideoRTP broadcasts a video RTP packet to players that set up the video track
+func (s *Stream) BroadcastVideoRTP(data []byte) {
+	s.broadcastTrackRTP(TrackVideo, data)
+}
+
+// BroadcastAudioRTP broadcasts an audio RTP packet to players that set up the audio track
+func (s *Stream) BroadcastAudioRTP(data []byte) {
+	s.broadcastTrackRTP(TrackAudio, data)
+}
+
+// broadcastTrackRTP buffers the packet and sends it to every player of the track
+func (s *Stream) broadcastTrackRTP(track TrackType, data []byte) {
+	s.mutex.Lock()
+	s.history.add(TrackPacket{Track: track, Data: data}, DefaultPacketBufferSize)
 	players := make([]*Session, 0, len(s.players))
 	for player := range s.players {
 		players = append(players, player)
 	}
-	s.mutex.RUnlock()
+	s.mutex.Unlock()
 
-	// Send RTP packet to all players
+	// Send RTP packet to the players of this track
 	for _, player := range players {
-		if player.IsInterleavedMode() {
-			// TCP interleaved mode
-			err := player.SendInterleavedRTPPacket(data)
-			if err != nil {
-				slog.Error("Failed to send interleaved RTP packet to player",
-					"streamPath", s.name, "sessionId", player.sessionId, "err", err)
-			} else {
-				slog.Debug("Interleaved RTP packet sent to player",
-					"streamPath", s.name, "sessionId", player.sessionId, "dataSize", len(data))
-			}
-		} else if player.IsUDPMode() && player.rtpSession != nil && player.rtpTransport != nil {
-			// UDP mode
-			err := player.rtpTransport.SendRTPPacket(player.rtpSession.GetSSRC(), data, 0, false)
-			if err != nil {
-				slog.Error("Failed to send UDP RTP packet to player",
-					"streamPath", s.name, "sessionId", player.sessionId, "err", err)
-			} else {
-				slog.Debug("UDP RTP packet sent to player",
-					"streamPath", s.name, "sessionId", player.sessionId, "dataSize", len(data))
-			}
-		} else {
-			slog.Debug("Player has no valid transport setup", "streamPath", s.name, "sessionId", player.sessionId)
+		if player.HasTrack(track) {
+			s.sendRTPPacketToPlayer(player, track, data)
 		}
 	}
 }
 
+// StartingPackets returns the buffered packets a new player receives before
+// live data; catch-up playback starts at the first cached key frame
+func (s *Stream) StartingPackets(policy PlayStartPolicy) []TrackPacket {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if policy != PlayFromStart {
+		// Live edge: no backlog, the player starts with the next packet
+		return nil
+	}
+
+	return s.history.fromKeyFrame()
+}
+
+// SendBufferedPackets sends the buffered history to a player (catch-up playback)
+func (s *Stream) SendBufferedPackets(player *Session) {
+	packets := s.StartingPackets(PlayFromStart)
+	for _, packet := range packets {
+		if player.HasTrack(packet.Track) {
+			s.sendRTPPacketToPlayer(player, packet.Track, packet.Data)
+		}
+	}
+	slog.Debug("Buffered RTP packets sent to player", "streamPath", s.name, "sessionId", player.sessionId, "packetCount", len(packets))
+}
+
+// sendRTPPacketToPlayer sends an RTP packet on the player's track using its transport
+func (s *Stream) sendRTPPacketToPlayer(player *Session, track TrackType, data []byte) {
+	// A stopped player stays in the stream until the server handles its termination
+	if player.ctx.Err() != nil {
+		return
+	}
+	if err := player.SendTrackRTPPacket(track, data); err != nil {
+		slog.Error("Failed to send RTP packet to player",
+			"streamPath", s.name, "sessionId", player.sessionId, "track", track, "err", err)
+		return
+	}
+	slog.Debug("RTP packet sent to player",
+		"streamPath", s.name, "sessionId", player.sessionId, "track", track, "dataSize", len(data))
+}
+
 // CleanupInactiveSessions removes inactive sessions
 func (s *Stream) CleanupInactiveSessions() {
 	s.mutex.Lock()
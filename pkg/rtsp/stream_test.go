@@ -0,0 +1,214 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"sol/pkg/rtp"
+	"testing"
+)
+
+// Single NAL unit H.264 payload headers
+const (
+	testIDRSlice    = 0x65
+	testNonIDRSlice = 0x41
+)
+
+// newH264Packet returns an H.264 RTP packet whose sequence number identifies it in assertions
+func newH264Packet(seq uint16, naluHeader byte) []byte {
+	data, _ := rtp.NewRTPPacket(rtp.PayloadTypeH264, seq, uint32(seq)*3000, 0xCAFEBABE, []byte{naluHeader, 0x01}).Marshal()
+	return data
+}
+
+// packetSeq returns the sequence number of a buffered packet
+func packetSeq(packet TrackPacket) uint16 {
+	return binary.BigEndian.Uint16(packet.Data[2:4])
+}
+
+// newStreamWithHistory broadcasts packetCount video packets with a key frame every gop packets
+func newStreamWithHistory(packetCount, gop int) *Stream {
+	stream := NewStream("rtsp://localhost/live/test")
+	for i := 0; i < packetCount; i++ {
+		header := byte(testNonIDRSlice)
+		if i%gop == 0 {
+			header = testIDRSlice
+		}
+		stream.BroadcastVideoRTP(newH264Packet(uint16(i), header))
+	}
+	return stream
+}
+
+func TestStartingPacketsFromStart(t *testing.T) {
+	stream := newStreamWithHistory(5, 30)
+
+	packets := stream.StartingPackets(PlayFromStart)
+	if len(packets) != 5 {
+		t.Fatalf("Expected 5 buffered packets, got %d", len(packets))
+	}
+	if packetSeq(packets[0]) != 0 {
+		t.Errorf("Expected playback to start at the earliest packet, got %d", packetSeq(packets[0]))
+	}
+}
+
+func TestStartingPacketsBeginAtKeyFrame(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	// Joined mid-GOP: two slices and an audio packet precede the first key frame
+	stream.BroadcastVideoRTP(newH264Packet(0, testNonIDRSlice))
+	stream.BroadcastAudioRTP(newH264Packet(1, 0x01))
+	stream.BroadcastVideoRTP(newH264Packet(2, testNonIDRSlice))
+	stream.BroadcastVideoRTP(newH264Packet(3, testIDRSlice))
+	stream.BroadcastAudioRTP(newH264Packet(4, 0x01))
+	stream.BroadcastVideoRTP(newH264Packet(5, testNonIDRSlice))
+
+	packets := stream.StartingPackets(PlayFromStart)
+	if len(packets) != 3 {
+		t.Fatalf("Expected 3 packets from the key frame on, got %d", len(packets))
+	}
+	if packetSeq(packets[0]) != 3 {
+		t.Errorf("Expected playback to start at the key frame, got packet %d", packetSeq(packets[0]))
+	}
+}
+
+func TestStartingPacketsWithoutKeyFrame(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	stream.BroadcastVideoRTP(newH264Packet(0, testNonIDRSlice))
+	stream.BroadcastAudioRTP(newH264Packet(1, 0x01))
+
+	packets := stream.StartingPackets(PlayFromStart)
+	if len(packets) != 1 || packets[0].Track != TrackAudio {
+		t.Fatalf("Expected only the audio packet without a key frame, got %+v", packets)
+	}
+}
+
+func TestStartingPacketsAudioOnly(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	for i := 0; i < 3; i++ {
+		stream.BroadcastAudioRTP(newH264Packet(uint16(i), 0x01))
+	}
+
+	if packets := stream.StartingPackets(PlayFromStart); len(packets) != 3 {
+		t.Fatalf("Expected the whole audio history, got %d packets", len(packets))
+	}
+}
+
+func TestStartingPacketsFromLiveEdge(t *testing.T) {
+	stream := newStreamWithHistory(5, 30)
+
+	packets := stream.StartingPackets(PlayFromLiveEdge)
+	if len(packets) != 0 {
+		t.Fatalf("Expected no backlog at the live edge, got %d packets", len(packets))
+	}
+}
+
+func TestPacketBufferIsBounded(t *testing.T) {
+	stream := newStreamWithHistory(DefaultPacketBufferSize+10, 100)
+
+	packets := stream.StartingPackets(PlayFromStart)
+	if len(packets) > DefaultPacketBufferSize {
+		t.Fatalf("Expected at most %d buffered packets, got %d", DefaultPacketBufferSize, len(packets))
+	}
+	// The oldest GOP is evicted whole, so the history starts at the next key frame
+	if packetSeq(packets[0]) != 100 {
+		t.Errorf("Expected oldest GOP to be evicted, first packet is %d", packetSeq(packets[0]))
+	}
+	if len(packets) != DefaultPacketBufferSize+10-100 {
+		t.Errorf("Expected %d buffered packets, got %d", DefaultPacketBufferSize+10-100, len(packets))
+	}
+}
+
+func TestPacketBufferSlidesOverLongGOP(t *testing.T) {
+	stream := newStreamWithHistory(DefaultPacketBufferSize+10, DefaultPacketBufferSize+100)
+
+	// Only one key frame and it was evicted: no video can be replayed
+	if packets := stream.StartingPackets(PlayFromStart); len(packets) != 0 {
+		t.Fatalf("Expected no replay without a cached key frame, got %d packets", len(packets))
+	}
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	if len(stream.history.packets) != DefaultPacketBufferSize {
+		t.Errorf("Expected the history to stay bounded at %d, got %d", DefaultPacketBufferSize, len(stream.history.packets))
+	}
+}
+
+// newH264GOP returns the packets of a GOP whose key frame is sent as in-band
+// SPS and PPS followed by an FU-A fragmented IDR, all on one RTP timestamp,
+// then slices frames of one packet each. Sequence numbers start at seq
+func newH264GOP(seq uint16, timestamp uint32, slices int) [][]byte {
+	payloads := [][]byte{
+		{0x67, 0x42, 0x00, 0x1F}, // SPS
+		{0x68, 0xCE, 0x3C, 0x80}, // PPS
+		{0x7C, 0x85, 0x01},       // FU-A start of an IDR slice
+		{0x7C, 0x05, 0x02},       // FU-A middle
+		{0x7C, 0x45, 0x03},       // FU-A end
+	}
+	var packets [][]byte
+	for _, payload := range payloads {
+		data, _ := rtp.NewRTPPacket(rtp.PayloadTypeH264, seq, timestamp, 0xCAFEBABE, payload).Marshal()
+		packets = append(packets, data)
+		seq++
+	}
+	for i := 1; i <= slices; i++ {
+		data, _ := rtp.NewRTPPacket(rtp.PayloadTypeH264, seq, timestamp+uint32(i)*3000, 0xCAFEBABE, []byte{testNonIDRSlice, 0x01}).Marshal()
+		packets = append(packets, data)
+		seq++
+	}
+	return packets
+}
+
+func TestStartingPacketsIncludeParameterSets(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	stream.BroadcastVideoRTP(newH264Packet(90, testNonIDRSlice)) // end of an earlier GOP
+	for _, packet := range newH264GOP(100, 900000, 2) {
+		stream.BroadcastVideoRTP(packet)
+	}
+
+	packets := stream.StartingPackets(PlayFromStart)
+	if len(packets) != 7 || packetSeq(packets[0]) != 100 {
+		t.Fatalf("Expected catch-up to start at the SPS (packet 100) with 7 packets, got %d packets from %d", len(packets), packetSeq(packets[0]))
+	}
+}
+
+func TestHistoryTrimKeepsKeyFrameAccessUnit(t *testing.T) {
+	var history packetHistory
+	const limit = 12
+	for _, packet := range newH264GOP(0, 0, 4) {
+		history.add(TrackPacket{Track: TrackVideo, Data: packet}, limit)
+	}
+	for _, packet := range newH264GOP(100, 90000, 4) {
+		history.add(TrackPacket{Track: TrackVideo, Data: packet}, limit)
+	}
+
+	// The first GOP is evicted whole; the second starts at its SPS, not at the IDR fragment
+	if len(history.packets) != 9 || packetSeq(history.packets[0]) != 100 {
+		t.Fatalf("Expected the second GOP from its SPS (9 packets from 100), got %d packets from %d", len(history.packets), packetSeq(history.packets[0]))
+	}
+	for i, packet := range history.packets {
+		if packet.keyFrame != (i == 0) {
+			t.Errorf("Expected only the SPS packet to be marked as a key frame, packet %d is %v", packetSeq(packet), packet.keyFrame)
+		}
+	}
+
+	// A GOP longer than the history slides a whole access unit at a time
+	history = packetHistory{}
+	for _, packet := range newH264GOP(0, 0, 20) {
+		history.add(TrackPacket{Track: TrackVideo, Data: packet}, 4)
+	}
+	for _, packet := range history.packets {
+		if !packet.accessUnitStart {
+			t.Fatalf("Expected the slid history to hold whole access units, packet %d is mid-frame", packetSeq(packet))
+		}
+	}
+	if packets := history.fromKeyFrame(); len(packets) != 0 {
+		t.Errorf("Expected no replay once the key frame slid out, got %d packets", len(packets))
+	}
+}
+
+func TestParsePlayStartPolicy(t *testing.T) {
+	if policy, err := ParsePlayStartPolicy(PlayStartLive); err != nil || policy != PlayFromLiveEdge {
+		t.Errorf("Expected live edge policy, got %v (err: %v)", policy, err)
+	}
+	if policy, err := ParsePlayStartPolicy(PlayStartStart); err != nil || policy != PlayFromStart {
+		t.Errorf("Expected start policy, got %v (err: %v)", policy, err)
+	}
+	if _, err := ParsePlayStartPolicy("middle"); err == nil {
+		t.Error("Expected error for invalid policy")
+	}
+}
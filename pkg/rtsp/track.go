@@ -0,0 +1,538 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sol/pkg/codec"
+	"sol/pkg/rtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackType identifies the media carried by a track set up with SETUP
+type TrackType int
+
+const (
+	TrackVideo TrackType = iota
+	TrackAudio
+)
+
+// String returns the SDP media type of the track
+func (t TrackType) String() string {
+	switch t {
+	case TrackVideo:
+		return "video"
+	case TrackAudio:
+		return "audio"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+// sessionTrack holds the transport state negotiated for one track
+type sessionTrack struct {
+	uri               string // SETUP URL of the track (RTP-Info)
+	ssrc              uint32
+	payloadType       uint8
+	packetizationMode rtp.PacketizationMode // H.264 packetization-mode from the SDP fmtp (video only)
+	rtpChannel        int                   // interleaved RTP channel (TCP only)
+	rtpSession        *rtp.RTPSession       // RTP session (UDP only)
+	rtxPayloadType    uint8                 // RTX payload type from the SDP (0 = no RTX)
+	rtxSSRC           uint32                // SSRC of the RTX stream (UDP only, when RTX is enabled)
+	receiverKey       string                // RTP receiver registration on the transport (UDP ingest only)
+	clockRate         int                   // RTP clock rate from the SDP rtpmap (0 if unknown)
+	codec             codec.Codec           // codec from the SDP rtpmap
+	packetizer        rtp.Repacketizer      // fits relayed payloads to the MTU (UDP play only, nil if unsupported)
+
+	// Last relayed packet and send counts (TCP only; UDP tracks take them from rtpSession).
+	// Written by the stream sending packets, read for PLAY responses and sender reports
+	relayMu       sync.Mutex
+	relayed       bool
+	lastSeq       uint16
+	lastTimestamp uint32
+	lastSentAt    time.Time
+	packetCount   uint32
+	octetCount    uint32
+}
+
+// rtpPosition returns the sequence number of the next packet sent on the
+// track and the RTP timestamp the stream has reached, (0, 0) before the first
+func (t *sessionTrack) rtpPosition() (seq uint16, rtptime uint32) {
+	if t.rtpSession != nil {
+		return t.rtpSession.NextSequenceNumber(), t.rtpSession.LastTimestamp()
+	}
+	t.relayMu.Lock()
+	defer t.relayMu.Unlock()
+	if !t.relayed {
+		return 0, 0
+	}
+	return t.lastSeq + 1, t.lastTimestamp
+}
+
+// recordRelayed remembers the sequence number and timestamp of a packet relayed on the track
+func (t *sessionTrack) recordRelayed(packet []byte) {
+	t.relayMu.Lock()
+	defer t.relayMu.Unlock()
+	t.relayed = true
+	t.lastSeq = binary.BigEndian.Uint16(packet[2:4])
+	t.lastTimestamp = binary.BigEndian.Uint32(packet[4:8])
+	t.lastSentAt = time.Now()
+	t.packetCount++
+	t.octetCount += uint32(rtpPayloadSize(packet))
+}
+
+// senderReport returns the RTCP SR for the packets relayed on the track, or
+// false if none was relayed within maxAge of now. The RTP timestamp is
+// extrapolated from the last packet to now on the track's clock; without a
+// known clock rate the report refers to the time the last packet was sent.
+func (t *sessionTrack) senderReport(now time.Time, maxAge time.Duration) (*rtp.RTCPSenderReport, bool) {
+	t.relayMu.Lock()
+	defer t.relayMu.Unlock()
+	if !t.relayed || now.Sub(t.lastSentAt) > maxAge {
+		return nil, false
+	}
+
+	report := &rtp.RTCPSenderReport{
+		SSRC:         t.ssrc,
+		NTPTime:      t.lastSentAt,
+		RTPTimestamp: t.lastTimestamp,
+		PacketCount:  t.packetCount,
+		OctetCount:   t.octetCount,
+	}
+	if t.clockRate > 0 {
+		elapsed := now.Sub(t.lastSentAt)
+		report.NTPTime = now
+		report.RTPTimestamp += uint32(int64(elapsed) * int64(t.clockRate) / int64(time.Second))
+	}
+	return report, true
+}
+
+// rtpPayloadSize returns the payload size of a well-formed RTP packet
+// (after the CSRC list and header extension, before any padding)
+func rtpPayloadSize(packet []byte) int {
+	return len(rtpPayload(packet))
+}
+
+// rtpTimestamp returns the timestamp of an RTP packet (false if the packet is malformed)
+func rtpTimestamp(packet []byte) (uint32, bool) {
+	if len(packet) < rtp.MinRTPHeaderSize {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(packet[4:8]), true
+}
+
+// rtpPayload returns the payload of an RTP packet (nil if the packet is malformed)
+func rtpPayload(packet []byte) []byte {
+	if len(packet) < rtp.MinRTPHeaderSize {
+		return nil
+	}
+	offset := rtp.MinRTPHeaderSize + 4*int(packet[0]&0x0F)
+	if packet[0]&0x10 != 0 && len(packet) >= offset+4 {
+		offset += 4 + 4*int(binary.BigEndian.Uint16(packet[offset+2:offset+4]))
+	}
+	end := len(packet)
+	if packet[0]&0x20 != 0 && end > 0 {
+		end -= int(packet[end-1])
+	}
+	if end < offset {
+		return nil
+	}
+	return packet[offset:end]
+}
+
+// sessionSDP returns the SDP this session's tracks refer to: the SDP it
+// announced itself, otherwise the one served for DESCRIBE (the stream's
+// announced SDP, one generated from its metadata, or the default), which
+// offers RTX retransmission when enabled
+func (s *Session) sessionSDP() (*SDP, error) {
+	if s.announcedSDP != nil {
+		return s.announcedSDP, nil
+	}
+	sdp, err := s.describedSDP()
+	if err != nil {
+		return nil, err
+	}
+	if s.rtx {
+		addRTXFormats(sdp)
+	}
+	return sdp, nil
+}
+
+// describedSDP returns the stream's announced SDP, one generated from its metadata, or the default
+func (s *Session) describedSDP() (*SDP, error) {
+	if s.streamManager != nil {
+		if stream := s.streamManager.GetStream(s.streamPath); stream != nil {
+			if announced := stream.GetSDP(); announced != "" {
+				sdp, err := ParseSDP(announced)
+				if err == nil {
+					// The publisher's addresses are private to it; behind NAT advertise the configured one
+					if s.sdpOptions.Address != "" {
+						sdp.SetAddress(sdpAddress(s.advertisedIP()))
+					}
+					return sdp, nil
+				}
+				slog.Warn("Invalid announced SDP, using default", "sessionId", s.sessionId, "streamPath", s.streamPath, "err", err)
+			} else if metadata := stream.GetMetadata(); metadata != nil {
+				codecs, err := CodecsFromMetadata(metadata)
+				if err != nil {
+					return nil, err
+				}
+				return s.generateSDP(codecs)
+			}
+		}
+	}
+	return s.generateDetailedSDP(), nil
+}
+
+// trackForURI resolves a SETUP URI to its track type and a track carrying the
+// payload type and packetization mode, by matching it against the media
+// control attributes of the session SDP
+func (s *Session) trackForURI(uri string) (TrackType, *sessionTrack) {
+	sdp, err := s.sessionSDP()
+	if err != nil {
+		return TrackVideo, newVideoTrack()
+	}
+	for _, media := range sdp.Media {
+		control := media.Control()
+		if control == "" || control == "*" {
+			continue
+		}
+		if uri != control && !strings.HasSuffix(uri, "/"+control) {
+			continue
+		}
+
+		trackType := TrackVideo
+		track := newVideoTrack()
+		if media.Type == "audio" {
+			trackType = TrackAudio
+			track.payloadType = rtp.PayloadTypeAAC
+			track.clockRate = 0 // AAC is clocked at its sample rate, known only from the rtpmap
+			track.codec = codec.AAC
+		}
+		if len(media.Formats) > 0 {
+			if pt, err := strconv.ParseUint(media.Formats[0], 10, 7); err == nil {
+				track.payloadType = uint8(pt)
+				track.codec = rtp.CodecForPayloadType(track.payloadType, media.RTPMap(int(pt)))
+			}
+		}
+		if trackType == TrackVideo {
+			track.packetizationMode = h264PacketizationMode(media.Fmtp(int(track.payloadType)))
+		}
+		if rtxPT, ok := media.RTXPayloadType(int(track.payloadType)); ok {
+			track.rtxPayloadType = uint8(rtxPT)
+		}
+		if clockRate, ok := media.ClockRate(int(track.payloadType)); ok {
+			track.clockRate = clockRate
+		}
+		return trackType, track
+	}
+
+	// Aggregate or unknown control URL: treat as the video track
+	return TrackVideo, newVideoTrack()
+}
+
+// newVideoTrack returns an H.264 track using the packetization mode of the default SDP
+func newVideoTrack() *sessionTrack {
+	return &sessionTrack{
+		payloadType:       rtp.PayloadTypeH264,
+		packetizationMode: rtp.PacketizationModeNonInterleaved,
+		clockRate:         codec.H264.RTPClockRate(),
+		codec:             codec.H264,
+	}
+}
+
+// newRepacketizer returns the repacketizer fitting relayed payloads of the
+// track to the MTU, nil for codecs whose payloads cannot be split
+func (t *sessionTrack) newRepacketizer(mtu int) rtp.Repacketizer {
+	switch t.codec {
+	case codec.H264:
+		return rtp.NewH264PacketizerWithMode(mtu, t.packetizationMode)
+	case codec.AAC:
+		return rtp.NewAACPacketizer(mtu)
+	}
+	return nil
+}
+
+// h264PacketizationMode parses packetization-mode from H.264 fmtp parameters.
+// Without the parameter mode 0 applies (RFC 6184 section 8.1); the interleaved
+// mode 2 is not supported, so anything but 0 falls back to mode 1
+func h264PacketizationMode(fmtp string) rtp.PacketizationMode {
+	value, found := fmtpParameter(fmtp, "packetization-mode")
+	if !found || value == "0" {
+		return rtp.PacketizationModeSingleNAL
+	}
+	return rtp.PacketizationModeNonInterleaved
+}
+
+// fmtpParameter returns the value of a key=value parameter of fmtp parameters
+func fmtpParameter(fmtp, name string) (string, bool) {
+	for _, param := range strings.Split(fmtp, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found && strings.EqualFold(key, name) {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// NewVideoPacketizer returns an H.264 packetizer for the given MTU that follows
+// the packetization mode negotiated for the session's video track
+func (s *Session) NewVideoPacketizer(mtu int) *rtp.H264Packetizer {
+	mode := rtp.PacketizationModeNonInterleaved
+	if track := s.tracks[TrackVideo]; track != nil {
+		mode = track.packetizationMode
+	}
+	return rtp.NewH264PacketizerWithMode(mtu, mode)
+}
+
+// newSSRC picks a random SSRC not used by another RTP session on the transport
+func (s *Session) newSSRC() uint32 {
+	for {
+		ssrc := rand.Uint32()
+		if ssrc == 0 {
+			continue
+		}
+		if s.rtpTransport != nil && s.rtpTransport.GetSession(ssrc) != nil {
+			continue
+		}
+		if s.hasSSRC(ssrc) {
+			continue
+		}
+		return ssrc
+	}
+}
+
+// hasSSRC reports whether one of the session's tracks already uses or was offered ssrc
+func (s *Session) hasSSRC(ssrc uint32) bool {
+	for _, track := range s.tracks {
+		if track.ssrc == ssrc || track.rtxSSRC == ssrc {
+			return true
+		}
+	}
+	for _, offered := range s.offeredSSRCs {
+		if offered == ssrc {
+			return true
+		}
+	}
+	for _, offered := range s.offeredRTXSSRCs {
+		if offered == ssrc {
+			return true
+		}
+	}
+	return false
+}
+
+// advertiseSSRCs picks the SSRC of each track when the stream is described
+// and puts it in the media section (a=ssrc), so the SDP, the SETUP and PLAY
+// responses and the RTP packets all carry the same value. A publisher's own
+// ssrc lines are replaced, as relayed packets are restamped. Media offering
+// RTX also get the SSRC of their RTX stream, grouped with the media one.
+func (s *Session) advertiseSSRCs(sdp *SDP) {
+	for _, media := range sdp.Media {
+		trackType := TrackVideo
+		if media.Type == "audio" {
+			trackType = TrackAudio
+		}
+		ssrc, ok := s.offeredSSRCs[trackType]
+		if !ok {
+			ssrc = s.newSSRC()
+			s.offeredSSRCs[trackType] = ssrc
+		}
+		media.SetSSRC(ssrc, s.sessionId)
+
+		if !s.rtx || len(media.Formats) == 0 {
+			continue
+		}
+		pt, err := strconv.Atoi(media.Formats[0])
+		if err != nil {
+			continue
+		}
+		if _, ok := media.RTXPayloadType(pt); !ok {
+			continue
+		}
+		rtxSSRC, ok := s.offeredRTXSSRCs[trackType]
+		if !ok {
+			rtxSSRC = s.newSSRC()
+			s.offeredRTXSSRCs[trackType] = rtxSSRC
+		}
+		media.SetRTXSSRC(ssrc, rtxSSRC, s.sessionId)
+	}
+}
+
+// trackSSRC returns the SSRC offered for the track in DESCRIBE, or a new one
+// when none was offered or another RTP session on the transport took it since
+func (s *Session) trackSSRC(trackType TrackType) uint32 {
+	if ssrc, ok := s.offeredSSRCs[trackType]; ok && (s.rtpTransport == nil || s.rtpTransport.GetSession(ssrc) == nil) {
+		return ssrc
+	}
+	return s.newSSRC()
+}
+
+// trackRTXSSRC returns the RTX SSRC offered for the track in DESCRIBE, or a new
+// one when none was offered or another RTP session on the transport took it since
+func (s *Session) trackRTXSSRC(trackType TrackType) uint32 {
+	if ssrc, ok := s.offeredRTXSSRCs[trackType]; ok && (s.rtpTransport == nil || s.rtpTransport.GetSession(ssrc) == nil) {
+		return ssrc
+	}
+	return s.newSSRC()
+}
+
+// rtpInfo builds the RTP-Info header of the PLAY response: one entry per set
+// up track with its SSRC and RTP position, or just the request URL when no
+// track is set up
+func (s *Session) rtpInfo(requestURI string) string {
+	var entries []string
+	for _, trackType := range []TrackType{TrackVideo, TrackAudio} {
+		if track := s.tracks[trackType]; track != nil {
+			seq, rtptime := track.rtpPosition()
+			entries = append(entries, fmt.Sprintf("url=%s;seq=%d;rtptime=%d;ssrc=%08X", track.uri, seq, rtptime, track.ssrc))
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("url=%s;seq=0;rtptime=0", requestURI)
+	}
+	return strings.Join(entries, ",")
+}
+
+// HasTrack returns true if the session set up the given track
+func (s *Session) HasTrack(trackType TrackType) bool {
+	_, ok := s.tracks[trackType]
+	return ok
+}
+
+// trackForChannel returns the track whose interleaved RTP channel matches
+func (s *Session) trackForChannel(channel int) (TrackType, *sessionTrack, bool) {
+	for trackType, track := range s.tracks {
+		if track.rtpChannel == channel {
+			return trackType, track, true
+		}
+	}
+	return 0, nil, false
+}
+
+// checkPayloadType rejects a received packet that carries neither the track's
+// negotiated payload type nor that of its RTX stream
+func (t *sessionTrack) checkPayloadType(payloadType uint8) error {
+	if payloadType == t.payloadType || (t.rtxPayloadType != 0 && payloadType == t.rtxPayloadType) {
+		return nil
+	}
+	return fmt.Errorf("unexpected payload type %d (track uses %d)", payloadType, t.payloadType)
+}
+
+// dropMalformedPacket counts and logs an RTP packet from the client that is not
+// forwarded because it failed validation
+func (s *Session) dropMalformedPacket(trackType TrackType, size int, err error) {
+	s.malformedRTP.Add(1)
+	slog.Debug("Malformed RTP packet dropped", "sessionId", s.sessionId, "track", trackType, "size", size, "err", err)
+}
+
+// GetMalformedPacketCount returns the number of RTP packets from the client
+// dropped for failing validation (bad header or unexpected payload type)
+func (s *Session) GetMalformedPacketCount() uint64 {
+	return s.malformedRTP.Load()
+}
+
+// SendTrackRTPPacket sends an RTP packet on one of the session's tracks,
+// restamped with that track's SSRC and payload type
+func (s *Session) SendTrackRTPPacket(trackType TrackType, data []byte) error {
+	track := s.tracks[trackType]
+	if track == nil {
+		return fmt.Errorf("%s track is not set up", trackType)
+	}
+
+	if s.IsInterleavedMode() {
+		packet, err := restampRTPPacket(data, track.ssrc, track.payloadType)
+		if err != nil {
+			return err
+		}
+		if err := s.writeInterleavedFrame(track.rtpChannel, packet); err != nil {
+			return err
+		}
+		track.recordRelayed(packet)
+		return nil
+	}
+
+	if track.rtpSession != nil && s.rtpTransport != nil {
+		packet := &rtp.RTPPacket{}
+		if err := packet.Unmarshal(data); err != nil {
+			return fmt.Errorf("invalid RTP packet: %v", err)
+		}
+		// The publisher may packetize for a larger MTU than this player's transport
+		payloads := []rtp.RelayPayload{{Payload: rtpPayload(data), Timestamp: packet.Header.Timestamp, Marker: packet.Header.Marker}}
+		if track.packetizer != nil {
+			var err error
+			payloads, err = track.packetizer.Repacketize(payloads[0].Payload, packet.Header.Timestamp, packet.Header.Marker)
+			if err != nil {
+				return fmt.Errorf("failed to repacketize RTP packet: %w", err)
+			}
+		}
+		for _, payload := range payloads {
+			if err := s.rtpTransport.SendRTPPacket(track.ssrc, payload.Payload, payload.Timestamp, payload.Marker); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	slog.Debug("Track has no valid transport setup", "sessionId", s.sessionId, "track", trackType)
+	return nil
+}
+
+// registerUDPReceiver routes RTP the client sends from its RTP port to the
+// server listener into RTPPacketReceived events for the track (UDP ingest)
+func (s *Session) registerUDPReceiver(trackType TrackType, track *sessionTrack) error {
+	clientIP, err := udpClientHost(s.conn.RemoteAddr())
+	if err != nil {
+		return err
+	}
+	streamPath := s.streamPath
+	payloadType := track.payloadType
+
+	key, err := s.rtpTransport.RegisterReceiver(clientIP, s.clientPorts[0], func(data []byte, header *rtp.RTPHeader) {
+		s.touch()
+		// The transport only hands over packets that pass rtp.ValidateRTPPacket
+		if err := track.checkPayloadType(header.PayloadType); err != nil {
+			s.dropMalformedPacket(trackType, len(data), err)
+			return
+		}
+		if s.externalChannel == nil {
+			return
+		}
+		select {
+		case s.externalChannel <- RTPPacketReceived{
+			SessionId:   s.sessionId,
+			StreamPath:  streamPath,
+			Track:       trackType,
+			Data:        data,
+			Timestamp:   header.Timestamp,
+			PayloadType: payloadType,
+		}:
+		default:
+		}
+	})
+	if err != nil {
+		return err
+	}
+	track.receiverKey = key
+	return nil
+}
+
+// restampRTPPacket returns a copy of an RTP packet with its SSRC and payload type replaced
+func restampRTPPacket(data []byte, ssrc uint32, payloadType uint8) ([]byte, error) {
+	if len(data) < rtp.MinRTPHeaderSize {
+		return nil, fmt.Errorf("RTP packet too short: %d bytes", len(data))
+	}
+
+	packet := make([]byte, len(data))
+	copy(packet, data)
+	packet[1] = packet[1]&0x80 | payloadType&0x7F // keep the marker bit
+	packet[8] = byte(ssrc >> 24)
+	packet[9] = byte(ssrc >> 16)
+	packet[10] = byte(ssrc >> 8)
+	packet[11] = byte(ssrc)
+	return packet, nil
+}
@@ -0,0 +1,697 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sol/pkg/rtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTrack sends a SETUP for one track over TCP interleaved and discards the response
+func setupTrack(t *testing.T, session *Session, conn *bufferConn, control string, channel int) {
+	t.Helper()
+	setupTrackTransport(t, session, conn, control, fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channel, channel+1))
+}
+
+// setupRecordTrack sets up an interleaved track the client publishes on
+func setupRecordTrack(t *testing.T, session *Session, conn *bufferConn, control string, channel int) {
+	t.Helper()
+	setupTrackTransport(t, session, conn, control, fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d;mode=record", channel, channel+1))
+}
+
+func setupTrackTransport(t *testing.T, session *Session, conn *bufferConn, control, transport string) {
+	t.Helper()
+	req := NewRequest(MethodSetup, "rtsp://localhost/live/test/"+control)
+	req.SetCSeq(session.cseq + 1)
+	req.SetHeader(HeaderTransport, transport)
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for SETUP, got %d", response.StatusCode)
+	}
+	conn.buf.Reset()
+}
+
+// interleavedFrame is an RTP packet read back from the interleaved stream
+type interleavedFrame struct {
+	channel int
+	packet  []byte
+}
+
+func readInterleavedFrames(t *testing.T, conn *bufferConn) []interleavedFrame {
+	t.Helper()
+	data := conn.buf.Bytes()
+	var frames []interleavedFrame
+	for len(data) > 0 {
+		if len(data) < 4 || data[0] != '$' {
+			t.Fatalf("Invalid interleaved frame: %x", data)
+		}
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		frames = append(frames, interleavedFrame{channel: int(data[1]), packet: data[4 : 4+length]})
+		data = data[4+length:]
+	}
+	return frames
+}
+
+func newTestRTPPacket(t *testing.T, payloadType uint8, payload byte) []byte {
+	t.Helper()
+	data, err := rtp.NewRTPPacket(payloadType, 1, 1000, 0xCAFEBABE, []byte{payload}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	return data
+}
+
+func TestSetupResolvesTrackFromControl(t *testing.T) {
+	session, conn, _ := newTestSession()
+	setupTrack(t, session, conn, "track2", 2)
+
+	if !session.HasTrack(TrackAudio) || session.HasTrack(TrackVideo) {
+		t.Fatalf("Expected only the audio track to be set up, got %v", session.tracks)
+	}
+	track := session.tracks[TrackAudio]
+	if track.payloadType != rtp.PayloadTypeAAC || track.rtpChannel != 2 {
+		t.Errorf("Expected audio track with PT %d on channel 2, got PT %d on channel %d", rtp.PayloadTypeAAC, track.payloadType, track.rtpChannel)
+	}
+
+	setupTrack(t, session, conn, "track1", 0)
+	if session.tracks[TrackVideo].ssrc == track.ssrc {
+		t.Error("Expected each track to have its own SSRC")
+	}
+}
+
+func TestAudioOnlyPlayerReceivesOnlyAudio(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	player, conn, _ := newTestSession()
+	setupTrack(t, player, conn, "track2", 2)
+	stream.AddPlayer(player)
+
+	stream.BroadcastVideoRTP(newTestRTPPacket(t, rtp.PayloadTypeH264, 0x01))
+	stream.BroadcastAudioRTP(newTestRTPPacket(t, rtp.PayloadTypeH264, 0x02))
+
+	frames := readInterleavedFrames(t, conn)
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 RTP packet, got %d", len(frames))
+	}
+	if frames[0].channel != 2 {
+		t.Errorf("Expected audio channel 2, got %d", frames[0].channel)
+	}
+
+	packet := &rtp.RTPPacket{}
+	if err := packet.Unmarshal(frames[0].packet); err != nil {
+		t.Fatalf("Failed to parse RTP packet: %v", err)
+	}
+	track := player.tracks[TrackAudio]
+	if packet.Header.SSRC != track.ssrc || packet.Header.PayloadType != track.payloadType {
+		t.Errorf("Expected SSRC %x PT %d, got SSRC %x PT %d", track.ssrc, track.payloadType, packet.Header.SSRC, packet.Header.PayloadType)
+	}
+	if packet.Payload[0] != 0x02 {
+		t.Errorf("Expected audio payload, got %x", packet.Payload)
+	}
+}
+
+func TestPlayerReceivesEachTrackOnItsChannel(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	player, conn, _ := newTestSession()
+	setupTrack(t, player, conn, "track1", 0)
+	setupTrack(t, player, conn, "track2", 2)
+	stream.AddPlayer(player)
+
+	stream.BroadcastVideoRTP(newTestRTPPacket(t, rtp.PayloadTypeH264, 0x01))
+	stream.BroadcastAudioRTP(newTestRTPPacket(t, rtp.PayloadTypeAAC, 0x02))
+
+	frames := readInterleavedFrames(t, conn)
+	if len(frames) != 2 || frames[0].channel != 0 || frames[1].channel != 2 {
+		t.Fatalf("Expected video on channel 0 and audio on channel 2, got %+v", frames)
+	}
+}
+
+func TestPlayRTPInfoListsEachTrack(t *testing.T) {
+	stream := NewStream("rtsp://localhost/live/test")
+	player, conn, _ := newTestSession()
+	setupTrack(t, player, conn, "track1", 0)
+	setupTrack(t, player, conn, "track2", 2)
+	stream.AddPlayer(player)
+
+	play := func(cseq int) []string {
+		t.Helper()
+		if err := player.handleRequest(newTestRequest(MethodPlay, cseq, map[string]string{HeaderSession: player.sessionId})); err != nil {
+			t.Fatalf("Failed to handle PLAY: %v", err)
+		}
+		entries := strings.Split(readResponse(t, conn).GetHeader(HeaderRTPInfo), ",")
+		if len(entries) != 2 {
+			t.Fatalf("Expected an RTP-Info entry per track, got %q", entries)
+		}
+		conn.buf.Reset()
+		return entries
+	}
+	expectEntry := func(entry, control string, seq uint16, rtptime uint32, ssrc uint32) {
+		t.Helper()
+		expected := fmt.Sprintf("url=rtsp://localhost/live/test/%s;seq=%d;rtptime=%d;ssrc=%08X", control, seq, rtptime, ssrc)
+		if entry != expected {
+			t.Errorf("Expected RTP-Info entry %q, got %q", expected, entry)
+		}
+	}
+	video, audio := player.tracks[TrackVideo], player.tracks[TrackAudio]
+
+	// Nothing sent yet
+	entries := play(10)
+	expectEntry(entries[0], "track1", 0, 0, video.ssrc)
+	expectEntry(entries[1], "track2", 0, 0, audio.ssrc)
+
+	for _, packet := range []*rtp.RTPPacket{
+		rtp.NewRTPPacket(rtp.PayloadTypeH264, 100, 3000, 0xCAFEBABE, []byte{0x65}),
+		rtp.NewRTPPacket(rtp.PayloadTypeH264, 101, 6000, 0xCAFEBABE, []byte{0x41}),
+	} {
+		data, err := packet.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal RTP packet: %v", err)
+		}
+		stream.BroadcastVideoRTP(data)
+	}
+	data, err := rtp.NewRTPPacket(rtp.PayloadTypeAAC, 7, 44100, 0xDEADBEEF, []byte{0x21}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	stream.BroadcastAudioRTP(data)
+
+	if err := player.handleRequest(newTestRequest(MethodPause, 11, map[string]string{HeaderSession: player.sessionId})); err != nil {
+		t.Fatalf("Failed to handle PAUSE: %v", err)
+	}
+	conn.buf.Reset()
+
+	// Each track resumes from its own position
+	entries = play(12)
+	expectEntry(entries[0], "track1", 102, 6000, video.ssrc)
+	expectEntry(entries[1], "track2", 8, 44100, audio.ssrc)
+}
+
+func TestInterleavedPublisherPacketCarriesTrack(t *testing.T) {
+	session, conn, channel := newTestSession()
+	setupRecordTrack(t, session, conn, "track1", 0)
+	setupRecordTrack(t, session, conn, "track2", 2)
+
+	// handleRequests peeks the '$' and leaves the whole frame to handleInterleavedData
+	packet := newTestRTPPacket(t, rtp.PayloadTypeAAC, 0x02)
+	frame := []byte{'$', 2, byte(len(packet) >> 8), byte(len(packet))}
+	session.conn = &readConn{bufferConn: conn, data: append(frame, packet...)}
+	session.reader = NewMessageReader(session.conn)
+	if err := session.handleInterleavedData(); err != nil {
+		t.Fatalf("Failed to handle interleaved data: %v", err)
+	}
+
+	event, ok := (<-channel).(RTPPacketReceived)
+	if !ok || event.Track != TrackAudio || event.PayloadType != rtp.PayloadTypeAAC {
+		t.Fatalf("Expected audio RTPPacketReceived, got %+v", event)
+	}
+}
+
+func TestInterleavedPlayerPacketNotForwarded(t *testing.T) {
+	session, conn, channel := newTestSession()
+	setupTrack(t, session, conn, "track1", 0)
+
+	// A PLAY client sending RTP on its own channel must not feed the stream
+	packet := newTestRTPPacket(t, rtp.PayloadTypeH264, 0x65)
+	frame := []byte{'$', 0, byte(len(packet) >> 8), byte(len(packet))}
+	session.conn = &readConn{bufferConn: conn, data: append(frame, packet...)}
+	session.reader = NewMessageReader(session.conn)
+	if err := session.handleInterleavedData(); err != nil {
+		t.Fatalf("Failed to handle interleaved data: %v", err)
+	}
+
+	if len(channel) != 0 {
+		t.Fatalf("Expected no RTPPacketReceived from a playing session, got %+v", <-channel)
+	}
+}
+
+func TestInterleavedIngestDropsMalformedPackets(t *testing.T) {
+	session, conn, channel := newTestSession()
+	setupRecordTrack(t, session, conn, "track1", 0)
+
+	valid := newTestRTPPacket(t, rtp.PayloadTypeH264, 0x65)
+	frames := [][]byte{
+		[]byte("not an RTP packet at all"),
+		newTestRTPPacket(t, rtp.PayloadTypePCMA, 0x01), // another track's payload type
+		valid,
+	}
+	var data []byte
+	for _, frame := range frames {
+		data = append(data, '$', 0, byte(len(frame)>>8), byte(len(frame)))
+		data = append(data, frame...)
+	}
+	session.conn = &readConn{bufferConn: conn, data: data}
+	session.reader = NewMessageReader(session.conn)
+	for range frames {
+		if err := session.handleInterleavedData(); err != nil {
+			t.Fatalf("Expected malformed packets to be dropped without closing the session, got %v", err)
+		}
+	}
+
+	if count := session.GetMalformedPacketCount(); count != 2 {
+		t.Errorf("Expected 2 malformed packets, got %d", count)
+	}
+	if len(channel) != 1 {
+		t.Fatalf("Expected only the valid packet to be forwarded, got %d events", len(channel))
+	}
+	event, ok := (<-channel).(RTPPacketReceived)
+	if !ok || !bytes.Equal(event.Data, valid) || event.Timestamp != 1000 {
+		t.Errorf("Expected the valid packet with its RTP timestamp, got %+v", event)
+	}
+}
+
+// readConn serves fixed data to Read on top of a bufferConn
+type readConn struct {
+	*bufferConn
+	data []byte
+}
+
+func (c *readConn) Read(p []byte) (int, error) {
+	n := copy(p, c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func TestInterleavedRoundTrip(t *testing.T) {
+	session, conn, channel := newTestSession()
+	setupRecordTrack(t, session, conn, "track1", 0)
+
+	// Loop the session over a pipe so frames go through the request reader
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	session.conn = serverConn
+	session.reader = NewMessageReader(serverConn)
+	session.writer = NewMessageWriter(serverConn)
+	done := make(chan struct{})
+	go func() {
+		session.handleRequests()
+		close(done)
+	}()
+	clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// Client -> server: a '$' frame longer than 255 bytes
+	payload := bytes.Repeat([]byte{0x5A}, 300)
+	packet, err := rtp.NewRTPPacket(rtp.PayloadTypeH264, 1, 1000, 0xCAFEBABE, payload).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	frame := append([]byte{'$', 0, byte(len(packet) >> 8), byte(len(packet))}, packet...)
+	if _, err := clientConn.Write(frame); err != nil {
+		t.Fatalf("Failed to write interleaved frame: %v", err)
+	}
+
+	var event RTPPacketReceived
+	select {
+	case received := <-channel:
+		event = received.(RTPPacketReceived)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected RTPPacketReceived event")
+	}
+	if event.Track != TrackVideo || !bytes.Equal(event.Data, packet) {
+		t.Fatalf("Expected video packet of %d bytes, got track %s with %d bytes", len(packet), event.Track, len(event.Data))
+	}
+
+	// Server -> client: exact '$' + channel + length + data framing
+	sent := make(chan error, 1)
+	go func() { sent <- session.writeInterleavedFrame(0, packet) }()
+	received := make([]byte, len(frame))
+	if _, err := io.ReadFull(clientConn, received); err != nil {
+		t.Fatalf("Failed to read interleaved frame: %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("Failed to send interleaved frame: %v", err)
+	}
+	if !bytes.Equal(received, frame) {
+		t.Errorf("Expected frame header %x, got %x", frame[:4], received[:4])
+	}
+
+	clientConn.Close()
+	<-done
+}
+
+func TestOversizedInterleavedFrameClosesSession(t *testing.T) {
+	session, conn, channel := newTestSession()
+	setupTrack(t, session, conn, "track1", 0)
+	session.maxFrameSize = 1500
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	session.conn = serverConn
+	session.reader = NewMessageReader(serverConn)
+	session.writer = NewMessageWriter(serverConn)
+	done := make(chan struct{})
+	go func() {
+		session.handleRequests()
+		close(done)
+	}()
+	clientConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// A header claiming 65535 bytes; the session must not wait for (or allocate) the payload
+	if _, err := clientConn.Write([]byte{'$', 0, 0xFF, 0xFF}); err != nil {
+		t.Fatalf("Failed to write interleaved header: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected session to stop on an oversized interleaved frame")
+	}
+	if _, err := clientConn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected connection to be closed, got %v", err)
+	}
+	for len(channel) > 0 {
+		if event, ok := (<-channel).(RTPPacketReceived); ok {
+			t.Errorf("Expected no packet from an oversized frame, got %d bytes", len(event.Data))
+		}
+	}
+}
+
+func TestSetupNegotiatesPacketizationMode(t *testing.T) {
+	// Default SDP advertises packetization-mode=1
+	session, conn, _ := newTestSession()
+	setupTrack(t, session, conn, "track1", 0)
+	if mode := session.tracks[TrackVideo].packetizationMode; mode != rtp.PacketizationModeNonInterleaved {
+		t.Fatalf("Expected packetization-mode 1 from the default SDP, got %d", mode)
+	}
+	if mode := session.NewVideoPacketizer(1200).Mode(); mode != rtp.PacketizationModeNonInterleaved {
+		t.Errorf("Expected mode 1 packetizer, got %d", mode)
+	}
+
+	// A legacy publisher announcing packetization-mode=0
+	sdp := NewSDP("legacy")
+	sdp.AddMedia("video", 0, "RTP/AVP", 96).
+		AddRTPMap(96, "H264/90000").
+		AddFmtp(96, "profile-level-id=42e01f;packetization-mode=0").
+		SetControl("track1")
+	session, conn, _ = newTestSession()
+	session.announcedSDP = sdp
+	setupTrack(t, session, conn, "track1", 0)
+	if mode := session.tracks[TrackVideo].packetizationMode; mode != rtp.PacketizationModeSingleNAL {
+		t.Fatalf("Expected packetization-mode 0 from the announced SDP, got %d", mode)
+	}
+
+	packetizer := session.NewVideoPacketizer(1200)
+	if _, err := packetizer.Packetize(make([]byte, 2000)); !errors.Is(err, rtp.ErrNALUTooLarge) {
+		t.Errorf("Expected mode 0 packetizer to reject an oversized NAL unit, got %v", err)
+	}
+}
+
+func TestSetupNegotiatesRTX(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	session, conn, _ := newTestSession()
+	session.rtpTransport = transport
+	session.rtx = true
+
+	if err := session.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	sdp := string(readResponse(t, conn).Body)
+	for _, line := range []string{
+		"m=video 0 RTP/AVP 96 98\r\n",
+		"a=rtpmap:98 rtx/90000\r\n",
+		"a=fmtp:98 apt=96\r\n",
+		"m=audio 0 RTP/AVP 97 99\r\n",
+		"a=rtpmap:99 rtx/48000\r\n",
+		"a=fmtp:99 apt=97\r\n",
+	} {
+		if !strings.Contains(sdp, line) {
+			t.Errorf("Expected SDP to contain %q:\n%s", line, sdp)
+		}
+	}
+	for _, trackType := range []TrackType{TrackVideo, TrackAudio} {
+		ssrc, rtxSSRC := session.offeredSSRCs[trackType], session.offeredRTXSSRCs[trackType]
+		if rtxSSRC == 0 || rtxSSRC == ssrc {
+			t.Fatalf("Expected an RTX SSRC of its own for %v, got %x (ssrc %x)", trackType, rtxSSRC, ssrc)
+		}
+		for _, line := range []string{
+			fmt.Sprintf("a=ssrc-group:FID %d %d\r\n", ssrc, rtxSSRC),
+			fmt.Sprintf("a=ssrc:%d cname:%s\r\n", rtxSSRC, session.sessionId),
+		} {
+			if !strings.Contains(sdp, line) {
+				t.Errorf("Expected SDP to contain %q:\n%s", line, sdp)
+			}
+		}
+	}
+
+	req := NewRequest(MethodSetup, "rtsp://localhost/live/test/track1")
+	req.SetCSeq(2)
+	req.SetHeader(HeaderTransport, "RTP/AVP;unicast;client_port=5000-5001")
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for SETUP, got %d", response.StatusCode)
+	}
+
+	track := session.tracks[TrackVideo]
+	if track.rtxPayloadType != rtp.PayloadTypeH264RTX || track.rtxSSRC != session.offeredRTXSSRCs[TrackVideo] {
+		t.Fatalf("Expected RTX payload type %d with the offered SSRC %x, got %d (rtx ssrc %x)", rtp.PayloadTypeH264RTX, session.offeredRTXSSRCs[TrackVideo], track.rtxPayloadType, track.rtxSSRC)
+	}
+	if !track.rtpSession.RTXEnabled() {
+		t.Error("Expected RTX to be enabled on the UDP RTP session")
+	}
+
+	// Without RTX the SDP offers only the media payload types
+	session, conn, _ = newTestSession()
+	if err := session.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	if sdp := string(readResponse(t, conn).Body); strings.Contains(sdp, "rtx/") || strings.Contains(sdp, "ssrc-group") {
+		t.Errorf("Expected no RTX in the SDP when disabled:\n%s", sdp)
+	}
+}
+
+func TestH264PacketizationModeFromFmtp(t *testing.T) {
+	tests := []struct {
+		fmtp     string
+		expected rtp.PacketizationMode
+	}{
+		{"packetization-mode=1;sprop-parameter-sets=Z0LAHpWgUH5PIAEAAAMAEAAAAwPA8UKZYA==,aMuBcsg=", rtp.PacketizationModeNonInterleaved},
+		{"profile-level-id=42e01f; packetization-mode=0", rtp.PacketizationModeSingleNAL},
+		{"profile-level-id=42e01f", rtp.PacketizationModeSingleNAL}, // absent means mode 0
+		{"", rtp.PacketizationModeSingleNAL},
+		{"packetization-mode=2", rtp.PacketizationModeNonInterleaved}, // interleaved mode is not supported
+	}
+
+	for _, tt := range tests {
+		if mode := h264PacketizationMode(tt.fmtp); mode != tt.expected {
+			t.Errorf("fmtp %q: Expected mode %d, got %d", tt.fmtp, tt.expected, mode)
+		}
+	}
+}
+
+func TestUDPTrackSendKeepsFrameTimestampsAndMarkers(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, clientPort := listenEvenUDPPort(t)
+	session, _, _ := newTestSession()
+	session.rtpTransport = transport
+	const ssrc = 0x0BADCAFE
+	rtpSession, err := transport.CreateSession(ssrc, rtp.PayloadTypeH264, clientPort, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create RTP session: %v", err)
+	}
+	session.tracks[TrackVideo] = &sessionTrack{ssrc: ssrc, payloadType: rtp.PayloadTypeH264, rtpSession: rtpSession}
+
+	// Publisher packets: two frames, the second split in two, with the publisher's own SSRC and sequence
+	source := []struct {
+		seq       uint16
+		timestamp uint32
+		marker    bool
+	}{
+		{100, 90000, true},
+		{101, 93000, false},
+		{105, 93000, true},
+	}
+	for _, p := range source {
+		packet := rtp.NewRTPPacket(rtp.PayloadTypeH264, p.seq, p.timestamp, 0x11111111, []byte{0x65, 0x01})
+		packet.SetMarker(p.marker)
+		data, err := packet.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal RTP packet: %v", err)
+		}
+		if err := session.SendTrackRTPPacket(TrackVideo, data); err != nil {
+			t.Fatalf("Failed to send RTP packet: %v", err)
+		}
+	}
+
+	var firstSeq uint16
+	for i, p := range source {
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, rtp.MaxRTPPacketSize)
+		n, _, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Expected RTP packet %d, got error: %v", i, err)
+		}
+		packet := &rtp.RTPPacket{}
+		if err := packet.Unmarshal(buf[:n]); err != nil {
+			t.Fatalf("Failed to parse RTP packet: %v", err)
+		}
+
+		if i == 0 {
+			firstSeq = packet.Header.SequenceNumber
+		}
+		if packet.Header.SequenceNumber != firstSeq+uint16(i) {
+			t.Errorf("Expected sequence %d, got %d", firstSeq+uint16(i), packet.Header.SequenceNumber)
+		}
+		if packet.Header.SSRC != ssrc {
+			t.Errorf("Expected the track SSRC %#x, got %#x", ssrc, packet.Header.SSRC)
+		}
+		if packet.Header.Timestamp != p.timestamp || packet.Header.Marker != p.marker {
+			t.Errorf("Expected timestamp %d marker %t, got %d %t", p.timestamp, p.marker, packet.Header.Timestamp, packet.Header.Marker)
+		}
+	}
+}
+
+func TestAdvertisedSSRCMatchesSentPackets(t *testing.T) {
+	transport := rtp.NewRTPTransport()
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, clientPort := listenEvenUDPPort(t)
+	session, conn, _ := newTestSession()
+	session.rtpTransport = transport
+	defer session.Stop()
+
+	if err := session.handleRequest(newTestRequest(MethodDescribe, 1, nil)); err != nil {
+		t.Fatalf("Failed to handle DESCRIBE: %v", err)
+	}
+	sdp, err := ParseSDP(string(readResponse(t, conn).Body))
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	var advertised uint32
+	for _, media := range sdp.Media {
+		ssrc, ok := media.SSRC()
+		if !ok {
+			t.Fatalf("Expected a=ssrc in the %s media section", media.Type)
+		}
+		if media.Type == "video" {
+			advertised = ssrc
+		}
+	}
+	expected := fmt.Sprintf("ssrc=%08X", advertised)
+
+	setup := newTestRequest(MethodSetup, 2, map[string]string{
+		HeaderTransport: fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", clientPort, clientPort+1),
+	})
+	setup.URI += "/track1"
+	if err := session.handleRequest(setup); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if transportHeader := readResponse(t, conn).GetHeader(HeaderTransport); !strings.Contains(transportHeader, expected) {
+		t.Fatalf("Expected %s in Transport, got %q", expected, transportHeader)
+	}
+
+	if err := session.handleRequest(newTestRequest(MethodPlay, 3, map[string]string{HeaderSession: session.sessionId})); err != nil {
+		t.Fatalf("Failed to handle PLAY: %v", err)
+	}
+	if rtpInfo := readResponse(t, conn).GetHeader(HeaderRTPInfo); !strings.Contains(rtpInfo, "/track1;") || !strings.Contains(rtpInfo, expected) {
+		t.Fatalf("Expected track1 with %s in RTP-Info, got %q", expected, rtpInfo)
+	}
+
+	data, err := rtp.NewRTPPacket(rtp.PayloadTypeH264, 1, 90000, 0x11111111, []byte{0x65, 0x01}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	if err := session.SendTrackRTPPacket(TrackVideo, data); err != nil {
+		t.Fatalf("Failed to send RTP packet: %v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, rtp.MaxRTPPacketSize)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected an RTP packet, got error: %v", err)
+	}
+	packet := &rtp.RTPPacket{}
+	if err := packet.Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Failed to parse RTP packet: %v", err)
+	}
+	if packet.Header.SSRC != advertised {
+		t.Fatalf("Expected packets with the advertised SSRC %#x, got %#x", advertised, packet.Header.SSRC)
+	}
+}
+
+func TestUDPPlayerRepacketizesToTransportMTU(t *testing.T) {
+	const mtu = 600
+	transport := rtp.NewRTPTransport()
+	if err := transport.SetMTU(mtu); err != nil {
+		t.Fatalf("Failed to set MTU: %v", err)
+	}
+	if err := transport.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start RTP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, clientPort := listenEvenUDPPort(t)
+
+	session, conn, _ := newTestSession()
+	session.rtpTransport = transport
+	req := NewRequest(MethodSetup, "rtsp://localhost/live/test/track1")
+	req.SetCSeq(1)
+	req.SetHeader(HeaderTransport, fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", clientPort, clientPort+1))
+	if err := session.handleRequest(req); err != nil {
+		t.Fatalf("Failed to handle SETUP: %v", err)
+	}
+	if response := readResponse(t, conn); response.StatusCode != StatusOK {
+		t.Fatalf("Expected 200 for SETUP, got %d", response.StatusCode)
+	}
+
+	// The publisher sent a 1400 byte IDR slice in one packet (larger MTU on its side)
+	nalu := make([]byte, 1400)
+	nalu[0] = 0x65
+	packet := rtp.NewRTPPacket(rtp.PayloadTypeH264, 1, 9000, 0xCAFEBABE, nalu)
+	packet.SetMarker(true)
+	data, err := packet.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal RTP packet: %v", err)
+	}
+	if err := session.SendTrackRTPPacket(TrackVideo, data); err != nil {
+		t.Fatalf("Expected the oversized packet to be fragmented, got %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	var reassembled []byte
+	for end := false; !end; {
+		n, _, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read fragment: %v", err)
+		}
+		if n > mtu {
+			t.Errorf("Expected packets within the %d byte MTU, got %d bytes", mtu, n)
+		}
+		received := &rtp.RTPPacket{}
+		if err := received.Unmarshal(buf[:n]); err != nil {
+			t.Fatalf("Failed to parse RTP packet: %v", err)
+		}
+		if received.Payload[0]&0x1F != rtp.NALTypeFUA || received.Header.Timestamp != 9000 {
+			t.Fatalf("Expected FU-A fragments at timestamp 9000, got type %d at %d", received.Payload[0]&0x1F, received.Header.Timestamp)
+		}
+		end = received.Payload[1]&0x40 != 0
+		if received.Header.Marker != end {
+			t.Errorf("Expected the marker only on the last fragment")
+		}
+		reassembled = append(reassembled, received.Payload[2:]...)
+	}
+	if !bytes.Equal(reassembled, nalu[1:]) {
+		t.Errorf("Expected the fragments to carry the whole NAL unit")
+	}
+}
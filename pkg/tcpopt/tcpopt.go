@@ -0,0 +1,62 @@
+// Package tcpopt applies socket options to connections accepted by the
+// RTMP and RTSP servers
+package tcpopt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Options are the socket options set on each accepted TCP connection.
+// A nil *Options leaves connections with the Go defaults (no-delay on,
+// keepalive every 15 seconds).
+type Options struct {
+	NoDelay     bool          // TCP_NODELAY: send small writes (e.g. audio chunks) without waiting to coalesce them
+	KeepAlive   time.Duration // SO_KEEPALIVE probe interval (0 disables keepalive)
+	ReadBuffer  int           // SO_RCVBUF in bytes (0 keeps the OS default)
+	WriteBuffer int           // SO_SNDBUF in bytes (0 keeps the OS default)
+}
+
+// socket is the part of *net.TCPConn the options are set through
+type socket interface {
+	SetNoDelay(noDelay bool) error
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(period time.Duration) error
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+}
+
+// Apply sets the options on conn. Connections that are not TCP sockets
+// (e.g. wrapped or in-memory connections) are left as they are.
+// Every option is attempted; failures are joined into the returned error.
+func (o *Options) Apply(conn net.Conn) error {
+	s, ok := conn.(socket)
+	if o == nil || !ok {
+		return nil
+	}
+
+	var errs []error
+	if err := s.SetNoDelay(o.NoDelay); err != nil {
+		errs = append(errs, fmt.Errorf("set no-delay: %w", err))
+	}
+	if err := s.SetKeepAlive(o.KeepAlive > 0); err != nil {
+		errs = append(errs, fmt.Errorf("set keepalive: %w", err))
+	} else if o.KeepAlive > 0 {
+		if err := s.SetKeepAlivePeriod(o.KeepAlive); err != nil {
+			errs = append(errs, fmt.Errorf("set keepalive period: %w", err))
+		}
+	}
+	if o.ReadBuffer > 0 {
+		if err := s.SetReadBuffer(o.ReadBuffer); err != nil {
+			errs = append(errs, fmt.Errorf("set read buffer: %w", err))
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := s.SetWriteBuffer(o.WriteBuffer); err != nil {
+			errs = append(errs, fmt.Errorf("set write buffer: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
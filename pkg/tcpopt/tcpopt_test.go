@@ -0,0 +1,119 @@
+package tcpopt
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// recordingConn records the socket options set on it
+type recordingConn struct {
+	net.Conn
+	noDelay         *bool
+	keepAlive       *bool
+	keepAlivePeriod time.Duration
+	readBuffer      int
+	writeBuffer     int
+	failReadBuffer  bool
+}
+
+func (c *recordingConn) SetNoDelay(noDelay bool) error {
+	c.noDelay = &noDelay
+	return nil
+}
+
+func (c *recordingConn) SetKeepAlive(keepalive bool) error {
+	c.keepAlive = &keepalive
+	return nil
+}
+
+func (c *recordingConn) SetKeepAlivePeriod(period time.Duration) error {
+	c.keepAlivePeriod = period
+	return nil
+}
+
+func (c *recordingConn) SetReadBuffer(bytes int) error {
+	if c.failReadBuffer {
+		return errors.New("not permitted")
+	}
+	c.readBuffer = bytes
+	return nil
+}
+
+func (c *recordingConn) SetWriteBuffer(bytes int) error {
+	c.writeBuffer = bytes
+	return nil
+}
+
+func TestApplySetsOptions(t *testing.T) {
+	conn := &recordingConn{}
+	options := &Options{NoDelay: true, KeepAlive: 30 * time.Second, ReadBuffer: 1 << 20, WriteBuffer: 2 << 20}
+	if err := options.Apply(conn); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if conn.noDelay == nil || !*conn.noDelay || conn.keepAlive == nil || !*conn.keepAlive || conn.keepAlivePeriod != 30*time.Second {
+		t.Errorf("expected no-delay and 30s keepalive, got noDelay=%v keepAlive=%v period=%v", conn.noDelay, conn.keepAlive, conn.keepAlivePeriod)
+	}
+	if conn.readBuffer != 1<<20 || conn.writeBuffer != 2<<20 {
+		t.Errorf("expected 1MB/2MB buffers, got %d/%d", conn.readBuffer, conn.writeBuffer)
+	}
+
+	// Zero values turn keepalive off and leave the buffers at the OS default
+	conn = &recordingConn{}
+	if err := (&Options{}).Apply(conn); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if conn.noDelay == nil || *conn.noDelay || conn.keepAlive == nil || *conn.keepAlive || conn.keepAlivePeriod != 0 {
+		t.Errorf("expected no-delay and keepalive off, got noDelay=%v keepAlive=%v period=%v", conn.noDelay, conn.keepAlive, conn.keepAlivePeriod)
+	}
+	if conn.readBuffer != 0 || conn.writeBuffer != 0 {
+		t.Errorf("expected buffers to be left alone, got %d/%d", conn.readBuffer, conn.writeBuffer)
+	}
+}
+
+func TestApplyReportsFailuresAndSkipsOtherConns(t *testing.T) {
+	conn := &recordingConn{failReadBuffer: true}
+	err := (&Options{NoDelay: true, ReadBuffer: 4096, WriteBuffer: 4096}).Apply(conn)
+	if err == nil {
+		t.Fatal("expected read buffer failure to be reported")
+	}
+	if conn.writeBuffer != 4096 {
+		t.Errorf("expected remaining options to be applied after a failure, got write buffer %d", conn.writeBuffer)
+	}
+
+	// nil options and non-TCP connections are left alone
+	var options *Options
+	if err := options.Apply(&recordingConn{}); err != nil {
+		t.Errorf("expected nil options to be a no-op, got %v", err)
+	}
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	if err := (&Options{NoDelay: true}).Apply(server); err != nil {
+		t.Errorf("expected non-TCP connection to be skipped, got %v", err)
+	}
+}
+
+func TestApplyToTCPConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	options := &Options{NoDelay: true, KeepAlive: time.Minute, ReadBuffer: 64 << 10, WriteBuffer: 64 << 10}
+	if err := options.Apply(conn); err != nil {
+		t.Fatalf("expected options to apply to a TCP connection, got %v", err)
+	}
+}